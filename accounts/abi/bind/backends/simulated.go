@@ -728,8 +728,10 @@ type filterBackend struct {
 	bc *core.BlockChain
 }
 
-func (fb *filterBackend) ChainDb() ongdb.Database  { return fb.db }
-func (fb *filterBackend) EventMux() *event.TypeMux { panic("not supported") }
+func (fb *filterBackend) ChainDb() ongdb.Database    { return fb.db }
+func (fb *filterBackend) EventMux() *event.TypeMux   { panic("not supported") }
+func (fb *filterBackend) RPCBlockRangeCap() uint64   { return 0 }
+func (fb *filterBackend) RPCPersistentFilters() bool { return false }
 
 func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumber) (*types.Header, error) {
 	if block == rpc.LatestBlockNumber {
@@ -19,8 +19,10 @@ package bind
 import (
 	"context"
 	"errors"
+	"math/big"
 	"time"
 
+	"github.com/ong2020/go-orange"
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/log"
@@ -52,13 +54,109 @@ func WaitMined(ctx context.Context, b DeployBackend, tx *types.Transaction) (*ty
 	}
 }
 
+// ConfirmationBackend is the chain access needed to wait for a transaction to
+// be mined with a number of confirmations.
+type ConfirmationBackend interface {
+	DeployBackend
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// WaitMinedConfirmations waits for tx to be mined on the blockchain and buried
+// under the given number of blocks, counting the containing block as the first
+// confirmation. If a reorg removes the containing block, it resumes waiting for
+// the transaction to be included again. It stops waiting when the context is
+// canceled.
+func WaitMinedConfirmations(ctx context.Context, b ConfirmationBackend, tx *types.Transaction, confirmations uint64) (*types.Receipt, error) {
+	if confirmations == 0 {
+		confirmations = 1
+	}
+	queryTicker := time.NewTicker(time.Second)
+	defer queryTicker.Stop()
+
+	var (
+		logger = log.New("hash", tx.Hash())
+		last   *types.Receipt
+	)
+	for {
+		receipt, err := b.TransactionReceipt(ctx, tx.Hash())
+		if errors.Is(err, orange.NotFound) {
+			receipt, err = nil, nil
+		}
+		switch {
+		case err != nil:
+			logger.Trace("Receipt retrieval failed", "err", err)
+		case receipt == nil:
+			if last != nil {
+				logger.Debug("Transaction reorged out, waiting for reinclusion", "block", last.BlockHash)
+				last = nil
+			} else {
+				logger.Trace("Transaction not yet mined")
+			}
+		default:
+			if last != nil && last.BlockHash != receipt.BlockHash {
+				logger.Debug("Transaction included in a different block", "old", last.BlockHash, "new", receipt.BlockHash)
+			}
+			last = receipt
+			if done, err := confirmed(ctx, b, receipt, confirmations); err != nil {
+				logger.Trace("Confirmation check failed", "err", err)
+			} else if done {
+				return receipt, nil
+			}
+		}
+		// Wait for the next round.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-queryTicker.C:
+		}
+	}
+}
+
+// confirmed checks whether the block containing a receipt is buried under the
+// given number of blocks and still part of the canonical chain.
+func confirmed(ctx context.Context, b ConfirmationBackend, receipt *types.Receipt, confirmations uint64) (bool, error) {
+	if receipt.BlockNumber == nil {
+		return false, errors.New("receipt without block number")
+	}
+	head, err := b.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	target := new(big.Int).Add(receipt.BlockNumber, new(big.Int).SetUint64(confirmations-1))
+	if head.Number.Cmp(target) < 0 {
+		return false, nil
+	}
+	header, err := b.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return false, err
+	}
+	return header != nil && header.Hash() == receipt.BlockHash, nil
+}
+
 // WaitDeployed waits for a contract deployment transaction and returns the on-chain
 // contract address when it is mined. It stops waiting when ctx is canceled.
 func WaitDeployed(ctx context.Context, b DeployBackend, tx *types.Transaction) (common.Address, error) {
+	return waitDeployed(ctx, b, tx, func() (*types.Receipt, error) {
+		return WaitMined(ctx, b, tx)
+	})
+}
+
+// WaitDeployedConfirmations waits for a contract deployment transaction to be
+// mined with the given number of confirmations, following reorgs, and returns
+// the on-chain contract address. It stops waiting when ctx is canceled.
+func WaitDeployedConfirmations(ctx context.Context, b ConfirmationBackend, tx *types.Transaction, confirmations uint64) (common.Address, error) {
+	return waitDeployed(ctx, b, tx, func() (*types.Receipt, error) {
+		return WaitMinedConfirmations(ctx, b, tx, confirmations)
+	})
+}
+
+// waitDeployed waits for a contract deployment transaction using the given
+// wait function and checks the contract code was indeed deployed.
+func waitDeployed(ctx context.Context, b DeployBackend, tx *types.Transaction, wait func() (*types.Receipt, error)) (common.Address, error) {
 	if tx.To() != nil {
 		return common.Address{}, errors.New("tx is not contract creation")
 	}
-	receipt, err := WaitMined(ctx, b, tx)
+	receipt, err := wait()
 	if err != nil {
 		return common.Address{}, err
 	}
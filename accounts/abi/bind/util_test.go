@@ -132,3 +132,49 @@ func TestWaitDeployedCornerCases(t *testing.T) {
 	backend.SendTransaction(ctx, tx)
 	cancel()
 }
+
+func TestWaitMinedConfirmations(t *testing.T) {
+	backend := backends.NewSimulatedBackend(
+		core.GenesisAlloc{
+			crypto.PubkeyToAddress(testKey.PublicKey): {Balance: big.NewInt(10000000000)},
+		},
+		10000000,
+	)
+	defer backend.Close()
+
+	tx := types.NewTransaction(0, common.HexToAddress("0x01"), big.NewInt(0), 21000, big.NewInt(1), nil)
+	tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+
+	var (
+		receipt *types.Receipt
+		err     error
+		mined   = make(chan struct{})
+		ctx     = context.Background()
+	)
+	go func() {
+		receipt, err = bind.WaitMinedConfirmations(ctx, backend, tx, 3)
+		close(mined)
+	}()
+	backend.SendTransaction(ctx, tx)
+	backend.Commit()
+	backend.Commit()
+
+	select {
+	case <-mined:
+		t.Fatalf("returned with 2 confirmations: %v", err)
+	case <-time.After(1500 * time.Millisecond):
+	}
+	backend.Commit()
+
+	select {
+	case <-mined:
+		if err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+		if receipt.TxHash != tx.Hash() || receipt.BlockNumber.Uint64() != 1 {
+			t.Errorf("receipt mismatch: tx %x, block %v", receipt.TxHash, receipt.BlockNumber)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for 3 confirmations")
+	}
+}
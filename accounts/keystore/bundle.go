@@ -0,0 +1,174 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ong2020/go-orange/accounts"
+	"github.com/ong2020/go-orange/common"
+)
+
+// bundleVersion is the version of the key bundle format.
+const bundleVersion = 1
+
+var errEmptyBundle = errors.New("no accounts in bundle")
+
+// bundleJSON is a key bundle: a list of plain JSON keys (including their
+// metadata), encrypted as a whole with the bundle passphrase the same way a
+// version 3 key file encrypts its key.
+type bundleJSON struct {
+	Version int        `json:"version"`
+	Count   int        `json:"count"`
+	Crypto  CryptoJSON `json:"crypto"`
+}
+
+// ExportBundle exports multiple accounts into a single JSON bundle, encrypted
+// with the bundle passphrase. The accounts are decrypted with the passphrase of
+// the same index, or with the single passphrase given for all of them.
+func (ks *KeyStore) ExportBundle(accs []accounts.Account, passphrases []string, bundlePassphrase string) ([]byte, error) {
+	if len(accs) == 0 {
+		return nil, errEmptyBundle
+	}
+	if len(passphrases) != 1 && len(passphrases) != len(accs) {
+		return nil, fmt.Errorf("passphrase count mismatch: have %d, want 1 or %d", len(passphrases), len(accs))
+	}
+	keys := make([]*Key, 0, len(accs))
+	defer func() {
+		for _, key := range keys {
+			zeroKey(key.PrivateKey)
+		}
+	}()
+	for i, a := range accs {
+		passphrase := passphrases[0]
+		if len(passphrases) > 1 {
+			passphrase = passphrases[i]
+		}
+		_, key, err := ks.getDecryptedKey(a, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("account %x: %v", a.Address, err)
+		}
+		keys = append(keys, key)
+	}
+	plain, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range plain {
+			plain[i] = 0
+		}
+	}()
+	N, P := ks.scrypt()
+	cryptoStruct, err := EncryptDataV3(plain, []byte(bundlePassphrase), N, P)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&bundleJSON{Version: bundleVersion, Count: len(keys), Crypto: cryptoStruct})
+}
+
+// ImportBundle decrypts a bundle created by ExportBundle and stores its keys into
+// the key directory, encrypted with newPassphrase. The import is atomic: if any
+// of the accounts already exists or can't be stored, none is imported.
+func (ks *KeyStore) ImportBundle(bundle []byte, bundlePassphrase, newPassphrase string) ([]accounts.Account, error) {
+	var enc bundleJSON
+	if err := json.Unmarshal(bundle, &enc); err != nil {
+		return nil, err
+	}
+	if enc.Version != bundleVersion {
+		return nil, fmt.Errorf("bundle version not supported: %v", enc.Version)
+	}
+	plain, err := DecryptDataV3(enc.Crypto, bundlePassphrase)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*Key
+	err = json.Unmarshal(plain, &keys)
+	for i := range plain {
+		plain[i] = 0
+	}
+	defer func() {
+		for _, key := range keys {
+			if key != nil && key.PrivateKey != nil {
+				zeroKey(key.PrivateKey)
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errEmptyBundle
+	}
+	ks.importMu.Lock()
+	defer ks.importMu.Unlock()
+
+	return ks.importKeys(keys, newPassphrase)
+}
+
+// ImportECDSABatch stores the given keys into the key directory, encrypting them
+// with the passphrase. The import is atomic: if any of the accounts already
+// exists or can't be stored, none is imported.
+func (ks *KeyStore) ImportECDSABatch(privs []*ecdsa.PrivateKey, passphrase string) ([]accounts.Account, error) {
+	keys := make([]*Key, len(privs))
+	for i, priv := range privs {
+		keys[i] = newKeyFromECDSA(priv)
+		keys[i].Meta = &KeyMeta{Origin: KeyOriginImport}
+	}
+	ks.importMu.Lock()
+	defer ks.importMu.Unlock()
+
+	return ks.importKeys(keys, passphrase)
+}
+
+// importKeys stores a batch of keys into the key directory, rolling back the
+// already stored ones if any fails. The caller must hold importMu.
+func (ks *KeyStore) importKeys(keys []*Key, passphrase string) ([]accounts.Account, error) {
+	seen := make(map[common.Address]bool)
+	for _, key := range keys {
+		if key == nil || key.PrivateKey == nil {
+			return nil, errors.New("invalid key in batch")
+		}
+		if seen[key.Address] || ks.cache.hasAddress(key.Address) {
+			return nil, fmt.Errorf("account %x: %w", key.Address, ErrAccountAlreadyExists)
+		}
+		seen[key.Address] = true
+	}
+	accs := make([]accounts.Account, 0, len(keys))
+	for _, key := range keys {
+		if key.Meta.empty() {
+			key.Meta = &KeyMeta{Origin: KeyOriginImport}
+		}
+		a := accounts.Account{Address: key.Address, URL: accounts.URL{Scheme: KeyStoreScheme, Path: ks.storage.JoinPath(keyFileName(key.Address))}}
+		if err := ks.storage.StoreKey(a.URL.Path, key, passphrase); err != nil {
+			for _, stored := range accs {
+				os.Remove(stored.URL.Path)
+			}
+			return nil, fmt.Errorf("account %x: %v", key.Address, err)
+		}
+		accs = append(accs, a)
+	}
+	for _, a := range accs {
+		ks.cache.add(a)
+	}
+	ks.refreshWallets()
+	return accs, nil
+}
@@ -36,7 +36,14 @@ import (
 )
 
 const (
-	version = 3
+	version     = 3
+	versionMeta = 4 // Version 3 extended with the key metadata section
+)
+
+// Key origins recorded in the metadata of keys not generated by the keystore.
+const (
+	KeyOriginImport  = "import"
+	KeyOriginPresale = "presale"
 )
 
 type Key struct {
@@ -46,6 +53,31 @@ type Key struct {
 	// we only store privkey as pubkey/address can be derived from it
 	// privkey in this struct is always in plaintext
 	PrivateKey *ecdsa.PrivateKey
+	// optional metadata, stored unencrypted in version 4 key files
+	Meta *KeyMeta
+}
+
+// KeyMeta is the optional metadata section of a key file, stored in plain text
+// next to the encrypted key. Key files carrying it use format version 4, which
+// is version 3 with the additional "meta" field.
+type KeyMeta struct {
+	Label  string   `json:"label,omitempty"`  // Human readable name of the account
+	Origin string   `json:"origin,omitempty"` // How the key entered the keystore (empty = generated)
+	Tags   []string `json:"tags,omitempty"`   // Free-form tags for grouping accounts
+}
+
+// empty reports whether the metadata holds no information.
+func (m *KeyMeta) empty() bool {
+	return m == nil || (m.Label == "" && m.Origin == "" && len(m.Tags) == 0)
+}
+
+// keyVersion returns the key file format version needed to store a key with the
+// given metadata.
+func keyVersion(meta *KeyMeta) int {
+	if meta.empty() {
+		return version
+	}
+	return versionMeta
 }
 
 type keyStore interface {
@@ -58,10 +90,11 @@ type keyStore interface {
 }
 
 type plainKeyJSON struct {
-	Address    string `json:"address"`
-	PrivateKey string `json:"privatekey"`
-	Id         string `json:"id"`
-	Version    int    `json:"version"`
+	Address    string   `json:"address"`
+	PrivateKey string   `json:"privatekey"`
+	Id         string   `json:"id"`
+	Version    int      `json:"version"`
+	Meta       *KeyMeta `json:"meta,omitempty"`
 }
 
 type encryptedKeyJSONV3 struct {
@@ -69,6 +102,7 @@ type encryptedKeyJSONV3 struct {
 	Crypto  CryptoJSON `json:"crypto"`
 	Id      string     `json:"id"`
 	Version int        `json:"version"`
+	Meta    *KeyMeta   `json:"meta,omitempty"`
 }
 
 type encryptedKeyJSONV1 struct {
@@ -93,10 +127,13 @@ type cipherparamsJSON struct {
 
 func (k *Key) MarshalJSON() (j []byte, err error) {
 	jStruct := plainKeyJSON{
-		hex.EncodeToString(k.Address[:]),
-		hex.EncodeToString(crypto.FromECDSA(k.PrivateKey)),
-		k.Id.String(),
-		version,
+		Address:    hex.EncodeToString(k.Address[:]),
+		PrivateKey: hex.EncodeToString(crypto.FromECDSA(k.PrivateKey)),
+		Id:         k.Id.String(),
+		Version:    keyVersion(k.Meta),
+	}
+	if !k.Meta.empty() {
+		jStruct.Meta = k.Meta
 	}
 	j, err = json.Marshal(jStruct)
 	return j, err
@@ -126,6 +163,7 @@ func (k *Key) UnmarshalJSON(j []byte) (err error) {
 
 	k.Address = common.BytesToAddress(addr)
 	k.PrivateKey = privkey
+	k.Meta = keyJSON.Meta
 
 	return nil
 }
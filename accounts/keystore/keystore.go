@@ -23,7 +23,10 @@ package keystore
 import (
 	"crypto/ecdsa"
 	crand "crypto/rand"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"path/filepath"
@@ -424,13 +427,16 @@ func (ks *KeyStore) Export(a accounts.Account, passphrase, newPassphrase string)
 	if err != nil {
 		return nil, err
 	}
-	var N, P int
+	N, P := ks.scrypt()
+	return EncryptKey(key, newPassphrase, N, P)
+}
+
+// scrypt returns the scrypt parameters to encrypt exported keys with.
+func (ks *KeyStore) scrypt() (int, int) {
 	if store, ok := ks.storage.(*keyStorePassphrase); ok {
-		N, P = store.scryptN, store.scryptP
-	} else {
-		N, P = StandardScryptN, StandardScryptP
+		return store.scryptN, store.scryptP
 	}
-	return EncryptKey(key, newPassphrase, N, P)
+	return StandardScryptN, StandardScryptP
 }
 
 // Import stores the given encrypted JSON key into the key directory.
@@ -450,6 +456,9 @@ func (ks *KeyStore) Import(keyJSON []byte, passphrase, newPassphrase string) (ac
 			Address: key.Address,
 		}, ErrAccountAlreadyExists
 	}
+	if key.Meta.empty() {
+		key.Meta = &KeyMeta{Origin: KeyOriginImport}
+	}
 	return ks.importKey(key, newPassphrase)
 }
 
@@ -459,6 +468,7 @@ func (ks *KeyStore) ImportECDSA(priv *ecdsa.PrivateKey, passphrase string) (acco
 	defer ks.importMu.Unlock()
 
 	key := newKeyFromECDSA(priv)
+	key.Meta = &KeyMeta{Origin: KeyOriginImport}
 	if ks.cache.hasAddress(key.Address) {
 		return accounts.Account{
 			Address: key.Address,
@@ -486,6 +496,64 @@ func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string)
 	return ks.storage.StoreKey(a.URL.Path, key, newPassphrase)
 }
 
+// Metadata returns the metadata section of the key file of an account, or nil if
+// the key file has none. The key doesn't need to be decrypted.
+func (ks *KeyStore) Metadata(a accounts.Account) (*KeyMeta, error) {
+	a, err := ks.Find(a)
+	if err != nil {
+		return nil, err
+	}
+	keyjson, err := ioutil.ReadFile(a.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Meta *KeyMeta `json:"meta"`
+	}
+	if err := json.Unmarshal(keyjson, &file); err != nil {
+		return nil, err
+	}
+	return file.Meta, nil
+}
+
+// SetMetadata replaces the metadata section of the key file of an account,
+// upgrading it to format version 4 (or downgrading it to version 3 if the new
+// metadata is empty). The encrypted key is left untouched.
+func (ks *KeyStore) SetMetadata(a accounts.Account, meta *KeyMeta) error {
+	ks.importMu.Lock()
+	defer ks.importMu.Unlock()
+
+	a, err := ks.Find(a)
+	if err != nil {
+		return err
+	}
+	keyjson, err := ioutil.ReadFile(a.URL.Path)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(keyjson, &fields); err != nil {
+		return err
+	}
+	var current int
+	if err := json.Unmarshal(fields["version"], &current); err != nil || (current != version && current != versionMeta) {
+		return fmt.Errorf("key file version %s doesn't support metadata", fields["version"])
+	}
+	if meta.empty() {
+		delete(fields, "meta")
+	} else {
+		if fields["meta"], err = json.Marshal(meta); err != nil {
+			return err
+		}
+	}
+	fields["version"], _ = json.Marshal(keyVersion(meta))
+
+	if keyjson, err = json.Marshal(fields); err != nil {
+		return err
+	}
+	return writeKeyFile(a.URL.Path, keyjson)
+}
+
 // ImportPreSaleKey decrypts the given Orange presale wallet and stores
 // a key file in the key directory. The key file is encrypted with the same passphrase.
 func (ks *KeyStore) ImportPreSaleKey(keyJSON []byte, passphrase string) (accounts.Account, error) {
@@ -17,9 +17,12 @@
 package keystore
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"reflect"
 	"runtime"
 	"sort"
 	"strings"
@@ -392,6 +395,132 @@ func TestImportExport(t *testing.T) {
 
 }
 
+// Tests that the key metadata can be edited without unlocking the key, and is
+// preserved across passphrase updates and export/import.
+func TestKeyMetadata(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	acc, err := ks.NewAccount("old")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	if meta, err := ks.Metadata(acc); err != nil || meta != nil {
+		t.Fatalf("new account metadata mismatch: have %v (%v), want none", meta, err)
+	}
+	want := &KeyMeta{Label: "treasury", Tags: []string{"cold", "ops"}}
+	if err := ks.SetMetadata(acc, want); err != nil {
+		t.Fatalf("failed to set metadata: %v", err)
+	}
+	if meta, err := ks.Metadata(acc); err != nil || !reflect.DeepEqual(meta, want) {
+		t.Fatalf("metadata mismatch: have %v (%v), want %v", meta, err, want)
+	}
+	if err := ks.Update(acc, "old", "new"); err != nil {
+		t.Fatalf("failed to update passphrase: %v", err)
+	}
+	if meta, err := ks.Metadata(acc); err != nil || !reflect.DeepEqual(meta, want) {
+		t.Fatalf("metadata mismatch after update: have %v (%v), want %v", meta, err, want)
+	}
+	json, err := ks.Export(acc, "new", "new")
+	if err != nil {
+		t.Fatalf("failed to export account: %v", err)
+	}
+	dir2, ks2 := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir2)
+
+	acc2, err := ks2.Import(json, "new", "new")
+	if err != nil {
+		t.Fatalf("failed to import account: %v", err)
+	}
+	if meta, err := ks2.Metadata(acc2); err != nil || !reflect.DeepEqual(meta, want) {
+		t.Fatalf("metadata mismatch after import: have %v (%v), want %v", meta, err, want)
+	}
+	// Clearing the metadata must restore a version 3 key file
+	if err := ks2.SetMetadata(acc2, nil); err != nil {
+		t.Fatalf("failed to clear metadata: %v", err)
+	}
+	if meta, err := ks2.Metadata(acc2); err != nil || meta != nil {
+		t.Fatalf("cleared metadata mismatch: have %v (%v), want none", meta, err)
+	}
+	if _, err := ks2.Export(acc2, "new", "new"); err != nil {
+		t.Fatalf("failed to export cleared account: %v", err)
+	}
+	// Raw key imports record their origin
+	key, _ := crypto.GenerateKey()
+	acc3, err := ks2.ImportECDSA(key, "new")
+	if err != nil {
+		t.Fatalf("failed to import key: %v", err)
+	}
+	if meta, err := ks2.Metadata(acc3); err != nil || meta == nil || meta.Origin != KeyOriginImport {
+		t.Fatalf("imported key origin mismatch: have %v (%v), want %s", meta, err, KeyOriginImport)
+	}
+}
+
+// Tests that multiple accounts can be migrated through an encrypted bundle, and
+// that bundle imports are atomic.
+func TestImportExportBundle(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	var accs []accounts.Account
+	for i := 0; i < 3; i++ {
+		acc, err := ks.NewAccount(fmt.Sprintf("pass%d", i))
+		if err != nil {
+			t.Fatalf("failed to create account: %v", err)
+		}
+		accs = append(accs, acc)
+	}
+	if err := ks.SetMetadata(accs[0], &KeyMeta{Label: "hot"}); err != nil {
+		t.Fatalf("failed to set metadata: %v", err)
+	}
+	if _, err := ks.ExportBundle(accs, []string{"pass0"}, "bundle"); err == nil {
+		t.Fatalf("exported accounts with invalid passphrases")
+	}
+	bundle, err := ks.ExportBundle(accs, []string{"pass0", "pass1", "pass2"}, "bundle")
+	if err != nil {
+		t.Fatalf("failed to export bundle: %v", err)
+	}
+	dir2, ks2 := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir2)
+
+	if _, err := ks2.ImportBundle(bundle, "wrong", "new"); err != ErrDecrypt {
+		t.Fatalf("bundle import error mismatch: have %v, want %v", err, ErrDecrypt)
+	}
+	// Pre-import one of the accounts and ensure the bundle import is rejected
+	// as a whole
+	key, err := ks.Export(accs[2], "pass2", "new")
+	if err != nil {
+		t.Fatalf("failed to export account: %v", err)
+	}
+	if _, err := ks2.Import(key, "new", "new"); err != nil {
+		t.Fatalf("failed to import account: %v", err)
+	}
+	if _, err := ks2.ImportBundle(bundle, "bundle", "new"); !errors.Is(err, ErrAccountAlreadyExists) {
+		t.Fatalf("bundle import error mismatch: have %v, want %v", err, ErrAccountAlreadyExists)
+	}
+	if len(ks2.Accounts()) != 1 {
+		t.Fatalf("partial bundle import: have %d accounts, want 1", len(ks2.Accounts()))
+	}
+	if err := ks2.Delete(ks2.Accounts()[0], "new"); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+	imported, err := ks2.ImportBundle(bundle, "bundle", "new")
+	if err != nil {
+		t.Fatalf("failed to import bundle: %v", err)
+	}
+	for i, acc := range imported {
+		if acc.Address != accs[i].Address {
+			t.Errorf("account %d: address mismatch: have %x, want %x", i, acc.Address, accs[i].Address)
+		}
+		if err := ks2.Unlock(acc, "new"); err != nil {
+			t.Errorf("account %d: failed to unlock: %v", i, err)
+		}
+	}
+	if meta, err := ks2.Metadata(imported[0]); err != nil || meta == nil || meta.Label != "hot" {
+		t.Errorf("metadata mismatch: have %v (%v), want label hot", meta, err)
+	}
+}
+
 // TestImportRace tests the keystore on races.
 // This test should fail under -race if importing races.
 func TestImportRace(t *testing.T) {
@@ -190,10 +190,13 @@ func EncryptKey(key *Key, auth string, scryptN, scryptP int) ([]byte, error) {
 		return nil, err
 	}
 	encryptedKeyJSONV3 := encryptedKeyJSONV3{
-		hex.EncodeToString(key.Address[:]),
-		cryptoStruct,
-		key.Id.String(),
-		version,
+		Address: hex.EncodeToString(key.Address[:]),
+		Crypto:  cryptoStruct,
+		Id:      key.Id.String(),
+		Version: keyVersion(key.Meta),
+	}
+	if !key.Meta.empty() {
+		encryptedKeyJSONV3.Meta = key.Meta
 	}
 	return json.Marshal(encryptedKeyJSONV3)
 }
@@ -208,6 +211,7 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 	// Depending on the version try to parse one way or another
 	var (
 		keyBytes, keyId []byte
+		meta            *KeyMeta
 		err             error
 	)
 	if version, ok := m["version"].(string); ok && version == "1" {
@@ -222,6 +226,7 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 			return nil, err
 		}
 		keyBytes, keyId, err = decryptKeyV3(k, auth)
+		meta = k.Meta
 	}
 	// Handle any decryption errors and return the key
 	if err != nil {
@@ -236,6 +241,7 @@ func DecryptKey(keyjson []byte, auth string) (*Key, error) {
 		Id:         id,
 		Address:    crypto.PubkeyToAddress(key.PublicKey),
 		PrivateKey: key,
+		Meta:       meta,
 	}, nil
 }
 
@@ -276,7 +282,7 @@ func DecryptDataV3(cryptoJson CryptoJSON, auth string) ([]byte, error) {
 }
 
 func decryptKeyV3(keyProtected *encryptedKeyJSONV3, auth string) (keyBytes []byte, keyId []byte, err error) {
-	if keyProtected.Version != version {
+	if keyProtected.Version != version && keyProtected.Version != versionMeta {
 		return nil, nil, fmt.Errorf("version not supported: %v", keyProtected.Version)
 	}
 	keyUUID, err := uuid.Parse(keyProtected.Id)
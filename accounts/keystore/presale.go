@@ -41,6 +41,7 @@ func importPreSaleKey(keyStore keyStore, keyJSON []byte, password string) (accou
 	if err != nil {
 		return accounts.Account{}, nil, err
 	}
+	key.Meta = &KeyMeta{Origin: KeyOriginPresale}
 	a := accounts.Account{
 		Address: key.Address,
 		URL: accounts.URL{
@@ -0,0 +1,298 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	crawlNetworkFlag = cli.StringFlag{
+		Name:  "network",
+		Usage: "Only keep the nodes of a network, by name (e.g. mainnet) or fork hash (e.g. 0xfc64ec04)",
+	}
+	crawlGeoIPFlag = cli.StringFlag{
+		Name:  "geoip",
+		Usage: "Offline IP to ASN/country database in the ip2asn TSV format (optionally gzipped)",
+	}
+	crawlSummaryFlag = cli.StringFlag{
+		Name:  "summary",
+		Usage: "Write a summary of the crawled nodes to the given file, CSV or JSON by extension",
+	}
+)
+
+// geoRange is an IP range of the GeoIP database.
+type geoRange struct {
+	start, end net.IP // 16-byte form
+	geo        geoInfo
+}
+
+// geoInfo is the location of an IP address.
+type geoInfo struct {
+	ASN     uint32 `json:"asn,omitempty"`
+	ASName  string `json:"asName,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+// geoDB maps IP addresses to their autonomous system and country. It is loaded
+// from the ip2asn TSV files, whose lines are the start, end, AS number, country
+// code and AS description of the IP ranges.
+type geoDB struct {
+	ranges []geoRange // sorted by start
+}
+
+func loadGeoDB(file string) (*geoDB, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return parseGeoDB(r)
+}
+
+func parseGeoDB(r io.Reader) (*geoDB, error) {
+	db := new(geoDB)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("line %d: have %d fields, want 5", line, len(fields))
+		}
+		start, end := net.ParseIP(fields[0]), net.ParseIP(fields[1])
+		if start == nil || end == nil {
+			return nil, fmt.Errorf("line %d: invalid IP range", line)
+		}
+		asn, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid AS number: %v", line, err)
+		}
+		if asn == 0 {
+			continue // not routed
+		}
+		db.ranges = append(db.ranges, geoRange{
+			start: start.To16(),
+			end:   end.To16(),
+			geo:   geoInfo{ASN: uint32(asn), Country: fields[3], ASName: fields[4]},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+	})
+	return db, nil
+}
+
+// lookup returns the location of an IP address, if known.
+func (db *geoDB) lookup(ip net.IP) (geoInfo, bool) {
+	ip = ip.To16()
+	if db == nil || ip == nil {
+		return geoInfo{}, false
+	}
+	// Find the last range starting at or before the address.
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, db.ranges[i].end) > 0 {
+		return geoInfo{}, false
+	}
+	return db.ranges[i].geo, true
+}
+
+// crawlNode is a crawled node of the summary.
+type crawlNode struct {
+	ID    string `json:"id"`
+	IP    string `json:"ip"`
+	Port  int    `json:"port"`
+	Seq   uint64 `json:"seq"`
+	Score int    `json:"score"`
+	geoInfo
+}
+
+// crawlCount is the number of nodes of a country or autonomous system.
+type crawlCount struct {
+	Key   string `json:"key"`
+	Nodes int    `json:"nodes"`
+}
+
+// crawlSummary summarizes the nodes found by a crawl.
+type crawlSummary struct {
+	Nodes     int          `json:"nodes"`
+	Located   int          `json:"located"` // nodes found in the GeoIP database
+	Countries []crawlCount `json:"countries"`
+	ASNs      []crawlCount `json:"asns"`
+	Details   []crawlNode  `json:"details"`
+}
+
+// summarizeCrawl summarizes the nodes of a set, locating them with the GeoIP
+// database if any.
+func summarizeCrawl(ns nodeSet, db *geoDB) *crawlSummary {
+	var (
+		summary   = &crawlSummary{Nodes: len(ns), Details: []crawlNode{}}
+		countries = make(map[string]int)
+		asns      = make(map[string]int)
+	)
+	for _, n := range ns.nodes() {
+		node := crawlNode{
+			ID:    n.ID().String(),
+			IP:    n.IP().String(),
+			Port:  n.TCP(),
+			Seq:   n.Seq(),
+			Score: ns[n.ID()].Score,
+		}
+		if geo, ok := db.lookup(n.IP()); ok {
+			node.geoInfo = geo
+			summary.Located++
+			countries[geo.Country]++
+			asns[fmt.Sprintf("AS%d %s", geo.ASN, geo.ASName)]++
+		}
+		summary.Details = append(summary.Details, node)
+	}
+	summary.Countries = sortCounts(countries)
+	summary.ASNs = sortCounts(asns)
+	return summary
+}
+
+// sortCounts orders counts by decreasing number of nodes.
+func sortCounts(counts map[string]int) []crawlCount {
+	sorted := make([]crawlCount, 0, len(counts))
+	for key, nodes := range counts {
+		sorted = append(sorted, crawlCount{key, nodes})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Nodes != sorted[j].Nodes {
+			return sorted[i].Nodes > sorted[j].Nodes
+		}
+		return sorted[i].Key < sorted[j].Key
+	})
+	return sorted
+}
+
+// writeCSV writes the crawled nodes as CSV, one node per line.
+func (s *crawlSummary) writeCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "ip", "port", "seq", "score", "asn", "as_name", "country"})
+	for _, n := range s.Details {
+		asn := ""
+		if n.ASN != 0 {
+			asn = strconv.FormatUint(uint64(n.ASN), 10)
+		}
+		out.Write([]string{
+			n.ID, n.IP, strconv.Itoa(n.Port), strconv.FormatUint(n.Seq, 10),
+			strconv.Itoa(n.Score), asn, n.ASName, n.Country,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// write writes the summary to a file, as CSV if it has the .csv extension and as
+// JSON otherwise. The file "-" is stdout.
+func (s *crawlSummary) write(file string) error {
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		if err := s.writeCSV(&buf); err != nil {
+			return err
+		}
+	} else {
+		enc, err := json.MarshalIndent(s, "", jsonIndent)
+		if err != nil {
+			return err
+		}
+		buf.Write(enc)
+		buf.WriteByte('\n')
+	}
+	if file == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(file, buf.Bytes(), 0644)
+}
+
+// crawlOutput post-processes the nodes found by a crawl as configured on the
+// command line.
+type crawlOutput struct {
+	filter  nodeFilter // keeps the nodes of the network, if set
+	geo     *geoDB
+	summary string // file the summary is written to, if set
+}
+
+// newCrawlOutput loads the settings of the crawl output before the crawl starts,
+// so invalid ones are reported right away.
+func newCrawlOutput(ctx *cli.Context) (*crawlOutput, error) {
+	o := &crawlOutput{summary: ctx.String(crawlSummaryFlag.Name)}
+	if network := ctx.String(crawlNetworkFlag.Name); network != "" {
+		filter, err := ongFilter([]string{network})
+		if err != nil {
+			return nil, err
+		}
+		o.filter = filter
+	}
+	if file := ctx.String(crawlGeoIPFlag.Name); file != "" {
+		db, err := loadGeoDB(file)
+		if err != nil {
+			return nil, fmt.Errorf("can't load GeoIP database: %v", err)
+		}
+		o.geo = db
+	}
+	return o, nil
+}
+
+// finish filters the crawled nodes and writes their summary.
+func (o *crawlOutput) finish(output nodeSet) (nodeSet, error) {
+	if o.filter != nil {
+		filtered := make(nodeSet)
+		for id, n := range output {
+			if o.filter(n) {
+				filtered[id] = n
+			}
+		}
+		output = filtered
+	}
+	if o.summary != "" {
+		if err := summarizeCrawl(output, o.geo).write(o.summary); err != nil {
+			return nil, err
+		}
+	}
+	return output, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ong2020/go-orange/core/forkid"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/p2p/enode"
+	"github.com/ong2020/go-orange/p2p/enr"
+	"github.com/ong2020/go-orange/rlp"
+)
+
+const testGeoDB = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"2.0.0.0\t2.0.255.255\t0\tNone\tNot routed\n" +
+	"5.0.0.0\t5.0.255.255\t3320\tDE\tDTAG\n" +
+	"2001:db8::\t2001:db8::ffff\t64496\tNL\tEXAMPLE\n"
+
+func TestGeoDBLookup(t *testing.T) {
+	db, err := parseGeoDB(strings.NewReader(testGeoDB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip  string
+		geo geoInfo
+		ok  bool
+	}{
+		{"1.0.0.1", geoInfo{13335, "CLOUDFLARENET", "US"}, true},
+		{"1.0.0.255", geoInfo{13335, "CLOUDFLARENET", "US"}, true},
+		{"1.0.1.0", geoInfo{}, false},
+		{"2.0.0.1", geoInfo{}, false},
+		{"5.0.100.1", geoInfo{3320, "DTAG", "DE"}, true},
+		{"2001:db8::1", geoInfo{64496, "EXAMPLE", "NL"}, true},
+		{"0.0.0.1", geoInfo{}, false},
+	}
+	for _, tt := range tests {
+		geo, ok := db.lookup(net.ParseIP(tt.ip))
+		if ok != tt.ok || geo != tt.geo {
+			t.Errorf("%s: have %v %t, want %v %t", tt.ip, geo, ok, tt.geo, tt.ok)
+		}
+	}
+}
+
+func newTestNode(t *testing.T, ip string, fork [4]byte) nodeJSON {
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	r.Set(enr.IP(net.ParseIP(ip)))
+	r.Set(enr.TCP(30303))
+	r.Set(enr.WithEntry("ong", struct {
+		ForkID forkid.ID
+		Tail   []rlp.RawValue `rlp:"tail"`
+	}{ForkID: forkid.ID{Hash: fork}}))
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nodeJSON{N: n, Seq: n.Seq(), Score: 1}
+}
+
+// Tests that crawled nodes are filtered by fork and summarized by location.
+func TestCrawlSummary(t *testing.T) {
+	db, _ := parseGeoDB(strings.NewReader(testGeoDB))
+	var (
+		fork  = [4]byte{0xfc, 0x64, 0xec, 0x04}
+		nodes = []nodeJSON{
+			newTestNode(t, "1.0.0.1", fork),
+			newTestNode(t, "1.0.0.2", fork),
+			newTestNode(t, "5.0.0.1", fork),
+			newTestNode(t, "9.0.0.1", fork),
+			newTestNode(t, "1.0.0.3", [4]byte{1, 2, 3, 4}),
+		}
+		ns = make(nodeSet)
+	)
+	for _, n := range nodes {
+		ns[n.N.ID()] = n
+	}
+	filter, err := ongFilter([]string{"0xfc64ec04"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &crawlOutput{filter: filter, geo: db}
+	filtered, err := out.finish(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 4 {
+		t.Fatalf("wrong number of nodes of the fork: have %d, want 4", len(filtered))
+	}
+	summary := summarizeCrawl(filtered, db)
+	if summary.Nodes != 4 || summary.Located != 3 {
+		t.Errorf("wrong node counts: %d nodes, %d located", summary.Nodes, summary.Located)
+	}
+	if want := []crawlCount{{"US", 2}, {"DE", 1}}; !reflect.DeepEqual(summary.Countries, want) {
+		t.Errorf("wrong countries: have %v, want %v", summary.Countries, want)
+	}
+	if want := []crawlCount{{"AS13335 CLOUDFLARENET", 2}, {"AS3320 DTAG", 1}}; !reflect.DeepEqual(summary.ASNs, want) {
+		t.Errorf("wrong ASNs: have %v, want %v", summary.ASNs, want)
+	}
+	var buf bytes.Buffer
+	if err := summary.writeCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || lines[0] != "id,ip,port,seq,score,asn,as_name,country" {
+		t.Errorf("wrong CSV output:\n%s", buf.String())
+	}
+}
@@ -74,7 +74,7 @@ var (
 		Name:   "crawl",
 		Usage:  "Updates a nodes.json file with random nodes found in the DHT",
 		Action: discv4Crawl,
-		Flags:  []cli.Flag{bootnodesFlag, crawlTimeoutFlag},
+		Flags:  []cli.Flag{bootnodesFlag, crawlTimeoutFlag, crawlNetworkFlag, crawlGeoIPFlag, crawlSummaryFlag},
 	}
 	discv4TestCommand = cli.Command{
 		Name:   "test",
@@ -194,11 +194,19 @@ func discv4Crawl(ctx *cli.Context) error {
 		inputSet = loadNodesJSON(nodesFile)
 	}
 
+	out, err := newCrawlOutput(ctx)
+	if err != nil {
+		return err
+	}
+
 	disc := startV4(ctx)
 	defer disc.Close()
 	c := newCrawler(inputSet, disc, disc.RandomNodes())
 	c.revalidateInterval = 10 * time.Minute
-	output := c.run(ctx.Duration(crawlTimeoutFlag.Name))
+	output, err := out.finish(c.run(ctx.Duration(crawlTimeoutFlag.Name)))
+	if err != nil {
+		return err
+	}
 	writeNodesJSON(nodesFile, output)
 	return nil
 }
@@ -53,7 +53,7 @@ var (
 		Name:   "crawl",
 		Usage:  "Updates a nodes.json file with random nodes found in the DHT",
 		Action: discv5Crawl,
-		Flags:  []cli.Flag{bootnodesFlag, crawlTimeoutFlag},
+		Flags:  []cli.Flag{bootnodesFlag, crawlTimeoutFlag, crawlNetworkFlag, crawlGeoIPFlag, crawlSummaryFlag},
 	}
 	discv5TestCommand = cli.Command{
 		Name:   "test",
@@ -107,11 +107,19 @@ func discv5Crawl(ctx *cli.Context) error {
 		inputSet = loadNodesJSON(nodesFile)
 	}
 
+	out, err := newCrawlOutput(ctx)
+	if err != nil {
+		return err
+	}
+
 	disc := startV5(ctx)
 	defer disc.Close()
 	c := newCrawler(inputSet, disc, disc.RandomNodes())
 	c.revalidateInterval = 10 * time.Minute
-	output := c.run(ctx.Duration(crawlTimeoutFlag.Name))
+	output, err := out.finish(c.run(ctx.Duration(crawlTimeoutFlag.Name)))
+	if err != nil {
+		return err
+	}
 	writeNodesJSON(nodesFile, output)
 	return nil
 }
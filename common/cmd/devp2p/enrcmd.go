@@ -31,6 +31,7 @@ import (
 	"github.com/ong2020/go-orange/p2p/enode"
 	"github.com/ong2020/go-orange/p2p/enr"
 	"github.com/ong2020/go-orange/rlp"
+	"github.com/ong2020/go-orange/rpc"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -178,6 +179,7 @@ var attrFormatters = map[string]func(rlp.RawValue) (string, bool){
 	"tcp6": formatAttrUint,
 	"udp":  formatAttrUint,
 	"udp6": formatAttrUint,
+	"rpc":  formatAttrRPC,
 }
 
 func formatAttrRaw(v rlp.RawValue) (string, bool) {
@@ -205,3 +207,11 @@ func formatAttrUint(v rlp.RawValue) (string, bool) {
 	}
 	return strconv.FormatUint(x, 10), true
 }
+
+func formatAttrRPC(v rlp.RawValue) (string, bool) {
+	var entry rpc.ENREntry
+	if err := rlp.DecodeBytes(v, &entry); err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(entry.URLs, " "), strings.Join(entry.Namespaces, ",")), true
+}
@@ -21,7 +21,9 @@ import (
 	"net"
 	"time"
 
+	"github.com/ong2020/go-orange/common/hexutil"
 	"github.com/ong2020/go-orange/core/forkid"
+	"github.com/ong2020/go-orange/ongclient"
 	"github.com/ong2020/go-orange/p2p/enr"
 	"github.com/ong2020/go-orange/params"
 	"github.com/ong2020/go-orange/rlp"
@@ -96,6 +98,7 @@ var filterFlags = map[string]nodeFilterC{
 	"-ong-network": {1, ongFilter},
 	"-les-server":  {0, lesFilter},
 	"-snap":        {0, snapFilter},
+	"-rpc":         {0, rpcFilter},
 }
 
 func parseFilters(args []string) ([]nodeFilter, error) {
@@ -167,7 +170,14 @@ func ongFilter(args []string) (nodeFilter, error) {
 	case "ropsten":
 		filter = forkid.NewStaticFilter(params.RopstenChainConfig, params.RopstenGenesisHash)
 	default:
-		return nil, fmt.Errorf("unknown network %q", args[0])
+		// Other networks are given by the hash of their current fork.
+		hash, err := hexutil.Decode(args[0])
+		if err != nil || len(hash) != 4 {
+			return nil, fmt.Errorf("unknown network %q", args[0])
+		}
+		var fork [4]byte
+		copy(fork[:], hash)
+		return forkHashFilter(fork), nil
 	}
 
 	f := func(n nodeJSON) bool {
@@ -183,6 +193,21 @@ func ongFilter(args []string) (nodeFilter, error) {
 	return f, nil
 }
 
+// forkHashFilter keeps the nodes advertising the given fork hash in their ong
+// ENR entry.
+func forkHashFilter(hash [4]byte) nodeFilter {
+	return func(n nodeJSON) bool {
+		var ong struct {
+			ForkID forkid.ID
+			_      []rlp.RawValue `rlp:"tail"`
+		}
+		if n.N.Load(enr.WithEntry("ong", &ong)) != nil {
+			return false
+		}
+		return ong.ForkID.Hash == hash
+	}
+}
+
 func lesFilter(args []string) (nodeFilter, error) {
 	f := func(n nodeJSON) bool {
 		var les struct {
@@ -202,3 +227,10 @@ func snapFilter(args []string) (nodeFilter, error) {
 	}
 	return f, nil
 }
+
+func rpcFilter(args []string) (nodeFilter, error) {
+	f := func(n nodeJSON) bool {
+		return ongclient.RPCEndpointOf(n.N) != nil
+	}
+	return f, nil
+}
@@ -129,6 +129,10 @@ var (
 		Name:  "noreturndata",
 		Usage: "disable return data output",
 	}
+	ForkFlag = cli.StringFlag{
+		Name:  "fork",
+		Usage: "Fork rules to execute with (e.g. Istanbul, Berlin), state tests only run the subtests of this fork",
+	}
 	EVMInterpreterFlag = cli.StringFlag{
 		Name:  "vm.evm",
 		Usage: "External EVM configuration (default = built-in interpreter)",
@@ -186,6 +190,7 @@ func init() {
 		DisableStorageFlag,
 		DisableReturnDataFlag,
 		EVMInterpreterFlag,
+		ForkFlag,
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
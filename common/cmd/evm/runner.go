@@ -25,6 +25,7 @@ import (
 	"os"
 	goruntime "runtime"
 	"runtime/pprof"
+	"strings"
 	"testing"
 	"time"
 
@@ -230,7 +231,14 @@ func runCmd(ctx *cli.Context) error {
 		defer pprof.StopCPUProfile()
 	}
 
-	if chainConfig != nil {
+	if fork := ctx.GlobalString(ForkFlag.Name); fork != "" {
+		config, err := runtime.ForkConfig(fork)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v, supported forks: %s\n", err, strings.Join(runtime.Forks(), ", "))
+			os.Exit(1)
+		}
+		runtimeConfig.ChainConfig = config
+	} else if chainConfig != nil {
 		runtimeConfig.ChainConfig = chainConfig
 	} else {
 		runtimeConfig.ChainConfig = params.AllOngashProtocolChanges
@@ -0,0 +1,149 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/reexec"
+	"github.com/ong2020/go-orange/internal/cmdtest"
+)
+
+type testEVM struct {
+	*cmdtest.TestCmd
+}
+
+// spawns evm with the given command line args.
+func runEVM(t *testing.T, args ...string) *testEVM {
+	tt := new(testEVM)
+	tt.TestCmd = cmdtest.NewTestCmd(t, tt)
+	tt.Run("evm-test", args...)
+	return tt
+}
+
+func TestMain(m *testing.M) {
+	// Run the app if we've been exec'd as "evm-test" in runEVM.
+	reexec.Register("evm-test", func() {
+		if err := app.Run(os.Args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	})
+	// check if we have been reexec'd
+	if reexec.Init() {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// chainIDCode returns the chain ID, using the CHAINID opcode introduced in
+// Istanbul.
+const chainIDCode = "4660005260206000f3"
+
+// Tests that --fork selects the rules code is run with.
+func TestRunFork(t *testing.T) {
+	evm := runEVM(t, "--fork", "Istanbul", "--code", chainIDCode, "run")
+	evm.Expect(`
+0x0000000000000000000000000000000000000000000000000000000000000001
+`)
+	evm.ExpectExit()
+
+	evm = runEVM(t, "--fork", "Petersburg", "--code", chainIDCode, "run")
+	evm.Expect(`
+0x
+ error: invalid opcode: CHAINID
+`)
+	evm.ExpectExit()
+
+	evm = runEVM(t, "--fork", "Unknown", "--code", chainIDCode, "run")
+	evm.ExpectExit()
+	if status := evm.ExitStatus(); status != 1 {
+		t.Errorf("exit status mismatch for unknown fork: have %d, want 1", status)
+	}
+	if stderr := evm.StderrText(); !strings.Contains(stderr, `unsupported fork "Unknown"`) || !strings.Contains(stderr, "Istanbul") {
+		t.Errorf("unknown fork error mismatch: %q", stderr)
+	}
+}
+
+// forkStateTest is a state test of a plain transfer, with subtests for Istanbul
+// and Berlin.
+const forkStateTest = `{
+  "fork": {
+    "env": {
+      "currentCoinbase": "2adc25665018aa1fe0e6bc666dac8fc2697ff9ba",
+      "currentDifficulty": "0x020000",
+      "currentGasLimit": "0x05f5e100",
+      "currentNumber": "0x01",
+      "currentTimestamp": "0x03e8"
+    },
+    "pre": {
+      "a94f5374fce5edbc8e2a8697c15331677e6ebf0b": {"balance": "0x0de0b6b3a7640000", "code": "0x", "nonce": "0x00", "storage": {}}
+    },
+    "transaction": {
+      "data": ["0x"],
+      "gasLimit": ["0x5208"],
+      "gasPrice": "0x01",
+      "nonce": "0x00",
+      "secretKey": "0x45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8",
+      "to": "0x1000000000000000000000000000000000000000",
+      "value": ["0x01"]
+    },
+    "post": {
+      "Istanbul": [{"hash": "0x3f42a5c1dc9a219f6f58b3a9e0ebac02df13f8c2ea2169c00e98ebd30b9dd22d", "logs": "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347", "indexes": {"data": 0, "gas": 0, "value": 0}}],
+      "Berlin": [{"hash": "0x3f42a5c1dc9a219f6f58b3a9e0ebac02df13f8c2ea2169c00e98ebd30b9dd22d", "logs": "0x1dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347", "indexes": {"data": 0, "gas": 0, "value": 0}}]
+    }
+  }
+}`
+
+// Tests that --fork restricts the state tests to the subtests of the fork.
+func TestStateTestFork(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fork.json")
+	if err := ioutil.WriteFile(file, []byte(forkStateTest), 0600); err != nil {
+		t.Fatal(err)
+	}
+	evm := runEVM(t, "statetest", file)
+	evm.ExpectRegexp(`"pass": true,\n    "fork": "(Istanbul|Berlin)"(.|\n)*"pass": true,\n    "fork": "(Istanbul|Berlin)"`)
+	evm.WaitExit()
+
+	evm = runEVM(t, "--fork", "Berlin", "statetest", file)
+	evm.Expect(`
+[
+  {
+    "name": "fork",
+    "pass": true,
+    "fork": "Berlin"
+  }
+]
+`)
+	evm.ExpectExit()
+
+	// Fork names are case sensitive, a mismatching one must not pass silently
+	evm = runEVM(t, "--fork", "berlin", "statetest", file)
+	evm.ExpectExit()
+	if status := evm.ExitStatus(); status != 1 {
+		t.Errorf("exit status mismatch for unknown fork: have %d, want 1", status)
+	}
+	if stderr := evm.StderrText(); !strings.Contains(stderr, `unsupported fork "berlin"`) || !strings.Contains(stderr, "Berlin") {
+		t.Errorf("unknown fork error mismatch: %q", stderr)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/ong2020/go-orange/core/state"
 	"github.com/ong2020/go-orange/core/vm"
@@ -52,6 +53,11 @@ func stateTestCmd(ctx *cli.Context) error {
 	if len(ctx.Args().First()) == 0 {
 		return errors.New("path-to-test argument required")
 	}
+	// Reject unknown forks, which would otherwise silently run no subtests
+	fork := ctx.GlobalString(ForkFlag.Name)
+	if _, ok := tests.Forks[fork]; fork != "" && !ok {
+		return fmt.Errorf("unsupported fork %q, supported forks: %s", fork, strings.Join(tests.AvailableForks(), ", "))
+	}
 	// Configure the go-orange logger
 	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
 	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
@@ -96,6 +102,9 @@ func stateTestCmd(ctx *cli.Context) error {
 	results := make([]StatetestResult, 0, len(tests))
 	for key, test := range tests {
 		for _, st := range test.Subtests() {
+			if fork != "" && st.Fork != fork {
+				continue
+			}
 			// Run the test and aggregate the result
 			result := &StatetestResult{Name: key, Fork: st.Fork, Pass: true}
 			_, state, err := test.Run(st, cfg, false)
@@ -185,6 +185,51 @@ Note:
 As you can directly copy your encrypted accounts to another orange instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+			},
+			{
+				Name:   "export-bundle",
+				Usage:  "Export accounts into a passphrase-encrypted bundle",
+				Action: utils.MigrateFlags(accountExportBundle),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				ArgsUsage: "<bundleFile> <address> [<address>...]",
+				Description: `
+    gong account export-bundle <bundlefile> <address> [<address>...]
+
+Exports the given accounts into a single bundle file, encrypted with a bundle
+password, to migrate them to another node with import-bundle. The bundle keeps
+the labels and other metadata of the accounts.
+
+You are prompted for the password of each account, then for the bundle password.
+For non-interactive use the passwords can be specified with the --password flag,
+one per line, in the same order, the bundle password last.
+`,
+			},
+			{
+				Name:   "import-bundle",
+				Usage:  "Import the accounts of a passphrase-encrypted bundle",
+				Action: utils.MigrateFlags(accountImportBundle),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				ArgsUsage: "<bundleFile>",
+				Description: `
+    gong account import-bundle <bundlefile>
+
+Imports all accounts of a bundle created by export-bundle. The import is atomic:
+if any account of the bundle already exists, none is imported.
+
+You are prompted for the bundle password, then for the password to lock the
+imported accounts with. For non-interactive use the passwords can be specified
+with the --password flag, one per line, in the same order.
 `,
 			},
 		},
@@ -263,7 +308,7 @@ func accountCreate(ctx *cli.Context) error {
 	cfg := gongConfig{Node: defaultNodeConfig()}
 	// Load config file.
 	if file := ctx.GlobalString(configFileFlag.Name); file != "" {
-		if err := loadConfig(file, &cfg); err != nil {
+		if err := loadSecretConfig(file, &cfg); err != nil {
 			utils.Fatalf("%v", err)
 		}
 	}
@@ -352,3 +397,66 @@ func accountImport(ctx *cli.Context) error {
 	fmt.Printf("Address: {%x}\n", acct.Address)
 	return nil
 }
+
+// accountExportBundle exports a list of accounts into an encrypted bundle file.
+func accountExportBundle(ctx *cli.Context) error {
+	if len(ctx.Args()) < 2 {
+		utils.Fatalf("Bundle file and accounts must be given as argument")
+	}
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	var (
+		bundlefile = ctx.Args().First()
+		addresses  = ctx.Args().Tail()
+		passwords  = utils.MakePasswordList(ctx)
+		accs       = make([]accounts.Account, len(addresses))
+		auths      = make([]string, len(addresses))
+	)
+	for i, address := range addresses {
+		account, err := utils.MakeAddress(ks, address)
+		if err != nil {
+			utils.Fatalf("Could not find account %s: %v", address, err)
+		}
+		accs[i] = account
+		auths[i] = utils.GetPassPhraseWithList(fmt.Sprintf("Password of account %s:", address), false, i, passwords)
+	}
+	bundlePassword := utils.GetPassPhraseWithList("The bundle is locked with a password. Please give a password. Do not forget this password.", true, len(addresses), passwords)
+
+	bundle, err := ks.ExportBundle(accs, auths, bundlePassword)
+	if err != nil {
+		utils.Fatalf("Could not export the accounts: %v", err)
+	}
+	if err := ioutil.WriteFile(bundlefile, bundle, 0600); err != nil {
+		utils.Fatalf("Could not write the bundle: %v", err)
+	}
+	fmt.Printf("Exported %d accounts to %s\n", len(accs), bundlefile)
+	return nil
+}
+
+// accountImportBundle imports all accounts of an encrypted bundle file.
+func accountImportBundle(ctx *cli.Context) error {
+	bundlefile := ctx.Args().First()
+	if len(bundlefile) == 0 {
+		utils.Fatalf("Bundle file must be given as argument")
+	}
+	bundle, err := ioutil.ReadFile(bundlefile)
+	if err != nil {
+		utils.Fatalf("Could not read the bundle: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	passwords := utils.MakePasswordList(ctx)
+	bundlePassword := utils.GetPassPhraseWithList("Password of the bundle:", false, 0, passwords)
+	password := utils.GetPassPhraseWithList("Your new accounts are locked with a password. Please give a password. Do not forget this password.", true, 1, passwords)
+
+	accs, err := ks.ImportBundle(bundle, bundlePassword, password)
+	if err != nil {
+		utils.Fatalf("Could not import the bundle: %v", err)
+	}
+	for _, acct := range accs {
+		fmt.Printf("Address: {%x}\n", acct.Address)
+	}
+	return nil
+}
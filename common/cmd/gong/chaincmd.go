@@ -17,21 +17,35 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/olekukonko/tablewriter"
 	"github.com/ong2020/go-orange/cmd/utils"
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/core/state"
 	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/core/vm"
 	"github.com/ong2020/go-orange/log"
 	"github.com/ong2020/go-orange/metrics"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/params"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -43,6 +57,10 @@ var (
 		ArgsUsage: "<genesisPath>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			genesisChecksumFlag,
+			genesisIPFSGatewayFlag,
+			utils.DataDirNamespaceFlag,
+			utils.ForceFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -50,7 +68,17 @@ The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
 participating.
 
-It expects the genesis file as argument.`,
+It expects the genesis file as argument: a local path, an http(s) URL or an
+IPFS content identifier (ipfs://<cid>), fetched through --genesis.ipfsgateway.
+If --genesis.checksum is set, the SHA-256 hash of the genesis file must match it.
+The checksum is required for the genesis files fetched over the network.
+The chain is stored in the datadir namespace of the genesis, unless the datadir
+uses the legacy layout or --datadir.namespace is given.
+
+With --force, a data directory which is already initialized is accepted as long
+as its genesis block matches and its chain configuration is compatible with the
+given one, nothing is written in that case. This makes init idempotent for
+automated deployments.`,
 	}
 	dumpGenesisCommand = cli.Command{
 		Action:    utils.MigrateFlags(dumpGenesis),
@@ -75,6 +103,7 @@ The dumpgenesis command dumps the genesis block configuration in JSON format to
 		ArgsUsage: "<filename> (<filename 2> ... <filename N>) ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientCompressionFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
@@ -146,6 +175,81 @@ be gzipped.`,
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 The export-preimages command export hash preimages to an RLP encoded stream`,
+	}
+	repairReceiptsCommand = cli.Command{
+		Action:    utils.MigrateFlags(repairReceipts),
+		Name:      "repair-receipts",
+		Usage:     "Regenerate the receipts of a block range by re-executing it",
+		ArgsUsage: "<blockNumFirst> <blockNumLast>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			repairReexecFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The repair-receipts command re-executes all the blocks in the given (inclusive)
+range and rewrites any receipts that are missing or corrupted in the database or
+the freezer. The regenerated receipts are verified against the receipt root and
+bloom of each block before being stored.
+
+The state of the block preceding the range must be available, or regenerable by
+re-executing at most --reexec blocks.`,
+	}
+	replayCommand = cli.Command{
+		Action:    utils.MigrateFlags(replayChain),
+		Name:      "replay",
+		Usage:     "Re-execute a block range with a different configuration and compare the results",
+		ArgsUsage: "<blockNumFirst> <blockNumLast>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			repairReexecFlag,
+			replayConfigFlag,
+			replayCacheFlag,
+			replayEVMFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The replay command re-executes all the blocks in the given (inclusive) range of
+an imported chain with an alternative configuration (chain rules, trie cache
+size, EVM interpreter) and compares the receipts, gas usage, blooms and state
+roots block by block against the imported chain. The first divergence found is
+reported and the command fails, making it suitable for differential testing of
+EVM and fork changes. The database is not modified.
+
+The alternative chain rules (e.g. fork block overrides) are read from the JSON
+chain configuration file given by --replay.config, in the format of the config
+section of a genesis file.
+
+The state of the block preceding the range must be available, or regenerable by
+re-executing at most --reexec blocks.`,
+	}
+	inspectChainCommand = cli.Command{
+		Action:    utils.MigrateFlags(inspectChain),
+		Name:      "inspect-chain",
+		Usage:     "Check the consistency of the stored chain data",
+		ArgsUsage: "[<blockNumFirst> <blockNumLast>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.SyncModeFlag,
+			inspectFixFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The inspect-chain command walks the canonical chain (optionally limited to the
+given inclusive block range) and cross checks the headers, bodies, receipts,
+total difficulties, transaction indices and canonical hash mappings, as well as
+the freezer boundaries and head markers, reporting any gaps, mismatches and
+dangling entries found.
+
+With --fix, inconsistencies that can be safely repaired from other data in the
+database (mappings, difficulties and indices) are fixed in place.`,
 	}
 	dumpCommand = cli.Command{
 		Action:    utils.MigrateFlags(dump),
@@ -168,6 +272,45 @@ Use "orange dump 0" to dump the genesis block.`,
 	}
 )
 
+var repairReexecFlag = cli.Uint64Flag{
+	Name:  "reexec",
+	Usage: "Maximum number of blocks to re-execute to regenerate missing historical state",
+	Value: 128,
+}
+
+var (
+	replayConfigFlag = cli.StringFlag{
+		Name:  "replay.config",
+		Usage: "JSON chain configuration file to replay with (default = configuration of the chain)",
+	}
+	replayCacheFlag = cli.IntFlag{
+		Name:  "replay.cache",
+		Usage: "Megabytes of memory allocated to the trie cache of the replay",
+		Value: 16,
+	}
+	replayEVMFlag = cli.StringFlag{
+		Name:  "replay.vm.evm",
+		Usage: "External EVM configuration to replay with (default = built-in interpreter)",
+	}
+)
+
+var (
+	genesisChecksumFlag = cli.StringFlag{
+		Name:  "genesis.checksum",
+		Usage: "Expected SHA-256 hash (hex) of the genesis file, required for URLs and IPFS",
+	}
+	genesisIPFSGatewayFlag = cli.StringFlag{
+		Name:  "genesis.ipfsgateway",
+		Usage: "HTTP gateway to fetch IPFS hosted genesis files through",
+		Value: "https://ipfs.io",
+	}
+)
+
+var inspectFixFlag = cli.BoolFlag{
+	Name:  "fix",
+	Usage: "Automatically fix the inconsistencies that are safe to repair",
+}
+
 // initGenesis will initialise the given JSON format genesis file and writes it as
 // the zero'd block (i.e. genesis) or will fail hard if it can't succeed.
 func initGenesis(ctx *cli.Context) error {
@@ -176,17 +319,19 @@ func initGenesis(ctx *cli.Context) error {
 	if len(genesisPath) == 0 {
 		utils.Fatalf("Must supply path to genesis JSON file")
 	}
-	file, err := os.Open(genesisPath)
+	blob, err := loadGenesis(genesisPath, ctx.String(genesisIPFSGatewayFlag.Name), ctx.String(genesisChecksumFlag.Name))
 	if err != nil {
-		utils.Fatalf("Failed to read genesis file: %v", err)
+		utils.Fatalf("Failed to load genesis file: %v", err)
 	}
-	defer file.Close()
-
 	genesis := new(core.Genesis)
-	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+	if err := json.Unmarshal(blob, genesis); err != nil {
 		utils.Fatalf("invalid genesis file: %v", err)
 	}
-	// Open and initialise both full and light databases
+	// Open and initialise both full and light databases, in the namespace of the
+	// genesis unless requested otherwise
+	if !ctx.GlobalIsSet(utils.DataDirNamespaceFlag.Name) {
+		ctx.GlobalSet(utils.DataDirNamespaceFlag.Name, utils.GenesisNamespace(genesis.ToBlock(nil).Hash()))
+	}
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
 
@@ -195,6 +340,16 @@ func initGenesis(ctx *cli.Context) error {
 		if err != nil {
 			utils.Fatalf("Failed to open database: %v", err)
 		}
+		if ctx.GlobalBool(utils.ForceFlag.Name) {
+			if stored := rawdb.ReadCanonicalHash(chaindb, 0); stored != (common.Hash{}) {
+				if err := checkGenesisCompatible(chaindb, genesis); err != nil {
+					utils.Fatalf("Incompatible genesis in database %s: %v", name, err)
+				}
+				chaindb.Close()
+				log.Info("Genesis already initialised", "database", name, "hash", stored)
+				continue
+			}
+		}
 		_, hash, err := core.SetupGenesisBlock(chaindb, genesis)
 		if err != nil {
 			utils.Fatalf("Failed to write genesis block: %v", err)
@@ -205,6 +360,118 @@ func initGenesis(ctx *cli.Context) error {
 	return nil
 }
 
+// maxGenesisSize is the maximum size of a genesis file fetched over the network.
+var maxGenesisSize int64 = 256 * 1024 * 1024
+
+// loadGenesis reads a genesis file and verifies it against the checksum, which
+// is required if the file is fetched over the network.
+func loadGenesis(source string, gateway string, checksum string) ([]byte, error) {
+	if checksum == "" && isRemoteGenesis(source) {
+		return nil, fmt.Errorf("--%s is required for remote genesis files", genesisChecksumFlag.Name)
+	}
+	blob, err := readGenesis(source, gateway)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != "" {
+		if err := verifyGenesisChecksum(blob, checksum); err != nil {
+			return nil, err
+		}
+	}
+	return blob, nil
+}
+
+// isRemoteGenesis reports whether a genesis file is fetched over the network.
+func isRemoteGenesis(source string) bool {
+	return strings.HasPrefix(source, "ipfs://") || strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// readGenesis loads a genesis file from a local path, an http(s) URL or an IPFS
+// content identifier (ipfs://<cid>) retrieved through the given gateway.
+func readGenesis(source string, gateway string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(source, "ipfs://"):
+		cid := strings.TrimPrefix(source, "ipfs://")
+		if cid == "" || strings.ContainsAny(cid, "?#") {
+			return nil, fmt.Errorf("invalid IPFS identifier %q", source)
+		}
+		return fetchGenesis(strings.TrimSuffix(gateway, "/") + "/ipfs/" + cid)
+	case strings.HasPrefix(source, "https://"), strings.HasPrefix(source, "http://"):
+		return fetchGenesis(source)
+	default:
+		return ioutil.ReadFile(source)
+	}
+}
+
+// fetchGenesis downloads a genesis file, failing on any non-success response and
+// on files larger than maxGenesisSize.
+func fetchGenesis(url string) ([]byte, error) {
+	client := &http.Client{Timeout: time.Minute}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, res.Status)
+	}
+	blob, err := ioutil.ReadAll(io.LimitReader(res.Body, maxGenesisSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(blob)) > maxGenesisSize {
+		return nil, fmt.Errorf("%s: genesis file larger than %d bytes", url, maxGenesisSize)
+	}
+	return blob, nil
+}
+
+// verifyGenesisChecksum checks that the SHA-256 hash of a genesis file matches
+// the expected hex encoded one.
+func verifyGenesisChecksum(blob []byte, checksum string) error {
+	want, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(checksum), "0x"))
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("invalid checksum %q", checksum)
+	}
+	if have := sha256.Sum256(blob); !bytes.Equal(have[:], want) {
+		return fmt.Errorf("checksum mismatch: have %x, want %x", have, want)
+	}
+	return nil
+}
+
+// checkGenesisCompatible verifies that an initialised database was created with
+// the given genesis block and that its chain can continue under the chain
+// configuration of the genesis.
+func checkGenesisCompatible(db ongdb.Database, genesis *core.Genesis) error {
+	if genesis.Config == nil {
+		return errors.New("genesis has no chain configuration")
+	}
+	stored := rawdb.ReadCanonicalHash(db, 0)
+	if hash := genesis.ToBlock(nil).Hash(); hash != stored {
+		return &core.GenesisMismatchError{Stored: stored, New: hash}
+	}
+	if err := genesis.Config.CheckConfigForkOrder(); err != nil {
+		return err
+	}
+	storedcfg := rawdb.ReadChainConfig(db, stored)
+	if storedcfg == nil {
+		return nil // Written on the first startup
+	}
+	headHash := rawdb.ReadHeadHeaderHash(db)
+	height := rawdb.ReadHeaderNumber(db, headHash)
+	if height == nil {
+		return errors.New("missing block number for head header hash")
+	}
+	head := rawdb.ReadHeader(db, headHash, *height)
+	if head == nil {
+		return errors.New("missing head header")
+	}
+	if err := storedcfg.CheckCompatible(genesis.Config, *height, head.Time); err != nil {
+		return err
+	}
+	return nil
+}
+
 func dumpGenesis(ctx *cli.Context) error {
 	// TODO(rjl493456442) support loading from the custom datadir
 	genesis := utils.MakeGenesis(ctx)
@@ -369,6 +636,140 @@ func exportPreimages(ctx *cli.Context) error {
 	return nil
 }
 
+// repairReceipts regenerates the receipts of the specified block range.
+func repairReceipts(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Repair error in parsing parameters: block number not an integer\n")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, chainDb := utils.MakeChain(ctx, stack, false)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	start := time.Now()
+	repaired, err := chain.RepairReceipts(first, last, ctx.Uint64(repairReexecFlag.Name))
+	if err != nil {
+		utils.Fatalf("Repair error: %v\n", err)
+	}
+	fmt.Printf("Repaired receipts of %d blocks in %v\n", repaired, time.Since(start))
+	return nil
+}
+
+// replayChain re-executes the specified block range with an alternative
+// configuration and reports the first divergence from the imported chain.
+func replayChain(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Replay error in parsing parameters: block number not an integer\n")
+	}
+	config := &core.ReplayConfig{
+		VMConfig:  vm.Config{EVMInterpreter: ctx.String(replayEVMFlag.Name)},
+		TrieCache: ctx.Int(replayCacheFlag.Name),
+		Reexec:    ctx.Uint64(repairReexecFlag.Name),
+	}
+	if path := ctx.String(replayConfigFlag.Name); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			utils.Fatalf("Failed to read chain config file: %v", err)
+		}
+		config.ChainConfig = new(params.ChainConfig)
+		err = json.NewDecoder(file).Decode(config.ChainConfig)
+		file.Close()
+		if err != nil {
+			utils.Fatalf("Invalid chain config file: %v", err)
+		}
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, chainDb := utils.MakeChain(ctx, stack, true)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	start := time.Now()
+	diverged, err := chain.Replay(first, last, config)
+	if err != nil {
+		utils.Fatalf("Replay error: %v\n", err)
+	}
+	if diverged != nil {
+		utils.Fatalf("Replay diverged: %v\n", diverged)
+	}
+	fmt.Printf("Replayed blocks #%d-#%d without divergence in %v\n", first, last, time.Since(start))
+	return nil
+}
+
+// inspectChain checks the consistency of the chain data and prints a report.
+func inspectChain(ctx *cli.Context) error {
+	var (
+		first uint64
+		last  uint64 = math.MaxUint64
+		err   error
+	)
+	switch len(ctx.Args()) {
+	case 0:
+	case 2:
+		first, err = strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+		if err == nil {
+			last, err = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		}
+		if err != nil {
+			utils.Fatalf("Inspect error in parsing parameters: block number not an integer\n")
+		}
+	default:
+		utils.Fatalf("This command requires zero or two arguments.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	start := time.Now()
+	report, err := rawdb.CheckChainConsistency(db, first, last, ctx.Bool(inspectFixFlag.Name))
+	if err != nil {
+		utils.Fatalf("Inspect error: %v\n", err)
+	}
+	fmt.Printf("Checked blocks #%d-#%d in %v (head block #%d, %d ancients)\n", report.From, report.To, time.Since(start), report.HeadBlock, report.Frozen)
+	if len(report.Issues) == 0 {
+		fmt.Println("No inconsistencies found")
+		return nil
+	}
+	// Print the individual issues, capped to avoid flooding the terminal
+	const maxIssues = 100
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Number", "Hash", "Issue", "Details", "Fixed"})
+	for i, issue := range report.Issues {
+		if i == maxIssues {
+			break
+		}
+		table.Append([]string{strconv.FormatUint(issue.Number, 10), issue.Hash.TerminalString(), issue.Kind, issue.Detail, strconv.FormatBool(issue.Fixed)})
+	}
+	table.Render()
+	if len(report.Issues) > maxIssues {
+		fmt.Printf("... %d more issues omitted\n", len(report.Issues)-maxIssues)
+	}
+	summary := tablewriter.NewWriter(os.Stdout)
+	summary.SetHeader([]string{"Issue", "Count"})
+	for kind, count := range report.Summary() {
+		summary.Append([]string{kind, strconv.Itoa(count)})
+	}
+	summary.SetFooter([]string{"Fixed", strconv.Itoa(report.Fixed())})
+	summary.Render()
+	return nil
+}
+
 func dump(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
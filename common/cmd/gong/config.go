@@ -0,0 +1,235 @@
+// Copyright 2017 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"unicode"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/naoina/toml"
+	"github.com/ong2020/go-orange/cmd/utils"
+	"github.com/ong2020/go-orange/internal/ongapi"
+	"github.com/ong2020/go-orange/metrics"
+	"github.com/ong2020/go-orange/node"
+	"github.com/ong2020/go-orange/ong/ongconfig"
+	"github.com/ong2020/go-orange/params"
+)
+
+var (
+	dumpConfigCommand = cli.Command{
+		Action:      utils.MigrateFlags(dumpConfig),
+		Name:        "dumpconfig",
+		Usage:       "Show configuration values",
+		ArgsUsage:   "",
+		Flags:       append(append(nodeFlags, rpcFlags...), dumpConfigDiffFlag),
+		Category:    "MISCELLANEOUS COMMANDS",
+		Description: `The dumpconfig command shows configuration values.`,
+	}
+
+	configFileFlag = cli.StringFlag{
+		Name:  "config",
+		Usage: "TOML configuration file",
+	}
+
+	dumpConfigDiffFlag = cli.BoolFlag{
+		Name:  "diff",
+		Usage: "Only dump the settings which differ from the defaults",
+	}
+)
+
+// These settings ensure that TOML keys use the same names as Go struct fields.
+var tomlSettings = toml.Config{
+	NormFieldName: func(rt reflect.Type, key string) string {
+		return key
+	},
+	FieldToKey: func(rt reflect.Type, field string) string {
+		return field
+	},
+	MissingField: func(rt reflect.Type, field string) error {
+		link := ""
+		if unicode.IsUpper(rune(rt.Name()[0])) && rt.PkgPath() != "main" {
+			link = fmt.Sprintf(", see https://godoc.org/%s#%s for available fields", rt.PkgPath(), rt.Name())
+		}
+		return fmt.Errorf("field '%s' is not defined in %s%s", field, rt.String(), link)
+	},
+}
+
+type ongstatsConfig struct {
+	URL string `toml:",omitempty"`
+}
+
+type gongConfig struct {
+	Ong      ongconfig.Config
+	Node     node.Config
+	Ongstats ongstatsConfig
+	Metrics  metrics.Config
+}
+
+func loadConfig(file string, cfg *gongConfig) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = tomlSettings.NewDecoder(bufio.NewReader(f)).Decode(cfg)
+	// Add file name to errors that have a line number.
+	if _, ok := err.(*toml.LineError); ok {
+		err = errors.New(file + ", " + err.Error())
+	}
+	return err
+}
+
+func defaultNodeConfig() node.Config {
+	cfg := node.DefaultConfig
+	cfg.Name = clientIdentifier
+	cfg.Version = params.VersionWithCommit(gitCommit, gitDate)
+	cfg.HTTPModules = append(cfg.HTTPModules, "ong")
+	cfg.WSModules = append(cfg.WSModules, "ong")
+	cfg.IPCPath = "gong.ipc"
+	return cfg
+}
+
+// makeConfigNode loads gong configuration and creates a blank node instance.
+func makeConfigNode(ctx *cli.Context) (*node.Node, gongConfig) {
+	// Load defaults.
+	cfg := gongConfig{
+		Ong:     ongconfig.Defaults,
+		Node:    defaultNodeConfig(),
+		Metrics: metrics.DefaultConfig,
+	}
+
+	// Load config file.
+	if file := ctx.GlobalString(configFileFlag.Name); file != "" {
+		if err := loadSecretConfig(file, &cfg); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
+
+	// Apply flags.
+	utils.SetNodeConfig(ctx, &cfg.Node)
+	stack, err := node.New(&cfg.Node)
+	if err != nil {
+		utils.Fatalf("Failed to create the protocol stack: %v", err)
+	}
+	utils.SetOngConfig(ctx, stack, &cfg.Ong)
+	if ctx.GlobalIsSet(utils.OngstatsURLFlag.Name) {
+		cfg.Ongstats.URL = ctx.GlobalString(utils.OngstatsURLFlag.Name)
+	}
+	applyMetricConfig(ctx, &cfg)
+
+	return stack, cfg
+}
+
+// makeFullNode loads gong configuration and creates the Orange backend.
+func makeFullNode(ctx *cli.Context) (*node.Node, ongapi.Backend) {
+	stack, cfg := makeConfigNode(ctx)
+	if ctx.GlobalIsSet(utils.OverrideBerlinFlag.Name) {
+		cfg.Ong.OverrideBerlin = new(big.Int).SetUint64(ctx.GlobalUint64(utils.OverrideBerlinFlag.Name))
+	}
+	backend := utils.RegisterOngService(stack, &cfg.Ong)
+
+	// Configure GraphQL if requested
+	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
+		utils.RegisterGraphQLService(stack, backend, cfg.Node)
+	}
+	// Add the Orange Stats daemon if requested.
+	if cfg.Ongstats.URL != "" {
+		utils.RegisterOngstatsService(stack, backend, cfg.Ongstats.URL)
+	}
+	return stack, backend
+}
+
+// dumpConfig is the dumpconfig command.
+func dumpConfig(ctx *cli.Context) error {
+	if ctx.Bool(dumpConfigDiffFlag.Name) {
+		return dumpConfigChanges(ctx)
+	}
+	_, cfg := makeConfigNode(ctx)
+	return writeConfigDump(ctx, &cfg, func(cfg *gongConfig) ([]byte, error) {
+		return tomlSettings.Marshal(cfg)
+	})
+}
+
+// writeConfigDump encodes a node config with the given marshaller and writes it
+// to the file given as argument, or to stdout if none. The genesis block is left
+// out of the dump, with a note.
+func writeConfigDump(ctx *cli.Context, cfg *gongConfig, marshal func(*gongConfig) ([]byte, error)) error {
+	comment := ""
+
+	if cfg.Ong.Genesis != nil {
+		cfg.Ong.Genesis = nil
+		comment += "# Note: this config doesn't contain the genesis block.\n\n"
+	}
+
+	out, err := marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	dump := os.Stdout
+	if ctx.NArg() > 0 {
+		dump, err = os.OpenFile(ctx.Args().Get(0), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		defer dump.Close()
+	}
+	dump.WriteString(comment)
+	_, err = dump.Write(out)
+	return err
+}
+
+func applyMetricConfig(ctx *cli.Context, cfg *gongConfig) {
+	if ctx.GlobalIsSet(utils.MetricsEnabledFlag.Name) {
+		cfg.Metrics.Enabled = ctx.GlobalBool(utils.MetricsEnabledFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsEnabledExpensiveFlag.Name) {
+		cfg.Metrics.EnabledExpensive = ctx.GlobalBool(utils.MetricsEnabledExpensiveFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsHTTPFlag.Name) {
+		cfg.Metrics.HTTP = ctx.GlobalString(utils.MetricsHTTPFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsPortFlag.Name) {
+		cfg.Metrics.Port = ctx.GlobalInt(utils.MetricsPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsEnableInfluxDBFlag.Name) {
+		cfg.Metrics.EnableInfluxDB = ctx.GlobalBool(utils.MetricsEnableInfluxDBFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsInfluxDBEndpointFlag.Name) {
+		cfg.Metrics.InfluxDBEndpoint = ctx.GlobalString(utils.MetricsInfluxDBEndpointFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsInfluxDBDatabaseFlag.Name) {
+		cfg.Metrics.InfluxDBDatabase = ctx.GlobalString(utils.MetricsInfluxDBDatabaseFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsInfluxDBUsernameFlag.Name) {
+		cfg.Metrics.InfluxDBUsername = ctx.GlobalString(utils.MetricsInfluxDBUsernameFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsInfluxDBPasswordFlag.Name) {
+		cfg.Metrics.InfluxDBPassword = ctx.GlobalString(utils.MetricsInfluxDBPasswordFlag.Name)
+	}
+	if ctx.GlobalIsSet(utils.MetricsInfluxDBTagsFlag.Name) {
+		cfg.Metrics.InfluxDBTags = ctx.GlobalString(utils.MetricsInfluxDBTagsFlag.Name)
+	}
+}
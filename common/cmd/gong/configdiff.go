@@ -0,0 +1,128 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ong2020/go-orange/metrics"
+	"github.com/ong2020/go-orange/ong/ongconfig"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// tomlSection is a table of a dumped TOML config, with its key/value lines in
+// their original order.
+type tomlSection struct {
+	name   string
+	keys   []string
+	values map[string]string
+}
+
+// parseTOMLSections splits a TOML config as dumped by gong into its tables. Each
+// setting is emitted by the encoder on a single line, so the values are kept as
+// their raw text for comparison.
+func parseTOMLSections(config []byte) []*tomlSection {
+	var (
+		sections = []*tomlSection{{values: make(map[string]string)}}
+		scanner  = bufio.NewScanner(bytes.NewReader(config))
+	)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			sections = append(sections, &tomlSection{name: line, values: make(map[string]string)})
+		default:
+			section := sections[len(sections)-1]
+			key := strings.TrimSpace(strings.SplitN(line, "=", 2)[0])
+			section.keys = append(section.keys, key)
+			section.values[key] = line
+		}
+	}
+	return sections
+}
+
+// diffTOMLConfig returns the settings of a dumped TOML config which differ from
+// the dumped defaults. Settings present in the defaults but missing from the
+// config (i.e. explicitly zeroed ones) are listed as comments.
+func diffTOMLConfig(config, defaults []byte) []byte {
+	base := make(map[string]*tomlSection)
+	for _, section := range parseTOMLSections(defaults) {
+		base[section.name] = section
+	}
+	out := new(bytes.Buffer)
+	for _, section := range parseTOMLSections(config) {
+		var (
+			lines []string
+			def   = base[section.name]
+		)
+		for _, key := range section.keys {
+			if def == nil || def.values[key] != section.values[key] {
+				lines = append(lines, section.values[key])
+			}
+		}
+		if def != nil {
+			for _, key := range def.keys {
+				if _, ok := section.values[key]; !ok {
+					lines = append(lines, fmt.Sprintf("# %s unset, default: %s", key, def.values[key]))
+				}
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		if section.name != "" {
+			if out.Len() > 0 {
+				out.WriteString("\n")
+			}
+			out.WriteString(section.name + "\n")
+		}
+		out.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+	return out.Bytes()
+}
+
+// dumpConfigDiff encodes the settings of a node config which differ from the
+// defaults, to audit the configuration drift of a node.
+func dumpConfigDiff(cfg *gongConfig) ([]byte, error) {
+	defaults := gongConfig{
+		Ong:     ongconfig.Defaults,
+		Node:    defaultNodeConfig(),
+		Metrics: metrics.DefaultConfig,
+	}
+	have, err := tomlSettings.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	want, err := tomlSettings.Marshal(&defaults)
+	if err != nil {
+		return nil, err
+	}
+	return diffTOMLConfig(have, want), nil
+}
+
+// dumpConfigChanges is the dumpconfig command with --diff, dumping the settings
+// which differ from the defaults to the given file or to stdout.
+func dumpConfigChanges(ctx *cli.Context) error {
+	_, cfg := makeConfigNode(ctx)
+	return writeConfigDump(ctx, &cfg, dumpConfigDiff)
+}
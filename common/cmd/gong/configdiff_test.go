@@ -0,0 +1,83 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that only the settings differing from the defaults are diffed, with the
+// settings missing from the config listed as comments.
+func TestDiffTOMLConfig(t *testing.T) {
+	defaults := `
+[Ong]
+NetworkId = 1
+SyncMode = "snap"
+NoPruning = false
+
+[Node]
+DataDir = "/data"
+HTTPPort = 8545
+`
+	config := `
+[Ong]
+NetworkId = 1234
+SyncMode = "snap"
+
+[Node]
+DataDir = "/data"
+HTTPPort = 8545
+
+[Metrics]
+Enabled = true
+`
+	want := `[Ong]
+NetworkId = 1234
+# NoPruning unset, default: NoPruning = false
+
+[Metrics]
+Enabled = true
+`
+	if have := string(diffTOMLConfig([]byte(config), []byte(defaults))); have != want {
+		t.Errorf("diff mismatch:\nhave:\n%s\nwant:\n%s", have, want)
+	}
+}
+
+// Tests that dumpconfig --diff dumps the settings changed by the flags alone.
+func TestDumpConfigDiff(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.toml")
+	gong := runGong(t, "dumpconfig", "--diff", "--networkid", "1234", file)
+	gong.ExpectExit()
+
+	dump, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read dumped config: %v", err)
+	}
+	if !strings.Contains(string(dump), "NetworkId = 1234") {
+		t.Errorf("changed setting missing from the diff:\n%s", dump)
+	}
+	if strings.Contains(string(dump), "SyncMode") {
+		t.Errorf("default setting in the diff:\n%s", dump)
+	}
+}
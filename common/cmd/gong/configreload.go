@@ -0,0 +1,79 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+
+	"github.com/ong2020/go-orange/cmd/utils"
+	"github.com/ong2020/go-orange/internal/ongapi"
+	"github.com/ong2020/go-orange/metrics"
+	"github.com/ong2020/go-orange/node"
+	"github.com/ong2020/go-orange/ong/ongconfig"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// configApplier is implemented by the backends able to apply the changes of
+// their settings at runtime, i.e. the full node.
+type configApplier interface {
+	ApplyConfig(cfg *ongconfig.Config, maxPeers int, res *node.ConfigReloadResult)
+}
+
+// setConfigReloader installs the reloader of the config file on the node, which
+// is invoked on SIGHUP and by admin_reloadConfig. Nothing is installed if the
+// node was not started from a config file.
+func setConfigReloader(ctx *cli.Context, stack *node.Node, backend ongapi.Backend) {
+	if ctx.GlobalString(configFileFlag.Name) == "" {
+		return
+	}
+	stack.SetConfigReloader(configReloader(ctx, stack, backend))
+}
+
+// configReloader returns a function re-reading the config file of a node and
+// applying the changes of the settings adjustable at runtime. The command line
+// flags are applied on top of the file again, so only the changes made to the
+// file itself are considered. The changes of the Ong settings are only applied
+// by full nodes.
+func configReloader(ctx *cli.Context, stack *node.Node, backend ongapi.Backend) node.ConfigReloader {
+	return func() (*node.ConfigReloadResult, error) {
+		file := ctx.GlobalString(configFileFlag.Name)
+		if file == "" {
+			return nil, errors.New("node not started from a config file")
+		}
+		cfg := gongConfig{
+			Ong:     ongconfig.Defaults,
+			Node:    defaultNodeConfig(),
+			Metrics: metrics.DefaultConfig,
+		}
+		if err := loadSecretConfig(file, &cfg); err != nil {
+			return nil, err
+		}
+		utils.SetNodeConfig(ctx, &cfg.Node)
+		utils.SetOngConfig(ctx, stack, &cfg.Ong)
+		if cfg.Ong.TxPool.Journal != "" {
+			// Resolved by the full node on startup, see ong.New
+			cfg.Ong.TxPool.Journal = stack.ResolvePath(cfg.Ong.TxPool.Journal)
+		}
+
+		res := node.NewConfigReloadResult()
+		stack.ApplyConfig(&cfg.Node, res)
+		if applier, ok := backend.(configApplier); ok {
+			applier.ApplyConfig(&cfg.Ong, cfg.Node.P2P.MaxPeers, res)
+		}
+		return res, nil
+	}
+}
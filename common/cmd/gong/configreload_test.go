@@ -0,0 +1,75 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/node"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// Tests that the config file of a running gong daemon is reloaded through the
+// admin API, applying the settings adjustable at runtime.
+func TestDaemonReloadConfig(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.toml")
+	writeConfig := func(verbosity string) {
+		if err := ioutil.WriteFile(file, []byte("[Node]\nVerbosity = \""+verbosity+"\"\n"), 0600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+	}
+	writeConfig("info")
+
+	ipc := filepath.Join(dir, "gong.ipc")
+	if runtime.GOOS == "windows" {
+		ipc = `\\.\pipe\gong` + strconv.Itoa(trulyRandInt(100000, 999999))
+	}
+	gong := runMinimalGong(t, "--config", file, "--ipcpath", ipc)
+	defer func() {
+		gong.Interrupt()
+		gong.WaitExit()
+	}()
+	waitForEndpoint(t, ipc, 3*time.Second)
+
+	client, err := rpc.Dial(ipc)
+	if err != nil {
+		t.Fatalf("failed to attach to gong: %v", err)
+	}
+	defer client.Close()
+
+	writeConfig("debug")
+	var res node.ConfigReloadResult
+	if err := client.Call(&res, "admin_reloadConfig"); err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if !reflect.DeepEqual(res.Applied, []string{"Node.Verbosity"}) {
+		t.Errorf("applied settings mismatch: have %v, want [Node.Verbosity]", res.Applied)
+	}
+	if len(res.Rejected) != 0 {
+		t.Errorf("unexpected rejected settings: %v", res.Rejected)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import "github.com/ong2020/go-orange/cmd/utils"
+
+// loadSecretConfig loads a config file, expanding the references to environment
+// variables and secret files in its values once decoded.
+func loadSecretConfig(file string, cfg *gongConfig) error {
+	if err := loadConfig(file, cfg); err != nil {
+		return err
+	}
+	return utils.ExpandConfigSecrets(cfg)
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Tests that the secrets referenced by the config file of the node are expanded
+// when the node is configured.
+func TestConfigSecrets(t *testing.T) {
+	dir := tmpdir(t)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("GONG_TEST_IDENT", "secret-ident")
+	defer os.Unsetenv("GONG_TEST_IDENT")
+
+	config := filepath.Join(dir, "config.toml")
+	if err := ioutil.WriteFile(config, []byte("[Node]\nUserIdent = \"${GONG_TEST_IDENT}\"\n"), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	dump := filepath.Join(dir, "dump.toml")
+	gong := runGong(t, "--config", config, "dumpconfig", dump)
+	gong.ExpectExit()
+
+	blob, err := ioutil.ReadFile(dump)
+	if err != nil {
+		t.Fatalf("failed to read dumped config: %v", err)
+	}
+	if !strings.Contains(string(blob), `UserIdent = "secret-ident"`) {
+		t.Errorf("secret not expanded:\n%s", blob)
+	}
+}
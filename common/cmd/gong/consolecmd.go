@@ -30,7 +30,7 @@ import (
 )
 
 var (
-	consoleFlags = []cli.Flag{utils.JSpathFlag, utils.ExecFlag, utils.PreloadJSFlag}
+	consoleFlags = []cli.Flag{utils.JSpathFlag, utils.ExecFlag, utils.PreloadJSFlag, utils.ConsoleRecordFlag}
 
 	consoleCommand = cli.Command{
 		Action:   utils.MigrateFlags(localConsole),
@@ -77,6 +77,7 @@ func localConsole(ctx *cli.Context) error {
 	// Create and start the node based on the CLI flags
 	prepare(ctx)
 	stack, backend := makeFullNode(ctx)
+	setConfigReloader(ctx, stack, backend)
 	startNode(ctx, stack, backend)
 	defer stack.Close()
 
@@ -90,6 +91,7 @@ func localConsole(ctx *cli.Context) error {
 		DocRoot: ctx.GlobalString(utils.JSpathFlag.Name),
 		Client:  client,
 		Preload: utils.MakeConsolePreloads(ctx),
+		Record:  ctx.GlobalString(utils.ConsoleRecordFlag.Name),
 	}
 
 	console, err := console.New(config)
@@ -149,6 +151,7 @@ func remoteConsole(ctx *cli.Context) error {
 		DocRoot: ctx.GlobalString(utils.JSpathFlag.Name),
 		Client:  client,
 		Preload: utils.MakeConsolePreloads(ctx),
+		Record:  ctx.GlobalString(utils.ConsoleRecordFlag.Name),
 	}
 
 	console, err := console.New(config)
@@ -189,6 +192,7 @@ func dialRPC(endpoint string) (*rpc.Client, error) {
 func ephemeralConsole(ctx *cli.Context) error {
 	// Create and start the node based on the CLI flags
 	stack, backend := makeFullNode(ctx)
+	setConfigReloader(ctx, stack, backend)
 	startNode(ctx, stack, backend)
 	defer stack.Close()
 
@@ -202,6 +206,7 @@ func ephemeralConsole(ctx *cli.Context) error {
 		DocRoot: ctx.GlobalString(utils.JSpathFlag.Name),
 		Client:  client,
 		Preload: utils.MakeConsolePreloads(ctx),
+		Record:  ctx.GlobalString(utils.ConsoleRecordFlag.Name),
 	}
 
 	console, err := console.New(config)
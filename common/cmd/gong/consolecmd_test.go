@@ -27,12 +27,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ong2020/go-orange/core"
 	"github.com/ong2020/go-orange/params"
 )
 
 const (
-	ipcAPIs  = "admin:1.0 debug:1.0 ong:1.0 ongash:1.0 miner:1.0 net:1.0 personal:1.0 rpc:1.0 txpool:1.0 web3:1.0"
-	httpAPIs = "ong:1.0 net:1.0 rpc:1.0 web3:1.0"
+	ipcAPIs  = "admin:1.0 debug:1.0 miner:1.0 net:1.0 ong:1.0 ongash:1.0 personal:1.0 rpc:1.0 txpool:1.0 web3:1.0"
+	httpAPIs = "net:1.0 ong:1.0 rpc:1.0 web3:1.0"
 )
 
 // spawns gong with the given command line args, using a set of flags to minimise
@@ -60,8 +61,8 @@ func TestConsoleWelcome(t *testing.T) {
 	gong.SetTemplateFunc("goarch", func() string { return runtime.GOARCH })
 	gong.SetTemplateFunc("gover", runtime.Version)
 	gong.SetTemplateFunc("gongver", func() string { return params.VersionWithCommit("", "") })
-	gong.SetTemplateFunc("niltime", func() string {
-		return time.Unix(0, 0).Format("Mon Jan 02 2006 15:04:05 GMT-0700 (MST)")
+	gong.SetTemplateFunc("genesistime", func() string {
+		return time.Unix(int64(core.DefaultRopstenGenesisBlock().Timestamp), 0).Format("Mon Jan 02 2006 15:04:05 GMT-0700 (MST)")
 	})
 	gong.SetTemplateFunc("apis", func() string { return ipcAPIs })
 
@@ -71,7 +72,7 @@ Welcome to the Gong JavaScript console!
 
 instance: Gong/v{{gongver}}/{{goos}}-{{goarch}}/{{gover}}
 coinbase: {{.Orangerbase}}
-at block: 0 ({{niltime}})
+at block: 0 ({{genesistime}})
  datadir: {{.Datadir}}
  modules: {{apis}}
 
@@ -132,8 +133,8 @@ func testAttachWelcome(t *testing.T, gong *testgong, endpoint, apis string) {
 	attach.SetTemplateFunc("gover", runtime.Version)
 	attach.SetTemplateFunc("gongver", func() string { return params.VersionWithCommit("", "") })
 	attach.SetTemplateFunc("ongerbase", func() string { return gong.Orangerbase })
-	attach.SetTemplateFunc("niltime", func() string {
-		return time.Unix(0, 0).Format("Mon Jan 02 2006 15:04:05 GMT-0700 (MST)")
+	attach.SetTemplateFunc("genesistime", func() string {
+		return time.Unix(int64(core.DefaultRopstenGenesisBlock().Timestamp), 0).Format("Mon Jan 02 2006 15:04:05 GMT-0700 (MST)")
 	})
 	attach.SetTemplateFunc("ipc", func() bool { return strings.HasPrefix(endpoint, "ipc") })
 	attach.SetTemplateFunc("datadir", func() string { return gong.Datadir })
@@ -145,7 +146,7 @@ Welcome to the Gong JavaScript console!
 
 instance: Gong/v{{gongver}}/{{goos}}-{{goarch}}/{{gover}}
 coinbase: {{ongerbase}}
-at block: 0 ({{niltime}}){{if ipc}}
+at block: 0 ({{genesistime}}){{if ipc}}
  datadir: {{datadir}}{{end}}
  modules: {{apis}}
 
@@ -23,7 +23,9 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/ong2020/go-orange/cmd/utils"
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core"
 	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/params"
 )
@@ -121,18 +123,18 @@ func testDAOForkBlockNewChain(t *testing.T, test int, genesis string, expectBloc
 		args := []string{"--port", "0", "--networkid", "1337", "--maxpeers", "0", "--nodiscover", "--nat", "none", "--ipcdisable", "--datadir", datadir}
 		runGong(t, append(args, []string{"--exec", "2+2", "console"}...)...).WaitExit()
 	}
-	// Retrieve the DAO config flag from the database
-	path := filepath.Join(datadir, "gong", "chaindata")
+	genesisHash := core.DefaultGenesisBlock().ToBlock(nil).Hash()
+	if genesis != "" {
+		genesisHash = daoGenesisHash
+	}
+	// Retrieve the DAO config flag from the database of the network's namespace
+	path := filepath.Join(datadir, "gong", utils.GenesisNamespace(genesisHash), "chaindata")
 	db, err := rawdb.NewLevelDBDatabase(path, 0, 0, "")
 	if err != nil {
 		t.Fatalf("test %d: failed to open test database: %v", test, err)
 	}
 	defer db.Close()
 
-	genesisHash := common.HexToHash("0xd4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3")
-	if genesis != "" {
-		genesisHash = daoGenesisHash
-	}
 	config := rawdb.ReadChainConfig(db, genesisHash)
 	if config == nil {
 		t.Errorf("test %d: failed to retrieve chain config: %v", test, err)
@@ -59,6 +59,8 @@ Remove blockchain and state databases`,
 			dbGetCmd,
 			dbDeleteCmd,
 			dbPutCmd,
+			dbRecompressCmd,
+			dbSchemaCmd,
 		},
 	}
 	dbInspectCmd = cli.Command{
@@ -105,6 +107,26 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		Description: `This command sets a given database key to the given value. 
 WARNING: This is a low-level operation which may cause database corruption!`,
 	}
+	dbRecompressCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbRecompress),
+		Name:      "freezer-recompress",
+		Usage:     "Recompress an ancient table with a different algorithm",
+		ArgsUsage: "<table> <none|snappy|zstd>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+		},
+		Description: `This command rewrites an ancient table (headers, hashes, bodies, receipts
+or diffs) with the given compression algorithm. The node must not be running.
+The new table is assembled next to the old one, which is only deleted once the
+new one is complete, so enough free disk space for both is required.`,
+	}
+	dbSchemaCmd = cli.Command{
+		Action:      dbSchema,
+		Name:        "schema",
+		Usage:       "Print the layout of the database tables",
+		Description: "This command prints the key layouts and value encodings of the database tables as markdown.",
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -258,6 +280,30 @@ func dbCompact(ctx *cli.Context) error {
 	return err
 }
 
+// dbRecompress rewrites an ancient table with a different compression algorithm.
+func dbRecompress(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, config := makeConfigNode(ctx)
+	defer stack.Close()
+
+	path := config.Ong.DatabaseFreezer
+	switch {
+	case path == "":
+		path = filepath.Join(stack.ResolvePath("chaindata"), "ancient")
+	case !filepath.IsAbs(path):
+		path = config.Node.ResolvePath(path)
+	}
+	return rawdb.RecompressFreezerTable(path, ctx.Args().Get(0), ctx.Args().Get(1))
+}
+
+// dbSchema prints the documentation of the database key schema.
+func dbSchema(ctx *cli.Context) error {
+	fmt.Print(rawdb.KeySchemaDoc())
+	return nil
+}
+
 // dbGet shows the value of a given database key
 func dbGet(ctx *cli.Context) error {
 	if ctx.NArg() != 1 {
@@ -0,0 +1,56 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ong2020/go-orange/cmd/utils"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/ongdb/memorydb"
+)
+
+// Tests that the ancient tables of a node can be recompressed with zstd offline.
+func TestDBRecompress(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	genesisHash := core.DefaultGenesisBlock().ToBlock(nil).Hash()
+	ancient := filepath.Join(datadir, "gong", utils.GenesisNamespace(genesisHash), "chaindata", "ancient")
+
+	db, err := rawdb.NewDatabaseWithFreezer(memorydb.New(), ancient, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	if err := db.AppendAncient(0, genesisHash[:], []byte{0x01}, []byte{0x02}, []byte{0x03}, []byte{0x04}); err != nil {
+		t.Fatalf("failed to append ancient block: %v", err)
+	}
+	db.Close()
+
+	gong := runGong(t, "--datadir", datadir, "db", "freezer-recompress", "receipts", rawdb.FreezerCompressionZstd)
+	gong.ExpectExit()
+	if status := gong.ExitStatus(); status != 0 {
+		t.Fatalf("recompression failed with status %d: %s", status, gong.StderrText())
+	}
+	if !common.FileExist(filepath.Join(ancient, "receipts.zidx")) {
+		t.Error("receipts table not recompressed with zstd")
+	}
+}
@@ -17,7 +17,12 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -92,3 +97,62 @@ func TestCustomGenesis(t *testing.T) {
 		gong.ExpectExit()
 	}
 }
+
+// Tests that genesis files can be initialized from URLs and IPFS gateways, and
+// that their checksum is enforced.
+func TestRemoteGenesis(t *testing.T) {
+	genesis := []byte(customGenesisTests[0].genesis)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/genesis.json", "/ipfs/QmGenesis":
+			w.Write(genesis)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, source := range []string{server.URL + "/genesis.json", "ipfs://QmGenesis"} {
+		blob, err := readGenesis(source, server.URL)
+		if err != nil {
+			t.Fatalf("%s: failed to read genesis: %v", source, err)
+		}
+		if string(blob) != string(genesis) {
+			t.Fatalf("%s: genesis mismatch", source)
+		}
+	}
+	if _, err := readGenesis(server.URL+"/missing.json", server.URL); err == nil {
+		t.Fatalf("missing genesis read")
+	}
+	sum := sha256.Sum256(genesis)
+	if err := verifyGenesisChecksum(genesis, hex.EncodeToString(sum[:])); err != nil {
+		t.Fatalf("valid checksum rejected: %v", err)
+	}
+	if err := verifyGenesisChecksum(genesis, fmt.Sprintf("%x", sha256.Sum256(nil))); err == nil {
+		t.Fatalf("invalid checksum accepted")
+	}
+	// Remote genesis files must come with a checksum, local ones needn't
+	checksum := hex.EncodeToString(sum[:])
+	for _, source := range []string{server.URL + "/genesis.json", "ipfs://QmGenesis"} {
+		if _, err := loadGenesis(source, server.URL, ""); err == nil {
+			t.Errorf("%s: remote genesis loaded without checksum", source)
+		}
+		if _, err := loadGenesis(source, server.URL, checksum); err != nil {
+			t.Errorf("%s: failed to load genesis: %v", source, err)
+		}
+	}
+	local := filepath.Join(t.TempDir(), "genesis.json")
+	if err := ioutil.WriteFile(local, genesis, 0600); err != nil {
+		t.Fatalf("failed to write genesis: %v", err)
+	}
+	if _, err := loadGenesis(local, server.URL, ""); err != nil {
+		t.Errorf("failed to load local genesis without checksum: %v", err)
+	}
+	// Oversized remote genesis files are rejected
+	defer func(size int64) { maxGenesisSize = size }(maxGenesisSize)
+	maxGenesisSize = int64(len(genesis)) - 1
+	if _, err := readGenesis(server.URL+"/genesis.json", server.URL); err == nil {
+		t.Errorf("oversized genesis read")
+	}
+}
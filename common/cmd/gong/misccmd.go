@@ -42,6 +42,18 @@ var (
 			params.VersionWithCommit(gitCommit, gitDate),
 			runtime.GOOS, runtime.GOARCH, runtime.Version()),
 	}
+	VersionCheckFileFlag = cli.StringFlag{
+		Name:  "check.file",
+		Usage: "Local vulnerabilities file to check against instead of the URL (offline mode)",
+	}
+	VersionCheckSkipSigFlag = cli.BoolFlag{
+		Name:  "check.skipsig",
+		Usage: "Skip the signature verification of the local vulnerabilities file",
+	}
+	VersionCheckJSONFlag = cli.BoolFlag{
+		Name:  "check.json",
+		Usage: "Print the outcome of the check as JSON",
+	}
 	makecacheCommand = cli.Command{
 		Action:    utils.MigrateFlags(makecache),
 		Name:      "makecache",
@@ -83,14 +95,23 @@ The output of this command is supposed to be machine-readable.
 		Flags: []cli.Flag{
 			VersionCheckUrlFlag,
 			VersionCheckVersionFlag,
+			VersionCheckFileFlag,
+			VersionCheckSkipSigFlag,
+			VersionCheckJSONFlag,
 		},
 		Name:      "version-check",
-		Usage:     "Checks (online) whonger the current version suffers from any known security vulnerabilities",
+		Usage:     "Checks (online or from a local file) whonger the current version suffers from any known security vulnerabilities",
 		ArgsUsage: "<versionstring (optional)>",
 		Category:  "MISCELLANEOUS COMMANDS",
 		Description: `
 The version-check command fetches vulnerability-information from https://gong.orange2020.com/docs/vulnerabilities/vulnerabilities.json, 
 and displays information about any security vulnerabilities that affect the currently executing version.
+
+With --check.file, the vulnerabilities are read from a local file instead, its signature
+being read from the file with the .minisig suffix unless --check.skipsig is set.
+
+The exit code is 0 if no vulnerability affects the version, 2 if some do and 3 if the
+vulnerabilities could not be checked.
 `,
 	}
 	licenseCommand = cli.Command{
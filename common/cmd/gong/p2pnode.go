@@ -0,0 +1,40 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/ong2020/go-orange/cmd/utils"
+	"github.com/ong2020/go-orange/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// bootnode runs gong in --bootnode-only mode: only the p2p server is started
+// with the v4 and v5 discovery protocols (serving the local ENR), no chain
+// database is opened and no Orange or light services are registered. The
+// discovered node table can be inspected via admin_discoveryTable.
+func bootnode(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	utils.StartNode(ctx, stack)
+
+	self := stack.Server().Self()
+	log.Info("Started bootstrap node", "enode", self.URLv4(), "enr", self.String())
+
+	stack.Wait()
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that --bootnode-only runs the p2p server alone, without making the full
+// node and opening its chain database.
+func TestBootnodeOnly(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	ipc := filepath.Join(datadir, "gong.ipc")
+	if runtime.GOOS == "windows" {
+		ipc = `\\.\pipe\gong` + strconv.Itoa(trulyRandInt(100000, 999999))
+	}
+	gong := runGong(t,
+		"--bootnode-only", "--datadir", datadir, "--port", "0", "--nat", "none",
+		"--ipcpath", ipc)
+	waitForEndpoint(t, ipc, 5*time.Second)
+	gong.Interrupt()
+	gong.WaitExit()
+
+	if !strings.Contains(gong.StderrText(), "Started bootstrap node") {
+		t.Errorf("bootnode not started, output:\n%s", gong.StderrText())
+	}
+	filepath.Walk(datadir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && info.Name() == "chaindata" {
+			t.Errorf("chain database opened at %s", path)
+		}
+		return nil
+	})
+}
@@ -36,7 +36,11 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			configFileFlag,
 			utils.DataDirFlag,
 			utils.AncientFlag,
+			utils.AncientCompressionFlag,
 			utils.MinFreeDiskSpaceFlag,
+			utils.WarnFreeDiskSpaceFlag,
+			utils.DataDirNamespaceFlag,
+			utils.ForceFlag,
 			utils.KeyStoreDirFlag,
 			utils.USBFlag,
 			utils.SmartCardDaemonPathFlag,
@@ -68,6 +72,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.UltraLightOnlyAnnounceFlag,
 			utils.LightNoPruneFlag,
 			utils.LightNoSyncServeFlag,
+			utils.LightMaxProofBloatFlag,
 		},
 	},
 	{
@@ -116,6 +121,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.CacheTrieRejournalFlag,
 			utils.CacheGCFlag,
 			utils.CacheSnapshotFlag,
+			utils.CacheSnapshotRejournalFlag,
 			utils.CacheNoPrefetchFlag,
 			utils.CachePreimagesFlag,
 		},
@@ -134,28 +140,76 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Flags: []cli.Flag{
 			utils.IPCDisabledFlag,
 			utils.IPCPathFlag,
+			utils.IPCModeFlag,
+			utils.IPCGroupFlag,
+			utils.IPCSELinuxLabelFlag,
 			utils.HTTPEnabledFlag,
 			utils.HTTPListenAddrFlag,
 			utils.HTTPPortFlag,
 			utils.HTTPApiFlag,
+			utils.HTTPMethodsFlag,
 			utils.HTTPPathPrefixFlag,
+			utils.HTTPGRPCFlag,
 			utils.HTTPCORSDomainFlag,
 			utils.HTTPVirtualHostsFlag,
 			utils.WSEnabledFlag,
 			utils.WSListenAddrFlag,
 			utils.WSPortFlag,
 			utils.WSApiFlag,
+			utils.WSMethodsFlag,
 			utils.WSPathPrefixFlag,
 			utils.WSAllowedOriginsFlag,
+			utils.WSCompressionFlag,
+			utils.WSReadLimitFlag,
+			utils.WSPingIntervalFlag,
+			utils.WSPongTimeoutFlag,
 			utils.GraphQLEnabledFlag,
 			utils.GraphQLCORSDomainFlag,
 			utils.GraphQLVirtualHostsFlag,
+			utils.HealthEnabledFlag,
+			utils.HealthMinPeersFlag,
+			utils.HealthMaxHeadAgeFlag,
+			utils.RelayEnabledFlag,
+			utils.RelayRelayerFlag,
+			utils.RelayMaxGasFlag,
+			utils.RelaySenderLimitFlag,
+			utils.RelayDailyLimitFlag,
+			utils.RelayTargetsFlag,
+			utils.RelayReimbursementTopicFlag,
+			utils.SystemContractsFlag,
+			utils.ExporterSinksFlag,
+			utils.ExporterFormatFlag,
+			utils.ExporterFromFlag,
 			utils.RPCGlobalGasCapFlag,
+			utils.RPCEVMTimeoutFlag,
+			utils.RPCBlockRangeCapFlag,
+			utils.RPCPersistentFiltersFlag,
+			utils.RPCSafeDepthFlag,
+			utils.RPCFinalizedDepthFlag,
+			utils.RPCVerifySnapshotFlag,
+			utils.RPCTraceTimeoutFlag,
+			utils.RPCEVMBudgetFlag,
 			utils.RPCGlobalTxFeeCapFlag,
+			utils.RPCTxSpendCapFlag,
+			utils.RPCDailySpendCapFlag,
 			utils.AllowUnprotectedTxs,
+			utils.BatchRequestLimitFlag,
+			utils.BatchResponseMaxSizeFlag,
+			utils.RPCTimeoutsFlag,
+			utils.RPCRateLimitsFlag,
+			utils.RPCNotifyBatchFlag,
+			utils.RPCAuthNamespacesFlag,
+			utils.RPCAuthJWTSecretFlag,
+			utils.RPCAuthAPIKeysFlag,
+			utils.RPCTLSCertFlag,
+			utils.RPCTLSKeyFlag,
+			utils.RPCTLSClientCAFlag,
+			utils.RPCTLSRequireClientCertFlag,
+			utils.RPCAdvertiseFlag,
 			utils.JSpathFlag,
 			utils.ExecFlag,
 			utils.PreloadJSFlag,
+			utils.ConsoleRecordFlag,
 		},
 	},
 	{
@@ -169,7 +223,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.BootnodeOnlyFlag,
 			utils.NetrestrictFlag,
+			utils.ForensicsFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
 		},
@@ -187,6 +243,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
 			utils.MinerNoVerfiyFlag,
+			utils.MinerMaxTxsFlag,
+			utils.MinerFillDeadlineFlag,
+			utils.MinerDeterministicFlag,
+			utils.MinerSeedFlag,
 		},
 	},
 	{
@@ -232,6 +292,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name: "MISC",
 		Flags: []cli.Flag{
 			utils.SnapshotFlag,
+			utils.SnapshotThrottleFlag,
+			utils.SnapServeRequestsFlag,
+			utils.SnapServePeerRequestsFlag,
+			utils.SnapServeBandwidthFlag,
 			utils.BloomFilterSizeFlag,
 			cli.HelpFlag,
 		},
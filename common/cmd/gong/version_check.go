@@ -39,74 +39,151 @@ var gongPubKeys []string = []string{
 	"RWSEhAnSshOY/b+GmaiDkObbCWefsAoavjoLcPjBo1xn71yuOH5I+Lts",
 }
 
+// Exit codes of the version-check command, so deployment pipelines can tell the
+// outcomes apart. Exit code 1 is left to the usage errors.
+const (
+	versionCheckClean      = 0 // No known vulnerability affects the version
+	versionCheckVulnerable = 2 // The version is affected by known vulnerabilities
+	versionCheckUnknown    = 3 // The vulnerabilities could not be checked
+)
+
 type vulnJson struct {
-	Name        string
-	Uid         string
-	Summary     string
-	Description string
-	Links       []string
-	Introduced  string
-	Fixed       string
-	Published   string
-	Severity    string
-	Check       string
-	CVE         string
+	Name        string   `json:"name"`
+	Uid         string   `json:"uid"`
+	Summary     string   `json:"summary"`
+	Description string   `json:"description"`
+	Links       []string `json:"links"`
+	Introduced  string   `json:"introduced"`
+	Fixed       string   `json:"fixed"`
+	Published   string   `json:"published"`
+	Severity    string   `json:"severity"`
+	Check       string   `json:"check"`
+	CVE         string   `json:"CVE"`
 }
 
-func versionCheck(ctx *cli.Context) error {
-	url := ctx.String(VersionCheckUrlFlag.Name)
-	version := ctx.String(VersionCheckVersionFlag.Name)
-	log.Info("Checking vulnerabilities", "version", version, "url", url)
-	return checkCurrent(url, version)
+// versionCheckReport is the machine-readable outcome of a version check.
+type versionCheckReport struct {
+	Version         string     `json:"version"`
+	Source          string     `json:"source"`
+	Status          string     `json:"status"` // clean, vulnerable or unknown
+	Error           string     `json:"error,omitempty"`
+	Vulnerabilities []vulnJson `json:"vulnerabilities"`
 }
 
-func checkCurrent(url, current string) error {
+func versionCheck(ctx *cli.Context) error {
 	var (
-		data []byte
-		sig  []byte
-		err  error
+		source  = ctx.String(VersionCheckUrlFlag.Name)
+		version = ctx.String(VersionCheckVersionFlag.Name)
+		verify  = true
 	)
-	if data, err = fetch(url); err != nil {
-		return fmt.Errorf("could not retrieve data: %w", err)
+	if file := ctx.String(VersionCheckFileFlag.Name); file != "" {
+		source = "file://" + file
+		verify = !ctx.Bool(VersionCheckSkipSigFlag.Name)
 	}
-	if sig, err = fetch(fmt.Sprintf("%v.minisig", url)); err != nil {
-		return fmt.Errorf("could not retrieve signature: %w", err)
+	if !ctx.Bool(VersionCheckJSONFlag.Name) {
+		log.Info("Checking vulnerabilities", "version", version, "source", source)
 	}
-	if err = verifySignature(gongPubKeys, data, sig); err != nil {
-		return err
+	report := checkCurrent(source, version, verify)
+	if ctx.Bool(VersionCheckJSONFlag.Name) {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		printReport(report)
+	}
+	switch report.Status {
+	case "vulnerable":
+		return cli.NewExitError("", versionCheckVulnerable)
+	case "unknown":
+		return cli.NewExitError("", versionCheckUnknown)
+	}
+	return nil
+}
+
+// checkCurrent checks the version against the vulnerabilities published at the
+// given source, a URL or a local file. The signature of the vulnerabilities is
+// verified unless told otherwise.
+func checkCurrent(source, current string, verify bool) *versionCheckReport {
+	report := &versionCheckReport{Version: current, Source: source, Vulnerabilities: []vulnJson{}}
+	vulns, err := loadVulns(source, verify)
+	if err == nil {
+		report.Vulnerabilities, err = matchVulns(vulns, current)
+	}
+	switch {
+	case err != nil:
+		report.Status, report.Error = "unknown", err.Error()
+	case len(report.Vulnerabilities) > 0:
+		report.Status = "vulnerable"
+	default:
+		report.Status = "clean"
+	}
+	return report
+}
+
+// loadVulns retrieves the vulnerabilities published at the given source.
+func loadVulns(source string, verify bool) ([]vulnJson, error) {
+	data, err := fetch(source)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve data: %w", err)
+	}
+	if verify {
+		sig, err := fetch(fmt.Sprintf("%v.minisig", source))
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve signature: %w", err)
+		}
+		if err = verifySignature(gongPubKeys, data, sig); err != nil {
+			return nil, err
+		}
 	}
 	var vulns []vulnJson
 	if err = json.Unmarshal(data, &vulns); err != nil {
-		return err
+		return nil, err
 	}
-	allOk := true
+	return vulns, nil
+}
+
+// matchVulns returns the vulnerabilities affecting the given version.
+func matchVulns(vulns []vulnJson, current string) ([]vulnJson, error) {
+	matched := []vulnJson{}
 	for _, vuln := range vulns {
 		r, err := regexp.Compile(vuln.Check)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if r.MatchString(current) {
-			allOk = false
-			fmt.Printf("## Vulnerable to %v (%v)\n\n", vuln.Uid, vuln.Name)
-			fmt.Printf("Severity: %v\n", vuln.Severity)
-			fmt.Printf("Summary : %v\n", vuln.Summary)
-			fmt.Printf("Fixed in: %v\n", vuln.Fixed)
-			if len(vuln.CVE) > 0 {
-				fmt.Printf("CVE: %v\n", vuln.CVE)
-			}
-			if len(vuln.Links) > 0 {
-				fmt.Printf("References:\n")
-				for _, ref := range vuln.Links {
-					fmt.Printf("\t- %v\n", ref)
-				}
+			matched = append(matched, vuln)
+		}
+	}
+	return matched, nil
+}
+
+// printReport prints the outcome of a version check for humans.
+func printReport(report *versionCheckReport) {
+	if report.Status == "unknown" {
+		fmt.Printf("Could not check vulnerabilities: %v\n", report.Error)
+		return
+	}
+	for _, vuln := range report.Vulnerabilities {
+		fmt.Printf("## Vulnerable to %v (%v)\n\n", vuln.Uid, vuln.Name)
+		fmt.Printf("Severity: %v\n", vuln.Severity)
+		fmt.Printf("Summary : %v\n", vuln.Summary)
+		fmt.Printf("Fixed in: %v\n", vuln.Fixed)
+		if len(vuln.CVE) > 0 {
+			fmt.Printf("CVE: %v\n", vuln.CVE)
+		}
+		if len(vuln.Links) > 0 {
+			fmt.Printf("References:\n")
+			for _, ref := range vuln.Links {
+				fmt.Printf("\t- %v\n", ref)
 			}
-			fmt.Println()
 		}
+		fmt.Println()
 	}
-	if allOk {
+	if report.Status == "clean" {
 		fmt.Println("No vulnerabilities found")
 	}
-	return nil
 }
 
 // fetch makes an HTTP request to the given url and returns the response body
@@ -128,3 +128,30 @@ func TestMatching(t *testing.T) {
 		}
 	}
 }
+
+// Tests that local vulnerability files are checked offline, telling vulnerable,
+// clean and unknown outcomes apart.
+func TestCheckOffline(t *testing.T) {
+	source := "file://./testdata/vcheck/vulnerabilities.json"
+	tests := []struct {
+		version string
+		verify  bool
+		status  string
+	}{
+		{"Gong/v1.9.24-stable/linux-amd64/go1.15.4", false, "vulnerable"},
+		{"Gong/v1.10.0-stable/linux-amd64/go1.16", false, "clean"},
+		{"Gong/v1.10.0-stable/linux-amd64/go1.16", true, "unknown"},
+	}
+	for i, tt := range tests {
+		report := checkCurrent(source, tt.version, tt.verify)
+		if report.Status != tt.status {
+			t.Errorf("test %d: status mismatch: have %s, want %s (%s)", i, report.Status, tt.status, report.Error)
+		}
+		if (report.Status == "vulnerable") != (len(report.Vulnerabilities) > 0) {
+			t.Errorf("test %d: vulnerabilities mismatch: %v", i, report.Vulnerabilities)
+		}
+	}
+	if report := checkCurrent("file://./testdata/vcheck/missing.json", "Gong/v1.10.0-stable", false); report.Status != "unknown" {
+		t.Errorf("missing file status mismatch: have %s, want unknown", report.Status)
+	}
+}
@@ -81,8 +81,12 @@ func StartNode(ctx *cli.Context, stack *node.Node) {
 		} else if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 			minFreeDiskSpace = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 		}
-		if minFreeDiskSpace > 0 {
-			go monitorFreeDiskSpace(sigc, stack.InstanceDir(), uint64(minFreeDiskSpace)*1024*1024)
+		warnFreeDiskSpace := 2 * minFreeDiskSpace
+		if ctx.GlobalIsSet(WarnFreeDiskSpaceFlag.Name) {
+			warnFreeDiskSpace = ctx.GlobalInt(WarnFreeDiskSpaceFlag.Name)
+		}
+		if minFreeDiskSpace > 0 || warnFreeDiskSpace > 0 {
+			go monitorFreeDiskSpace(sigc, stack.InstanceDir(), uint64(minFreeDiskSpace)*1024*1024, uint64(warnFreeDiskSpace)*1024*1024)
 		}
 
 		<-sigc
@@ -97,9 +101,23 @@ func StartNode(ctx *cli.Context, stack *node.Node) {
 		debug.Exit() // ensure trace and CPU profile data is flushed.
 		debug.LoudPanic("boom")
 	}()
+	// Reload the config on SIGHUP if supported, keeping the default action of
+	// the signal (terminate) otherwise
+	if stack.CanReloadConfig() {
+		go func() {
+			hupc := make(chan os.Signal, 1)
+			signal.Notify(hupc, syscall.SIGHUP)
+			defer signal.Stop(hupc)
+
+			for range hupc {
+				log.Info("Got SIGHUP, reloading config...")
+				stack.ReloadConfig()
+			}
+		}()
+	}
 }
 
-func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritical uint64) {
+func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritical uint64, freeDiskSpaceWarning uint64) {
 	for {
 		freeSpace, err := getFreeDiskSpace(path)
 		if err != nil {
@@ -110,8 +128,8 @@ func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritica
 			log.Error("Low disk space. Gracefully shutting down Gong to prevent database corruption.", "available", common.StorageSize(freeSpace))
 			sigc <- syscall.SIGTERM
 			break
-		} else if freeSpace < 2*freeDiskSpaceCritical {
-			log.Warn("Disk space is running low. Gong will shutdown if disk space runs below critical level.", "available", common.StorageSize(freeSpace), "critical_level", common.StorageSize(freeDiskSpaceCritical))
+		} else if freeSpace < freeDiskSpaceWarning {
+			log.Warn("Disk space is running low. Gong will shutdown if disk space runs below critical level.", "available", common.StorageSize(freeSpace), "warning_level", common.StorageSize(freeDiskSpaceWarning), "critical_level", common.StorageSize(freeDiskSpaceCritical))
 		}
 		time.Sleep(60 * time.Second)
 	}
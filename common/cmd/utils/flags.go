@@ -19,9 +19,11 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"os"
 	"path/filepath"
@@ -34,6 +36,7 @@ import (
 	"github.com/ong2020/go-orange/accounts"
 	"github.com/ong2020/go-orange/accounts/keystore"
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
 	"github.com/ong2020/go-orange/common/fdlimit"
 	"github.com/ong2020/go-orange/consensus"
 	"github.com/ong2020/go-orange/consensus/clique"
@@ -42,6 +45,7 @@ import (
 	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/core/vm"
 	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/exporter"
 	"github.com/ong2020/go-orange/ong"
 	"github.com/ong2020/go-orange/ong/downloader"
 	"github.com/ong2020/go-orange/ong/ongconfig"
@@ -50,6 +54,7 @@ import (
 	"github.com/ong2020/go-orange/ongdb"
 	"github.com/ong2020/go-orange/ongstats"
 	"github.com/ong2020/go-orange/graphql"
+	"github.com/ong2020/go-orange/health"
 	"github.com/ong2020/go-orange/internal/ongapi"
 	"github.com/ong2020/go-orange/internal/flags"
 	"github.com/ong2020/go-orange/les"
@@ -64,6 +69,9 @@ import (
 	"github.com/ong2020/go-orange/p2p/nat"
 	"github.com/ong2020/go-orange/p2p/netutil"
 	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/relay"
+	"github.com/ong2020/go-orange/syscontract"
+	"github.com/ong2020/go-orange/rpc"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -114,10 +122,26 @@ var (
 		Name:  "datadir.ancient",
 		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
 	}
+	AncientCompressionFlag = cli.StringFlag{
+		Name:  "datadir.ancient.compression",
+		Usage: "Comma separated compression overrides of the new ancient tables (<table>=none|snappy|zstd, e.g. receipts=none)",
+	}
 	MinFreeDiskSpaceFlag = DirectoryFlag{
 		Name:  "datadir.minfreedisk",
 		Usage: "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
 	}
+	WarnFreeDiskSpaceFlag = cli.IntFlag{
+		Name:  "datadir.warnfreedisk",
+		Usage: "Free disk space in MB below which periodic low disk space warnings are emitted (default = twice --datadir.minfreedisk)",
+	}
+	DataDirNamespaceFlag = cli.StringFlag{
+		Name:  "datadir.namespace",
+		Usage: "Subdirectory of the datadir to store the data of the network in (default = genesis hash prefix)",
+	}
+	ForceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Open a data directory created for a different genesis or network ID",
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -212,6 +236,23 @@ var (
 		Name:  "snapshot",
 		Usage: `Enables snapshot-database mode (default = enable)`,
 	}
+	SnapshotThrottleFlag = cli.IntFlag{
+		Name:  "snapshot.throttle",
+		Usage: "Maximum disk write rate (MB/s) of the background snapshot generation (0 = unlimited)",
+	}
+	SnapServeRequestsFlag = cli.IntFlag{
+		Name:  "snapshot.serve.requests",
+		Usage: "Maximum number of snap requests of remote peers served at once (0 = unlimited)",
+	}
+	SnapServePeerRequestsFlag = cli.IntFlag{
+		Name:  "snapshot.serve.peerrequests",
+		Usage: "Maximum number of snap requests of a single remote peer served at once",
+		Value: 1,
+	}
+	SnapServeBandwidthFlag = cli.IntFlag{
+		Name:  "snapshot.serve.bandwidth",
+		Usage: "Maximum rate (KB/s) of the snap responses served to remote peers (0 = unlimited)",
+	}
 	TxLookupLimitFlag = cli.Uint64Flag{
 		Name:  "txlookuplimit",
 		Usage: "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
@@ -277,6 +318,11 @@ var (
 		Name:  "light.nosyncserve",
 		Usage: "Enables serving light clients before syncing",
 	}
+	LightMaxProofBloatFlag = cli.Float64Flag{
+		Name:  "light.maxproofbloat",
+		Usage: "Maximum size of the proofs accepted from light servers, as a multiple of the nodes they need",
+		Value: ongconfig.Defaults.LightMaxProofBloat,
+	}
 	// Ongash settings
 	OngashCacheDirFlag = DirectoryFlag{
 		Name:  "ongash.cachedir",
@@ -405,6 +451,11 @@ var (
 		Usage: "Percentage of cache memory allowance to use for snapshot caching (default = 10% full mode, 20% archive mode)",
 		Value: 10,
 	}
+	CacheSnapshotRejournalFlag = cli.DurationFlag{
+		Name:  "cache.snapshot.rejournal",
+		Usage: "Time interval to checkpoint the snapshot diff layers to disk (0 = on shutdown only)",
+		Value: ongconfig.Defaults.SnapshotRejournal,
+	}
 	CacheNoPrefetchFlag = cli.BoolFlag{
 		Name:  "cache.noprefetch",
 		Usage: "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
@@ -460,6 +511,24 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerMaxTxsFlag = cli.IntFlag{
+		Name:  "miner.maxtxs",
+		Usage: "Maximum number of transactions included in a mined block (0 = unlimited)",
+		Value: ongconfig.Defaults.Miner.MaxTxs,
+	}
+	MinerFillDeadlineFlag = cli.DurationFlag{
+		Name:  "miner.filldeadline",
+		Usage: "Maximum time spent filling a mined block with transactions (0 = unlimited)",
+		Value: ongconfig.Defaults.Miner.FillDeadline,
+	}
+	MinerDeterministicFlag = cli.BoolFlag{
+		Name:  "miner.deterministic",
+		Usage: "Build mined blocks deterministically from the pool contents, seed and parent timestamp (disables recommits and fill deadline)",
+	}
+	MinerSeedFlag = cli.Uint64Flag{
+		Name:  "miner.seed",
+		Usage: "Seed ordering the same priced transactions in deterministic mode",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -489,15 +558,61 @@ var (
 		Usage: "Sets a cap on gas that can be used in ong_call/estimateGas (0=infinite)",
 		Value: ongconfig.Defaults.RPCGasCap,
 	}
+	RPCEVMTimeoutFlag = cli.DurationFlag{
+		Name:  "rpc.evmtimeout",
+		Usage: "Sets a timeout used for ong_call and ong_estimateGas (0=infinite)",
+		Value: ongconfig.Defaults.RPCEVMTimeout,
+	}
+	RPCBlockRangeCapFlag = cli.Uint64Flag{
+		Name:  "rpc.blockrangecap",
+		Usage: "Sets a cap on the number of blocks spanned by ong_getLogs, ong_feeHistory and debug_traceChain (0=no cap)",
+		Value: ongconfig.Defaults.RPCBlockRangeCap,
+	}
+	RPCPersistentFiltersFlag = cli.BoolFlag{
+		Name:  "rpc.persistentfilters",
+		Usage: "Persist the filters installed by ong_newFilter and co. across restarts (full nodes only)",
+	}
+	RPCSafeDepthFlag = cli.Uint64Flag{
+		Name:  "rpc.safedepth",
+		Usage: "Number of confirmations below the head at which the \"safe\" block tag resolves",
+		Value: ongconfig.Defaults.RPCSafeDepth,
+	}
+	RPCFinalizedDepthFlag = cli.Uint64Flag{
+		Name:  "rpc.finalizeddepth",
+		Usage: "Number of confirmations below the head at which the \"finalized\" block tag resolves",
+		Value: ongconfig.Defaults.RPCFinalizedDepth,
+	}
+	RPCVerifySnapshotFlag = cli.BoolFlag{
+		Name:  "rpc.verifysnapshot",
+		Usage: "Cross-checks the state read from the snapshot by RPC calls against the trie (debugging, slow)",
+	}
+	RPCTraceTimeoutFlag = cli.DurationFlag{
+		Name:  "rpc.tracetimeout",
+		Usage: "Sets a cap on the execution time of a traced transaction (0=no cap)",
+		Value: ongconfig.Defaults.RPCTraceTimeout,
+	}
+	RPCEVMBudgetFlag = cli.DurationFlag{
+		Name:  "rpc.evmbudget",
+		Usage: "Sets the EVM execution time each remote origin may use per minute for calls and traces (0=infinite)",
+		Value: ongconfig.Defaults.RPCEVMBudget,
+	}
 	RPCGlobalTxFeeCapFlag = cli.Float64Flag{
 		Name:  "rpc.txfeecap",
 		Usage: "Sets a cap on transaction fee (in onger) that can be sent via the RPC APIs (0 = no cap)",
 		Value: ongconfig.Defaults.RPCTxFeeCap,
 	}
+	RPCTxSpendCapFlag = cli.Float64Flag{
+		Name:  "rpc.txspendcap",
+		Usage: "Sets a cap on the value plus fee (in onger) of a transaction signed by the node via the RPC APIs (0 = no cap)",
+	}
+	RPCDailySpendCapFlag = cli.Float64Flag{
+		Name:  "rpc.dailyspendcap",
+		Usage: "Sets a cap on the value plus fee (in onger) of the transactions signed and sent by the node per account within 24 hours (0 = no cap)",
+	}
 	// Logging and debug settings
 	OngstatsURLFlag = cli.StringFlag{
 		Name:  "ongstats",
-		Usage: "Reporting URL of a ongstats service (nodename:secret@host:port)",
+		Usage: "Reporting URL of a ongstats service (nodename:secret@host:port), use commas to report to several services and pipes to list failover servers",
 	}
 	FakePoWFlag = cli.BoolFlag{
 		Name:  "fakepow",
@@ -516,6 +631,18 @@ var (
 		Name:  "ipcpath",
 		Usage: "Filename for IPC socket/pipe within the datadir (explicit paths escape it)",
 	}
+	IPCModeFlag = cli.StringFlag{
+		Name:  "ipcmode",
+		Usage: "File mode of the IPC socket, in octal (default = 0600)",
+	}
+	IPCGroupFlag = cli.StringFlag{
+		Name:  "ipcgroup",
+		Usage: "Group owning the IPC socket, by name or id",
+	}
+	IPCSELinuxLabelFlag = cli.StringFlag{
+		Name:  "ipcselinux",
+		Usage: "SELinux security context of the IPC socket (Linux only)",
+	}
 	HTTPEnabledFlag = cli.BoolFlag{
 		Name:  "http",
 		Usage: "Enable the HTTP-RPC server",
@@ -545,11 +672,20 @@ var (
 		Usage: "API's offered over the HTTP-RPC interface",
 		Value: "",
 	}
+	HTTPMethodsFlag = cli.StringFlag{
+		Name:  "http.methods",
+		Usage: "Comma separated rules allowing (e.g. 'ong') or denying (e.g. '!ong_sendRawTransaction') the methods offered over the HTTP-RPC interface, the last matching rule applies",
+		Value: "",
+	}
 	HTTPPathPrefixFlag = cli.StringFlag{
 		Name:  "http.rpcprefix",
 		Usage: "HTTP path path prefix on which JSON-RPC is served. Use '/' to serve on all paths.",
 		Value: "",
 	}
+	HTTPGRPCFlag = cli.BoolFlag{
+		Name:  "http.grpc",
+		Usage: "Serve the HTTP-RPC APIs over gRPC (cleartext HTTP/2) on the HTTP endpoint too",
+	}
 	GraphQLEnabledFlag = cli.BoolFlag{
 		Name:  "graphql",
 		Usage: "Enable GraphQL on the HTTP-RPC server. Note that GraphQL can only be started if an HTTP server is started as well.",
@@ -564,6 +700,66 @@ var (
 		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
 		Value: strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
 	}
+	HealthEnabledFlag = cli.BoolFlag{
+		Name:  "health",
+		Usage: "Enable the /health and /ready endpoints on the HTTP-RPC server",
+	}
+	HealthMinPeersFlag = cli.IntFlag{
+		Name:  "health.minpeers",
+		Usage: "Minimum number of peers for the node to report ready",
+		Value: ongconfig.Defaults.Health.MinPeers,
+	}
+	HealthMaxHeadAgeFlag = cli.DurationFlag{
+		Name:  "health.maxheadage",
+		Usage: "Maximum age of the head block for the node to report ready (0 = unchecked)",
+		Value: ongconfig.Defaults.Health.MaxHeadAge,
+	}
+	RelayEnabledFlag = cli.BoolFlag{
+		Name:  "relay",
+		Usage: "Enable the sponsored transaction relay (relay namespace)",
+	}
+	RelayRelayerFlag = cli.StringFlag{
+		Name:  "relay.relayer",
+		Usage: "Unlocked account signing and paying for the relayed transactions",
+	}
+	RelayMaxGasFlag = cli.Uint64Flag{
+		Name:  "relay.maxgas",
+		Usage: "Maximum gas of a relayed transaction",
+		Value: ongconfig.Defaults.Relay.MaxGas,
+	}
+	RelaySenderLimitFlag = BigFlag{
+		Name:  "relay.senderlimit",
+		Usage: "Maximum cost (wei) relayed per sender and day (unlimited if unset)",
+	}
+	RelayDailyLimitFlag = BigFlag{
+		Name:  "relay.dailylimit",
+		Usage: "Maximum cost (wei) relayed per day (unlimited if unset)",
+	}
+	RelayTargetsFlag = cli.StringFlag{
+		Name:  "relay.targets",
+		Usage: "Comma separated list of contracts transactions may be relayed to (any if unset)",
+	}
+	RelayReimbursementTopicFlag = cli.StringFlag{
+		Name:  "relay.reimbursementtopic",
+		Usage: "Topic of the events of the targets reimbursing the relayer, the amount being the first word of their data",
+	}
+	SystemContractsFlag = cli.StringFlag{
+		Name:  "system.contracts",
+		Usage: "Comma separated system contracts served by the system namespace (name:address:abi-file)",
+	}
+	ExporterSinksFlag = cli.StringFlag{
+		Name:  "exporter.sinks",
+		Usage: "Comma separated endpoints the chain is exported to (nats://host:port/subject, to a JetStream stream; kafka://broker:port/topic; kafka+http(s)://rest-proxy/topic, through a Kafka REST proxy)",
+	}
+	ExporterFormatFlag = cli.StringFlag{
+		Name:  "exporter.format",
+		Usage: "Encoding of the exported chain events (json, protobuf)",
+		Value: ongconfig.Defaults.Exporter.Format,
+	}
+	ExporterFromFlag = cli.Uint64Flag{
+		Name:  "exporter.from",
+		Usage: "First block exported to new sinks (default = head at startup)",
+	}
 	WSEnabledFlag = cli.BoolFlag{
 		Name:  "ws",
 		Usage: "Enable the WS-RPC server",
@@ -583,6 +779,11 @@ var (
 		Usage: "API's offered over the WS-RPC interface",
 		Value: "",
 	}
+	WSMethodsFlag = cli.StringFlag{
+		Name:  "ws.methods",
+		Usage: "Comma separated rules allowing (e.g. 'ong') or denying (e.g. '!ong_sendRawTransaction') the methods offered over the WS-RPC interface, the last matching rule applies",
+		Value: "",
+	}
 	WSAllowedOriginsFlag = cli.StringFlag{
 		Name:  "ws.origins",
 		Usage: "Origins from which to accept websockets requests",
@@ -593,6 +794,22 @@ var (
 		Usage: "HTTP path prefix on which JSON-RPC is served. Use '/' to serve on all paths.",
 		Value: "",
 	}
+	WSCompressionFlag = cli.BoolFlag{
+		Name:  "ws.compression",
+		Usage: "Enable permessage-deflate compression of the WS-RPC messages",
+	}
+	WSReadLimitFlag = cli.Int64Flag{
+		Name:  "ws.readlimit",
+		Usage: "Maximum size in bytes of the messages received by the WS-RPC server (0 = 15MB)",
+	}
+	WSPingIntervalFlag = cli.DurationFlag{
+		Name:  "ws.pinginterval",
+		Usage: "Time without traffic from a client after which the WS-RPC server pings it (0 = 60s)",
+	}
+	WSPongTimeoutFlag = cli.DurationFlag{
+		Name:  "ws.pongtimeout",
+		Usage: "Time the WS-RPC clients have to answer a ping before being disconnected as dead (0 = 30s, negative = never)",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -601,10 +818,77 @@ var (
 		Name:  "preload",
 		Usage: "Comma separated list of JavaScript files to preload into the console",
 	}
+	ConsoleRecordFlag = cli.StringFlag{
+		Name:  "record",
+		Usage: "Append an annotated transcript of the console session (inputs, outputs and timestamps) to the given file",
+	}
 	AllowUnprotectedTxs = cli.BoolFlag{
 		Name:  "rpc.allow-unprotected-txs",
 		Usage: "Allow for unprotected (non EIP155 signed) transactions to be submitted via RPC",
 	}
+	BatchRequestLimitFlag = cli.IntFlag{
+		Name:  "rpc.batch-request-limit",
+		Usage: "Maximum number of requests in a batch (0 = unlimited)",
+		Value: node.DefaultConfig.BatchRequestLimit,
+	}
+	BatchResponseMaxSizeFlag = cli.IntFlag{
+		Name:  "rpc.batch-response-max-size",
+		Usage: "Maximum number of bytes returned from a batched call (0 = unlimited)",
+		Value: node.DefaultConfig.BatchResponseMaxSize,
+	}
+	RPCTimeoutsFlag = cli.StringFlag{
+		Name:  "rpc.timeouts",
+		Usage: "Comma separated list of execution timeouts of HTTP and WS methods or namespaces (e.g. debug=1m,ong_call=10s)",
+		Value: "",
+	}
+	RPCRateLimitsFlag = cli.StringFlag{
+		Name:  "rpc.ratelimits",
+		Usage: "Comma separated list of per-address call rates of HTTP and WS methods or namespaces, '*' for all others (e.g. *=50,debug=1:5 as calls/s[:burst])",
+		Value: "",
+	}
+	RPCNotifyBatchFlag = cli.DurationFlag{
+		Name:  "rpc.notifybatch",
+		Usage: "Window within which the subscription notifications sent over WS and IPC are coalesced into batches (0 = disabled)",
+	}
+	RPCAuthNamespacesFlag = cli.StringFlag{
+		Name:  "rpc.auth.namespaces",
+		Usage: "Comma separated list of API namespaces only available to authenticated HTTP and WS clients",
+		Value: "",
+	}
+	RPCAuthJWTSecretFlag = cli.StringFlag{
+		Name:  "rpc.auth.jwtsecret",
+		Usage: "Path to a hex encoded secret authenticating HS256 JSON web tokens on HTTP and WS",
+		Value: "",
+	}
+	RPCAuthAPIKeysFlag = cli.StringFlag{
+		Name:  "rpc.auth.apikeys",
+		Usage: "Path to a file listing the API keys (with optional holder names) accepted on HTTP and WS",
+		Value: "",
+	}
+	RPCTLSCertFlag = cli.StringFlag{
+		Name:  "rpc.tls.cert",
+		Usage: "Path to the PEM certificate serving HTTP and WS over TLS (requires --rpc.tls.key)",
+		Value: "",
+	}
+	RPCTLSKeyFlag = cli.StringFlag{
+		Name:  "rpc.tls.key",
+		Usage: "Path to the PEM private key of the HTTP and WS TLS certificate",
+		Value: "",
+	}
+	RPCTLSClientCAFlag = cli.StringFlag{
+		Name:  "rpc.tls.clientca",
+		Usage: "Path to the PEM authorities verifying TLS client certificates, which authenticate HTTP and WS clients",
+		Value: "",
+	}
+	RPCTLSRequireClientCertFlag = cli.BoolFlag{
+		Name:  "rpc.tls.requireclientcert",
+		Usage: "Reject the HTTP and WS clients without a valid TLS client certificate",
+	}
+	RPCAdvertiseFlag = cli.StringFlag{
+		Name:  "rpc.advertise",
+		Usage: "Comma separated list of public RPC endpoint URLs (https/wss) to advertise in the node record",
+		Value: "",
+	}
 
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
@@ -648,10 +932,18 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	BootnodeOnlyFlag = cli.BoolFlag{
+		Name:  "bootnode-only",
+		Usage: "Runs only the peer discovery protocols (v4 and v5) as a bootstrap node, without any chain",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	ForensicsFlag = cli.BoolFlag{
+		Name:  "forensics",
+		Usage: "Store the offending messages of peers dropped for protocol violations in the data directory",
+	}
 	DNSDiscoveryFlag = cli.StringFlag{
 		Name:  "discovery.dns",
 		Usage: "Sets DNS discovery entry points (use \"\" to disable DNS)",
@@ -936,6 +1228,9 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(HTTPApiFlag.Name) {
 		cfg.HTTPModules = SplitAndTrim(ctx.GlobalString(HTTPApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(HTTPMethodsFlag.Name) {
+		cfg.HTTPMethodRules = SplitAndTrim(ctx.GlobalString(HTTPMethodsFlag.Name))
+	}
 
 	if ctx.GlobalIsSet(LegacyRPCVirtualHostsFlag.Name) {
 		cfg.HTTPVirtualHosts = SplitAndTrim(ctx.GlobalString(LegacyRPCVirtualHostsFlag.Name))
@@ -948,9 +1243,80 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(HTTPPathPrefixFlag.Name) {
 		cfg.HTTPPathPrefix = ctx.GlobalString(HTTPPathPrefixFlag.Name)
 	}
+	if ctx.GlobalIsSet(HTTPGRPCFlag.Name) {
+		cfg.HTTPGRPC = ctx.GlobalBool(HTTPGRPCFlag.Name)
+	}
 	if ctx.GlobalIsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.GlobalBool(AllowUnprotectedTxs.Name)
 	}
+	if ctx.GlobalIsSet(BatchRequestLimitFlag.Name) {
+		cfg.BatchRequestLimit = ctx.GlobalInt(BatchRequestLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(BatchResponseMaxSizeFlag.Name) {
+		cfg.BatchResponseMaxSize = ctx.GlobalInt(BatchResponseMaxSizeFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTimeoutsFlag.Name) {
+		cfg.RPCTimeouts = make(map[string]time.Duration)
+		for _, entry := range SplitAndTrim(ctx.GlobalString(RPCTimeoutsFlag.Name)) {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				Fatalf("Invalid RPC timeout %q, expected name=duration", entry)
+			}
+			timeout, err := time.ParseDuration(parts[1])
+			if err != nil {
+				Fatalf("Invalid RPC timeout %q: %v", entry, err)
+			}
+			cfg.RPCTimeouts[parts[0]] = timeout
+		}
+	}
+	if ctx.GlobalIsSet(RPCRateLimitsFlag.Name) {
+		cfg.RPCRateLimits = make(map[string]rpc.RateLimit)
+		for _, entry := range SplitAndTrim(ctx.GlobalString(RPCRateLimitsFlag.Name)) {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				Fatalf("Invalid RPC rate limit %q, expected name=rate[:burst]", entry)
+			}
+			var (
+				limit rpc.RateLimit
+				err   error
+			)
+			spec := strings.SplitN(parts[1], ":", 2)
+			if limit.Rate, err = strconv.ParseFloat(spec[0], 64); err != nil || limit.Rate <= 0 {
+				Fatalf("Invalid RPC rate limit %q: bad rate", entry)
+			}
+			limit.Burst = int(math.Ceil(limit.Rate))
+			if len(spec) == 2 {
+				if limit.Burst, err = strconv.Atoi(spec[1]); err != nil || limit.Burst < 1 {
+					Fatalf("Invalid RPC rate limit %q: bad burst", entry)
+				}
+			}
+			cfg.RPCRateLimits[parts[0]] = limit
+		}
+	}
+	if ctx.GlobalIsSet(RPCNotifyBatchFlag.Name) {
+		cfg.RPCNotifyBatchWindow = ctx.GlobalDuration(RPCNotifyBatchFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCAuthNamespacesFlag.Name) {
+		cfg.RPCAuthNamespaces = SplitAndTrim(ctx.GlobalString(RPCAuthNamespacesFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCAuthJWTSecretFlag.Name) {
+		cfg.RPCAuthJWTSecret = ctx.GlobalString(RPCAuthJWTSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCAuthAPIKeysFlag.Name) {
+		cfg.RPCAuthAPIKeys = ctx.GlobalString(RPCAuthAPIKeysFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTLSCertFlag.Name) {
+		cfg.RPCTLSCert = ctx.GlobalString(RPCTLSCertFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTLSKeyFlag.Name) {
+		cfg.RPCTLSKey = ctx.GlobalString(RPCTLSKeyFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTLSClientCAFlag.Name) {
+		cfg.RPCTLSClientCA = ctx.GlobalString(RPCTLSClientCAFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTLSRequireClientCertFlag.Name) {
+		cfg.RPCTLSRequireClientCert = ctx.GlobalBool(RPCTLSRequireClientCertFlag.Name)
+	}
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -984,10 +1350,34 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(WSApiFlag.Name) {
 		cfg.WSModules = SplitAndTrim(ctx.GlobalString(WSApiFlag.Name))
 	}
+	if ctx.GlobalIsSet(WSMethodsFlag.Name) {
+		cfg.WSMethodRules = SplitAndTrim(ctx.GlobalString(WSMethodsFlag.Name))
+	}
 
 	if ctx.GlobalIsSet(WSPathPrefixFlag.Name) {
 		cfg.WSPathPrefix = ctx.GlobalString(WSPathPrefixFlag.Name)
 	}
+
+	if ctx.GlobalIsSet(WSCompressionFlag.Name) {
+		cfg.WSCompression = ctx.GlobalBool(WSCompressionFlag.Name)
+	}
+	if ctx.GlobalIsSet(WSReadLimitFlag.Name) {
+		cfg.WSReadLimit = ctx.GlobalInt64(WSReadLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(WSPingIntervalFlag.Name) {
+		cfg.WSPingInterval = ctx.GlobalDuration(WSPingIntervalFlag.Name)
+	}
+	if ctx.GlobalIsSet(WSPongTimeoutFlag.Name) {
+		cfg.WSPongTimeout = ctx.GlobalDuration(WSPongTimeoutFlag.Name)
+	}
+}
+
+// setRPCAdvertise creates the list of public RPC endpoints to advertise in the
+// node record from the set command line flags.
+func setRPCAdvertise(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCAdvertiseFlag.Name) {
+		cfg.RPCAdvertise = SplitAndTrim(ctx.GlobalString(RPCAdvertiseFlag.Name))
+	}
 }
 
 // setIPC creates an IPC path configuration from the set command line flags,
@@ -1000,6 +1390,19 @@ func setIPC(ctx *cli.Context, cfg *node.Config) {
 	case ctx.GlobalIsSet(IPCPathFlag.Name):
 		cfg.IPCPath = ctx.GlobalString(IPCPathFlag.Name)
 	}
+	if ctx.GlobalIsSet(IPCModeFlag.Name) {
+		mode, err := strconv.ParseUint(ctx.GlobalString(IPCModeFlag.Name), 8, 32)
+		if err != nil || mode > 0777 {
+			Fatalf("Invalid IPC socket mode %q, want octal permissions like 0660", ctx.GlobalString(IPCModeFlag.Name))
+		}
+		cfg.IPCMode = os.FileMode(mode)
+	}
+	if ctx.GlobalIsSet(IPCGroupFlag.Name) {
+		cfg.IPCGroup = ctx.GlobalString(IPCGroupFlag.Name)
+	}
+	if ctx.GlobalIsSet(IPCSELinuxLabelFlag.Name) {
+		cfg.IPCSELinuxLabel = ctx.GlobalString(IPCSELinuxLabelFlag.Name)
+	}
 }
 
 // setLes configures the les server and ultra light client settings from the command line flags.
@@ -1035,6 +1438,13 @@ func setLes(ctx *cli.Context, cfg *ongconfig.Config) {
 	if ctx.GlobalIsSet(LightNoSyncServeFlag.Name) {
 		cfg.LightNoSyncServe = ctx.GlobalBool(LightNoSyncServeFlag.Name)
 	}
+	if ctx.GlobalIsSet(LightMaxProofBloatFlag.Name) {
+		cfg.LightMaxProofBloat = ctx.GlobalFloat64(LightMaxProofBloatFlag.Name)
+	}
+	if cfg.LightMaxProofBloat < 1 {
+		log.Error("Light proof bloat factor is invalid", "had", cfg.LightMaxProofBloat, "updated", ongconfig.Defaults.LightMaxProofBloat)
+		cfg.LightMaxProofBloat = ongconfig.Defaults.LightMaxProofBloat
+	}
 }
 
 // MakeDatabaseHandles raises out the number of allowed file handles per process
@@ -1170,6 +1580,13 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	} else if forceV5Discovery {
 		cfg.DiscoveryV5 = true
 	}
+	// Bootstrap nodes run both discovery protocols, but never connect to peers
+	if ctx.GlobalBool(BootnodeOnlyFlag.Name) {
+		cfg.MaxPeers = 0
+		cfg.NoDial = true
+		cfg.NoDiscovery = false
+		cfg.DiscoveryV5 = true
+	}
 
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
@@ -1178,6 +1595,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NetRestrict = list
 	}
+	if ctx.GlobalBool(ForensicsFlag.Name) {
+		cfg.ForensicsDir = "forensics" // Resolved within the data directory by the node
+	}
 
 	if ctx.GlobalBool(DeveloperFlag.Name) {
 		// --dev mode can't use p2p networking.
@@ -1195,10 +1615,16 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCAdvertise(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
+	setNamespace(ctx, cfg)
 	setSmartCard(ctx, cfg)
 
+	// The verbosity set on the command line takes precedence over the config file
+	if ctx.GlobalIsSet("verbosity") {
+		cfg.Verbosity = ""
+	}
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
 	}
@@ -1220,6 +1646,95 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// GenesisNamespace returns the data directory namespace of the network with the
+// given genesis hash.
+func GenesisNamespace(hash common.Hash) string {
+	return hex.EncodeToString(hash[:8])
+}
+
+// setNamespace separates the data of the selected network within the data
+// directory, by the hash of its genesis block. Data directories created before
+// the namespacing (holding un-namespaced chain databases) keep their layout.
+//
+// For custom networks, the genesis is only known once the database is open, so
+// the namespace created by `gong init` for the custom genesis is used. It is
+// only picked if unambiguous: with several custom networks in the data directory,
+// or with both mainnet and a custom network but no network ID to tell them apart,
+// the namespace must be selected explicitly. Switching networks within the
+// namespace is guarded by the genesis and network ID recorded in the database.
+func setNamespace(ctx *cli.Context, cfg *node.Config) {
+	if cfg.DataDir == "" || ctx.GlobalBool(DeveloperFlag.Name) {
+		return
+	}
+	for _, name := range []string{"chaindata", "lightchaindata"} {
+		if common.FileExist(cfg.ResolvePath(name)) {
+			log.Debug("Using legacy datadir layout", "datadir", cfg.DataDir)
+			return
+		}
+	}
+	if ctx.GlobalIsSet(DataDirNamespaceFlag.Name) {
+		cfg.Namespace = ctx.GlobalString(DataDirNamespaceFlag.Name)
+	} else if genesis := MakeGenesis(ctx); genesis != nil {
+		cfg.Namespace = GenesisNamespace(genesis.ToBlock(nil).Hash())
+	} else {
+		cfg.Namespace = customNamespace(ctx, cfg.ResolvePath(""))
+	}
+	log.Info("Using datadir namespace", "namespace", cfg.Namespace)
+}
+
+// customNamespace returns the namespace of the network selected without preset
+// network flag: the custom network initialized in the instance directory if any,
+// mainnet otherwise.
+func customNamespace(ctx *cli.Context, instdir string) string {
+	var (
+		mainnet     = GenesisNamespace(core.DefaultGenesisBlock().ToBlock(nil).Hash())
+		namespaces  = existingNamespaces(instdir)
+		custom      []string
+		withMainnet bool
+	)
+	for _, namespace := range namespaces {
+		if namespace == mainnet {
+			withMainnet = true
+		} else {
+			custom = append(custom, namespace)
+		}
+	}
+	networkSet := ctx.GlobalIsSet(NetworkIdFlag.Name)
+	switch {
+	case len(custom) == 0:
+		return mainnet
+	case networkSet && ctx.GlobalUint64(NetworkIdFlag.Name) == ongconfig.Defaults.NetworkId:
+		return mainnet
+	case len(custom) > 1:
+		Fatalf("Multiple networks found in datadir (%s), select one with --%s", strings.Join(namespaces, ", "), DataDirNamespaceFlag.Name)
+	case withMainnet && !networkSet:
+		Fatalf("Both mainnet and a custom network (%s) found in datadir, select one with --%s or --%s", custom[0], NetworkIdFlag.Name, DataDirNamespaceFlag.Name)
+	}
+	return custom[0]
+}
+
+// existingNamespaces returns the namespaces in an instance directory which hold
+// chain databases.
+func existingNamespaces(instdir string) []string {
+	entries, err := ioutil.ReadDir(instdir)
+	if err != nil {
+		return nil
+	}
+	var namespaces []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		for _, name := range []string{"chaindata", "lightchaindata"} {
+			if common.FileExist(filepath.Join(instdir, entry.Name(), name)) {
+				namespaces = append(namespaces, entry.Name())
+				break
+			}
+		}
+	}
+	return namespaces
+}
+
 func setSmartCard(ctx *cli.Context, cfg *node.Config) {
 	// Skip enabling smartcards if no path is set
 	path := ctx.GlobalString(SmartCardDaemonPathFlag.Name)
@@ -1285,6 +1800,91 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config, light bool) {
 	}
 }
 
+func setHealth(ctx *cli.Context, cfg *health.Config) {
+	if ctx.GlobalIsSet(HealthEnabledFlag.Name) {
+		cfg.Enabled = ctx.GlobalBool(HealthEnabledFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMinPeersFlag.Name) {
+		cfg.MinPeers = ctx.GlobalInt(HealthMinPeersFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxHeadAgeFlag.Name) {
+		cfg.MaxHeadAge = ctx.GlobalDuration(HealthMaxHeadAgeFlag.Name)
+	}
+}
+
+func setRelay(ctx *cli.Context, cfg *relay.Config) {
+	if ctx.GlobalIsSet(RelayEnabledFlag.Name) {
+		cfg.Enabled = ctx.GlobalBool(RelayEnabledFlag.Name)
+	}
+	if ctx.GlobalIsSet(RelayRelayerFlag.Name) {
+		relayer := ctx.GlobalString(RelayRelayerFlag.Name)
+		if !common.IsHexAddress(relayer) {
+			Fatalf("Invalid relayer account %q", relayer)
+		}
+		cfg.Relayer = common.HexToAddress(relayer)
+	}
+	if ctx.GlobalIsSet(RelayMaxGasFlag.Name) {
+		cfg.MaxGas = ctx.GlobalUint64(RelayMaxGasFlag.Name)
+	}
+	if ctx.GlobalIsSet(RelaySenderLimitFlag.Name) {
+		cfg.SenderDailyLimit = GlobalBig(ctx, RelaySenderLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RelayDailyLimitFlag.Name) {
+		cfg.DailyLimit = GlobalBig(ctx, RelayDailyLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RelayTargetsFlag.Name) {
+		cfg.Targets = nil
+		for _, target := range SplitAndTrim(ctx.GlobalString(RelayTargetsFlag.Name)) {
+			if !common.IsHexAddress(target) {
+				Fatalf("Invalid relay target %q", target)
+			}
+			cfg.Targets = append(cfg.Targets, common.HexToAddress(target))
+		}
+	}
+	if ctx.GlobalIsSet(RelayReimbursementTopicFlag.Name) {
+		topic, err := hexutil.Decode(ctx.GlobalString(RelayReimbursementTopicFlag.Name))
+		if err != nil || len(topic) != common.HashLength {
+			Fatalf("Invalid relay reimbursement topic %q", ctx.GlobalString(RelayReimbursementTopicFlag.Name))
+		}
+		cfg.ReimbursementTopic = common.BytesToHash(topic)
+	}
+}
+
+func setSystemContracts(ctx *cli.Context, cfg *syscontract.Config) {
+	if ctx.GlobalIsSet(SystemContractsFlag.Name) {
+		cfg.Contracts = nil
+		for _, entry := range SplitAndTrim(ctx.GlobalString(SystemContractsFlag.Name)) {
+			parts := strings.SplitN(entry, ":", 3)
+			if len(parts) != 3 || !common.IsHexAddress(parts[1]) {
+				Fatalf("Invalid system contract %q, want name:address:abi-file", entry)
+			}
+			cfg.Contracts = append(cfg.Contracts, syscontract.Contract{
+				Name:    parts[0],
+				Address: common.HexToAddress(parts[1]),
+				ABI:     parts[2],
+			})
+		}
+	}
+}
+
+func setExporter(ctx *cli.Context, cfg *exporter.Config) {
+	if ctx.GlobalIsSet(ExporterSinksFlag.Name) {
+		cfg.Sinks = SplitAndTrim(ctx.GlobalString(ExporterSinksFlag.Name))
+	}
+	if ctx.GlobalIsSet(ExporterFormatFlag.Name) {
+		cfg.Format = ctx.GlobalString(ExporterFormatFlag.Name)
+	}
+	switch cfg.Format {
+	case exporter.FormatJSON, exporter.FormatProtobuf:
+	default:
+		Fatalf("Invalid exporter format %q, want %s or %s", cfg.Format, exporter.FormatJSON, exporter.FormatProtobuf)
+	}
+	if ctx.GlobalIsSet(ExporterFromFlag.Name) {
+		from := ctx.GlobalUint64(ExporterFromFlag.Name)
+		cfg.FromBlock = &from
+	}
+}
+
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLocalsFlag.Name) {
 		locals := strings.Split(ctx.GlobalString(TxPoolLocalsFlag.Name), ",")
@@ -1377,6 +1977,18 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.Noverify = ctx.GlobalBool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerMaxTxsFlag.Name) {
+		cfg.MaxTxs = ctx.GlobalInt(MinerMaxTxsFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerFillDeadlineFlag.Name) {
+		cfg.FillDeadline = ctx.GlobalDuration(MinerFillDeadlineFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerDeterministicFlag.Name) {
+		cfg.Deterministic = ctx.GlobalBool(MinerDeterministicFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerSeedFlag.Name) {
+		cfg.Seed = ctx.GlobalUint64(MinerSeedFlag.Name)
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *ongconfig.Config) {
@@ -1462,6 +2074,10 @@ func SetOngConfig(ctx *cli.Context, stack *node.Node, cfg *ongconfig.Config) {
 	}
 	setOrangerbase(ctx, ks, cfg)
 	setGPO(ctx, &cfg.GPO, ctx.GlobalString(SyncModeFlag.Name) == "light")
+	setHealth(ctx, &cfg.Health)
+	setRelay(ctx, &cfg.Relay)
+	setSystemContracts(ctx, &cfg.SystemContracts)
+	setExporter(ctx, &cfg.Exporter)
 	setTxPool(ctx, &cfg.TxPool)
 	setOngash(ctx, cfg)
 	setMiner(ctx, &cfg.Miner)
@@ -1471,6 +2087,9 @@ func SetOngConfig(ctx *cli.Context, stack *node.Node, cfg *ongconfig.Config) {
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.GlobalIsSet(ForceFlag.Name) {
+		cfg.ForceNetwork = ctx.GlobalBool(ForceFlag.Name)
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -1481,6 +2100,9 @@ func SetOngConfig(ctx *cli.Context, stack *node.Node, cfg *ongconfig.Config) {
 	if ctx.GlobalIsSet(AncientFlag.Name) {
 		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
 	}
+	if ctx.GlobalIsSet(AncientCompressionFlag.Name) {
+		cfg.DatabaseFreezerCompression = ancientCompression(ctx)
+	}
 
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1515,6 +2137,21 @@ func SetOngConfig(ctx *cli.Context, stack *node.Node, cfg *ongconfig.Config) {
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheSnapshotFlag.Name) {
 		cfg.SnapshotCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(CacheSnapshotRejournalFlag.Name) {
+		cfg.SnapshotRejournal = ctx.GlobalDuration(CacheSnapshotRejournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapshotThrottleFlag.Name) {
+		cfg.SnapshotThrottle = ctx.GlobalInt(SnapshotThrottleFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapServeRequestsFlag.Name) {
+		cfg.SnapServeRequests = ctx.GlobalInt(SnapServeRequestsFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapServePeerRequestsFlag.Name) {
+		cfg.SnapServePeerRequests = ctx.GlobalInt(SnapServePeerRequestsFlag.Name)
+	}
+	if ctx.GlobalIsSet(SnapServeBandwidthFlag.Name) {
+		cfg.SnapServeBandwidth = ctx.GlobalInt(SnapServeBandwidthFlag.Name)
+	}
 	if !ctx.GlobalBool(SnapshotFlag.Name) {
 		// If snap-sync is requested, this flag is also required
 		if cfg.SyncMode == downloader.SnapSync {
@@ -1547,9 +2184,42 @@ func SetOngConfig(ctx *cli.Context, stack *node.Node, cfg *ongconfig.Config) {
 	} else {
 		log.Info("Global gas cap disabled")
 	}
+	if ctx.GlobalIsSet(RPCEVMTimeoutFlag.Name) {
+		cfg.RPCEVMTimeout = ctx.GlobalDuration(RPCEVMTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCBlockRangeCapFlag.Name) {
+		cfg.RPCBlockRangeCap = ctx.GlobalUint64(RPCBlockRangeCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCPersistentFiltersFlag.Name) {
+		cfg.RPCPersistentFilters = ctx.GlobalBool(RPCPersistentFiltersFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCSafeDepthFlag.Name) {
+		cfg.RPCSafeDepth = ctx.GlobalUint64(RPCSafeDepthFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCFinalizedDepthFlag.Name) {
+		cfg.RPCFinalizedDepth = ctx.GlobalUint64(RPCFinalizedDepthFlag.Name)
+	}
+	if cfg.RPCSafeDepth > cfg.RPCFinalizedDepth {
+		Fatalf("--%s (%d) must not exceed --%s (%d)", RPCSafeDepthFlag.Name, cfg.RPCSafeDepth, RPCFinalizedDepthFlag.Name, cfg.RPCFinalizedDepth)
+	}
+	if ctx.GlobalIsSet(RPCVerifySnapshotFlag.Name) {
+		cfg.RPCVerifySnapshot = ctx.GlobalBool(RPCVerifySnapshotFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTraceTimeoutFlag.Name) {
+		cfg.RPCTraceTimeout = ctx.GlobalDuration(RPCTraceTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCEVMBudgetFlag.Name) {
+		cfg.RPCEVMBudget = ctx.GlobalDuration(RPCEVMBudgetFlag.Name)
+	}
 	if ctx.GlobalIsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.GlobalFloat64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.GlobalIsSet(RPCTxSpendCapFlag.Name) {
+		cfg.RPCTxSpendCap = ctx.GlobalFloat64(RPCTxSpendCapFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCDailySpendCapFlag.Name) {
+		cfg.RPCDailySpendCap = ctx.GlobalFloat64(RPCDailySpendCapFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) {
 		cfg.OngDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
@@ -1672,6 +2342,18 @@ func RegisterOngService(stack *node.Node, cfg *ongconfig.Config) ongapi.Backend
 			Fatalf("Failed to register the Orange service: %v", err)
 		}
 		stack.RegisterAPIs(tracers.APIs(backend.ApiBackend))
+		if cfg.Health.Enabled {
+			RegisterHealthService(stack, backend.ApiBackend, cfg.Health)
+		}
+		if cfg.Relay.Enabled {
+			RegisterRelayService(stack, backend.ApiBackend, cfg.Relay)
+		}
+		if len(cfg.SystemContracts.Contracts) > 0 {
+			RegisterSystemContractService(stack, backend.ApiBackend, cfg.SystemContracts)
+		}
+		if len(cfg.Exporter.Sinks) > 0 {
+			RegisterExporterService(stack, backend.ApiBackend, cfg.Exporter)
+		}
 		return backend.ApiBackend
 	}
 	backend, err := ong.New(stack, cfg)
@@ -1685,6 +2367,18 @@ func RegisterOngService(stack *node.Node, cfg *ongconfig.Config) ongapi.Backend
 		}
 	}
 	stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
+	if cfg.Health.Enabled {
+		RegisterHealthService(stack, backend.APIBackend, cfg.Health)
+	}
+	if cfg.Relay.Enabled {
+		RegisterRelayService(stack, backend.APIBackend, cfg.Relay)
+	}
+	if len(cfg.SystemContracts.Contracts) > 0 {
+		RegisterSystemContractService(stack, backend.APIBackend, cfg.SystemContracts)
+	}
+	if len(cfg.Exporter.Sinks) > 0 {
+		RegisterExporterService(stack, backend.APIBackend, cfg.Exporter)
+	}
 	return backend.APIBackend
 }
 
@@ -1703,6 +2397,35 @@ func RegisterGraphQLService(stack *node.Node, backend ongapi.Backend, cfg node.C
 	}
 }
 
+// RegisterHealthService adds the health and readiness endpoints to the HTTP-RPC
+// server of the given node.
+func RegisterHealthService(stack *node.Node, backend ongapi.Backend, cfg health.Config) {
+	if err := health.New(stack, backend, cfg); err != nil {
+		Fatalf("Failed to register the health service: %v", err)
+	}
+}
+
+// RegisterRelayService adds the sponsored transaction relay to the given node.
+func RegisterRelayService(stack *node.Node, backend ongapi.Backend, cfg relay.Config) {
+	if err := relay.New(stack, backend, cfg); err != nil {
+		Fatalf("Failed to register the transaction relay: %v", err)
+	}
+}
+
+// RegisterSystemContractService adds the system contract API to the given node.
+func RegisterSystemContractService(stack *node.Node, backend ongapi.Backend, cfg syscontract.Config) {
+	if err := syscontract.New(stack, backend, cfg); err != nil {
+		Fatalf("Failed to register the system contract API: %v", err)
+	}
+}
+
+// RegisterExporterService adds the chain exporter to the given node.
+func RegisterExporterService(stack *node.Node, backend ongapi.Backend, cfg exporter.Config) {
+	if err := exporter.New(stack, backend, cfg); err != nil {
+		Fatalf("Failed to register the chain exporter: %v", err)
+	}
+}
+
 func SetupMetrics(ctx *cli.Context) {
 	if metrics.Enabled {
 		log.Info("Enabling metrics collection")
@@ -1748,6 +2471,22 @@ func SplitTagsFlag(tagsFlag string) map[string]string {
 	return tagsMap
 }
 
+// ancientCompression parses the compression overrides of the ancient tables.
+func ancientCompression(ctx *cli.Context) map[string]string {
+	if !ctx.GlobalIsSet(AncientCompressionFlag.Name) {
+		return nil
+	}
+	overrides := make(map[string]string)
+	for _, override := range strings.Split(ctx.GlobalString(AncientCompressionFlag.Name), ",") {
+		parts := strings.SplitN(strings.TrimSpace(override), "=", 2)
+		if len(parts) != 2 {
+			Fatalf("Invalid --%s override %q, want <table>=<compression>", AncientCompressionFlag.Name, override)
+		}
+		overrides[parts[0]] = parts[1]
+	}
+	return overrides
+}
+
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
 func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ongdb.Database {
 	var (
@@ -1762,7 +2501,7 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ongdb.Database {
 		chainDb, err = stack.OpenDatabase(name, cache, handles, "")
 	} else {
 		name := "chaindata"
-		chainDb, err = stack.OpenDatabaseWithFreezer(name, cache, handles, ctx.GlobalString(AncientFlag.Name), "")
+		chainDb, err = stack.OpenDatabaseWithFreezer(name, cache, handles, ctx.GlobalString(AncientFlag.Name), "", ancientCompression(ctx))
 	}
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
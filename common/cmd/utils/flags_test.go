@@ -18,8 +18,17 @@
 package utils
 
 import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"testing"
+
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/node"
+	"gopkg.in/urfave/cli.v1"
 )
 
 func Test_SplitTagsFlag(t *testing.T) {
@@ -62,3 +71,48 @@ func Test_SplitTagsFlag(t *testing.T) {
 		})
 	}
 }
+
+// Tests that the datadir namespace of a custom network initialized in the data
+// directory is picked when no preset network is selected, and that mainnet is
+// only picked if selected or without custom network.
+func TestSetNamespace(t *testing.T) {
+	mainnet := GenesisNamespace(core.DefaultGenesisBlock().ToBlock(nil).Hash())
+
+	tests := []struct {
+		existing  []string
+		networkID uint64
+		want      string
+	}{
+		{nil, 0, mainnet},
+		{nil, 1234, mainnet},
+		{[]string{mainnet}, 0, mainnet},
+		{[]string{"0123456789abcdef"}, 0, "0123456789abcdef"},
+		{[]string{"0123456789abcdef"}, 1234, "0123456789abcdef"},
+		{[]string{"0123456789abcdef", mainnet}, 1234, "0123456789abcdef"},
+		{[]string{"0123456789abcdef", mainnet}, 1, mainnet},
+	}
+	for i, tt := range tests {
+		datadir, err := ioutil.TempDir("", "namespace")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(datadir)
+
+		cfg := &node.Config{Name: "gong", DataDir: datadir}
+		for _, namespace := range tt.existing {
+			if err := os.MkdirAll(filepath.Join(datadir, "gong", namespace, "chaindata"), 0700); err != nil {
+				t.Fatal(err)
+			}
+		}
+		set := flag.NewFlagSet("test", 0)
+		set.String(DataDirNamespaceFlag.Name, "", "")
+		set.Uint64(NetworkIdFlag.Name, 1, "")
+		if tt.networkID != 0 {
+			set.Set(NetworkIdFlag.Name, strconv.FormatUint(tt.networkID, 10))
+		}
+		setNamespace(cli.NewContext(nil, set, nil), cfg)
+		if cfg.Namespace != tt.want {
+			t.Errorf("test %d: namespace mismatch: have %q, want %q", i, cfg.Namespace, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// secretFilePrefix marks a config value to be replaced by the contents of a file.
+const secretFilePrefix = "@file:"
+
+// envVarRegexp matches the environment variable references within a string.
+var envVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandConfigSecrets expands the references to environment variables and secret
+// files in the string values of a decoded config, so that sensitive values (e.g.
+// the ongstats secret or passwords) don't need to be stored in the config file:
+//
+//   - "${VAR}" anywhere in a value is replaced by the environment variable VAR
+//   - "@file:/path" as the whole value is replaced by the contents of the file,
+//     without the trailing newline
+//
+// The config must be passed by pointer. The exported string fields of nested
+// structs, and the strings held by pointers, slices and maps are expanded too.
+// Referencing an undefined environment variable or an unreadable file is an
+// error, rather than silently running with an empty secret.
+func ExpandConfigSecrets(config interface{}) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("config must be a non-nil pointer, have %T", config)
+	}
+	return expandSecrets(v.Elem(), "")
+}
+
+// expandSecrets expands the secret references in the strings held by a value,
+// reporting the failures with the path of the setting.
+func expandSecrets(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		expanded, err := expandSecret(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %v", strings.TrimPrefix(path, "."), err)
+		}
+		if v.CanSet() {
+			v.SetString(expanded)
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return expandSecrets(v.Elem(), path)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Type().Field(i); field.PkgPath == "" {
+				if err := expandSecrets(v.Field(i), path+"."+field.Name); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandSecrets(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			expanded, err := expandSecret(iter.Value().String())
+			if err != nil {
+				return fmt.Errorf("%s[%v]: %v", strings.TrimPrefix(path, "."), iter.Key(), err)
+			}
+			v.SetMapIndex(iter.Key(), reflect.ValueOf(expanded).Convert(v.Type().Elem()))
+		}
+	}
+	return nil
+}
+
+// expandSecret expands the environment variable and secret file references in
+// a single config value.
+func expandSecret(value string) (string, error) {
+	if strings.HasPrefix(value, secretFilePrefix) {
+		path := strings.TrimPrefix(value, secretFilePrefix)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	var err error
+	expanded := envVarRegexp.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envVarRegexp.FindStringSubmatch(ref)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s not defined", name)
+		}
+		return env
+	})
+	return expanded, err
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of go-orange.
+//
+// go-orange is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-orange is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-orange. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/naoina/toml"
+	"github.com/ong2020/go-orange/node"
+)
+
+// secretsTestConfig mimics the layout of the gong config file.
+type secretsTestConfig struct {
+	Node     node.Config
+	Ongstats struct {
+		URL      string
+		Password *string
+		Plain    string
+		Peers    []string
+		Labels   map[string]string
+	}
+}
+
+// Tests that the secret references of a decoded TOML config are expanded.
+func TestExpandConfigSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secret, []byte("s3cr\"t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GONG_TEST_SECRET", "hunter2")
+	defer os.Unsetenv("GONG_TEST_SECRET")
+
+	config := "[Node]\n" +
+		"UserIdent = \"${GONG_TEST_SECRET}\"\n" +
+		"[Ongstats]\n" +
+		"URL = \"node:${GONG_TEST_SECRET}@host:3000\"\n" +
+		"# Password = \"${GONG_UNDEFINED}\"\n" +
+		"Password = \"@file:" + secret + "\"\n" +
+		"Plain = \"$HOME\"\n" +
+		"Peers = [\"${GONG_TEST_SECRET}\"]\n" +
+		"[Ongstats.Labels]\n" +
+		"key = \"${GONG_TEST_SECRET}\"\n"
+
+	var cfg secretsTestConfig
+	if err := toml.Unmarshal([]byte(config), &cfg); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if err := ExpandConfigSecrets(&cfg); err != nil {
+		t.Fatalf("failed to expand config: %v", err)
+	}
+	if cfg.Node.UserIdent != "hunter2" {
+		t.Errorf("user ident mismatch: have %q, want %q", cfg.Node.UserIdent, "hunter2")
+	}
+	if cfg.Ongstats.URL != "node:hunter2@host:3000" {
+		t.Errorf("URL mismatch: have %q", cfg.Ongstats.URL)
+	}
+	if cfg.Ongstats.Password == nil || *cfg.Ongstats.Password != "s3cr\"t" {
+		t.Errorf("password mismatch: have %v", cfg.Ongstats.Password)
+	}
+	if cfg.Ongstats.Plain != "$HOME" {
+		t.Errorf("plain value mismatch: have %q", cfg.Ongstats.Plain)
+	}
+	if !reflect.DeepEqual(cfg.Ongstats.Peers, []string{"hunter2"}) {
+		t.Errorf("list mismatch: have %v", cfg.Ongstats.Peers)
+	}
+	if cfg.Ongstats.Labels["key"] != "hunter2" {
+		t.Errorf("map mismatch: have %v", cfg.Ongstats.Labels)
+	}
+
+	cfg.Ongstats.URL = "${GONG_UNDEFINED}"
+	if err := ExpandConfigSecrets(&cfg); err == nil {
+		t.Error("expected error for undefined environment variable")
+	}
+	cfg.Ongstats.URL = "@file:" + filepath.Join(dir, "missing")
+	if err := ExpandConfigSecrets(&cfg); err == nil {
+		t.Error("expected error for missing secret file")
+	}
+}
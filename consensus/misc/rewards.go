@@ -0,0 +1,235 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus"
+	"github.com/ong2020/go-orange/core/state"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/params"
+)
+
+// Names of the built-in reward policies.
+const (
+	RewardPolicySplit    = "split"    // splits the rewards among the payees proportionally to their weights
+	RewardPolicyContract = "contract" // pays the rewards out to a system contract distributing them
+)
+
+// RewardContractGas is the gas available to the system contract of the contract
+// reward policy for distributing a reward.
+const RewardContractGas uint64 = 1000000
+
+var (
+	// RewardSystemAddress is the caller of the system contract of the contract
+	// reward policy, letting it authenticate the reward notifications.
+	RewardSystemAddress = common.HexToAddress("0xfffffffffffffffffffffffffffffffffffffffe")
+
+	// rewardContractMethod is the selector of the method of the system contract
+	// notified of the rewards, distribute(address beneficiary, uint256 reward).
+	rewardContractMethod = crypto.Keccak256([]byte("distribute(address,uint256)"))[:4]
+)
+
+// RewardPolicy is a payout scheme of the block rewards. It is invoked by the
+// consensus engine when finalizing a block, both when sealing and importing
+// it, so it must be deterministic and depend only on its inputs.
+type RewardPolicy interface {
+	// Validate checks that the policy can be applied with the given configuration.
+	Validate(config *params.RewardPolicyConfig) error
+
+	// Distribute credits the reward of the beneficiary of a block, on top of the
+	// given chain.
+	Distribute(chain consensus.ChainHeaderReader, config *params.RewardPolicyConfig, state *state.StateDB, header *types.Header, reward *big.Int)
+}
+
+var (
+	rewardPolicies = map[string]RewardPolicy{
+		RewardPolicySplit:    splitPolicy{},
+		RewardPolicyContract: contractPolicy{},
+	}
+	rewardPoliciesLock sync.RWMutex
+)
+
+func init() {
+	params.ValidateRewardPolicy = validateRewardPolicy
+}
+
+// RegisterRewardPolicy makes a payout scheme available to chain configurations
+// under the given name. It's meant to be called by the programs embedding the
+// node for their private chains, before the chain is opened.
+func RegisterRewardPolicy(name string, policy RewardPolicy) {
+	rewardPoliciesLock.Lock()
+	defer rewardPoliciesLock.Unlock()
+
+	if _, exists := rewardPolicies[name]; exists {
+		panic(fmt.Sprintf("reward policy %q already registered", name))
+	}
+	rewardPolicies[name] = policy
+}
+
+// rewardPolicy returns the payout scheme registered under the given name.
+func rewardPolicy(name string) RewardPolicy {
+	rewardPoliciesLock.RLock()
+	defer rewardPoliciesLock.RUnlock()
+
+	return rewardPolicies[name]
+}
+
+// VerifyRewardPolicy checks that the reward policy of a chain configuration, if
+// any, is available and correctly configured.
+func VerifyRewardPolicy(config *params.ChainConfig) error {
+	if config.RewardPolicy == nil || config.RewardPolicy.Block == nil && config.RewardPolicy.Time == nil {
+		return nil
+	}
+	return validateRewardPolicy(config.RewardPolicy)
+}
+
+// validateRewardPolicy checks that a scheduled reward policy is available and
+// correctly configured. It's installed as params.ValidateRewardPolicy, so the
+// chain configurations get rejected on setup rather than when finalizing blocks.
+func validateRewardPolicy(config *params.RewardPolicyConfig) error {
+	policy := rewardPolicy(config.Policy)
+	if policy == nil {
+		return fmt.Errorf("unknown reward policy %q", config.Policy)
+	}
+	if err := policy.Validate(config); err != nil {
+		return fmt.Errorf("invalid reward policy %q: %v", config.Policy, err)
+	}
+	return nil
+}
+
+// CreditReward credits the block reward of the beneficiary of a block, either
+// to the beneficiary or according to the reward policy of the chain if active.
+func CreditReward(chain consensus.ChainHeaderReader, state *state.StateDB, header *types.Header, reward *big.Int) {
+	config := chain.Config()
+	if !config.IsRewardPolicy(header.Number, header.Time) {
+		state.AddBalance(header.Coinbase, reward)
+		return
+	}
+	policy := rewardPolicy(config.RewardPolicy.Policy)
+	if policy == nil {
+		// Unreachable, the policy was checked with the chain configuration.
+		// Crediting the beneficiary instead would fork the chain off silently.
+		panic(fmt.Sprintf("unknown reward policy %q", config.RewardPolicy.Policy))
+	}
+	policy.Distribute(chain, config.RewardPolicy, state, header, reward)
+}
+
+// splitPolicy splits the rewards among the payees proportionally to their
+// weights. The rounding remainder is credited to the first payee.
+type splitPolicy struct{}
+
+func (splitPolicy) Validate(config *params.RewardPolicyConfig) error {
+	if len(config.Payees) == 0 {
+		return errors.New("no payees")
+	}
+	for _, payee := range config.Payees {
+		if payee.Weight == 0 {
+			return fmt.Errorf("payee %x has zero weight", payee.Address)
+		}
+	}
+	return nil
+}
+
+func (splitPolicy) Distribute(chain consensus.ChainHeaderReader, config *params.RewardPolicyConfig, state *state.StateDB, header *types.Header, reward *big.Int) {
+	total := new(big.Int)
+	for _, payee := range config.Payees {
+		total.Add(total, new(big.Int).SetUint64(payee.Weight))
+	}
+	var (
+		paid  = new(big.Int)
+		share = new(big.Int)
+	)
+	for i := len(config.Payees) - 1; i > 0; i-- {
+		share.Mul(reward, new(big.Int).SetUint64(config.Payees[i].Weight))
+		share.Div(share, total)
+		state.AddBalance(config.Payees[i].Address, share)
+		paid.Add(paid, share)
+	}
+	state.AddBalance(config.Payees[0].Address, new(big.Int).Sub(reward, paid))
+}
+
+// contractPolicy pays the rewards out to a system contract, then calls its
+// distribute(address beneficiary, uint256 reward) method from the system address
+// to let it distribute them according to its own logic. A failing call leaves
+// the reward with the contract.
+type contractPolicy struct{}
+
+func (contractPolicy) Validate(config *params.RewardPolicyConfig) error {
+	if config.Contract == nil {
+		return errors.New("no contract")
+	}
+	return nil
+}
+
+func (contractPolicy) Distribute(chain consensus.ChainHeaderReader, config *params.RewardPolicyConfig, statedb *state.StateDB, header *types.Header, reward *big.Int) {
+	statedb.AddBalance(*config.Contract, reward)
+
+	input := make([]byte, 0, 4+2*32)
+	input = append(input, rewardContractMethod...)
+	input = append(input, common.LeftPadBytes(header.Coinbase.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(reward.Bytes(), 32)...)
+
+	context := vm.BlockContext{
+		CanTransfer: func(db vm.StateDB, addr common.Address, amount *big.Int) bool {
+			return db.GetBalance(addr).Cmp(amount) >= 0
+		},
+		Transfer: func(db vm.StateDB, sender, recipient common.Address, amount *big.Int) {
+			db.SubBalance(sender, amount)
+			db.AddBalance(recipient, amount)
+		},
+		GetHash:     ancestorHash(chain, header),
+		Coinbase:    header.Coinbase,
+		BlockNumber: new(big.Int).Set(header.Number),
+		Time:        new(big.Int).SetUint64(header.Time),
+		Difficulty:  new(big.Int).Set(header.Difficulty),
+		GasLimit:    header.GasLimit,
+	}
+	// Run the call outside of any transaction, as done for the transactions
+	statedb.Prepare(common.Hash{}, common.Hash{}, 0)
+	if rules := chain.Config().Rules(header.Number); rules.IsBerlin {
+		statedb.PrepareAccessList(RewardSystemAddress, config.Contract, vm.ActivePrecompiles(rules), nil)
+	}
+	evm := vm.NewEVM(context, vm.TxContext{Origin: RewardSystemAddress, GasPrice: new(big.Int)}, statedb, chain.Config(), vm.Config{})
+	evm.Call(vm.AccountRef(RewardSystemAddress), *config.Contract, input, RewardContractGas, new(big.Int))
+}
+
+// ancestorHash returns a function retrieving the hashes of the ancestors of a
+// header by number, for the BLOCKHASH opcode.
+func ancestorHash(chain consensus.ChainHeaderReader, header *types.Header) vm.GetHashFunc {
+	return func(number uint64) common.Hash {
+		for parent := header; parent.Number.Sign() > 0; {
+			if parent = chain.GetHeader(parent.ParentHash, parent.Number.Uint64()-1); parent == nil {
+				break
+			}
+			if parent.Number.Uint64() == number {
+				return parent.Hash()
+			}
+			if parent.Number.Uint64() < number {
+				break
+			}
+		}
+		return common.Hash{}
+	}
+}
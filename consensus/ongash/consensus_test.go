@@ -27,6 +27,9 @@ import (
 
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/common/math"
+	"github.com/ong2020/go-orange/consensus/misc"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/state"
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/params"
 )
@@ -186,3 +189,128 @@ func BenchmarkDifficultyCalculator(b *testing.B) {
 		}
 	})
 }
+
+// rewardTestChain is a chain reader without any headers, finalizing blocks with
+// the given configuration.
+type rewardTestChain struct {
+	config *params.ChainConfig
+}
+
+func (c *rewardTestChain) Config() *params.ChainConfig                             { return c.config }
+func (c *rewardTestChain) CurrentHeader() *types.Header                            { return nil }
+func (c *rewardTestChain) GetHeader(hash common.Hash, number uint64) *types.Header { return nil }
+func (c *rewardTestChain) GetHeaderByNumber(number uint64) *types.Header           { return nil }
+func (c *rewardTestChain) GetHeaderByHash(hash common.Hash) *types.Header          { return nil }
+
+// Tests that the reward policy of the chain redirects the block rewards of the
+// beneficiary from its activation block, leaving the uncle rewards untouched.
+func TestRewardPolicy(t *testing.T) {
+	var (
+		coinbase = common.HexToAddress("0xc0")
+		uncle    = common.HexToAddress("0xc1")
+		payee1   = common.HexToAddress("0x01")
+		payee2   = common.HexToAddress("0x02")
+	)
+	config := *params.TestChainConfig
+	config.RewardPolicy = &params.RewardPolicyConfig{
+		Block:  big.NewInt(10),
+		Policy: misc.RewardPolicySplit,
+		Payees: []params.RewardPayee{{Address: payee1, Weight: 1}, {Address: payee2, Weight: 2}},
+	}
+	if err := misc.VerifyRewardPolicy(&config); err != nil {
+		t.Fatalf("failed to verify reward policy: %v", err)
+	}
+	for _, number := range []int64{9, 10} {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		header := &types.Header{Number: big.NewInt(number), Coinbase: coinbase}
+		uncles := []*types.Header{{Number: big.NewInt(number - 1), Coinbase: uncle}}
+		accumulateRewards(&rewardTestChain{&config}, statedb, header, uncles)
+
+		reward := new(big.Int).Add(ConstantinopleBlockReward, new(big.Int).Div(ConstantinopleBlockReward, big32))
+		if statedb.GetBalance(uncle).Sign() == 0 {
+			t.Errorf("block %d: uncle not rewarded", number)
+		}
+		if number < 10 {
+			if have := statedb.GetBalance(coinbase); have.Cmp(reward) != 0 {
+				t.Errorf("block %d: coinbase reward mismatch: have %v, want %v", number, have, reward)
+			}
+			continue
+		}
+		if have := statedb.GetBalance(coinbase); have.Sign() != 0 {
+			t.Errorf("block %d: coinbase rewarded with policy: %v", number, have)
+		}
+		share2 := new(big.Int).Div(new(big.Int).Mul(reward, big.NewInt(2)), big.NewInt(3))
+		if have := statedb.GetBalance(payee2); have.Cmp(share2) != 0 {
+			t.Errorf("block %d: payee 2 reward mismatch: have %v, want %v", number, have, share2)
+		}
+		if have, want := statedb.GetBalance(payee1), new(big.Int).Sub(reward, share2); have.Cmp(want) != 0 {
+			t.Errorf("block %d: payee 1 reward mismatch: have %v, want %v", number, have, want)
+		}
+	}
+	config.RewardPolicy.Policy = "unknown"
+	if err := misc.VerifyRewardPolicy(&config); err == nil {
+		t.Errorf("unknown reward policy accepted")
+	}
+	if err := config.CheckConfigForkOrder(); err == nil {
+		t.Errorf("unknown reward policy accepted by the config checks")
+	}
+}
+
+// Tests that the contract reward policy pays the block rewards out to the system
+// contract and notifies it from the system address, the contract keeping the
+// rewards if the notification fails.
+func TestRewardPolicyContract(t *testing.T) {
+	var (
+		coinbase = common.HexToAddress("0xc0")
+		contract = common.HexToAddress("0xc0de")
+		reward   = new(big.Int).Set(ConstantinopleBlockReward)
+
+		// recordCode stores the caller and the arguments of its distribute call
+		recordCode = []byte{0x33, 0x60, 0x00, 0x55, 0x60, 0x24, 0x35, 0x60, 0x01, 0x55, 0x60, 0x04, 0x35, 0x60, 0x02, 0x55, 0x00}
+		revertCode = []byte{0x60, 0x00, 0x60, 0x00, 0xfd}
+	)
+	config := *params.TestChainConfig
+	config.RewardPolicy = &params.RewardPolicyConfig{Block: big.NewInt(0), Policy: misc.RewardPolicyContract, Contract: &contract}
+	if err := config.CheckConfigForkOrder(); err != nil {
+		t.Fatalf("failed to check reward policy: %v", err)
+	}
+	tests := []struct {
+		code   []byte
+		record bool
+	}{
+		{code: recordCode, record: true},
+		{code: revertCode},
+	}
+	for _, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(contract, tt.code)
+
+		header := &types.Header{Number: big.NewInt(1), Coinbase: coinbase, Difficulty: big.NewInt(1), GasLimit: 8000000}
+		accumulateRewards(&rewardTestChain{&config}, statedb, header, nil)
+
+		if have := statedb.GetBalance(coinbase); have.Sign() != 0 {
+			t.Errorf("coinbase rewarded with policy: %v", have)
+		}
+		if have := statedb.GetBalance(contract); have.Cmp(reward) != 0 {
+			t.Errorf("contract reward mismatch: have %v, want %v", have, reward)
+		}
+		var want [3]common.Hash
+		if tt.record {
+			want = [3]common.Hash{misc.RewardSystemAddress.Hash(), common.BigToHash(reward), coinbase.Hash()}
+		}
+		for i, want := range want {
+			if have := statedb.GetState(contract, common.BigToHash(big.NewInt(int64(i)))); have != want {
+				t.Errorf("contract slot %d mismatch: have %x, want %x", i, have, want)
+			}
+		}
+	}
+	config.RewardPolicy.Contract = nil
+	if err := config.CheckConfigForkOrder(); err == nil {
+		t.Errorf("contract reward policy without contract accepted")
+	}
+	config.RewardPolicy.Contract = &contract
+	config.Clique = &params.CliqueConfig{Period: 1, Epoch: 30000}
+	if err := config.CheckConfigForkOrder(); err == nil {
+		t.Errorf("reward policy accepted on clique chain")
+	}
+}
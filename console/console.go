@@ -61,6 +61,7 @@ type Config struct {
 	Prompter prompt.UserPrompter // Input prompter to allow interactive user feedback (defaults to TerminalPrompter)
 	Printer  io.Writer           // Output writer to serialize any display strings to (defaults to os.Stdout)
 	Preload  []string            // Absolute paths to JavaScript files to preload
+	Record   string              // File to append the transcript of the session to (empty = not recorded)
 }
 
 // Console is a JavaScript interpreted runtime environment. It is a fully fledged
@@ -74,6 +75,7 @@ type Console struct {
 	histPath string              // Absolute path to the console scrollback history
 	history  []string            // Scroll history maintained by the console
 	printer  io.Writer           // Output writer to serialize any display strings to
+	recorder *recorder           // Transcript of the session, if recorded
 }
 
 // New initializes a JavaScript interpreted runtime environment and sets defaults
@@ -90,6 +92,14 @@ func New(config Config) (*Console, error) {
 		config.Printer = colorable.NewColorableStdout()
 	}
 
+	var rec *recorder
+	if config.Record != "" {
+		var err error
+		if rec, err = newRecorder(config.Record); err != nil {
+			return nil, err
+		}
+		config.Printer = io.MultiWriter(config.Printer, rec)
+	}
 	// Initialize the console and return
 	console := &Console{
 		client:   config.Client,
@@ -98,11 +108,15 @@ func New(config Config) (*Console, error) {
 		prompter: config.Prompter,
 		printer:  config.Printer,
 		histPath: filepath.Join(config.DataDir, HistoryFile),
+		recorder: rec,
 	}
 	if err := os.MkdirAll(config.DataDir, 0700); err != nil {
 		return nil, err
 	}
 	if err := console.init(config.Preload); err != nil {
+		if rec != nil {
+			rec.close()
+		}
 		return nil, err
 	}
 	return console, nil
@@ -222,6 +236,7 @@ func (c *Console) initAdmin(vm *goja.Runtime, bridge *bridge) {
 		admin.Set("sleepBlocks", jsre.MakeCallback(vm, bridge.SleepBlocks))
 		admin.Set("sleep", jsre.MakeCallback(vm, bridge.Sleep))
 		admin.Set("clearHistory", c.clearHistory)
+		admin.Set("searchHistory", c.searchHistory)
 	}
 }
 
@@ -258,6 +273,21 @@ func (c *Console) clearHistory() {
 	}
 }
 
+// searchHistory returns the commands of the history containing the given text,
+// oldest first. The search is case insensitive.
+func (c *Console) searchHistory(text string) []string {
+	var (
+		matches = make([]string, 0)
+		lower   = strings.ToLower(text)
+	)
+	for _, command := range c.history {
+		if command != "" && strings.Contains(strings.ToLower(command), lower) {
+			matches = append(matches, command)
+		}
+	}
+	return matches
+}
+
 // consoleOutput is an override for the console.log and console.error Methods to
 // stream the output into the configured output stream instead of stdout.
 func (c *Console) consoleOutput(call goja.FunctionCall) goja.Value {
@@ -336,6 +366,9 @@ func (c *Console) Evaluate(statement string) {
 			fmt.Fprintf(c.printer, "[native] error: %v\n", r)
 		}
 	}()
+	if c.recorder != nil {
+		c.recorder.input(statement)
+	}
 	c.jsre.Evaluate(statement, c.printer)
 }
 
@@ -483,5 +516,8 @@ func (c *Console) Stop(graceful bool) error {
 		return err
 	}
 	c.jsre.Stop(graceful)
+	if c.recorder != nil {
+		return c.recorder.close()
+	}
 	return nil
 }
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -340,3 +342,51 @@ func TestIndenting(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the commands of the history can be searched.
+func TestSearchHistory(t *testing.T) {
+	tester := newTester(t, nil)
+	defer tester.Close(t)
+
+	tester.console.history = []string{"ong.blockNumber", "admin.peers", "ONG.getBalance(ong.coinbase)", ""}
+	tester.console.Evaluate("admin.searchHistory('ong.')")
+	if output := tester.output.String(); !strings.Contains(output, `["ong.blockNumber", "ONG.getBalance(ong.coinbase)"]`) {
+		t.Fatalf("history search mismatch: have %s", output)
+	}
+}
+
+// Tests that console sessions are recorded with their inputs, outputs and
+// timestamps, leaving out the inputs which may contain passwords.
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transcript")
+	rec, err := newRecorder(path)
+	if err != nil {
+		t.Fatalf("failed to create recorder: %v", err)
+	}
+	rec.now = func() time.Time { return time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC) }
+	rec.input("function f() {\nreturn 4\n}\n")
+	fmt.Fprintln(rec, "undefined")
+	rec.input(`personal.unlockAccount(ong.coinbase, "secret")`)
+	rec.close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read transcript: %v", err)
+	}
+	lines := strings.Split(string(content), "\n")
+	want := []string{
+		"[2021-01-02T03:04:05.000Z] > function f() {",
+		"                           . return 4",
+		"                           . }",
+		"undefined",
+		"[2021-01-02T03:04:05.000Z] > <input not recorded, may contain a password>",
+		"# 2021-01-02T03:04:05.000Z session ended",
+		"",
+	}
+	if !strings.HasPrefix(lines[0], "# ") || !strings.HasSuffix(lines[0], " session started") {
+		t.Errorf("transcript header mismatch: %q", lines[0])
+	}
+	if !reflect.DeepEqual(lines[1:], want) {
+		t.Errorf("transcript mismatch:\nhave %q\nwant %q", lines[1:], want)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package console
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordTimeFormat is the format of the timestamps of the session transcripts.
+const recordTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// recorder writes an annotated transcript of a console session to a file: every
+// input prefixed by the time it was entered, followed by its output.
+type recorder struct {
+	file *os.File
+	lock sync.Mutex
+	now  func() time.Time
+}
+
+// newRecorder opens the transcript file, appending to it if it already exists.
+func newRecorder(path string) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	r := &recorder{file: file, now: time.Now}
+	r.annotate("session started")
+	return r, nil
+}
+
+// annotate writes a timestamped comment line.
+func (r *recorder) annotate(comment string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	fmt.Fprintf(r.file, "# %s %s\n", r.now().Format(recordTimeFormat), comment)
+}
+
+// input records an input statement. Statements which may contain passwords are
+// not recorded, like they are left out of the history.
+func (r *recorder) input(statement string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	statement = strings.TrimRight(statement, "\n")
+	if passwordRegexp.MatchString(statement) {
+		statement = "<input not recorded, may contain a password>"
+	}
+	prefix := "[" + r.now().Format(recordTimeFormat) + "] "
+	for i, line := range strings.Split(statement, "\n") {
+		if i == 0 {
+			fmt.Fprintf(r.file, "%s> %s\n", prefix, line)
+		} else {
+			fmt.Fprintf(r.file, "%s. %s\n", strings.Repeat(" ", len(prefix)), line)
+		}
+	}
+}
+
+// Write records the output of the console.
+func (r *recorder) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.file.Write(p)
+}
+
+// close ends the transcript of the session.
+func (r *recorder) close() error {
+	r.annotate("session ended")
+	return r.file.Close()
+}
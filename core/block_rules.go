@@ -0,0 +1,246 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/params"
+)
+
+// Names of the built-in block rules.
+const (
+	BlockRuleMaxExtraData  = "maxExtraData"  // {"size": n}: limits the extra-data of the headers to n bytes
+	BlockRuleBannedTxTypes = "bannedTxTypes" // {"types": [t, ...]}: rejects the transactions of the given types
+	BlockRuleMaxCalldata   = "maxCalldata"   // {"size": n}: limits the input data of the transactions to n bytes
+)
+
+// BlockRule is an additional validation rule of the blocks of a chain, enforced
+// from the activation given in the chain configuration on. It is checked
+// when importing blocks, and transactions breaking it are refused by the
+// transaction pool, so it must be deterministic and depend only on its inputs.
+type BlockRule interface {
+	// VerifyHeader checks the header of a block.
+	VerifyHeader(header *types.Header) error
+
+	// VerifyTransaction checks a transaction of a block.
+	VerifyTransaction(tx *types.Transaction) error
+}
+
+// BlockRuleFactory creates a block rule from its parameters in the chain
+// configuration.
+type BlockRuleFactory func(params json.RawMessage) (BlockRule, error)
+
+var (
+	blockRuleFactories = map[string]BlockRuleFactory{
+		BlockRuleMaxExtraData:  newMaxExtraDataRule,
+		BlockRuleBannedTxTypes: newBannedTxTypesRule,
+		BlockRuleMaxCalldata:   newMaxCalldataRule,
+	}
+	blockRuleFactoriesLock sync.RWMutex
+)
+
+// RegisterBlockRule makes a block validation rule available to chain
+// configurations under the given name. It's meant to be called by the programs
+// embedding the node for their private chains, before the chain is opened.
+func RegisterBlockRule(name string, factory BlockRuleFactory) {
+	blockRuleFactoriesLock.Lock()
+	defer blockRuleFactoriesLock.Unlock()
+
+	if _, exists := blockRuleFactories[name]; exists {
+		panic(fmt.Sprintf("block rule %q already registered", name))
+	}
+	blockRuleFactories[name] = factory
+}
+
+// VerifyBlockRules checks that the block rules of a chain configuration, if any,
+// are available and correctly configured.
+func VerifyBlockRules(config *params.ChainConfig) error {
+	_, err := newBlockRules(config)
+	return err
+}
+
+// blockRule is a block rule scheduled by the chain configuration.
+type blockRule struct {
+	id    int // Position of the rule in the chain configuration
+	name  string
+	block *big.Int // Activation block (nil = scheduled by timestamp)
+	time  *uint64  // Activation timestamp (nil = scheduled by block)
+	rule  BlockRule
+}
+
+// blockRules are the block rules scheduled by a chain configuration.
+type blockRules []blockRule
+
+// newBlockRules creates the block rules scheduled by a chain configuration.
+func newBlockRules(config *params.ChainConfig) (blockRules, error) {
+	blockRuleFactoriesLock.RLock()
+	defer blockRuleFactoriesLock.RUnlock()
+
+	var rules blockRules
+	for i, cfg := range config.BlockRules {
+		if cfg.Block == nil && cfg.Time == nil {
+			continue
+		}
+		factory := blockRuleFactories[cfg.Rule]
+		if factory == nil {
+			return nil, fmt.Errorf("unknown block rule %q", cfg.Rule)
+		}
+		rule, err := factory(cfg.Params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block rule %q: %v", cfg.Rule, err)
+		}
+		rules = append(rules, blockRule{id: i, name: cfg.Rule, block: cfg.Block, time: cfg.Time, rule: rule})
+	}
+	return rules, nil
+}
+
+// active returns the rules applying to the block with the given number and
+// timestamp.
+func (rules blockRules) active(num *big.Int, time uint64) blockRules {
+	var active blockRules
+	for _, r := range rules {
+		if (r.block != nil && r.block.Cmp(num) <= 0) || (r.time != nil && *r.time <= time) {
+			active = append(active, r)
+		}
+	}
+	return active
+}
+
+// equal reports whether two sets of rules of a chain configuration hold the same
+// rules.
+func (rules blockRules) equal(other blockRules) bool {
+	if len(rules) != len(other) {
+		return false
+	}
+	for i := range rules {
+		if rules[i].id != other[i].id {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyBlock checks a block against the rules applying to it.
+func (rules blockRules) verifyBlock(block *types.Block) error {
+	rules = rules.active(block.Number(), block.Time())
+	for _, r := range rules {
+		if err := r.rule.VerifyHeader(block.Header()); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrBlockRuleViolated, r.name, err)
+		}
+	}
+	for i, tx := range block.Transactions() {
+		if err := rules.verifyTransaction(tx); err != nil {
+			return fmt.Errorf("tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+	}
+	return nil
+}
+
+// verifyTransaction checks a transaction against the rules.
+func (rules blockRules) verifyTransaction(tx *types.Transaction) error {
+	for _, r := range rules {
+		if err := r.rule.VerifyTransaction(tx); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrBlockRuleViolated, r.name, err)
+		}
+	}
+	return nil
+}
+
+// maxExtraDataRule limits the size of the extra-data of the headers.
+type maxExtraDataRule struct{ size int }
+
+func newMaxExtraDataRule(config json.RawMessage) (BlockRule, error) {
+	var cfg struct {
+		Size *int `json:"size"`
+	}
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Size == nil || *cfg.Size < 0 {
+		return nil, errors.New("missing or negative size")
+	}
+	return &maxExtraDataRule{size: *cfg.Size}, nil
+}
+
+func (r *maxExtraDataRule) VerifyHeader(header *types.Header) error {
+	if len(header.Extra) > r.size {
+		return fmt.Errorf("extra-data too long: have %d, want at most %d", len(header.Extra), r.size)
+	}
+	return nil
+}
+
+func (r *maxExtraDataRule) VerifyTransaction(tx *types.Transaction) error { return nil }
+
+// bannedTxTypesRule rejects the transactions of some types.
+type bannedTxTypesRule struct{ types map[uint8]bool }
+
+func newBannedTxTypesRule(config json.RawMessage) (BlockRule, error) {
+	var cfg struct {
+		Types []uint8 `json:"types"`
+	}
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.Types) == 0 {
+		return nil, errors.New("no transaction types")
+	}
+	rule := &bannedTxTypesRule{types: make(map[uint8]bool)}
+	for _, typ := range cfg.Types {
+		rule.types[typ] = true
+	}
+	return rule, nil
+}
+
+func (r *bannedTxTypesRule) VerifyHeader(header *types.Header) error { return nil }
+
+func (r *bannedTxTypesRule) VerifyTransaction(tx *types.Transaction) error {
+	if r.types[tx.Type()] {
+		return fmt.Errorf("transaction type %d banned", tx.Type())
+	}
+	return nil
+}
+
+// maxCalldataRule limits the size of the input data of the transactions.
+type maxCalldataRule struct{ size int }
+
+func newMaxCalldataRule(config json.RawMessage) (BlockRule, error) {
+	var cfg struct {
+		Size *int `json:"size"`
+	}
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Size == nil || *cfg.Size < 0 {
+		return nil, errors.New("missing or negative size")
+	}
+	return &maxCalldataRule{size: *cfg.Size}, nil
+}
+
+func (r *maxCalldataRule) VerifyHeader(header *types.Header) error { return nil }
+
+func (r *maxCalldataRule) VerifyTransaction(tx *types.Transaction) error {
+	if len(tx.Data()) > r.size {
+		return fmt.Errorf("calldata too large: have %d, want at most %d", len(tx.Data()), r.size)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus/ongash"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/state"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/params"
+)
+
+// Tests that the block rules of the chain configuration are checked.
+func TestVerifyBlockRules(t *testing.T) {
+	tests := []struct {
+		rule params.BlockRuleConfig
+		fail bool
+	}{
+		{params.BlockRuleConfig{Rule: BlockRuleMaxCalldata, Block: big.NewInt(0), Params: json.RawMessage(`{"size": 10}`)}, false},
+		{params.BlockRuleConfig{Rule: BlockRuleMaxCalldata, Block: big.NewInt(0), Params: json.RawMessage(`{}`)}, true},
+		{params.BlockRuleConfig{Rule: BlockRuleBannedTxTypes, Block: big.NewInt(0), Params: json.RawMessage(`{"types": []}`)}, true},
+		{params.BlockRuleConfig{Rule: "unknown", Block: big.NewInt(0)}, true},
+		{params.BlockRuleConfig{Rule: "unknown"}, false},
+	}
+	for i, tt := range tests {
+		config := *params.TestChainConfig
+		config.BlockRules = []params.BlockRuleConfig{tt.rule}
+		if err := VerifyBlockRules(&config); (err != nil) != tt.fail {
+			t.Errorf("test %d: error mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+}
+
+// Tests that blocks and transactions breaking the active block rules are
+// refused.
+func TestBlockRules(t *testing.T) {
+	var (
+		signer = types.HomesteadSigner{}
+		config = *params.TestChainConfig
+	)
+	config.BlockRules = []params.BlockRuleConfig{
+		{Rule: BlockRuleMaxCalldata, Block: big.NewInt(1), Params: json.RawMessage(`{"size": 4}`)},
+	}
+	blockchain, _ := newTransferChain(t, rawdb.NewMemoryDatabase(), &config, 0)
+	defer blockchain.Stop()
+
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(0), 50000, big.NewInt(1), make([]byte, 5)), signer, transferKey)
+	block := GenerateBadBlock(blockchain.Genesis(), ongash.NewFaker(), types.Transactions{tx})
+	if _, err := blockchain.InsertChain(types.Blocks{block}); !errors.Is(err, ErrBlockRuleViolated) {
+		t.Fatalf("block import error mismatch: have %v, want %v", err, ErrBlockRuleViolated)
+	}
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.AddBalance(transferAddr, big.NewInt(params.Oranger))
+	pool := NewTxPool(testTxPoolConfig, &config, &testBlockChain{statedb, 1000000, new(event.Feed)})
+	defer pool.Stop()
+
+	if err := pool.AddRemote(tx); !errors.Is(err, ErrBlockRuleViolated) {
+		t.Fatalf("pool error mismatch: have %v, want %v", err, ErrBlockRuleViolated)
+	}
+	tx, _ = types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(0), 50000, big.NewInt(1), make([]byte, 4)), signer, transferKey)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("pool refused valid transaction: %v", err)
+	}
+}
+
+// Tests that the block rules activate at their block or at their timestamp.
+func TestBlockRulesActivation(t *testing.T) {
+	activation := uint64(100)
+	config := *params.TestChainConfig
+	config.BlockRules = []params.BlockRuleConfig{
+		{Rule: BlockRuleMaxCalldata, Block: big.NewInt(10), Params: json.RawMessage(`{"size": 4}`)},
+		{Rule: BlockRuleMaxExtraData, Time: &activation, Params: json.RawMessage(`{"size": 4}`)},
+	}
+	rules, err := newBlockRules(&config)
+	if err != nil {
+		t.Fatalf("failed to create block rules: %v", err)
+	}
+	tests := []struct {
+		number uint64
+		time   uint64
+		active int
+	}{
+		{number: 9, time: 99, active: 0},
+		{number: 10, time: 99, active: 1},
+		{number: 9, time: 100, active: 1},
+		{number: 10, time: 100, active: 2},
+	}
+	for i, tt := range tests {
+		if active := rules.active(new(big.Int).SetUint64(tt.number), tt.time); len(active) != tt.active {
+			t.Errorf("test %d: active rules mismatch: have %d, want %d", i, len(active), tt.active)
+		}
+	}
+}
+
+// Tests that a chain with misconfigured block rules fails to open instead of
+// silently ignoring them.
+func TestBlockRulesMisconfigured(t *testing.T) {
+	config := *params.TestChainConfig
+	config.BlockRules = []params.BlockRuleConfig{
+		{Rule: "unknown", Block: big.NewInt(1)},
+	}
+	db := rawdb.NewMemoryDatabase()
+	(&Genesis{Config: &config}).MustCommit(db)
+
+	if _, err := NewBlockChain(db, nil, &config, ongash.NewFaker(), vm.Config{}, nil, nil); err == nil {
+		t.Fatalf("chain opened with unknown block rule")
+	}
+}
+
+// Tests that the transaction pool evicts the transactions breaking the block
+// rules when the head reaches their activation block.
+func TestBlockRulesPoolActivation(t *testing.T) {
+	var (
+		signer = types.HomesteadSigner{}
+		config = *params.TestChainConfig
+	)
+	config.BlockRules = []params.BlockRuleConfig{
+		{Rule: BlockRuleMaxCalldata, Block: big.NewInt(2), Params: json.RawMessage(`{"size": 4}`)},
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.AddBalance(transferAddr, big.NewInt(params.Oranger))
+	pool := NewTxPool(testTxPoolConfig, &config, &testBlockChain{statedb, 1000000, new(event.Feed)})
+	defer pool.Stop()
+
+	valid, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(0), 50000, big.NewInt(1), make([]byte, 4)), signer, transferKey)
+	violating, _ := types.SignTx(types.NewTransaction(1, common.Address{}, big.NewInt(0), 50000, big.NewInt(1), make([]byte, 5)), signer, transferKey)
+	for _, err := range pool.AddRemotesSync([]*types.Transaction{valid, violating}) {
+		if err != nil {
+			t.Fatalf("pool refused transaction before rule activation: %v", err)
+		}
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 2)
+	}
+	// Move the head to the block before the activation, the rule applies to the next one
+	<-pool.requestReset(nil, &types.Header{Number: big.NewInt(1), GasLimit: 1000000})
+
+	if pool.Get(violating.Hash()) != nil {
+		t.Fatalf("transaction breaking activated rule not evicted")
+	}
+	if pool.Get(valid.Hash()) == nil {
+		t.Fatalf("valid transaction evicted")
+	}
+}
+
+// Tests that the transaction pool evicts the transactions breaking the block
+// rules when the applying rules switch to a different set of the same size.
+func TestBlockRulesPoolSwitch(t *testing.T) {
+	var (
+		signer = types.HomesteadSigner{}
+		config = *params.TestChainConfig
+		never  = uint64(math.MaxUint64)
+	)
+	config.BlockRules = []params.BlockRuleConfig{
+		{Rule: BlockRuleMaxCalldata, Block: big.NewInt(2), Params: json.RawMessage(`{"size": 4}`)},
+		{Rule: BlockRuleMaxCalldata, Time: &never, Params: json.RawMessage(`{"size": 8}`)},
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.AddBalance(transferAddr, big.NewInt(params.Oranger))
+	pool := NewTxPool(testTxPoolConfig, &config, &testBlockChain{statedb, 1000000, new(event.Feed)})
+	defer pool.Stop()
+
+	// Pretend the pool applied the second rule only, as after a reorg to a head
+	// before the first one with the second one scheduled at an elapsed time
+	pool.mu.Lock()
+	pool.txRules = pool.blockRules[1:]
+	pool.mu.Unlock()
+
+	valid, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(0), 50000, big.NewInt(1), make([]byte, 4)), signer, transferKey)
+	violating, _ := types.SignTx(types.NewTransaction(1, common.Address{}, big.NewInt(0), 50000, big.NewInt(1), make([]byte, 5)), signer, transferKey)
+	for _, err := range pool.AddRemotesSync([]*types.Transaction{valid, violating}) {
+		if err != nil {
+			t.Fatalf("pool refused transaction allowed by the applying rules: %v", err)
+		}
+	}
+	// Move the head to the block before the activation of the first rule only
+	<-pool.requestReset(nil, &types.Header{Number: big.NewInt(1), GasLimit: 1000000})
+
+	if pool.Get(violating.Hash()) != nil {
+		t.Fatalf("transaction breaking switched rules not evicted")
+	}
+	if pool.Get(valid.Hash()) == nil {
+		t.Fatalf("valid transaction evicted")
+	}
+}
@@ -34,16 +34,24 @@ type BlockValidator struct {
 	config *params.ChainConfig // Chain configuration options
 	bc     *BlockChain         // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for validating
+	rules  blockRules          // Additional validation rules of the chain
 }
 
-// NewBlockValidator returns a new block validator which is safe for re-use
-func NewBlockValidator(config *params.ChainConfig, blockchain *BlockChain, engine consensus.Engine) *BlockValidator {
+// NewBlockValidator returns a new block validator which is safe for re-use. It
+// fails if the block rules of the chain configuration can't be set up, as
+// ignoring them would accept blocks the rest of the network refuses.
+func NewBlockValidator(config *params.ChainConfig, blockchain *BlockChain, engine consensus.Engine) (*BlockValidator, error) {
+	rules, err := newBlockRules(config)
+	if err != nil {
+		return nil, err
+	}
 	validator := &BlockValidator{
 		config: config,
 		engine: engine,
 		bc:     blockchain,
+		rules:  rules,
 	}
-	return validator
+	return validator, nil
 }
 
 // ValidateBody validates the given block's uncles and verifies the block
@@ -65,6 +73,9 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != header.TxHash {
 		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, header.TxHash)
 	}
+	if err := v.rules.verifyBlock(block); err != nil {
+		return err
+	}
 	if !v.bc.HasBlockAndState(block.ParentHash(), block.NumberU64()-1) {
 		if !v.bc.HasBlock(block.ParentHash(), block.NumberU64()-1) {
 			return consensus.ErrUnknownAncestor
@@ -76,6 +76,10 @@ var (
 	blockReorgDropMeter     = metrics.NewRegisteredMeter("chain/reorg/drop", nil)
 	blockReorgInvalidatedTx = metrics.NewRegisteredMeter("chain/reorg/invalidTx", nil)
 
+	blockImportCanonicalMeter = metrics.NewRegisteredMeter("chain/import/canonical", nil)
+	blockImportSideMeter      = metrics.NewRegisteredMeter("chain/import/side", nil)
+	blockImportRejectedMeter  = metrics.NewRegisteredMeter("chain/import/rejected", nil)
+
 	blockPrefetchExecuteTimer   = metrics.NewRegisteredTimer("chain/prefetch/executes", nil)
 	blockPrefetchInterruptMeter = metrics.NewRegisteredMeter("chain/prefetch/interrupts", nil)
 
@@ -128,6 +132,8 @@ type CacheConfig struct {
 	TrieDirtyDisabled   bool          // Whonger to disable trie write caching and GC altogonger (archive node)
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
+	SnapshotRejournal   time.Duration // Time interval to checkpoint the snapshot diff layers to disk periodically
+	SnapshotThrottle    int           // Maximum write rate (MB/s) of the background snapshot generation, 0 = unlimited
 	Preimages           bool          // Whonger to store preimage of trie key to the disk
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
@@ -180,6 +186,7 @@ type BlockChain struct {
 	chainHeadFeed event.Feed
 	logsFeed      event.Feed
 	blockProcFeed event.Feed
+	importFeed    event.Feed
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
@@ -202,7 +209,8 @@ type BlockChain struct {
 	procInterrupt int32          // interrupt signaler for block processing
 
 	engine     consensus.Engine
-	validator  Validator // Block and state validator interface
+	validator  Validator  // Block and state validator interface
+	rules      blockRules // Additional validation rules of the chain
 	prefetcher Prefetcher
 	processor  Processor // Block transaction processor interface
 	vmConfig   vm.Config
@@ -247,11 +255,14 @@ func NewBlockChain(db ongdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		engine:         engine,
 		vmConfig:       vmConfig,
 	}
-	bc.validator = NewBlockValidator(chainConfig, bc, engine)
+	validator, err := NewBlockValidator(chainConfig, bc, engine)
+	if err != nil {
+		return nil, err
+	}
+	bc.validator, bc.rules = validator, validator.rules
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
 
-	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
 	if err != nil {
 		return nil, err
@@ -373,6 +384,9 @@ func NewBlockChain(db ongdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			recover = true
 		}
 		bc.snaps, _ = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), !bc.cacheConfig.SnapshotWait, true, recover)
+		if bc.snaps != nil && bc.cacheConfig.SnapshotThrottle > 0 {
+			bc.snaps.SetGenerationRate(bc.cacheConfig.SnapshotThrottle * 1024 * 1024)
+		}
 	}
 	// Take ownership of this particular state
 	go bc.update()
@@ -395,9 +409,43 @@ func NewBlockChain(db ongdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			triedb.SaveCachePeriodically(bc.cacheConfig.TrieCleanJournal, bc.cacheConfig.TrieCleanRejournal, bc.quit)
 		}()
 	}
+	// If periodic snapshot checkpoints are required, spin them up too.
+	if bc.snaps != nil && bc.cacheConfig.SnapshotRejournal > 0 {
+		if bc.cacheConfig.SnapshotRejournal < time.Minute {
+			log.Warn("Sanitizing invalid snapshot journal time", "provided", bc.cacheConfig.SnapshotRejournal, "updated", time.Minute)
+			bc.cacheConfig.SnapshotRejournal = time.Minute
+		}
+		bc.wg.Add(1)
+		go bc.checkpointSnapshot(bc.cacheConfig.SnapshotRejournal)
+	}
 	return bc, nil
 }
 
+// checkpointSnapshot periodically persists the in-memory snapshot diff layers
+// into the snapshot journal, so that a crash or a hard kill doesn't discard
+// all of them (forcing a full snapshot regeneration if the chain head is not
+// recoverable from the disk layer).
+func (bc *BlockChain) checkpointSnapshot(interval time.Duration) {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if bc.snaps.Snapshot(bc.CurrentBlock().Root()) == nil {
+				continue // snapshot not (yet) available for the head, e.g. regenerating
+			}
+			if err := bc.snaps.Checkpoint(bc.CurrentBlock().Root()); err != nil {
+				log.Warn("Failed to checkpoint state snapshot", "err", err)
+			}
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
 // GetVMConfig returns the block chain VM config.
 func (bc *BlockChain) GetVMConfig() *vm.Config {
 	return &bc.vmConfig
@@ -676,6 +724,12 @@ func (bc *BlockChain) Validator() Validator {
 	return bc.validator
 }
 
+// CheckBlockRules checks a transaction against the block rules of the chain
+// configuration applying to the block with the given number and timestamp.
+func (bc *BlockChain) CheckBlockRules(tx *types.Transaction, number *big.Int, time uint64) error {
+	return bc.rules.active(number, time).verifyTransaction(tx)
+}
+
 // Processor returns the current processor.
 func (bc *BlockChain) Processor() Processor {
 	return bc.processor
@@ -896,6 +950,18 @@ func (bc *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
 	return block
 }
 
+// CachedBlocks returns the blocks held in the recent block cache, canonical or not.
+func (bc *BlockChain) CachedBlocks() []*types.Block {
+	keys := bc.blockCache.Keys()
+	blocks := make([]*types.Block, 0, len(keys))
+	for _, key := range keys {
+		if block, ok := bc.blockCache.Peek(key); ok {
+			blocks = append(blocks, block.(*types.Block))
+		}
+	}
+	return blocks
+}
+
 // GetBlockByHash retrieves a block from the database by hash, caching it if found.
 func (bc *BlockChain) GetBlockByHash(hash common.Hash) *types.Block {
 	number := bc.hc.GetBlockNumber(hash)
@@ -1801,7 +1867,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 	case err != nil:
 		bc.futureBlocks.Remove(block.Hash())
 		stats.ignored += len(it.chain)
-		bc.reportBlock(block, nil, err)
+		bc.reportBlock(block, nil, err, validationRejectCode(err))
 		return it.index, err
 	}
 	// No validation errors for the first block (or chain prefix skipped)
@@ -1824,7 +1890,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		}
 		// If the header is a banned one, straight out abort
 		if BadHashes[block.Hash()] {
-			bc.reportBlock(block, nil, ErrBlacklistedHash)
+			bc.reportBlock(block, nil, ErrBlacklistedHash, RejectBlacklisted)
 			return it.index, ErrBlacklistedHash
 		}
 		// If the block is known (in the middle of the chain), it's a special case for
@@ -1901,7 +1967,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		substart := time.Now()
 		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
 		if err != nil {
-			bc.reportBlock(block, receipts, err)
+			bc.reportBlock(block, receipts, err, RejectExecution)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
 		}
@@ -1921,7 +1987,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		// Validate the state using the default validator
 		substart = time.Now()
 		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
-			bc.reportBlock(block, receipts, err)
+			bc.reportBlock(block, receipts, err, RejectState)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
 		}
@@ -1960,12 +2026,18 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 			// Only count canonical blocks for GC processing time
 			bc.gcproc += proctime
 
+			blockImportCanonicalMeter.Mark(1)
+			bc.importFeed.Send(BlockImportEvent{Block: block, Status: ImportCanonical, Elapsed: time.Since(start)})
+
 		case SideStatTy:
 			log.Debug("Inserted forked block", "number", block.Number(), "hash", block.Hash(),
 				"diff", block.Difficulty(), "elapsed", common.PrettyDuration(time.Since(start)),
 				"txs", len(block.Transactions()), "gas", block.GasUsed(), "uncles", len(block.Uncles()),
 				"root", block.Root())
 
+			blockImportSideMeter.Mark(1)
+			bc.importFeed.Send(BlockImportEvent{Block: block, Status: ImportSide, Elapsed: time.Since(start)})
+
 		default:
 			// This in theory is impossible, but lets be nice to our future selves and leave
 			// a log, instead of trying to track down blocks imports that don't emit logs.
@@ -1980,6 +2052,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		dirty, _ := bc.stateCache.TrieDB().Size()
 		stats.report(chain, it.index, dirty)
 	}
+	// A block failing validation in the middle of the batch is rejected too
+	if block != nil && err != nil && err != ErrKnownBlock && !errors.Is(err, consensus.ErrFutureBlock) {
+		blockImportRejectedMeter.Mark(1)
+		bc.importFeed.Send(BlockImportEvent{Block: block, Status: ImportRejected, Code: validationRejectCode(err), Err: err})
+	}
 	// Any blocks remaining here? The only ones we care about are the future ones
 	if block != nil && errors.Is(err, consensus.ErrFutureBlock) {
 		if err := bc.addFutureBlock(block); err != nil {
@@ -2058,6 +2135,9 @@ func (bc *BlockChain) insertSideChain(block *types.Block, it *insertIterator) (i
 				"diff", block.Difficulty(), "elapsed", common.PrettyDuration(time.Since(start)),
 				"txs", len(block.Transactions()), "gas", block.GasUsed(), "uncles", len(block.Uncles()),
 				"root", block.Root())
+
+			blockImportSideMeter.Mark(1)
+			bc.importFeed.Send(BlockImportEvent{Block: block, Status: ImportSide, Elapsed: time.Since(start)})
 		}
 	}
 	// At this point, we've written all sidechain blocks to database. Loop ended
@@ -2389,10 +2469,28 @@ func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 	}
 }
 
-// reportBlock logs a bad block error.
-func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error) {
+// validationRejectCode classifies a block validation error. Blocks whose parent
+// is unknown or which are too far in the future are not proven invalid, so they
+// are told apart from the blocks failing validation.
+func validationRejectCode(err error) RejectCode {
+	switch {
+	case errors.Is(err, consensus.ErrUnknownAncestor):
+		return RejectUnknownAncestor
+	case errors.Is(err, consensus.ErrFutureBlock):
+		return RejectFuture
+	default:
+		return RejectValidation
+	}
+}
+
+// reportBlock logs a bad block error and notifies import event subscribers of
+// the rejection.
+func (bc *BlockChain) reportBlock(block *types.Block, receipts types.Receipts, err error, code RejectCode) {
 	rawdb.WriteBadBlock(bc.db, block)
 
+	blockImportRejectedMeter.Mark(1)
+	bc.importFeed.Send(BlockImportEvent{Block: block, Status: ImportRejected, Code: code, Err: err})
+
 	var receiptString string
 	for i, receipt := range receipts {
 		receiptString += fmt.Sprintf("\t %d: cumulative: %v gas: %v contract: %v status: %v tx: %v logs: %v bloom: %x state: %x\n",
@@ -2545,6 +2643,12 @@ func (bc *BlockChain) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscript
 	return bc.scope.Track(bc.logsFeed.Subscribe(ch))
 }
 
+// SubscribeBlockImportEvent registers a subscription of BlockImportEvent, posted
+// for every block the chain attempted to import, whether accepted or rejected.
+func (bc *BlockChain) SubscribeBlockImportEvent(ch chan<- BlockImportEvent) event.Subscription {
+	return bc.scope.Track(bc.importFeed.Subscribe(ch))
+}
+
 // SubscribeBlockProcessingEvent registers a subscription of bool where true means
 // block processing has started while false means it has stopped.
 func (bc *BlockChain) SubscribeBlockProcessingEvent(ch chan<- bool) event.Subscription {
@@ -73,6 +73,40 @@ func newCanonical(engine consensus.Engine, n int, full bool) (ongdb.Database, *B
 	return db, blockchain, err
 }
 
+var (
+	// transferKey is the key of the account funded by the transfer chains.
+	transferKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	transferAddr   = crypto.PubkeyToAddress(transferKey.PublicKey)
+)
+
+// newTransferChain creates an archive chain over db with the given config, and
+// injects n blocks each holding a transfer from transferAddr. The caller must
+// stop the returned chain.
+func newTransferChain(t *testing.T, db ongdb.Database, config *params.ChainConfig, n int) (*BlockChain, []*types.Block) {
+	var (
+		gspec  = &Genesis{Config: config, Alloc: GenesisAlloc{transferAddr: {Balance: big.NewInt(1000000000)}}}
+		signer = types.LatestSigner(config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ongash.NewFaker(), n, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(transferAddr), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, transferKey)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, &CacheConfig{TrieDirtyDisabled: true}, config, ongash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		chain.Stop()
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	return chain, blocks
+}
+
 // Test fork of length N starting from block i
 func testFork(t *testing.T, blockchain *BlockChain, i, n int, full bool, comparator func(td1, td2 *big.Int)) {
 	// Copy old chain up to #i into a new db
@@ -151,12 +185,12 @@ func testBlockChainImport(chain types.Blocks, blockchain *BlockChain) error {
 		}
 		receipts, _, usedGas, err := blockchain.processor.Process(block, statedb, vm.Config{})
 		if err != nil {
-			blockchain.reportBlock(block, receipts, err)
+			blockchain.reportBlock(block, receipts, err, RejectExecution)
 			return err
 		}
 		err = blockchain.validator.ValidateState(block, statedb, receipts, usedGas)
 		if err != nil {
-			blockchain.reportBlock(block, receipts, err)
+			blockchain.reportBlock(block, receipts, err, RejectState)
 			return err
 		}
 		blockchain.chainmu.Lock()
@@ -474,6 +508,84 @@ func testBadHashes(t *testing.T, full bool) {
 	}
 }
 
+// Tests that the outcome of every block import attempt is reported through the
+// import event feed, along with the reason of rejected blocks.
+func TestBlockImportEvents(t *testing.T) {
+	db, blockchain, err := newCanonical(ongash.NewFaker(), 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	events := make(chan BlockImportEvent, 16)
+	sub := blockchain.SubscribeBlockImportEvent(events)
+	defer sub.Unsubscribe()
+
+	type result struct {
+		hash   common.Hash
+		status ImportStatus
+		code   RejectCode
+	}
+	check := func(want []result) {
+		t.Helper()
+		for i, w := range want {
+			select {
+			case ev := <-events:
+				have := result{ev.Block.Hash(), ev.Status, ev.Code}
+				if have != w {
+					t.Fatalf("event %d mismatch: have %v/%v/%v, want %v/%v/%v", i, have.hash, have.status, have.code, w.hash, w.status, w.code)
+				}
+				if (ev.Err != nil) != (w.status == ImportRejected) {
+					t.Fatalf("event %d error mismatch: have %v", i, ev.Err)
+				}
+			default:
+				t.Fatalf("event %d missing", i)
+			}
+		}
+		select {
+		case ev := <-events:
+			t.Fatalf("unexpected event for %v: %v", ev.Block.Hash(), ev.Status)
+		default:
+		}
+	}
+	// Canonical blocks, then a shorter fork landing on a side chain
+	canon := makeBlockChain(blockchain.CurrentBlock(), 3, ongash.NewFaker(), db, 10)
+	if _, err := blockchain.InsertChain(canon); err != nil {
+		t.Fatalf("failed to insert canonical chain: %v", err)
+	}
+	check([]result{{canon[0].Hash(), ImportCanonical, RejectNone}, {canon[1].Hash(), ImportCanonical, RejectNone}, {canon[2].Hash(), ImportCanonical, RejectNone}})
+
+	side := makeBlockChain(blockchain.Genesis(), 2, ongash.NewFaker(), db, 11)
+	if _, err := blockchain.InsertChain(side); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	check([]result{{side[0].Hash(), ImportSide, RejectNone}, {side[1].Hash(), ImportSide, RejectNone}})
+
+	// A block with a bogus state root, then a blacklisted one
+	next := makeBlockChain(canon[2], 1, ongash.NewFaker(), db, 10)[0]
+	header := next.Header()
+	header.Root = common.Hash{0x01}
+	bad := types.NewBlockWithHeader(header).WithBody(next.Transactions(), next.Uncles())
+	if _, err := blockchain.InsertChain(types.Blocks{bad}); err == nil {
+		t.Fatal("block with invalid state root imported")
+	}
+	check([]result{{bad.Hash(), ImportRejected, RejectState}})
+
+	BadHashes[next.Hash()] = true
+	defer delete(BadHashes, next.Hash())
+	if _, err := blockchain.InsertChain(types.Blocks{next}); !errors.Is(err, ErrBlacklistedHash) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrBlacklistedHash)
+	}
+	check([]result{{next.Hash(), ImportRejected, RejectBlacklisted}})
+
+	// A block with an unknown parent is not proven invalid
+	orphans := makeBlockChain(canon[2], 2, ongash.NewFaker(), db, 12)
+	if _, err := blockchain.InsertChain(orphans[1:]); !errors.Is(err, consensus.ErrUnknownAncestor) {
+		t.Fatalf("error mismatch: have %v, want %v", err, consensus.ErrUnknownAncestor)
+	}
+	check([]result{{orphans[1].Hash(), ImportRejected, RejectUnknownAncestor}})
+}
+
 // Tests that bad hashes are detected on boot, and the chain rolled back to a
 // good state prior to the bad hash.
 func TestReorgBadHeaderHashes(t *testing.T) { testReorgBadHashes(t, false) }
@@ -651,7 +763,7 @@ func TestFastVsFullChains(t *testing.T) {
 		t.Fatalf("failed to create temp freezer dir: %v", err)
 	}
 	defer os.Remove(frdir)
-	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", nil)
 	if err != nil {
 		t.Fatalf("failed to create temp freezer db: %v", err)
 	}
@@ -725,7 +837,7 @@ func TestLightVsFastVsFullChainHeads(t *testing.T) {
 			t.Fatalf("failed to create temp freezer dir: %v", err)
 		}
 		defer os.Remove(dir)
-		db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), dir, "")
+		db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), dir, "", nil)
 		if err != nil {
 			t.Fatalf("failed to create temp freezer db: %v", err)
 		}
@@ -1592,7 +1704,7 @@ func TestBlockchainRecovery(t *testing.T) {
 	}
 	defer os.Remove(frdir)
 
-	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", nil)
 	if err != nil {
 		t.Fatalf("failed to create temp freezer db: %v", err)
 	}
@@ -1649,7 +1761,7 @@ func TestIncompleteAncientReceiptChainInsertion(t *testing.T) {
 		t.Fatalf("failed to create temp freezer dir: %v", err)
 	}
 	defer os.Remove(frdir)
-	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", nil)
 	if err != nil {
 		t.Fatalf("failed to create temp freezer db: %v", err)
 	}
@@ -1848,7 +1960,7 @@ func testInsertKnownChainData(t *testing.T, typ string) {
 		t.Fatalf("failed to create temp freezer dir: %v", err)
 	}
 	defer os.Remove(dir)
-	chaindb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), dir, "")
+	chaindb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), dir, "", nil)
 	if err != nil {
 		t.Fatalf("failed to create temp freezer db: %v", err)
 	}
@@ -2128,7 +2240,7 @@ func TestTransactionIndices(t *testing.T) {
 		t.Fatalf("failed to create temp freezer dir: %v", err)
 	}
 	defer os.Remove(frdir)
-	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", nil)
 	if err != nil {
 		t.Fatalf("failed to create temp freezer db: %v", err)
 	}
@@ -2156,7 +2268,7 @@ func TestTransactionIndices(t *testing.T) {
 	// Init block chain with external ancients, check all needed indices has been indexed.
 	limit := []uint64{0, 32, 64, 128}
 	for _, l := range limit {
-		ancientDb, err = rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+		ancientDb, err = rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", nil)
 		if err != nil {
 			t.Fatalf("failed to create temp freezer db: %v", err)
 		}
@@ -2176,7 +2288,7 @@ func TestTransactionIndices(t *testing.T) {
 	}
 
 	// Reconstruct a block chain which only reserves HEAD-64 tx indices
-	ancientDb, err = rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	ancientDb, err = rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", nil)
 	if err != nil {
 		t.Fatalf("failed to create temp freezer db: %v", err)
 	}
@@ -2255,7 +2367,7 @@ func TestSkipStaleTxIndicesInFastSync(t *testing.T) {
 		t.Fatalf("failed to create temp freezer dir: %v", err)
 	}
 	defer os.Remove(frdir)
-	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", nil)
 	if err != nil {
 		t.Fatalf("failed to create temp freezer db: %v", err)
 	}
@@ -23,6 +23,7 @@ import (
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/consensus"
 	"github.com/ong2020/go-orange/consensus/misc"
+	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/core/state"
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/core/vm"
@@ -240,6 +241,20 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 	return blocks, receipts
 }
 
+// GenerateChainWithGenesis is a wrapper of GenerateChain which commits the
+// genesis block to a new in-memory database first, then generates the chain on
+// top of it. The database is returned along with the blocks, holding their
+// states for generating further blocks.
+func GenerateChainWithGenesis(genesis *Genesis, engine consensus.Engine, n int, gen func(int, *BlockGen)) (ongdb.Database, []*types.Block, []types.Receipts) {
+	db := rawdb.NewMemoryDatabase()
+	block, err := genesis.Commit(db)
+	if err != nil {
+		panic(err)
+	}
+	blocks, receipts := GenerateChain(genesis.Config, block, engine, db, n, gen)
+	return db, blocks, receipts
+}
+
 func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.StateDB, engine consensus.Engine) *types.Header {
 	var time uint64
 	if parent.Time() == 0 {
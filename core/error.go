@@ -31,6 +31,10 @@ var (
 
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
+
+	// ErrBlockRuleViolated is returned if a block or a transaction breaks one of
+	// the additional validation rules of the chain configuration.
+	ErrBlockRuleViolated = errors.New("block rule violated")
 )
 
 // List of evm-call-message pre-checking errors. All state transition messages will
@@ -68,6 +72,10 @@ var (
 	// than required to start the invocation.
 	ErrIntrinsicGas = errors.New("intrinsic gas too low")
 
+	// ErrGasPriceBelowMinimum is returned if the gas price of a transaction is
+	// lower than the minimum enforced by the chain configuration.
+	ErrGasPriceBelowMinimum = errors.New("gas price below network minimum")
+
 	// ErrTxTypeNotSupported is returned if a transaction is not supported in the
 	// current network configuration.
 	ErrTxTypeNotSupported = types.ErrTxTypeNotSupported
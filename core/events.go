@@ -17,6 +17,8 @@
 package core
 
 import (
+	"time"
+
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/core/types"
 )
@@ -41,3 +43,69 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ImportStatus is the outcome of an attempt to import a block.
+type ImportStatus byte
+
+const (
+	ImportCanonical ImportStatus = iota // Block accepted into the canonical chain
+	ImportSide                          // Block accepted into a side chain
+	ImportRejected                      // Block not imported, see RejectCode for the reason
+)
+
+func (s ImportStatus) String() string {
+	switch s {
+	case ImportCanonical:
+		return "canonical"
+	case ImportSide:
+		return "side"
+	case ImportRejected:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// RejectCode classifies the reason a block was rejected for.
+type RejectCode byte
+
+const (
+	RejectNone            RejectCode = iota // Block not rejected
+	RejectBlacklisted                       // Block hash is on the list of bad hashes
+	RejectValidation                        // Header or body failed validation
+	RejectExecution                         // Block failed to execute on top of its parent
+	RejectState                             // Post-execution state does not match the header
+	RejectUnknownAncestor                   // Parent block is not known, the block may be valid
+	RejectFuture                            // Block is too far in the future, it may be valid later
+)
+
+func (c RejectCode) String() string {
+	switch c {
+	case RejectNone:
+		return ""
+	case RejectBlacklisted:
+		return "blacklisted"
+	case RejectValidation:
+		return "validation"
+	case RejectExecution:
+		return "execution"
+	case RejectState:
+		return "state"
+	case RejectUnknownAncestor:
+		return "unknownAncestor"
+	case RejectFuture:
+		return "future"
+	default:
+		return "unknown"
+	}
+}
+
+// BlockImportEvent is posted for every block the chain attempted to import,
+// reporting whether it was accepted and if not, why.
+type BlockImportEvent struct {
+	Block   *types.Block
+	Status  ImportStatus
+	Code    RejectCode    // Classification of the rejection, RejectNone if accepted
+	Err     error         // Reason of the rejection, nil if accepted
+	Elapsed time.Duration // Time spent on importing the block
+}
@@ -148,8 +148,9 @@ func (e *GenesisMismatchError) Error() string {
 //     db has genesis    |  from DB           |  genesis (if compatible)
 //
 // The stored chain configuration will be updated if it is compatible (i.e. does not
-// specify a fork block below the local head block). In case of a conflict, the
-// error is a *params.ConfigCompatError and the new, unwritten config is returned.
+// specify a fork block or timestamp below the local head block). In case of a
+// conflict, the error is a *params.ConfigCompatError and the new, unwritten config
+// is returned.
 //
 // The returned chain configuration is never nil.
 func SetupGenesisBlock(db ongdb.Database, genesis *Genesis) (*params.ChainConfig, common.Hash, error) {
@@ -222,11 +223,19 @@ func SetupGenesisBlockWithOverride(db ongdb.Database, genesis *Genesis, override
 	}
 	// Check config compatibility and write the config. Compatibility errors
 	// are returned to the caller unless we're already at block zero.
-	height := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db))
+	headHash := rawdb.ReadHeadHeaderHash(db)
+	height := rawdb.ReadHeaderNumber(db, headHash)
 	if height == nil {
 		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
 	}
-	compatErr := storedcfg.CheckCompatible(newcfg, *height)
+	head := rawdb.ReadHeader(db, headHash, *height)
+	if head == nil {
+		return newcfg, stored, fmt.Errorf("missing head header %d [%x]", *height, headHash)
+	}
+	compatErr := storedcfg.CheckCompatible(newcfg, *height, head.Time)
+	if compatErr != nil && compatErr.RewindToTime > 0 {
+		compatErr.RewindTo = rewindToTimestamp(db, head, compatErr.RewindToTime)
+	}
 	if compatErr != nil && *height != 0 && compatErr.RewindTo != 0 {
 		return newcfg, stored, compatErr
 	}
@@ -234,6 +243,19 @@ func SetupGenesisBlockWithOverride(db ongdb.Database, genesis *Genesis, override
 	return newcfg, stored, nil
 }
 
+// rewindToTimestamp returns the number of the highest canonical block, starting
+// from the given head, which was produced at or before the given timestamp.
+func rewindToTimestamp(db ongdb.Reader, head *types.Header, time uint64) uint64 {
+	for head.Number.Uint64() > 0 && head.Time > time {
+		parent := rawdb.ReadHeader(db, head.ParentHash, head.Number.Uint64()-1)
+		if parent == nil {
+			break
+		}
+		head = parent
+	}
+	return head.Number.Uint64()
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case g != nil:
@@ -17,12 +17,14 @@
 package core
 
 import (
+	"errors"
 	"math/big"
 	"reflect"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus/misc"
 	"github.com/ong2020/go-orange/consensus/ongash"
 	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/core/vm"
@@ -53,6 +55,21 @@ func TestSetupGenesis(t *testing.T) {
 		oldcustomg = customg
 	)
 	oldcustomg.Config = &params.ChainConfig{HomesteadBlock: big.NewInt(2)}
+
+	// Chains scheduling a fork by timestamp, at 25 and 35 seconds respectively
+	var (
+		oldtime, newtime = uint64(25), uint64(35)
+		payees           = []params.RewardPayee{{Address: common.Address{2}, Weight: 1}}
+		timeg            = customg
+		oldtimeg         = customg
+	)
+	timeg.Config = &params.ChainConfig{RewardPolicy: &params.RewardPolicyConfig{Time: &newtime, Policy: misc.RewardPolicySplit, Payees: payees}}
+	oldtimeg.Config = &params.ChainConfig{RewardPolicy: &params.RewardPolicyConfig{Time: &oldtime, Policy: misc.RewardPolicySplit, Payees: payees}}
+
+	// Chain scheduling the minimum gas price both by block and by timestamp
+	badtimeg := customg
+	badtimeg.Config = &params.ChainConfig{MinGasPrice: &params.MinGasPriceConfig{Block: big.NewInt(1), Time: &newtime, Price: big.NewInt(1)}}
+
 	tests := []struct {
 		name       string
 		fn         func(ongdb.Database) (*params.ChainConfig, common.Hash, error)
@@ -138,6 +155,40 @@ func TestSetupGenesis(t *testing.T) {
 				RewindTo:     1,
 			},
 		},
+		{
+			name: "incompatible timestamp config in DB",
+			fn: func(db ongdb.Database) (*params.ChainConfig, common.Hash, error) {
+				// Commit the 'old' genesis block with the policy activated at 25s.
+				// Advance to block #4 (40s), past the activation time of timeg.
+				genesis := oldtimeg.MustCommit(db)
+
+				bc, _ := NewBlockChain(db, nil, oldtimeg.Config, ongash.NewFullFaker(), vm.Config{}, nil, nil)
+				defer bc.Stop()
+
+				blocks, _ := GenerateChain(oldtimeg.Config, genesis, ongash.NewFaker(), db, 4, nil)
+				bc.InsertChain(blocks)
+				// This should return a compatibility error rewinding to block #2 (20s).
+				return SetupGenesisBlock(db, &timeg)
+			},
+			wantHash:   customghash,
+			wantConfig: timeg.Config,
+			wantErr: &params.ConfigCompatError{
+				What:         "reward policy timestamp",
+				StoredTime:   &oldtime,
+				NewTime:      &newtime,
+				RewindTo:     2,
+				RewindToTime: 24,
+			},
+		},
+		{
+			name: "config scheduled both by block and by timestamp",
+			fn: func(db ongdb.Database) (*params.ChainConfig, common.Hash, error) {
+				customg.MustCommit(db)
+				return SetupGenesisBlock(db, &badtimeg)
+			},
+			wantConfig: badtimeg.Config,
+			wantErr:    errors.New("minimum gas price scheduled both at block 1 and at timestamp 35"),
+		},
 	}
 
 	for _, test := range tests {
@@ -534,15 +534,11 @@ func ReadReceiptsRLP(db ongdb.Reader, hash common.Hash, number uint64) rlp.RawVa
 	// First try to look up the data in ancient database. Extra hash
 	// comparison is necessary since ancient database only maintains
 	// the canonical data.
-	data, _ := db.Ancient(freezerReceiptTable, number)
-	if len(data) > 0 {
-		h, _ := db.Ancient(freezerHashTable, number)
-		if common.BytesToHash(h) == hash {
-			return data
-		}
+	if data := readAncientReceiptsRLP(db, hash, number); len(data) > 0 {
+		return data
 	}
 	// Then try to look up the data in leveldb.
-	data, _ = db.Get(blockReceiptsKey(number, hash))
+	data, _ := db.Get(blockReceiptsKey(number, hash))
 	if len(data) > 0 {
 		return data
 	}
@@ -550,14 +546,23 @@ func ReadReceiptsRLP(db ongdb.Reader, hash common.Hash, number uint64) rlp.RawVa
 	// So during the first check for ancient db, the data is not yet in there,
 	// but when we reach into leveldb, the data was already moved. That would
 	// result in a not found error.
-	data, _ = db.Ancient(freezerReceiptTable, number)
-	if len(data) > 0 {
-		h, _ := db.Ancient(freezerHashTable, number)
-		if common.BytesToHash(h) == hash {
-			return data
-		}
+	return readAncientReceiptsRLP(db, hash, number)
+}
+
+// readAncientReceiptsRLP retrieves the receipts of a frozen block in RLP encoding,
+// preferring the repaired receipts overriding them if any.
+func readAncientReceiptsRLP(db ongdb.Reader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Ancient(freezerReceiptTable, number)
+	if len(data) == 0 {
+		return nil
 	}
-	return nil // Can't find the data anywhere.
+	if h, _ := db.Ancient(freezerHashTable, number); common.BytesToHash(h) != hash {
+		return nil
+	}
+	if repaired, _ := db.Get(repairedReceiptsKey(number, hash)); len(repaired) > 0 {
+		return repaired
+	}
+	return data
 }
 
 // ReadRawReceipts retrieves all the transaction receipts belonging to a block.
@@ -631,6 +636,22 @@ func DeleteReceipts(db ongdb.KeyValueWriter, hash common.Hash, number uint64) {
 	}
 }
 
+// WriteRepairedReceipts stores the transaction receipts of a frozen block in the
+// key-value store, overriding the immutable ones of the freezer.
+func WriteRepairedReceipts(db ongdb.KeyValueWriter, hash common.Hash, number uint64, receipts types.Receipts) {
+	storageReceipts := make([]*types.ReceiptForStorage, len(receipts))
+	for i, receipt := range receipts {
+		storageReceipts[i] = (*types.ReceiptForStorage)(receipt)
+	}
+	bytes, err := rlp.EncodeToBytes(storageReceipts)
+	if err != nil {
+		log.Crit("Failed to encode repaired block receipts", "err", err)
+	}
+	if err := db.Put(repairedReceiptsKey(number, hash), bytes); err != nil {
+		log.Crit("Failed to store repaired block receipts", "err", err)
+	}
+}
+
 // ReadBlock retrieves an entire block corresponding to the hash, assembling it
 // back from the stored header and body. If either the header or body could not
 // be retrieved nil is returned.
@@ -17,6 +17,7 @@
 package rawdb
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"time"
 
@@ -53,6 +54,31 @@ func WriteDatabaseVersion(db ongdb.KeyValueWriter, version uint64) {
 	}
 }
 
+// ReadNetworkID retrieves the network ID the database was created for.
+func ReadNetworkID(db ongdb.KeyValueReader) *uint64 {
+	var id uint64
+
+	enc, _ := db.Get(networkIDKey)
+	if len(enc) == 0 {
+		return nil
+	}
+	if err := rlp.DecodeBytes(enc, &id); err != nil {
+		return nil
+	}
+	return &id
+}
+
+// WriteNetworkID stores the network ID the database was created for.
+func WriteNetworkID(db ongdb.KeyValueWriter, id uint64) {
+	enc, err := rlp.EncodeToBytes(id)
+	if err != nil {
+		log.Crit("Failed to encode network ID", "err", err)
+	}
+	if err = db.Put(networkIDKey, enc); err != nil {
+		log.Crit("Failed to store the network ID", "err", err)
+	}
+}
+
 // ReadChainConfig retrieves the consensus settings based on the given genesis hash.
 func ReadChainConfig(db ongdb.KeyValueReader, hash common.Hash) *params.ChainConfig {
 	data, _ := db.Get(configKey(hash))
@@ -138,3 +164,111 @@ func PopUncleanShutdownMarker(db ongdb.KeyValueStore) {
 		log.Warn("Failed to clear unclean-shutdown marker", "err", err)
 	}
 }
+
+// ReadRelayNonce retrieves the next meta-transaction nonce of a sender of the
+// transaction relay.
+func ReadRelayNonce(db ongdb.KeyValueReader, sender common.Address) uint64 {
+	data, _ := db.Get(relayNonceKey(sender))
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteRelayNonce stores the next meta-transaction nonce of a sender of the
+// transaction relay.
+func WriteRelayNonce(db ongdb.KeyValueWriter, sender common.Address, nonce uint64) {
+	var enc [8]byte
+	binary.BigEndian.PutUint64(enc[:], nonce)
+	if err := db.Put(relayNonceKey(sender), enc[:]); err != nil {
+		log.Crit("Failed to store relay nonce", "err", err)
+	}
+}
+
+// ReadRelaySpendingJournal retrieves the persisted daily spending of the
+// transaction relay.
+func ReadRelaySpendingJournal(db ongdb.KeyValueReader) []byte {
+	data, _ := db.Get(relaySpendingKey)
+	return data
+}
+
+// WriteRelaySpendingJournal stores the daily spending of the transaction relay.
+func WriteRelaySpendingJournal(db ongdb.KeyValueWriter, blob []byte) {
+	if err := db.Put(relaySpendingKey, blob); err != nil {
+		log.Crit("Failed to store relay spending", "err", err)
+	}
+}
+
+// ExporterCursor is the last block exported by the chain exporter to a sink.
+type ExporterCursor struct {
+	Number uint64
+	Hash   common.Hash
+}
+
+// ReadExporterCursor retrieves the last block exported to the sink with the
+// given identifier hash, nil if nothing was exported yet.
+func ReadExporterCursor(db ongdb.KeyValueReader, sink common.Hash) *ExporterCursor {
+	data, _ := db.Get(exporterCursorKey(sink))
+	if len(data) == 0 {
+		return nil
+	}
+	cursor := new(ExporterCursor)
+	if err := rlp.DecodeBytes(data, cursor); err != nil {
+		log.Error("Invalid exporter cursor", "sink", sink, "err", err)
+		return nil
+	}
+	return cursor
+}
+
+// WriteExporterCursor stores the last block exported to the sink with the given
+// identifier hash.
+func WriteExporterCursor(db ongdb.KeyValueWriter, sink common.Hash, cursor *ExporterCursor) {
+	data, err := rlp.EncodeToBytes(cursor)
+	if err != nil {
+		log.Crit("Failed to encode exporter cursor", "err", err)
+	}
+	if err := db.Put(exporterCursorKey(sink), data); err != nil {
+		log.Crit("Failed to store exporter cursor", "err", err)
+	}
+}
+
+// ReadFilterJournal retrieves the persisted RPC filters.
+func ReadFilterJournal(db ongdb.Iteratee) [][]byte {
+	it := db.NewIterator(filterJournalPrefix, nil)
+	defer it.Release()
+
+	var filters [][]byte
+	for it.Next() {
+		if key := it.Key(); len(key) == len(filterJournalPrefix)+common.HashLength {
+			filters = append(filters, common.CopyBytes(it.Value()))
+		}
+	}
+	return filters
+}
+
+// WriteFilterJournalEntry stores the persisted RPC filter with the given id.
+func WriteFilterJournalEntry(db ongdb.KeyValueWriter, id string, blob []byte) {
+	if err := db.Put(filterJournalKey(id), blob); err != nil {
+		log.Crit("Failed to store persisted filter", "err", err)
+	}
+}
+
+// DeleteFilterJournalEntry removes the persisted RPC filter with the given id.
+func DeleteFilterJournalEntry(db ongdb.KeyValueWriter, id string) {
+	if err := db.Delete(filterJournalKey(id)); err != nil {
+		log.Crit("Failed to delete persisted filter", "err", err)
+	}
+}
+
+// ReadSpendGuardJournal retrieves the persisted state of the RPC spend guard.
+func ReadSpendGuardJournal(db ongdb.KeyValueReader) []byte {
+	data, _ := db.Get(spendGuardKey)
+	return data
+}
+
+// WriteSpendGuardJournal stores the state of the RPC spend guard.
+func WriteSpendGuardJournal(db ongdb.KeyValueWriter, blob []byte) {
+	if err := db.Put(spendGuardKey, blob); err != nil {
+		log.Crit("Failed to store spend guard state", "err", err)
+	}
+}
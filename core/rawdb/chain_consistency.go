@@ -0,0 +1,282 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/rlp"
+)
+
+// Kinds of chain inconsistencies detected by CheckChainConsistency.
+const (
+	IssueMissingCanonicalHash = "missing canonical hash" // Gap in the number->hash mappings
+	IssueMissingHeader        = "missing header"         // Canonical hash without header
+	IssueHeaderMismatch       = "header mismatch"        // Header doesn't match its canonical slot
+	IssueBrokenParentLink     = "broken parent link"     // Header's parent is not the previous canonical block
+	IssueMissingHashNumber    = "missing hash->number"   // Hash to number mapping absent or wrong
+	IssueMissingTd            = "missing difficulty"     // Total difficulty absent
+	IssueMissingBody          = "missing body"           // Block body absent below the head block
+	IssueMissingReceipts      = "missing receipts"       // Receipts absent below the head block
+	IssueReceiptCount         = "receipt count mismatch" // Receipts don't match the transactions
+	IssueMissingTxIndex       = "missing tx index"       // Transaction lookup absent or wrong above the index tail
+	IssueDanglingCanonical    = "dangling canonical"     // Canonical mapping above the head header
+	IssueFreezerAhead         = "freezer ahead of head"  // Ancients beyond the head header
+	IssueInvalidHead          = "invalid head marker"    // Head marker pointing to unknown data
+)
+
+// ConsistencyIssue is a single inconsistency found in the chain data.
+type ConsistencyIssue struct {
+	Kind   string      // Kind of the inconsistency, one of the Issue* constants
+	Number uint64      // Block number the inconsistency was found at
+	Hash   common.Hash // Block hash if known, zero otherwise
+	Detail string      // Human readable details
+	Fixed  bool        // Whether the issue was fixed automatically
+}
+
+// ConsistencyReport is the result of a chain data consistency check.
+type ConsistencyReport struct {
+	From, To  uint64              // Range of blocks checked
+	HeadBlock uint64              // Number of the head full block
+	Frozen    uint64              // Number of items in the freezer
+	TxTail    *uint64             // Oldest block with indexed transactions, nil if not limited
+	Issues    []*ConsistencyIssue // Inconsistencies found
+}
+
+// Fixed returns the number of issues that were automatically fixed.
+func (r *ConsistencyReport) Fixed() int {
+	var fixed int
+	for _, issue := range r.Issues {
+		if issue.Fixed {
+			fixed++
+		}
+	}
+	return fixed
+}
+
+// Summary returns the number of issues found per kind.
+func (r *ConsistencyReport) Summary() map[string]int {
+	summary := make(map[string]int)
+	for _, issue := range r.Issues {
+		summary[issue.Kind]++
+	}
+	return summary
+}
+
+// consistencyChecker accumulates the issues and fixes of a consistency check.
+type consistencyChecker struct {
+	db     ongdb.Database
+	report *ConsistencyReport
+	batch  ongdb.Batch // Batch accumulating fixes, nil if fixing is disabled
+}
+
+// issue records an inconsistency, applying the fix if one is available and
+// fixing is enabled.
+func (c *consistencyChecker) issue(kind string, number uint64, hash common.Hash, fix func(ongdb.KeyValueWriter), format string, args ...interface{}) {
+	issue := &ConsistencyIssue{
+		Kind:   kind,
+		Number: number,
+		Hash:   hash,
+		Detail: fmt.Sprintf(format, args...),
+	}
+	if fix != nil && c.batch != nil {
+		fix(c.batch)
+		issue.Fixed = true
+	}
+	c.report.Issues = append(c.report.Issues, issue)
+}
+
+// flush writes out the accumulated fixes if the batch grew large enough, or
+// unconditionally if forced.
+func (c *consistencyChecker) flush(force bool) error {
+	if c.batch == nil || (!force && c.batch.ValueSize() < ongdb.IdealBatchSize) {
+		return nil
+	}
+	if err := c.batch.Write(); err != nil {
+		return err
+	}
+	c.batch.Reset()
+	return nil
+}
+
+// CheckChainConsistency walks the canonical chain in the [from, to] range (the
+// end being capped to the head header) and cross checks the headers, bodies,
+// receipts, total difficulties, transaction indices and canonical mappings,
+// as well as the freezer boundaries and the head markers.
+//
+// If fix is set, inconsistencies that can be safely repaired from other data in
+// the database (mappings and indices) are fixed. Missing chain data is only ever
+// reported, never fabricated.
+func CheckChainConsistency(db ongdb.Database, from, to uint64, fix bool) (*ConsistencyReport, error) {
+	headHeaderHash := ReadHeadHeaderHash(db)
+	headHeaderNumber := ReadHeaderNumber(db, headHeaderHash)
+	if headHeaderNumber == nil {
+		return nil, errors.New("head header unknown")
+	}
+	report := &ConsistencyReport{
+		From:   from,
+		To:     to,
+		TxTail: ReadTxIndexTail(db),
+	}
+	if report.To > *headHeaderNumber {
+		report.To = *headHeaderNumber
+	}
+	if report.From > report.To {
+		return nil, fmt.Errorf("invalid block range [%d, %d]", from, report.To)
+	}
+	checker := &consistencyChecker{db: db, report: report}
+	if fix {
+		checker.batch = db.NewBatch()
+	}
+	// Validate the head markers and freezer boundaries
+	if number := ReadHeaderNumber(db, ReadHeadBlockHash(db)); number == nil {
+		checker.issue(IssueInvalidHead, 0, ReadHeadBlockHash(db), nil, "head block unknown")
+	} else {
+		report.HeadBlock = *number
+		if *number > *headHeaderNumber {
+			checker.issue(IssueInvalidHead, *number, ReadHeadBlockHash(db), nil, "head block above head header #%d", *headHeaderNumber)
+		}
+	}
+	if hash := ReadHeadFastBlockHash(db); hash != (common.Hash{}) {
+		if number := ReadHeaderNumber(db, hash); number == nil {
+			checker.issue(IssueInvalidHead, 0, hash, nil, "head fast block unknown")
+		}
+	}
+	if frozen, err := db.Ancients(); err == nil {
+		report.Frozen = frozen
+		if frozen > 0 && frozen-1 > *headHeaderNumber {
+			checker.issue(IssueFreezerAhead, frozen-1, common.Hash{}, nil, "freezer contains %d items, head header is #%d", frozen, *headHeaderNumber)
+		}
+	}
+	// Walk the canonical chain and cross reference all the data
+	var (
+		start  = time.Now()
+		logged = time.Now()
+		parent common.Hash
+	)
+	if report.From > 0 {
+		parent = ReadCanonicalHash(db, report.From-1)
+	}
+	for number := report.From; number <= report.To; number++ {
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Checking chain consistency", "number", number, "target", report.To, "issues", len(report.Issues), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		if err := checker.checkBlock(number, parent); err != nil {
+			return nil, err
+		}
+		parent = ReadCanonicalHash(db, number)
+
+		if err := checker.flush(false); err != nil {
+			return nil, err
+		}
+	}
+	// Look for canonical mappings left behind above the head header
+	if *headHeaderNumber < math.MaxUint64 {
+		numbers, hashes := ReadAllCanonicalHashes(db, *headHeaderNumber+1, math.MaxUint64, math.MaxInt32)
+		for i, number := range numbers {
+			number := number
+			checker.issue(IssueDanglingCanonical, number, hashes[i], func(w ongdb.KeyValueWriter) {
+				DeleteCanonicalHash(w, number)
+			}, "canonical mapping above head header #%d", *headHeaderNumber)
+		}
+	}
+	if err := checker.flush(true); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// checkBlock cross checks all the data stored for a single canonical block.
+func (c *consistencyChecker) checkBlock(number uint64, parent common.Hash) error {
+	hash := ReadCanonicalHash(c.db, number)
+	if hash == (common.Hash{}) {
+		c.issue(IssueMissingCanonicalHash, number, hash, nil, "no canonical hash")
+		return nil
+	}
+	header := ReadHeader(c.db, hash, number)
+	if header == nil {
+		c.issue(IssueMissingHeader, number, hash, nil, "canonical header not found")
+		return nil
+	}
+	if header.Number.Uint64() != number || header.Hash() != hash {
+		c.issue(IssueHeaderMismatch, number, hash, nil, "stored header is #%d [%x]", header.Number, header.Hash())
+		return nil
+	}
+	if number > 0 && parent != (common.Hash{}) && header.ParentHash != parent {
+		c.issue(IssueBrokenParentLink, number, hash, nil, "parent %x, canonical parent %x", header.ParentHash, parent)
+	}
+	if stored := ReadHeaderNumber(c.db, hash); stored == nil || *stored != number {
+		c.issue(IssueMissingHashNumber, number, hash, func(w ongdb.KeyValueWriter) {
+			WriteHeaderNumber(w, hash, number)
+		}, "hash->number mapping absent or wrong")
+	}
+	if ReadTd(c.db, hash, number) == nil {
+		// The difficulty can be recomputed if the parent's is available
+		var fix func(ongdb.KeyValueWriter)
+		if number > 0 {
+			if ptd := ReadTd(c.db, header.ParentHash, number-1); ptd != nil {
+				td := new(big.Int).Add(ptd, header.Difficulty)
+				fix = func(w ongdb.KeyValueWriter) { WriteTd(w, hash, number, td) }
+			}
+		}
+		c.issue(IssueMissingTd, number, hash, fix, "total difficulty not found")
+	}
+	// Bodies, receipts and indices are only expected up to the head block
+	if number > c.report.HeadBlock {
+		return nil
+	}
+	body := ReadBody(c.db, hash, number)
+	if body == nil {
+		c.issue(IssueMissingBody, number, hash, nil, "block body not found")
+		return nil
+	}
+	if blob := ReadReceiptsRLP(c.db, hash, number); len(blob) == 0 {
+		c.issue(IssueMissingReceipts, number, hash, nil, "block receipts not found, run repair-receipts")
+	} else if count, err := rlp.CountValues(rlpListContent(blob)); err != nil || count != len(body.Transactions) {
+		c.issue(IssueReceiptCount, number, hash, nil, "%d receipts for %d transactions", count, len(body.Transactions))
+	}
+	// Genesis transactions can't be indexed (empty number encoding), skip them
+	if tail := c.report.TxTail; number > 0 && (tail == nil || number >= *tail) {
+		for _, tx := range body.Transactions {
+			if stored := ReadTxLookupEntry(c.db, tx.Hash()); stored == nil || *stored != number {
+				txhash := tx.Hash()
+				c.issue(IssueMissingTxIndex, number, hash, func(w ongdb.KeyValueWriter) {
+					WriteTxLookupEntries(w, number, []common.Hash{txhash})
+				}, "transaction %x not indexed", tx.Hash())
+			}
+		}
+	}
+	return nil
+}
+
+// rlpListContent returns the content of an RLP list, or the input itself if it
+// isn't a well formed list.
+func rlpListContent(blob []byte) []byte {
+	content, _, err := rlp.SplitList(blob)
+	if err != nil {
+		return blob
+	}
+	return content
+}
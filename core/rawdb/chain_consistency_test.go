@@ -0,0 +1,91 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/types"
+)
+
+// Tests that chain inconsistencies are detected and the safe ones fixed.
+func TestChainConsistency(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	var blocks []*types.Block
+	for i := uint64(0); i <= 10; i++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(i), Difficulty: big.NewInt(1)}
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		tx := types.NewTransaction(i, common.BytesToAddress([]byte{0x11}), big.NewInt(111), 1111, big.NewInt(11111), nil)
+		block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil, newHasher())
+
+		WriteBlock(db, block)
+		WriteCanonicalHash(db, block.Hash(), i)
+		WriteTd(db, block.Hash(), i, new(big.Int).SetUint64(i+1))
+		WriteReceipts(db, block.Hash(), i, types.Receipts{{CumulativeGasUsed: 1}})
+		WriteTxLookupEntriesByBlock(db, block)
+		blocks = append(blocks, block)
+	}
+	head := blocks[len(blocks)-1]
+	WriteHeadHeaderHash(db, head.Hash())
+	WriteHeadBlockHash(db, head.Hash())
+
+	report, err := CheckChainConsistency(db, 0, math.MaxUint64, false)
+	if err != nil {
+		t.Fatalf("failed to check consistent chain: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Fatalf("consistent chain reported issues: %v", report.Summary())
+	}
+	// Break the database in various ways
+	DeleteTxLookupEntry(db, blocks[3].Transactions()[0].Hash())
+	DeleteTd(db, blocks[5].Hash(), 5)
+	DeleteReceipts(db, blocks[7].Hash(), 7)
+	WriteCanonicalHash(db, common.Hash{0x01}, 12)
+
+	want := map[string]int{
+		IssueMissingTxIndex:    1,
+		IssueMissingTd:         1,
+		IssueMissingReceipts:   1,
+		IssueDanglingCanonical: 1,
+	}
+	if report, err = CheckChainConsistency(db, 0, math.MaxUint64, true); err != nil {
+		t.Fatalf("failed to check broken chain: %v", err)
+	}
+	if summary := report.Summary(); !reflect.DeepEqual(summary, want) {
+		t.Fatalf("issue summary mismatch: have %v, want %v", summary, want)
+	}
+	if fixed := report.Fixed(); fixed != 3 {
+		t.Fatalf("fixed issue count mismatch: have %d, want %d", fixed, 3)
+	}
+	// Only the unfixable issues should remain
+	if report, err = CheckChainConsistency(db, 0, math.MaxUint64, false); err != nil {
+		t.Fatalf("failed to check fixed chain: %v", err)
+	}
+	if summary := report.Summary(); !reflect.DeepEqual(summary, map[string]int{IssueMissingReceipts: 1}) {
+		t.Fatalf("issue summary mismatch after fix: have %v", summary)
+	}
+	if td := ReadTd(db, blocks[5].Hash(), 5); td == nil || td.Uint64() != 6 {
+		t.Fatalf("recomputed difficulty mismatch: have %v, want 6", td)
+	}
+}
@@ -120,10 +120,11 @@ func NewDatabase(db ongdb.KeyValueStore) ongdb.Database {
 
 // NewDatabaseWithFreezer creates a high level database on top of a given key-
 // value data store with a freezer moving immutable chain segments into cold
-// storage.
-func NewDatabaseWithFreezer(db ongdb.KeyValueStore, freezer string, namespace string) (ongdb.Database, error) {
+// storage. The compression algorithms of the new freezer tables can be overridden
+// by table name (nil = defaults).
+func NewDatabaseWithFreezer(db ongdb.KeyValueStore, freezer string, namespace string, compression map[string]string) (ongdb.Database, error) {
 	// Create the idle freezer instance
-	frdb, err := newFreezer(freezer, namespace)
+	frdb, err := newFreezer(freezer, namespace, compression)
 	if err != nil {
 		return nil, err
 	}
@@ -220,17 +221,18 @@ func NewLevelDBDatabase(file string, cache int, handles int, namespace string) (
 	if err != nil {
 		return nil, err
 	}
-	return NewDatabase(db), nil
+	return NewDatabase(newSizeTracker(db)), nil
 }
 
 // NewLevelDBDatabaseWithFreezer creates a persistent key-value database with a
-// freezer moving immutable chain segments into cold storage.
-func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer string, namespace string) (ongdb.Database, error) {
+// freezer moving immutable chain segments into cold storage. The compression
+// algorithms of the new freezer tables can be overridden by table name.
+func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer string, namespace string, compression map[string]string) (ongdb.Database, error) {
 	kvdb, err := leveldb.New(file, cache, handles, namespace)
 	if err != nil {
 		return nil, err
 	}
-	frdb, err := NewDatabaseWithFreezer(kvdb, freezer, namespace)
+	frdb, err := NewDatabaseWithFreezer(newSizeTracker(kvdb), freezer, namespace, compression)
 	if err != nil {
 		kvdb.Close()
 		return nil, err
@@ -279,21 +281,9 @@ func InspectDatabase(db ongdb.Database, keyPrefix, keyStart []byte) error {
 		start  = time.Now()
 		logged = time.Now()
 
-		// Key-value store statistics
-		headers         stat
-		bodies          stat
-		receipts        stat
-		tds             stat
-		numHashPairings stat
-		hashNumPairings stat
-		tries           stat
-		codes           stat
-		txLookups       stat
-		accountSnaps    stat
-		storageSnaps    stat
-		preimages       stat
-		bloomBits       stat
-		cliqueSnaps     stat
+		// Key-value store statistics, per schema category
+		stats      = make(map[string]*stat)
+		categories = inspectCategories()
 
 		// Ancient store statistics
 		ancientHeadersSize  common.StorageSize
@@ -302,17 +292,14 @@ func InspectDatabase(db ongdb.Database, keyPrefix, keyStart []byte) error {
 		ancientTdsSize      common.StorageSize
 		ancientHashesSize   common.StorageSize
 
-		// Les statistic
-		chtTrieNodes   stat
-		bloomTrieNodes stat
-
-		// Meta- and unaccounted data
-		metadata     stat
-		unaccounted  stat
-		shutdownInfo stat
+		// Unaccounted data
+		unaccounted stat
 
 		// Totals
 		total common.StorageSize
+
+		// Exact tally to reseed the incremental size accounting with
+		tracked = make(map[string]int64)
 	)
 	// Inspect key-value database first.
 	for it.Next() {
@@ -321,64 +308,21 @@ func InspectDatabase(db ongdb.Database, keyPrefix, keyStart []byte) error {
 			size = common.StorageSize(len(key) + len(it.Value()))
 		)
 		total += size
-		switch {
-		case bytes.HasPrefix(key, headerPrefix) && len(key) == (len(headerPrefix)+8+common.HashLength):
-			headers.Add(size)
-		case bytes.HasPrefix(key, blockBodyPrefix) && len(key) == (len(blockBodyPrefix)+8+common.HashLength):
-			bodies.Add(size)
-		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == (len(blockReceiptsPrefix)+8+common.HashLength):
-			receipts.Add(size)
-		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerTDSuffix):
-			tds.Add(size)
-		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerHashSuffix):
-			numHashPairings.Add(size)
-		case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == (len(headerNumberPrefix)+common.HashLength):
-			hashNumPairings.Add(size)
-		case len(key) == common.HashLength:
-			tries.Add(size)
-		case bytes.HasPrefix(key, CodePrefix) && len(key) == len(CodePrefix)+common.HashLength:
-			codes.Add(size)
-		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == (len(txLookupPrefix)+common.HashLength):
-			txLookups.Add(size)
-		case bytes.HasPrefix(key, SnapshotAccountPrefix) && len(key) == (len(SnapshotAccountPrefix)+common.HashLength):
-			accountSnaps.Add(size)
-		case bytes.HasPrefix(key, SnapshotStoragePrefix) && len(key) == (len(SnapshotStoragePrefix)+2*common.HashLength):
-			storageSnaps.Add(size)
-		case bytes.HasPrefix(key, preimagePrefix) && len(key) == (len(preimagePrefix)+common.HashLength):
-			preimages.Add(size)
-		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == (len(bloomBitsPrefix)+10+common.HashLength):
-			bloomBits.Add(size)
-		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
-			bloomBits.Add(size)
-		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
-			cliqueSnaps.Add(size)
-		case bytes.HasPrefix(key, []byte("cht-")) ||
-			bytes.HasPrefix(key, []byte("chtIndexV2-")) ||
-			bytes.HasPrefix(key, []byte("chtRootV2-")): // Canonical hash trie
-			chtTrieNodes.Add(size)
-		case bytes.HasPrefix(key, []byte("blt-")) ||
-			bytes.HasPrefix(key, []byte("bltIndex-")) ||
-			bytes.HasPrefix(key, []byte("bltRoot-")): // Bloomtrie sub
-			bloomTrieNodes.Add(size)
-		case bytes.Equal(key, uncleanShutdownKey):
-			shutdownInfo.Add(size)
-		default:
-			var accounted bool
-			for _, meta := range [][]byte{
-				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, lastPivotKey,
-				fastTrieProgressKey, snapshotRootKey, snapshotJournalKey, snapshotGeneratorKey,
-				snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey, uncleanShutdownKey,
-				badBlockKey,
-			} {
-				if bytes.Equal(key, meta) {
-					metadata.Add(size)
-					accounted = true
-					break
-				}
-			}
-			if !accounted {
-				unaccounted.Add(size)
+
+		schema := MatchKeySchema(key)
+		category := sizeCategories[sizeCategoryOf(schema)]
+		tracked[category] += int64(size)
+		tracked[itemsKey(category)]++
+
+		if schema == nil {
+			unaccounted.Add(size)
+		} else {
+			st, ok := stats[schema.Store+"/"+schema.Category]
+			if !ok {
+				st = new(stat)
+				stats[schema.Store+"/"+schema.Category] = st
 			}
+			st.Add(size)
 		}
 		count++
 		if count%1000 == 0 && time.Since(logged) > 8*time.Second {
@@ -400,40 +344,46 @@ func InspectDatabase(db ongdb.Database, keyPrefix, keyStart []byte) error {
 		ancients = counter(count)
 	}
 	// Display the database statistic.
-	stats := [][]string{
-		{"Key-Value store", "Headers", headers.Size(), headers.Count()},
-		{"Key-Value store", "Bodies", bodies.Size(), bodies.Count()},
-		{"Key-Value store", "Receipt lists", receipts.Size(), receipts.Count()},
-		{"Key-Value store", "Difficulties", tds.Size(), tds.Count()},
-		{"Key-Value store", "Block number->hash", numHashPairings.Size(), numHashPairings.Count()},
-		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
-		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
-		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
-		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
-		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
-		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
-		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
-		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
-		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
-		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
-		{"Key-Value store", "Shutdown metadata", shutdownInfo.Size(), shutdownInfo.Count()},
-		{"Ancient store", "Headers", ancientHeadersSize.String(), ancients.String()},
-		{"Ancient store", "Bodies", ancientBodiesSize.String(), ancients.String()},
-		{"Ancient store", "Receipt lists", ancientReceiptsSize.String(), ancients.String()},
-		{"Ancient store", "Difficulties", ancientTdsSize.String(), ancients.String()},
-		{"Ancient store", "Block number->hash", ancientHashesSize.String(), ancients.String()},
-		{"Light client", "CHT trie nodes", chtTrieNodes.Size(), chtTrieNodes.Count()},
-		{"Light client", "Bloom trie nodes", bloomTrieNodes.Size(), bloomTrieNodes.Count()},
+	var rows [][]string
+	for _, store := range []string{storeKeyValue, storeLight} {
+		if store == storeLight {
+			rows = append(rows, [][]string{
+				{"Ancient store", "Headers", ancientHeadersSize.String(), ancients.String()},
+				{"Ancient store", "Bodies", ancientBodiesSize.String(), ancients.String()},
+				{"Ancient store", "Receipt lists", ancientReceiptsSize.String(), ancients.String()},
+				{"Ancient store", "Difficulties", ancientTdsSize.String(), ancients.String()},
+				{"Ancient store", "Block number->hash", ancientHashesSize.String(), ancients.String()},
+			}...)
+		}
+		for _, schema := range categories {
+			if schema.Store != store {
+				continue
+			}
+			st := stats[schema.Store+"/"+schema.Category]
+			if st == nil {
+				st = new(stat)
+			}
+			rows = append(rows, []string{schema.Store, schema.Category, st.Size(), st.Count()})
+		}
 	}
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Database", "Category", "Size", "Items"})
 	table.SetFooter([]string{"", "Total", total.String(), " "})
-	table.AppendBulk(stats)
+	table.AppendBulk(rows)
 	table.Render()
 
 	if unaccounted.size > 0 {
 		log.Error("Database contains unaccounted data", "size", unaccounted.size, "count", unaccounted.count)
 	}
+	// If the whole database was inspected, reseed the incremental size accounting
+	if len(keyPrefix) == 0 && len(keyStart) == 0 {
+		if tracker := sizeTrackerOf(db); tracker != nil {
+			tracker.reset(tracked)
+		}
+		if err := WriteDatabaseSizes(db, tracked); err != nil {
+			log.Error("Failed to store database sizes", "err", err)
+		}
+	}
 
 	return nil
 }
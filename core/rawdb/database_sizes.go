@@ -0,0 +1,346 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/ongdb"
+)
+
+// Data categories tracked by the size accounting. The names are part of the
+// debug_dbSizes RPC output, so they should not be changed lightly.
+const (
+	SizeCategoryHeaders   = "headers"
+	SizeCategoryBodies    = "bodies"
+	SizeCategoryReceipts  = "receipts"
+	SizeCategoryChainMeta = "chainIndex" // difficulties and number<->hash mappings
+	SizeCategoryTxIndex   = "txIndex"
+	SizeCategoryState     = "state" // trie nodes, contract codes and preimages
+	SizeCategorySnapshot  = "snapshot"
+	SizeCategoryBloomBits = "bloomBits"
+	SizeCategoryLes       = "les"
+	SizeCategoryMetadata  = "metadata"
+	SizeCategoryOther     = "other"
+
+	// Ancient store categories, derived from the freezer tables directly.
+	SizeCategoryAncientHeaders  = "ancientHeaders"
+	SizeCategoryAncientBodies   = "ancientBodies"
+	SizeCategoryAncientReceipts = "ancientReceipts"
+	SizeCategoryAncientHashes   = "ancientHashes"
+	SizeCategoryAncientDiffs    = "ancientDiffs"
+)
+
+// sizeCategories is the list of key-value store categories, in the order the
+// tracker stores their counters.
+var sizeCategories = []string{
+	SizeCategoryHeaders, SizeCategoryBodies, SizeCategoryReceipts, SizeCategoryChainMeta,
+	SizeCategoryTxIndex, SizeCategoryState, SizeCategorySnapshot, SizeCategoryBloomBits,
+	SizeCategoryLes, SizeCategoryMetadata, SizeCategoryOther,
+}
+
+// ancientSizeCategories maps the freezer tables to their reported categories.
+var ancientSizeCategories = map[string]string{
+	freezerHeaderTable:     SizeCategoryAncientHeaders,
+	freezerBodiesTable:     SizeCategoryAncientBodies,
+	freezerReceiptTable:    SizeCategoryAncientReceipts,
+	freezerHashTable:       SizeCategoryAncientHashes,
+	freezerDifficultyTable: SizeCategoryAncientDiffs,
+}
+
+// sizeCategoryOther is the index of the size category of unknown keys.
+var sizeCategoryOther = sizeCategoryIndex(SizeCategoryOther)
+
+// sizeCategoryIndex returns the index of a size category in the tracker.
+func sizeCategoryIndex(category string) int {
+	for i, name := range sizeCategories {
+		if name == category {
+			return i
+		}
+	}
+	panic("unknown size category " + category)
+}
+
+// sizeCategoryOf returns the index of the size category of a table of the key
+// schema registry, or the one of unknown keys if nil.
+func sizeCategoryOf(schema *KeySchema) int {
+	if schema == nil {
+		return sizeCategoryOther
+	}
+	return schema.sizeCategory
+}
+
+// classifyKey returns the index of the size category a database key belongs to,
+// as registered in the key schema registry.
+func classifyKey(key []byte) int {
+	return sizeCategoryOf(MatchKeySchema(key))
+}
+
+// ReadDatabaseSizes retrieves the persisted per-category storage accounting,
+// along with the per-category item counts.
+func ReadDatabaseSizes(db ongdb.KeyValueReader) map[string]int64 {
+	data, _ := db.Get(databaseSizesKey)
+	if len(data) == 0 {
+		return nil
+	}
+	var sizes map[string]int64
+	if err := json.Unmarshal(data, &sizes); err != nil {
+		log.Error("Invalid database sizes JSON", "err", err)
+		return nil
+	}
+	return sizes
+}
+
+// WriteDatabaseSizes stores the per-category storage accounting.
+func WriteDatabaseSizes(db ongdb.KeyValueWriter, sizes map[string]int64) error {
+	data, err := json.Marshal(sizes)
+	if err != nil {
+		return err
+	}
+	return db.Put(databaseSizesKey, data)
+}
+
+// itemsKey is the key of the item count of a category in the persisted tally.
+func itemsKey(category string) string {
+	return category + ".items"
+}
+
+// sizeTracker is a key-value store wrapper that keeps a running tally of the
+// bytes stored in each data category, updated incrementally as data is written
+// and deleted.
+//
+// The tally is an approximation of the on-disk usage: it counts raw key and
+// value lengths (ignoring compression and compaction debt) and overwrites of
+// existing keys are accounted twice. Deletions aren't looked up, they free the
+// average item size of their category instead. Running a full database
+// inspection reseeds the counters with exact values.
+//
+// The counters are updated atomically to keep locks off the write path. The
+// deleted items are claimed from the item count before their size is freed, so
+// concurrent deletions never free more items than accounted, but readers may
+// see the size of a deletion freed an instant after its items.
+type sizeTracker struct {
+	ongdb.KeyValueStore
+	sizes []int64 // Running sizes per category, accessed atomically
+	items []int64 // Running item counts per category, accessed atomically
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newSizeTracker wraps a key-value store with size accounting, loading any
+// previously persisted tally from the store itself.
+func newSizeTracker(db ongdb.KeyValueStore) *sizeTracker {
+	t := &sizeTracker{
+		KeyValueStore: db,
+		sizes:         make([]int64, len(sizeCategories)),
+		items:         make([]int64, len(sizeCategories)),
+	}
+	t.reset(ReadDatabaseSizes(db))
+	return t
+}
+
+// reset overrides the running counters with the given tally.
+func (t *sizeTracker) reset(sizes map[string]int64) {
+	for i, name := range sizeCategories {
+		atomic.StoreInt64(&t.sizes[i], sizes[name])
+		atomic.StoreInt64(&t.items[i], sizes[itemsKey(name)])
+	}
+}
+
+// added records items written in the given category.
+func (t *sizeTracker) added(category int, size, items int64) {
+	atomic.AddInt64(&t.sizes[category], size)
+	atomic.AddInt64(&t.items[category], items)
+}
+
+// removed records items deleted from the given category, estimating the freed
+// size from the average item size of the category to avoid reading the items.
+func (t *sizeTracker) removed(category int, items int64) {
+	for items > 0 {
+		have := atomic.LoadInt64(&t.items[category])
+		if items > have {
+			items = have // deleted items not accounted, e.g. deleted twice
+		}
+		if items <= 0 {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&t.items[category], have, have-items) {
+			atomic.AddInt64(&t.sizes[category], -atomic.LoadInt64(&t.sizes[category])/have*items)
+			return
+		}
+	}
+}
+
+// persisted returns the tally to store in the database: the sizes along with
+// the item counts the deletions are estimated from.
+func (t *sizeTracker) persisted() map[string]int64 {
+	sizes := t.snapshot()
+	for i, name := range sizeCategories {
+		if items := atomic.LoadInt64(&t.items[i]); items > 0 {
+			sizes[itemsKey(name)] = items
+		}
+	}
+	return sizes
+}
+
+// snapshot returns a copy of the current size counters.
+func (t *sizeTracker) snapshot() map[string]int64 {
+	sizes := make(map[string]int64, len(sizeCategories))
+	for i, name := range sizeCategories {
+		if size := atomic.LoadInt64(&t.sizes[i]); size > 0 {
+			sizes[name] = size
+		}
+	}
+	return sizes
+}
+
+// Put inserts the given value into the key-value store, accounting for its size.
+func (t *sizeTracker) Put(key []byte, value []byte) error {
+	if err := t.KeyValueStore.Put(key, value); err != nil {
+		return err
+	}
+	t.added(classifyKey(key), int64(len(key)+len(value)), 1)
+	return nil
+}
+
+// Delete removes the key from the key-value store, accounting for the freed size.
+func (t *sizeTracker) Delete(key []byte) error {
+	if err := t.KeyValueStore.Delete(key); err != nil {
+		return err
+	}
+	t.removed(classifyKey(key), 1)
+	return nil
+}
+
+// NewBatch creates a write-only database that buffers changes to its host db
+// until a final write is called, accounting for the sizes once written.
+func (t *sizeTracker) NewBatch() ongdb.Batch {
+	return &sizeTrackerBatch{
+		Batch:   t.KeyValueStore.NewBatch(),
+		tracker: t,
+		sizes:   make([]int64, len(sizeCategories)),
+		puts:    make([]int64, len(sizeCategories)),
+		deletes: make([]int64, len(sizeCategories)),
+	}
+}
+
+// Close persists the current tally and closes the underlying store. Closing the
+// tracker more than once is a no-op returning the result of the first close.
+func (t *sizeTracker) Close() error {
+	t.closeOnce.Do(func() {
+		if err := WriteDatabaseSizes(t.KeyValueStore, t.persisted()); err != nil {
+			log.Error("Failed to store database sizes", "err", err)
+		}
+		t.closeErr = t.KeyValueStore.Close()
+	})
+	return t.closeErr
+}
+
+// sizeTrackerBatch is a wrapper around a database batch that collects the size
+// changes of the queued operations and applies them to the tracker on write.
+type sizeTrackerBatch struct {
+	ongdb.Batch
+	tracker *sizeTracker
+	sizes   []int64 // Sizes of the queued writes per category
+	puts    []int64 // Number of queued writes per category
+	deletes []int64 // Number of queued deletions per category
+}
+
+// Put inserts the given value into the batch for later committing.
+func (b *sizeTrackerBatch) Put(key, value []byte) error {
+	if err := b.Batch.Put(key, value); err != nil {
+		return err
+	}
+	category := classifyKey(key)
+	b.sizes[category] += int64(len(key) + len(value))
+	b.puts[category]++
+	return nil
+}
+
+// Delete inserts the a key removal into the batch for later committing.
+func (b *sizeTrackerBatch) Delete(key []byte) error {
+	if err := b.Batch.Delete(key); err != nil {
+		return err
+	}
+	b.deletes[classifyKey(key)]++
+	return nil
+}
+
+// Write flushes any accumulated data to disk and updates the size accounting.
+func (b *sizeTrackerBatch) Write() error {
+	if err := b.Batch.Write(); err != nil {
+		return err
+	}
+	for i := range sizeCategories {
+		b.tracker.added(i, b.sizes[i], b.puts[i])
+		b.tracker.removed(i, b.deletes[i])
+	}
+	b.clear()
+	return nil
+}
+
+// Reset resets the batch for reuse.
+func (b *sizeTrackerBatch) Reset() {
+	b.Batch.Reset()
+	b.clear()
+}
+
+// clear drops the queued size changes.
+func (b *sizeTrackerBatch) clear() {
+	for i := range sizeCategories {
+		b.sizes[i], b.puts[i], b.deletes[i] = 0, 0, 0
+	}
+}
+
+// sizeTrackerOf returns the size tracker backing the given database, or nil if
+// the database was not opened with size accounting.
+func sizeTrackerOf(db interface{}) *sizeTracker {
+	switch db := db.(type) {
+	case *sizeTracker:
+		return db
+	case *freezerdb:
+		return sizeTrackerOf(db.KeyValueStore)
+	case *nofreezedb:
+		return sizeTrackerOf(db.KeyValueStore)
+	case *table:
+		return sizeTrackerOf(db.db)
+	}
+	return nil
+}
+
+// DatabaseSizes returns the approximate storage usage of each data category in
+// the database, including the ancient store tables. The key-value categories
+// are only available if the database was opened with size accounting enabled
+// (i.e. a persistent database).
+func DatabaseSizes(db ongdb.Database) map[string]uint64 {
+	sizes := make(map[string]uint64)
+	if tracker := sizeTrackerOf(db); tracker != nil {
+		for name, size := range tracker.snapshot() {
+			sizes[name] = uint64(size)
+		}
+	}
+	for kind, name := range ancientSizeCategories {
+		if size, err := db.AncientSize(kind); err == nil {
+			sizes[name] = size
+		}
+	}
+	return sizes
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/ongdb/memorydb"
+)
+
+// Tests that the size tracker accounts writes and deletes into the correct
+// categories, both for direct and batched operations.
+func TestDatabaseSizeTracking(t *testing.T) {
+	kvdb := memorydb.New()
+	db := NewDatabase(newSizeTracker(kvdb))
+
+	hash := common.Hash{0x01}
+	header := headerKey(1, hash)
+	body := blockBodyKey(1, hash)
+	lookup := txLookupKey(hash)
+
+	db.Put(header, make([]byte, 100))
+	batch := db.NewBatch()
+	batch.Put(body, make([]byte, 200))
+	batch.Put(lookup, make([]byte, 8))
+
+	// Batched data must only be accounted once written
+	if size := DatabaseSizes(db)[SizeCategoryBodies]; size != 0 {
+		t.Fatalf("unwritten batch accounted: have %d, want 0", size)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	sizes := DatabaseSizes(db)
+	if have, want := sizes[SizeCategoryHeaders], uint64(len(header)+100); have != want {
+		t.Errorf("header size mismatch: have %d, want %d", have, want)
+	}
+	if have, want := sizes[SizeCategoryBodies], uint64(len(body)+200); have != want {
+		t.Errorf("body size mismatch: have %d, want %d", have, want)
+	}
+	if have, want := sizes[SizeCategoryTxIndex], uint64(len(lookup)+8); have != want {
+		t.Errorf("tx index size mismatch: have %d, want %d", have, want)
+	}
+	// Deletions should free up the previously accounted size
+	db.Delete(header)
+	if size := DatabaseSizes(db)[SizeCategoryHeaders]; size != 0 {
+		t.Errorf("deleted header still accounted: have %d, want 0", size)
+	}
+	// Deletions free the average item size of the category, without reading
+	db.Put(headerKey(2, hash), make([]byte, 100))
+	db.Put(headerKey(3, hash), make([]byte, 300))
+	db.Delete(headerKey(2, hash))
+	if have, want := DatabaseSizes(db)[SizeCategoryHeaders], uint64(len(header)+200); have != want {
+		t.Errorf("estimated header size mismatch: have %d, want %d", have, want)
+	}
+	db.Delete(headerKey(3, hash))
+	db.Delete(headerKey(3, hash))
+	if size := DatabaseSizes(db)[SizeCategoryHeaders]; size != 0 {
+		t.Errorf("deleted headers still accounted: have %d, want 0", size)
+	}
+	// Persisting the tally (done on close) should restore it on the next run
+	if err := WriteDatabaseSizes(kvdb, sizeTrackerOf(db).persisted()); err != nil {
+		t.Fatalf("failed to store sizes: %v", err)
+	}
+
+	sizes = DatabaseSizes(NewDatabase(newSizeTracker(kvdb)))
+	if have, want := sizes[SizeCategoryBodies], uint64(len(body)+200); have != want {
+		t.Errorf("persisted body size mismatch: have %d, want %d", have, want)
+	}
+}
+
+// Tests that closing a size tracked database twice doesn't fail.
+func TestDatabaseSizeTrackerDoubleClose(t *testing.T) {
+	db := NewDatabase(newSizeTracker(memorydb.New()))
+	db.Put(headerKey(1, common.Hash{0x01}), make([]byte, 100))
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("first close failed: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("second close failed: %v", err)
+	}
+}
+
+// Tests that concurrent writes and deletions keep the sizes and item counts of
+// a category consistent with each other.
+func TestDatabaseSizeTrackerConcurrency(t *testing.T) {
+	tracker := newSizeTracker(memorydb.New())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := headerKey(uint64(i*100+j), common.Hash{0x01})
+				tracker.Put(key, make([]byte, 100))
+				tracker.Delete(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if sizes := tracker.persisted(); len(sizes) != 0 {
+		t.Errorf("leftover sizes after deleting everything: %v", sizes)
+	}
+}
@@ -83,8 +83,13 @@ type freezer struct {
 }
 
 // newFreezer creates a chain freezer that moves ancient chain data into
-// append-only flat file containers.
-func newFreezer(datadir string, namespace string) (*freezer, error) {
+// append-only flat file containers. The compression algorithms of the new tables
+// can be overridden by table name, existing tables keep theirs.
+func newFreezer(datadir string, namespace string, compression map[string]string) (*freezer, error) {
+	codecs, err := freezerTableCodecs(compression)
+	if err != nil {
+		return nil, err
+	}
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -112,8 +117,13 @@ func newFreezer(datadir string, namespace string) (*freezer, error) {
 		trigger:      make(chan chan struct{}),
 		quit:         make(chan struct{}),
 	}
-	for name, disableSnappy := range freezerNoSnappy {
-		table, err := newTable(datadir, name, readMeter, writeMeter, sizeGauge, disableSnappy)
+	for _, name := range FreezerTables() {
+		want := codecs[name]
+		codec := existingCodec(datadir, name, want)
+		if codec != want {
+			log.Info("Freezer table compressed differently than configured", "table", name, "have", codec.name, "want", want.name)
+		}
+		table, err := newCodecTable(datadir, name, readMeter, writeMeter, sizeGauge, freezerTableSize, codec)
 		if err != nil {
 			for _, table := range freezer.tables {
 				table.Close()
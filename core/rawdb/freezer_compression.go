@@ -0,0 +1,265 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/metrics"
+	"github.com/prometheus/tsdb/fileutil"
+)
+
+// Compression algorithms of the freezer tables.
+const (
+	FreezerCompressionNone   = "none"
+	FreezerCompressionSnappy = "snappy"
+	FreezerCompressionZstd   = "zstd"
+)
+
+// freezerCodec is a compression algorithm of the freezer table data files. The
+// files of a table are named after the algorithm, so that data compressed with
+// one algorithm is never read with another one.
+type freezerCodec struct {
+	name   string
+	prefix string // Prefix of the index and data file extensions
+
+	encode func(blob []byte) []byte
+	decode func(blob []byte) ([]byte, error)
+}
+
+var (
+	freezerCodecNone = &freezerCodec{
+		name:   FreezerCompressionNone,
+		prefix: "r",
+		encode: func(blob []byte) []byte { return blob },
+		decode: func(blob []byte) ([]byte, error) { return blob, nil },
+	}
+	freezerCodecSnappy = &freezerCodec{
+		name:   FreezerCompressionSnappy,
+		prefix: "c",
+		encode: func(blob []byte) []byte { return snappy.Encode(nil, blob) },
+		decode: func(blob []byte) ([]byte, error) { return snappy.Decode(nil, blob) },
+	}
+	freezerCodecZstd = &freezerCodec{
+		name:   FreezerCompressionZstd,
+		prefix: "z",
+		encode: func(blob []byte) []byte { return zstdEncoder.EncodeAll(blob, nil) },
+		decode: func(blob []byte) ([]byte, error) { return zstdDecoder.DecodeAll(blob, nil) },
+	}
+	// freezerCodecs are the supported compression algorithms, in the order the
+	// existing tables are looked up on disk.
+	freezerCodecs = []*freezerCodec{freezerCodecSnappy, freezerCodecZstd, freezerCodecNone}
+
+	// The zstd encoder and decoder are safe for concurrent use by the tables when
+	// operating on whole blobs. Items are small, so the decoder doesn't need more
+	// than a single goroutine.
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+)
+
+// indexFile returns the name of the index file of a table.
+func (c *freezerCodec) indexFile(table string) string {
+	return fmt.Sprintf("%s.%sidx", table, c.prefix)
+}
+
+// dataFile returns the name of a data file of a table.
+func (c *freezerCodec) dataFile(table string, num uint32) string {
+	return fmt.Sprintf("%s.%04d.%sdat", table, num, c.prefix)
+}
+
+// parseFreezerCompression looks up a compression algorithm by name.
+func parseFreezerCompression(name string) (*freezerCodec, error) {
+	for _, codec := range freezerCodecs {
+		if codec.name == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown freezer compression %q (supported: %s)", name, freezerCompressionNames())
+}
+
+// FreezerTables returns the names of the freezer tables.
+func FreezerTables() []string {
+	tables := make([]string, 0, len(freezerDefaultCompression))
+	for table := range freezerDefaultCompression {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// freezerTableCodecs returns the compression algorithms of the new tables of a
+// freezer: the defaults, overridden per table by the given compression names.
+func freezerTableCodecs(overrides map[string]string) (map[string]*freezerCodec, error) {
+	codecs := make(map[string]*freezerCodec)
+	for table, name := range freezerDefaultCompression {
+		codecs[table], _ = parseFreezerCompression(name)
+	}
+	for table, name := range overrides {
+		if _, ok := codecs[table]; !ok {
+			return nil, fmt.Errorf("unknown freezer table %q", table)
+		}
+		codec, err := parseFreezerCompression(name)
+		if err != nil {
+			return nil, err
+		}
+		codecs[table] = codec
+	}
+	return codecs, nil
+}
+
+// existingCodec returns the compression algorithm of a table already present on
+// disk, preferring the wanted one if files of multiple algorithms are present
+// (e.g. after an interrupted recompression). If the table doesn't exist yet, the
+// wanted algorithm is returned.
+func existingCodec(path string, table string, want *freezerCodec) *freezerCodec {
+	if common.FileExist(filepath.Join(path, want.indexFile(table))) {
+		return want
+	}
+	for _, codec := range freezerCodecs {
+		if common.FileExist(filepath.Join(path, codec.indexFile(table))) {
+			return codec
+		}
+	}
+	return want
+}
+
+// RecompressFreezerTable rewrites a freezer table with the given compression
+// algorithm. The freezer must not be in use: the directory lock is held during
+// the migration. The recompressed table is assembled in a temporary directory
+// and its index is moved in last, so an interrupted migration leaves the
+// original table intact.
+func RecompressFreezerTable(datadir string, table string, compression string) error {
+	want, err := parseFreezerCompression(compression)
+	if err != nil {
+		return err
+	}
+	if _, ok := freezerDefaultCompression[table]; !ok {
+		return fmt.Errorf("unknown freezer table %q", table)
+	}
+	lock, _, err := fileutil.Flock(filepath.Join(datadir, "FLOCK"))
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	have := existingCodec(datadir, table, want)
+	if !common.FileExist(filepath.Join(datadir, have.indexFile(table))) {
+		return fmt.Errorf("freezer table %q not found in %s", table, datadir)
+	}
+	if have == want {
+		log.Info("Freezer table already compressed", "table", table, "compression", want.name)
+		return nil
+	}
+	src, err := newCodecTable(datadir, table, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, freezerTableSize, have)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if src.itemOffset != 0 {
+		return fmt.Errorf("freezer table %q has deleted tail items, recompression not supported", table)
+	}
+	tmpdir, err := ioutil.TempDir(datadir, "recompress-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	dst, err := newCodecTable(tmpdir, table, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, freezerTableSize, want)
+	if err != nil {
+		return err
+	}
+	var (
+		items  = atomic.LoadUint64(&src.items)
+		logged uint64
+	)
+	log.Info("Recompressing freezer table", "table", table, "from", have.name, "to", want.name, "items", items)
+	for i := uint64(0); i < items; i++ {
+		blob, err := src.Retrieve(i)
+		if err != nil {
+			dst.Close()
+			return err
+		}
+		if err := dst.Append(i, blob); err != nil {
+			dst.Close()
+			return err
+		}
+		if i-logged >= 1000000 {
+			log.Info("Recompressing freezer table", "table", table, "item", i, "items", items)
+			logged = i
+		}
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	oldSize, _ := src.size()
+	newSize, _ := dst.size()
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	// Move the data files of the new table in first and the index last, which
+	// commits the migration, then delete the files of the old table.
+	dataFiles, err := filepath.Glob(filepath.Join(tmpdir, table+".*."+want.prefix+"dat"))
+	if err != nil {
+		return err
+	}
+	for _, file := range dataFiles {
+		if err := os.Rename(file, filepath.Join(datadir, filepath.Base(file))); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(filepath.Join(tmpdir, want.indexFile(table)), filepath.Join(datadir, want.indexFile(table))); err != nil {
+		return err
+	}
+	src.Close()
+	if err := os.Remove(filepath.Join(datadir, have.indexFile(table))); err != nil {
+		return err
+	}
+	oldFiles, err := filepath.Glob(filepath.Join(datadir, table+".*."+have.prefix+"dat"))
+	if err != nil {
+		return err
+	}
+	for _, file := range oldFiles {
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
+	log.Info("Recompressed freezer table", "table", table, "compression", want.name,
+		"items", items, "old", common.StorageSize(oldSize), "new", common.StorageSize(newSize))
+	return nil
+}
+
+// freezerCompressionNames returns the names of the supported compression
+// algorithms, for error and usage messages.
+func freezerCompressionNames() string {
+	names := make([]string, len(freezerCodecs))
+	for i, codec := range freezerCodecs {
+		names[i] = codec.name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/metrics"
+)
+
+// Tests that a freezer table can be recompressed offline and that the existing
+// files are picked up regardless of the configured compression.
+func TestRecompressFreezerTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := freezerBodiesTable
+	tab, err := newCodecTable(dir, table, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, freezerTableSize, freezerCodecSnappy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := tab.Append(uint64(i), getChunk(100, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tab.Close()
+
+	if err := RecompressFreezerTable(dir, table, "lz4"); err == nil {
+		t.Fatal("unsupported compression accepted")
+	}
+	if err := RecompressFreezerTable(dir, table, FreezerCompressionNone); err != nil {
+		t.Fatalf("recompression failed: %v", err)
+	}
+	if common.FileExist(filepath.Join(dir, freezerCodecSnappy.indexFile(table))) {
+		t.Error("old index not removed")
+	}
+	if codec := existingCodec(dir, table, freezerCodecSnappy); codec != freezerCodecNone {
+		t.Fatalf("existing compression mismatch: have %s, want %s", codec.name, FreezerCompressionNone)
+	}
+	tab, err = newCodecTable(dir, table, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, freezerTableSize, freezerCodecNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tab.Close()
+
+	if tab.items != 100 {
+		t.Fatalf("item count mismatch: have %d, want 100", tab.items)
+	}
+	for i := 0; i < 100; i++ {
+		blob, err := tab.Retrieve(uint64(i))
+		if err != nil {
+			t.Fatalf("item %d: %v", i, err)
+		}
+		if !bytes.Equal(blob, getChunk(100, i)) {
+			t.Fatalf("item %d: content mismatch", i)
+		}
+	}
+}
+
+// Tests that the compression overrides passed to the freezer apply to its new
+// tables, and that unsupported ones are rejected.
+func TestFreezerCompressionOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := newFreezer(dir, "", map[string]string{freezerReceiptTable: "lz4"}); err == nil {
+		t.Fatal("unsupported compression accepted")
+	}
+	if _, err := newFreezer(dir, "", map[string]string{"missing": FreezerCompressionNone}); err == nil {
+		t.Fatal("unknown table accepted")
+	}
+	f, err := newFreezer(dir, "", map[string]string{freezerReceiptTable: FreezerCompressionNone, freezerHeaderTable: FreezerCompressionZstd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range f.tables {
+		table.Close()
+	}
+	f.instanceLock.Release()
+
+	if !common.FileExist(filepath.Join(dir, freezerCodecNone.indexFile(freezerReceiptTable))) {
+		t.Error("receipts table not created uncompressed")
+	}
+	if !common.FileExist(filepath.Join(dir, freezerCodecZstd.indexFile(freezerHeaderTable))) {
+		t.Error("headers table not created with zstd compression")
+	}
+	if !common.FileExist(filepath.Join(dir, freezerCodecSnappy.indexFile(freezerBodiesTable))) {
+		t.Error("bodies table not created with the default compression")
+	}
+}
+
+// Tests that the items of a zstd compressed table read back intact, also after
+// reopening the table, and that the table can be recompressed back and forth.
+func TestFreezerZstdCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := freezerReceiptTable
+	tab, err := newCodecTable(dir, table, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, 50, freezerCodecZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := tab.Append(uint64(i), getChunk(100, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkItems := func(tab *freezerTable) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			blob, err := tab.Retrieve(uint64(i))
+			if err != nil {
+				t.Fatalf("item %d: %v", i, err)
+			}
+			if !bytes.Equal(blob, getChunk(100, i)) {
+				t.Fatalf("item %d: content mismatch", i)
+			}
+		}
+	}
+	checkItems(tab)
+	tab.Close()
+
+	// Recompress to snappy and back, checking the contents after each pass
+	for _, codec := range []*freezerCodec{freezerCodecSnappy, freezerCodecZstd} {
+		if err := RecompressFreezerTable(dir, table, codec.name); err != nil {
+			t.Fatalf("recompression to %s failed: %v", codec.name, err)
+		}
+		if have := existingCodec(dir, table, freezerCodecNone); have != codec {
+			t.Fatalf("existing compression mismatch: have %s, want %s", have.name, codec.name)
+		}
+		tab, err := newCodecTable(dir, table, metrics.NilMeter{}, metrics.NilMeter{}, metrics.NilGauge{}, 50, codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tab.items != 100 {
+			t.Fatalf("item count mismatch: have %d, want 100", tab.items)
+		}
+		checkItems(tab)
+		tab.Close()
+	}
+}
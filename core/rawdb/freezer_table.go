@@ -26,7 +26,6 @@ import (
 	"sync"
 	"sync/atomic"
 
-	"github.com/golang/snappy"
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/log"
 	"github.com/ong2020/go-orange/metrics"
@@ -70,8 +69,11 @@ func (i *indexEntry) marshallBinary() []byte {
 	return b
 }
 
+// freezerTableSize is the default maximum size of the data files of a table.
+const freezerTableSize = 2 * 1000 * 1000 * 1000
+
 // freezerTable represents a single chained data table within the freezer (e.g. blocks).
-// It consists of a data file (snappy encoded arbitrary data blobs) and an indexEntry
+// It consists of a data file (compressed arbitrary data blobs) and an indexEntry
 // file (uncompressed 64 bit indices into the data file).
 type freezerTable struct {
 	// WARNING: The `items` field is accessed atomically. On 32 bit platforms, only
@@ -79,10 +81,10 @@ type freezerTable struct {
 	// so take advantage of that (https://golang.org/pkg/sync/atomic/#pkg-note-BUG).
 	items uint64 // Number of items stored in the table (including items removed from tail)
 
-	noCompression bool   // if true, disables snappy compression. Note: does not work retroactively
-	maxFileSize   uint32 // Max file size for data-files
-	name          string
-	path          string
+	codec       *freezerCodec // Compression algorithm of the data files
+	maxFileSize uint32        // Max file size for data-files
+	name        string
+	path        string
 
 	head   *os.File            // File descriptor for the data head of the table
 	files  map[uint32]*os.File // open files
@@ -110,7 +112,7 @@ func NewFreezerTable(path, name string, disableSnappy bool) (*freezerTable, erro
 
 // newTable opens a freezer table with default settings - 2G files
 func newTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, disableSnappy bool) (*freezerTable, error) {
-	return newCustomTable(path, name, readMeter, writeMeter, sizeGauge, 2*1000*1000*1000, disableSnappy)
+	return newCustomTable(path, name, readMeter, writeMeter, sizeGauge, freezerTableSize, disableSnappy)
 }
 
 // openFreezerFileForAppend opens a freezer table file and seeks to the end
@@ -155,34 +157,36 @@ func truncateFreezerFile(file *os.File, size int64) error {
 // non existent. Both files are truncated to the shortest common length to ensure
 // they don't go out of sync.
 func newCustomTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression bool) (*freezerTable, error) {
+	codec := freezerCodecSnappy
+	if noCompression {
+		codec = freezerCodecNone
+	}
+	return newCodecTable(path, name, readMeter, writeMeter, sizeGauge, maxFilesize, codec)
+}
+
+// newCodecTable opens a freezer table whose data files are compressed with the
+// given algorithm, creating the data and index files if they are non existent.
+func newCodecTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, codec *freezerCodec) (*freezerTable, error) {
 	// Ensure the containing directory exists and open the indexEntry file
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, err
 	}
-	var idxName string
-	if noCompression {
-		// Raw idx
-		idxName = fmt.Sprintf("%s.ridx", name)
-	} else {
-		// Compressed idx
-		idxName = fmt.Sprintf("%s.cidx", name)
-	}
-	offsets, err := openFreezerFileForAppend(filepath.Join(path, idxName))
+	offsets, err := openFreezerFileForAppend(filepath.Join(path, codec.indexFile(name)))
 	if err != nil {
 		return nil, err
 	}
 	// Create the table and repair any past inconsistency
 	tab := &freezerTable{
-		index:         offsets,
-		files:         make(map[uint32]*os.File),
-		readMeter:     readMeter,
-		writeMeter:    writeMeter,
-		sizeGauge:     sizeGauge,
-		name:          name,
-		path:          path,
-		logger:        log.New("database", path, "table", name),
-		noCompression: noCompression,
-		maxFileSize:   maxFilesize,
+		index:       offsets,
+		files:       make(map[uint32]*os.File),
+		readMeter:   readMeter,
+		writeMeter:  writeMeter,
+		sizeGauge:   sizeGauge,
+		name:        name,
+		path:        path,
+		logger:      log.New("database", path, "table", name),
+		codec:       codec,
+		maxFileSize: maxFilesize,
 	}
 	if err := tab.repair(); err != nil {
 		tab.Close()
@@ -421,13 +425,7 @@ func (t *freezerTable) Close() error {
 func (t *freezerTable) openFile(num uint32, opener func(string) (*os.File, error)) (f *os.File, err error) {
 	var exist bool
 	if f, exist = t.files[num]; !exist {
-		var name string
-		if t.noCompression {
-			name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
-		} else {
-			name = fmt.Sprintf("%s.%04d.cdat", t.name, num)
-		}
-		f, err = opener(filepath.Join(t.path, name))
+		f, err = opener(filepath.Join(t.path, t.codec.dataFile(t.name, num)))
 		if err != nil {
 			return nil, err
 		}
@@ -478,9 +476,7 @@ func (t *freezerTable) Append(item uint64, blob []byte) error {
 		return fmt.Errorf("appending unexpected item: want %d, have %d", t.items, item)
 	}
 	// Encode the blob and write it into the data file
-	if !t.noCompression {
-		blob = snappy.Encode(nil, blob)
-	}
+	blob = t.codec.encode(blob)
 	bLen := uint32(len(blob))
 	if t.headBytes+bLen < bLen ||
 		t.headBytes+bLen > t.maxFileSize {
@@ -596,10 +592,7 @@ func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
 	t.lock.RUnlock()
 	t.readMeter.Mark(int64(len(blob) + 2*indexEntrySize))
 
-	if t.noCompression {
-		return blob, nil
-	}
-	return snappy.Decode(nil, blob)
+	return t.codec.decode(blob)
 }
 
 // has returns an indicator whonger the specified number data
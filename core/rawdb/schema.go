@@ -22,6 +22,7 @@ import (
 	"encoding/binary"
 
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/crypto"
 	"github.com/ong2020/go-orange/metrics"
 )
 
@@ -72,6 +73,18 @@ var (
 	// uncleanShutdownKey tracks the list of local crashes
 	uncleanShutdownKey = []byte("unclean-shutdown") // config prefix for the db
 
+	// databaseSizesKey tracks the per-category storage accounting across restarts.
+	databaseSizesKey = []byte("DatabaseSizes")
+
+	// networkIDKey tracks the network ID the database was created for.
+	networkIDKey = []byte("NetworkID")
+
+	// spendGuardKey tracks the spends of the accounts signing through the RPC APIs.
+	spendGuardKey = []byte("SpendGuard")
+
+	// relaySpendingKey tracks the daily spending of the sponsored transaction relay.
+	relaySpendingKey = []byte("RelaySpending")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -90,6 +103,12 @@ var (
 	preimagePrefix = []byte("secure-key-")    // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("orange-config-") // config prefix for the db
 
+	repairedReceiptsPrefix = []byte("repaired-receipts-") // repairedReceiptsPrefix + num (uint64 big endian) + hash -> block receipts overriding the frozen ones
+
+	relayNoncePrefix     = []byte("relay-sender-nonce-") // relayNoncePrefix + sender address -> next meta-transaction nonce
+	exporterCursorPrefix = []byte("exporter-cursor-")    // exporterCursorPrefix + sink hash -> last block exported to the sink
+	filterJournalPrefix  = []byte("filter-journal-")     // filterJournalPrefix + filter id hash -> persisted RPC filter
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -114,14 +133,15 @@ const (
 	freezerDifficultyTable = "diffs"
 )
 
-// freezerNoSnappy configures whonger compression is disabled for the ancient-tables.
-// Hashes and difficulties don't compress well.
-var freezerNoSnappy = map[string]bool{
-	freezerHeaderTable:     false,
-	freezerHashTable:       true,
-	freezerBodiesTable:     false,
-	freezerReceiptTable:    false,
-	freezerDifficultyTable: true,
+// freezerDefaultCompression is the default compression algorithm of the new
+// ancient-tables, which can be overridden when opening the freezer. Hashes and
+// difficulties don't compress well.
+var freezerDefaultCompression = map[string]string{
+	freezerHeaderTable:     FreezerCompressionSnappy,
+	freezerHashTable:       FreezerCompressionNone,
+	freezerBodiesTable:     FreezerCompressionSnappy,
+	freezerReceiptTable:    FreezerCompressionSnappy,
+	freezerDifficultyTable: FreezerCompressionNone,
 }
 
 // LegacyTxLookupEntry is the legacy TxLookupEntry definition with some unnecessary
@@ -174,6 +194,11 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// repairedReceiptsKey = repairedReceiptsPrefix + num (uint64 big endian) + hash
+func repairedReceiptsKey(number uint64, hash common.Hash) []byte {
+	return append(append(repairedReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -227,3 +252,18 @@ func IsCodeKey(key []byte) (bool, []byte) {
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
 }
+
+// relayNonceKey = relayNoncePrefix + sender address
+func relayNonceKey(sender common.Address) []byte {
+	return append(relayNoncePrefix, sender.Bytes()...)
+}
+
+// exporterCursorKey = exporterCursorPrefix + sink hash
+func exporterCursorKey(sink common.Hash) []byte {
+	return append(exporterCursorPrefix, sink.Bytes()...)
+}
+
+// filterJournalKey = filterJournalPrefix + keccak256(filter id)
+func filterJournalKey(id string) []byte {
+	return append(filterJournalPrefix, crypto.Keccak256([]byte(id))...)
+}
@@ -0,0 +1,309 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ong2020/go-orange/common"
+)
+
+// Stores of the database, as grouped in the inspect output.
+const (
+	storeKeyValue = "Key-Value store"
+	storeLight    = "Light client"
+)
+
+// KeySchema describes a table (or singleton entry) of the key-value store: the
+// layout of its keys, the encoding of its values and the category it's reported
+// under by the database inspection and size accounting.
+type KeySchema struct {
+	Name     string // Unique name of the table
+	Store    string // Store the table is reported under by the inspection
+	Category string // Category the table is reported under by the inspection
+	Size     string // Category the table is tracked under by the size accounting
+
+	Prefix []byte // Common prefix of the keys (the full key for singletons)
+	Suffix []byte // Common suffix of the keys, if any
+	Length int    // Exact length of the keys, 0 if variable
+
+	Layout string // Human readable layout of the keys
+	Value  string // Human readable encoding of the values
+
+	sizeCategory int // Index of the size category in the size tracker
+}
+
+// Matches reports whether a database key belongs to the table.
+func (s *KeySchema) Matches(key []byte) bool {
+	if s.Length != 0 && len(key) != s.Length {
+		return false
+	}
+	return bytes.HasPrefix(key, s.Prefix) && bytes.HasSuffix(key, s.Suffix)
+}
+
+// singleton creates the schema of a single metadata entry.
+func singleton(key []byte, value string) *KeySchema {
+	return &KeySchema{
+		Name:     string(key),
+		Store:    storeKeyValue,
+		Category: "Singleton metadata",
+		Size:     SizeCategoryMetadata,
+		Prefix:   key,
+		Length:   len(key),
+		Layout:   strconv.Quote(string(key)),
+		Value:    value,
+	}
+}
+
+// keySchemas is the registry of the key-value store tables. A key belongs to the
+// first table it matches, so more specific tables must precede the generic ones
+// they overlap with.
+var keySchemas = []*KeySchema{
+	{Name: "headers", Store: storeKeyValue, Category: "Headers", Size: SizeCategoryHeaders,
+		Prefix: headerPrefix, Length: len(headerPrefix) + 8 + common.HashLength,
+		Layout: `"h" + num (uint64 big endian) + hash`, Value: "RLP(types.Header)"},
+	{Name: "bodies", Store: storeKeyValue, Category: "Bodies", Size: SizeCategoryBodies,
+		Prefix: blockBodyPrefix, Length: len(blockBodyPrefix) + 8 + common.HashLength,
+		Layout: `"b" + num (uint64 big endian) + hash`, Value: "RLP(types.Body)"},
+	{Name: "receipts", Store: storeKeyValue, Category: "Receipt lists", Size: SizeCategoryReceipts,
+		Prefix: blockReceiptsPrefix, Length: len(blockReceiptsPrefix) + 8 + common.HashLength,
+		Layout: `"r" + num (uint64 big endian) + hash`, Value: "RLP([]types.ReceiptForStorage)"},
+	{Name: "repaired-receipts", Store: storeKeyValue, Category: "Receipt lists", Size: SizeCategoryReceipts,
+		Prefix: repairedReceiptsPrefix, Length: len(repairedReceiptsPrefix) + 8 + common.HashLength,
+		Layout: `"repaired-receipts-" + num (uint64 big endian) + hash`, Value: "RLP([]types.ReceiptForStorage)"},
+	{Name: "header-td", Store: storeKeyValue, Category: "Difficulties", Size: SizeCategoryChainMeta,
+		Prefix: headerPrefix, Suffix: headerTDSuffix, Length: len(headerPrefix) + 8 + common.HashLength + len(headerTDSuffix),
+		Layout: `"h" + num (uint64 big endian) + hash + "t"`, Value: "RLP(big.Int)"},
+	{Name: "canonical-hash", Store: storeKeyValue, Category: "Block number->hash", Size: SizeCategoryChainMeta,
+		Prefix: headerPrefix, Suffix: headerHashSuffix, Length: len(headerPrefix) + 8 + len(headerHashSuffix),
+		Layout: `"h" + num (uint64 big endian) + "n"`, Value: "hash"},
+	{Name: "header-number", Store: storeKeyValue, Category: "Block hash->number", Size: SizeCategoryChainMeta,
+		Prefix: headerNumberPrefix, Length: len(headerNumberPrefix) + common.HashLength,
+		Layout: `"H" + hash`, Value: "num (uint64 big endian)"},
+	{Name: "trie-nodes", Store: storeKeyValue, Category: "Trie nodes", Size: SizeCategoryState,
+		Length: common.HashLength,
+		Layout: "hash", Value: "RLP(trie node)"},
+	{Name: "codes", Store: storeKeyValue, Category: "Contract codes", Size: SizeCategoryState,
+		Prefix: CodePrefix, Length: len(CodePrefix) + common.HashLength,
+		Layout: `"c" + code hash`, Value: "EVM bytecode"},
+	{Name: "tx-lookups", Store: storeKeyValue, Category: "Transaction index", Size: SizeCategoryTxIndex,
+		Prefix: txLookupPrefix, Length: len(txLookupPrefix) + common.HashLength,
+		Layout: `"l" + tx hash`, Value: "num (big endian, leading zeroes trimmed)"},
+	{Name: "account-snapshots", Store: storeKeyValue, Category: "Account snapshot", Size: SizeCategorySnapshot,
+		Prefix: SnapshotAccountPrefix, Length: len(SnapshotAccountPrefix) + common.HashLength,
+		Layout: `"a" + account hash`, Value: "RLP(snapshot.Account) (slim format)"},
+	{Name: "storage-snapshots", Store: storeKeyValue, Category: "Storage snapshot", Size: SizeCategorySnapshot,
+		Prefix: SnapshotStoragePrefix, Length: len(SnapshotStoragePrefix) + 2*common.HashLength,
+		Layout: `"o" + account hash + storage hash`, Value: "RLP(slot value)"},
+	{Name: "preimages", Store: storeKeyValue, Category: "Trie preimages", Size: SizeCategoryState,
+		Prefix: preimagePrefix, Length: len(preimagePrefix) + common.HashLength,
+		Layout: `"secure-key-" + hash`, Value: "preimage"},
+	{Name: "bloombits", Store: storeKeyValue, Category: "Bloombit index", Size: SizeCategoryBloomBits,
+		Prefix: bloomBitsPrefix, Length: len(bloomBitsPrefix) + 10 + common.HashLength,
+		Layout: `"B" + bit (uint16 big endian) + section (uint64 big endian) + section head hash`, Value: "compressed bit vector"},
+	{Name: "bloombits-index", Store: storeKeyValue, Category: "Bloombit index", Size: SizeCategoryBloomBits,
+		Prefix: BloomBitsIndexPrefix,
+		Layout: `"iB" + chain indexer key`, Value: "chain indexer progress"},
+	{Name: "clique-snapshots", Store: storeKeyValue, Category: "Clique snapshots", Size: SizeCategoryOther,
+		Prefix: []byte("clique-"), Length: 7 + common.HashLength,
+		Layout: `"clique-" + block hash`, Value: "JSON(clique.Snapshot)"},
+	{Name: "chain-configs", Store: storeKeyValue, Category: "Singleton metadata", Size: SizeCategoryMetadata,
+		Prefix: configPrefix, Length: len(configPrefix) + common.HashLength,
+		Layout: `"orange-config-" + genesis hash`, Value: "JSON(params.ChainConfig)"},
+	{Name: "relay-nonces", Store: storeKeyValue, Category: "Relay nonces", Size: SizeCategoryMetadata,
+		Prefix: relayNoncePrefix, Length: len(relayNoncePrefix) + common.AddressLength,
+		Layout: `"relay-sender-nonce-" + sender address`, Value: "uint64 (big endian)"},
+	{Name: "exporter-cursors", Store: storeKeyValue, Category: "Exporter cursors", Size: SizeCategoryMetadata,
+		Prefix: exporterCursorPrefix, Length: len(exporterCursorPrefix) + common.HashLength,
+		Layout: `"exporter-cursor-" + sink hash`, Value: "RLP(block number, block hash)"},
+	{Name: "filter-journal", Store: storeKeyValue, Category: "Persisted filters", Size: SizeCategoryMetadata,
+		Prefix: filterJournalPrefix, Length: len(filterJournalPrefix) + common.HashLength,
+		Layout: `"filter-journal-" + keccak256(filter id)`, Value: "JSON(filter id, criteria and position)"},
+	{Name: "unclean-shutdown", Store: storeKeyValue, Category: "Shutdown metadata", Size: SizeCategoryMetadata,
+		Prefix: uncleanShutdownKey, Length: len(uncleanShutdownKey),
+		Layout: strconv.Quote(string(uncleanShutdownKey)), Value: "RLP(crash timestamps)"},
+	singleton(databaseVersionKey, "RLP(uint64)"),
+	singleton(headHeaderKey, "hash"),
+	singleton(headBlockKey, "hash"),
+	singleton(headFastBlockKey, "hash"),
+	singleton(lastPivotKey, "RLP(uint64)"),
+	singleton(fastTrieProgressKey, "uint64 (big endian)"),
+	singleton(snapshotRootKey, "hash"),
+	singleton(snapshotJournalKey, "RLP(snapshot journal)"),
+	singleton(snapshotGeneratorKey, "RLP(snapshot generator progress)"),
+	singleton(snapshotRecoveryKey, "uint64 (big endian)"),
+	singleton(snapshotSyncStatusKey, "snap sync status"),
+	singleton(txIndexTailKey, "uint64 (big endian)"),
+	singleton(fastTxLookupLimitKey, "uint64 (big endian)"),
+	singleton(badBlockKey, "RLP([]badBlock)"),
+	singleton(databaseSizesKey, "JSON(map[category]size)"),
+	singleton(networkIDKey, "RLP(uint64)"),
+	singleton(spendGuardKey, "JSON(spend guard journal)"),
+	singleton(relaySpendingKey, "JSON(relay spending journal)"),
+	{Name: "cht-nodes", Store: storeLight, Category: "CHT trie nodes", Size: SizeCategoryLes,
+		Prefix: []byte("cht-"), Length: 4 + common.HashLength,
+		Layout: `"cht-" + hash`, Value: "RLP(trie node)"},
+	{Name: "cht-index", Store: storeLight, Category: "CHT trie nodes", Size: SizeCategoryLes,
+		Prefix: []byte("chtIndexV2-"),
+		Layout: `"chtIndexV2-" + chain indexer key`, Value: "chain indexer progress"},
+	{Name: "cht-roots", Store: storeLight, Category: "CHT trie nodes", Size: SizeCategoryLes,
+		Prefix: []byte("chtRootV2-"), Length: 10 + 8 + common.HashLength,
+		Layout: `"chtRootV2-" + section (uint64 big endian) + section head hash`, Value: "trie root hash"},
+	{Name: "bloomtrie-nodes", Store: storeLight, Category: "Bloom trie nodes", Size: SizeCategoryLes,
+		Prefix: []byte("blt-"), Length: 4 + common.HashLength,
+		Layout: `"blt-" + hash`, Value: "RLP(trie node)"},
+	{Name: "bloomtrie-index", Store: storeLight, Category: "Bloom trie nodes", Size: SizeCategoryLes,
+		Prefix: []byte("bltIndex-"),
+		Layout: `"bltIndex-" + chain indexer key`, Value: "chain indexer progress"},
+	{Name: "bloomtrie-roots", Store: storeLight, Category: "Bloom trie nodes", Size: SizeCategoryLes,
+		Prefix: []byte("bltRoot-"), Length: 8 + 8 + common.HashLength,
+		Layout: `"bltRoot-" + section (uint64 big endian) + section head hash`, Value: "trie root hash"},
+}
+
+// keySchemaIndex groups the tables of the registry by the first byte of their
+// keys, in registry order, so keys are only matched against the tables they
+// may belong to. Tables without a prefix are part of every group.
+var keySchemaIndex [256][]*KeySchema
+
+func init() {
+	for _, schema := range keySchemas {
+		schema.sizeCategory = sizeCategoryIndex(schema.Size)
+		for b := range keySchemaIndex {
+			if len(schema.Prefix) == 0 || schema.Prefix[0] == byte(b) {
+				keySchemaIndex[b] = append(keySchemaIndex[b], schema)
+			}
+		}
+	}
+}
+
+// KeySchemas returns the registry of the key-value store tables, in the order
+// keys are matched against them.
+func KeySchemas() []*KeySchema {
+	return keySchemas
+}
+
+// MatchKeySchema returns the table a database key belongs to, or nil if the key
+// doesn't match any known table.
+func MatchKeySchema(key []byte) *KeySchema {
+	if len(key) == 0 {
+		return nil
+	}
+	for _, schema := range keySchemaIndex[key[0]] {
+		if schema.Matches(key) {
+			return schema
+		}
+	}
+	return nil
+}
+
+// inspectCategories returns the first table of every category reported by the
+// database inspection, in registry order.
+func inspectCategories() []*KeySchema {
+	var (
+		categories []*KeySchema
+		seen       = make(map[string]bool)
+	)
+	for _, schema := range keySchemas {
+		if id := schema.Store + "/" + schema.Category; !seen[id] {
+			seen[id] = true
+			categories = append(categories, schema)
+		}
+	}
+	return categories
+}
+
+// overlaps reports whether some key could match both tables, i.e. whether the
+// tables share part of their key space.
+func (s *KeySchema) overlaps(o *KeySchema) bool {
+	short, long := s, o
+	if len(short.Prefix) > len(long.Prefix) {
+		short, long = long, short
+	}
+	if !bytes.HasPrefix(long.Prefix, short.Prefix) {
+		return false
+	}
+	// Tables with fixed key lengths only overlap if the lengths match and their
+	// suffixes are compatible
+	if s.Length != 0 && o.Length != 0 {
+		if s.Length != o.Length {
+			return false
+		}
+		if !bytes.HasSuffix(s.Suffix, o.Suffix) && !bytes.HasSuffix(o.Suffix, s.Suffix) {
+			return false
+		}
+	}
+	// A fixed key length shorter than the other table's prefix can't match it
+	for _, t := range []*KeySchema{s, o} {
+		other := o
+		if t == o {
+			other = s
+		}
+		if t.Length != 0 && t.Length < len(other.Prefix)+len(other.Suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// KeySchemaOverlaps returns the pairs of tables sharing part of their key space.
+// Keys in the shared space are attributed to the table registered first, so any
+// overlap is a potential source of misattributed keys or, worse, of colliding
+// writes, and new tables should be designed to avoid them.
+func KeySchemaOverlaps() [][2]string {
+	var overlaps [][2]string
+	for i, s := range keySchemas {
+		for _, o := range keySchemas[i+1:] {
+			if s.overlaps(o) {
+				overlaps = append(overlaps, [2]string{s.Name, o.Name})
+			}
+		}
+	}
+	return overlaps
+}
+
+// KeySchemaDoc renders the registry of the key-value store tables and the
+// freezer tables as a markdown document.
+func KeySchemaDoc() string {
+	var doc strings.Builder
+
+	doc.WriteString("# Database schema\n\n## Key-value store\n\n")
+	doc.WriteString("| Table | Key layout | Value | Category |\n")
+	doc.WriteString("|-------|------------|-------|----------|\n")
+	for _, s := range keySchemas {
+		fmt.Fprintf(&doc, "| %s | `%s` | %s | %s / %s |\n", s.Name, s.Layout, s.Value, s.Store, s.Category)
+	}
+	doc.WriteString("\n## Ancient store\n\n")
+	doc.WriteString("| Table | Item | Compression |\n")
+	doc.WriteString("|-------|------|-------------|\n")
+	for _, table := range FreezerTables() {
+		fmt.Fprintf(&doc, "| %s | %s | %s |\n", table, freezerTableItems[table], freezerDefaultCompression[table])
+	}
+	return doc.String()
+}
+
+// freezerTableItems describes the items stored in the freezer tables, indexed
+// by block number.
+var freezerTableItems = map[string]string{
+	freezerHeaderTable:     "RLP(types.Header)",
+	freezerHashTable:       "hash",
+	freezerBodiesTable:     "RLP(types.Body)",
+	freezerReceiptTable:    "RLP([]types.ReceiptForStorage)",
+	freezerDifficultyTable: "RLP(big.Int)",
+}
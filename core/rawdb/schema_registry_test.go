@@ -0,0 +1,100 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+)
+
+// knownKeySchemaOverlaps are the accepted key space overlaps of the registered
+// tables: the variable length chain indexer tables of the light client share
+// the key space of hash keyed tables, but never store keys of those lengths.
+var knownKeySchemaOverlaps = map[[2]string]bool{
+	{"bodies", "bloomtrie-index"}:     true,
+	{"trie-nodes", "bloombits-index"}: true,
+	{"trie-nodes", "cht-index"}:       true,
+	{"trie-nodes", "bloomtrie-index"}: true,
+	{"codes", "cht-index"}:            true,
+}
+
+// Tests that tables added to the schema registry don't share their key space
+// with existing ones. Adding a new overlap fails this test, which should be
+// fixed by choosing a different key prefix rather than extending the list of
+// known overlaps.
+func TestKeySchemaOverlaps(t *testing.T) {
+	for _, overlap := range KeySchemaOverlaps() {
+		if !knownKeySchemaOverlaps[overlap] {
+			t.Errorf("tables %q and %q share key space", overlap[0], overlap[1])
+		}
+	}
+	names := make(map[string]bool)
+	for _, schema := range KeySchemas() {
+		if names[schema.Name] {
+			t.Errorf("duplicate table name %q", schema.Name)
+		}
+		names[schema.Name] = true
+	}
+}
+
+// Tests that the keys produced by the accessors are attributed to their tables.
+func TestMatchKeySchema(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	tests := []struct {
+		key  []byte
+		want string
+	}{
+		{headerKey(1, hash), "headers"},
+		{headerTDKey(1, hash), "header-td"},
+		{headerHashKey(1), "canonical-hash"},
+		{headerNumberKey(hash), "header-number"},
+		{blockBodyKey(1, hash), "bodies"},
+		{blockReceiptsKey(1, hash), "receipts"},
+		{repairedReceiptsKey(1, hash), "repaired-receipts"},
+		{txLookupKey(hash), "tx-lookups"},
+		{accountSnapshotKey(hash), "account-snapshots"},
+		{storageSnapshotKey(hash, hash), "storage-snapshots"},
+		{bloomBitsKey(1, 1, hash), "bloombits"},
+		{preimageKey(hash), "preimages"},
+		{codeKey(hash), "codes"},
+		{configKey(hash), "chain-configs"},
+		{filterJournalKey("0x1"), "filter-journal"},
+		{hash.Bytes(), "trie-nodes"},
+		{headHeaderKey, string(headHeaderKey)},
+		{uncleanShutdownKey, "unclean-shutdown"},
+	}
+	for _, tt := range tests {
+		schema := MatchKeySchema(tt.key)
+		if schema == nil {
+			t.Errorf("key %x: no table matched, want %s", tt.key, tt.want)
+			continue
+		}
+		if schema.Name != tt.want {
+			t.Errorf("key %x: table mismatch: have %s, want %s", tt.key, schema.Name, tt.want)
+		}
+	}
+	for _, key := range [][]byte{nil, []byte("unknown")} {
+		if schema := MatchKeySchema(key); schema != nil {
+			t.Errorf("unknown key %q matched table %s", key, schema.Name)
+		}
+	}
+	if doc := KeySchemaDoc(); !strings.Contains(doc, "`\"h\" + num (uint64 big endian) + hash`") {
+		t.Errorf("schema doc misses the header layout:\n%s", doc)
+	}
+}
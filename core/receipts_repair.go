@@ -0,0 +1,166 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/state"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/rlp"
+	"github.com/ong2020/go-orange/trie"
+)
+
+// RepairReceipts re-executes the canonical blocks in the [from, to] range and
+// rewrites any stored receipts that are missing or differ from the regenerated
+// ones. The regenerated receipts are verified against the receipt root, bloom
+// and state root of each block header before being written.
+//
+// If the state of the block preceding the range is not available, up to reexec
+// blocks are executed in addition to regenerate it. Receipts already moved into
+// the freezer are immutable, so they are repaired by storing the regenerated
+// ones in the key-value store, where they override the frozen ones.
+//
+// The number of repaired blocks is returned.
+func (bc *BlockChain) RepairReceipts(from, to uint64, reexec uint64) (int, error) {
+	if from == 0 {
+		from = 1 // Genesis has no receipts to repair
+	}
+	if from > to {
+		return 0, fmt.Errorf("invalid block range [%d, %d]", from, to)
+	}
+	if head := bc.CurrentBlock().NumberU64(); to > head {
+		return 0, fmt.Errorf("block range end #%d beyond current head #%d", to, head)
+	}
+	// Find the closest available state before the range to start executing from
+	base := bc.GetBlockByNumber(from - 1)
+	if base == nil {
+		return 0, fmt.Errorf("block #%d not found", from-1)
+	}
+	var (
+		database = state.NewDatabaseWithConfig(bc.db, &trie.Config{Cache: 16})
+		statedb  *state.StateDB
+		err      error
+	)
+	for i := uint64(0); ; i++ {
+		if statedb, err = state.New(base.Root(), database, nil); err == nil {
+			break
+		}
+		if i >= reexec || base.NumberU64() == 0 {
+			return 0, fmt.Errorf("required historical state unavailable (reexec=%d)", reexec)
+		}
+		if base = bc.GetBlock(base.ParentHash(), base.NumberU64()-1); base == nil {
+			return 0, errors.New("missing ancestor block")
+		}
+	}
+	var (
+		start    = time.Now()
+		logged   time.Time
+		parent   common.Hash
+		repaired int
+	)
+	defer func() {
+		if parent != (common.Hash{}) {
+			database.TrieDB().Dereference(parent)
+		}
+	}()
+	for number := base.NumberU64() + 1; number <= to; number++ {
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Regenerating receipts", "block", number, "target", to, "repaired", repaired, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return repaired, fmt.Errorf("block #%d not found", number)
+		}
+		receipts, _, usedGas, err := bc.Processor().Process(block, statedb, *bc.GetVMConfig())
+		if err != nil {
+			return repaired, fmt.Errorf("processing block #%d failed: %v", number, err)
+		}
+		// Make sure the re-execution matches the header before trusting it
+		header := block.Header()
+		if usedGas != header.GasUsed {
+			return repaired, fmt.Errorf("block #%d gas used mismatch: have %d, want %d", number, usedGas, header.GasUsed)
+		}
+		if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != header.ReceiptHash {
+			return repaired, fmt.Errorf("block #%d receipt root mismatch: have %x, want %x", number, root, header.ReceiptHash)
+		}
+		if bloom := types.CreateBloom(receipts); bloom != header.Bloom {
+			return repaired, fmt.Errorf("block #%d bloom mismatch: have %x, want %x", number, bloom, header.Bloom)
+		}
+		root, err := statedb.Commit(bc.chainConfig.IsEIP158(block.Number()))
+		if err != nil {
+			return repaired, err
+		}
+		if root != header.Root {
+			return repaired, fmt.Errorf("block #%d state root mismatch: have %x, want %x", number, root, header.Root)
+		}
+		if statedb, err = state.New(root, database, nil); err != nil {
+			return repaired, fmt.Errorf("state reset after block #%d failed: %v", number, err)
+		}
+		database.TrieDB().Reference(root, common.Hash{})
+		if parent != (common.Hash{}) {
+			database.TrieDB().Dereference(parent)
+		}
+		parent = root
+
+		// Blocks before the requested range were only needed to rebuild the state
+		if number < from {
+			continue
+		}
+		if bc.hasValidReceipts(block, receipts) {
+			continue
+		}
+		// Stored receipts are missing or corrupted, rewrite them. Ancient data is
+		// immutable, so frozen receipts are overridden instead.
+		if frozen, err := bc.db.Ancients(); err == nil && number < frozen {
+			rawdb.WriteRepairedReceipts(bc.db, block.Hash(), number, receipts)
+		} else {
+			rawdb.WriteReceipts(bc.db, block.Hash(), number, receipts)
+		}
+		bc.receiptsCache.Remove(block.Hash())
+
+		log.Info("Repaired block receipts", "number", number, "hash", block.Hash(), "receipts", len(receipts))
+		repaired++
+	}
+	log.Info("Regenerated receipts", "from", from, "to", to, "repaired", repaired, "elapsed", common.PrettyDuration(time.Since(start)))
+	return repaired, nil
+}
+
+// hasValidReceipts checks whether the stored receipts of a block match the
+// freshly regenerated ones.
+func (bc *BlockChain) hasValidReceipts(block *types.Block, receipts types.Receipts) bool {
+	stored := rawdb.ReadReceiptsRLP(bc.db, block.Hash(), block.NumberU64())
+	if len(stored) == 0 {
+		return false
+	}
+	storageReceipts := make([]*types.ReceiptForStorage, len(receipts))
+	for i, receipt := range receipts {
+		storageReceipts[i] = (*types.ReceiptForStorage)(receipt)
+	}
+	blob, err := rlp.EncodeToBytes(storageReceipts)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(stored, blob)
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/trie"
+)
+
+// Tests that missing receipts are regenerated both in the key-value store and
+// over the frozen ones, leaving intact ones untouched.
+func TestRepairReceipts(t *testing.T) {
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create temp freezer db: %v", err)
+	}
+	defer db.Close()
+
+	chain, blocks := newTransferChain(t, db, params.TestChainConfig, 32)
+	defer chain.Stop()
+
+	// Corrupt some receipts that are about to be moved into the freezer
+	for _, number := range []uint64{5, 12} {
+		rawdb.WriteReceipts(db, blocks[number-1].Hash(), number, types.Receipts{})
+	}
+	db.(interface{ Freeze(threshold uint64) }).Freeze(16)
+	if frozen, _ := db.Ancients(); frozen != 17 {
+		t.Fatalf("frozen items mismatch: have %d, want %d", frozen, 17)
+	}
+	for _, number := range []uint64{5, 12} {
+		if receipts := rawdb.ReadRawReceipts(db, blocks[number-1].Hash(), number); len(receipts) != 0 {
+			t.Fatalf("block #%d: frozen receipts not corrupted: have %d receipts", number, len(receipts))
+		}
+	}
+	// Drop some receipts from the key-value store and check all get repaired
+	for _, number := range []uint64{20, 25} {
+		rawdb.DeleteReceipts(db, blocks[number-1].Hash(), number)
+	}
+	repaired, err := chain.RepairReceipts(1, 32, 0)
+	if err != nil {
+		t.Fatalf("failed to repair receipts: %v", err)
+	}
+	if repaired != 4 {
+		t.Fatalf("repaired block count mismatch: have %d, want %d", repaired, 4)
+	}
+	// The freezer should have been left alone, the frozen receipts overridden
+	if frozen, _ := db.Ancients(); frozen != 17 {
+		t.Fatalf("frozen items mismatch after repair: have %d, want %d", frozen, 17)
+	}
+	for _, block := range blocks {
+		receipts := rawdb.ReadRawReceipts(db, block.Hash(), block.NumberU64())
+		if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != block.ReceiptHash() {
+			t.Errorf("block #%d: receipt root mismatch: have %x, want %x", block.NumberU64(), root, block.ReceiptHash())
+		}
+	}
+	// Repairing again should find nothing left to repair
+	if repaired, err := chain.RepairReceipts(1, 32, 0); err != nil || repaired != 0 {
+		t.Fatalf("repeated repair mismatch: have %d (err %v), want %d", repaired, err, 0)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus/misc"
+	"github.com/ong2020/go-orange/core/state"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/trie"
+)
+
+// ReplayConfig is the alternative configuration a chain segment is re-executed
+// with when replaying it against the imported chain.
+type ReplayConfig struct {
+	ChainConfig *params.ChainConfig // Chain rules to replay with (nil = rules of the chain)
+	VMConfig    vm.Config           // EVM configuration to replay with
+	TrieCache   int                 // Memory allowance (MB) of the trie cache used during the replay
+	Reexec      uint64              // Maximum number of blocks to re-execute to regenerate the starting state
+}
+
+// ReplayDivergence is the first difference between the replayed and the
+// imported chain.
+type ReplayDivergence struct {
+	Number  uint64      `json:"number"`  // Number of the diverging block
+	Hash    common.Hash `json:"hash"`    // Hash of the diverging block
+	TxIndex int         `json:"txIndex"` // Index of the diverging transaction, -1 for block level differences
+	Field   string      `json:"field"`   // Name of the diverging field
+	Have    string      `json:"have"`    // Value produced by the replay
+	Want    string      `json:"want"`    // Value recorded in the imported chain
+}
+
+func (d *ReplayDivergence) String() string {
+	if d.TxIndex >= 0 {
+		return fmt.Sprintf("block #%d [%x…] tx %d: %s mismatch: have %s, want %s", d.Number, d.Hash[:4], d.TxIndex, d.Field, d.Have, d.Want)
+	}
+	return fmt.Sprintf("block #%d [%x…]: %s mismatch: have %s, want %s", d.Number, d.Hash[:4], d.Field, d.Have, d.Want)
+}
+
+// replayChain is the chain context of the replay, exposing the replayed chain
+// rules to the consensus engine (e.g. for fork dependent block rewards).
+type replayChain struct {
+	*BlockChain
+	config *params.ChainConfig
+}
+
+// Config overrides the chain configuration of the embedded blockchain.
+func (c *replayChain) Config() *params.ChainConfig { return c.config }
+
+// Replay re-executes the canonical blocks in the [first, last] range with the
+// given alternative configuration on top of the state of the block preceding
+// the range, comparing the receipts, gas usage, blooms and state roots block by
+// block against the imported chain. The first divergence found is returned, or
+// nil if the replay matched the imported chain.
+//
+// If the state of the block preceding the range is not available, up to reexec
+// blocks are executed with the rules of the chain to regenerate it.
+func (bc *BlockChain) Replay(first, last uint64, config *ReplayConfig) (*ReplayDivergence, error) {
+	if first == 0 {
+		first = 1 // Genesis is not executed
+	}
+	if first > last {
+		return nil, fmt.Errorf("invalid block range [%d, %d]", first, last)
+	}
+	if head := bc.CurrentBlock().NumberU64(); last > head {
+		return nil, fmt.Errorf("block range end #%d beyond current head #%d", last, head)
+	}
+	chain := &replayChain{BlockChain: bc, config: config.ChainConfig}
+	if chain.config == nil {
+		chain.config = bc.chainConfig
+	}
+	// Find the closest available state before the range to start executing from
+	base := bc.GetBlockByNumber(first - 1)
+	if base == nil {
+		return nil, fmt.Errorf("block #%d not found", first-1)
+	}
+	var (
+		database = state.NewDatabaseWithConfig(bc.db, &trie.Config{Cache: config.TrieCache})
+		statedb  *state.StateDB
+		err      error
+	)
+	for i := uint64(0); ; i++ {
+		if statedb, err = state.New(base.Root(), database, nil); err == nil {
+			break
+		}
+		if i >= config.Reexec || base.NumberU64() == 0 {
+			return nil, fmt.Errorf("required historical state unavailable (reexec=%d)", config.Reexec)
+		}
+		if base = bc.GetBlock(base.ParentHash(), base.NumberU64()-1); base == nil {
+			return nil, errors.New("missing ancestor block")
+		}
+	}
+	var (
+		start  = time.Now()
+		logged time.Time
+		parent common.Hash
+	)
+	defer func() {
+		if parent != (common.Hash{}) {
+			database.TrieDB().Dereference(parent)
+		}
+	}()
+	for number := base.NumberU64() + 1; number <= last; number++ {
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Replaying chain", "block", number, "target", last, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("block #%d not found", number)
+		}
+		// Blocks before the requested range only regenerate the starting state
+		// with the rules of the imported chain.
+		if number < first {
+			if _, _, _, err := bc.Processor().Process(block, statedb, *bc.GetVMConfig()); err != nil {
+				return nil, fmt.Errorf("processing block #%d failed: %v", number, err)
+			}
+		} else if diverged := chain.replayBlock(block, statedb, config.VMConfig); diverged != nil {
+			log.Warn("Replay diverged", "number", number, "hash", block.Hash(), "tx", diverged.TxIndex, "field", diverged.Field)
+			return diverged, nil
+		}
+		root, err := statedb.Commit(chain.config.IsEIP158(block.Number()))
+		if err != nil {
+			return nil, err
+		}
+		if root != block.Root() {
+			return &ReplayDivergence{
+				Number:  number,
+				Hash:    block.Hash(),
+				TxIndex: -1,
+				Field:   "root",
+				Have:    root.Hex(),
+				Want:    block.Root().Hex(),
+			}, nil
+		}
+		if statedb, err = state.New(root, database, nil); err != nil {
+			return nil, fmt.Errorf("state reset after block #%d failed: %v", number, err)
+		}
+		database.TrieDB().Reference(root, common.Hash{})
+		if parent != (common.Hash{}) {
+			database.TrieDB().Dereference(parent)
+		}
+		parent = root
+	}
+	log.Info("Replayed chain", "first", first, "last", last, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil, nil
+}
+
+// replayBlock executes a block with the replayed chain rules, comparing each
+// receipt against the imported one as soon as it is produced, and the block
+// level results after all transactions were applied. The state root is left
+// for the caller to check after committing the state.
+func (c *replayChain) replayBlock(block *types.Block, statedb *state.StateDB, cfg vm.Config) *ReplayDivergence {
+	var (
+		header   = block.Header()
+		stored   = c.GetReceiptsByHash(block.Hash())
+		receipts types.Receipts
+		usedGas  = new(uint64)
+		gp       = new(GasPool).AddGas(block.GasLimit())
+	)
+	diverge := func(index int, field string, have, want interface{}) *ReplayDivergence {
+		return &ReplayDivergence{
+			Number:  block.NumberU64(),
+			Hash:    block.Hash(),
+			TxIndex: index,
+			Field:   field,
+			Have:    fmt.Sprint(have),
+			Want:    fmt.Sprint(want),
+		}
+	}
+	if len(stored) != len(block.Transactions()) {
+		stored = nil // Receipts pruned or missing, only compare block level results
+	}
+	if c.config.DAOForkSupport && c.config.DAOForkBlock != nil && c.config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	vmenv := vm.NewEVM(NewEVMBlockContext(header, c, nil), vm.TxContext{}, statedb, c.config, cfg)
+	for i, tx := range block.Transactions() {
+		msg, err := tx.AsMessage(types.MakeSigner(c.config, header.Number))
+		if err != nil {
+			return diverge(i, "sender", err, "valid signature")
+		}
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+		receipt, err := applyTransaction(msg, c.config, c, nil, gp, statedb, header, tx, usedGas, vmenv)
+		if err != nil {
+			return diverge(i, "execution", err, "success")
+		}
+		if stored != nil {
+			if d := diffReceipts(receipt, stored[i]); d != nil {
+				return diverge(i, d[0], d[1], d[2])
+			}
+		}
+		receipts = append(receipts, receipt)
+	}
+	c.engine.Finalize(c, header, statedb, block.Transactions(), block.Uncles())
+
+	if *usedGas != header.GasUsed {
+		return diverge(-1, "gasUsed", *usedGas, header.GasUsed)
+	}
+	if root := types.DeriveSha(receipts, trie.NewStackTrie(nil)); root != header.ReceiptHash {
+		return diverge(-1, "receiptRoot", root.Hex(), header.ReceiptHash.Hex())
+	}
+	if bloom := types.CreateBloom(receipts); bloom != header.Bloom {
+		return diverge(-1, "bloom", common.Bytes2Hex(bloom[:]), common.Bytes2Hex(header.Bloom[:]))
+	}
+	return nil
+}
+
+// diffReceipts compares the consensus fields of a replayed and an imported
+// receipt, returning the name and the two values of the first differing field,
+// or nil if the receipts match.
+func diffReceipts(have, want *types.Receipt) []string {
+	switch {
+	case have.Type != want.Type:
+		return []string{"type", fmt.Sprint(have.Type), fmt.Sprint(want.Type)}
+	case have.Status != want.Status:
+		return []string{"status", fmt.Sprint(have.Status), fmt.Sprint(want.Status)}
+	case common.BytesToHash(have.PostState) != common.BytesToHash(want.PostState):
+		return []string{"postState", common.Bytes2Hex(have.PostState), common.Bytes2Hex(want.PostState)}
+	case have.CumulativeGasUsed != want.CumulativeGasUsed:
+		return []string{"cumulativeGasUsed", fmt.Sprint(have.CumulativeGasUsed), fmt.Sprint(want.CumulativeGasUsed)}
+	case len(have.Logs) != len(want.Logs):
+		return []string{"logs", fmt.Sprint(len(have.Logs)), fmt.Sprint(len(want.Logs))}
+	case have.Bloom != want.Bloom:
+		return []string{"logsBloom", common.Bytes2Hex(have.Bloom[:]), common.Bytes2Hex(want.Bloom[:])}
+	}
+	return nil
+}
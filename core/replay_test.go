@@ -0,0 +1,67 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/params"
+)
+
+// Tests that replaying a chain segment with the rules it was imported with
+// matches, and that replaying it with different rules reports the first
+// diverging transaction.
+func TestReplay(t *testing.T) {
+	chain, blocks := newTransferChain(t, rawdb.NewMemoryDatabase(), params.TestChainConfig, 16)
+	defer chain.Stop()
+
+	// Replaying with the original rules must match the imported chain
+	diverged, err := chain.Replay(4, 16, &ReplayConfig{TrieCache: 16})
+	if err != nil {
+		t.Fatalf("failed to replay chain: %v", err)
+	}
+	if diverged != nil {
+		t.Fatalf("unexpected divergence: %v", diverged)
+	}
+	// Replaying with Byzantium delayed must diverge at the first receipt
+	config := *params.TestChainConfig
+	config.ByzantiumBlock = big.NewInt(100)
+	config.ConstantinopleBlock = big.NewInt(100)
+	config.PetersburgBlock = big.NewInt(100)
+	config.IstanbulBlock = big.NewInt(100)
+	config.BerlinBlock = big.NewInt(100)
+
+	diverged, err = chain.Replay(4, 16, &ReplayConfig{ChainConfig: &config, TrieCache: 16})
+	if err != nil {
+		t.Fatalf("failed to replay chain: %v", err)
+	}
+	if diverged == nil {
+		t.Fatalf("divergence not detected")
+	}
+	if diverged.Number != 4 || diverged.Hash != blocks[3].Hash() || diverged.TxIndex != 0 || diverged.Field != "postState" {
+		t.Fatalf("divergence mismatch: have %v, want block #4 tx 0 postState", diverged)
+	}
+	// Invalid ranges must be rejected
+	if _, err := chain.Replay(8, 4, &ReplayConfig{}); err == nil {
+		t.Fatalf("inverted range accepted")
+	}
+	if _, err := chain.Replay(1, 17, &ReplayConfig{}); err == nil {
+		t.Fatalf("range beyond head accepted")
+	}
+}
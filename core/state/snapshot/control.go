@@ -0,0 +1,188 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/metrics"
+)
+
+var (
+	generatorAccountsGauge = metrics.NewRegisteredGauge("state/snapshot/generation/accounts", nil)
+	generatorSlotsGauge    = metrics.NewRegisteredGauge("state/snapshot/generation/slots", nil)
+	generatorStorageGauge  = metrics.NewRegisteredGauge("state/snapshot/generation/storage", nil)
+	generatorProgressGauge = metrics.NewRegisteredGauge("state/snapshot/generation/progress", nil)
+	generatorETAGauge      = metrics.NewRegisteredGauge("state/snapshot/generation/eta", nil)
+)
+
+// GeneratorStatus is a progress report of the background snapshot generation.
+type GeneratorStatus struct {
+	Generating bool          `json:"generating"`       // Whether the snapshot is still being generated
+	Paused     bool          `json:"paused"`           // Whether the generation is paused by the operator
+	Wiping     bool          `json:"wiping"`           // Whether a previous snapshot is still being wiped
+	Root       common.Hash   `json:"root"`             // State root the snapshot is generated for
+	Accounts   uint64        `json:"accounts"`         // Number of accounts indexed
+	Slots      uint64        `json:"slots"`            // Number of storage slots indexed
+	Storage    uint64        `json:"storage"`          // Size of the indexed accounts and slots in bytes
+	Marker     hexutil.Bytes `json:"marker,omitempty"` // Account (and slot) hash the generation reached
+	Progress   float64       `json:"progress"`         // Fraction of the account key space indexed
+	Elapsed    uint64        `json:"elapsed"`          // Seconds spent generating since the last (re)start
+	ETA        uint64        `json:"eta"`              // Estimated seconds left until completion (0 = unknown)
+}
+
+// generatorControl allows operators to pause and throttle the background
+// snapshot generation, e.g. to prioritize block processing and RPC latency on
+// constrained disks, and tracks its progress. It is shared by all the disk
+// layers of a snapshot tree, as the generator is restarted on a new disk layer
+// whenever diffs are flattened.
+//
+// A nil control is valid and never pauses or throttles the generator.
+type generatorControl struct {
+	resume chan struct{}    // Closed when the generation is resumed, nil if not paused
+	rate   int              // Maximum generated data written per second, 0 = unlimited
+	last   time.Time        // Time of the last throttled flush
+	status *GeneratorStatus // Last progress reported by the generator, nil if none
+	lock   sync.Mutex
+}
+
+// newGeneratorControl creates a control for an unpaused, unthrottled generator.
+func newGeneratorControl() *generatorControl {
+	return new(generatorControl)
+}
+
+// pause suspends the generator at its next flush point.
+func (gc *generatorControl) pause() {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	if gc.resume == nil {
+		gc.resume = make(chan struct{})
+	}
+}
+
+// unpause resumes a paused generator.
+func (gc *generatorControl) unpause() {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	if gc.resume != nil {
+		close(gc.resume)
+		gc.resume = nil
+	}
+}
+
+// paused reports whether generation is currently suspended.
+func (gc *generatorControl) paused() bool {
+	if gc == nil {
+		return false
+	}
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	return gc.resume != nil
+}
+
+// report records the latest progress of the generator.
+func (gc *generatorControl) report(status *GeneratorStatus) {
+	generatorAccountsGauge.Update(int64(status.Accounts))
+	generatorSlotsGauge.Update(int64(status.Slots))
+	generatorStorageGauge.Update(int64(status.Storage))
+	generatorProgressGauge.Update(int64(status.Progress * 100))
+	generatorETAGauge.Update(int64(status.ETA))
+
+	if gc == nil {
+		return
+	}
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	gc.status = status
+}
+
+// progress returns a copy of the latest progress reported by the generator, or
+// nil if nothing was reported yet.
+func (gc *generatorControl) progress() *GeneratorStatus {
+	if gc == nil {
+		return nil
+	}
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	if gc.status == nil {
+		return nil
+	}
+	status := *gc.status
+	return &status
+}
+
+// setRate limits the data written by the generator to the given amount of bytes
+// per second, 0 meaning unlimited.
+func (gc *generatorControl) setRate(rate int) {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+
+	gc.rate = rate
+}
+
+// wait is called by the generator after flushing a batch of the given size. It
+// blocks as long as the generation is paused or needs to be throttled, returning
+// early if an abort request arrives in the meantime.
+func (gc *generatorControl) wait(written int, abort chan chan *generatorStats) chan *generatorStats {
+	if gc == nil {
+		return nil
+	}
+	// Throttle the generator if it wrote data faster than allowed
+	gc.lock.Lock()
+	var delay time.Duration
+	if gc.rate > 0 && written > 0 {
+		delay = time.Duration(float64(written)/float64(gc.rate)*float64(time.Second)) - time.Since(gc.last)
+	}
+	gc.lock.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case req := <-abort:
+			timer.Stop()
+			return req
+		}
+	}
+	// Block until resumed if the generation was paused
+	for {
+		gc.lock.Lock()
+		resume := gc.resume
+		gc.lock.Unlock()
+
+		if resume == nil {
+			break
+		}
+		select {
+		case <-resume:
+		case req := <-abort:
+			return req
+		}
+	}
+	gc.lock.Lock()
+	gc.last = time.Now()
+	gc.lock.Unlock()
+	return nil
+}
@@ -78,23 +78,58 @@ func (gs *generatorStats) Log(msg string, root common.Hash, marker []byte) {
 		"elapsed", common.PrettyDuration(time.Since(gs.start)),
 	}...)
 	// Calculate the estimated indexing time based on current stats
-	if len(marker) > 0 {
-		if done := binary.BigEndian.Uint64(marker[:8]) - gs.origin; done > 0 {
-			left := math.MaxUint64 - binary.BigEndian.Uint64(marker[:8])
-
-			speed := done/uint64(time.Since(gs.start)/time.Millisecond+1) + 1 // +1s to avoid division by zero
-			ctx = append(ctx, []interface{}{
-				"eta", common.PrettyDuration(time.Duration(left/speed) * time.Millisecond),
-			}...)
-		}
+	if eta := gs.eta(marker); eta > 0 {
+		ctx = append(ctx, []interface{}{
+			"eta", common.PrettyDuration(eta),
+		}...)
 	}
 	log.Info(msg, ctx...)
 }
 
+// eta estimates the time left until the generation completes, based on the
+// speed the generator advanced through the key space so far. Zero is returned
+// if no estimate can be made yet.
+func (gs *generatorStats) eta(marker []byte) time.Duration {
+	if len(marker) == 0 {
+		return 0
+	}
+	done := binary.BigEndian.Uint64(marker[:8]) - gs.origin
+	if done == 0 {
+		return 0
+	}
+	left := math.MaxUint64 - binary.BigEndian.Uint64(marker[:8])
+
+	speed := done/uint64(time.Since(gs.start)/time.Millisecond+1) + 1 // +1s to avoid division by zero
+	return time.Duration(left/speed) * time.Millisecond
+}
+
+// status creates a progress report of the generation of the given state root,
+// which reached the given marker.
+func (gs *generatorStats) status(root common.Hash, marker []byte) *GeneratorStatus {
+	status := &GeneratorStatus{
+		Generating: marker != nil,
+		Wiping:     gs.wiping != nil,
+		Root:       root,
+		Accounts:   gs.accounts,
+		Slots:      gs.slots,
+		Storage:    uint64(gs.storage),
+		Marker:     common.CopyBytes(marker),
+		Elapsed:    uint64(time.Since(gs.start) / time.Second),
+		ETA:        uint64(gs.eta(marker) / time.Second),
+	}
+	switch {
+	case marker == nil:
+		status.Progress = 1
+	case len(marker) > 0:
+		status.Progress = float64(binary.BigEndian.Uint64(marker[:8])) / float64(math.MaxUint64)
+	}
+	return status
+}
+
 // generateSnapshot regenerates a brand new snapshot based on an existing state
 // database and head block asynchronously. The snapshot is returned immediately
 // and generation is continued in the background until done.
-func generateSnapshot(diskdb ongdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash, wiper chan struct{}) *diskLayer {
+func generateSnapshot(diskdb ongdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash, wiper chan struct{}, control *generatorControl) *diskLayer {
 	// Wipe any previously existing snapshot from the database if no wiper is
 	// currently in progress.
 	if wiper == nil {
@@ -119,6 +154,7 @@ func generateSnapshot(diskdb ongdb.KeyValueStore, triedb *trie.Database, cache i
 		genMarker:  genMarker,
 		genPending: make(chan struct{}),
 		genAbort:   make(chan chan *generatorStats),
+		genControl: control,
 	}
 	go base.generate(stats)
 	log.Debug("Start snapshot generation", "root", root)
@@ -166,6 +202,7 @@ func journalProgress(db ongdb.KeyValueWriter, marker []byte, stats *generatorSta
 func (dl *diskLayer) generate(stats *generatorStats) {
 	// If a database wipe is in operation, wait until it's done
 	if stats.wiping != nil {
+		dl.genControl.report(stats.status(dl.root, dl.genMarker))
 		stats.Log("Wiper running, state snapshotting paused", common.Hash{}, dl.genMarker)
 		select {
 		// If wiper is done, resume normal mode of operation
@@ -189,6 +226,7 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 		abort <- stats
 		return
 	}
+	dl.genControl.report(stats.status(dl.root, dl.genMarker))
 	stats.Log("Resuming state snapshot generation", dl.root, dl.genMarker)
 
 	var accMarker []byte
@@ -227,9 +265,10 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 		case abort = <-dl.genAbort:
 		default:
 		}
-		if batch.ValueSize() > ongdb.IdealBatchSize || abort != nil {
+		if batch.ValueSize() > ongdb.IdealBatchSize || abort != nil || dl.genControl.paused() {
 			// Only write and set the marker if we actually did somonging useful
-			if batch.ValueSize() > 0 {
+			written := batch.ValueSize()
+			if written > 0 {
 				// Ensure the generator entry is in sync with the data
 				marker := accountHash[:]
 				journalProgress(batch, marker, stats)
@@ -240,6 +279,15 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 				dl.lock.Lock()
 				dl.genMarker = marker
 				dl.lock.Unlock()
+
+				dl.genControl.report(stats.status(dl.root, marker))
+			}
+			// Wait if the generation is paused or throttled by the operator
+			if abort == nil {
+				if dl.genControl.paused() {
+					stats.Log("Pausing state snapshot generation", dl.root, accountHash[:])
+				}
+				abort = dl.genControl.wait(written, dl.genAbort)
 			}
 			if abort != nil {
 				stats.Log("Aborting state snapshot generation", dl.root, accountHash[:])
@@ -272,9 +320,10 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 				case abort = <-dl.genAbort:
 				default:
 				}
-				if batch.ValueSize() > ongdb.IdealBatchSize || abort != nil {
+				if batch.ValueSize() > ongdb.IdealBatchSize || abort != nil || dl.genControl.paused() {
 					// Only write and set the marker if we actually did somonging useful
-					if batch.ValueSize() > 0 {
+					written := batch.ValueSize()
+					if written > 0 {
 						// Ensure the generator entry is in sync with the data
 						marker := append(accountHash[:], storeIt.Key...)
 						journalProgress(batch, marker, stats)
@@ -285,6 +334,15 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 						dl.lock.Lock()
 						dl.genMarker = marker
 						dl.lock.Unlock()
+
+						dl.genControl.report(stats.status(dl.root, marker))
+					}
+					// Wait if the generation is paused or throttled by the operator
+					if abort == nil {
+						if dl.genControl.paused() {
+							stats.Log("Pausing state snapshot generation", dl.root, append(accountHash[:], storeIt.Key...))
+						}
+						abort = dl.genControl.wait(written, dl.genAbort)
 					}
 					if abort != nil {
 						stats.Log("Aborting state snapshot generation", dl.root, append(accountHash[:], storeIt.Key...))
@@ -322,6 +380,7 @@ func (dl *diskLayer) generate(stats *generatorStats) {
 	// generator anyway to mark the snapshot is complete.
 	journalProgress(batch, nil, stats)
 	batch.Write()
+	dl.genControl.report(stats.status(dl.root, nil))
 
 	log.Info("Generated state snapshot", "accounts", stats.accounts, "slots", stats.slots,
 		"storage", stats.storage, "elapsed", common.PrettyDuration(time.Since(stats.start)))
@@ -17,6 +17,7 @@
 package snapshot
 
 import (
+	"fmt"
 	"math/big"
 	"testing"
 	"time"
@@ -55,7 +56,7 @@ func TestGenerateCorruptAccountTrie(t *testing.T) {
 	triedb.Commit(common.HexToHash("0xa04693ea110a31037fb5ee814308a6f1d76bdab0b11676bdf4541d2de55ba978"), false, nil)
 	diskdb.Delete(common.HexToHash("0x65145f923027566669a1ae5ccac66f945b55ff6eaeb17d2ea8e048b7d381f2d7").Bytes())
 
-	snap := generateSnapshot(diskdb, triedb, 16, common.HexToHash("0xa04693ea110a31037fb5ee814308a6f1d76bdab0b11676bdf4541d2de55ba978"), nil)
+	snap := generateSnapshot(diskdb, triedb, 16, common.HexToHash("0xa04693ea110a31037fb5ee814308a6f1d76bdab0b11676bdf4541d2de55ba978"), nil, nil)
 	select {
 	case <-snap.genPending:
 		// Snapshot generation succeeded
@@ -115,7 +116,7 @@ func TestGenerateMissingStorageTrie(t *testing.T) {
 	// Delete a storage trie root and ensure the generator chokes
 	diskdb.Delete(common.HexToHash("0xddefcd9376dd029653ef384bd2f0a126bb755fe84fdcc9e7cf421ba454f2bc67").Bytes())
 
-	snap := generateSnapshot(diskdb, triedb, 16, common.HexToHash("0xe3712f1a226f3782caca78ca770ccc19ee000552813a9f59d479f8611db9b1fd"), nil)
+	snap := generateSnapshot(diskdb, triedb, 16, common.HexToHash("0xe3712f1a226f3782caca78ca770ccc19ee000552813a9f59d479f8611db9b1fd"), nil, nil)
 	select {
 	case <-snap.genPending:
 		// Snapshot generation succeeded
@@ -174,7 +175,7 @@ func TestGenerateCorruptStorageTrie(t *testing.T) {
 	// Delete a storage trie leaf and ensure the generator chokes
 	diskdb.Delete(common.HexToHash("0x18a0f4d79cff4459642dd7604f303886ad9d77c30cf3d7d7cedb3a693ab6d371").Bytes())
 
-	snap := generateSnapshot(diskdb, triedb, 16, common.HexToHash("0xe3712f1a226f3782caca78ca770ccc19ee000552813a9f59d479f8611db9b1fd"), nil)
+	snap := generateSnapshot(diskdb, triedb, 16, common.HexToHash("0xe3712f1a226f3782caca78ca770ccc19ee000552813a9f59d479f8611db9b1fd"), nil, nil)
 	select {
 	case <-snap.genPending:
 		// Snapshot generation succeeded
@@ -188,3 +189,98 @@ func TestGenerateCorruptStorageTrie(t *testing.T) {
 	snap.genAbort <- stop
 	<-stop
 }
+
+// Tests that a paused snapshot generation doesn't progress until resumed, and
+// that it can still be aborted while paused.
+func TestGeneratePauseResume(t *testing.T) {
+	var (
+		diskdb = memorydb.New()
+		triedb = trie.NewDatabase(diskdb)
+	)
+	tr, _ := trie.NewSecure(common.Hash{}, triedb)
+	for i := 1; i <= 3; i++ {
+		acc := &Account{Balance: big.NewInt(int64(i)), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()}
+		val, _ := rlp.EncodeToBytes(acc)
+		tr.Update([]byte(fmt.Sprintf("acc-%d", i)), val)
+	}
+	root, _ := tr.Commit(nil)
+	triedb.Commit(root, false, nil)
+
+	control := newGeneratorControl()
+	control.pause()
+
+	snap := generateSnapshot(diskdb, triedb, 16, root, nil, control)
+	select {
+	case <-snap.genPending:
+		t.Fatalf("Snapshot generated while paused")
+	case <-time.After(250 * time.Millisecond):
+	}
+	control.unpause()
+	select {
+	case <-snap.genPending:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Snapshot generation not resumed")
+	}
+	stop := make(chan *generatorStats)
+	snap.genAbort <- stop
+	<-stop
+
+	// Ensure a paused generator can be aborted
+	control.pause()
+	snap = generateSnapshot(diskdb, triedb, 16, root, nil, control)
+	time.Sleep(50 * time.Millisecond)
+
+	stop = make(chan *generatorStats)
+	snap.genAbort <- stop
+	if stats := <-stop; stats == nil {
+		t.Errorf("Paused generator reported completion on abort")
+	}
+}
+
+// Tests that the generator reports its progress through its control.
+func TestGenerateProgress(t *testing.T) {
+	var (
+		diskdb = memorydb.New()
+		triedb = trie.NewDatabase(diskdb)
+	)
+	tr, _ := trie.NewSecure(common.Hash{}, triedb)
+	for i := 1; i <= 3; i++ {
+		acc := &Account{Balance: big.NewInt(int64(i)), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()}
+		val, _ := rlp.EncodeToBytes(acc)
+		tr.Update([]byte(fmt.Sprintf("acc-%d", i)), val)
+	}
+	root, _ := tr.Commit(nil)
+	triedb.Commit(root, false, nil)
+
+	// Pause the generator after the first account and check the progress
+	control := newGeneratorControl()
+	control.pause()
+
+	snap := generateSnapshot(diskdb, triedb, 16, root, nil, control)
+	time.Sleep(250 * time.Millisecond)
+
+	status := control.progress()
+	if status == nil {
+		t.Fatalf("No progress reported")
+	}
+	if !status.Generating || status.Root != root || status.Accounts != 1 || len(status.Marker) != common.HashLength {
+		t.Errorf("Paused progress mismatch: %+v", status)
+	}
+	if status.Progress <= 0 || status.Progress >= 1 {
+		t.Errorf("Paused progress fraction out of bounds: %v", status.Progress)
+	}
+	// Resume the generation and check the final progress
+	control.unpause()
+	select {
+	case <-snap.genPending:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Snapshot generation not resumed")
+	}
+	status = control.progress()
+	if status.Generating || status.Accounts != 3 || status.Marker != nil || status.Progress != 1 || status.ETA != 0 {
+		t.Errorf("Final progress mismatch: %+v", status)
+	}
+	stop := make(chan *generatorStats)
+	snap.genAbort <- stop
+	<-stop
+}
@@ -87,8 +87,9 @@ func loadAndParseLegacyJournal(db ongdb.KeyValueStore, base *diskLayer) (snapsho
 	return snapshot, generator, nil
 }
 
-// loadAndParseJournal tries to parse the snapshot journal in latest format.
-func loadAndParseJournal(db ongdb.KeyValueStore, base *diskLayer) (snapshot, journalGenerator, error) {
+// loadAndParseJournal tries to parse the snapshot journal in latest format,
+// reconstructing the diff layers between the disk layer and the chain head.
+func loadAndParseJournal(db ongdb.KeyValueStore, base *diskLayer, head common.Hash) (snapshot, journalGenerator, error) {
 	// Retrieve the disk layer generator. It must exist, no matter the
 	// snapshot is fully generated or not. Otherwise the entire disk
 	// layer is invalid.
@@ -130,24 +131,51 @@ func loadAndParseJournal(db ongdb.KeyValueStore, base *diskLayer) (snapshot, jou
 	if err := r.Decode(&root); err != nil {
 		return nil, journalGenerator{}, errors.New("missing disk layer root")
 	}
-	// The diff journal is not matched with disk, discard them.
-	// It can happen that Gong crashes without persisting the latest
-	// diff journal.
-	if !bytes.Equal(root.Bytes(), base.root.Bytes()) {
-		log.Warn("Loaded snapshot journal", "diskroot", base.root, "diffs", "unmatched")
-		return base, generator, nil
-	}
 	// Load all the snapshot diffs from the journal
-	snapshot, err := loadDiffLayer(base, r)
+	diffs, err := readDiffLayers(r)
 	if err != nil {
 		return nil, journalGenerator{}, err
 	}
-	log.Debug("Loaded snapshot journal", "diskroot", base.root, "diffhead", snapshot.Root())
+	// If the journal was checkpointed while running, the disk layer might have
+	// advanced since by flattening some of the journalled diffs. Skip the ones
+	// already persisted. If the disk layer is not part of the journal at all,
+	// discard the diffs. It can happen that Gong crashes without persisting the
+	// latest diff journal.
+	if root != base.root {
+		var found bool
+		for i, diff := range diffs {
+			if diff.root == base.root {
+				diffs, found = diffs[i+1:], true
+				break
+			}
+		}
+		if !found {
+			log.Warn("Loaded snapshot journal", "diskroot", base.root, "diffs", "unmatched")
+			return base, generator, nil
+		}
+	}
+	// A checkpointed journal might also extend beyond the chain head (e.g. the
+	// chain was rewound to the last persisted state after a crash), drop any
+	// diffs above it.
+	if head == base.root {
+		diffs = nil
+	}
+	for i, diff := range diffs {
+		if diff.root == head {
+			diffs = diffs[:i+1]
+			break
+		}
+	}
+	var snapshot snapshot = base
+	for _, diff := range diffs {
+		snapshot = newDiffLayer(snapshot, diff.root, diff.destructs, diff.accounts, diff.storage)
+	}
+	log.Debug("Loaded snapshot journal", "diskroot", base.root, "diffhead", snapshot.Root(), "diffs", len(diffs))
 	return snapshot, generator, nil
 }
 
 // loadSnapshot loads a pre-existing state snapshot backed by a key-value store.
-func loadSnapshot(diskdb ongdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash, recovery bool) (snapshot, error) {
+func loadSnapshot(diskdb ongdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash, recovery bool, control *generatorControl) (snapshot, error) {
 	// Retrieve the block number and hash of the snapshot, failing if no snapshot
 	// is present in the database (or crashed mid-update).
 	baseRoot := rawdb.ReadSnapshotRoot(diskdb)
@@ -155,13 +183,14 @@ func loadSnapshot(diskdb ongdb.KeyValueStore, triedb *trie.Database, cache int,
 		return nil, errors.New("missing or corrupted snapshot")
 	}
 	base := &diskLayer{
-		diskdb: diskdb,
-		triedb: triedb,
-		cache:  fastcache.New(cache * 1024 * 1024),
-		root:   baseRoot,
+		diskdb:     diskdb,
+		triedb:     triedb,
+		cache:      fastcache.New(cache * 1024 * 1024),
+		root:       baseRoot,
+		genControl: control,
 	}
 	var legacy bool
-	snapshot, generator, err := loadAndParseJournal(diskdb, base)
+	snapshot, generator, err := loadAndParseJournal(diskdb, base, root)
 	if err != nil {
 		log.Warn("Failed to load new-format journal", "error", err)
 		snapshot, generator, err = loadAndParseLegacyJournal(diskdb, base)
@@ -225,15 +254,53 @@ func loadSnapshot(diskdb ongdb.KeyValueStore, triedb *trie.Database, cache int,
 	return snapshot, nil
 }
 
+// journalDiff is a diff layer read from the snapshot journal, not yet linked to
+// its parent.
+type journalDiff struct {
+	root      common.Hash
+	destructs map[common.Hash]struct{}
+	accounts  map[common.Hash][]byte
+	storage   map[common.Hash]map[common.Hash][]byte
+}
+
 // loadDiffLayer reads the next sections of a snapshot journal, reconstructing a new
 // diff and verifying that it can be linked to the requested parent.
 func loadDiffLayer(parent snapshot, r *rlp.Stream) (snapshot, error) {
+	diff, err := readDiffLayer(r)
+	if err == io.EOF {
+		return parent, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loadDiffLayer(newDiffLayer(parent, diff.root, diff.destructs, diff.accounts, diff.storage), r)
+}
+
+// readDiffLayers reads all the remaining diffs of a snapshot journal, from the
+// bottom-most to the top-most one.
+func readDiffLayers(r *rlp.Stream) ([]*journalDiff, error) {
+	var diffs []*journalDiff
+	for {
+		diff, err := readDiffLayer(r)
+		if err == io.EOF {
+			return diffs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+}
+
+// readDiffLayer reads the next sections of a snapshot journal, returning io.EOF
+// if the end of the journal was reached.
+func readDiffLayer(r *rlp.Stream) (*journalDiff, error) {
 	// Read the next diff journal entry
 	var root common.Hash
 	if err := r.Decode(&root); err != nil {
 		// The first read may fail with EOF, marking the end of the journal
 		if err == io.EOF {
-			return parent, nil
+			return nil, io.EOF
 		}
 		return nil, fmt.Errorf("load diff root: %v", err)
 	}
@@ -273,7 +340,12 @@ func loadDiffLayer(parent snapshot, r *rlp.Stream) (snapshot, error) {
 		}
 		storageData[entry.Hash] = slots
 	}
-	return loadDiffLayer(newDiffLayer(parent, root, destructSet, accountData, storageData), r)
+	return &journalDiff{
+		root:      root,
+		destructs: destructSet,
+		accounts:  accountData,
+		storage:   storageData,
+	}, nil
 }
 
 // Journal terminates any in-progress snapshot generation, also implicitly pushing
@@ -311,30 +383,39 @@ func (dl *diffLayer) Journal(buffer *bytes.Buffer) (common.Hash, error) {
 	if err != nil {
 		return common.Hash{}, err
 	}
+	// Everything below was journalled, persist this layer too
+	if err := dl.journalLayer(buffer); err != nil {
+		return common.Hash{}, err
+	}
+	return base, nil
+}
+
+// journalLayer writes the contents of this single layer into a buffer to be
+// stored in the database as part of the snapshot journal.
+func (dl *diffLayer) journalLayer(buffer *bytes.Buffer) error {
 	// Ensure the layer didn't get stale
 	dl.lock.RLock()
 	defer dl.lock.RUnlock()
 
 	if dl.Stale() {
-		return common.Hash{}, ErrSnapshotStale
+		return ErrSnapshotStale
 	}
-	// Everything below was journalled, persist this layer too
 	if err := rlp.Encode(buffer, dl.root); err != nil {
-		return common.Hash{}, err
+		return err
 	}
 	destructs := make([]journalDestruct, 0, len(dl.destructSet))
 	for hash := range dl.destructSet {
 		destructs = append(destructs, journalDestruct{Hash: hash})
 	}
 	if err := rlp.Encode(buffer, destructs); err != nil {
-		return common.Hash{}, err
+		return err
 	}
 	accounts := make([]journalAccount, 0, len(dl.accountData))
 	for hash, blob := range dl.accountData {
 		accounts = append(accounts, journalAccount{Hash: hash, Blob: blob})
 	}
 	if err := rlp.Encode(buffer, accounts); err != nil {
-		return common.Hash{}, err
+		return err
 	}
 	storage := make([]journalStorage, 0, len(dl.storageData))
 	for hash, slots := range dl.storageData {
@@ -347,10 +428,10 @@ func (dl *diffLayer) Journal(buffer *bytes.Buffer) (common.Hash, error) {
 		storage = append(storage, journalStorage{Hash: hash, Keys: keys, Vals: vals})
 	}
 	if err := rlp.Encode(buffer, storage); err != nil {
-		return common.Hash{}, err
+		return err
 	}
 	log.Debug("Journalled diff layer", "root", dl.root, "parent", dl.parent.Root())
-	return base, nil
+	return nil
 }
 
 // LegacyJournal writes the persistent layer generator stats into a buffer
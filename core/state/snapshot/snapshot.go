@@ -167,6 +167,8 @@ type Tree struct {
 	cache  int                      // Megabytes permitted to use for read caches
 	layers map[common.Hash]snapshot // Collection of all known layers
 	lock   sync.RWMutex
+
+	genControl *generatorControl // Operator control of the background generation
 }
 
 // New attempts to load an already existing snapshot from a persistent key-value
@@ -185,12 +187,14 @@ func New(diskdb ongdb.KeyValueStore, triedb *trie.Database, cache int, root comm
 		triedb: triedb,
 		cache:  cache,
 		layers: make(map[common.Hash]snapshot),
+
+		genControl: newGeneratorControl(),
 	}
 	if !async {
 		defer snap.waitBuild()
 	}
 	// Attempt to load a previously persisted snapshot and rebuild one if failed
-	head, err := loadSnapshot(diskdb, triedb, cache, root, recovery)
+	head, err := loadSnapshot(diskdb, triedb, cache, root, recovery, snap.genControl)
 	if err != nil {
 		if rebuild {
 			log.Warn("Failed to load snapshot, regenerating", "err", err)
@@ -549,6 +553,7 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 		triedb:     base.triedb,
 		genMarker:  base.genMarker,
 		genPending: base.genPending,
+		genControl: base.genControl,
 	}
 	// If snapshot generation hasn't finished yet, port over all the starts and
 	// continue where the previous round left off.
@@ -603,6 +608,52 @@ func (t *Tree) Journal(root common.Hash) (common.Hash, error) {
 	return base, nil
 }
 
+// Checkpoint writes the diff layers between the disk layer and the given head
+// snapshot into the database as the snapshot journal. As opposed to Journal, it
+// doesn't interrupt the background generation, so it can be called periodically
+// to limit the amount of diffs lost if Gong crashes. The disk layer itself may
+// advance after a checkpoint, the journal loader skips any diffs already flattened.
+func (t *Tree) Checkpoint(root common.Hash) error {
+	// Retrieve the head snapshot to journal from var snap snapshot
+	snap := t.Snapshot(root)
+	if snap == nil {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	// Gather the diff layers with the tree locked, so none are flattened
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var diffs []*diffLayer
+	for layer := snap.(snapshot); ; layer = layer.Parent() {
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, diff)
+	}
+	diskroot := t.diskRoot()
+	if diskroot == (common.Hash{}) {
+		return errors.New("invalid disk root")
+	}
+	// Write out the metadata and the disk layer root, followed by the diffs from
+	// the bottom-most to the top-most one
+	journal := new(bytes.Buffer)
+	if err := rlp.Encode(journal, journalVersion); err != nil {
+		return err
+	}
+	if err := rlp.Encode(journal, diskroot); err != nil {
+		return err
+	}
+	for i := len(diffs) - 1; i >= 0; i-- {
+		if err := diffs[i].journalLayer(journal); err != nil {
+			return err
+		}
+	}
+	rawdb.WriteSnapshotJournal(t.diskdb, journal.Bytes())
+	log.Debug("Checkpointed snapshot journal", "diskroot", diskroot, "head", root, "diffs", len(diffs), "size", common.StorageSize(journal.Len()))
+	return nil
+}
+
 // LegacyJournal is basically identical to Journal. it's the legacy
 // version for flushing legacy journal. Now the only purpose of this
 // function is for testing.
@@ -672,7 +723,7 @@ func (t *Tree) Rebuild(root common.Hash) {
 	// generator will run a wiper first if there's not one running right now.
 	log.Info("Rebuilding state snapshot")
 	t.layers = map[common.Hash]snapshot{
-		root: generateSnapshot(t.diskdb, t.triedb, t.cache, root, wiper),
+		root: generateSnapshot(t.diskdb, t.triedb, t.cache, root, wiper, t.genControl),
 	}
 }
 
@@ -765,6 +816,53 @@ func (t *Tree) diskRoot() common.Hash {
 	return disklayer.Root()
 }
 
+// PauseGeneration suspends the background snapshot generation (if any) at its
+// next flush point until ResumeGeneration is called. The progress is persisted,
+// so the generation still resumes where it left off after a restart.
+func (t *Tree) PauseGeneration() {
+	if t.genControl != nil {
+		t.genControl.pause()
+	}
+}
+
+// ResumeGeneration continues a previously paused background snapshot generation.
+func (t *Tree) ResumeGeneration() {
+	if t.genControl != nil {
+		t.genControl.unpause()
+	}
+}
+
+// GenerationPaused reports whether the background snapshot generation is paused.
+func (t *Tree) GenerationPaused() bool {
+	return t.genControl.paused()
+}
+
+// SetGenerationRate limits the amount of snapshot data written per second by the
+// background generator, throttling the disk IO it causes. Zero means unlimited.
+func (t *Tree) SetGenerationRate(rate int) {
+	if t.genControl != nil {
+		t.genControl.setRate(rate)
+	}
+}
+
+// GenerationStatus reports the progress of the background snapshot generation.
+func (t *Tree) GenerationStatus() (*GeneratorStatus, error) {
+	generating, err := t.generating()
+	if err != nil {
+		return nil, err
+	}
+	status := t.genControl.progress()
+	if status == nil {
+		status = &GeneratorStatus{Root: t.DiskRoot()}
+	}
+	status.Generating = generating
+	status.Paused = t.GenerationPaused()
+	if !generating {
+		status.Wiping, status.Marker, status.Progress, status.ETA = false, nil, 1, 0
+	}
+	return status, nil
+}
+
 // generating is an internal helper function which reports whonger the snapshot
 // is still under the construction.
 func (t *Tree) generating() (bool, error) {
@@ -423,3 +423,71 @@ func TestSnaphots(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a snapshot journal checkpointed while running can be loaded even
+// after the disk layer advanced past its bottom-most diffs, and that any diffs
+// above the requested chain head are dropped.
+func TestCheckpointRecovery(t *testing.T) {
+	// Create a persisted, fully generated base layer and a few diffs on top
+	diskdb := rawdb.NewMemoryDatabase()
+	rawdb.WriteSnapshotRoot(diskdb, common.HexToHash("0x01"))
+	journalProgress(diskdb, nil, nil)
+
+	base := &diskLayer{
+		diskdb: diskdb,
+		root:   common.HexToHash("0x01"),
+		cache:  fastcache.New(1024 * 500),
+	}
+	snaps := &Tree{
+		diskdb: diskdb,
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	accounts := make(map[common.Hash][]byte)
+	for i := 2; i <= 5; i++ {
+		root, parent := common.BigToHash(big.NewInt(int64(i))), common.BigToHash(big.NewInt(int64(i-1)))
+		account := map[common.Hash][]byte{common.BigToHash(big.NewInt(int64(0xa0 + i))): randomAccount()}
+		for hash, blob := range account {
+			accounts[hash] = blob
+		}
+		if err := snaps.Update(root, parent, nil, account, nil); err != nil {
+			t.Fatalf("failed to create diff layer %d: %v", i, err)
+		}
+	}
+	if err := snaps.Checkpoint(common.HexToHash("0x05")); err != nil {
+		t.Fatalf("failed to checkpoint snapshot: %v", err)
+	}
+	// Flatten the bottom diffs into the disk layer after the checkpoint
+	if err := snaps.Cap(common.HexToHash("0x03"), 0); err != nil {
+		t.Fatalf("failed to flatten diff layers: %v", err)
+	}
+	if root := rawdb.ReadSnapshotRoot(diskdb); root != common.HexToHash("0x03") {
+		t.Fatalf("disk layer root mismatch: have %x, want %x", root, common.HexToHash("0x03"))
+	}
+	// Reload the snapshot and ensure the remaining diffs are reconstructed
+	head, err := loadSnapshot(diskdb, nil, 16, common.HexToHash("0x05"), false, nil)
+	if err != nil {
+		t.Fatalf("failed to load checkpointed snapshot: %v", err)
+	}
+	if head.Root() != common.HexToHash("0x05") || head.Parent().Root() != common.HexToHash("0x04") || head.Parent().Parent().Root() != common.HexToHash("0x03") {
+		t.Fatalf("reconstructed layers mismatch")
+	}
+	for hash, blob := range accounts {
+		have, err := head.AccountRLP(hash)
+		if err != nil {
+			t.Fatalf("failed to retrieve account %x: %v", hash, err)
+		}
+		if string(have) != string(blob) {
+			t.Errorf("account %x mismatch: have %x, want %x", hash, have, blob)
+		}
+	}
+	// Reload the snapshot for an older chain head and ensure newer diffs are dropped
+	head, err = loadSnapshot(diskdb, nil, 16, common.HexToHash("0x04"), false, nil)
+	if err != nil {
+		t.Fatalf("failed to load checkpointed snapshot: %v", err)
+	}
+	if head.Root() != common.HexToHash("0x04") {
+		t.Fatalf("head mismatch: have %x, want %x", head.Root(), common.HexToHash("0x04"))
+	}
+}
@@ -24,7 +24,9 @@ import (
 	"time"
 
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
 	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/log"
 	"github.com/ong2020/go-orange/metrics"
 	"github.com/ong2020/go-orange/rlp"
 )
@@ -235,6 +237,9 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 			return common.Hash{}
 		}
 		enc, err = s.db.snap.Storage(s.addrHash, crypto.Keccak256Hash(key.Bytes()))
+		if err == nil && s.db.snapVerify {
+			enc = s.verifySnapshotStorage(db, key, enc)
+		}
 	}
 	// If snapshot unavailable or reading from it failed, load from the database
 	if s.db.snap == nil || err != nil {
@@ -264,6 +269,21 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 	return value
 }
 
+// verifySnapshotStorage checks a storage value read from the snapshot against
+// the trie, returning the value of the trie.
+func (s *stateObject) verifySnapshotStorage(db Database, key common.Hash, snap []byte) []byte {
+	enc, err := s.getTrie(db).TryGet(key.Bytes())
+	if err != nil {
+		log.Warn("Failed to verify snapshot storage", "root", s.db.originalRoot, "addr", s.address, "key", key, "err", err)
+		return snap
+	}
+	if !bytes.Equal(snap, enc) {
+		snapshotMismatchMeter.Mark(1)
+		log.Error("Snapshot storage mismatch", "root", s.db.originalRoot, "addr", s.address, "key", key, "snapshot", hexutil.Bytes(snap), "trie", hexutil.Bytes(enc))
+	}
+	return enc
+}
+
 // SetState updates a value in account storage.
 func (s *stateObject) SetState(db Database, key, value common.Hash) {
 	// If the fake storage is set, put the temporary state update here.
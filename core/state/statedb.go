@@ -18,6 +18,7 @@
 package state
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -41,6 +42,10 @@ type revision struct {
 }
 
 var (
+	// snapshotMismatchMeter counts the snapshot reads found to differ from the
+	// trie, when verified.
+	snapshotMismatchMeter = metrics.NewRegisteredMeter("state/snapshot/mismatch", nil)
+
 	// emptyRoot is the known root hash of an empty trie.
 	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
 )
@@ -73,6 +78,7 @@ type StateDB struct {
 	snapDestructs map[common.Hash]struct{}
 	snapAccounts  map[common.Hash][]byte
 	snapStorage   map[common.Hash]map[common.Hash][]byte
+	snapVerify    bool // Cross-check the snapshot reads against the trie
 
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects        map[common.Address]*stateObject
@@ -529,20 +535,25 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 		}
 		var acc *snapshot.Account
 		if acc, err = s.snap.Account(crypto.HashData(s.hasher, addr.Bytes())); err == nil {
-			if acc == nil {
-				return nil
-			}
-			data = &Account{
-				Nonce:    acc.Nonce,
-				Balance:  acc.Balance,
-				CodeHash: acc.CodeHash,
-				Root:     common.BytesToHash(acc.Root),
+			if acc != nil {
+				data = &Account{
+					Nonce:    acc.Nonce,
+					Balance:  acc.Balance,
+					CodeHash: acc.CodeHash,
+					Root:     common.BytesToHash(acc.Root),
+				}
+				if len(data.CodeHash) == 0 {
+					data.CodeHash = emptyCodeHash
+				}
+				if data.Root == (common.Hash{}) {
+					data.Root = emptyRoot
+				}
 			}
-			if len(data.CodeHash) == 0 {
-				data.CodeHash = emptyCodeHash
+			if s.snapVerify {
+				data = s.verifySnapshotAccount(addr, data)
 			}
-			if data.Root == (common.Hash{}) {
-				data.Root = emptyRoot
+			if data == nil {
+				return nil
 			}
 		}
 	}
@@ -571,6 +582,45 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	return obj
 }
 
+// VerifySnapshotReads makes the state cross-check every value read from the
+// snapshot against the trie, for debugging the snapshot. Mismatches are logged
+// and the values of the trie are used instead, which makes the reads as slow as
+// without snapshot.
+func (s *StateDB) VerifySnapshotReads() {
+	s.snapVerify = true
+}
+
+// verifySnapshotAccount checks an account read from the snapshot against the
+// trie, returning the account of the trie.
+func (s *StateDB) verifySnapshotAccount(addr common.Address, snap *Account) *Account {
+	enc, err := s.trie.TryGet(addr.Bytes())
+	if err != nil {
+		log.Warn("Failed to verify snapshot account", "root", s.originalRoot, "addr", addr, "err", err)
+		return snap
+	}
+	var data *Account
+	if len(enc) > 0 {
+		data = new(Account)
+		if err := rlp.DecodeBytes(enc, data); err != nil {
+			log.Error("Failed to decode state object", "addr", addr, "err", err)
+			return snap
+		}
+	}
+	if !sameAccount(snap, data) {
+		snapshotMismatchMeter.Mark(1)
+		log.Error("Snapshot account mismatch", "root", s.originalRoot, "addr", addr, "snapshot", snap, "trie", data)
+	}
+	return data
+}
+
+// sameAccount reports whether two accounts, possibly missing, are identical.
+func sameAccount(a, b *Account) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Nonce == b.Nonce && a.Balance.Cmp(b.Balance) == 0 && a.Root == b.Root && bytes.Equal(a.CodeHash, b.CodeHash)
+}
+
 func (s *StateDB) setStateObject(object *stateObject) {
 	s.stateObjects[object.Address()] = object
 }
@@ -735,6 +785,7 @@ func (s *StateDB) Copy() *StateDB {
 		// and force the miner to operate trie-backed only
 		state.snaps = s.snaps
 		state.snap = s.snap
+		state.snapVerify = s.snapVerify
 		// deep copy needed
 		state.snapDestructs = make(map[common.Hash]struct{})
 		for k, v := range s.snapDestructs {
@@ -31,7 +31,10 @@ import (
 
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/state/snapshot"
 	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/rlp"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -915,3 +918,45 @@ func TestStateDBAccessList(t *testing.T) {
 		t.Fatalf("expected empty, got %d", got)
 	}
 }
+
+// Tests that the snapshot reads are checked against the trie when verification
+// is enabled, the trie taking precedence.
+func TestVerifySnapshotReads(t *testing.T) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		db     = NewDatabase(diskdb)
+		addr   = common.Address{0x01}
+		slot   = common.Hash{0x02}
+	)
+	state, _ := New(common.Hash{}, db, nil)
+	state.SetBalance(addr, big.NewInt(100))
+	state.SetState(addr, slot, common.Hash{0x03})
+	root, _ := state.Commit(false)
+	db.TrieDB().Commit(root, false, nil)
+
+	snaps, err := snapshot.New(diskdb, db.TrieDB(), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	// Corrupt the snapshot of the account and of its storage
+	obj := state.getStateObject(addr)
+	rawdb.WriteAccountSnapshot(diskdb, obj.addrHash, snapshot.SlimAccountRLP(0, big.NewInt(200), obj.data.Root, obj.data.CodeHash))
+	enc, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(common.Hash{0x04}.Bytes()))
+	rawdb.WriteStorageSnapshot(diskdb, obj.addrHash, crypto.Keccak256Hash(slot.Bytes()), enc)
+
+	state, _ = New(root, db, snaps)
+	if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(200)) != 0 {
+		t.Fatalf("snapshot not used: balance %v", balance)
+	}
+	state, _ = New(root, db, snaps)
+	state.VerifySnapshotReads()
+	if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 100", balance)
+	}
+	if value := state.GetState(addr, slot); value != (common.Hash{0x03}) {
+		t.Errorf("storage mismatch: have %x, want %x", value, common.Hash{0x03})
+	}
+	if value := state.Copy().GetState(addr, common.Hash{0x05}); value != (common.Hash{}) {
+		t.Errorf("missing storage mismatch: have %x", value)
+	}
+}
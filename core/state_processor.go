@@ -90,6 +90,10 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 }
 
 func applyTransaction(msg types.Message, config *params.ChainConfig, bc ChainContext, author *common.Address, gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, usedGas *uint64, evm *vm.EVM) (*types.Receipt, error) {
+	// Reject transactions under the minimum gas price of the network, if any.
+	if floor := config.MinGasPriceAt(header.Number, header.Time); floor != nil && msg.GasPrice().Cmp(floor) < 0 {
+		return nil, fmt.Errorf("%w: have %v, want %v", ErrGasPriceBelowMinimum, msg.GasPrice(), floor)
+	}
 	// Create a new context to be used in the EVM environment.
 	txContext := NewEVMTxContext(msg)
 	evm.Reset(txContext, statedb)
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"errors"
 	"math/big"
 	"testing"
 
@@ -150,3 +151,30 @@ func GenerateBadBlock(parent *types.Block, engine consensus.Engine, txs types.Tr
 	// Assemble and return the final block for sealing
 	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil))
 }
+
+// TestStateProcessorMinGasPrice tests that blocks including transactions under
+// the minimum gas price of the chain are rejected.
+func TestStateProcessorMinGasPrice(t *testing.T) {
+	var (
+		signer     = types.HomesteadSigner{}
+		testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address    = crypto.PubkeyToAddress(testKey.PublicKey)
+		db         = rawdb.NewMemoryDatabase()
+		config     = *params.TestChainConfig
+	)
+	config.MinGasPrice = &params.MinGasPriceConfig{Block: big.NewInt(1), Price: big.NewInt(params.GWei)}
+
+	gspec := &Genesis{
+		Config: &config,
+		Alloc:  GenesisAlloc{address: {Balance: big.NewInt(params.Oranger)}},
+	}
+	genesis := gspec.MustCommit(db)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ongash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(0), params.TxGas, big.NewInt(params.GWei-1), nil), signer, testKey)
+	block := GenerateBadBlock(genesis, ongash.NewFaker(), types.Transactions{tx})
+	if _, err := blockchain.InsertChain(types.Blocks{block}); !errors.Is(err, ErrGasPriceBelowMinimum) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrGasPriceBelowMinimum)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/metrics"
+)
+
+// ErrTxRejected is returned if a transaction is rejected by one of the admission
+// filters of the transaction pool. The error of the filter is wrapped into it.
+var ErrTxRejected = errors.New("transaction rejected by admission policy")
+
+// rejectedTxMeter counts the transactions rejected by admission filters.
+var rejectedTxMeter = metrics.NewRegisteredMeter("txpool/rejected", nil)
+
+// TxOrigin describes the submission of a transaction to the pool.
+type TxOrigin struct {
+	From  common.Address // Sender of the transaction
+	Peer  string         // Identifier of the network peer relaying the transaction, empty if not network sourced
+	Local bool           // Whether the transaction was submitted locally or by a local account
+}
+
+// TxAdmissionFilter is a policy deciding whether transactions are admitted into
+// the pool, e.g. a compliance or screening module wired in by an embedder. The
+// filters are invoked for local and remote transactions alike, once they passed
+// the validation of the pool and with the pool lock held, so they must not call
+// back into the pool.
+type TxAdmissionFilter interface {
+	// Admit returns a non-nil error if the transaction must be rejected.
+	Admit(tx *types.Transaction, origin TxOrigin) error
+}
+
+// TxAdmissionFunc is an adapter to allow the use of ordinary functions as
+// transaction admission filters.
+type TxAdmissionFunc func(tx *types.Transaction, origin TxOrigin) error
+
+// Admit calls f(tx, origin).
+func (f TxAdmissionFunc) Admit(tx *types.Transaction, origin TxOrigin) error {
+	return f(tx, origin)
+}
+
+// AddAdmissionFilter registers a filter every transaction added to the pool must
+// pass. Transactions already in the pool are not re-evaluated.
+func (pool *TxPool) AddAdmissionFilter(filter TxAdmissionFilter) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.filters = append(pool.filters, filter)
+}
+
+// admit runs a validated transaction through the admission filters, returning
+// the rejection of the first filter refusing it.
+func (pool *TxPool) admit(tx *types.Transaction, peer string, local bool) error {
+	if len(pool.filters) == 0 {
+		return nil
+	}
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	origin := TxOrigin{From: from, Peer: peer, Local: local}
+
+	for _, filter := range pool.filters {
+		if err := filter.Admit(tx, origin); err != nil {
+			log.Trace("Transaction rejected by admission filter", "hash", tx.Hash(), "from", from, "peer", peer, "local", local, "err", err)
+			rejectedTxMeter.Mark(1)
+			return fmt.Errorf("%w: %v", ErrTxRejected, err)
+		}
+	}
+	return nil
+}
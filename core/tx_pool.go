@@ -20,6 +20,7 @@ import (
 	"errors"
 	"math"
 	"math/big"
+	"runtime"
 	"sort"
 	"sync"
 	"time"
@@ -38,6 +39,11 @@ const (
 	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
 	chainHeadChanSize = 10
 
+	// minParallelAccounts is the number of accounts below which promotions and
+	// demotions process them sequentially, the goroutine overhead outweighing
+	// the gain of processing them concurrently.
+	minParallelAccounts = 64
+
 	// txSlotSize is used to calculate how many data slots a single transaction
 	// takes up based on its size. The slots are used as DoS protection, ensuring
 	// that validating a new transaction remains a constant operation (in reality
@@ -231,12 +237,19 @@ type TxPool struct {
 	istanbul bool // Fork indicator whonger we are in the istanbul stage.
 	eip2718  bool // Fork indicator whonger we are using EIP-2718 type transactions.
 
+	minGasPrice *big.Int   // Minimum gas price enforced by the chain, if any
+	blockRules  blockRules // Additional validation rules of the chain
+	txRules     blockRules // Block rules applying to the next block
+
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	accountWorkers int // Number of goroutines processing accounts concurrently on promotions and demotions
+
+	locals  *accountSet         // Set of local transaction to exempt from eviction rules
+	filters []TxAdmissionFilter // Admission policies transactions must pass to enter the pool
+	journal *txJournal          // Journal of local transaction to back up to disk
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -281,6 +294,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		reorgDoneCh:     make(chan chan struct{}),
 		reorgShutdownCh: make(chan struct{}),
 		gasPrice:        new(big.Int).SetUint64(config.PriceLimit),
+		accountWorkers:  runtime.NumCPU(),
 	}
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
@@ -288,6 +302,11 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		pool.locals.add(addr)
 	}
 	pool.priced = newTxPricedList(pool.all)
+	if rules, err := newBlockRules(chainconfig); err != nil {
+		log.Error("Failed to set up block rules", "err", err)
+	} else {
+		pool.blockRules = rules
+	}
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	// Start the reorg loop early so it can handle requests generated during journal loading.
@@ -545,6 +564,14 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Drop all transactions under the minimal gas price of the network
+	if pool.minGasPrice != nil && tx.GasPriceIntCmp(pool.minGasPrice) < 0 {
+		return ErrGasPriceBelowMinimum
+	}
+	// Drop all transactions breaking the rules of the next block
+	if err := pool.txRules.verifyTransaction(tx); err != nil {
+		return err
+	}
 	// Drop non-local transactions under our own minimal accepted gas price
 	if !local && tx.GasPriceIntCmp(pool.gasPrice) < 0 {
 		return ErrUnderpriced
@@ -576,7 +603,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 // If a newly added transaction is marked as local, its sending account will be
 // whitelisted, preventing any associated transaction from being dropped out of the pool
 // due to pricing constraints.
-func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err error) {
+func (pool *TxPool) add(tx *types.Transaction, peer string, local bool) (replaced bool, err error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
 	if pool.all.Get(hash) != nil {
@@ -594,6 +621,10 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		invalidTxMeter.Mark(1)
 		return false, err
 	}
+	// If the transaction is refused by an admission policy, discard it
+	if err := pool.admit(tx, peer, isLocal); err != nil {
+		return false, err
+	}
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(pool.all.Count()+numSlots(tx)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
@@ -759,7 +790,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 // This Method is used to add transactions from the RPC API and performs synchronous pool
 // reorganization and event propagation.
 func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals, true)
+	return pool.addTxs(txs, "", !pool.config.NoLocals, true)
 }
 
 // AddLocal enqueues a single local transaction into the pool if it is valid. This is
@@ -775,12 +806,18 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 // This Method is used to add transactions from the p2p network and does not wait for pool
 // reorganization and internal event propagation.
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, false)
+	return pool.addTxs(txs, "", false, false)
+}
+
+// AddRemotesFrom is like AddRemotes, but records the network peer the transactions
+// were received from, exposing it to the admission filters.
+func (pool *TxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	return pool.addTxs(txs, peer, false, false)
 }
 
 // This is like AddRemotes, but waits for pool reorganization. Tests use this Method.
 func (pool *TxPool) AddRemotesSync(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, true)
+	return pool.addTxs(txs, "", false, true)
 }
 
 // This is like AddRemotes with a single transaction, but waits for pool reorganization. Tests use this Method.
@@ -799,7 +836,7 @@ func (pool *TxPool) AddRemote(tx *types.Transaction) error {
 }
 
 // addTxs attempts to queue a batch of transactions if they are valid.
-func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
+func (pool *TxPool) addTxs(txs []*types.Transaction, peer string, local, sync bool) []error {
 	// Filter out known ones without obtaining the pool lock or recovering signatures
 	var (
 		errs = make([]error, len(txs))
@@ -830,7 +867,7 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
-	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	newErrs, dirtyAddrs := pool.addTxsLocked(news, peer, local)
 	pool.mu.Unlock()
 
 	var nilSlot = 0
@@ -851,11 +888,11 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid.
 // The transaction pool lock must be held.
-func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) ([]error, *accountSet) {
+func (pool *TxPool) addTxsLocked(txs []*types.Transaction, peer string, local bool) ([]error, *accountSet) {
 	dirty := newAccountSet(pool.signer)
 	errs := make([]error, len(txs))
 	for i, tx := range txs {
-		replaced, err := pool.add(tx, local)
+		replaced, err := pool.add(tx, peer, local)
 		errs[i] = err
 		if err == nil && !replaced {
 			dirty.addTx(tx)
@@ -1199,70 +1236,155 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
-	pool.addTxsLocked(reinject, false)
+	pool.addTxsLocked(reinject, "", false)
 
-	// Update all fork indicator by next pending block number.
+	// Update all fork indicator by next pending block number. The timestamp
+	// scheduled transitions are checked against the current time, the next
+	// block being stamped with it.
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
+	now := uint64(time.Now().Unix())
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
 	pool.eip2718 = pool.chainconfig.IsBerlin(next)
+	pool.minGasPrice = pool.chainconfig.MinGasPriceAt(next, now)
+
+	// If the applying block rules change, evict the transactions breaking them
+	if rules := pool.blockRules.active(next, now); !rules.equal(pool.txRules) {
+		pool.txRules = rules
+
+		var violating []common.Hash
+		pool.all.Range(func(hash common.Hash, tx *types.Transaction, local bool) bool {
+			if rules.verifyTransaction(tx) != nil {
+				violating = append(violating, hash)
+			}
+			return true
+		}, true, true)
+		for _, hash := range violating {
+			pool.removeTx(hash, true)
+		}
+		if len(violating) > 0 {
+			log.Debug("Evicted transactions breaking block rules", "count", len(violating), "number", next)
+		}
+	}
+}
+
+// accountChanges are the transactions removed from the list of an account while
+// validating it against the current state during promotions and demotions.
+type accountChanges struct {
+	olds     types.Transactions // Transactions with a nonce below the account nonce
+	drops    types.Transactions // Transactions too costly for the account balance or the block gas limit
+	invalids types.Transactions // Pending transactions invalidated by the drops, to be queued back
+	readies  types.Transactions // Queued transactions ready to be promoted
+	caps     types.Transactions // Queued transactions over the per-account limit
+	gapped   types.Transactions // Pending transactions behind a nonce gap, to be queued back
+}
+
+// forEachAccount runs fn for every account, spreading them over concurrent workers
+// if there are enough of them. Each worker reads the account data from a private
+// copy of the current state, as the state database mutates even on reads.
+//
+// The pool lock must be held and fn may only modify the transaction list of the
+// account it is invoked for, the shared pool structures (lookup, price heap,
+// pending and queue maps) are updated by the caller once all accounts are done.
+func (pool *TxPool) forEachAccount(accounts []common.Address, fn func(i int, addr common.Address, statedb *state.StateDB)) {
+	workers := pool.accountWorkers
+	if workers > len(accounts)/minParallelAccounts {
+		workers = len(accounts) / minParallelAccounts
+	}
+	if workers <= 1 {
+		for i, addr := range accounts {
+			fn(i, addr, pool.currentState)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int, statedb *state.StateDB) {
+			defer wg.Done()
+			for i := w; i < len(accounts); i += workers {
+				fn(i, accounts[i], statedb)
+			}
+		}(w, pool.currentState.Copy())
+	}
+	wg.Wait()
 }
 
 // promoteExecutables moves transactions that have become processable from the
 // future queue to the set of pending transactions. During this process, all
 // invalidated transactions (low nonce, low balance) are deleted.
+//
+// The queues of the accounts are validated against the state concurrently, the
+// resulting changes are then applied to the pool one account after the other.
 func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Transaction {
+	// Validate the queue of every account, dropping the invalid transactions and
+	// gathering the executable ones
+	changes := make([]accountChanges, len(accounts))
+	pool.forEachAccount(accounts, func(i int, addr common.Address, statedb *state.StateDB) {
+		list := pool.queue[addr]
+		if list == nil {
+			return // Just in case someone calls with a non existing account
+		}
+		change := &changes[i]
+
+		// Drop all transactions that are deemed too old (low nonce)
+		change.olds = list.Forward(statedb.GetNonce(addr))
+
+		// Drop all transactions that are too costly (low balance or out of gas)
+		change.drops, _ = list.Filter(statedb.GetBalance(addr), pool.currentMaxGas)
+
+		// Gather all executable transactions
+		change.readies = list.Ready(pool.pendingNonces.get(addr))
+
+		// Drop all transactions over the allowed limit
+		if !pool.locals.contains(addr) {
+			change.caps = list.Cap(int(pool.config.AccountQueue))
+		}
+	})
 	// Track the promoted transactions to broadcast them at once
 	var promoted []*types.Transaction
 
-	// Iterate over all accounts and promote any executable transactions
-	for _, addr := range accounts {
+	// Apply the changes of all accounts and promote any executable transactions
+	for i, addr := range accounts {
 		list := pool.queue[addr]
 		if list == nil {
-			continue // Just in case someone calls with a non existing account
+			continue
 		}
-		// Drop all transactions that are deemed too old (low nonce)
-		forwards := list.Forward(pool.currentState.GetNonce(addr))
-		for _, tx := range forwards {
+		change := &changes[i]
+
+		for _, tx := range change.olds {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
-		log.Trace("Removed old queued transactions", "count", len(forwards))
-		// Drop all transactions that are too costly (low balance or out of gas)
-		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
-		for _, tx := range drops {
+		log.Trace("Removed old queued transactions", "count", len(change.olds))
+		for _, tx := range change.drops {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
-		log.Trace("Removed unpayable queued transactions", "count", len(drops))
-		queuedNofundsMeter.Mark(int64(len(drops)))
+		log.Trace("Removed unpayable queued transactions", "count", len(change.drops))
+		queuedNofundsMeter.Mark(int64(len(change.drops)))
 
-		// Gather all executable transactions and promote them
-		readies := list.Ready(pool.pendingNonces.get(addr))
-		for _, tx := range readies {
+		for _, tx := range change.readies {
 			hash := tx.Hash()
 			if pool.promoteTx(addr, hash, tx) {
 				promoted = append(promoted, tx)
 			}
 		}
 		log.Trace("Promoted queued transactions", "count", len(promoted))
-		queuedGauge.Dec(int64(len(readies)))
+		queuedGauge.Dec(int64(len(change.readies)))
 
-		// Drop all transactions over the allowed limit
-		var caps types.Transactions
-		if !pool.locals.contains(addr) {
-			caps = list.Cap(int(pool.config.AccountQueue))
-			for _, tx := range caps {
-				hash := tx.Hash()
-				pool.all.Remove(hash)
-				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
-			}
-			queuedRateLimitMeter.Mark(int64(len(caps)))
+		for _, tx := range change.caps {
+			hash := tx.Hash()
+			pool.all.Remove(hash)
+			log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 		}
+		queuedRateLimitMeter.Mark(int64(len(change.caps)))
+
 		// Mark all the items dropped as removed
-		pool.priced.Removed(len(forwards) + len(drops) + len(caps))
-		queuedGauge.Dec(int64(len(forwards) + len(drops) + len(caps)))
+		dropped := len(change.olds) + len(change.drops) + len(change.caps)
+		pool.priced.Removed(dropped)
+		queuedGauge.Dec(int64(dropped))
 		if pool.locals.contains(addr) {
-			localGauge.Dec(int64(len(forwards) + len(drops) + len(caps)))
+			localGauge.Dec(int64(dropped))
 		}
 		// Delete the entire queue entry if it became empty.
 		if list.Empty() {
@@ -1409,51 +1531,71 @@ func (pool *TxPool) truncateQueue() {
 // executable/pending queue and any subsequent transactions that become unexecutable
 // are moved back into the future queue.
 func (pool *TxPool) demoteUnexecutables() {
-	// Iterate over all accounts and demote any non-executable transactions
-	for addr, list := range pool.pending {
-		nonce := pool.currentState.GetNonce(addr)
-
+	accounts := make([]common.Address, 0, len(pool.pending))
+	for addr := range pool.pending {
+		accounts = append(accounts, addr)
+	}
+	// Validate the pending transactions of every account against the state
+	changes := make([]accountChanges, len(accounts))
+	pool.forEachAccount(accounts, func(i int, addr common.Address, statedb *state.StateDB) {
+		var (
+			list   = pool.pending[addr]
+			change = &changes[i]
+			nonce  = statedb.GetNonce(addr)
+		)
 		// Drop all transactions that are deemed too old (low nonce)
-		olds := list.Forward(nonce)
-		for _, tx := range olds {
+		change.olds = list.Forward(nonce)
+
+		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
+		change.drops, change.invalids = list.Filter(statedb.GetBalance(addr), pool.currentMaxGas)
+
+		// If there's a gap in front, postpone all transactions
+		if list.Len() > 0 && list.txs.Get(nonce) == nil {
+			change.gapped = list.Cap(0)
+		}
+	})
+	// Apply the changes of all accounts and demote any non-executable transactions
+	for i, addr := range accounts {
+		var (
+			list   = pool.pending[addr]
+			change = &changes[i]
+		)
+		for _, tx := range change.olds {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
-		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
-		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
-		for _, tx := range drops {
+		for _, tx := range change.drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 		}
-		pool.priced.Removed(len(olds) + len(drops))
-		pendingNofundsMeter.Mark(int64(len(drops)))
+		pool.priced.Removed(len(change.olds) + len(change.drops))
+		pendingNofundsMeter.Mark(int64(len(change.drops)))
 
-		for _, tx := range invalids {
+		for _, tx := range change.invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
 
 			// Internal shuffle shouldn't touch the lookup set.
 			pool.enqueueTx(hash, tx, false, false)
 		}
-		pendingGauge.Dec(int64(len(olds) + len(drops) + len(invalids)))
+		pendingGauge.Dec(int64(len(change.olds) + len(change.drops) + len(change.invalids)))
 		if pool.locals.contains(addr) {
-			localGauge.Dec(int64(len(olds) + len(drops) + len(invalids)))
+			localGauge.Dec(int64(len(change.olds) + len(change.drops) + len(change.invalids)))
 		}
-		// If there's a gap in front, alert (should never happen) and postpone all transactions
-		if list.Len() > 0 && list.txs.Get(nonce) == nil {
-			gapped := list.Cap(0)
-			for _, tx := range gapped {
+		// If there was a gap in front, alert (should never happen)
+		if len(change.gapped) > 0 {
+			for _, tx := range change.gapped {
 				hash := tx.Hash()
 				log.Error("Demoting invalidated transaction", "hash", hash)
 
 				// Internal shuffle shouldn't touch the lookup set.
 				pool.enqueueTx(hash, tx, false, false)
 			}
-			pendingGauge.Dec(int64(len(gapped)))
+			pendingGauge.Dec(int64(len(change.gapped)))
 			// This might happen in a reorg, so log it to the metering
-			blockReorgInvalidatedTx.Mark(int64(len(gapped)))
+			blockReorgInvalidatedTx.Mark(int64(len(change.gapped)))
 		}
 		// Delete the entire pending entry if it became empty.
 		if list.Empty() {
@@ -24,6 +24,8 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
+	"runtime"
 	"testing"
 	"time"
 
@@ -358,14 +360,14 @@ func TestTransactionChainFork(t *testing.T) {
 	resetState()
 
 	tx := transaction(0, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, "", false); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	pool.removeTx(tx.Hash(), true)
 
 	// reset the pool's internal state
 	resetState()
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, "", false); err != nil {
 		t.Error("didn't expect error", err)
 	}
 }
@@ -392,10 +394,10 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	tx3, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), 1000000, big.NewInt(1), nil), signer, key)
 
 	// Add the first two transaction, ensure higher priced stays only
-	if replace, err := pool.add(tx1, false); err != nil || replace {
+	if replace, err := pool.add(tx1, "", false); err != nil || replace {
 		t.Errorf("first transaction insert failed (%v) or reported replacement (%v)", err, replace)
 	}
-	if replace, err := pool.add(tx2, false); err != nil || !replace {
+	if replace, err := pool.add(tx2, "", false); err != nil || !replace {
 		t.Errorf("second transaction insert failed (%v) or not reported replacement (%v)", err, replace)
 	}
 	<-pool.requestPromoteExecutables(newAccountSet(signer, addr))
@@ -407,7 +409,7 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	}
 
 	// Add the third transaction and ensure it's not saved (smaller price)
-	pool.add(tx3, false)
+	pool.add(tx3, "", false)
 	<-pool.requestPromoteExecutables(newAccountSet(signer, addr))
 	if pool.pending[addr].Len() != 1 {
 		t.Error("expected 1 pending transactions, got", pool.pending[addr].Len())
@@ -430,7 +432,7 @@ func TestTransactionMissingNonce(t *testing.T) {
 	addr := crypto.PubkeyToAddress(key.PublicKey)
 	pool.currentState.AddBalance(addr, big.NewInt(100000000000000))
 	tx := transaction(1, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, "", false); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	if len(pool.pending) != 0 {
@@ -1933,6 +1935,129 @@ func TestTransactionSlotCount(t *testing.T) {
 	}
 }
 
+// Tests that admission filters are consulted for local and remote transactions
+// and that the rejected ones are not added to the pool.
+func TestTransactionAdmissionFilter(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	blocked, _ := crypto.GenerateKey()
+	blockedAddr := crypto.PubkeyToAddress(blocked.PublicKey)
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+	pool.currentState.AddBalance(blockedAddr, big.NewInt(1000000))
+
+	var origins []TxOrigin
+	pool.AddAdmissionFilter(TxAdmissionFunc(func(tx *types.Transaction, origin TxOrigin) error {
+		origins = append(origins, origin)
+		if origin.From == blockedAddr {
+			return errors.New("sanctioned sender")
+		}
+		return nil
+	}))
+	if err := pool.AddRemote(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("failed to add admitted remote transaction: %v", err)
+	}
+	if err := pool.AddLocal(transaction(1, 100000, key)); err != nil {
+		t.Fatalf("failed to add admitted local transaction: %v", err)
+	}
+	if err := pool.AddRemote(transaction(0, 100000, blocked)); !errors.Is(err, ErrTxRejected) {
+		t.Fatalf("remote transaction rejection mismatch: have %v, want %v", err, ErrTxRejected)
+	}
+	if err := pool.AddLocal(transaction(0, 100000, blocked)); !errors.Is(err, ErrTxRejected) {
+		t.Fatalf("local transaction rejection mismatch: have %v, want %v", err, ErrTxRejected)
+	}
+	if errs := pool.AddRemotesFrom("peer", []*types.Transaction{transaction(1, 100000, blocked)}); !errors.Is(errs[0], ErrTxRejected) {
+		t.Fatalf("network transaction rejection mismatch: have %v, want %v", errs[0], ErrTxRejected)
+	}
+	// Check the origins reported to the filter
+	want := []TxOrigin{
+		{From: crypto.PubkeyToAddress(key.PublicKey), Local: false},
+		{From: crypto.PubkeyToAddress(key.PublicKey), Local: true},
+		{From: blockedAddr, Local: false},
+		{From: blockedAddr, Local: true},
+		{From: blockedAddr, Peer: "peer", Local: false},
+	}
+	if !reflect.DeepEqual(origins, want) {
+		t.Errorf("filter origins mismatch: have %v, want %v", origins, want)
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Errorf("pool stats mismatch: have %d pending, %d queued, want 2 pending, 0 queued", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that validating the accounts concurrently on chain head resets yields
+// the same pool contents as validating them one after the other.
+func TestTransactionConcurrentReset(t *testing.T) {
+	t.Parallel()
+
+	keys := make([]*ecdsa.PrivateKey, 4*minParallelAccounts)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	var txs types.Transactions
+	for _, key := range keys {
+		// Executable transactions, followed by a gapped one
+		for nonce := uint64(0); nonce < 4; nonce++ {
+			txs = append(txs, transaction(nonce, 100000, key))
+		}
+		txs = append(txs, transaction(6, 100000, key))
+	}
+	fill := func(workers int) *TxPool {
+		pool, _ := setupTxPool()
+		pool.accountWorkers = workers
+		for _, key := range keys {
+			pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+		}
+		<-pool.requestReset(nil, nil)
+		pool.AddRemotesSync(txs)
+
+		// Invalidate some of the transactions: include a few, make some unpayable
+		// and fill the nonce gap of others
+		for i, key := range keys {
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+			switch i % 4 {
+			case 0:
+				pool.currentState.SetNonce(addr, 2)
+			case 1:
+				pool.currentState.SetBalance(addr, big.NewInt(100000*2+200))
+			case 2:
+				pool.currentState.SetNonce(addr, 5)
+			}
+		}
+		<-pool.requestReset(nil, nil)
+		return pool
+	}
+	sequential := fill(1)
+	defer sequential.Stop()
+	concurrent := fill(4)
+	defer concurrent.Stop()
+
+	if err := validateTxPoolInternals(concurrent); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	havePending, haveQueued := concurrent.Stats()
+	wantPending, wantQueued := sequential.Stats()
+	if havePending != wantPending || haveQueued != wantQueued {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want %d pending, %d queued", havePending, haveQueued, wantPending, wantQueued)
+	}
+	havePendingTxs, haveQueuedTxs := concurrent.Content()
+	wantPendingTxs, wantQueuedTxs := sequential.Content()
+	for _, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		if have, want := len(havePendingTxs[addr]), len(wantPendingTxs[addr]); have != want {
+			t.Errorf("account %x: pending mismatch: have %d, want %d", addr, have, want)
+		}
+		if have, want := len(haveQueuedTxs[addr]), len(wantQueuedTxs[addr]); have != want {
+			t.Errorf("account %x: queued mismatch: have %d, want %d", addr, have, want)
+		}
+	}
+}
+
 // Benchmarks the speed of validating the contents of the pending queue of the
 // transaction pool.
 func BenchmarkPendingDemotion100(b *testing.B)   { benchmarkPendingDemotion(b, 100) }
@@ -1983,6 +2108,51 @@ func benchmarkFuturePromotion(b *testing.B, size int) {
 	}
 }
 
+// Benchmarks the speed of validating the pool contents against a new chain head
+// with accounts processed sequentially or concurrently.
+func BenchmarkPoolReset1000Sequential(b *testing.B)  { benchmarkPoolReset(b, 1000, 1) }
+func BenchmarkPoolReset1000Concurrent(b *testing.B)  { benchmarkPoolReset(b, 1000, runtime.NumCPU()) }
+func BenchmarkPoolReset10000Sequential(b *testing.B) { benchmarkPoolReset(b, 10000, 1) }
+func BenchmarkPoolReset10000Concurrent(b *testing.B) { benchmarkPoolReset(b, 10000, runtime.NumCPU()) }
+
+func benchmarkPoolReset(b *testing.B, accounts int, workers int) {
+	// Fill the pool with pending and queued transactions of many accounts
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+	pool.accountWorkers = workers
+	pool.config.GlobalSlots = uint64(4 * accounts)
+	pool.config.GlobalQueue = uint64(accounts)
+
+	var txs types.Transactions
+	for i := 0; i < accounts; i++ {
+		key, _ := crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000))
+		for nonce := uint64(0); nonce < 4; nonce++ {
+			txs = append(txs, transaction(nonce, 100000, key))
+		}
+		txs = append(txs, transaction(6, 100000, key))
+	}
+	pool.AddRemotesSync(txs)
+
+	// Commit the state to validate against a fresh state database on every run
+	root, _ := pool.currentState.Commit(false)
+	database := pool.currentState.Database()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool.mu.Lock()
+		pool.currentState, _ = state.New(root, database, nil)
+		pool.demoteUnexecutables()
+
+		addrs := make([]common.Address, 0, len(pool.queue))
+		for addr := range pool.queue {
+			addrs = append(addrs, addr)
+		}
+		pool.promoteExecutables(addrs)
+		pool.mu.Unlock()
+	}
+}
+
 // Benchmarks the speed of batched transaction insertion.
 func BenchmarkPoolBatchInsert100(b *testing.B)   { benchmarkPoolBatchInsert(b, 100, false) }
 func BenchmarkPoolBatchInsert1000(b *testing.B)  { benchmarkPoolBatchInsert(b, 1000, false) }
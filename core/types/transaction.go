@@ -19,6 +19,7 @@ package types
 import (
 	"bytes"
 	"container/heap"
+	"encoding/binary"
 	"errors"
 	"io"
 	"math/big"
@@ -414,12 +415,58 @@ func (s *TxByPriceAndTime) Pop() interface{} {
 	return x
 }
 
+func (s *TxByPriceAndTime) head() *Transaction      { return (*s)[0] }
+func (s *TxByPriceAndTime) setHead(tx *Transaction) { (*s)[0] = tx }
+
+// txHeads is a price heap of the next transactions of a set of accounts.
+type txHeads interface {
+	heap.Interface
+	head() *Transaction      // Returns the best transaction
+	setHead(tx *Transaction) // Replaces the best transaction with one of the same account
+}
+
+// rankedTx is a transaction along with the rank of its sender.
+type rankedTx struct {
+	tx   *Transaction
+	rank common.Hash
+}
+
+// txByPriceAndRank implements the heap interface like TxByPriceAndTime, but
+// breaks the price ties by the rank of the senders instead of by the time the
+// transactions were first seen.
+type txByPriceAndRank []rankedTx
+
+func (s txByPriceAndRank) Len() int { return len(s) }
+func (s txByPriceAndRank) Less(i, j int) bool {
+	cmp := s[i].tx.GasPrice().Cmp(s[j].tx.GasPrice())
+	if cmp == 0 {
+		return bytes.Compare(s[i].rank[:], s[j].rank[:]) < 0
+	}
+	return cmp > 0
+}
+func (s txByPriceAndRank) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txByPriceAndRank) Push(x interface{}) {
+	*s = append(*s, x.(rankedTx))
+}
+
+func (s *txByPriceAndRank) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+func (s *txByPriceAndRank) head() *Transaction      { return (*s)[0].tx }
+func (s *txByPriceAndRank) setHead(tx *Transaction) { (*s)[0].tx = tx }
+
 // TransactionsByPriceAndNonce represents a set of transactions that can return
 // transactions in a profit-maximizing sorted order, while supporting removing
 // entire batches of transactions for non-executable accounts.
 type TransactionsByPriceAndNonce struct {
 	txs    map[common.Address]Transactions // Per account nonce-sorted list of transactions
-	heads  TxByPriceAndTime                // Next transaction for each unique account (price heap)
+	heads  txHeads                         // Next transaction for each unique account (price heap)
 	signer Signer                          // Signer for the set of transactions
 }
 
@@ -445,27 +492,60 @@ func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transa
 	// Assemble and return the transaction set
 	return &TransactionsByPriceAndNonce{
 		txs:    txs,
-		heads:  heads,
+		heads:  &heads,
+		signer: signer,
+	}
+}
+
+// NewTransactionsByPriceAndSeed creates a transaction set like
+// NewTransactionsByPriceAndNonce, but breaking the price ties by an order of the
+// senders derived from the seed instead of by the time the transactions were
+// first seen. The order of the transactions is thus fully determined by the
+// given transactions and the seed.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// if after providing it to the constructor.
+func NewTransactionsByPriceAndSeed(signer Signer, txs map[common.Address]Transactions, seed uint64) *TransactionsByPriceAndNonce {
+	var enc [8 + common.AddressLength]byte
+	binary.BigEndian.PutUint64(enc[:8], seed)
+
+	heads := make(txByPriceAndRank, 0, len(txs))
+	for from, accTxs := range txs {
+		// Ensure the sender address is from the signer
+		if acc, _ := Sender(signer, accTxs[0]); acc != from {
+			delete(txs, from)
+			continue
+		}
+		copy(enc[8:], from[:])
+		heads = append(heads, rankedTx{tx: accTxs[0], rank: crypto.Keccak256Hash(enc[:])})
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(&heads)
+
+	return &TransactionsByPriceAndNonce{
+		txs:    txs,
+		heads:  &heads,
 		signer: signer,
 	}
 }
 
 // Peek returns the next transaction by price.
 func (t *TransactionsByPriceAndNonce) Peek() *Transaction {
-	if len(t.heads) == 0 {
+	if t.heads.Len() == 0 {
 		return nil
 	}
-	return t.heads[0]
+	return t.heads.head()
 }
 
 // Shift replaces the current best head with the next one from the same account.
 func (t *TransactionsByPriceAndNonce) Shift() {
-	acc, _ := Sender(t.signer, t.heads[0])
+	acc, _ := Sender(t.signer, t.heads.head())
 	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
-		t.heads[0], t.txs[acc] = txs[0], txs[1:]
-		heap.Fix(&t.heads, 0)
+		t.heads.setHead(txs[0])
+		t.txs[acc] = txs[1:]
+		heap.Fix(t.heads, 0)
 	} else {
-		heap.Pop(&t.heads)
+		heap.Pop(t.heads)
 	}
 }
 
@@ -473,7 +553,7 @@ func (t *TransactionsByPriceAndNonce) Shift() {
 // the same account. This should be used when a transaction cannot be executed
 // and hence all subsequent ones should be discarded from the same account.
 func (t *TransactionsByPriceAndNonce) Pop() {
-	heap.Pop(&t.heads)
+	heap.Pop(t.heads)
 }
 
 // Message is a fully derived transaction and implements core.Message
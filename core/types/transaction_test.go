@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"math/rand"
 	"reflect"
 	"testing"
 	"time"
@@ -358,6 +359,55 @@ func TestTransactionTimeSort(t *testing.T) {
 	}
 }
 
+// Tests that the seeded ordering of same priced transactions doesn't depend on
+// the time they were first seen, but only on the seed.
+func TestTransactionSeedSort(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 10)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	signer := HomesteadSigner{}
+
+	groups := func() map[common.Address]Transactions {
+		groups := map[common.Address]Transactions{}
+		for _, key := range keys {
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+			for nonce := uint64(0); nonce < 2; nonce++ {
+				tx, _ := SignTx(NewTransaction(nonce, common.Address{}, big.NewInt(100), 100, big.NewInt(1), nil), signer, key)
+				tx.time = time.Unix(0, rand.Int63())
+				groups[addr] = append(groups[addr], tx)
+			}
+		}
+		return groups
+	}
+	order := func(seed uint64) []common.Address {
+		txset := NewTransactionsByPriceAndSeed(signer, groups(), seed)
+
+		var senders []common.Address
+		nonces := make(map[common.Address]uint64)
+		for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+			from, _ := Sender(signer, tx)
+			if tx.Nonce() != nonces[from] {
+				t.Fatalf("invalid nonce ordering: have %d, want %d", tx.Nonce(), nonces[from])
+			}
+			nonces[from]++
+			senders = append(senders, from)
+			txset.Shift()
+		}
+		if len(senders) != 2*len(keys) {
+			t.Fatalf("expected %d transactions, found %d", 2*len(keys), len(senders))
+		}
+		return senders
+	}
+	first := order(1)
+	if second := order(1); !reflect.DeepEqual(first, second) {
+		t.Errorf("order differs for the same seed:\nfirst:  %x\nsecond: %x", first, second)
+	}
+	if other := order(2); reflect.DeepEqual(first, other) {
+		t.Errorf("order identical for different seeds: %x", first)
+	}
+}
+
 // TestTransactionCoding tests serializing/de-serializing to/from rlp and JSON.
 func TestTransactionCoding(t *testing.T) {
 	key, err := crypto.GenerateKey()
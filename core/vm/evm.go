@@ -17,6 +17,7 @@
 package vm
 
 import (
+	"context"
 	"errors"
 	"math/big"
 	"sync/atomic"
@@ -45,12 +46,18 @@ type (
 // ActivePrecompiles returns the addresses of the precompiles enabled with the current
 // configuration
 func (evm *EVM) ActivePrecompiles() []common.Address {
+	return ActivePrecompiles(evm.chainRules)
+}
+
+// ActivePrecompiles returns the addresses of the precompiles enabled with the given
+// chain rules.
+func ActivePrecompiles(rules params.Rules) []common.Address {
 	switch {
-	case evm.chainRules.IsBerlin:
+	case rules.IsBerlin:
 		return PrecompiledAddressesBerlin
-	case evm.chainRules.IsIstanbul:
+	case rules.IsIstanbul:
 		return PrecompiledAddressesIstanbul
-	case evm.chainRules.IsByzantium:
+	case rules.IsByzantium:
 		return PrecompiledAddressesByzantium
 	default:
 		return PrecompiledAddressesHomestead
@@ -211,6 +218,22 @@ func (evm *EVM) Cancelled() bool {
 	return atomic.LoadInt32(&evm.abort) == 1
 }
 
+// CancelWith cancels the running EVM operation as soon as the given context is
+// done, e.g. when its deadline expires or the RPC request that started the
+// execution is cancelled. The returned function stops watching the context and
+// must be called once the execution finished.
+func (evm *EVM) CancelWith(ctx context.Context) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			evm.Cancel()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
 // Interpreter returns the current interpreter
 func (evm *EVM) Interpreter() Interpreter {
 	return evm.interpreter
@@ -131,6 +131,11 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	}
 }
 
+// abortCheckInterval is the number of opcodes executed between two checks of
+// the abort flag of the EVM, trading the latency of cancellations against the
+// cost of the atomic load.
+const abortCheckInterval = 256
+
 // Run loops and evaluates the contract's code with the given input data and returns
 // the return byte-slice and an error if one occurred.
 //
@@ -154,8 +159,11 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	// as every returning call will return new data anyway.
 	in.returnData = nil
 
-	// Don't bother with the execution if there's no code.
-	if len(contract.Code) == 0 {
+	// Don't bother with the execution if there's no code or if the EVM has been
+	// cancelled meanwhile (e.g. by a cancelled RPC request). The latter stops
+	// contracts from evading the periodic check below by recursing into calls
+	// shorter than the check interval.
+	if len(contract.Code) == 0 || atomic.LoadInt32(&in.evm.abort) != 0 {
 		return nil, nil
 	}
 
@@ -205,7 +213,7 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	steps := 0
 	for {
 		steps++
-		if steps%1000 == 0 && atomic.LoadInt32(&in.evm.abort) != 0 {
+		if steps%abortCheckInterval == 0 && atomic.LoadInt32(&in.evm.abort) != 0 {
 			break
 		}
 		if in.cfg.Debug {
@@ -0,0 +1,76 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package runtime
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ong2020/go-orange/params"
+)
+
+// forks lists the supported fork names in activation order, along with the
+// function activating each on a chain config.
+var forks = []struct {
+	name     string
+	activate func(cfg *params.ChainConfig)
+}{
+	{"Frontier", func(cfg *params.ChainConfig) {}},
+	{"Homestead", func(cfg *params.ChainConfig) { cfg.HomesteadBlock = new(big.Int) }},
+	{"EIP150", func(cfg *params.ChainConfig) { cfg.EIP150Block = new(big.Int) }},
+	{"EIP158", func(cfg *params.ChainConfig) { cfg.EIP155Block, cfg.EIP158Block = new(big.Int), new(big.Int) }},
+	{"Byzantium", func(cfg *params.ChainConfig) { cfg.ByzantiumBlock = new(big.Int) }},
+	{"Constantinople", func(cfg *params.ChainConfig) { cfg.ConstantinopleBlock = new(big.Int) }},
+	{"Petersburg", func(cfg *params.ChainConfig) { cfg.PetersburgBlock = new(big.Int) }},
+	{"Istanbul", func(cfg *params.ChainConfig) { cfg.IstanbulBlock = new(big.Int) }},
+	{"MuirGlacier", func(cfg *params.ChainConfig) { cfg.MuirGlacierBlock = new(big.Int) }},
+	{"Berlin", func(cfg *params.ChainConfig) { cfg.BerlinBlock = new(big.Int) }},
+}
+
+// forkAliases maps alternative fork names (e.g. the ones used by the state
+// tests) to the canonical ones.
+var forkAliases = map[string]string{
+	"TangerineWhistle":  "EIP150",
+	"SpuriousDragon":    "EIP158",
+	"ConstantinopleFix": "Petersburg",
+}
+
+// Forks returns the names of the forks supported by ForkConfig, in activation
+// order.
+func Forks() []string {
+	names := make([]string, len(forks))
+	for i, fork := range forks {
+		names[i] = fork.name
+	}
+	return names
+}
+
+// ForkConfig returns a chain config with all the forks up to and including the
+// named one activated from genesis, to execute code under that fork's rules.
+func ForkConfig(name string) (*params.ChainConfig, error) {
+	if alias, ok := forkAliases[name]; ok {
+		name = alias
+	}
+	cfg := &params.ChainConfig{ChainID: big.NewInt(1)}
+	for _, fork := range forks {
+		fork.activate(cfg)
+		if fork.name == name {
+			return cfg, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported fork %q", name)
+}
@@ -17,6 +17,7 @@
 package runtime
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"os"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/ong2020/go-orange/accounts/abi"
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/math"
 	"github.com/ong2020/go-orange/consensus"
 	"github.com/ong2020/go-orange/core"
 	"github.com/ong2020/go-orange/core/asm"
@@ -101,6 +103,40 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+// Tests that an endless execution is aborted once the context it is bound to
+// is done.
+func TestCancelWith(t *testing.T) {
+	cfg := &Config{GasLimit: math.MaxUint64}
+	setDefaults(cfg)
+	cfg.State, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	address := common.HexToAddress("0x0a")
+	cfg.State.SetCode(address, []byte{
+		byte(vm.JUMPDEST),
+		byte(vm.PUSH1), 0,
+		byte(vm.JUMP),
+	})
+	vmenv := NewEnv(cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	defer vmenv.CancelWith(ctx)()
+
+	done := make(chan struct{})
+	go func() {
+		vmenv.Call(vm.AccountRef(cfg.Origin), address, nil, cfg.GasLimit, new(big.Int))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("execution not aborted")
+	}
+	if !vmenv.Cancelled() {
+		t.Error("execution not marked as cancelled")
+	}
+}
+
 func TestCall(t *testing.T) {
 	state, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
 	address := common.HexToAddress("0x0a")
@@ -613,3 +649,134 @@ func TestEip2929Cases(t *testing.T) {
 			"account (cheap)", code)
 	}
 }
+
+func TestAccessListTracer(t *testing.T) {
+	var (
+		origin   = common.HexToAddress("0x1000")
+		contract = common.BytesToAddress([]byte("contract"))
+		other    = common.HexToAddress("0xbb")
+	)
+	code := []byte{
+		// SLOAD(0x01), SSTORE(0x02, 0x11)
+		byte(vm.PUSH1), 0x01, byte(vm.SLOAD), byte(vm.POP),
+		byte(vm.PUSH1), 0x11, byte(vm.PUSH1), 0x02, byte(vm.SSTORE),
+		// BALANCE(0xbb)
+		byte(vm.PUSH1), 0xbb, byte(vm.BALANCE), byte(vm.POP),
+		// CALL the identity precompile, which must not end up in the list
+		byte(vm.PUSH1), 0x0, byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1),
+		byte(vm.PUSH1), 0x04, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+		byte(vm.STOP),
+	}
+	cfg := &Config{Origin: origin}
+	setDefaults(cfg)
+	precompiles := vm.ActivePrecompiles(cfg.ChainConfig.Rules(new(big.Int)))
+
+	tracer := vm.NewAccessListTracer(nil, origin, contract, precompiles)
+	cfg.EVMConfig = vm.Config{Debug: true, Tracer: tracer}
+	if _, _, err := Execute(code, nil, cfg); err != nil {
+		t.Fatalf("execution failed: %v", err)
+	}
+	want := types.AccessList{
+		{Address: contract, StorageKeys: []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")}},
+		{Address: other, StorageKeys: []common.Hash{}},
+	}
+	have := tracer.AccessList()
+	if len(have) != len(want) {
+		t.Fatalf("access list length mismatch: have %d, want %d: %v", len(have), len(want), have)
+	}
+	for _, wantTuple := range want {
+		var found bool
+		for _, haveTuple := range have {
+			if haveTuple.Address != wantTuple.Address {
+				continue
+			}
+			found = true
+			if len(haveTuple.StorageKeys) != len(wantTuple.StorageKeys) {
+				t.Errorf("storage keys mismatch for %x: have %v, want %v", wantTuple.Address, haveTuple.StorageKeys, wantTuple.StorageKeys)
+				continue
+			}
+			keys := make(map[common.Hash]bool)
+			for _, key := range haveTuple.StorageKeys {
+				keys[key] = true
+			}
+			for _, key := range wantTuple.StorageKeys {
+				if !keys[key] {
+					t.Errorf("missing storage key %x for %x", key, wantTuple.Address)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("missing address %x in access list", wantTuple.Address)
+		}
+	}
+	// A tracer seeded with the produced list must compare equal to the original
+	seeded := vm.NewAccessListTracer(have, origin, contract, precompiles)
+	if !seeded.Equal(tracer) {
+		t.Errorf("seeded tracer not equal to the original")
+	}
+}
+
+func TestSession(t *testing.T) {
+	session := NewSession(nil)
+
+	// Install a counter, incrementing and returning storage slot 0 on every call
+	address := common.HexToAddress("0x0a")
+	session.SetCode(address, []byte{
+		byte(vm.PUSH1), 0,
+		byte(vm.SLOAD),
+		byte(vm.PUSH1), 1,
+		byte(vm.ADD),
+		byte(vm.DUP1),
+		byte(vm.PUSH1), 0,
+		byte(vm.SSTORE),
+		byte(vm.PUSH1), 0,
+		byte(vm.MSTORE),
+		byte(vm.PUSH1), 32,
+		byte(vm.PUSH1), 0,
+		byte(vm.RETURN),
+	})
+	call := func() uint64 {
+		ret, _, err := session.Call(address, nil)
+		if err != nil {
+			t.Fatal("didn't expect error", err)
+		}
+		return new(big.Int).SetBytes(ret).Uint64()
+	}
+	if n := call(); n != 1 {
+		t.Fatalf("Expected 1, got %d", n)
+	}
+	snap := session.Snapshot()
+	if n := call(); n != 2 {
+		t.Fatalf("Expected 2, got %d", n)
+	}
+	if err := session.RevertToSnapshot(snap); err != nil {
+		t.Fatal("didn't expect error", err)
+	}
+	if n := call(); n != 2 {
+		t.Fatalf("Expected 2 after rollback, got %d", n)
+	}
+	if err := session.RevertToSnapshot(snap); err == nil {
+		t.Fatal("expected error reverting to discarded snapshot")
+	}
+}
+
+func TestForkConfig(t *testing.T) {
+	// CHAINID was introduced in Istanbul, it must be invalid before
+	code := []byte{byte(vm.CHAINID), byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+	for _, fork := range Forks() {
+		config, err := ForkConfig(fork)
+		if err != nil {
+			t.Fatalf("fork %s: %v", fork, err)
+		}
+		_, _, err = Execute(code, nil, &Config{ChainConfig: config})
+		if want := config.IsIstanbul(common.Big0); (err == nil) != want {
+			t.Errorf("fork %s: CHAINID availability mismatch: err %v", fork, err)
+		}
+	}
+	if _, err := ForkConfig("ConstantinopleFix"); err != nil {
+		t.Errorf("alias not resolved: %v", err)
+	}
+	if _, err := ForkConfig("Unknown"); err == nil {
+		t.Errorf("expected error for unknown fork")
+	}
+}
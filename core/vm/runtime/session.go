@@ -0,0 +1,122 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/state"
+	"github.com/ong2020/go-orange/core/vm"
+)
+
+// Session is a sequence of EVM executions sharing the same state, e.g. deploying
+// a contract and calling it afterwards. Each execution is treated as a separate
+// transaction: the state is finalised after it, so self-destructs and refunds
+// don't leak into the next one.
+//
+// The session reads the config on every execution, so changes to it (e.g. to the
+// origin, value or block number) apply to the subsequent executions.
+type Session struct {
+	cfg       *Config
+	snapshots []*state.StateDB // State copies to roll back to
+}
+
+// NewSession creates an execution session with the given config. If the config
+// has no state set, the session starts from an empty in-memory one.
+func NewSession(cfg *Config) *Session {
+	if cfg == nil {
+		cfg = new(Config)
+	}
+	setDefaults(cfg)
+
+	if cfg.State == nil {
+		cfg.State, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	}
+	return &Session{cfg: cfg}
+}
+
+// State returns the current state of the session. Note, the state is replaced
+// when rolling back to a snapshot.
+func (s *Session) State() *state.StateDB {
+	return s.cfg.State
+}
+
+// SetTracer attaches a tracer to all subsequent executions, or detaches the
+// current one if nil.
+func (s *Session) SetTracer(tracer vm.Tracer) {
+	s.cfg.EVMConfig.Debug = tracer != nil
+	s.cfg.EVMConfig.Tracer = tracer
+}
+
+// SetCode installs the given runtime code at an address, without executing any
+// constructor.
+func (s *Session) SetCode(address common.Address, code []byte) {
+	if !s.cfg.State.Exist(address) {
+		s.cfg.State.CreateAccount(address)
+	}
+	s.cfg.State.SetCode(address, code)
+	s.finalise()
+}
+
+// Create executes the given init code with the origin as the sender, deploying
+// the returned code at the created address.
+func (s *Session) Create(input []byte) ([]byte, common.Address, uint64, error) {
+	vmenv := NewEnv(s.cfg)
+	if s.cfg.ChainConfig.IsBerlin(vmenv.Context.BlockNumber) {
+		s.cfg.State.PrepareAccessList(s.cfg.Origin, nil, vmenv.ActivePrecompiles(), nil)
+	}
+	code, address, leftOverGas, err := vmenv.Create(vm.AccountRef(s.cfg.Origin), input, s.cfg.GasLimit, s.cfg.Value)
+	s.finalise()
+	return code, address, leftOverGas, err
+}
+
+// Call executes the code at the given address with the origin as the sender.
+func (s *Session) Call(address common.Address, input []byte) ([]byte, uint64, error) {
+	vmenv := NewEnv(s.cfg)
+	if s.cfg.ChainConfig.IsBerlin(vmenv.Context.BlockNumber) {
+		s.cfg.State.PrepareAccessList(s.cfg.Origin, &address, vmenv.ActivePrecompiles(), nil)
+	}
+	sender := s.cfg.State.GetOrNewStateObject(s.cfg.Origin)
+	ret, leftOverGas, err := vmenv.Call(sender, address, input, s.cfg.GasLimit, s.cfg.Value)
+	s.finalise()
+	return ret, leftOverGas, err
+}
+
+// Snapshot records the current state of the session, returning an identifier to
+// roll back to it later.
+func (s *Session) Snapshot() int {
+	s.snapshots = append(s.snapshots, s.cfg.State.Copy())
+	return len(s.snapshots) - 1
+}
+
+// RevertToSnapshot rolls the state of the session back to a snapshot, discarding
+// all the snapshots taken since (including the reverted one).
+func (s *Session) RevertToSnapshot(id int) error {
+	if id < 0 || id >= len(s.snapshots) {
+		return fmt.Errorf("unknown snapshot %d", id)
+	}
+	s.cfg.State = s.snapshots[id]
+	s.snapshots = s.snapshots[:id]
+	return nil
+}
+
+// finalise closes the current execution, as if it was a standalone transaction.
+func (s *Session) finalise() {
+	s.cfg.State.Finalise(s.cfg.ChainConfig.IsEIP158(s.cfg.BlockNumber))
+}
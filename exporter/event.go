@@ -0,0 +1,152 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"encoding/json"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/rlp"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Types of the exported events.
+const (
+	EventBlock = "block" // A block was added to the canonical chain
+	EventReorg = "reorg" // Exported blocks were removed from the canonical chain
+)
+
+// Event is a change of the canonical chain published to the sinks. A block
+// event carries the block along with its receipts. A reorg event carries the
+// hashes of the removed blocks, from the highest one down, and the common
+// ancestor the following block events build on as Number and Hash.
+//
+// In protobuf format, events are encoded as the message below, the chain
+// objects in their consensus encoding:
+//
+//	syntax = "proto3";
+//	package orange.exporter;
+//
+//	message Event {
+//	    string type = 1;
+//	    uint64 number = 2;
+//	    bytes hash = 3;
+//	    bytes header = 4;                // RLP encoded header
+//	    repeated bytes transactions = 5; // binary encoded transactions
+//	    repeated bytes receipts = 6;     // binary encoded consensus receipts
+//	    repeated bytes dropped = 7;
+//	}
+type Event struct {
+	Type         string               `json:"type"`
+	Number       hexutil.Uint64       `json:"number"`
+	Hash         common.Hash          `json:"hash"`
+	Header       *types.Header        `json:"header,omitempty"`
+	Transactions []*types.Transaction `json:"transactions,omitempty"`
+	Receipts     types.Receipts       `json:"receipts,omitempty"`
+	Dropped      []common.Hash        `json:"dropped,omitempty"`
+}
+
+func newBlockEvent(block *types.Block, receipts types.Receipts) *Event {
+	return &Event{
+		Type:         EventBlock,
+		Number:       hexutil.Uint64(block.NumberU64()),
+		Hash:         block.Hash(),
+		Header:       block.Header(),
+		Transactions: block.Transactions(),
+		Receipts:     receipts,
+	}
+}
+
+func newReorgEvent(number uint64, hash common.Hash, dropped []common.Hash) *Event {
+	return &Event{
+		Type:    EventReorg,
+		Number:  hexutil.Uint64(number),
+		Hash:    hash,
+		Dropped: dropped,
+	}
+}
+
+// key returns the identifier the sinks deduplicate the event on: the hash of the
+// block, or for a reorg the hash of the highest dropped block, as the ancestor
+// was already exported in a block event of its own.
+func (ev *Event) key() string {
+	if ev.Type == EventReorg && len(ev.Dropped) > 0 {
+		return EventReorg + "-" + ev.Dropped[0].Hex()
+	}
+	return ev.Hash.Hex()
+}
+
+func encodeJSON(ev *Event) ([]byte, error) {
+	return json.Marshal(ev)
+}
+
+func encodeProtobuf(ev *Event) ([]byte, error) {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, ev.Type)
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(ev.Number))
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, ev.Hash[:])
+
+	if ev.Header != nil {
+		enc, err := rlp.EncodeToBytes(ev.Header)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, enc)
+	}
+	for _, tx := range ev.Transactions {
+		enc, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, enc)
+	}
+	for _, receipt := range ev.Receipts {
+		enc, err := receiptBinary(receipt)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendBytes(b, enc)
+	}
+	for _, hash := range ev.Dropped {
+		b = protowire.AppendTag(b, 7, protowire.BytesType)
+		b = protowire.AppendBytes(b, hash[:])
+	}
+	return b, nil
+}
+
+// receiptBinary returns the consensus encoding of the receipt, the RLP list of
+// legacy receipts and the type prefixed payload of typed ones.
+func receiptBinary(receipt *types.Receipt) ([]byte, error) {
+	enc, err := rlp.EncodeToBytes(receipt)
+	if err != nil || receipt.Type == types.LegacyTxType {
+		return enc, err
+	}
+	// Typed receipts are RLP encoded as a string wrapping the payload
+	var payload []byte
+	if err := rlp.DecodeBytes(enc, &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
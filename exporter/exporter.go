@@ -0,0 +1,331 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package exporter publishes the chain to external streaming systems: every new
+// canonical block along with its receipts, and the reorgs undoing the exported
+// blocks, are sent as messages to Kafka or NATS endpoints.
+//
+// Each sink has a cursor stored in the node database, the last block it
+// acknowledged, and only advances it once the endpoint confirmed storing the
+// message, so every event is delivered at least once. A message may be sent
+// again after a failure or a restart: the messages are keyed by the event (the
+// block hash) for the consumers to deduplicate on. The endpoints confirm the
+// messages as follows:
+//
+//   - Kafka sinks use an idempotent producer waiting for all the in-sync
+//     replicas (acks=all), either talking to the brokers directly or through a
+//     Kafka REST proxy, which only returns the offset once the brokers stored
+//     the message.
+//   - NATS sinks publish to a JetStream stream capturing the subject, waiting
+//     for the stream to acknowledge every message. The event key is set as the
+//     message id, so the stream drops the duplicates within its window.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/node"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// Message encodings supported by the exporter.
+const (
+	FormatJSON     = "json"
+	FormatProtobuf = "protobuf"
+)
+
+// Config contains the settings of the chain exporter.
+type Config struct {
+	Sinks         []string      `toml:",omitempty"` // Endpoints to publish to: nats://[user:pass@]host:port/subject (JetStream), kafka://[user:pass@]broker:port/topic or kafka+http(s)://[user:pass@]rest-proxy/topic
+	Format        string        // Message encoding, "json" or "protobuf"
+	FromBlock     *uint64       `toml:",omitempty"` // First block exported to a sink without cursor (nil = the head at startup)
+	RetryInterval time.Duration // Delay before retrying a failed delivery
+}
+
+// DefaultConfig contains the default exporter settings.
+var DefaultConfig = Config{
+	Format:        FormatJSON,
+	RetryInterval: 5 * time.Second,
+}
+
+// Backend is the chain access needed to export the chain.
+type Backend interface {
+	ChainDb() ongdb.Database
+	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error)
+	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// Service exports the chain to the configured sinks.
+type Service struct {
+	config    Config
+	backend   Backend
+	encode    func(*Event) ([]byte, error)
+	exporters []*exporter
+
+	ctx    context.Context // Canceled when the service stops, interrupting the deliveries
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// exporter feeds the chain to a single sink, following its own cursor.
+type exporter struct {
+	*Service
+	sink   Sink
+	name   string      // Endpoint of the sink without credentials
+	id     common.Hash // Identifier of the sink the cursor is stored under
+	start  uint64      // First block to export if there is no cursor
+	cursor *rawdb.ExporterCursor
+	wake   chan struct{}
+}
+
+// New registers the chain exporter on the node.
+func New(stack *node.Node, backend Backend, config Config) error {
+	s, err := newService(backend, config)
+	if err != nil {
+		return err
+	}
+	stack.RegisterLifecycle(s)
+	return nil
+}
+
+func newService(backend Backend, config Config) (*Service, error) {
+	s := &Service{
+		config:  config,
+		backend: backend,
+	}
+	switch config.Format {
+	case FormatJSON, "":
+		s.encode = encodeJSON
+	case FormatProtobuf:
+		s.encode = encodeProtobuf
+	default:
+		return nil, fmt.Errorf("unknown exporter format %q", config.Format)
+	}
+	for _, endpoint := range config.Sinks {
+		sink, name, err := newSink(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		s.exporters = append(s.exporters, &exporter{
+			Service: s,
+			sink:    sink,
+			name:    name,
+			id:      crypto.Keccak256Hash([]byte(name)),
+			wake:    make(chan struct{}, 1),
+		})
+	}
+	return s, nil
+}
+
+// Start implements node.Lifecycle, starting to export the chain.
+func (s *Service) Start() error {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := s.backend.SubscribeChainHeadEvent(heads)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case <-heads:
+				for _, e := range s.exporters {
+					select {
+					case e.wake <- struct{}{}:
+					default:
+					}
+				}
+			case <-sub.Err():
+				return
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+	for _, e := range s.exporters {
+		s.wg.Add(1)
+		go e.loop()
+	}
+	log.Info("Chain exporter started", "sinks", len(s.exporters), "format", s.config.Format)
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the export.
+func (s *Service) Stop() error {
+	s.cancel()
+	s.wg.Wait()
+
+	for _, e := range s.exporters {
+		e.sink.Close()
+	}
+	log.Info("Chain exporter stopped")
+	return nil
+}
+
+// loop exports the chain to the sink whenever the head changes, retrying the
+// failed deliveries.
+func (e *exporter) loop() {
+	defer e.wg.Done()
+
+	if err := e.init(e.ctx); err != nil {
+		log.Error("Failed to initialize chain export", "sink", e.name, "err", err)
+		return
+	}
+	for {
+		var retry <-chan time.Time
+		if err := e.export(e.ctx); err != nil && e.ctx.Err() == nil {
+			log.Warn("Chain export failed", "sink", e.name, "block", e.next(), "err", err)
+			retry = time.After(e.config.RetryInterval)
+		}
+		select {
+		case <-e.wake:
+		case <-retry:
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+// init loads the cursor of the sink, or determines the first block to export if
+// nothing was exported to it yet.
+func (e *exporter) init(ctx context.Context) error {
+	if e.cursor = rawdb.ReadExporterCursor(e.backend.ChainDb(), e.id); e.cursor != nil {
+		log.Info("Resuming chain export", "sink", e.name, "number", e.cursor.Number, "hash", e.cursor.Hash)
+		return nil
+	}
+	if e.config.FromBlock != nil {
+		e.start = *e.config.FromBlock
+	} else {
+		head, err := e.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+		if err != nil {
+			return err
+		}
+		e.start = head.Number.Uint64()
+	}
+	log.Info("Starting chain export", "sink", e.name, "number", e.start)
+	return nil
+}
+
+// next returns the number of the next block to export.
+func (e *exporter) next() uint64 {
+	if e.cursor == nil {
+		return e.start
+	}
+	return e.cursor.Number + 1
+}
+
+// export publishes the events following the cursor until caught up with the
+// head, advancing the cursor with every acknowledged event.
+func (e *exporter) export(ctx context.Context) error {
+	for {
+		ev, err := e.nextEvent(ctx)
+		if ev == nil || err != nil {
+			return err
+		}
+		msg, err := e.encode(ev)
+		if err != nil {
+			return err
+		}
+		if err := e.sink.Publish(ctx, ev.key(), msg); err != nil {
+			return err
+		}
+		e.cursor = &rawdb.ExporterCursor{Number: uint64(ev.Number), Hash: ev.Hash}
+		rawdb.WriteExporterCursor(e.backend.ChainDb(), e.id, e.cursor)
+
+		if ev.Type == EventReorg {
+			log.Info("Exported chain reorg", "sink", e.name, "ancestor", ev.Number, "dropped", len(ev.Dropped))
+		} else {
+			log.Debug("Exported block", "sink", e.name, "number", ev.Number, "hash", ev.Hash)
+		}
+	}
+}
+
+// nextEvent assembles the event following the cursor: the reorg back to the
+// common ancestor if the last exported block is no longer canonical, the next
+// canonical block otherwise. It returns nil once caught up with the head.
+func (e *exporter) nextEvent(ctx context.Context) (*Event, error) {
+	if e.cursor != nil {
+		header, err := e.backend.HeaderByNumber(ctx, rpc.BlockNumber(e.cursor.Number))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil || header.Hash() != e.cursor.Hash {
+			return e.reorgEvent(ctx)
+		}
+	}
+	block, err := e.backend.BlockByNumber(ctx, rpc.BlockNumber(e.next()))
+	if block == nil || err != nil {
+		return nil, err
+	}
+	if e.cursor != nil && block.ParentHash() != e.cursor.Hash {
+		// The chain was reorged in between, retry with the next head event
+		return nil, nil
+	}
+	receipts, err := e.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) != len(block.Transactions()) {
+		return nil, fmt.Errorf("receipts of block #%d unavailable", block.NumberU64())
+	}
+	return newBlockEvent(block, receipts), nil
+}
+
+// reorgEvent walks the branch of the last exported block back to the canonical
+// chain, returning the event undoing the blocks of the branch.
+func (e *exporter) reorgEvent(ctx context.Context) (*Event, error) {
+	var (
+		number  = e.cursor.Number
+		hash    = e.cursor.Hash
+		dropped []common.Hash
+	)
+	for {
+		canonical, err := e.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		if canonical != nil && canonical.Hash() == hash {
+			break
+		}
+		header, err := e.backend.HeaderByHash(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if header == nil || number == 0 {
+			return nil, fmt.Errorf("exported block #%d [%x…] not found", number, hash[:4])
+		}
+		dropped = append(dropped, hash)
+		number, hash = number-1, header.ParentHash
+	}
+	return newReorgEvent(number, hash, dropped), nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus/ongash"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rlp"
+	"github.com/ong2020/go-orange/rpc"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testGenesis = &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddr: {Balance: big.NewInt(1000000000000000000)}},
+	}
+)
+
+type testBackend struct {
+	db    ongdb.Database
+	chain *core.BlockChain
+}
+
+func (b *testBackend) ChainDb() ongdb.Database { return b.db }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.chain.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
+func (b *testBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.chain.SubscribeChainHeadEvent(ch)
+}
+
+// newTestChain creates a chain of blocks transferring funds. The returned function
+// generates blocks on top of the one at the given height, with a different
+// coinbase to fork the chain.
+func newTestChain(t *testing.T, n int) (*testBackend, func(fork, n int) []*types.Block) {
+	signer := types.LatestSigner(params.TestChainConfig)
+	transfers := func(coinbase common.Address) func(int, *core.BlockGen) {
+		return func(i int, b *core.BlockGen) {
+			b.SetCoinbase(coinbase)
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testKey)
+			b.AddTx(tx)
+		}
+	}
+	gendb, blocks, _ := core.GenerateChainWithGenesis(testGenesis, ongash.NewFaker(), n, transfers(common.Address{0xaa}))
+
+	db := rawdb.NewMemoryDatabase()
+	testGenesis.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, ongash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(chain.Stop)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	fork := func(height, n int) []*types.Block {
+		parent := chain.Genesis()
+		if height > 0 {
+			parent = blocks[height-1]
+		}
+		forked, _ := core.GenerateChain(params.TestChainConfig, parent, ongash.NewFaker(), gendb, n, transfers(common.Address{0xbb}))
+		return forked
+	}
+	return &testBackend{db: db, chain: chain}, fork
+}
+
+// memorySink records the published messages, failing on demand.
+type memorySink struct {
+	keys []string
+	msgs [][]byte
+	fail error
+}
+
+func (s *memorySink) Publish(ctx context.Context, key string, msg []byte) error {
+	if s.fail != nil {
+		return s.fail
+	}
+	s.keys = append(s.keys, key)
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func (s *memorySink) events(t *testing.T) []*Event {
+	var events []*Event
+	for _, msg := range s.msgs {
+		ev := new(Event)
+		if err := json.Unmarshal(msg, ev); err != nil {
+			t.Fatalf("invalid message: %v", err)
+		}
+		events = append(events, ev)
+	}
+	s.keys, s.msgs = nil, nil
+	return events
+}
+
+func newTestExporter(t *testing.T, backend Backend, config Config, sink Sink) *exporter {
+	s, err := newService(backend, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &exporter{Service: s, sink: sink, name: "test", id: common.Hash{0x01}, wake: make(chan struct{}, 1)}
+	if err := e.init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func checkBlockEvents(t *testing.T, events []*Event, backend *testBackend, from, to uint64) {
+	t.Helper()
+
+	if len(events) != int(to-from+1) {
+		t.Fatalf("event count mismatch: have %d, want %d", len(events), to-from+1)
+	}
+	for i, ev := range events {
+		block := backend.chain.GetBlockByNumber(from + uint64(i))
+		if ev.Type != EventBlock || uint64(ev.Number) != block.NumberU64() || ev.Hash != block.Hash() {
+			t.Errorf("event %d mismatch: have %s #%d [%x], want block #%d [%x]", i, ev.Type, ev.Number, ev.Hash, block.NumberU64(), block.Hash())
+		}
+		if len(ev.Transactions) != 1 || len(ev.Receipts) != 1 || ev.Receipts[0].TxHash != ev.Transactions[0].Hash() {
+			t.Errorf("event %d: transactions or receipts missing", i)
+		}
+	}
+}
+
+// Tests that the chain is exported from the configured block, and that the export
+// resumes from the stored cursor after a restart.
+func TestExportResume(t *testing.T) {
+	backend, fork := newTestChain(t, 5)
+	from := uint64(2)
+
+	sink := new(memorySink)
+	e := newTestExporter(t, backend, Config{FromBlock: &from}, sink)
+	if err := e.export(context.Background()); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	checkBlockEvents(t, sink.events(t), backend, 2, 5)
+
+	// Extend the chain while the exporter is down, it should only send the new blocks
+	if _, err := backend.chain.InsertChain(fork(5, 3)); err != nil {
+		t.Fatal(err)
+	}
+	e = newTestExporter(t, backend, Config{}, sink)
+	if err := e.export(context.Background()); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	checkBlockEvents(t, sink.events(t), backend, 6, 8)
+}
+
+// Tests that failed deliveries are retried without skipping any block.
+func TestExportRedelivery(t *testing.T) {
+	backend, _ := newTestChain(t, 4)
+	from := uint64(1)
+
+	sink := &memorySink{fail: errors.New("unavailable")}
+	e := newTestExporter(t, backend, Config{FromBlock: &from}, sink)
+	if err := e.export(context.Background()); err == nil {
+		t.Fatalf("export succeeded with failing sink")
+	}
+	if cursor := rawdb.ReadExporterCursor(backend.db, e.id); cursor != nil {
+		t.Fatalf("cursor advanced on failure: %+v", cursor)
+	}
+	sink.fail = nil
+	if err := e.export(context.Background()); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	checkBlockEvents(t, sink.events(t), backend, 1, 4)
+
+	head := backend.chain.CurrentBlock()
+	if cursor := rawdb.ReadExporterCursor(backend.db, e.id); cursor == nil || cursor.Number != 4 || cursor.Hash != head.Hash() {
+		t.Fatalf("cursor mismatch: have %+v, want #4 [%x]", cursor, head.Hash())
+	}
+}
+
+// Tests that reorgs of the exported blocks are published, followed by the blocks
+// of the new canonical chain.
+func TestExportReorg(t *testing.T) {
+	backend, fork := newTestChain(t, 5)
+	from := uint64(1)
+
+	sink := new(memorySink)
+	e := newTestExporter(t, backend, Config{FromBlock: &from}, sink)
+	if err := e.export(context.Background()); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	var old []common.Hash
+	for n := uint64(5); n > 2; n-- {
+		old = append(old, backend.chain.GetHeaderByNumber(n).Hash())
+	}
+	sink.events(t)
+
+	// Replace blocks 3-5 with a longer branch
+	if _, err := backend.chain.InsertChain(fork(2, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.export(context.Background()); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	keys := sink.keys
+	events := sink.events(t)
+	if len(events) != 5 {
+		t.Fatalf("event count mismatch: have %d, want 5", len(events))
+	}
+	reorg := events[0]
+	if reorg.Type != EventReorg || reorg.Number != 2 || reorg.Hash != backend.chain.GetHeaderByNumber(2).Hash() {
+		t.Errorf("reorg event mismatch: have %s #%d [%x]", reorg.Type, reorg.Number, reorg.Hash)
+	}
+	if len(reorg.Dropped) != len(old) {
+		t.Fatalf("dropped block count mismatch: have %d, want %d", len(reorg.Dropped), len(old))
+	}
+	for i := range old {
+		if reorg.Dropped[i] != old[i] {
+			t.Errorf("dropped block %d mismatch: have %x, want %x", i, reorg.Dropped[i], old[i])
+		}
+	}
+	if want := "reorg-" + old[0].Hex(); keys[0] != want {
+		t.Errorf("reorg event key mismatch: have %s, want %s", keys[0], want)
+	}
+	checkBlockEvents(t, events[1:], backend, 3, 6)
+}
+
+// Tests the protobuf encoding of events.
+func TestEncodeProtobuf(t *testing.T) {
+	backend, _ := newTestChain(t, 1)
+	block := backend.chain.GetBlockByNumber(1)
+	ev := newBlockEvent(block, backend.chain.GetReceiptsByHash(block.Hash()))
+
+	enc, err := encodeProtobuf(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[protowire.Number][][]byte)
+	for len(enc) > 0 {
+		num, typ, n := protowire.ConsumeTag(enc)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		enc = enc[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(enc)
+			if n < 0 {
+				t.Fatalf("invalid field %d: %v", num, protowire.ParseError(n))
+			}
+			fields[num], enc = append(fields[num], v), enc[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(enc)
+			if n < 0 || v != 1 {
+				t.Fatalf("invalid number field: %d", v)
+			}
+			enc = enc[n:]
+		}
+	}
+	if string(fields[1][0]) != EventBlock || !bytes.Equal(fields[3][0], block.Hash().Bytes()) {
+		t.Errorf("event type or hash mismatch")
+	}
+	var header types.Header
+	if err := rlp.DecodeBytes(fields[4][0], &header); err != nil || header.Hash() != block.Hash() {
+		t.Errorf("header mismatch: %v", err)
+	}
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(fields[5][0]); err != nil || tx.Hash() != block.Transactions()[0].Hash() {
+		t.Errorf("transaction mismatch: %v", err)
+	}
+	var receipt types.Receipt
+	if err := rlp.DecodeBytes(fields[6][0], &receipt); err != nil || receipt.CumulativeGasUsed != params.TxGas {
+		t.Errorf("receipt mismatch: %v", err)
+	}
+}
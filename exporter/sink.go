@@ -0,0 +1,483 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Sink is an endpoint the events are published to.
+type Sink interface {
+	// Publish delivers the message, returning once the endpoint acknowledged it.
+	// The key identifies the event, allowing consumers to deduplicate.
+	Publish(ctx context.Context, key string, msg []byte) error
+
+	// Close releases the connection to the endpoint.
+	Close() error
+}
+
+// newSink creates the sink for the endpoint URL, also returning the endpoint
+// stripped of the credentials for logging.
+func newSink(endpoint string) (Sink, string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid exporter sink %q: %v", endpoint, err)
+	}
+	target := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || target == "" || strings.Contains(target, "/") {
+		return nil, "", fmt.Errorf("invalid exporter sink %q: want scheme://host/subject", endpoint)
+	}
+	user := u.User
+	u.User = nil
+	name := u.String()
+
+	switch u.Scheme {
+	case "nats":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "4222")
+		}
+		return &natsSink{addr: host, subject: target, user: user}, name, nil
+	case "kafka":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(host, "9092")
+		}
+		config := newKafkaConfig(user)
+		if err := config.Validate(); err != nil {
+			return nil, "", fmt.Errorf("invalid exporter sink %q: %v", name, err)
+		}
+		return &kafkaSink{broker: host, topic: target, config: config, dial: sarama.NewSyncProducer}, name, nil
+	case "kafka+http", "kafka+https":
+		proxy := strings.TrimPrefix(u.Scheme, "kafka+") + "://" + u.Host
+		return &kafkaProxySink{url: proxy + "/topics/" + url.PathEscape(target), user: user, client: new(http.Client)}, name, nil
+	default:
+		return nil, "", fmt.Errorf("invalid exporter sink %q: unsupported scheme %q", name, u.Scheme)
+	}
+}
+
+// natsSink publishes the messages to a NATS JetStream stream, which must be set
+// up to capture the subject. It speaks the NATS client protocol directly: every
+// message is published with a reply subject and the event key as Nats-Msg-Id,
+// and is only delivered once the stream acknowledged storing it. A message sent
+// again after a failure is discarded by the stream if it is still within its
+// duplicate window.
+type natsSink struct {
+	addr    string
+	subject string
+	user    *url.Userinfo
+
+	mu    sync.Mutex
+	conn  net.Conn
+	r     *bufio.Reader
+	inbox string // Prefix of the reply subjects the acknowledgements are sent to
+	seq   uint64 // Number of the last publication, making its reply subject unique
+}
+
+// natsPubAck is the acknowledgement of a message published to JetStream.
+type natsPubAck struct {
+	Stream    string `json:"stream"`
+	Seq       uint64 `json:"seq"`
+	Duplicate bool   `json:"duplicate"`
+	Error     *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+func (s *natsSink) Publish(ctx context.Context, key string, msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetDeadline(deadline)
+	} else {
+		s.conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	// Abort the delivery if the context is canceled meanwhile
+	done := make(chan struct{})
+	defer close(done)
+	go func(conn net.Conn) {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}(s.conn)
+
+	s.seq++
+	var (
+		reply  = fmt.Sprintf("%s.%d", s.inbox, s.seq)
+		header = "NATS/1.0\r\nNats-Msg-Id: " + key + "\r\n\r\n"
+		buf    bytes.Buffer
+	)
+	fmt.Fprintf(&buf, "HPUB %s %s %d %d\r\n", s.subject, reply, len(header), len(header)+len(msg))
+	buf.WriteString(header)
+	buf.Write(msg)
+	buf.WriteString("\r\n")
+	if _, err := s.conn.Write(buf.Bytes()); err != nil {
+		s.reset()
+		return err
+	}
+	if err := s.awaitAck(reply); err != nil {
+		s.reset()
+		return err
+	}
+	return nil
+}
+
+// connect dials the server, performs the protocol handshake and subscribes to
+// the reply subjects of the acknowledgements.
+func (s *natsSink) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	s.conn, s.r = conn, bufio.NewReader(conn)
+
+	// The server greets with its INFO, answered with the client options. The
+	// message headers carrying the deduplication ids need NATS 2.2 or later.
+	line, err := s.readLine()
+	if err != nil {
+		s.reset()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		s.reset()
+		return fmt.Errorf("unexpected NATS greeting %q", line)
+	}
+	var info struct {
+		Headers bool `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		s.reset()
+		return fmt.Errorf("invalid NATS greeting: %v", err)
+	}
+	if !info.Headers {
+		s.reset()
+		return errors.New("NATS server doesn't support message headers, JetStream unavailable")
+	}
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "gong-exporter", "headers": true, "no_responders": true}
+	if s.user != nil {
+		opts["user"] = s.user.Username()
+		if pass, ok := s.user.Password(); ok {
+			opts["pass"] = pass
+		}
+	}
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		s.reset()
+		return err
+	}
+	s.inbox = "_INBOX." + hex.EncodeToString(nonce[:])
+
+	blob, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nSUB %s.* 1\r\nPING\r\n", blob, s.inbox); err != nil {
+		s.reset()
+		return err
+	}
+	if err := s.awaitPong(); err != nil {
+		s.reset()
+		return err
+	}
+	return nil
+}
+
+// awaitPong reads the server messages until the PONG answering our PING,
+// replying to the server's own PINGs meanwhile.
+func (s *natsSink) awaitPong() error {
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := io.WriteString(s.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// awaitAck reads the server messages until the acknowledgement sent to the given
+// reply subject, skipping the late acknowledgements of previous publications and
+// replying to the server's PINGs meanwhile.
+func (s *natsSink) awaitAck(reply string) error {
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		switch {
+		case line == "PING":
+			if _, err := io.WriteString(s.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+
+		case len(fields) >= 4 && (fields[0] == "MSG" || fields[0] == "HMSG"):
+			// MSG <subject> <sid> [reply] <size>, HMSG <subject> <sid> [reply] <header size> <size>
+			var hdrSize, size int
+			if fields[0] == "HMSG" {
+				if len(fields) < 5 {
+					return fmt.Errorf("invalid NATS message %q", line)
+				}
+				hdrSize, _ = strconv.Atoi(fields[len(fields)-2])
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || hdrSize < 0 || hdrSize > size {
+				return fmt.Errorf("invalid NATS message %q", line)
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(s.r, payload); err != nil {
+				return err
+			}
+			if fields[1] != reply {
+				continue
+			}
+			header, body := string(payload[:hdrSize]), payload[hdrSize:size]
+			if len(body) == 0 && strings.HasPrefix(header, "NATS/1.0 503") {
+				return fmt.Errorf("no JetStream stream captures subject %s", s.subject)
+			}
+			var ack natsPubAck
+			if err := json.Unmarshal(body, &ack); err != nil {
+				return fmt.Errorf("invalid JetStream acknowledgement: %v", err)
+			}
+			if ack.Error != nil {
+				return fmt.Errorf("JetStream error: %s (code %d)", ack.Error.Description, ack.Error.Code)
+			}
+			if ack.Stream == "" {
+				return errors.New("invalid JetStream acknowledgement: stream missing")
+			}
+			return nil
+		}
+	}
+}
+
+func (s *natsSink) readLine() (string, error) {
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// reset drops the connection, reconnecting on the next publication.
+func (s *natsSink) reset() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn, s.r = nil, nil
+	}
+}
+
+func (s *natsSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+	return nil
+}
+
+// kafkaSink produces the messages to a Kafka topic over the native protocol,
+// keyed by the event. The producer is idempotent and waits for all the in-sync
+// replicas to store a message, so the retries of the producer don't duplicate
+// a message and an acknowledged one survives the loss of the partition leader.
+type kafkaSink struct {
+	broker string // Bootstrap broker, the others are discovered from its metadata
+	topic  string
+	config *sarama.Config
+	dial   func(addrs []string, config *sarama.Config) (sarama.SyncProducer, error)
+
+	mu       sync.Mutex
+	producer sarama.SyncProducer
+}
+
+// newKafkaConfig creates the settings of the Kafka producers, authenticating with
+// SASL/PLAIN if credentials are given.
+func newKafkaConfig(user *url.Userinfo) *sarama.Config {
+	config := sarama.NewConfig()
+	config.ClientID = "gong-exporter"
+	config.Version = sarama.V0_11_0_0 // First version supporting idempotent producers
+	config.Net.MaxOpenRequests = 1
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Idempotent = true
+	config.Producer.Return.Successes = true
+
+	if user != nil {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		config.Net.SASL.User = user.Username()
+		config.Net.SASL.Password, _ = user.Password()
+	}
+	return config
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, key string, msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.producer == nil {
+		producer, err := s.dial([]string{s.broker}, s.config)
+		if err != nil {
+			return err
+		}
+		s.producer = producer
+	}
+	_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(msg),
+	})
+	if err != nil {
+		// Start over with a new producer id, the sequence of the failed one is
+		// uncertain
+		s.reset()
+	}
+	return err
+}
+
+// reset drops the producer, creating a new one on the next publication.
+func (s *kafkaSink) reset() {
+	if s.producer != nil {
+		s.producer.Close()
+		s.producer = nil
+	}
+}
+
+func (s *kafkaSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reset()
+	return nil
+}
+
+// kafkaProxySink produces the messages to a Kafka topic through a Kafka REST
+// proxy (v2 API), keyed by the event, for brokers which can't be reached
+// directly.
+type kafkaProxySink struct {
+	url    string
+	user   *url.Userinfo
+	client *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition int     `json:"partition"`
+		Offset    int64   `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (s *kafkaProxySink) Publish(ctx context.Context, key string, msg []byte) error {
+	body, err := json.Marshal(map[string][]kafkaRecord{
+		"records": {{
+			Key:   base64.StdEncoding.EncodeToString([]byte(key)),
+			Value: base64.StdEncoding.EncodeToString(msg),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.binary.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if s.user != nil {
+		pass, _ := s.user.Password()
+		req.SetBasicAuth(s.user.Username(), pass)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	blob, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kafka proxy responded %s: %s", resp.Status, bytes.TrimSpace(blob))
+	}
+	var result kafkaProduceResponse
+	if err := json.Unmarshal(blob, &result); err != nil {
+		return err
+	}
+	if len(result.Offsets) == 0 {
+		return errors.New("kafka proxy returned no offset")
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			reason := "unknown"
+			if offset.Error != nil {
+				reason = *offset.Error
+			}
+			return fmt.Errorf("kafka produce failed: %s (code %d)", reason, *offset.ErrorCode)
+		}
+	}
+	return nil
+}
+
+func (s *kafkaProxySink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
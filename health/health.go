@@ -0,0 +1,152 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package health implements plain HTTP liveness and readiness endpoints, meant
+// to be probed by container orchestrators and load balancers.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ong2020/go-orange"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/node"
+	"github.com/ong2020/go-orange/ong/downloader"
+)
+
+// Config contains the readiness thresholds of the health endpoints.
+type Config struct {
+	Enabled    bool          // Whether to serve the endpoints on the HTTP-RPC server
+	MinPeers   int           // Minimum number of connected peers for the node to be ready
+	MaxHeadAge time.Duration // Maximum age of the head block for the node to be ready (0 = unchecked)
+}
+
+// DefaultConfig contains the default readiness thresholds.
+var DefaultConfig = Config{
+	MinPeers: 1,
+}
+
+// Backend is the chain access needed to assess the readiness of the node.
+type Backend interface {
+	CurrentHeader() *types.Header
+	Downloader() *downloader.Downloader
+}
+
+// Status is the readiness report served by the readiness endpoint.
+type Status struct {
+	Ready   bool     `json:"ready"`             // Whether the node is ready to serve traffic
+	Syncing bool     `json:"syncing"`           // Whether the node is synchronising with the network
+	Peers   int      `json:"peers"`             // Number of connected peers
+	Head    uint64   `json:"head"`              // Number of the head block
+	HeadAge string   `json:"headAge"`           // Time since the head block was produced
+	Reasons []string `json:"reasons,omitempty"` // Failed readiness checks
+}
+
+// service assesses the health of the node.
+type service struct {
+	config   Config
+	head     func() *types.Header
+	progress func() orange.SyncProgress
+	peers    func() int
+}
+
+// New registers the liveness (/health) and readiness (/ready) endpoints on the
+// HTTP-RPC server of the node. The endpoints answer plain JSON, not wrapped in
+// a JSON-RPC envelope: /health reports 200 as long as the node runs, /ready
+// reports 200 once the node is synced, connected to enough peers and following
+// a fresh enough head, 503 otherwise.
+func New(stack *node.Node, backend Backend, config Config) error {
+	if backend == nil {
+		panic("missing backend")
+	}
+	s := &service{
+		config:   config,
+		head:     backend.CurrentHeader,
+		progress: backend.Downloader().Progress,
+		peers: func() int {
+			if server := stack.Server(); server != nil {
+				return server.PeerCount()
+			}
+			return 0
+		},
+	}
+	stack.RegisterHandler("Health", "/health", http.HandlerFunc(s.health))
+	stack.RegisterHandler("Readiness", "/ready", http.HandlerFunc(s.ready))
+	return nil
+}
+
+// status assesses the readiness of the node against the configured thresholds.
+func (s *service) status() *Status {
+	var (
+		progress = s.progress()
+		head     = s.head()
+		status   = &Status{
+			Syncing: progress.CurrentBlock < progress.HighestBlock,
+			Peers:   s.peers(),
+			Head:    head.Number.Uint64(),
+		}
+		mined = time.Unix(int64(head.Time), 0)
+		age   = time.Since(mined)
+	)
+	status.HeadAge = common.PrettyAge(mined).String()
+
+	if status.Syncing {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("syncing: block %d of %d", progress.CurrentBlock, progress.HighestBlock))
+	}
+	if status.Peers < s.config.MinPeers {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("not enough peers: have %d, want %d", status.Peers, s.config.MinPeers))
+	}
+	if s.config.MaxHeadAge > 0 && age > s.config.MaxHeadAge {
+		status.Reasons = append(status.Reasons, fmt.Sprintf("stale head: %v old, allowed %v", common.PrettyDuration(age), s.config.MaxHeadAge))
+	}
+	status.Ready = len(status.Reasons) == 0
+	return status
+}
+
+// health answers the liveness probes.
+func (s *service) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reply(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ready answers the readiness probes.
+func (s *service) ready(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	status := s.status()
+	if !status.Ready {
+		reply(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	reply(w, http.StatusOK, status)
+}
+
+// reply writes a JSON response with the given status code.
+func reply(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
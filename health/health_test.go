@@ -0,0 +1,97 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package health
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange"
+	"github.com/ong2020/go-orange/core/types"
+)
+
+// Tests that the readiness endpoint reports the failed thresholds.
+func TestReadiness(t *testing.T) {
+	var (
+		progress orange.SyncProgress
+		peers    int
+		mined    = time.Now()
+	)
+	s := &service{
+		config: Config{MinPeers: 2, MaxHeadAge: time.Minute},
+		head: func() *types.Header {
+			return &types.Header{Number: big.NewInt(10), Time: uint64(mined.Unix())}
+		},
+		progress: func() orange.SyncProgress { return progress },
+		peers:    func() int { return peers },
+	}
+	tests := []struct {
+		progress orange.SyncProgress
+		peers    int
+		mined    time.Time
+		code     int
+		reasons  int
+	}{
+		{orange.SyncProgress{}, 2, time.Now(), http.StatusOK, 0},
+		{orange.SyncProgress{CurrentBlock: 10, HighestBlock: 20}, 2, time.Now(), http.StatusServiceUnavailable, 1},
+		{orange.SyncProgress{}, 1, time.Now(), http.StatusServiceUnavailable, 1},
+		{orange.SyncProgress{}, 2, time.Now().Add(-time.Hour), http.StatusServiceUnavailable, 1},
+		{orange.SyncProgress{CurrentBlock: 10, HighestBlock: 20}, 0, time.Now().Add(-time.Hour), http.StatusServiceUnavailable, 3},
+	}
+	for i, tt := range tests {
+		progress, peers, mined = tt.progress, tt.peers, tt.mined
+
+		rec := httptest.NewRecorder()
+		s.ready(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		if rec.Code != tt.code {
+			t.Errorf("test %d: status code mismatch: have %d, want %d", i, rec.Code, tt.code)
+		}
+		var status Status
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("test %d: failed to decode status: %v", i, err)
+		}
+		if status.Ready != (tt.code == http.StatusOK) {
+			t.Errorf("test %d: readiness mismatch: have %v, want %v", i, status.Ready, tt.code == http.StatusOK)
+		}
+		if len(status.Reasons) != tt.reasons {
+			t.Errorf("test %d: reasons mismatch: have %v, want %d", i, status.Reasons, tt.reasons)
+		}
+		if status.Peers != tt.peers || status.Head != 10 {
+			t.Errorf("test %d: status mismatch: have peers %d head %d, want peers %d head 10", i, status.Peers, status.Head, tt.peers)
+		}
+	}
+}
+
+// Tests that the liveness endpoint only answers probes.
+func TestHealth(t *testing.T) {
+	s := new(service)
+
+	rec := httptest.NewRecorder()
+	s.health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status code mismatch: have %d, want %d", rec.Code, http.StatusOK)
+	}
+	rec = httptest.NewRecorder()
+	s.health(rec, httptest.NewRequest(http.MethodPost, "/health", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status code mismatch: have %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
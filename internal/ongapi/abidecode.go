@@ -0,0 +1,220 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/ong2020/go-orange/accounts/abi"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// maxRegisteredABIs is the maximum number of contract ABIs that can be
+// registered for decoding at the same time, across all the sessions.
+const maxRegisteredABIs = 1024
+
+var (
+	errTooManyABIs        = errors.New("too many registered ABIs")
+	errABISessionRequired = errors.New("ABI registration requires a persistent connection (websocket or IPC)")
+)
+
+// DecodedCall is a contract call decoded using a registered ABI.
+type DecodedCall struct {
+	Method    string                 `json:"method"`
+	Signature string                 `json:"signature"`
+	Args      map[string]interface{} `json:"args"`
+}
+
+// DecodedLog is a contract event decoded using a registered ABI.
+type DecodedLog struct {
+	Address   common.Address         `json:"address"`
+	LogIndex  uint                   `json:"logIndex"`
+	Event     string                 `json:"event"`
+	Signature string                 `json:"signature"`
+	Args      map[string]interface{} `json:"args"`
+}
+
+// ABIRegistry holds the contract ABIs supplied by RPC clients, used to decode
+// the calls and logs returned by the API. The ABIs are registered on the RPC
+// session of the client: they only apply to the calls made on the same
+// connection and are dropped when it is closed.
+type ABIRegistry struct {
+	sessions map[rpc.ID]map[common.Address]*abi.ABI
+	count    int // Number of ABIs registered across all the sessions
+	lock     sync.RWMutex
+}
+
+// NewABIRegistry creates an empty contract ABI registry.
+func NewABIRegistry() *ABIRegistry {
+	return &ABIRegistry{sessions: make(map[rpc.ID]map[common.Address]*abi.ABI)}
+}
+
+// Register parses the JSON ABI definition and associates it with the contract
+// address in the session of the caller, replacing any previously registered one.
+func (r *ABIRegistry) Register(ctx context.Context, address common.Address, definition string) error {
+	session, ok := rpc.SessionFromContext(ctx)
+	if !ok {
+		return errABISessionRequired
+	}
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	abis := r.sessions[session.ID]
+	if _, ok := abis[address]; !ok && r.count >= maxRegisteredABIs {
+		return errTooManyABIs
+	}
+	if abis == nil {
+		abis = make(map[common.Address]*abi.ABI)
+		r.sessions[session.ID] = abis
+		go r.release(session)
+	}
+	if _, ok := abis[address]; !ok {
+		r.count++
+	}
+	abis[address] = &parsed
+	return nil
+}
+
+// Unregister removes the ABI of the contract from the session of the caller,
+// returning whether there was one.
+func (r *ABIRegistry) Unregister(ctx context.Context, address common.Address) bool {
+	session, ok := rpc.SessionFromContext(ctx)
+	if !ok {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	abis := r.sessions[session.ID]
+	if _, ok := abis[address]; !ok {
+		return false
+	}
+	delete(abis, address)
+	r.count--
+	return true
+}
+
+// release drops the ABIs registered in a session once its connection is closed.
+func (r *ABIRegistry) release(session *rpc.Session) {
+	<-session.Closed()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.count -= len(r.sessions[session.ID])
+	delete(r.sessions, session.ID)
+}
+
+// lookup retrieves the ABI registered for the contract in the session of the
+// caller, if any.
+func (r *ABIRegistry) lookup(ctx context.Context, address common.Address) *abi.ABI {
+	if r == nil {
+		return nil
+	}
+	session, ok := rpc.SessionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.sessions[session.ID][address]
+}
+
+// DecodeCall decodes the input of a call to the given contract, returning nil
+// if no matching ABI method is registered.
+func (r *ABIRegistry) DecodeCall(ctx context.Context, to *common.Address, input []byte) *DecodedCall {
+	if to == nil || len(input) < 4 {
+		return nil
+	}
+	contract := r.lookup(ctx, *to)
+	if contract == nil {
+		return nil
+	}
+	method, err := contract.MethodById(input[:4])
+	if err != nil {
+		return nil
+	}
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, input[4:]); err != nil {
+		return nil
+	}
+	return &DecodedCall{
+		Method:    method.Name,
+		Signature: method.Sig,
+		Args:      args,
+	}
+}
+
+// DecodeLog decodes a log emitted by a contract, returning nil if no matching
+// ABI event is registered.
+func (r *ABIRegistry) DecodeLog(ctx context.Context, log *types.Log) *DecodedLog {
+	if len(log.Topics) == 0 {
+		return nil
+	}
+	contract := r.lookup(ctx, log.Address)
+	if contract == nil {
+		return nil
+	}
+	event, err := contract.EventByID(log.Topics[0])
+	if err != nil {
+		return nil
+	}
+	args := make(map[string]interface{})
+	if len(log.Data) > 0 {
+		if err := event.Inputs.NonIndexed().UnpackIntoMap(args, log.Data); err != nil {
+			return nil
+		}
+	}
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(args, indexed, log.Topics[1:]); err != nil {
+		return nil
+	}
+	return &DecodedLog{
+		Address:   log.Address,
+		LogIndex:  log.Index,
+		Event:     event.Name,
+		Signature: event.Sig,
+		Args:      args,
+	}
+}
+
+// DecodeLogs decodes all the logs emitted by contracts with a registered ABI,
+// skipping the others.
+func (r *ABIRegistry) DecodeLogs(ctx context.Context, logs []*types.Log) []*DecodedLog {
+	var decoded []*DecodedLog
+	for _, log := range logs {
+		if dec := r.DecodeLog(ctx, log); dec != nil {
+			decoded = append(decoded, dec)
+		}
+	}
+	return decoded
+}
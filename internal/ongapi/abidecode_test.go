@@ -0,0 +1,161 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+const pingABI = `[
+	{"type":"function","name":"ping","inputs":[{"name":"value","type":"uint256"}],"outputs":[]},
+	{"type":"event","name":"Ping","inputs":[{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+var (
+	// pingCode is the code of a contract emitting Ping(42) whatever it is
+	// called with.
+	pingCode = append(append([]byte{0x60, 0x2a, 0x60, 0x00, 0x52, 0x7f}, crypto.Keccak256([]byte("Ping(uint256)"))...), 0x60, 0x20, 0x60, 0x00, 0xa1, 0x00)
+
+	// pingInput is the input of a ping(7) call.
+	pingInput = append(crypto.Keccak256([]byte("ping(uint256)"))[:4], common.LeftPadBytes([]byte{0x07}, 32)...)
+)
+
+// dialABISession serves the APIs decoding with the registered ABIs over an
+// in-process connection, as the ABIs are registered per connection.
+func dialABISession(t *testing.T, b Backend) *rpc.Client {
+	server := rpc.NewServer()
+	t.Cleanup(server.Stop)
+
+	server.RegisterName("debug", NewPrivateDebugAPI(b))
+	server.RegisterName("ong", NewPublicBlockChainAPI(b))
+	server.RegisterName("ong", NewPublicTransactionPoolAPI(b, nil, nil))
+
+	client := rpc.DialInProc(server)
+	t.Cleanup(client.Close)
+	return client
+}
+
+// Tests that the plain receipts are left undecoded, while the decoded receipts
+// carry the call and the logs decoded with the ABI registered on the connection.
+func TestGetDecodedTransactionReceipt(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0de")
+		alloc    = core.GenesisAlloc{contract: {Code: pingCode, Balance: new(big.Int)}}
+		txHash   common.Hash
+	)
+	backend := newTestBackend(t, alloc, 1, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), contract, new(big.Int), 100000, big.NewInt(10*params.GWei), pingInput), types.HomesteadSigner{}, testKey)
+		b.AddTx(tx)
+		txHash = tx.Hash()
+	})
+	var (
+		client = dialABISession(t, backend)
+		other  = dialABISession(t, backend)
+	)
+	receipt := make(map[string]interface{})
+	if err := client.Call(&receipt, "ong_getTransactionReceipt", txHash); err != nil {
+		t.Fatalf("failed to retrieve receipt: %v", err)
+	}
+	if _, ok := receipt["decodedLogs"]; ok {
+		t.Fatalf("plain receipt carries decoded logs")
+	}
+	// Without a registered ABI nothing is decoded
+	var decoded struct {
+		DecodedCall *DecodedCall  `json:"decodedCall"`
+		DecodedLogs []*DecodedLog `json:"decodedLogs"`
+	}
+	if err := client.Call(&decoded, "ong_getDecodedTransactionReceipt", txHash); err != nil {
+		t.Fatalf("failed to retrieve decoded receipt: %v", err)
+	}
+	if decoded.DecodedCall != nil || len(decoded.DecodedLogs) != 0 {
+		t.Fatalf("receipt decoded without ABI: call %+v, logs %+v", decoded.DecodedCall, decoded.DecodedLogs)
+	}
+	// Register the ABI and check the call and the log are decoded
+	if err := client.Call(nil, "debug_registerABI", contract, pingABI); err != nil {
+		t.Fatalf("failed to register ABI: %v", err)
+	}
+	if err := client.Call(&decoded, "ong_getDecodedTransactionReceipt", txHash); err != nil {
+		t.Fatalf("failed to retrieve decoded receipt: %v", err)
+	}
+	if call := decoded.DecodedCall; call == nil || call.Method != "ping" || call.Args["value"].(float64) != 7 {
+		t.Fatalf("call mismatch: %+v", call)
+	}
+	if logs := decoded.DecodedLogs; len(logs) != 1 || logs[0].Event != "Ping" || logs[0].Address != contract || logs[0].Args["value"].(float64) != 42 {
+		t.Fatalf("logs mismatch: %+v", logs)
+	}
+	// Registering the ABI must not leak into the plain receipts nor into the
+	// other connections
+	receipt = make(map[string]interface{})
+	client.Call(&receipt, "ong_getTransactionReceipt", txHash)
+	if _, ok := receipt["decodedCall"]; ok {
+		t.Fatalf("plain receipt carries decoded call")
+	}
+	decoded.DecodedCall, decoded.DecodedLogs = nil, nil
+	if err := other.Call(&decoded, "ong_getDecodedTransactionReceipt", txHash); err != nil {
+		t.Fatalf("failed to retrieve decoded receipt: %v", err)
+	}
+	if decoded.DecodedCall != nil || len(decoded.DecodedLogs) != 0 {
+		t.Fatalf("receipt decoded with the ABI of another connection: call %+v, logs %+v", decoded.DecodedCall, decoded.DecodedLogs)
+	}
+}
+
+// Tests that the ABIs can only be registered on persistent connections, and are
+// dropped when the connection is closed.
+func TestABIRegistrySession(t *testing.T) {
+	var (
+		backend  = newTestBackend(t, nil, 0, nil)
+		contract = common.HexToAddress("0xc0de")
+	)
+	if err := NewPrivateDebugAPI(backend).RegisterABI(context.Background(), contract, pingABI); err != errABISessionRequired {
+		t.Fatalf("registration without session error mismatch: have %v, want %v", err, errABISessionRequired)
+	}
+	client := dialABISession(t, backend)
+	if err := client.Call(nil, "debug_registerABI", contract, pingABI); err != nil {
+		t.Fatalf("failed to register ABI: %v", err)
+	}
+	var removed bool
+	if err := client.Call(&removed, "debug_unregisterABI", contract); err != nil || !removed {
+		t.Fatalf("failed to unregister ABI: removed %v, err %v", removed, err)
+	}
+	if err := client.Call(nil, "debug_registerABI", contract, pingABI); err != nil {
+		t.Fatalf("failed to register ABI: %v", err)
+	}
+	client.Close()
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		backend.abis.lock.RLock()
+		sessions, count := len(backend.abis.sessions), backend.abis.count
+		backend.abis.lock.RUnlock()
+
+		if sessions == 0 && count == 0 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("ABIs not dropped with the connection: %d sessions, %d ABIs", sessions, count)
+		}
+	}
+}
@@ -19,10 +19,14 @@ package ongapi
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
@@ -36,6 +40,7 @@ import (
 	"github.com/ong2020/go-orange/consensus/clique"
 	"github.com/ong2020/go-orange/consensus/ongash"
 	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/state"
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/core/vm"
 	"github.com/ong2020/go-orange/crypto"
@@ -110,7 +115,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -118,7 +123,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = NewRPCPendingTransaction(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -191,14 +196,16 @@ func (s *PublicAccountAPI) Accounts() []common.Address {
 type PrivateAccountAPI struct {
 	am        *accounts.Manager
 	nonceLock *AddrLocker
+	guard     *SpendGuard
 	b         Backend
 }
 
 // NewPrivateAccountAPI create a new PrivateAccountAPI.
-func NewPrivateAccountAPI(b Backend, nonceLock *AddrLocker) *PrivateAccountAPI {
+func NewPrivateAccountAPI(b Backend, nonceLock *AddrLocker, guard *SpendGuard) *PrivateAccountAPI {
 	return &PrivateAccountAPI{
 		am:        b.AccountManager(),
 		nonceLock: nonceLock,
+		guard:     guard,
 		b:         b,
 	}
 }
@@ -211,29 +218,93 @@ func (s *PrivateAccountAPI) ListAccounts() []common.Address {
 // rawWallet is a JSON representation of an accounts.Wallet interface, with its
 // data contents extracted into plain fields.
 type rawWallet struct {
-	URL      string             `json:"url"`
-	Status   string             `json:"status"`
-	Failure  string             `json:"failure,omitempty"`
-	Accounts []accounts.Account `json:"accounts,omitempty"`
+	URL      string       `json:"url"`
+	Status   string       `json:"status"`
+	Failure  string       `json:"failure,omitempty"`
+	Accounts []rawAccount `json:"accounts,omitempty"`
+}
+
+// rawAccount is an account of a wallet, along with its key file metadata for
+// accounts of the local keystore.
+type rawAccount struct {
+	accounts.Account
+	Meta *keystore.KeyMeta `json:"meta,omitempty"`
 }
 
 // ListWallets will return a list of wallets this node manages.
 func (s *PrivateAccountAPI) ListWallets() []rawWallet {
 	wallets := make([]rawWallet, 0) // return [] instead of nil if empty
 	for _, wallet := range s.am.Wallets() {
-		status, failure := wallet.Status()
+		wallets = append(wallets, s.rawWallet(wallet))
+	}
+	return wallets
+}
 
-		raw := rawWallet{
-			URL:      wallet.URL().String(),
-			Status:   status,
-			Accounts: wallet.Accounts(),
-		}
-		if failure != nil {
-			raw.Failure = failure.Error()
+// rawWallet assembles the JSON representation of a wallet.
+func (s *PrivateAccountAPI) rawWallet(wallet accounts.Wallet) rawWallet {
+	status, failure := wallet.Status()
+
+	raw := rawWallet{
+		URL:    wallet.URL().String(),
+		Status: status,
+	}
+	if failure != nil {
+		raw.Failure = failure.Error()
+	}
+	ks, _ := fetchKeystore(s.am)
+	for _, account := range wallet.Accounts() {
+		acc := rawAccount{Account: account}
+		if ks != nil && account.URL.Scheme == keystore.KeyStoreScheme {
+			acc.Meta, _ = ks.Metadata(account)
 		}
-		wallets = append(wallets, raw)
+		raw.Accounts = append(raw.Accounts, acc)
 	}
-	return wallets
+	return raw
+}
+
+// walletEvent is the notification of a wallet arriving, being opened or dropped.
+type walletEvent struct {
+	Kind   string    `json:"kind"` // "arrived", "opened" or "dropped"
+	Wallet rawWallet `json:"wallet"`
+}
+
+// walletEventKinds names the wallet event types.
+var walletEventKinds = map[accounts.WalletEventType]string{
+	accounts.WalletArrived: "arrived",
+	accounts.WalletOpened:  "opened",
+	accounts.WalletDropped: "dropped",
+}
+
+// SubscribeWalletEvents creates a subscription notified whenever a wallet
+// arrives, such as a hardware wallet being plugged in or a key file added to the
+// keystore, is opened or is dropped. It is called as
+// personal_subscribeWalletEvents.
+func (s *PrivateAccountAPI) SubscribeWalletEvents(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	// Subscribe right away, so no event is missed once the subscription is returned
+	events := make(chan accounts.WalletEvent, 16)
+	sub := s.am.Subscribe(events)
+
+	go func() {
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, walletEvent{Kind: walletEventKinds[ev.Kind], Wallet: s.rawWallet(ev.Wallet)})
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
 }
 
 // OpenWallet initiates a hardware wallet opening procedure, establishing a USB
@@ -308,6 +379,96 @@ func (s *PrivateAccountAPI) ImportRawKey(privkey string, password string) (commo
 	return acc.Address, err
 }
 
+// ImportRawKeys stores the given hex encoded ECDSA keys into the key directory,
+// encrypting them with the passphrase. Either all keys are imported or none.
+func (s *PrivateAccountAPI) ImportRawKeys(privkeys []string, password string) ([]common.Address, error) {
+	keys := make([]*ecdsa.PrivateKey, len(privkeys))
+	for i, privkey := range privkeys {
+		key, err := crypto.HexToECDSA(privkey)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %v", i, err)
+		}
+		keys[i] = key
+	}
+	ks, err := fetchKeystore(s.am)
+	if err != nil {
+		return nil, err
+	}
+	accs, err := ks.ImportECDSABatch(keys, password)
+	if err != nil {
+		return nil, err
+	}
+	return accountAddresses(accs), nil
+}
+
+// ExportBundle exports the given accounts of the local keystore into a single
+// bundle encrypted with bundlePassword, to be imported by ImportBundle on another
+// node. The accounts are decrypted with the password of the same index, or with
+// the single password given for all of them.
+func (s *PrivateAccountAPI) ExportBundle(addrs []common.Address, passwords []string, bundlePassword string) (json.RawMessage, error) {
+	// Like unlocking, exporting keys is only allowed over insecure transports
+	// if explicitly requested.
+	if s.b.ExtRPCEnabled() && !s.b.AccountManager().Config().InsecureUnlockAllowed {
+		return nil, errors.New("account export with HTTP access is forbidden")
+	}
+	ks, err := fetchKeystore(s.am)
+	if err != nil {
+		return nil, err
+	}
+	accs := make([]accounts.Account, len(addrs))
+	for i, addr := range addrs {
+		accs[i] = accounts.Account{Address: addr}
+	}
+	return ks.ExportBundle(accs, passwords, bundlePassword)
+}
+
+// ImportBundle stores the accounts of a bundle created by ExportBundle into the
+// key directory, encrypting them with password. Either all accounts are
+// imported or none.
+func (s *PrivateAccountAPI) ImportBundle(bundle json.RawMessage, bundlePassword string, password string) ([]common.Address, error) {
+	ks, err := fetchKeystore(s.am)
+	if err != nil {
+		return nil, err
+	}
+	accs, err := ks.ImportBundle(bundle, bundlePassword, password)
+	if err != nil {
+		return nil, err
+	}
+	return accountAddresses(accs), nil
+}
+
+// accountAddresses returns the addresses of a list of accounts.
+func accountAddresses(accs []accounts.Account) []common.Address {
+	addrs := make([]common.Address, len(accs))
+	for i, acc := range accs {
+		addrs[i] = acc.Address
+	}
+	return addrs
+}
+
+// SetAccountLabel sets the label, and optionally the tags, of an account of the
+// local keystore. The metadata is stored unencrypted in the key file, so the
+// account doesn't need to be unlocked. An empty label removes it.
+func (s *PrivateAccountAPI) SetAccountLabel(addr common.Address, label string, tags *[]string) error {
+	ks, err := fetchKeystore(s.am)
+	if err != nil {
+		return err
+	}
+	account := accounts.Account{Address: addr}
+	meta, err := ks.Metadata(account)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		meta = new(keystore.KeyMeta)
+	}
+	meta.Label = label
+	if tags != nil {
+		meta.Tags = *tags
+	}
+	return ks.SetMetadata(account, meta)
+}
+
 // UnlockAccount will unlock the account associated with the given address with
 // the given password for duration seconds. If duration is nil it will use a
 // default of 300 seconds. It returns an indication if the account was unlocked.
@@ -377,12 +538,28 @@ func (s *PrivateAccountAPI) SendTransaction(ctx context.Context, args SendTxArgs
 		s.nonceLock.LockAddr(args.From)
 		defer s.nonceLock.UnlockAddr(args.From)
 	}
+	release, err := args.reserveNonce(ctx, s.b)
+	if err != nil {
+		return common.Hash{}, err
+	}
 	signed, err := s.signTransaction(ctx, &args, passwd)
 	if err != nil {
+		release()
 		log.Warn("Failed transaction send attempt", "from", args.From, "to", args.To, "value", args.Value.ToInt(), "err", err)
 		return common.Hash{}, err
 	}
-	return SubmitTransaction(ctx, s.b, signed)
+	// Account the spend of the transaction, reverting it if it can't be sent
+	revert, err := s.guard.reserve(args.From, signed)
+	if err != nil {
+		release()
+		return common.Hash{}, err
+	}
+	hash, err := SubmitTransaction(ctx, s.b, signed)
+	if err != nil {
+		release()
+		revert()
+	}
+	return hash, err
 }
 
 // SignTransaction will create a transaction from the given arguments and
@@ -410,6 +587,11 @@ func (s *PrivateAccountAPI) SignTransaction(ctx context.Context, args SendTxArgs
 		log.Warn("Failed transaction sign attempt", "from", args.From, "to", args.To, "value", args.Value.ToInt(), "err", err)
 		return nil, err
 	}
+	// The transaction isn't sent, check it against the spend caps without
+	// accounting it
+	if err := s.guard.check(args.From, signed); err != nil {
+		return nil, err
+	}
 	data, err := signed.MarshalBinary()
 	if err != nil {
 		return nil, err
@@ -529,17 +711,145 @@ func NewPublicBlockChainAPI(b Backend) *PublicBlockChainAPI {
 	return &PublicBlockChainAPI{b}
 }
 
-// ChainId returns the chainID value for transaction replay protection.
+// ChainId returns the chainID value for transaction replay protection. The
+// configured value is returned even before the EIP-155 fork, so clients can tell
+// the network apart while it syncs. It is unrelated to the network id returned
+// by net_version, which only identifies the peer-to-peer network.
 func (s *PublicBlockChainAPI) ChainId() *hexutil.Big {
 	return (*hexutil.Big)(s.b.ChainConfig().ChainID)
 }
 
+// ForkInfo describes a fork of the chain configuration.
+type ForkInfo struct {
+	Name   string          `json:"name"`
+	Block  *hexutil.Big    `json:"block"`          // Activation block, nil if not scheduled
+	Time   *hexutil.Uint64 `json:"time,omitempty"` // Activation timestamp, for forks scheduled by time
+	Active bool            `json:"active"`         // Whether the fork is active at the chain head
+}
+
+// ChainConfigResult is the chain configuration returned by ong_chainConfig.
+type ChainConfigResult struct {
+	ChainID *hexutil.Big        `json:"chainId"`
+	Head    hexutil.Uint64      `json:"head"`   // Block the forks are evaluated at
+	Engine  string              `json:"engine"` // Consensus engine of the chain
+	Forks   []ForkInfo          `json:"forks"`
+	Config  *params.ChainConfig `json:"config"` // Full configuration, including the private chain rules
+}
+
+// ChainConfig returns the active chain configuration, with the blocks of the
+// forks and whether they are active at the chain head, so tools can introspect
+// the rules of custom networks instead of hardcoding them.
+func (s *PublicBlockChainAPI) ChainConfig() *ChainConfigResult {
+	var (
+		config = s.b.ChainConfig()
+		head   = s.b.CurrentHeader()
+	)
+	result := &ChainConfigResult{
+		ChainID: (*hexutil.Big)(config.ChainID),
+		Head:    hexutil.Uint64(head.Number.Uint64()),
+		Engine:  "unknown",
+		Config:  config,
+	}
+	switch {
+	case config.Ongash != nil:
+		result.Engine = config.Ongash.String()
+	case config.Clique != nil:
+		result.Engine = config.Clique.String()
+	}
+	for _, fork := range []struct {
+		name  string
+		block *big.Int
+	}{
+		{"homestead", config.HomesteadBlock},
+		{"daoFork", config.DAOForkBlock},
+		{"eip150", config.EIP150Block},
+		{"eip155", config.EIP155Block},
+		{"eip158", config.EIP158Block},
+		{"byzantium", config.ByzantiumBlock},
+		{"constantinople", config.ConstantinopleBlock},
+		{"petersburg", config.PetersburgBlock},
+		{"istanbul", config.IstanbulBlock},
+		{"muirGlacier", config.MuirGlacierBlock},
+		{"berlin", config.BerlinBlock},
+		{"yoloV3", config.YoloV3Block},
+		{"ewasm", config.EWASMBlock},
+	} {
+		result.Forks = append(result.Forks, ForkInfo{
+			Name:   fork.name,
+			Block:  (*hexutil.Big)(fork.block),
+			Active: fork.block != nil && fork.block.Cmp(head.Number) <= 0,
+		})
+	}
+	if config.MinGasPrice != nil {
+		result.Forks = append(result.Forks, ForkInfo{
+			Name:   "minGasPrice",
+			Block:  (*hexutil.Big)(config.MinGasPrice.Block),
+			Time:   (*hexutil.Uint64)(config.MinGasPrice.Time),
+			Active: config.IsMinGasPrice(head.Number, head.Time),
+		})
+	}
+	if policy := config.RewardPolicy; policy != nil {
+		result.Forks = append(result.Forks, ForkInfo{
+			Name:   "rewardPolicy",
+			Block:  (*hexutil.Big)(policy.Block),
+			Time:   (*hexutil.Uint64)(policy.Time),
+			Active: config.IsRewardPolicy(head.Number, head.Time),
+		})
+	}
+	for i := range config.BlockRules {
+		rule := &config.BlockRules[i]
+		result.Forks = append(result.Forks, ForkInfo{
+			Name:   "blockRule:" + rule.Rule,
+			Block:  (*hexutil.Big)(rule.Block),
+			Time:   (*hexutil.Uint64)(rule.Time),
+			Active: rule.IsActive(head.Number, head.Time),
+		})
+	}
+	return result
+}
+
 // BlockNumber returns the block number of the chain head.
 func (s *PublicBlockChainAPI) BlockNumber() hexutil.Uint64 {
 	header, _ := s.b.HeaderByNumber(context.Background(), rpc.LatestBlockNumber) // latest header should always be available
 	return hexutil.Uint64(header.Number.Uint64())
 }
 
+// feeHistoryResult is the fee history of a range of blocks.
+type feeHistoryResult struct {
+	OldestBlock  *hexutil.Big     `json:"oldestBlock"`
+	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
+	GasUsedRatio []float64        `json:"gasUsedRatio"`
+}
+
+// FeeHistory returns the fee statistics of up to 1024 blocks ending with lastBlock:
+// the ratio of the gas limit used by every block and the gas prices paid at the
+// given percentiles of its gas used. The percentiles must be increasing values
+// between 0 and 100.
+func (s *PublicBlockChainAPI) FeeHistory(ctx context.Context, blockCount hexutil.Uint64, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*feeHistoryResult, error) {
+	// Like the fixed maximum of the oracle, the range cap truncates the history
+	if limit := s.b.RPCBlockRangeCap(); limit > 0 && uint64(blockCount) > limit {
+		blockCount = hexutil.Uint64(limit)
+	}
+	oldest, reward, gasUsed, err := s.b.FeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	results := &feeHistoryResult{
+		OldestBlock:  (*hexutil.Big)(oldest),
+		GasUsedRatio: gasUsed,
+	}
+	if reward != nil {
+		results.Reward = make([][]*hexutil.Big, len(reward))
+		for i, prices := range reward {
+			results.Reward[i] = make([]*hexutil.Big, len(prices))
+			for j, price := range prices {
+				results.Reward[i][j] = (*hexutil.Big)(price)
+			}
+		}
+	}
+	return results, nil
+}
+
 // GetBalance returns the amount of wei for the given address in the state of the
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
@@ -798,13 +1108,13 @@ func (args *CallArgs) ToMessage(globalGasCap uint64) types.Message {
 	return msg
 }
 
-// account indicates the overriding fields of account during the execution of
-// a message call.
+// OverrideAccount indicates the overriding fields of account during the execution
+// of a message call.
 // Note, state and stateDiff can't be specified at the same time. If state is
 // set, message execution will only use the data in the given state. Otherwise
 // if statDiff is set, all diff will be applied first and then execute the call
 // message.
-type account struct {
+type OverrideAccount struct {
 	Nonce     *hexutil.Uint64              `json:"nonce"`
 	Code      *hexutil.Bytes               `json:"code"`
 	Balance   **hexutil.Big                `json:"balance"`
@@ -812,15 +1122,15 @@ type account struct {
 	StateDiff *map[common.Hash]common.Hash `json:"stateDiff"`
 }
 
-func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides map[common.Address]account, vmCfg vm.Config, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
-	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+// StateOverride is the collection of overridden accounts.
+type StateOverride map[common.Address]OverrideAccount
 
-	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
-	if state == nil || err != nil {
-		return nil, err
+// Apply overrides the fields of specified accounts into the given state.
+func (diff *StateOverride) Apply(state *state.StateDB) error {
+	if diff == nil {
+		return nil
 	}
-	// Override the fields of specified contracts before execution.
-	for addr, account := range overrides {
+	for addr, account := range *diff {
 		// Override account nonce.
 		if account.Nonce != nil {
 			state.SetNonce(addr, uint64(*account.Nonce))
@@ -834,7 +1144,7 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			state.SetBalance(addr, (*big.Int)(*account.Balance))
 		}
 		if account.State != nil && account.StateDiff != nil {
-			return nil, fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
+			return fmt.Errorf("account %s has both 'state' and 'stateDiff'", addr.Hex())
 		}
 		// Replace entire state if caller requires.
 		if account.State != nil {
@@ -847,6 +1157,26 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 			}
 		}
 	}
+	return nil
+}
+
+func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, vmCfg vm.Config, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
+
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
+	return applyMessage(ctx, b, args, state, header, nil, timeout, globalGasCap)
+}
+
+// applyMessage executes the call message on top of the given state, aborting
+// after the given timeout. If vmCfg is nil, the backend's default configuration
+// is used.
+func applyMessage(ctx context.Context, b Backend, args CallArgs, state *state.StateDB, header *types.Header, vmCfg *vm.Config, timeout time.Duration, globalGasCap uint64) (*core.ExecutionResult, error) {
 	// Setup context so it may be cancelled the call has completed
 	// or, in case of unmetered gas, setup a context with a timeout.
 	var cancel context.CancelFunc
@@ -861,16 +1191,13 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 
 	// Get a new instance of the EVM.
 	msg := args.ToMessage(globalGasCap)
-	evm, vmError, err := b.GetEVM(ctx, msg, state, header)
+	evm, vmError, err := b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
 		return nil, err
 	}
-	// Wait for the context to be done and cancel the evm. Even if the
-	// EVM has finished, cancelling may be done (repeatedly)
-	go func() {
-		<-ctx.Done()
-		evm.Cancel()
-	}()
+	// Cancel the evm once the context is done, be it due to the timeout or
+	// to the request being cancelled
+	defer evm.CancelWith(ctx)()
 
 	// Execute the message.
 	gp := new(core.GasPool).AddGas(math.MaxUint64)
@@ -889,7 +1216,10 @@ func DoCall(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.Blo
 	return result, nil
 }
 
-func newRevertError(result *core.ExecutionResult) *revertError {
+// NewRevertError creates the error of a reverted execution, answered with the
+// JSON-RPC error code 3 and the hex encoded revert data in the data field, the
+// message including the decoded revert reason if any.
+func NewRevertError(result *core.ExecutionResult) error {
 	reason, errUnpack := abi.UnpackRevert(result.Revert())
 	err := errors.New("execution reverted")
 	if errUnpack == nil {
@@ -925,23 +1255,27 @@ func (e *revertError) ErrorData() interface{} {
 //
 // Note, this function doesn't make and changes in the state/blockchain and is
 // useful to execute and retrieve values.
-func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *map[common.Address]account) (hexutil.Bytes, error) {
-	var accounts map[common.Address]account
-	if overrides != nil {
-		accounts = *overrides
+func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Bytes, error) {
+	timeout, release, err := s.b.RPCExecutionBudget().Acquire(ctx, s.b.RPCEVMTimeout())
+	if err != nil {
+		return nil, err
 	}
-	result, err := DoCall(ctx, s.b, args, blockNrOrHash, accounts, vm.Config{}, 5*time.Second, s.b.RPCGasCap())
+	defer release()
+
+	result, err := DoCall(ctx, s.b, args, blockNrOrHash, overrides, vm.Config{}, timeout, s.b.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
 	// If the result contains a revert reason, try to unpack and return it.
 	if len(result.Revert()) > 0 {
-		return nil, newRevertError(result)
+		return nil, NewRevertError(result)
 	}
 	return result.Return(), result.Err
 }
 
-func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, gasCap uint64) (hexutil.Uint64, error) {
+// DoEstimateGas binary searches the lowest gas limit the given call can be
+// executed with, on top of the given block's state with the overrides applied.
+func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride, gasCap uint64) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
 		lo  uint64 = params.TxGas - 1
@@ -972,6 +1306,9 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 		if err != nil {
 			return 0, err
 		}
+		if err := overrides.Apply(state); err != nil {
+			return 0, err
+		}
 		balance := state.GetBalance(*args.From) // from can't be nil
 		available := new(big.Int).Set(balance)
 		if args.Value != nil {
@@ -1004,7 +1341,7 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 	executable := func(gas uint64) (bool, *core.ExecutionResult, error) {
 		args.Gas = (*hexutil.Uint64)(&gas)
 
-		result, err := DoCall(ctx, b, args, blockNrOrHash, nil, vm.Config{}, 0, gasCap)
+		result, err := DoCall(ctx, b, args, blockNrOrHash, overrides, vm.Config{}, 0, gasCap)
 		if err != nil {
 			if errors.Is(err, core.ErrIntrinsicGas) {
 				return true, nil, nil // Special case, raise gas limit
@@ -1039,7 +1376,7 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 		if failed {
 			if result != nil && result.Err != vm.ErrOutOfGas {
 				if len(result.Revert()) > 0 {
-					return 0, newRevertError(result)
+					return 0, NewRevertError(result)
 				}
 				return 0, result.Err
 			}
@@ -1051,13 +1388,140 @@ func DoEstimateGas(ctx context.Context, b Backend, args CallArgs, blockNrOrHash
 }
 
 // EstimateGas returns an estimate of the amount of gas needed to execute the
-// given transaction against the current pending block.
-func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash) (hexutil.Uint64, error) {
+// given transaction against the current pending block, or the given one if
+// specified. Like for Call, a set of account overrides can be supplied.
+func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Uint64, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	timeout, release, err := s.b.RPCExecutionBudget().Acquire(ctx, s.b.RPCEVMTimeout())
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	// The timeout covers all the executions of the estimation
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	gas, err := DoEstimateGas(ctx, s.b, args, bNrOrHash, overrides, s.b.RPCGasCap())
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return 0, fmt.Errorf("gas estimation aborted (timeout = %v)", timeout)
+	}
+	return gas, err
+}
+
+// accessListResult returns an optional accesslist
+// Its the result of the `ong_createAccessList` RPC call.
+// It contains an error if the transaction itself failed.
+type accessListResult struct {
+	Accesslist *types.AccessList `json:"accessList"`
+	Error      string            `json:"error,omitempty"`
+	GasUsed    hexutil.Uint64    `json:"gasUsed"`
+}
+
+// CreateAccessList creates an EIP-2930 type AccessList for the given transaction,
+// executed on top of the given block (pending by default), along with the gas it
+// uses with the access list attached.
+func (s *PublicBlockChainAPI) CreateAccessList(ctx context.Context, args SendTxArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*accessListResult, error) {
 	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
 	if blockNrOrHash != nil {
 		bNrOrHash = *blockNrOrHash
 	}
-	return DoEstimateGas(ctx, s.b, args, bNrOrHash, s.b.RPCGasCap())
+	timeout, release, err := s.b.RPCExecutionBudget().Acquire(ctx, s.b.RPCEVMTimeout())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	acl, gasUsed, vmerr, err := AccessList(ctx, s.b, bNrOrHash, args, timeout)
+	if err != nil {
+		return nil, err
+	}
+	result := &accessListResult{Accesslist: &acl, GasUsed: hexutil.Uint64(gasUsed)}
+	if vmerr != nil {
+		result.Error = vmerr.Error()
+	}
+	return result, nil
+}
+
+// AccessList creates an access list for the given transaction by executing it
+// with an access list tracer, repeatedly extending the list with the accounts and
+// storage slots touched until it no longer changes.
+// If the accesslist creation fails an error is returned.
+// If the transaction itself fails, an vmErr is returned.
+func AccessList(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash, args SendTxArgs, timeout time.Duration) (acl types.AccessList, gasUsed uint64, vmErr error, err error) {
+	// Retrieve the execution context
+	db, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if db == nil || err != nil {
+		return nil, 0, nil, err
+	}
+	// If the gas amount is not set, extract this as it will depend on access
+	// lists and we'll need to reestimate every time
+	nogas := args.Gas == nil
+
+	// Ensure any missing fields are filled, extract the recipient and input data
+	if err := args.setDefaults(ctx, b); err != nil {
+		return nil, 0, nil, err
+	}
+	var to common.Address
+	if args.To != nil {
+		to = *args.To
+	} else {
+		to = crypto.CreateAddress(args.From, uint64(*args.Nonce))
+	}
+	var input hexutil.Bytes
+	if args.Input != nil {
+		input = *args.Input
+	} else if args.Data != nil {
+		input = *args.Data
+	}
+	// Retrieve the precompiles since they don't need to be added to the access list
+	precompiles := vm.ActivePrecompiles(b.ChainConfig().Rules(header.Number))
+
+	// Create an initial tracer
+	prevTracer := vm.NewAccessListTracer(nil, args.From, to, precompiles)
+	if args.AccessList != nil {
+		prevTracer = vm.NewAccessListTracer(*args.AccessList, args.From, to, precompiles)
+	}
+	for {
+		// Retrieve the current access list to expand
+		accessList := prevTracer.AccessList()
+		log.Trace("Creating access list", "input", accessList)
+
+		// If no gas amount was specified, each unique access list needs it's own
+		// gas calculation. This is quite expensive, but we need to be accurate
+		// and it's convered by the sender only anyway.
+		if nogas {
+			args.Gas = nil
+			args.AccessList = &accessList
+			if err := args.setDefaults(ctx, b); err != nil {
+				return nil, 0, nil, err // shouldn't happen, just in case
+			}
+		}
+		callArgs := CallArgs{
+			From:       &args.From,
+			To:         args.To,
+			Gas:        args.Gas,
+			GasPrice:   args.GasPrice,
+			Value:      args.Value,
+			Data:       &input,
+			AccessList: &accessList,
+		}
+		// Apply the transaction with the access list tracer on a copy of the state
+		tracer := vm.NewAccessListTracer(accessList, args.From, to, precompiles)
+		res, err := applyMessage(ctx, b, callArgs, db.Copy(), header, &vm.Config{Tracer: tracer, Debug: true}, timeout, b.RPCGasCap())
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("failed to apply transaction: %v err: %v", args.toTransaction().Hash(), err)
+		}
+		if tracer.Equal(prevTracer) {
+			return accessList, res.UsedGas, res.Err, nil
+		}
+		prevTracer = tracer
+	}
 }
 
 // ExecutionResult groups all structured logs emitted by the EVM
@@ -1265,8 +1729,8 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 	return result
 }
 
-// newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
+// NewRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
+func NewRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
 	return newRPCTransaction(tx, common.Hash{}, 0, 0)
 }
 
@@ -1303,15 +1767,16 @@ func newRPCTransactionFromBlockHash(b *types.Block, hash common.Hash) *RPCTransa
 type PublicTransactionPoolAPI struct {
 	b         Backend
 	nonceLock *AddrLocker
+	guard     *SpendGuard
 	signer    types.Signer
 }
 
 // NewPublicTransactionPoolAPI creates a new RPC service with Methods specific for the transaction pool.
-func NewPublicTransactionPoolAPI(b Backend, nonceLock *AddrLocker) *PublicTransactionPoolAPI {
+func NewPublicTransactionPoolAPI(b Backend, nonceLock *AddrLocker, guard *SpendGuard) *PublicTransactionPoolAPI {
 	// The signer used by the API should always be the 'latest' known one because we expect
 	// signers to be backwards-compatible with old transactions.
 	signer := types.LatestSigner(b.ChainConfig())
-	return &PublicTransactionPoolAPI{b, nonceLock, signer}
+	return &PublicTransactionPoolAPI{b, nonceLock, guard, signer}
 }
 
 // GetBlockTransactionCountByNumber returns the number of transactions in the block with the given block number.
@@ -1395,7 +1860,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, has
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx), nil
+		return NewRPCPendingTransaction(tx), nil
 	}
 
 	// Transaction unknown, return as such
@@ -1437,13 +1902,73 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	// Derive the sender.
 	bigblock := new(big.Int).SetUint64(blockNumber)
 	signer := types.MakeSigner(s.b.ChainConfig(), bigblock)
+	return s.marshalReceipt(receipt, blockHash, blockNumber, signer, tx, int(index)), nil
+}
+
+// GetDecodedTransactionReceipt returns the transaction receipt for the given
+// transaction hash, along with the call and the logs decoded with the contract
+// ABIs registered via debug_registerABI.
+func (s *PublicTransactionPoolAPI) GetDecodedTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	fields, err := s.GetTransactionReceipt(ctx, hash)
+	if fields == nil || err != nil {
+		return fields, err
+	}
+	tx, _, _, _, err := s.b.GetTransaction(ctx, hash)
+	if tx == nil || err != nil {
+		return nil, err
+	}
+	var (
+		abis    = s.b.ABIRegistry()
+		logs, _ = fields["logs"].([]*types.Log)
+		decoded = abis.DecodeLogs(ctx, logs)
+	)
+	if decoded == nil {
+		decoded = []*DecodedLog{}
+	}
+	fields["decodedCall"] = abis.DecodeCall(ctx, tx.To(), tx.Data())
+	fields["decodedLogs"] = decoded
+	return fields, nil
+}
+
+// GetBlockReceipts returns the receipts of all the transactions in the given
+// block, in the same format as GetTransactionReceipt.
+func (s *PublicTransactionPoolAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		// When the block doesn't exist, the RPC method should return JSON null
+		// as per specification.
+		return nil, nil
+	}
+	receipts, err := s.b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d vs %d", len(txs), len(receipts))
+	}
+	// Derive the sender.
+	signer := types.MakeSigner(s.b.ChainConfig(), block.Number())
+
+	result := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		result[i] = s.marshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], i)
+	}
+	return result, nil
+}
+
+// marshalReceipt marshals a transaction receipt into a JSON object.
+func (s *PublicTransactionPoolAPI) marshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, signer types.Signer, tx *types.Transaction, txIndex int) map[string]interface{} {
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
-		"transactionIndex":  hexutil.Uint64(index),
+		"transactionHash":   tx.Hash(),
+		"transactionIndex":  hexutil.Uint64(txIndex),
 		"from":              from,
 		"to":                tx.To(),
 		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
@@ -1467,7 +1992,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1518,6 +2043,10 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 		if err != nil {
 			return err
 		}
+		// Skip the nonces reserved by external signers
+		if nonce, err = b.NonceReservations().next(args.From, nonce, 0); err != nil {
+			return err
+		}
 		args.Nonce = (*hexutil.Uint64)(&nonce)
 	}
 	if args.Data != nil && args.Input != nil && !bytes.Equal(*args.Data, *args.Input) {
@@ -1553,7 +2082,7 @@ func (args *SendTxArgs) setDefaults(ctx context.Context, b Backend) error {
 			AccessList: args.AccessList,
 		}
 		pendingBlockNr := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
-		estimated, err := DoEstimateGas(ctx, b, callArgs, pendingBlockNr, b.RPCGasCap())
+		estimated, err := DoEstimateGas(ctx, b, callArgs, pendingBlockNr, nil, b.RPCGasCap())
 		if err != nil {
 			return err
 		}
@@ -1635,6 +2164,79 @@ func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 // SendTransaction creates a transaction for the given argument, sign it and submit it to the
 // transaction pool.
 func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args SendTxArgs) (common.Hash, error) {
+	if args.Nonce == nil {
+		// Hold the addresse's mutex around signing to prevent concurrent assignment of
+		// the same nonce to multiple accounts.
+		s.nonceLock.LockAddr(args.From)
+		defer s.nonceLock.UnlockAddr(args.From)
+	}
+	return s.sendTransaction(ctx, args)
+}
+
+// BatchTxResult is the outcome of submitting a single transaction of a batch.
+type BatchTxResult struct {
+	Hash  *common.Hash `json:"hash,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// SendTransactions creates, signs and submits a batch of transactions, returning
+// the hash or the error of each, in order. Transactions without a nonce get the
+// next free nonces of their sender, assigned while holding the nonce lock of all
+// the senders for the whole batch, so concurrent submissions can't interleave.
+//
+// If a transaction with an assigned nonce fails, the subsequent ones of the same
+// sender are not submitted, as they would leave a nonce gap.
+func (s *PublicTransactionPoolAPI) SendTransactions(ctx context.Context, batch []SendTxArgs) ([]*BatchTxResult, error) {
+	if len(batch) == 0 {
+		return nil, errors.New("empty transaction batch")
+	}
+	// Lock all the senders needing nonces, in a deterministic order to avoid
+	// deadlocking with other batches
+	var senders []common.Address
+	for _, args := range batch {
+		if args.Nonce == nil {
+			senders = append(senders, args.From)
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return bytes.Compare(senders[i][:], senders[j][:]) < 0
+	})
+	for i, sender := range senders {
+		if i > 0 && sender == senders[i-1] {
+			continue
+		}
+		s.nonceLock.LockAddr(sender)
+		defer s.nonceLock.UnlockAddr(sender)
+	}
+	// Submit the transactions one by one. Each one assigned a nonce enters the
+	// pool before the next one of the same sender is assigned the following nonce.
+	var (
+		failed  = make(map[common.Address]bool)
+		results = make([]*BatchTxResult, len(batch))
+	)
+	for i, args := range batch {
+		assign := args.Nonce == nil
+		if assign && failed[args.From] {
+			results[i] = &BatchTxResult{Error: "skipped due to a failed transaction of the same sender"}
+			continue
+		}
+		hash, err := s.sendTransaction(ctx, args)
+		if err != nil {
+			if assign {
+				failed[args.From] = true
+			}
+			results[i] = &BatchTxResult{Error: err.Error()}
+			continue
+		}
+		results[i] = &BatchTxResult{Hash: &hash}
+	}
+	return results, nil
+}
+
+// sendTransaction creates a transaction for the given argument, signs it and
+// submits it to the transaction pool.
+// NOTE: the caller needs to ensure that the nonceLock is held, if applicable.
+func (s *PublicTransactionPoolAPI) sendTransaction(ctx context.Context, args SendTxArgs) (common.Hash, error) {
 	// Look up the wallet containing the requested signer
 	account := accounts.Account{Address: args.From}
 
@@ -1642,26 +2244,36 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	if err != nil {
 		return common.Hash{}, err
 	}
-
-	if args.Nonce == nil {
-		// Hold the addresse's mutex around signing to prevent concurrent assignment of
-		// the same nonce to multiple accounts.
-		s.nonceLock.LockAddr(args.From)
-		defer s.nonceLock.UnlockAddr(args.From)
+	release, err := args.reserveNonce(ctx, s.b)
+	if err != nil {
+		return common.Hash{}, err
 	}
-
 	// Set some sanity defaults and terminate on failure
 	if err := args.setDefaults(ctx, s.b); err != nil {
+		release()
 		return common.Hash{}, err
 	}
-	// Assemble the transaction and sign with the wallet
+	// Assemble the transaction and ensure it's within the spend caps
 	tx := args.toTransaction()
 
+	revert, err := s.guard.reserve(args.From, tx)
+	if err != nil {
+		release()
+		return common.Hash{}, err
+	}
+	// Sign the transaction with the wallet and submit it
 	signed, err := wallet.SignTx(account, tx, s.b.ChainConfig().ChainID)
 	if err != nil {
+		release()
+		revert()
 		return common.Hash{}, err
 	}
-	return SubmitTransaction(ctx, s.b, signed)
+	hash, err := SubmitTransaction(ctx, s.b, signed)
+	if err != nil {
+		release()
+		revert()
+	}
+	return hash, err
 }
 
 // FillTransaction fills the defaults (nonce, gas, gasPrice) on a given unsigned transaction,
@@ -1741,7 +2353,13 @@ func (s *PublicTransactionPoolAPI) SignTransaction(ctx context.Context, args Sen
 	if err := checkTxFee(args.GasPrice.ToInt(), uint64(*args.Gas), s.b.RPCTxFeeCap()); err != nil {
 		return nil, err
 	}
-	tx, err := s.sign(args.From, args.toTransaction())
+	// The transaction isn't sent, check it against the spend caps without
+	// accounting it
+	unsigned := args.toTransaction()
+	if err := s.guard.check(args.From, unsigned); err != nil {
+		return nil, err
+	}
+	tx, err := s.sign(args.From, unsigned)
 	if err != nil {
 		return nil, err
 	}
@@ -1769,7 +2387,7 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 	for _, tx := range pending {
 		from, _ := types.Sender(s.signer, tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
+			transactions = append(transactions, NewRPCPendingTransaction(tx))
 		}
 	}
 	return transactions, nil
@@ -1912,7 +2530,8 @@ func (api *PublicDebugAPI) SeedHash(ctx context.Context, number uint64) (string,
 // PrivateDebugAPI is the collection of Orange APIs exposed over the private
 // debugging endpoint.
 type PrivateDebugAPI struct {
-	b Backend
+	b          Backend
+	compacting int32 // Flag whether a database compaction is running (atomic)
 }
 
 // NewPrivateDebugAPI creates a new API definition for the private debug Methods
@@ -1921,7 +2540,28 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 	return &PrivateDebugAPI{b: b}
 }
 
-// ChaindbProperty returns leveldb properties of the key-value database.
+// RegisterABI registers the JSON ABI of a contract on the RPC connection, after
+// which calls to it and logs emitted by it are decoded by
+// ong_getDecodedTransactionReceipt, ong_simulateTransaction and the call frames
+// of the transaction traces requested on the same connection. The ABI is dropped
+// when the connection is closed, so it's unavailable over HTTP.
+func (api *PrivateDebugAPI) RegisterABI(ctx context.Context, address common.Address, definition string) error {
+	return api.b.ABIRegistry().Register(ctx, address, definition)
+}
+
+// UnregisterABI removes a contract ABI previously registered on the RPC
+// connection.
+func (api *PrivateDebugAPI) UnregisterABI(ctx context.Context, address common.Address) bool {
+	return api.b.ABIRegistry().Unregister(ctx, address)
+}
+
+// errCompactionRunning is returned if a database compaction is requested while
+// another one is still running.
+var errCompactionRunning = errors.New("database compaction already running")
+
+// ChaindbProperty returns leveldb properties of the key-value database, e.g.
+// "stats", "iostats", "compcount" (compaction counters), "writedelay" or
+// "num-files-at-level<N>". The stats are returned if no property is given.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
 	if property == "" {
 		property = "leveldb.stats"
@@ -1931,9 +2571,36 @@ func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
 	return api.b.ChainDb().Stat(property)
 }
 
-// ChaindbCompact flattens the entire key-value database into a single level,
-// removing all unused slots and merging all keys.
-func (api *PrivateDebugAPI) ChaindbCompact() error {
+// ChaindbCompact compacts the key range [start, limit) of the key-value database,
+// e.g. a single table prefix after a large deletion. If no range is given, the
+// entire database is flattened into a single level, removing all unused slots
+// and merging all keys. Only one compaction may run at a time.
+func (api *PrivateDebugAPI) ChaindbCompact(start, limit *hexutil.Bytes) error {
+	if !atomic.CompareAndSwapInt32(&api.compacting, 0, 1) {
+		return errCompactionRunning
+	}
+	defer atomic.StoreInt32(&api.compacting, 0)
+
+	if start != nil || limit != nil {
+		var from, to []byte
+		if start != nil {
+			from = *start
+		}
+		if limit != nil {
+			to = *limit
+		}
+		if to != nil && bytes.Compare(from, to) >= 0 {
+			return errors.New("empty compaction range")
+		}
+		begin := time.Now()
+		log.Info("Compacting chain database", "start", hexutil.Bytes(from), "limit", hexutil.Bytes(to))
+		if err := api.b.ChainDb().Compact(from, to); err != nil {
+			log.Error("Database compaction failed", "err", err)
+			return err
+		}
+		log.Info("Compacted chain database", "elapsed", common.PrettyDuration(time.Since(begin)))
+		return nil
+	}
 	for b := byte(0); b < 255; b++ {
 		log.Info("Compacting chain database", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", b, b+1))
 		if err := api.b.ChainDb().Compact([]byte{b}, []byte{b + 1}); err != nil {
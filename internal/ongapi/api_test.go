@@ -0,0 +1,281 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/accounts"
+	"github.com/ong2020/go-orange/accounts/keystore"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// Tests that the transactions of a batch are assigned sequential nonces, and
+// that a failed transaction with an assigned nonce stops the subsequent ones of
+// its sender.
+func TestSendTransactions(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	backend.am = newTestAccountManager(t)
+
+	var (
+		ctx = context.Background()
+		api = NewPublicTransactionPoolAPI(backend, new(AddrLocker), NewSpendGuard(nil, 0, 0))
+	)
+	if _, err := api.SendTransactions(ctx, nil); err == nil {
+		t.Fatalf("empty batch accepted")
+	}
+	results, err := api.SendTransactions(ctx, []SendTxArgs{sendArgs(), sendArgs(), sendArgs()})
+	if err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	for i, res := range results {
+		if res.Error != "" || res.Hash == nil {
+			t.Fatalf("transaction %d failed: %v", i, res.Error)
+		}
+		if tx := backend.pool[testAddr][uint64(i)]; tx == nil || tx.Hash() != *res.Hash {
+			t.Fatalf("transaction %d not sent with nonce %d", i, i)
+		}
+	}
+	// An explicit nonce failing doesn't stop the batch, an assigned one does
+	var (
+		taken     = hexutil.Uint64(0)
+		explicit  = sendArgs()
+		overpaid  = sendArgs()
+		following = sendArgs()
+	)
+	explicit.Nonce = &taken
+	overpaid.GasPrice = (*hexutil.Big)(new(big.Int).Mul(big.NewInt(params.Oranger), big.NewInt(params.Oranger)))
+
+	results, err = api.SendTransactions(ctx, []SendTxArgs{explicit, sendArgs(), overpaid, following})
+	if err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	if results[0].Error == "" {
+		t.Errorf("transaction with a taken nonce sent")
+	}
+	if results[1].Error != "" || results[1].Hash == nil || backend.pool[testAddr][3] == nil || backend.pool[testAddr][3].Hash() != *results[1].Hash {
+		t.Errorf("transaction after a failed explicit nonce not sent with nonce 3: %+v", results[1])
+	}
+	if results[2].Error == "" {
+		t.Errorf("transaction over the fee cap sent")
+	}
+	if results[3].Error == "" || results[3].Hash != nil {
+		t.Errorf("transaction after a failed assigned nonce sent: %+v", results[3])
+	}
+	if len(backend.pool[testAddr]) != 4 {
+		t.Errorf("pool size mismatch: have %d, want 4", len(backend.pool[testAddr]))
+	}
+}
+
+// compactionRecorder is a database recording the ranges compacted, optionally
+// blocking the compactions until released.
+type compactionRecorder struct {
+	ongdb.Database
+
+	lock    sync.Mutex
+	ranges  [][2][]byte
+	started chan struct{}
+	release chan struct{}
+}
+
+func (db *compactionRecorder) Compact(start []byte, limit []byte) error {
+	if db.started != nil {
+		db.started <- struct{}{}
+		<-db.release
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.ranges = append(db.ranges, [2][]byte{start, limit})
+	return nil
+}
+
+// Tests that debug_chaindbCompact compacts the requested range, or the whole
+// database in single byte prefix steps, one compaction at a time.
+func TestChaindbCompact(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	db := &compactionRecorder{Database: backend.db}
+	backend.db = db
+
+	var (
+		api   = NewPrivateDebugAPI(backend)
+		start = hexutil.Bytes{0x01}
+		limit = hexutil.Bytes{0x02}
+	)
+	tests := []struct {
+		start, limit *hexutil.Bytes
+		ranges       [][2][]byte
+	}{
+		{&start, &limit, [][2][]byte{{start, limit}}},
+		{&start, nil, [][2][]byte{{start, nil}}},
+		{nil, &limit, [][2][]byte{{nil, limit}}},
+	}
+	for i, tt := range tests {
+		db.ranges = nil
+		if err := api.ChaindbCompact(tt.start, tt.limit); err != nil {
+			t.Fatalf("test %d: compaction failed: %v", i, err)
+		}
+		if len(db.ranges) != len(tt.ranges) {
+			t.Fatalf("test %d: compacted ranges mismatch: have %x, want %x", i, db.ranges, tt.ranges)
+		}
+		for j := range tt.ranges {
+			if !bytes.Equal(db.ranges[j][0], tt.ranges[j][0]) || !bytes.Equal(db.ranges[j][1], tt.ranges[j][1]) {
+				t.Fatalf("test %d: compacted ranges mismatch: have %x, want %x", i, db.ranges, tt.ranges)
+			}
+		}
+	}
+	// Empty ranges are refused
+	db.ranges = nil
+	if err := api.ChaindbCompact(&limit, &start); err == nil || len(db.ranges) != 0 {
+		t.Fatalf("empty range compacted: err %v, ranges %x", err, db.ranges)
+	}
+	// Without range the whole database is compacted
+	if err := api.ChaindbCompact(nil, nil); err != nil {
+		t.Fatalf("full compaction failed: %v", err)
+	}
+	if len(db.ranges) != 255 || !bytes.Equal(db.ranges[0][0], []byte{0x00}) || !bytes.Equal(db.ranges[254][1], []byte{0xff}) {
+		t.Fatalf("full compaction ranges mismatch: %d ranges", len(db.ranges))
+	}
+	// Concurrent compactions are refused
+	db.started, db.release = make(chan struct{}), make(chan struct{})
+	errc := make(chan error)
+	go func() { errc <- api.ChaindbCompact(&start, &limit) }()
+	<-db.started
+
+	if err := api.ChaindbCompact(&start, &limit); err != errCompactionRunning {
+		t.Fatalf("concurrent compaction error mismatch: have %v, want %v", err, errCompactionRunning)
+	}
+	close(db.release)
+	if err := <-errc; err != nil {
+		t.Fatalf("blocked compaction failed: %v", err)
+	}
+}
+
+// Tests that personal_subscribeWalletEvents notifies the wallets arriving and
+// being dropped.
+func TestSubscribeWalletEvents(t *testing.T) {
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	backend := &testBackend{am: accounts.NewManager(&accounts.Config{}, ks)}
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("personal", NewPrivateAccountAPI(backend, nil, nil)); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(rpc.NewCodec(serverConn), 0)
+
+	// Subscribe with a direct call of the subscription method
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(clientConn, `{"jsonrpc":"2.0","id":1,"method":"personal_subscribeWalletEvents","params":[]}`)
+
+	var (
+		dec = json.NewDecoder(clientConn)
+		msg struct {
+			Result json.RawMessage
+			Error  *struct{ Message string }
+			Params struct {
+				Subscription string
+				Result       walletEvent
+			}
+		}
+	)
+	if err := dec.Decode(&msg); err != nil {
+		t.Fatalf("failed to read subscription response: %v", err)
+	}
+	if msg.Error != nil {
+		t.Fatalf("failed to subscribe: %v", msg.Error.Message)
+	}
+	var id string
+	if err := json.Unmarshal(msg.Result, &id); err != nil {
+		t.Fatalf("invalid subscription id %s: %v", msg.Result, err)
+	}
+	// Creating and deleting an account makes its wallet arrive and drop
+	account, err := ks.NewAccount("")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	next := func(kind string) {
+		t.Helper()
+		if err := dec.Decode(&msg); err != nil {
+			t.Fatalf("failed to read %s notification: %v", kind, err)
+		}
+		if msg.Params.Subscription != id || msg.Params.Result.Kind != kind || msg.Params.Result.Wallet.URL != account.URL.String() {
+			t.Fatalf("%s notification mismatch: %+v", kind, msg.Params)
+		}
+	}
+	next("arrived")
+	if err := ks.Delete(account, ""); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+	next("dropped")
+}
+
+// Tests that the state overrides of ong_estimateGas apply to the estimation.
+func TestEstimateGasOverrides(t *testing.T) {
+	var (
+		backend  = newTestBackend(t, nil, 1, nil)
+		api      = NewPublicBlockChainAPI(backend)
+		ctx      = context.Background()
+		contract = common.HexToAddress("0xc0de")
+		poor     = common.HexToAddress("0x5678")
+		price    = (*hexutil.Big)(big.NewInt(params.GWei))
+		input    = hexutil.Bytes(pingInput)
+	)
+	// Overriding the code of the callee makes the call cost more than a transfer
+	intrinsic, _ := core.IntrinsicGas(pingInput, nil, false, true, true)
+	gas, err := api.EstimateGas(ctx, CallArgs{From: &testAddr, To: &contract, Data: &input}, nil, nil)
+	if err != nil || uint64(gas) != intrinsic {
+		t.Fatalf("estimate without code mismatch: have %d (%v), want %d", gas, err, intrinsic)
+	}
+	code := hexutil.Bytes(pingCode)
+	overridden, err := api.EstimateGas(ctx, CallArgs{From: &testAddr, To: &contract, Data: &input}, nil, &StateOverride{contract: {Code: &code}})
+	if err != nil {
+		t.Fatalf("failed to estimate with code override: %v", err)
+	}
+	if overridden <= gas {
+		t.Fatalf("code override not applied: estimate %d, without code %d", overridden, gas)
+	}
+	// Overriding it with reverting code makes the estimation fail with the reason
+	code = hexutil.Bytes(revertCode("nope"))
+	if _, err := api.EstimateGas(ctx, CallArgs{From: &testAddr, To: &contract}, nil, &StateOverride{contract: {Code: &code}}); err == nil || err.Error() != "execution reverted: nope" {
+		t.Fatalf("reverting code override error mismatch: %v", err)
+	}
+	// Overriding the balance of a sender without funds makes the transfer affordable
+	if _, err := api.EstimateGas(ctx, CallArgs{From: &poor, To: &contract, GasPrice: price}, nil, nil); err == nil {
+		t.Fatalf("estimate without funds succeeded")
+	}
+	balance := (*hexutil.Big)(big.NewInt(params.Oranger))
+	gas, err = api.EstimateGas(ctx, CallArgs{From: &poor, To: &contract, GasPrice: price}, nil, &StateOverride{poor: {Balance: &balance}})
+	if err != nil || uint64(gas) != params.TxGas {
+		t.Fatalf("estimate with balance override mismatch: have %d (%v), want %d", gas, err, params.TxGas)
+	}
+}
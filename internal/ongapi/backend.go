@@ -19,7 +19,10 @@ package ongapi
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/ong2020/go-orange/accounts"
 	"github.com/ong2020/go-orange/common"
@@ -42,12 +45,21 @@ type Backend interface {
 	// General Orange API
 	Downloader() *downloader.Downloader
 	SuggestPrice(ctx context.Context) (*big.Int, error)
+	FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []float64, error)
 	ChainDb() ongdb.Database
 	AccountManager() *accounts.Manager
 	ExtRPCEnabled() bool
-	RPCGasCap() uint64        // global gas cap for ong_call over rpc: DoS protection
-	RPCTxFeeCap() float64     // global tx fee cap for all transaction related APIs
-	UnprotectedAllowed() bool // allows only for EIP155 transactions.
+	RPCGasCap() uint64                     // global gas cap for ong_call over rpc: DoS protection
+	RPCEVMTimeout() time.Duration          // global timeout for ong_call and gas estimation over rpc
+	RPCBlockRangeCap() uint64              // global cap on the blocks spanned by ranged requests over rpc
+	RPCPersistentFilters() bool            // whether the filters installed over rpc survive restarts
+	RPCExecutionBudget() *ExecutionBudget  // per-origin EVM execution time budget
+	ABIRegistry() *ABIRegistry             // contract ABIs registered by the RPC sessions for decoding calls and logs
+	NonceReservations() *NonceReservations // nonces reserved for external signers and the node
+	RPCTxFeeCap() float64                  // global tx fee cap for all transaction related APIs
+	RPCTxSpendCap() float64                // spend cap per transaction signed by the node
+	RPCDailySpendCap() float64             // daily spend cap per account for transactions signed by the node
+	UnprotectedAllowed() bool              // allows only for EIP155 transactions.
 
 	// Blockchain API
 	SetHead(number uint64)
@@ -63,7 +75,7 @@ type Backend interface {
 	StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error)
 	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
 	GetTd(ctx context.Context, hash common.Hash) *big.Int
-	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error)
+	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error)
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
 	SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription
@@ -90,8 +102,78 @@ type Backend interface {
 	Engine() consensus.Engine
 }
 
+// ResolveFinalityTag maps the "safe" and "finalized" block tags onto the block
+// the given number of confirmations below the head. Any other block number is
+// returned unchanged.
+func ResolveFinalityTag(number rpc.BlockNumber, head uint64, safeDepth, finalizedDepth uint64) rpc.BlockNumber {
+	var depth uint64
+	switch number {
+	case rpc.SafeBlockNumber:
+		depth = safeDepth
+	case rpc.FinalizedBlockNumber:
+		depth = finalizedDepth
+	default:
+		return number
+	}
+	if head < depth {
+		return rpc.EarliestBlockNumber
+	}
+	return rpc.BlockNumber(head - depth)
+}
+
+// HeaderReader is the part of the backends needed to resolve block ranges.
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+}
+
+// NewBlockResolver returns a resolver mapping the ends of an rpc.BlockRange onto
+// block numbers. Plain block numbers are taken as is, tags and hashes are looked
+// up through the backend.
+func NewBlockResolver(ctx context.Context, b HeaderReader) rpc.BlockResolver {
+	return func(blockNrOrHash rpc.BlockNumberOrHash) (uint64, error) {
+		var (
+			header *types.Header
+			err    error
+		)
+		if number, ok := blockNrOrHash.Number(); ok {
+			if number >= 0 {
+				return uint64(number), nil
+			}
+			if header, err = b.HeaderByNumber(ctx, number); err != nil {
+				return 0, err
+			}
+			if header == nil {
+				return 0, fmt.Errorf("block %s not found", blockNrOrHash.String())
+			}
+			return header.Number.Uint64(), nil
+		}
+		hash, ok := blockNrOrHash.Hash()
+		if !ok {
+			return 0, errors.New("invalid arguments; neither block nor hash specified")
+		}
+		if header, err = b.HeaderByHash(ctx, hash); err != nil {
+			return 0, err
+		}
+		if header == nil {
+			return 0, fmt.Errorf("block %s not found", hash.Hex())
+		}
+		if blockNrOrHash.RequireCanonical {
+			canonical, err := b.HeaderByNumber(ctx, rpc.BlockNumber(header.Number.Int64()))
+			if err != nil {
+				return 0, err
+			}
+			if canonical == nil || canonical.Hash() != hash {
+				return 0, errors.New("hash is not currently canonical")
+			}
+		}
+		return header.Number.Uint64(), nil
+	}
+}
+
 func GetAPIs(apiBackend Backend) []rpc.API {
 	nonceLock := new(AddrLocker)
+	guard := NewSpendGuard(apiBackend.ChainDb(), apiBackend.RPCTxSpendCap(), apiBackend.RPCDailySpendCap())
 	return []rpc.API{
 		{
 			Namespace: "ong",
@@ -106,7 +188,12 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 		}, {
 			Namespace: "ong",
 			Version:   "1.0",
-			Service:   NewPublicTransactionPoolAPI(apiBackend, nonceLock),
+			Service:   NewPublicTransactionPoolAPI(apiBackend, nonceLock, guard),
+			Public:    true,
+		}, {
+			Namespace: "ong",
+			Version:   "1.0",
+			Service:   NewPublicNonceAPI(apiBackend),
 			Public:    true,
 		}, {
 			Namespace: "txpool",
@@ -130,7 +217,12 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 		}, {
 			Namespace: "personal",
 			Version:   "1.0",
-			Service:   NewPrivateAccountAPI(apiBackend, nonceLock),
+			Service:   NewPrivateAccountAPI(apiBackend, nonceLock, guard),
+			Public:    false,
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateSpendGuardAPI(guard),
 			Public:    false,
 		},
 	}
@@ -0,0 +1,254 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/accounts"
+	"github.com/ong2020/go-orange/accounts/keystore"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus"
+	"github.com/ong2020/go-orange/consensus/ongash"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/state"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Oranger))
+)
+
+// testBackend is a Backend over an in-memory chain. The methods not needed by
+// the tests are left unimplemented and panic if called.
+type testBackend struct {
+	Backend
+
+	db     ongdb.Database
+	chain  *core.BlockChain
+	abis   *ABIRegistry
+	nonces *NonceReservations
+	am     *accounts.Manager
+
+	poolLock sync.Mutex
+	pool     map[common.Address]map[uint64]*types.Transaction // transactions sent, by sender and nonce
+
+	headFeed event.Feed // chain head events, sent by the tests
+}
+
+// newTestBackend creates a backend with a chain of the given number of blocks
+// generated on top of a genesis funding testAddr and holding the given accounts.
+func newTestBackend(t *testing.T, alloc core.GenesisAlloc, n int, generator func(int, *core.BlockGen)) *testBackend {
+	if alloc == nil {
+		alloc = make(core.GenesisAlloc)
+	}
+	alloc[testAddr] = core.GenesisAccount{Balance: testBalance}
+
+	var (
+		gspec  = &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+		engine = ongash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+	)
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, n, generator)
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	t.Cleanup(chain.Stop)
+
+	return &testBackend{
+		db:     db,
+		chain:  chain,
+		abis:   NewABIRegistry(),
+		nonces: NewNonceReservations(),
+		pool:   make(map[common.Address]map[uint64]*types.Transaction),
+	}
+}
+
+// newTestAccountManager creates an account manager holding the unlocked key of
+// testAddr.
+func newTestAccountManager(t *testing.T) *accounts.Manager {
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.ImportECDSA(testKey, "")
+	if err != nil {
+		t.Fatalf("failed to import key: %v", err)
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		t.Fatalf("failed to unlock account: %v", err)
+	}
+	return accounts.NewManager(&accounts.Config{InsecureUnlockAllowed: true}, ks)
+}
+
+func (b *testBackend) ChainDb() ongdb.Database               { return b.db }
+func (b *testBackend) ChainConfig() *params.ChainConfig      { return b.chain.Config() }
+func (b *testBackend) Engine() consensus.Engine              { return b.chain.Engine() }
+func (b *testBackend) ABIRegistry() *ABIRegistry             { return b.abis }
+func (b *testBackend) NonceReservations() *NonceReservations { return b.nonces }
+func (b *testBackend) AccountManager() *accounts.Manager     { return b.am }
+func (b *testBackend) UnprotectedAllowed() bool              { return false }
+func (b *testBackend) RPCGasCap() uint64                     { return 25000000 }
+func (b *testBackend) RPCTxFeeCap() float64                  { return 1 }
+func (b *testBackend) RPCEVMTimeout() time.Duration          { return 5 * time.Second }
+func (b *testBackend) RPCExecutionBudget() *ExecutionBudget  { return nil }
+func (b *testBackend) CurrentHeader() *types.Header          { return b.chain.CurrentHeader() }
+func (b *testBackend) CurrentBlock() *types.Block            { return b.chain.CurrentBlock() }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number < 0 {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.chain.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	if number, ok := blockNrOrHash.Number(); ok {
+		return b.HeaderByNumber(ctx, number)
+	}
+	hash, _ := blockNrOrHash.Hash()
+	return b.HeaderByHash(ctx, hash)
+}
+
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number < 0 {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.chain.GetBlockByHash(hash), nil
+}
+
+func (b *testBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if number, ok := blockNrOrHash.Number(); ok {
+		return b.BlockByNumber(ctx, number)
+	}
+	hash, _ := blockNrOrHash.Hash()
+	return b.BlockByHash(ctx, hash)
+}
+
+func (b *testBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumber(ctx, number)
+	if header == nil {
+		return nil, nil, errors.New("header not found")
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	header, _ := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil {
+		return nil, nil, errors.New("header not found")
+	}
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	if vmConfig == nil {
+		vmConfig = b.chain.GetVMConfig()
+	}
+	txContext := core.NewEVMTxContext(msg)
+	context := core.NewEVMBlockContext(header, b.chain, nil)
+	return vm.NewEVM(context, txContext, state, b.chain.Config(), *vmConfig), func() error { return nil }, nil
+}
+
+func (b *testBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(b.db, txHash)
+	return tx, blockHash, blockNumber, index, nil
+}
+
+func (b *testBackend) GetPoolTransaction(txHash common.Hash) *types.Transaction {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	for _, txs := range b.pool {
+		for _, tx := range txs {
+			if tx.Hash() == txHash {
+				return tx
+			}
+		}
+	}
+	return nil
+}
+
+func (b *testBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.headFeed.Subscribe(ch)
+}
+
+func (b *testBackend) SendTx(ctx context.Context, tx *types.Transaction) error {
+	from, err := types.Sender(types.LatestSigner(b.chain.Config()), tx)
+	if err != nil {
+		return err
+	}
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	if b.pool[from] == nil {
+		b.pool[from] = make(map[uint64]*types.Transaction)
+	}
+	if _, ok := b.pool[from][tx.Nonce()]; ok {
+		return core.ErrNonceTooLow
+	}
+	b.pool[from][tx.Nonce()] = tx
+	return nil
+}
+
+// GetPoolNonce returns the nonce following the transactions of the account which
+// are executable, i.e. without nonce gap.
+func (b *testBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	statedb, err := b.chain.State()
+	if err != nil {
+		return 0, err
+	}
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	nonce := statedb.GetNonce(addr)
+	for b.pool[addr][nonce] != nil {
+		nonce++
+	}
+	return nonce, nil
+}
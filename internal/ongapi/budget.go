@@ -0,0 +1,134 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/log"
+)
+
+const (
+	// budgetWindow is the period over which the execution time of an origin is
+	// accounted against its budget.
+	budgetWindow = time.Minute
+
+	// budgetSweepThreshold is the number of tracked origins above which the
+	// expired ones are dropped.
+	budgetSweepThreshold = 1024
+)
+
+// budgetUsage is the execution time spent by an origin in the current window.
+type budgetUsage struct {
+	start time.Time
+	used  time.Duration
+}
+
+// ExecutionBudget limits the wall-clock time the EVM may spend executing the
+// calls (ong_call, ong_estimateGas, debug_trace*) of a single remote origin per
+// minute, so that a client can't monopolise the node with long running calls.
+// Requests without a remote origin (IPC, in-process) are not limited.
+type ExecutionBudget struct {
+	limit time.Duration // Execution time allowed per origin and window, 0 = unlimited
+	usage map[string]*budgetUsage
+	lock  sync.Mutex
+}
+
+// NewExecutionBudget creates an execution budget allowing each remote origin the
+// given EVM execution time per minute, 0 meaning no limit.
+func NewExecutionBudget(limit time.Duration) *ExecutionBudget {
+	return &ExecutionBudget{
+		limit: limit,
+		usage: make(map[string]*budgetUsage),
+	}
+}
+
+// Acquire checks whether the origin of the request has execution time left and
+// returns the time the execution may run for: the given timeout, capped by the
+// remaining budget (0 = no limit). That time is reserved from the budget right
+// away, so that concurrent calls of the same origin can't overrun it. The
+// returned function refunds the reserved time the execution did not use and
+// must be called once the execution is done.
+func (b *ExecutionBudget) Acquire(ctx context.Context, timeout time.Duration) (time.Duration, func(), error) {
+	origin := RequestOrigin(ctx)
+	if b == nil || b.limit == 0 || origin == "" {
+		return timeout, func() {}, nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	usage := b.current(origin, now)
+	remaining := b.limit - usage.used
+	if remaining <= 0 {
+		retry := usage.start.Add(budgetWindow).Sub(now)
+		log.Debug("RPC execution budget exhausted", "origin", origin, "used", common.PrettyDuration(usage.used))
+		return 0, nil, fmt.Errorf("execution budget of %v per %v exhausted, retry in %v", b.limit, budgetWindow, retry.Round(time.Second))
+	}
+	if timeout == 0 || timeout > remaining {
+		timeout = remaining
+	}
+	usage.used += timeout
+
+	var once sync.Once
+	return timeout, func() {
+		once.Do(func() {
+			if unused := timeout - time.Since(now); unused > 0 {
+				b.lock.Lock()
+				defer b.lock.Unlock()
+				usage.used -= unused
+			}
+		})
+	}, nil
+}
+
+// current returns the usage of an origin in the window containing the given
+// time, starting a new window if the last one expired. The caller must hold
+// the lock.
+func (b *ExecutionBudget) current(origin string, now time.Time) *budgetUsage {
+	usage := b.usage[origin]
+	if usage == nil || now.Sub(usage.start) >= budgetWindow {
+		if len(b.usage) >= budgetSweepThreshold {
+			for o, u := range b.usage {
+				if now.Sub(u.start) >= budgetWindow {
+					delete(b.usage, o)
+				}
+			}
+		}
+		usage = &budgetUsage{start: now}
+		b.usage[origin] = usage
+	}
+	return usage
+}
+
+// RequestOrigin returns the host of the remote endpoint an RPC request was
+// received from, or an empty string for local (IPC, in-process) requests.
+func RequestOrigin(ctx context.Context) string {
+	remote, _ := ctx.Value("remote").(string)
+	if remote == "" {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Tests that the execution budget reserves the granted time up front, so that
+// concurrent calls can't overrun it, and refunds the unused part on release.
+func TestExecutionBudgetReserve(t *testing.T) {
+	budget := NewExecutionBudget(time.Second)
+	ctx := context.WithValue(context.Background(), "remote", "10.0.0.1:30303")
+
+	timeout, release, err := budget.Acquire(ctx, 600*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to acquire budget: %v", err)
+	}
+	if timeout != 600*time.Millisecond {
+		t.Fatalf("timeout mismatch: have %v, want %v", timeout, 600*time.Millisecond)
+	}
+	// A concurrent call may only get what the first one did not reserve
+	timeout, release2, err := budget.Acquire(ctx, 600*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to acquire remaining budget: %v", err)
+	}
+	if timeout != 400*time.Millisecond {
+		t.Fatalf("remaining timeout mismatch: have %v, want %v", timeout, 400*time.Millisecond)
+	}
+	if _, _, err := budget.Acquire(ctx, 0); err == nil {
+		t.Fatalf("acquired exhausted budget")
+	}
+	// Releasing the calls refunds the unused time
+	release()
+	release()
+	release2()
+
+	timeout, _, err = budget.Acquire(ctx, 0)
+	if err != nil {
+		t.Fatalf("failed to acquire refunded budget: %v", err)
+	}
+	if timeout < 900*time.Millisecond {
+		t.Fatalf("refunded budget too low: have %v", timeout)
+	}
+	// Other origins and local requests are accounted separately
+	if _, _, err := budget.Acquire(context.WithValue(context.Background(), "remote", "10.0.0.2:30303"), 0); err != nil {
+		t.Fatalf("failed to acquire budget of other origin: %v", err)
+	}
+	if timeout, _, err := budget.Acquire(context.Background(), time.Hour); err != nil || timeout != time.Hour {
+		t.Fatalf("local request limited: timeout %v, err %v", timeout, err)
+	}
+}
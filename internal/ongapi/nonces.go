@@ -0,0 +1,204 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+)
+
+const (
+	// maxNonceReservationTTL is the maximum time a nonce can be reserved for.
+	maxNonceReservationTTL = 10 * time.Minute
+
+	// sendNonceReservationTTL is the time the nonces assigned by the node to the
+	// transactions it signs are reserved for, covering the time until the
+	// transactions enter the pool.
+	sendNonceReservationTTL = time.Minute
+
+	// maxNonceReservations is the maximum number of nonces reserved at any time,
+	// across all accounts.
+	maxNonceReservations = 4096
+)
+
+var errTooManyReservations = errors.New("too many nonce reservations")
+
+// NonceReservations tracks the nonces reserved for a short time, during which
+// they are not handed out again, allowing multiple processes signing for the same
+// account to avoid reusing nonces or leaving gaps. Nonces are reserved by the
+// external signers via ong_getNextNonce, and by the node for the transactions it
+// signs itself.
+type NonceReservations struct {
+	reservations map[common.Address]map[uint64]time.Time // Reserved nonces and their expiry per account
+	count        int                                     // Total number of reservations
+	lock         sync.Mutex
+}
+
+// NewNonceReservations creates an empty set of nonce reservations.
+func NewNonceReservations() *NonceReservations {
+	return &NonceReservations{
+		reservations: make(map[common.Address]map[uint64]time.Time),
+	}
+}
+
+// next returns the lowest nonce of an account from the pool nonce on which isn't
+// reserved. The nonce is reserved for the given time if non-zero, or until it's
+// used by a transaction in the pool.
+func (r *NonceReservations) next(address common.Address, poolNonce uint64, ttl time.Duration) (uint64, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	nonce := poolNonce
+	reserved := r.expire(address, poolNonce)
+	for {
+		if _, ok := reserved[nonce]; !ok {
+			break
+		}
+		nonce++
+	}
+	if ttl > 0 {
+		if r.count >= maxNonceReservations {
+			r.expireAll()
+		}
+		if r.count >= maxNonceReservations {
+			return 0, errTooManyReservations
+		}
+		if ttl > maxNonceReservationTTL {
+			ttl = maxNonceReservationTTL
+		}
+		if reserved = r.reservations[address]; reserved == nil {
+			reserved = make(map[uint64]time.Time)
+			r.reservations[address] = reserved
+		}
+		reserved[nonce] = time.Now().Add(ttl)
+		r.count++
+	}
+	return nonce, nil
+}
+
+// release drops the reservation of a nonce, returning whether it was reserved.
+func (r *NonceReservations) release(address common.Address, nonce uint64) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	reserved := r.reservations[address]
+	if _, ok := reserved[nonce]; !ok {
+		return false
+	}
+	delete(reserved, nonce)
+	r.count--
+	if len(reserved) == 0 {
+		delete(r.reservations, address)
+	}
+	return true
+}
+
+// expire drops the reservations of an account which timed out or were used by
+// a transaction in the pool (i.e. are below the pool nonce), returning the ones
+// still active. The caller must hold the lock.
+func (r *NonceReservations) expire(address common.Address, poolNonce uint64) map[uint64]time.Time {
+	reserved := r.reservations[address]
+	now := time.Now()
+	for nonce, expiry := range reserved {
+		if nonce < poolNonce || now.After(expiry) {
+			delete(reserved, nonce)
+			r.count--
+		}
+	}
+	if reserved != nil && len(reserved) == 0 {
+		delete(r.reservations, address)
+		return nil
+	}
+	return reserved
+}
+
+// expireAll drops the timed out reservations of all accounts. The caller must
+// hold the lock.
+func (r *NonceReservations) expireAll() {
+	now := time.Now()
+	for address, reserved := range r.reservations {
+		for nonce, expiry := range reserved {
+			if now.After(expiry) {
+				delete(reserved, nonce)
+				r.count--
+			}
+		}
+		if len(reserved) == 0 {
+			delete(r.reservations, address)
+		}
+	}
+}
+
+// reserveNonce assigns the next free nonce of the sender to a transaction about
+// to be sent by the node, if unset. The nonce is reserved until the transaction
+// enters the pool, so that ong_getNextNonce doesn't hand it out meanwhile. The
+// returned function releases the reservation if the transaction isn't sent.
+func (args *SendTxArgs) reserveNonce(ctx context.Context, b Backend) (func(), error) {
+	if args.Nonce != nil {
+		return func() {}, nil
+	}
+	poolNonce, err := b.GetPoolNonce(ctx, args.From)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := b.NonceReservations().next(args.From, poolNonce, sendNonceReservationTTL)
+	if err != nil {
+		return nil, err
+	}
+	args.Nonce = (*hexutil.Uint64)(&nonce)
+	return func() { b.NonceReservations().release(args.From, nonce) }, nil
+}
+
+// PublicNonceAPI provides an API to coordinate the nonces used by external
+// signers with the ones used by the node, via the nonce reservations of the
+// backend.
+type PublicNonceAPI struct {
+	b Backend
+}
+
+// NewPublicNonceAPI creates a new nonce management API.
+func NewPublicNonceAPI(b Backend) *PublicNonceAPI {
+	return &PublicNonceAPI{b}
+}
+
+// GetNextNonce returns the next usable nonce of an account, i.e. the lowest one
+// that is neither used by the account's transactions in the pool, nor reserved.
+// If a reservation period is specified (in seconds), the returned nonce is
+// reserved for that long, or until it's used by a transaction in the pool.
+func (api *PublicNonceAPI) GetNextNonce(ctx context.Context, address common.Address, reserve *hexutil.Uint64) (hexutil.Uint64, error) {
+	poolNonce, err := api.b.GetPoolNonce(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	var ttl time.Duration
+	if reserve != nil {
+		ttl = time.Duration(*reserve) * time.Second
+	}
+	nonce, err := api.b.NonceReservations().next(address, poolNonce, ttl)
+	return hexutil.Uint64(nonce), err
+}
+
+// ReleaseNonce drops the reservation of a nonce, e.g. if the external signer
+// decided not to use it after all. It returns whether the nonce was reserved.
+func (api *PublicNonceAPI) ReleaseNonce(address common.Address, nonce hexutil.Uint64) bool {
+	return api.b.NonceReservations().release(address, uint64(nonce))
+}
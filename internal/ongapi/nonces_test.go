@@ -0,0 +1,135 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/params"
+)
+
+// sendArgs returns the arguments of a plain transfer from testAddr, without nonce.
+func sendArgs() SendTxArgs {
+	gas := hexutil.Uint64(21000)
+	return SendTxArgs{
+		From:     testAddr,
+		To:       &common.Address{0x01},
+		Gas:      &gas,
+		GasPrice: (*hexutil.Big)(big.NewInt(params.GWei)),
+		Value:    (*hexutil.Big)(big.NewInt(1)),
+	}
+}
+
+// Tests that the nonces assigned by the node skip the ones reserved by external
+// signers, and are reserved until the transactions enter the pool.
+func TestSendTransactionReservedNonces(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	backend.am = newTestAccountManager(t)
+
+	var (
+		ctx     = context.Background()
+		api     = NewPublicTransactionPoolAPI(backend, new(AddrLocker), NewSpendGuard(nil, 0, 0))
+		nonces  = NewPublicNonceAPI(backend)
+		reserve = hexutil.Uint64(60)
+	)
+	if nonce, err := nonces.GetNextNonce(ctx, testAddr, &reserve); err != nil || nonce != 0 {
+		t.Fatalf("reserved nonce mismatch: have %d (%v), want 0", nonce, err)
+	}
+	hash, err := api.SendTransaction(ctx, sendArgs())
+	if err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	if tx := backend.pool[testAddr][1]; tx == nil || tx.Hash() != hash {
+		t.Fatalf("transaction not sent with the first free nonce")
+	}
+	// The pool nonce is stuck behind the reserved nonce, but the one of the sent
+	// transaction must not be handed out again
+	if nonce, err := nonces.GetNextNonce(ctx, testAddr, nil); err != nil || nonce != 2 {
+		t.Fatalf("next nonce mismatch: have %d (%v), want 2", nonce, err)
+	}
+	// A failed send releases its nonce
+	args := sendArgs()
+	args.Value = (*hexutil.Big)(new(big.Int).Mul(big.NewInt(2), testBalance))
+	api.guard = NewSpendGuard(nil, 1, 0)
+	if _, err := api.SendTransaction(ctx, args); err == nil {
+		t.Fatalf("transaction over the spend cap sent")
+	}
+	if nonce, err := nonces.GetNextNonce(ctx, testAddr, nil); err != nil || nonce != 2 {
+		t.Fatalf("nonce of failed transaction not released: next nonce %d (%v), want 2", nonce, err)
+	}
+}
+
+// Tests that concurrent sends by the node and reservations by external signers
+// never hand out the same nonce, nor leave gaps.
+func TestSendTransactionConcurrentNonces(t *testing.T) {
+	backend := newTestBackend(t, nil, 0, nil)
+	backend.am = newTestAccountManager(t)
+
+	var (
+		ctx     = context.Background()
+		api     = NewPublicTransactionPoolAPI(backend, new(AddrLocker), NewSpendGuard(nil, 0, 0))
+		nonces  = NewPublicNonceAPI(backend)
+		reserve = hexutil.Uint64(60)
+
+		lock     sync.Mutex
+		reserved []uint64
+		wg       sync.WaitGroup
+	)
+	const workers = 16
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := api.SendTransaction(ctx, sendArgs()); err != nil {
+				t.Errorf("failed to send transaction: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			nonce, err := nonces.GetNextNonce(ctx, testAddr, &reserve)
+			if err != nil {
+				t.Errorf("failed to reserve nonce: %v", err)
+				return
+			}
+			lock.Lock()
+			reserved = append(reserved, uint64(nonce))
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	used := make(map[uint64]string)
+	for nonce := range backend.pool[testAddr] {
+		used[nonce] = "sent"
+	}
+	for _, nonce := range reserved {
+		if use, ok := used[nonce]; ok {
+			t.Errorf("nonce %d reserved, but already %s", nonce, use)
+		}
+		used[nonce] = "reserved"
+	}
+	for nonce := uint64(0); nonce < 2*workers; nonce++ {
+		if _, ok := used[nonce]; !ok {
+			t.Errorf("nonce %d neither sent nor reserved", nonce)
+		}
+	}
+}
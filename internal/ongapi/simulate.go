@@ -0,0 +1,197 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ong2020/go-orange/accounts/abi"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// BalanceChange is the balance difference of a single account caused by a
+// simulated transaction.
+type BalanceChange struct {
+	Address common.Address `json:"address"`
+	Before  *hexutil.Big   `json:"before"`
+	After   *hexutil.Big   `json:"after"`
+	Delta   *hexutil.Big   `json:"delta"`
+}
+
+// SimulationResult is the outcome of a simulated transaction: the gas used and
+// refunded, the return data or revert reason, the logs emitted and the balance
+// changes of all the accounts involved.
+type SimulationResult struct {
+	GasUsed        hexutil.Uint64   `json:"gasUsed"`
+	GasRefund      hexutil.Uint64   `json:"gasRefund"`
+	Failed         bool             `json:"failed"`
+	Error          string           `json:"error,omitempty"`
+	RevertReason   string           `json:"revertReason,omitempty"`
+	ReturnData     hexutil.Bytes    `json:"returnData"`
+	Logs           []*types.Log     `json:"logs"`
+	BalanceChanges []*BalanceChange `json:"balanceChanges"`
+
+	DecodedCall *DecodedCall  `json:"decodedCall,omitempty"` // Decoded input, if the contract ABI is registered
+	DecodedLogs []*DecodedLog `json:"decodedLogs,omitempty"` // Decoded logs of contracts with registered ABIs
+}
+
+// simulationTracer is a lightweight EVM tracer collecting the accounts touched
+// during execution and the gas used by the EVM itself.
+type simulationTracer struct {
+	touched map[common.Address]struct{}
+	gasUsed uint64
+}
+
+func newSimulationTracer() *simulationTracer {
+	return &simulationTracer{touched: make(map[common.Address]struct{})}
+}
+
+func (t *simulationTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.touched[from] = struct{}{}
+	t.touched[to] = struct{}{}
+	return nil
+}
+
+func (t *simulationTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, rData []byte, contract *vm.Contract, depth int, err error) error {
+	t.touched[contract.Address()] = struct{}{}
+
+	// Track the value recipients that might not execute any code themselves
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		if len(stack.Data()) >= 2 {
+			t.touched[common.BytesToAddress(stack.Back(1).Bytes())] = struct{}{}
+		}
+	case vm.SELFDESTRUCT:
+		if len(stack.Data()) >= 1 {
+			t.touched[common.BytesToAddress(stack.Back(0).Bytes())] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (t *simulationTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (t *simulationTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	t.gasUsed = gasUsed
+	return nil
+}
+
+// SimulateTransaction executes the given transaction on top of the state of the
+// given block (pending by default) without making any changes, returning a
+// preview of its effects: gas used and refunded, return data, emitted logs and
+// the balance changes of all involved accounts (including the fee recipient).
+//
+// Additionally, the caller can specify a batch of contract for fields overriding.
+func (s *PublicBlockChainAPI) SimulateTransaction(ctx context.Context, args CallArgs, blockNrOrHash *rpc.BlockNumberOrHash, overrides *StateOverride) (*SimulationResult, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	state, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, bNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if err := overrides.Apply(state); err != nil {
+		return nil, err
+	}
+	// Execute the transaction on a copy, keeping the original for the deltas
+	var (
+		prestate = state.Copy()
+		tracer   = newSimulationTracer()
+	)
+	timeout, release, err := s.b.RPCExecutionBudget().Acquire(ctx, s.b.RPCEVMTimeout())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := applyMessage(ctx, s.b, args, state, header, &vm.Config{Debug: true, Tracer: tracer}, timeout, s.b.RPCGasCap())
+	if err != nil {
+		return nil, err
+	}
+	res := &SimulationResult{
+		GasUsed:    hexutil.Uint64(result.UsedGas),
+		Failed:     result.Failed(),
+		ReturnData: result.Return(),
+		Logs:       state.Logs(),
+	}
+	if res.Logs == nil {
+		res.Logs = []*types.Log{}
+	}
+	abis := s.b.ABIRegistry()
+	res.DecodedLogs = abis.DecodeLogs(ctx, res.Logs)
+	if args.Data != nil {
+		res.DecodedCall = abis.DecodeCall(ctx, args.To, *args.Data)
+	}
+	if result.Err != nil {
+		res.Error = result.Err.Error()
+	}
+	if revert := result.Revert(); len(revert) > 0 {
+		res.ReturnData = revert
+		if reason, err := abi.UnpackRevert(revert); err == nil {
+			res.RevertReason = reason
+		}
+	}
+	// The refund is the difference between the consumed and the charged gas
+	var data []byte
+	if args.Data != nil {
+		data = *args.Data
+	}
+	var accessList types.AccessList
+	if args.AccessList != nil {
+		accessList = *args.AccessList
+	}
+	rules := s.b.ChainConfig().Rules(header.Number)
+	if intrinsic, err := core.IntrinsicGas(data, accessList, args.To == nil, rules.IsHomestead, rules.IsIstanbul); err == nil {
+		if consumed := intrinsic + tracer.gasUsed; consumed > result.UsedGas {
+			res.GasRefund = hexutil.Uint64(consumed - result.UsedGas)
+		}
+	}
+	// Gather the balance changes of all the accounts touched
+	tracer.touched[header.Coinbase] = struct{}{}
+
+	for addr := range tracer.touched {
+		before, after := prestate.GetBalance(addr), state.GetBalance(addr)
+		if before.Cmp(after) == 0 {
+			continue
+		}
+		res.BalanceChanges = append(res.BalanceChanges, &BalanceChange{
+			Address: addr,
+			Before:  (*hexutil.Big)(new(big.Int).Set(before)),
+			After:   (*hexutil.Big)(new(big.Int).Set(after)),
+			Delta:   (*hexutil.Big)(new(big.Int).Sub(after, before)),
+		})
+	}
+	sort.Slice(res.BalanceChanges, func(i, j int) bool {
+		return bytes.Compare(res.BalanceChanges[i].Address[:], res.BalanceChanges[j].Address[:]) < 0
+	})
+	if res.BalanceChanges == nil {
+		res.BalanceChanges = []*BalanceChange{}
+	}
+	return res, nil
+}
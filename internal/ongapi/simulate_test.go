@@ -0,0 +1,130 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ong2020/go-orange/accounts/abi"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// revertCode returns the code of a contract reverting with the given reason
+// whatever it is called with.
+func revertCode(reason string) []byte {
+	str, _ := abi.NewType("string", "", nil)
+	data, _ := abi.Arguments{{Type: str}}.Pack(reason)
+	payload := append(crypto.Keccak256([]byte("Error(string)"))[:4], data...)
+
+	// CODECOPY the payload appended to the code into memory, then REVERT with it
+	code := []byte{0x60, byte(len(payload)), 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, byte(len(payload)), 0x60, 0x00, 0xfd}
+	return append(code, payload...)
+}
+
+// Tests that a simulated transaction reports its gas, logs and balance changes,
+// without changing the state.
+func TestSimulateTransaction(t *testing.T) {
+	var (
+		contract  = common.HexToAddress("0xc0de")
+		recipient = common.HexToAddress("0x1234")
+		alloc     = core.GenesisAlloc{contract: {Code: pingCode, Balance: new(big.Int)}}
+		backend   = newTestBackend(t, alloc, 1, nil)
+		api       = NewPublicBlockChainAPI(backend)
+		ctx       = context.Background()
+		price     = big.NewInt(params.GWei)
+		value     = big.NewInt(1000)
+	)
+	// A plain transfer moves the value and the fee
+	res, err := api.SimulateTransaction(ctx, CallArgs{From: &testAddr, To: &recipient, GasPrice: (*hexutil.Big)(price), Value: (*hexutil.Big)(value)}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to simulate transfer: %v", err)
+	}
+	if res.Failed || res.GasUsed != hexutil.Uint64(params.TxGas) || len(res.Logs) != 0 {
+		t.Fatalf("transfer result mismatch: %+v", res)
+	}
+	fee := new(big.Int).Mul(price, new(big.Int).SetUint64(params.TxGas))
+	deltas := make(map[common.Address]*big.Int)
+	for _, change := range res.BalanceChanges {
+		deltas[change.Address] = change.Delta.ToInt()
+	}
+	if delta := deltas[recipient]; delta == nil || delta.Cmp(value) != 0 {
+		t.Errorf("recipient delta mismatch: have %v, want %v", delta, value)
+	}
+	if delta, want := deltas[testAddr], new(big.Int).Neg(new(big.Int).Add(value, fee)); delta == nil || delta.Cmp(want) != 0 {
+		t.Errorf("sender delta mismatch: have %v, want %v", delta, want)
+	}
+	state, _, _ := backend.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if balance := state.GetBalance(recipient); balance.Sign() != 0 {
+		t.Errorf("simulation changed the state: recipient balance %v", balance)
+	}
+	// A contract call reports its logs, decoded once the ABI is registered
+	client := dialABISession(t, backend)
+	if err := client.Call(nil, "debug_registerABI", contract, pingABI); err != nil {
+		t.Fatalf("failed to register ABI: %v", err)
+	}
+	input := hexutil.Bytes(pingInput)
+	res = new(SimulationResult)
+	if err := client.Call(res, "ong_simulateTransaction", CallArgs{From: &testAddr, To: &contract, Data: &input}); err != nil {
+		t.Fatalf("failed to simulate call: %v", err)
+	}
+	if res.Failed || len(res.Logs) != 1 || res.Logs[0].Address != contract {
+		t.Fatalf("call result mismatch: %+v", res)
+	}
+	if res.DecodedCall == nil || res.DecodedCall.Method != "ping" || len(res.DecodedLogs) != 1 || res.DecodedLogs[0].Event != "Ping" {
+		t.Fatalf("decoding mismatch: call %+v, logs %+v", res.DecodedCall, res.DecodedLogs)
+	}
+	// A state override applies to the simulation
+	code := hexutil.Bytes(revertCode("nope"))
+	res, err = api.SimulateTransaction(ctx, CallArgs{From: &testAddr, To: &contract}, nil, &StateOverride{contract: {Code: &code}})
+	if err != nil {
+		t.Fatalf("failed to simulate reverting call: %v", err)
+	}
+	if !res.Failed || res.RevertReason != "nope" || len(res.Logs) != 0 {
+		t.Fatalf("reverting call result mismatch: %+v", res)
+	}
+}
+
+// Tests that the simulations which can't be executed are refused.
+func TestSimulateTransactionErrors(t *testing.T) {
+	var (
+		backend   = newTestBackend(t, nil, 1, nil)
+		api       = NewPublicBlockChainAPI(backend)
+		ctx       = context.Background()
+		recipient = common.HexToAddress("0x1234")
+		poor      = common.HexToAddress("0x02")
+		value     = (*hexutil.Big)(big.NewInt(1))
+	)
+	unknown := rpc.BlockNumberOrHashWithHash(common.Hash{0x01}, false)
+	if _, err := api.SimulateTransaction(ctx, CallArgs{From: &testAddr, To: &recipient}, &unknown, nil); err == nil {
+		t.Errorf("simulation on unknown block succeeded")
+	}
+	storage := map[common.Hash]common.Hash{}
+	overrides := &StateOverride{recipient: {State: &storage, StateDiff: &storage}}
+	if _, err := api.SimulateTransaction(ctx, CallArgs{From: &testAddr, To: &recipient}, nil, overrides); err == nil {
+		t.Errorf("simulation with invalid override succeeded")
+	}
+	if _, err := api.SimulateTransaction(ctx, CallArgs{From: &poor, To: &recipient, Value: value}, nil, nil); err == nil {
+		t.Errorf("simulation of unaffordable transfer succeeded")
+	}
+}
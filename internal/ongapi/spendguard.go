@@ -0,0 +1,291 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/params"
+)
+
+// spendWindow is the period over which the daily spend of an account is tracked.
+const spendWindow = 24 * time.Hour
+
+// spendRecord is a single spend accounted against an account's daily cap.
+type spendRecord struct {
+	Time   time.Time `json:"time"`
+	Amount *big.Int  `json:"amount"` // Cost of the transaction in wei
+}
+
+// spendJournal is the state of the spend guard persisted across restarts.
+type spendJournal struct {
+	Spends    map[common.Address][]*spendRecord `json:"spends"`
+	Overrides map[common.Address]*big.Int       `json:"overrides"`
+}
+
+// SpendGuard limits the amount of funds that can be sent by the transactions
+// signed by this node via the RPC APIs. It caps the total cost (value + fee) of
+// each individual transaction, as well as the total cost of all the transactions
+// sent for an account over a rolling 24 hour window. The daily cap can be
+// overridden per account through the admin API.
+//
+// Only the transactions submitted by the node count against the daily cap: the
+// ones just signed and handed back are checked against the caps, but not
+// accounted for, and the transactions signed elsewhere and submitted raw are
+// only subject to the global transaction fee cap. The spends and the overrides
+// are persisted in the database, so restarting the node doesn't reset them.
+type SpendGuard struct {
+	db        ongdb.KeyValueStore               // Database the state is persisted in, nil if not persisted
+	txCap     *big.Int                          // Per-transaction spend cap in wei, 0 = no cap
+	dailyCap  *big.Int                          // Default daily spend cap per account in wei, 0 = no cap
+	overrides map[common.Address]*big.Int       // Per-account daily spend caps in wei
+	spends    map[common.Address][]*spendRecord // Spends within the rolling window, oldest first
+
+	lock sync.Mutex
+}
+
+// NewSpendGuard creates a spend guard with the given per-transaction and default
+// per-account daily caps, both in onger, 0 meaning no cap. The spends and the
+// cap overrides are persisted in the given database, if any, and restored from
+// it.
+func NewSpendGuard(db ongdb.KeyValueStore, txCap, dailyCap float64) *SpendGuard {
+	g := &SpendGuard{
+		db:        db,
+		txCap:     ongerToWei(txCap),
+		dailyCap:  ongerToWei(dailyCap),
+		overrides: make(map[common.Address]*big.Int),
+		spends:    make(map[common.Address][]*spendRecord),
+	}
+	if db == nil {
+		return g
+	}
+	if blob := rawdb.ReadSpendGuardJournal(db); len(blob) > 0 {
+		var journal spendJournal
+		if err := json.Unmarshal(blob, &journal); err != nil {
+			log.Warn("Failed to restore spend guard state", "err", err)
+			return g
+		}
+		for account, records := range journal.Spends {
+			g.spends[account] = records
+		}
+		for account, cap := range journal.Overrides {
+			g.overrides[account] = cap
+		}
+	}
+	return g
+}
+
+// reserve checks whether the given transaction of an account is within the spend
+// caps, accounting its cost against the account's daily spend if so. The returned
+// function reverts the reservation, to be called if the transaction could not be
+// signed or submitted after all.
+func (g *SpendGuard) reserve(from common.Address, tx *types.Transaction) (func(), error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	cost := tx.Cost()
+	if err := g.verify(from, cost); err != nil {
+		return nil, err
+	}
+	record := &spendRecord{Time: time.Now(), Amount: cost}
+	g.spends[from] = append(g.spends[from], record)
+	g.persist()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.lock.Lock()
+			defer g.lock.Unlock()
+
+			records := g.spends[from]
+			for i, r := range records {
+				if r == record {
+					g.spends[from] = append(records[:i:i], records[i+1:]...)
+					break
+				}
+			}
+			if len(g.spends[from]) == 0 {
+				delete(g.spends, from)
+			}
+			g.persist()
+		})
+	}, nil
+}
+
+// check checks whether the given transaction of an account is within the spend
+// caps, without accounting its cost.
+func (g *SpendGuard) check(from common.Address, tx *types.Transaction) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.verify(from, tx.Cost())
+}
+
+// verify checks whether a transaction cost of an account is within the spend
+// caps. The caller must hold the lock.
+func (g *SpendGuard) verify(from common.Address, cost *big.Int) error {
+	if g.txCap.Sign() != 0 && cost.Cmp(g.txCap) > 0 {
+		return fmt.Errorf("tx spend (%v wei) exceeds the configured cap (%v wei)", cost, g.txCap)
+	}
+	limit, spent := g.limit(from), g.spent(from)
+	if limit.Sign() != 0 && new(big.Int).Add(spent, cost).Cmp(limit) > 0 {
+		log.Warn("Daily spend cap reached", "account", from, "spent", spent, "cost", cost, "cap", limit)
+		return fmt.Errorf("daily spend of %s (%v wei + %v wei) exceeds the configured cap (%v wei)", from.Hex(), spent, cost, limit)
+	}
+	return nil
+}
+
+// limit returns the daily spend cap of an account. The caller must hold the lock.
+func (g *SpendGuard) limit(account common.Address) *big.Int {
+	if limit, ok := g.overrides[account]; ok {
+		return limit
+	}
+	return g.dailyCap
+}
+
+// spent drops the spends of an account that fell out of the rolling window and
+// returns the total of the remaining ones. The caller must hold the lock.
+func (g *SpendGuard) spent(account common.Address) *big.Int {
+	var (
+		records = g.spends[account]
+		cutoff  = time.Now().Add(-spendWindow)
+		total   = new(big.Int)
+	)
+	for len(records) > 0 && !records[0].Time.After(cutoff) {
+		records = records[1:]
+	}
+	if len(records) == 0 {
+		delete(g.spends, account)
+		return total
+	}
+	g.spends[account] = records
+	for _, r := range records {
+		total.Add(total, r.Amount)
+	}
+	return total
+}
+
+// persist stores the spends and the cap overrides in the database, if any. The
+// caller must hold the lock.
+func (g *SpendGuard) persist() {
+	if g.db == nil {
+		return
+	}
+	blob, err := json.Marshal(&spendJournal{Spends: g.spends, Overrides: g.overrides})
+	if err != nil {
+		log.Error("Failed to encode spend guard state", "err", err)
+		return
+	}
+	rawdb.WriteSpendGuardJournal(g.db, blob)
+}
+
+// ongerToWei converts an amount of onger into wei.
+func ongerToWei(amount float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(amount), new(big.Float).SetInt64(params.Oranger)).Int(nil)
+	return wei
+}
+
+// AccountSpend is the spend status of a single account.
+type AccountSpend struct {
+	Spent    *hexutil.Big `json:"spent"`    // Total sent within the last 24 hours, in wei
+	Cap      *hexutil.Big `json:"cap"`      // Daily spend cap in wei, 0 = no cap
+	Override bool         `json:"override"` // Whether the cap was overridden for the account
+}
+
+// SpendGuardStatus is the configuration and the tracked spends of the spend guard.
+type SpendGuardStatus struct {
+	TxCap    *hexutil.Big                     `json:"txCap"`
+	DailyCap *hexutil.Big                     `json:"dailyCap"`
+	Accounts map[common.Address]*AccountSpend `json:"accounts"`
+}
+
+// PrivateSpendGuardAPI provides an API to inspect the spend guard and to override
+// the daily spend caps of individual accounts.
+type PrivateSpendGuardAPI struct {
+	guard *SpendGuard
+}
+
+// NewPrivateSpendGuardAPI creates a new API to manage the given spend guard.
+func NewPrivateSpendGuardAPI(guard *SpendGuard) *PrivateSpendGuardAPI {
+	return &PrivateSpendGuardAPI{guard: guard}
+}
+
+// SpendGuard returns the configured caps and the daily spend of the accounts that
+// either sent funds within the last 24 hours or have an overridden cap.
+func (api *PrivateSpendGuardAPI) SpendGuard() *SpendGuardStatus {
+	g := api.guard
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	status := &SpendGuardStatus{
+		TxCap:    (*hexutil.Big)(g.txCap),
+		DailyCap: (*hexutil.Big)(g.dailyCap),
+		Accounts: make(map[common.Address]*AccountSpend),
+	}
+	for account := range g.spends {
+		status.Accounts[account] = &AccountSpend{}
+	}
+	for account := range g.overrides {
+		status.Accounts[account] = &AccountSpend{}
+	}
+	for account, spend := range status.Accounts {
+		_, spend.Override = g.overrides[account]
+		spend.Cap = (*hexutil.Big)(g.limit(account))
+		spend.Spent = (*hexutil.Big)(g.spent(account))
+	}
+	return status
+}
+
+// SetDailySpendCap overrides the daily spend cap of an account, in wei. A cap of
+// 0 removes the limit for the account altogether.
+func (api *PrivateSpendGuardAPI) SetDailySpendCap(account common.Address, cap hexutil.Big) error {
+	if cap.ToInt().Sign() < 0 {
+		return fmt.Errorf("invalid spend cap %v", cap.ToInt())
+	}
+	api.guard.lock.Lock()
+	defer api.guard.lock.Unlock()
+
+	api.guard.overrides[account] = new(big.Int).Set(cap.ToInt())
+	api.guard.persist()
+	log.Info("Overrode daily spend cap", "account", account, "cap", cap.ToInt())
+	return nil
+}
+
+// ResetDailySpendCap removes the daily spend cap override of an account, making
+// the default cap apply to it again. It returns whether there was an override.
+func (api *PrivateSpendGuardAPI) ResetDailySpendCap(account common.Address) bool {
+	api.guard.lock.Lock()
+	defer api.guard.lock.Unlock()
+
+	_, ok := api.guard.overrides[account]
+	delete(api.guard.overrides, account)
+	if ok {
+		api.guard.persist()
+	}
+	return ok
+}
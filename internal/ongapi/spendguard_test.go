@@ -0,0 +1,146 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/params"
+)
+
+// spendTx creates a transaction costing the given amount of onger, fee included.
+func spendTx(onger int64) *types.Transaction {
+	value := new(big.Int).Mul(big.NewInt(onger), big.NewInt(params.Oranger))
+	value.Sub(value, big.NewInt(21000))
+	return types.NewTransaction(0, common.Address{}, value, 21000, big.NewInt(1), nil)
+}
+
+// Tests that spends are reserved against the daily cap, refused once over it,
+// and reverted if the transaction couldn't be sent.
+func TestSpendGuardReserve(t *testing.T) {
+	var (
+		guard   = NewSpendGuard(nil, 5, 10)
+		account = common.Address{0x01}
+	)
+	if _, err := guard.reserve(account, spendTx(6)); err == nil {
+		t.Fatalf("transaction over the per-transaction cap accepted")
+	}
+	revert, err := guard.reserve(account, spendTx(4))
+	if err != nil {
+		t.Fatalf("failed to reserve spend: %v", err)
+	}
+	if _, err := guard.reserve(account, spendTx(4)); err != nil {
+		t.Fatalf("failed to reserve second spend: %v", err)
+	}
+	if _, err := guard.reserve(account, spendTx(4)); err == nil {
+		t.Fatalf("spend over the daily cap accepted")
+	}
+	// Other accounts have their own tally
+	if _, err := guard.reserve(common.Address{0x02}, spendTx(4)); err != nil {
+		t.Fatalf("failed to reserve spend of other account: %v", err)
+	}
+	// Reverting a spend frees its amount, but only once
+	revert()
+	revert()
+	if _, err := guard.reserve(account, spendTx(4)); err != nil {
+		t.Fatalf("failed to reserve reverted spend: %v", err)
+	}
+	if _, err := guard.reserve(account, spendTx(3)); err == nil {
+		t.Fatalf("spend over the daily cap accepted after revert")
+	}
+	// Checking a transaction doesn't account it
+	if err := guard.check(account, spendTx(2)); err != nil {
+		t.Fatalf("failed to check spend within the cap: %v", err)
+	}
+	if err := guard.check(account, spendTx(3)); err == nil {
+		t.Fatalf("check of spend over the daily cap succeeded")
+	}
+	if err := guard.check(common.Address{0x03}, spendTx(2)); err != nil {
+		t.Fatalf("failed to check spend: %v", err)
+	}
+	if _, err := guard.reserve(common.Address{0x03}, spendTx(5)); err != nil {
+		t.Fatalf("checked spend was accounted: %v", err)
+	}
+}
+
+// Tests that the daily cap can be overridden per account.
+func TestSpendGuardOverride(t *testing.T) {
+	var (
+		guard   = NewSpendGuard(nil, 0, 1)
+		api     = NewPrivateSpendGuardAPI(guard)
+		account = common.Address{0x01}
+		cap     = new(big.Int).Mul(big.NewInt(3), big.NewInt(params.Oranger))
+	)
+	if _, err := guard.reserve(account, spendTx(2)); err == nil {
+		t.Fatalf("spend over the daily cap accepted")
+	}
+	if err := api.SetDailySpendCap(account, hexutil.Big(*cap)); err != nil {
+		t.Fatalf("failed to override cap: %v", err)
+	}
+	if _, err := guard.reserve(account, spendTx(2)); err != nil {
+		t.Fatalf("spend within the overridden cap refused: %v", err)
+	}
+	status := api.SpendGuard().Accounts[account]
+	if status == nil || !status.Override || status.Cap.ToInt().Cmp(cap) != 0 {
+		t.Fatalf("wrong account status: %+v", status)
+	}
+	if want := new(big.Int).Mul(big.NewInt(2), big.NewInt(params.Oranger)); status.Spent.ToInt().Cmp(want) != 0 {
+		t.Fatalf("spent mismatch: have %v, want %v", status.Spent.ToInt(), want)
+	}
+	if !api.ResetDailySpendCap(account) {
+		t.Fatalf("override not reset")
+	}
+	if _, err := guard.reserve(account, spendTx(1)); err == nil {
+		t.Fatalf("spend over the default cap accepted after reset")
+	}
+}
+
+// Tests that the tally and the overrides survive a restart.
+func TestSpendGuardPersistence(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		guard   = NewSpendGuard(db, 0, 10)
+		account = common.Address{0x01}
+		cap     = new(big.Int).Mul(big.NewInt(20), big.NewInt(params.Oranger))
+	)
+	if _, err := guard.reserve(account, spendTx(8)); err != nil {
+		t.Fatalf("failed to reserve spend: %v", err)
+	}
+	revert, err := guard.reserve(account, spendTx(2))
+	if err != nil {
+		t.Fatalf("failed to reserve spend: %v", err)
+	}
+	revert()
+	if err := NewPrivateSpendGuardAPI(guard).SetDailySpendCap(common.Address{0x02}, hexutil.Big(*cap)); err != nil {
+		t.Fatalf("failed to override cap: %v", err)
+	}
+	guard = NewSpendGuard(db, 0, 10)
+	if _, err := guard.reserve(account, spendTx(3)); err == nil {
+		t.Fatalf("spend over the daily cap accepted after restart")
+	}
+	if _, err := guard.reserve(account, spendTx(2)); err != nil {
+		t.Fatalf("reverted spend still accounted after restart: %v", err)
+	}
+	if _, err := guard.reserve(common.Address{0x02}, spendTx(15)); err != nil {
+		t.Fatalf("cap override lost after restart: %v", err)
+	}
+}
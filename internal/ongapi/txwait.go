@@ -0,0 +1,157 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core"
+)
+
+const (
+	// defaultReceiptWaitTimeout is the time waited for a transaction receipt if
+	// no timeout is specified.
+	defaultReceiptWaitTimeout = time.Minute
+
+	// maxReceiptWaitTimeout is the maximum time waited for a transaction receipt.
+	maxReceiptWaitTimeout = 10 * time.Minute
+
+	// maxReceiptConfirmations is the maximum number of confirmations waited for.
+	maxReceiptConfirmations = 1024
+
+	// txPoolRecheckInterval is the time between two checks whether a transaction
+	// waited for was dropped from the pool, in the absence of new blocks.
+	txPoolRecheckInterval = 3 * time.Second
+)
+
+// Outcomes of waiting for a transaction receipt.
+const (
+	TxWaitMined   = "mined"   // Included with the requested number of confirmations
+	TxWaitDropped = "dropped" // Neither included nor pending in the pool (anymore)
+	TxWaitTimeout = "timeout" // Still pending (or not confirmed enough) at the deadline
+)
+
+// WaitReceiptArgs are the options of waiting for a transaction receipt.
+type WaitReceiptArgs struct {
+	Confirmations *hexutil.Uint64 `json:"confirmations"` // Number of blocks including and on top of the tx, default 1
+	Timeout       *hexutil.Uint64 `json:"timeout"`       // Time to wait in seconds, default 60
+}
+
+// TxWaitResult is the outcome of waiting for a transaction receipt.
+type TxWaitResult struct {
+	Status        string                 `json:"status"`
+	Confirmations hexutil.Uint64         `json:"confirmations"`
+	Receipt       map[string]interface{} `json:"receipt"`
+}
+
+// WaitForTransactionReceipt waits until the transaction with the given hash is
+// included in the canonical chain with the requested number of confirmations,
+// or dropped from the transaction pool, or the timeout elapses, sparing clients
+// from polling for the receipt. Chain reorganisations are followed, so a mined
+// transaction reorged out of the chain is waited for again. The receipt is
+// returned along with the outcome if the transaction is included, even if not
+// yet confirmed enough. A transaction unknown to the node is reported as
+// dropped, the same as one leaving the pool while waited for.
+func (s *PublicTransactionPoolAPI) WaitForTransactionReceipt(ctx context.Context, hash common.Hash, args *WaitReceiptArgs) (*TxWaitResult, error) {
+	var (
+		confirmations = uint64(1)
+		timeout       = defaultReceiptWaitTimeout
+	)
+	if args != nil && args.Confirmations != nil {
+		confirmations = uint64(*args.Confirmations)
+		if confirmations == 0 {
+			confirmations = 1
+		}
+		if confirmations > maxReceiptConfirmations {
+			confirmations = maxReceiptConfirmations
+		}
+	}
+	if args != nil && args.Timeout != nil {
+		timeout = time.Duration(*args.Timeout) * time.Second
+		if timeout > maxReceiptWaitTimeout {
+			timeout = maxReceiptWaitTimeout
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Subscribe to new heads before the first check to avoid missing any block
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := s.b.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	result, err := s.checkReceipt(ctx, hash, confirmations)
+	if err != nil {
+		return nil, err
+	}
+	recheck := time.NewTicker(txPoolRecheckInterval)
+	defer recheck.Stop()
+
+	for result.Status == "" {
+		select {
+		case <-heads:
+		case <-recheck.C:
+		case err := <-sub.Err():
+			return nil, err
+		case <-ctx.Done():
+			result.Status = TxWaitTimeout
+			return result, nil
+		}
+		current, err := s.checkReceipt(ctx, hash, confirmations)
+		if err != nil {
+			if ctx.Err() != nil {
+				result.Status = TxWaitTimeout
+				return result, nil
+			}
+			return nil, err
+		}
+		result = current
+	}
+	return result, nil
+}
+
+// checkReceipt looks up the receipt of a transaction, reporting whether it's
+// mined with the given number of confirmations or dropped from the pool. If it's
+// neither, the status is left empty.
+func (s *PublicTransactionPoolAPI) checkReceipt(ctx context.Context, hash common.Hash, confirmations uint64) (*TxWaitResult, error) {
+	result := new(TxWaitResult)
+
+	tx, _, number, _, err := s.b.GetTransaction(ctx, hash)
+	if err == nil && tx != nil {
+		receipt, err := s.GetTransactionReceipt(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			if head := s.b.CurrentHeader().Number.Uint64(); head >= number {
+				result.Confirmations = hexutil.Uint64(head - number + 1)
+			}
+			result.Receipt = receipt
+			if uint64(result.Confirmations) >= confirmations {
+				result.Status = TxWaitMined
+			}
+			return result, nil
+		}
+	}
+	if s.b.GetPoolTransaction(hash) == nil {
+		result.Status = TxWaitDropped
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/params"
+)
+
+// Tests the outcomes of waiting for a transaction receipt: mined, timed out
+// while pending, and dropped, whether unknown from the start or leaving the pool
+// while waited for.
+func TestWaitForTransactionReceipt(t *testing.T) {
+	var mined common.Hash
+	backend := newTestBackend(t, nil, 2, func(i int, b *core.BlockGen) {
+		if i == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(testAddr), common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(10*params.GWei), nil), types.HomesteadSigner{}, testKey)
+			b.AddTx(tx)
+			mined = tx.Hash()
+		}
+	})
+	var (
+		ctx    = context.Background()
+		api    = NewPublicTransactionPoolAPI(backend, nil, nil)
+		second = hexutil.Uint64(1)
+	)
+	// A mined transaction is reported with its receipt and confirmations
+	confirmations := hexutil.Uint64(2)
+	result, err := api.WaitForTransactionReceipt(ctx, mined, &WaitReceiptArgs{Confirmations: &confirmations, Timeout: &second})
+	if err != nil {
+		t.Fatalf("failed to wait for mined transaction: %v", err)
+	}
+	if result.Status != TxWaitMined || result.Confirmations != 2 || result.Receipt == nil {
+		t.Fatalf("mined transaction result mismatch: %+v", result)
+	}
+	// An unknown transaction is reported as dropped, without waiting
+	start := time.Now()
+	result, err = api.WaitForTransactionReceipt(ctx, common.Hash{0x01}, &WaitReceiptArgs{Timeout: &second})
+	if err != nil {
+		t.Fatalf("failed to wait for unknown transaction: %v", err)
+	}
+	if result.Status != TxWaitDropped || result.Receipt != nil {
+		t.Fatalf("unknown transaction result mismatch: %+v", result)
+	}
+	if time.Since(start) >= time.Second {
+		t.Fatalf("waited for unknown transaction")
+	}
+	// A pending transaction times out, and is reported as dropped the same way
+	// once it leaves the pool
+	tx, _ := types.SignTx(types.NewTransaction(1, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(10*params.GWei), nil), types.HomesteadSigner{}, testKey)
+	if err := backend.SendTx(ctx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	result, err = api.WaitForTransactionReceipt(ctx, tx.Hash(), &WaitReceiptArgs{Timeout: &second})
+	if err != nil {
+		t.Fatalf("failed to wait for pending transaction: %v", err)
+	}
+	if result.Status != TxWaitTimeout {
+		t.Fatalf("pending transaction result mismatch: %+v", result)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		backend.poolLock.Lock()
+		delete(backend.pool[testAddr], tx.Nonce())
+		backend.poolLock.Unlock()
+		backend.headFeed.Send(core.ChainHeadEvent{Block: backend.chain.CurrentBlock()})
+	}()
+	timeout := hexutil.Uint64(10)
+	result, err = api.WaitForTransactionReceipt(ctx, tx.Hash(), &WaitReceiptArgs{Timeout: &timeout})
+	if err != nil {
+		t.Fatalf("failed to wait for dropped transaction: %v", err)
+	}
+	if result.Status != TxWaitDropped || result.Receipt != nil {
+		t.Fatalf("dropped transaction result mismatch: %+v", result)
+	}
+}
@@ -34,6 +34,8 @@ var Modules = map[string]string{
 	"txpool":     TxpoolJs,
 	"les":        LESJs,
 	"vflux":      VfluxJs,
+	"relay":      RelayJs,
+	"system":     SystemJs,
 }
 
 const ChequebookJs = `
@@ -186,6 +188,10 @@ web3._extend({
 			call: 'admin_sleepBlocks',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'forkReadiness',
+			call: 'admin_forkReadiness'
+		}),
 		new web3._extend.Method({
 			name: 'startRPC',
 			call: 'admin_startRPC',
@@ -206,12 +212,57 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'reloadConfig',
+			call: 'admin_reloadConfig'
+		}),
+		new web3._extend.Method({
+			name: 'checkConnectivity',
+			call: 'admin_checkConnectivity'
+		}),
+		new web3._extend.Method({
+			name: 'forensics',
+			call: 'admin_forensics'
+		}),
+		new web3._extend.Method({
+			name: 'forensicRecord',
+			call: 'admin_forensicRecord',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setDailySpendCap',
+			call: 'admin_setDailySpendCap',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'resetDailySpendCap',
+			call: 'admin_resetDailySpendCap',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'spendGuard',
+			getter: 'admin_spendGuard'
+		}),
 		new web3._extend.Property({
 			name: 'nodeInfo',
 			getter: 'admin_nodeInfo'
 		}),
+		new web3._extend.Property({
+			name: 'discoveryTable',
+			getter: 'admin_discoveryTable'
+		}),
+		new web3._extend.Property({
+			name: 'handshakeFailures',
+			getter: 'admin_handshakeFailures'
+		}),
+		new web3._extend.Property({
+			name: 'natStatus',
+			getter: 'admin_natStatus'
+		}),
 		new web3._extend.Property({
 			name: 'peers',
 			getter: 'admin_peers'
@@ -276,6 +327,8 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'chaindbCompact',
 			call: 'debug_chaindbCompact',
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'verbosity',
@@ -448,6 +501,54 @@ web3._extend({
 			call: 'debug_getBadBlocks',
 			params: 0,
 		}),
+		new web3._extend.Method({
+			name: 'dbSizes',
+			call: 'debug_dbSizes',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'pauseSnapshotGeneration',
+			call: 'debug_pauseSnapshotGeneration',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'resumeSnapshotGeneration',
+			call: 'debug_resumeSnapshotGeneration',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'snapshotStatus',
+			call: 'debug_snapshotStatus',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'getSideChains',
+			call: 'debug_getSideChains',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'getUncleByHash',
+			call: 'debug_getUncleByHash',
+			params: 2,
+		}),
+		new web3._extend.Method({
+			name: 'blockPropagation',
+			call: 'debug_blockPropagation',
+			params: 1,
+			inputFormatter: [null],
+		}),
+		new web3._extend.Method({
+			name: 'registerABI',
+			call: 'debug_registerABI',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'unregisterABI',
+			call: 'debug_unregisterABI',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'storageRangeAt',
 			call: 'debug_storageRangeAt',
@@ -465,6 +566,18 @@ web3._extend({
 			params: 2,
 			inputFormatter:[null, null],
 		}),
+		new web3._extend.Method({
+			name: 'getModifiedStorageByNumber',
+			call: 'debug_getModifiedStorageByNumber',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null],
+		}),
+		new web3._extend.Method({
+			name: 'getModifiedStorageByHash',
+			call: 'debug_getModifiedStorageByHash',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null],
+		}),
 		new web3._extend.Method({
 			name: 'freezeClient',
 			call: 'debug_freezeClient',
@@ -484,12 +597,50 @@ web3._extend({
 			call: 'ong_chainId',
 			params: 0
 		}),
+		new web3._extend.Method({
+			name: 'chainConfig',
+			call: 'ong_chainConfig',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'feeHistory',
+			call: 'ong_feeHistory',
+			params: 3,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'sign',
 			call: 'ong_sign',
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'getNextNonce',
+			call: 'ong_getNextNonce',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null],
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Method({
+			name: 'releaseNonce',
+			call: 'ong_releaseNonce',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'sendTransactions',
+			call: 'ong_sendTransactions',
+			params: 1,
+			inputFormatter: [function(txs) {
+				return txs.map(web3._extend.formatters.inputTransactionFormatter);
+			}]
+		}),
+		new web3._extend.Method({
+			name: 'waitForTransactionReceipt',
+			call: 'ong_waitForTransactionReceipt',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'resend',
 			call: 'ong_resend',
@@ -549,6 +700,17 @@ web3._extend({
 			call: 'ong_getRawTransactionByHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getBlockReceipts',
+			call: 'ong_getBlockReceipts',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'getDecodedTransactionReceipt',
+			call: 'ong_getDecodedTransactionReceipt',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'getRawTransactionFromBlock',
 			call: function(args) {
@@ -563,6 +725,18 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'createAccessList',
+			call: 'ong_createAccessList',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter],
+		}),
+		new web3._extend.Method({
+			name: 'simulateTransaction',
+			call: 'ong_simulateTransaction',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -617,6 +791,20 @@ web3._extend({
 			call: 'miner_setRecommitInterval',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'setMaxTxs',
+			call: 'miner_setMaxTxs',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'setFillDeadline',
+			call: 'miner_setFillDeadline',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'pendingTransactions',
+			call: 'miner_pendingTransactions'
+		}),
 		new web3._extend.Method({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
@@ -684,6 +872,27 @@ web3._extend({
 			name: 'initializeWallet',
 			call: 'personal_initializeWallet',
 			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'importRawKeys',
+			call: 'personal_importRawKeys',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'exportBundle',
+			call: 'personal_exportBundle',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'importBundle',
+			call: 'personal_importBundle',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'setAccountLabel',
+			call: 'personal_setAccountLabel',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
 		})
 	],
 	properties: [
@@ -704,6 +913,10 @@ web3._extend({
 			name: 'modules',
 			getter: 'rpc_modules'
 		}),
+		new web3._extend.Property({
+			name: 'stats',
+			getter: 'rpc_stats'
+		}),
 	]
 });
 `
@@ -873,6 +1086,10 @@ web3._extend({
 			name: 'serverInfo',
 			getter: 'les_serverInfo'
 		}),
+		new web3._extend.Property({
+			name: 'costTable',
+			getter: 'les_costTable'
+		}),
 	]
 });
 `
@@ -907,3 +1124,62 @@ web3._extend({
 	]
 });
 `
+
+const RelayJs = `
+web3._extend({
+	property: 'relay',
+	Methods:
+	[
+		new web3._extend.Method({
+			name: 'sendMetaTransaction',
+			call: 'relay_sendMetaTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'metaTransactionHash',
+			call: 'relay_metaTransactionHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'nonce',
+			call: 'relay_nonce',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'transaction',
+			call: 'relay_transaction',
+			params: 1
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'status',
+			getter: 'relay_status'
+		}),
+	]
+});
+`
+
+const SystemJs = `
+web3._extend({
+	property: 'system',
+	Methods:
+	[
+		new web3._extend.Method({
+			name: 'call',
+			call: 'system_call',
+			params: 4,
+			inputFormatter: [null, null, null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'contracts',
+			getter: 'system_contracts'
+		}),
+	]
+});
+`
@@ -58,6 +58,24 @@ func (api *PrivateLightServerAPI) ServerInfo() map[string]interface{} {
 	return res
 }
 
+// CostTable returns the request cost estimates currently advertised to newly
+// connected clients, along with the learned serving costs they are scaled by.
+func (api *PrivateLightServerAPI) CostTable() []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, entry := range api.server.costTracker.costTable() {
+		res = append(res, map[string]interface{}{
+			"msgCode":      entry.MsgCode,
+			"name":         Les3[entry.MsgCode].Name,
+			"baseCost":     entry.BaseCost,
+			"reqCost":      entry.ReqCost,
+			"multiplier":   entry.Multiplier,
+			"relativeCost": entry.Ratio,
+			"samples":      entry.Samples,
+		})
+	}
+	return res
+}
+
 // ClientInfo returns information about clients listed in the ids list or matching the given tags
 func (api *PrivateLightServerAPI) ClientInfo(ids []enode.ID) map[enode.ID]map[string]interface{} {
 	res := make(map[enode.ID]map[string]interface{})
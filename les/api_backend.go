@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"time"
 
 	"github.com/ong2020/go-orange/accounts"
 	"github.com/ong2020/go-orange/common"
@@ -31,6 +32,7 @@ import (
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/core/vm"
 	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/internal/ongapi"
 	"github.com/ong2020/go-orange/light"
 	"github.com/ong2020/go-orange/ong/downloader"
 	"github.com/ong2020/go-orange/ong/gasprice"
@@ -44,6 +46,9 @@ type LesApiBackend struct {
 	allowUnprotectedTxs bool
 	ong                 *LightOrange
 	gpo                 *gasprice.Oracle
+	budget              *ongapi.ExecutionBudget
+	abis                *ongapi.ABIRegistry
+	nonces              *ongapi.NonceReservations
 }
 
 func (b *LesApiBackend) ChainConfig() *params.ChainConfig {
@@ -63,6 +68,8 @@ func (b *LesApiBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
 		return b.ong.blockchain.CurrentHeader(), nil
 	}
+	head := b.ong.blockchain.CurrentHeader().Number.Uint64()
+	number = ongapi.ResolveFinalityTag(number, head, b.ong.config.RPCSafeDepth, b.ong.config.RPCFinalizedDepth)
 	return b.ong.blockchain.GetHeaderByNumberOdr(ctx, uint64(number))
 }
 
@@ -171,10 +178,13 @@ func (b *LesApiBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
 	return nil
 }
 
-func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	if vmConfig == nil {
+		vmConfig = new(vm.Config)
+	}
 	txContext := core.NewEVMTxContext(msg)
 	context := core.NewEVMBlockContext(header, b.ong.blockchain, nil)
-	return vm.NewEVM(context, txContext, state, b.ong.chainConfig, vm.Config{}), state.Error, nil
+	return vm.NewEVM(context, txContext, state, b.ong.chainConfig, *vmConfig), state.Error, nil
 }
 
 func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
@@ -252,6 +262,10 @@ func (b *LesApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *LesApiBackend) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blocks, lastBlock, rewardPercentiles)
+}
+
 func (b *LesApiBackend) ChainDb() ongdb.Database {
 	return b.ong.chainDb
 }
@@ -272,10 +286,48 @@ func (b *LesApiBackend) RPCGasCap() uint64 {
 	return b.ong.config.RPCGasCap
 }
 
+func (b *LesApiBackend) RPCEVMTimeout() time.Duration {
+	return b.ong.config.RPCEVMTimeout
+}
+
+func (b *LesApiBackend) RPCBlockRangeCap() uint64 {
+	return b.ong.config.RPCBlockRangeCap
+}
+
+// RPCPersistentFilters always disables the filter persistence of light clients:
+// the changes missed during a restart can't be replayed before peers connect.
+func (b *LesApiBackend) RPCPersistentFilters() bool {
+	return false
+}
+
+func (b *LesApiBackend) RPCTraceTimeout() time.Duration {
+	return b.ong.config.RPCTraceTimeout
+}
+
+func (b *LesApiBackend) RPCExecutionBudget() *ongapi.ExecutionBudget {
+	return b.budget
+}
+
+func (b *LesApiBackend) ABIRegistry() *ongapi.ABIRegistry {
+	return b.abis
+}
+
+func (b *LesApiBackend) NonceReservations() *ongapi.NonceReservations {
+	return b.nonces
+}
+
 func (b *LesApiBackend) RPCTxFeeCap() float64 {
 	return b.ong.config.RPCTxFeeCap
 }
 
+func (b *LesApiBackend) RPCTxSpendCap() float64 {
+	return b.ong.config.RPCTxSpendCap
+}
+
+func (b *LesApiBackend) RPCDailySpendCap() float64 {
+	return b.ong.config.RPCDailySpendCap
+}
+
 func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	if b.ong.bloomIndexer == nil {
 		return 0, 0
@@ -87,7 +87,7 @@ func New(stack *node.Node, config *ongconfig.Config) (*LightOrange, error) {
 	if err != nil {
 		return nil, err
 	}
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideBerlin)
+	chainConfig, genesisHash, genesisErr := ongconfig.SetupGenesis(chainDb, config)
 	if _, isCompat := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !isCompat {
 		return nil, genesisErr
 	}
@@ -129,6 +129,7 @@ func New(stack *node.Node, config *ongconfig.Config) (*LightOrange, error) {
 	long.chtIndexer = light.NewChtIndexer(chainDb, long.odr, params.CHTFrequency, params.HelperTrieConfirmations, config.LightNoPrune)
 	long.bloomTrieIndexer = light.NewBloomTrieIndexer(chainDb, long.odr, params.BloomBitsBlocksClient, params.BloomTrieFrequency, config.LightNoPrune)
 	long.odr.SetIndexers(long.chtIndexer, long.bloomTrieIndexer, long.bloomIndexer)
+	long.odr.SetMaxProofBloat(config.LightMaxProofBloat)
 
 	checkpoint := config.Checkpoint
 	if checkpoint == nil {
@@ -160,7 +161,7 @@ func New(stack *node.Node, config *ongconfig.Config) (*LightOrange, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 
-	long.ApiBackend = &LesApiBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, long, nil}
+	long.ApiBackend = &LesApiBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, long, nil, ongapi.NewExecutionBudget(config.RPCEVMBudget), ongapi.NewABIRegistry(), ongapi.NewNonceReservations()}
 	gpoParams := config.GPO
 	if gpoParams.Default == nil {
 		gpoParams.Default = config.Miner.GasPrice
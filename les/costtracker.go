@@ -19,6 +19,7 @@ package les
 import (
 	"encoding/binary"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -29,6 +30,7 @@ import (
 	"github.com/ong2020/go-orange/metrics"
 	"github.com/ong2020/go-orange/ong/ongconfig"
 	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/rlp"
 )
 
 const makeCostStats = false // make request cost statistics during operation
@@ -89,6 +91,12 @@ const (
 	gfRaiseTC        = time.Second * 200
 	gfDropTC         = time.Second * 50
 	gfDbKey          = "_globalCostFactorV6"
+
+	rcDbKey        = "relativeCostsV1" // Database key of the learned relative request costs
+	rcSampleWeight = 0.001             // Weight of a single request in the moving average of relative costs
+	rcMinSamples   = 1000              // Number of requests served before the cost of a type is adjusted
+	rcMinMul       = 0.5               // Minimum multiplier applied to the cost estimate of a request type
+	rcMaxMul       = 2                 // Maximum multiplier applied to the cost estimate of a request type
 )
 
 // costTracker is responsible for calculating costs and cost estimates on the
@@ -114,8 +122,16 @@ const (
 // applying the factor to the serving times. This is more convenient because the
 // changes in the cost factor can be applied immediately without always notifying
 // the clients about the changed cost tables.
+//
+// On top of the global factor, the tracker also learns the serving cost of each
+// request type relative to the others, which depends on the hardware and the
+// database of the server (e.g. proofs are much more expensive on a slow disk).
+// The cost estimates of the request types are periodically scaled accordingly,
+// within a limited range, and advertised to the newly connected clients. The
+// learned values are persisted in the server database across restarts.
 type costTracker struct {
 	db     ongdb.Database
+	lesDb  ongdb.Database
 	stopCh chan chan struct{}
 
 	inSizeFactor  float64
@@ -128,6 +144,9 @@ type costTracker struct {
 	reqInfoCh       chan reqInfo
 	totalRechargeCh chan uint64
 
+	relCosts    map[uint64]relativeCost // Last published relative request costs, protected by gfLock
+	multipliers map[uint64]float64      // Cost estimate multipliers per request type, protected by gfLock
+
 	stats map[uint64][]uint64 // Used for testing purpose.
 
 	// TestHooks
@@ -135,12 +154,14 @@ type costTracker struct {
 	testCostList RequestCostList // Customized cost table for testing purpose.
 }
 
-// newCostTracker creates a cost tracker and loads the cost factor statistics from the database.
-// It also returns the minimum capacity that can be assigned to any peer.
-func newCostTracker(db ongdb.Database, config *ongconfig.Config) (*costTracker, uint64) {
+// newCostTracker creates a cost tracker and loads the cost factor statistics from the database
+// and the learned relative request costs from the server database. It also returns the minimum
+// capacity that can be assigned to any peer.
+func newCostTracker(db, lesDb ongdb.Database, config *ongconfig.Config) (*costTracker, uint64) {
 	utilTarget := float64(config.LightServ) * flowcontrol.FixedPointMultiplier / 100
 	ct := &costTracker{
 		db:         db,
+		lesDb:      lesDb,
 		stopCh:     make(chan chan struct{}),
 		reqInfoCh:  make(chan reqInfo, 100),
 		utilTarget: utilTarget,
@@ -195,10 +216,18 @@ func (ct *costTracker) makeCostList(globalFactor float64) RequestCostList {
 		}
 		return cost
 	}
+	ct.gfLock.RLock()
+	multipliers := ct.multipliers
+	ct.gfLock.RUnlock()
+
 	var list RequestCostList
 	for code, data := range reqAvgTimeCost {
-		baseCost := maxCost(data.baseCost, reqMaxInSize[code].baseCost, reqMaxOutSize[code].baseCost)
-		reqCost := maxCost(data.reqCost, reqMaxInSize[code].reqCost, reqMaxOutSize[code].reqCost)
+		avgBaseCost, avgReqCost := data.baseCost, data.reqCost
+		if mul, ok := multipliers[code]; ok {
+			avgBaseCost, avgReqCost = uint64(float64(avgBaseCost)*mul), uint64(float64(avgReqCost)*mul)
+		}
+		baseCost := maxCost(avgBaseCost, reqMaxInSize[code].baseCost, reqMaxOutSize[code].baseCost)
+		reqCost := maxCost(avgReqCost, reqMaxInSize[code].reqCost, reqMaxOutSize[code].reqCost)
 		if ct.minBufLimit != 0 {
 			// if minBufLimit is set then always enforce maximum request cost <= minBufLimit
 			maxCost := baseCost + reqCost*minBufferReqAmount[code]
@@ -257,6 +286,10 @@ func (ct *costTracker) gfLoop() {
 	ct.factor = math.Exp(gfLog)
 	factor, totalRecharge = ct.factor, ct.utilTarget*ct.factor
 
+	// Load the learned relative request costs from the server database.
+	relCosts := ct.loadRelativeCosts()
+	ct.publishRelativeCosts(relCosts)
+
 	// In order to perform factor data statistics under the high request pressure,
 	// we only adjust factor when recent factor usage beyond the threshold.
 	threshold := gfUsageThreshold * float64(gfUsageTC) * ct.utilTarget / flowcontrol.FixedPointMultiplier
@@ -267,6 +300,9 @@ func (ct *costTracker) gfLoop() {
 			binary.BigEndian.PutUint64(data[:], math.Float64bits(gfLog))
 			ct.db.Put([]byte(gfDbKey), data[:])
 			log.Debug("global cost factor saved", "value", factor)
+
+			ct.publishRelativeCosts(relCosts)
+			ct.saveRelativeCosts(relCosts)
 		}
 		saveTicker := time.NewTicker(time.Minute * 10)
 		defer saveTicker.Stop()
@@ -312,6 +348,16 @@ func (ct *costTracker) gfLoop() {
 				requestEstimatedTimer.Update(time.Duration(r.avgTimeCost / factor))
 				relativeCostHistogram.Update(relCost)
 
+				// Update the moving average of the relative cost of the request type
+				rc := relCosts[r.msgCode]
+				if rc.Samples == 0 {
+					rc.Ratio = factor * r.servingTime / r.avgTimeCost
+				} else {
+					rc.Ratio += (factor*r.servingTime/r.avgTimeCost - rc.Ratio) * rcSampleWeight
+				}
+				rc.Samples++
+				relCosts[r.msgCode] = rc
+
 				now := mclock.Now()
 				dt := float64(now - expUpdate)
 				expUpdate = now
@@ -380,6 +426,138 @@ func (ct *costTracker) gfLoop() {
 	}()
 }
 
+// relativeCost is the moving average of the actual serving cost of a request
+// type relative to its estimate in the hardcoded cost table.
+type relativeCost struct {
+	Ratio   float64 // Actual cost per estimated cost unit
+	Samples uint64  // Number of requests served
+}
+
+// storedRelativeCost is the database representation of a relative request cost.
+type storedRelativeCost struct {
+	MsgCode uint64
+	Ratio   uint64 // IEEE 754 representation of the ratio
+	Samples uint64
+}
+
+// loadRelativeCosts loads the learned relative request costs from the database.
+func (ct *costTracker) loadRelativeCosts() map[uint64]relativeCost {
+	relCosts := make(map[uint64]relativeCost)
+	if ct.lesDb == nil {
+		return relCosts
+	}
+	data, _ := ct.lesDb.Get([]byte(rcDbKey))
+	if len(data) == 0 {
+		return relCosts
+	}
+	var stored []storedRelativeCost
+	if err := rlp.DecodeBytes(data, &stored); err != nil {
+		log.Warn("Failed to decode relative request costs", "err", err)
+		return relCosts
+	}
+	for _, rc := range stored {
+		if _, ok := reqAvgTimeCost[rc.MsgCode]; ok {
+			relCosts[rc.MsgCode] = relativeCost{Ratio: math.Float64frombits(rc.Ratio), Samples: rc.Samples}
+		}
+	}
+	return relCosts
+}
+
+// saveRelativeCosts persists the learned relative request costs in the database.
+func (ct *costTracker) saveRelativeCosts(relCosts map[uint64]relativeCost) {
+	if ct.lesDb == nil {
+		return
+	}
+	stored := make([]storedRelativeCost, 0, len(relCosts))
+	for code, rc := range relCosts {
+		stored = append(stored, storedRelativeCost{MsgCode: code, Ratio: math.Float64bits(rc.Ratio), Samples: rc.Samples})
+	}
+	data, err := rlp.EncodeToBytes(stored)
+	if err != nil {
+		log.Error("Failed to encode relative request costs", "err", err)
+		return
+	}
+	ct.lesDb.Put([]byte(rcDbKey), data)
+}
+
+// publishRelativeCosts recalculates the cost estimate multipliers of the request
+// types from their relative costs. The multipliers are normalized so that their
+// average is 1, leaving the overall cost scaling to the global cost factor.
+func (ct *costTracker) publishRelativeCosts(relCosts map[uint64]relativeCost) {
+	var (
+		sum   float64
+		count int
+	)
+	for _, rc := range relCosts {
+		if rc.Samples >= rcMinSamples && rc.Ratio > 0 {
+			sum += rc.Ratio
+			count++
+		}
+	}
+	multipliers := make(map[uint64]float64)
+	if count > 1 {
+		avg := sum / float64(count)
+		for code, rc := range relCosts {
+			if rc.Samples < rcMinSamples || rc.Ratio <= 0 {
+				continue
+			}
+			mul := rc.Ratio / avg
+			if mul < rcMinMul {
+				mul = rcMinMul
+			}
+			if mul > rcMaxMul {
+				mul = rcMaxMul
+			}
+			multipliers[code] = mul
+		}
+	}
+	published := make(map[uint64]relativeCost, len(relCosts))
+	for code, rc := range relCosts {
+		published[code] = rc
+	}
+	ct.gfLock.Lock()
+	ct.relCosts, ct.multipliers = published, multipliers
+	ct.gfLock.Unlock()
+}
+
+// costTableEntry is the current cost estimate of a request type, along with the
+// statistics it is derived from.
+type costTableEntry struct {
+	MsgCode    uint64
+	BaseCost   uint64
+	ReqCost    uint64
+	Multiplier float64
+	Ratio      float64
+	Samples    uint64
+}
+
+// costTable returns the cost estimates currently advertised to new clients,
+// along with the learned relative costs of the request types.
+func (ct *costTracker) costTable() []costTableEntry {
+	list := ct.makeCostList(ct.globalFactor())
+
+	ct.gfLock.RLock()
+	defer ct.gfLock.RUnlock()
+
+	entries := make([]costTableEntry, 0, len(list))
+	for _, item := range list {
+		mul, ok := ct.multipliers[item.MsgCode]
+		if !ok {
+			mul = 1
+		}
+		entries = append(entries, costTableEntry{
+			MsgCode:    item.MsgCode,
+			BaseCost:   item.BaseCost,
+			ReqCost:    item.ReqCost,
+			Multiplier: mul,
+			Ratio:      ct.relCosts[item.MsgCode].Ratio,
+			Samples:    ct.relCosts[item.MsgCode].Samples,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].MsgCode < entries[j].MsgCode })
+	return entries
+}
+
 // globalFactor returns the current value of the global cost factor
 func (ct *costTracker) globalFactor() float64 {
 	ct.gfLock.RLock()
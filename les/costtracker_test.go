@@ -0,0 +1,54 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"testing"
+
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/ong/ongconfig"
+)
+
+// Tests that the learned relative request costs scale the advertised cost
+// estimates and are persisted across restarts.
+func TestRelativeCostPersistence(t *testing.T) {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		config = &ongconfig.Config{LightServ: 100}
+	)
+	ct, _ := newCostTracker(db, db, config)
+	ct.stop()
+	ct.saveRelativeCosts(map[uint64]relativeCost{
+		GetBlockHeadersMsg: {Ratio: 1, Samples: rcMinSamples},
+		GetReceiptsMsg:     {Ratio: 3, Samples: rcMinSamples},
+		GetCodeMsg:         {Ratio: 100, Samples: rcMinSamples - 1},
+	})
+
+	ct, _ = newCostTracker(db, db, config)
+	defer ct.stop()
+
+	mul := make(map[uint64]float64)
+	for _, entry := range ct.costTable() {
+		mul[entry.MsgCode] = entry.Multiplier
+	}
+	if mul[GetBlockHeadersMsg] != 0.5 || mul[GetReceiptsMsg] != 1.5 {
+		t.Errorf("multiplier mismatch: headers %v, receipts %v", mul[GetBlockHeadersMsg], mul[GetReceiptsMsg])
+	}
+	if mul[GetCodeMsg] != 1 {
+		t.Errorf("multiplier applied without enough samples: %v", mul[GetCodeMsg])
+	}
+}
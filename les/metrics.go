@@ -108,6 +108,12 @@ var (
 	requestRTT       = metrics.NewRegisteredTimer("les/client/req/rtt", nil)
 	requestSendDelay = metrics.NewRegisteredTimer("les/client/req/sendDelay", nil)
 
+	proofBloatMeter     = metrics.NewRegisteredMeter("les/client/proof/bloated", nil)
+	proofBloatHistogram = metrics.NewRegisteredHistogram("les/client/proof/bloat", nil, metrics.NewExpDecaySample(1028, 0.015))
+
+	prefetchRequestMeter = metrics.NewRegisteredMeter("les/client/prefetch/requests", nil)
+	prefetchHitMeter     = metrics.NewRegisteredMeter("les/client/prefetch/hits", nil)
+
 	serverSelectableGauge = metrics.NewRegisteredGauge("les/client/serverPool/selectable", nil)
 	serverDialedMeter     = metrics.NewRegisteredMeter("les/client/serverPool/dialed", nil)
 	serverConnectedGauge  = metrics.NewRegisteredGauge("les/client/serverPool/connected", nil)
@@ -34,17 +34,22 @@ type LesOdr struct {
 	chtIndexer, bloomTrieIndexer, bloomIndexer *core.ChainIndexer
 	peers                                      *serverPeerSet
 	retriever                                  *retrieveManager
+	prefetcher                                 *odrPrefetcher
+	maxProofBloat                              float64 // Maximum size of the proofs over the minimal ones
 	stop                                       chan struct{}
 }
 
 func NewLesOdr(db ongdb.Database, config *light.IndexerConfig, peers *serverPeerSet, retriever *retrieveManager) *LesOdr {
-	return &LesOdr{
+	odr := &LesOdr{
 		db:            db,
 		indexerConfig: config,
 		peers:         peers,
 		retriever:     retriever,
+		maxProofBloat: 1,
 		stop:          make(chan struct{}),
 	}
+	odr.prefetcher = newOdrPrefetcher(odr)
+	return odr
 }
 
 // Stop cancels all pending retrievals
@@ -64,6 +69,16 @@ func (odr *LesOdr) SetIndexers(chtIndexer, bloomTrieIndexer, bloomIndexer *core.
 	odr.bloomIndexer = bloomIndexer
 }
 
+// SetMaxProofBloat sets the maximum size of the proofs accepted from the servers,
+// as a factor of the size of the nodes needed to verify them. Proofs padded with
+// more unneeded nodes are rejected. The default of 1 rejects any unneeded node.
+func (odr *LesOdr) SetMaxProofBloat(factor float64) {
+	if factor < 1 {
+		factor = 1
+	}
+	odr.maxProofBloat = factor
+}
+
 // ChtIndexer returns the CHT chain indexer
 func (odr *LesOdr) ChtIndexer() *core.ChainIndexer {
 	return odr.chtIndexer
@@ -168,7 +183,7 @@ func (odr *LesOdr) RetrieveTxStatus(ctx context.Context, req *light.TxStatusRequ
 				},
 			}
 		)
-		if err := odr.retriever.retrieve(ctx, id, distreq, func(p distPeer, msg *Msg) error { return req.Validate(odr.db, msg) }, odr.stop); err != nil {
+		if err := odr.retriever.retrieve(ctx, id, distreq, func(p distPeer, msg *Msg) error { return req.Validate(odr.db, msg, odr.maxProofBloat) }, odr.stop); err != nil {
 			return err
 		}
 		// Collect the response and assemble them to the final result.
@@ -197,7 +212,19 @@ func (odr *LesOdr) RetrieveTxStatus(ctx context.Context, req *light.TxStatusRequ
 // for most of the LES requests except for the TxStatusRequest which needs
 // the additional retry mechanism.
 // If the network retrieval was successful, it stores the object in local db.
-func (odr *LesOdr) Retrieve(ctx context.Context, req light.OdrRequest) (err error) {
+//
+// Sequential retrievals of block bodies and receipts are detected, prefetching
+// the subsequent ones in the background.
+func (odr *LesOdr) Retrieve(ctx context.Context, req light.OdrRequest) error {
+	odr.prefetcher.observe(req)
+	if odr.prefetcher.wait(ctx, req) {
+		return nil
+	}
+	return odr.retrieve(ctx, req)
+}
+
+// retrieve fetches an object from the LES network and stores it in local db.
+func (odr *LesOdr) retrieve(ctx context.Context, req light.OdrRequest) (err error) {
 	lreq := LesRequest(req)
 
 	reqID := genReqID()
@@ -227,7 +254,7 @@ func (odr *LesOdr) Retrieve(ctx context.Context, req light.OdrRequest) (err erro
 		requestRTT.Update(time.Duration(mclock.Now() - sent))
 	}(mclock.Now())
 
-	if err := odr.retriever.retrieve(ctx, reqID, rq, func(p distPeer, msg *Msg) error { return lreq.Validate(odr.db, msg) }, odr.stop); err != nil {
+	if err := odr.retriever.retrieve(ctx, reqID, rq, func(p distPeer, msg *Msg) error { return lreq.Validate(odr.db, msg, odr.maxProofBloat) }, odr.stop); err != nil {
 		return err
 	}
 	req.StoreResult(odr.db)
@@ -0,0 +1,226 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/light"
+	"github.com/ong2020/go-orange/log"
+)
+
+const (
+	prefetchTrigger  = 3                // Number of sequential accesses before prefetching kicks in
+	prefetchWindow   = 8                // Number of objects retrieved ahead of the last access
+	prefetchTimeout  = 10 * time.Second // Time allowance for a single prefetch request
+	prefetchInflight = 2 * prefetchWindow
+)
+
+// prefetchKind is the type of the chain objects being prefetched.
+type prefetchKind int
+
+const (
+	prefetchBodies prefetchKind = iota
+	prefetchReceipts
+	prefetchKinds
+)
+
+// accessPattern detects sequential (ascending or descending) accesses to the
+// chain objects of a given kind.
+type accessPattern struct {
+	last uint64 // Last block number accessed
+	dir  int    // Direction of the sequential run (+1/-1), 0 if none
+	run  int    // Number of accesses in the current sequential run
+}
+
+// observe records the access of the given block number, returning the direction
+// of the sequential run if it's long enough to prefetch subsequent blocks, or 0.
+func (p *accessPattern) observe(number uint64) int {
+	switch {
+	case p.run > 0 && p.dir == 1 && number == p.last+1, p.run > 0 && p.dir == -1 && number+1 == p.last:
+		p.run++
+	case p.run > 0 && number == p.last+1:
+		p.dir, p.run = 1, 2
+	case p.run > 0 && number+1 == p.last:
+		p.dir, p.run = -1, 2
+	case p.run > 0 && number == p.last:
+		// Repeated access of the same block, keep the current run
+	default:
+		p.dir, p.run = 0, 1
+	}
+	p.last = number
+	if p.run < prefetchTrigger {
+		return 0
+	}
+	return p.dir
+}
+
+// prefetchKey identifies a prefetched chain object.
+type prefetchKey struct {
+	kind prefetchKind
+	hash common.Hash
+}
+
+// prefetchTask is an in-flight prefetch request.
+type prefetchTask struct {
+	req  light.OdrRequest
+	err  error
+	done chan struct{}
+}
+
+// odrPrefetcher recognizes sequential access patterns of block bodies and
+// receipts (e.g. an application iterating over blocks or filtering logs) and
+// pipelines the retrieval of the subsequent objects, so they are already
+// available locally (or at least on their way) when requested.
+type odrPrefetcher struct {
+	odr      *LesOdr
+	patterns [prefetchKinds]accessPattern
+	inflight map[prefetchKey]*prefetchTask
+	lock     sync.Mutex
+}
+
+func newOdrPrefetcher(odr *LesOdr) *odrPrefetcher {
+	return &odrPrefetcher{
+		odr:      odr,
+		inflight: make(map[prefetchKey]*prefetchTask),
+	}
+}
+
+// requestKey returns the prefetch key and block number of the ODR requests
+// eligible for prefetching.
+func requestKey(req light.OdrRequest) (prefetchKey, uint64, bool) {
+	switch r := req.(type) {
+	case *light.BlockRequest:
+		return prefetchKey{prefetchBodies, r.Hash}, r.Number, true
+	case *light.ReceiptsRequest:
+		if r.Untrusted {
+			return prefetchKey{}, 0, false
+		}
+		return prefetchKey{prefetchReceipts, r.Hash}, r.Number, true
+	}
+	return prefetchKey{}, 0, false
+}
+
+// observe records an ODR request issued by the API and starts prefetching the
+// subsequent objects if a sequential access pattern is detected.
+func (pf *odrPrefetcher) observe(req light.OdrRequest) {
+	key, number, ok := requestKey(req)
+	if !ok {
+		return
+	}
+	pf.lock.Lock()
+	defer pf.lock.Unlock()
+
+	dir := pf.patterns[key.kind].observe(number)
+	if dir == 0 {
+		return
+	}
+	db := pf.odr.db
+	for i := 1; i <= prefetchWindow && len(pf.inflight) < prefetchInflight; i++ {
+		if dir < 0 && uint64(i) > number {
+			break
+		}
+		n := number + uint64(i*dir)
+
+		// Stop at the end of the locally known header chain
+		hash := rawdb.ReadCanonicalHash(db, n)
+		if hash == (common.Hash{}) {
+			break
+		}
+		key := prefetchKey{key.kind, hash}
+		if _, ok := pf.inflight[key]; ok {
+			continue
+		}
+		header := rawdb.ReadHeader(db, hash, n)
+		if header == nil {
+			break
+		}
+		var req light.OdrRequest
+		switch key.kind {
+		case prefetchBodies:
+			if rawdb.HasBody(db, hash, n) {
+				continue
+			}
+			req = &light.BlockRequest{Hash: hash, Number: n, Header: header}
+		case prefetchReceipts:
+			if rawdb.HasReceipts(db, hash, n) {
+				continue
+			}
+			req = &light.ReceiptsRequest{Hash: hash, Number: n, Header: header}
+		}
+		task := &prefetchTask{req: req, done: make(chan struct{})}
+		pf.inflight[key] = task
+		prefetchRequestMeter.Mark(1)
+
+		go pf.prefetch(key, task)
+	}
+}
+
+// prefetch retrieves a single chain object in the background.
+func (pf *odrPrefetcher) prefetch(key prefetchKey, task *prefetchTask) {
+	ctx, cancel := context.WithTimeout(context.Background(), prefetchTimeout)
+	defer cancel()
+
+	task.err = pf.odr.retrieve(ctx, task.req)
+	if task.err != nil {
+		log.Debug("Failed to prefetch chain object", "kind", key.kind, "hash", key.hash, "err", task.err)
+	}
+	pf.lock.Lock()
+	delete(pf.inflight, key)
+	pf.lock.Unlock()
+
+	close(task.done)
+}
+
+// wait checks whether the object requested by the API is being prefetched. If
+// so, it waits for the prefetch request to finish and fills the results into
+// the API request, returning whether it was successfully served.
+func (pf *odrPrefetcher) wait(ctx context.Context, req light.OdrRequest) bool {
+	key, _, ok := requestKey(req)
+	if !ok {
+		return false
+	}
+	pf.lock.Lock()
+	task := pf.inflight[key]
+	pf.lock.Unlock()
+
+	if task == nil {
+		return false
+	}
+	select {
+	case <-task.done:
+	case <-ctx.Done():
+		return false
+	case <-pf.odr.stop:
+		return false
+	}
+	if task.err != nil {
+		return false
+	}
+	switch r := req.(type) {
+	case *light.BlockRequest:
+		r.Rlp = task.req.(*light.BlockRequest).Rlp
+	case *light.ReceiptsRequest:
+		r.Receipts = task.req.(*light.ReceiptsRequest).Receipts
+	}
+	prefetchHitMeter.Mark(1)
+	return true
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import "testing"
+
+// Tests that sequential access patterns are detected in both directions, and
+// that random accesses don't trigger prefetching.
+func TestAccessPattern(t *testing.T) {
+	tests := []struct {
+		numbers []uint64
+		dir     int
+	}{
+		{[]uint64{10}, 0},
+		{[]uint64{10, 11}, 0},
+		{[]uint64{10, 11, 12}, 1},
+		{[]uint64{10, 11, 11, 12}, 1},
+		{[]uint64{12, 11, 10}, -1},
+		{[]uint64{10, 11, 12, 11}, 0},
+		{[]uint64{10, 11, 12, 20}, 0},
+		{[]uint64{10, 20, 30, 40}, 0},
+		{[]uint64{2, 1, 0}, -1},
+	}
+	for i, tt := range tests {
+		var (
+			p   accessPattern
+			dir int
+		)
+		for _, number := range tt.numbers {
+			dir = p.observe(number)
+		}
+		if dir != tt.dir {
+			t.Errorf("test %d: direction mismatch: have %d, want %d", i, dir, tt.dir)
+		}
+	}
+}
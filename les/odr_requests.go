@@ -49,7 +49,7 @@ type LesOdrRequest interface {
 	GetCost(*serverPeer) uint64
 	CanSend(*serverPeer) bool
 	Request(uint64, *serverPeer) error
-	Validate(ongdb.Database, *Msg) error
+	Validate(db ongdb.Database, msg *Msg, maxBloat float64) error
 }
 
 func LesRequest(req light.OdrRequest) LesOdrRequest {
@@ -96,7 +96,7 @@ func (r *BlockRequest) Request(reqID uint64, peer *serverPeer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *BlockRequest) Validate(db ongdb.Database, msg *Msg) error {
+func (r *BlockRequest) Validate(db ongdb.Database, msg *Msg, maxBloat float64) error {
 	log.Debug("Validating block body", "hash", r.Hash)
 
 	// Ensure we have a correct message with a single block body
@@ -154,7 +154,7 @@ func (r *ReceiptsRequest) Request(reqID uint64, peer *serverPeer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *ReceiptsRequest) Validate(db ongdb.Database, msg *Msg) error {
+func (r *ReceiptsRequest) Validate(db ongdb.Database, msg *Msg, maxBloat float64) error {
 	log.Debug("Validating block receipts", "hash", r.Hash)
 
 	// Ensure we have a correct message with a single block receipt
@@ -216,7 +216,7 @@ func (r *TrieRequest) Request(reqID uint64, peer *serverPeer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *TrieRequest) Validate(db ongdb.Database, msg *Msg) error {
+func (r *TrieRequest) Validate(db ongdb.Database, msg *Msg, maxBloat float64) error {
 	log.Debug("Validating trie proof", "root", r.Id.Root, "key", r.Key)
 
 	if msg.MsgType != MsgProofsV2 {
@@ -225,13 +225,13 @@ func (r *TrieRequest) Validate(db ongdb.Database, msg *Msg) error {
 	proofs := msg.Obj.(light.NodeList)
 	// Verify the proof and store if checks out
 	nodeSet := proofs.NodeSet()
-	reads := &readTraceDB{db: nodeSet}
+	reads := trie.NewKeyValueNotary(nodeSet)
 	if _, err := trie.VerifyProof(r.Id.Root, r.Key, reads); err != nil {
 		return fmt.Errorf("merkle proof verification failed: %v", err)
 	}
-	// check if all nodes have been read by VerifyProof
-	if len(reads.reads) != nodeSet.KeyCount() {
-		return errUselessNodes
+	// check that the nodes not read by VerifyProof don't bloat the proof
+	if err := checkProofBloat(nodeSet, reads, maxBloat); err != nil {
+		return err
 	}
 	r.Proof = nodeSet
 	return nil
@@ -269,7 +269,7 @@ func (r *CodeRequest) Request(reqID uint64, peer *serverPeer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *CodeRequest) Validate(db ongdb.Database, msg *Msg) error {
+func (r *CodeRequest) Validate(db ongdb.Database, msg *Msg, maxBloat float64) error {
 	log.Debug("Validating code data", "hash", r.Hash)
 
 	// Ensure we have a correct message with a single code element
@@ -346,7 +346,7 @@ func (r *ChtRequest) Request(reqID uint64, peer *serverPeer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *ChtRequest) Validate(db ongdb.Database, msg *Msg) error {
+func (r *ChtRequest) Validate(db ongdb.Database, msg *Msg, maxBloat float64) error {
 	log.Debug("Validating CHT", "cht", r.ChtNum, "block", r.BlockNum)
 
 	if msg.MsgType != MsgHelperTrieProofs {
@@ -372,13 +372,13 @@ func (r *ChtRequest) Validate(db ongdb.Database, msg *Msg) error {
 	)
 	binary.BigEndian.PutUint64(encNumber[:], r.BlockNum)
 
-	reads := &readTraceDB{db: nodeSet}
+	reads := trie.NewKeyValueNotary(nodeSet)
 	value, err := trie.VerifyProof(r.ChtRoot, encNumber[:], reads)
 	if err != nil {
 		return fmt.Errorf("merkle proof verification failed: %v", err)
 	}
-	if len(reads.reads) != nodeSet.KeyCount() {
-		return errUselessNodes
+	if err := checkProofBloat(nodeSet, reads, maxBloat); err != nil {
+		return err
 	}
 	if err := rlp.DecodeBytes(value, &node); err != nil {
 		return err
@@ -442,7 +442,7 @@ func (r *BloomRequest) Request(reqID uint64, peer *serverPeer) error {
 // Valid processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *BloomRequest) Validate(db ongdb.Database, msg *Msg) error {
+func (r *BloomRequest) Validate(db ongdb.Database, msg *Msg, maxBloat float64) error {
 	log.Debug("Validating BloomBits", "bloomTrie", r.BloomTrieNum, "bitIdx", r.BitIdx, "sections", r.SectionIndexList)
 
 	// Ensure we have a correct message with a single proof element
@@ -452,7 +452,7 @@ func (r *BloomRequest) Validate(db ongdb.Database, msg *Msg) error {
 	resps := msg.Obj.(HelperTrieResps)
 	proofs := resps.Proofs
 	nodeSet := proofs.NodeSet()
-	reads := &readTraceDB{db: nodeSet}
+	reads := trie.NewKeyValueNotary(nodeSet)
 
 	r.BloomBits = make([][]byte, len(r.SectionIndexList))
 
@@ -469,8 +469,8 @@ func (r *BloomRequest) Validate(db ongdb.Database, msg *Msg) error {
 		r.BloomBits[i] = value
 	}
 
-	if len(reads.reads) != nodeSet.KeyCount() {
-		return errUselessNodes
+	if err := checkProofBloat(nodeSet, reads, maxBloat); err != nil {
+		return err
 	}
 	r.Proofs = nodeSet
 	return nil
@@ -499,7 +499,7 @@ func (r *TxStatusRequest) Request(reqID uint64, peer *serverPeer) error {
 // Validate processes an ODR request reply message from the LES network
 // returns true and stores results in memory if the message was a valid reply
 // to the request (implementation of LesOdrRequest)
-func (r *TxStatusRequest) Validate(db ongdb.Database, msg *Msg) error {
+func (r *TxStatusRequest) Validate(db ongdb.Database, msg *Msg, maxBloat float64) error {
 	log.Debug("Validating transaction status", "count", len(r.Hashes))
 
 	if msg.MsgType != MsgTxStatus {
@@ -513,24 +513,32 @@ func (r *TxStatusRequest) Validate(db ongdb.Database, msg *Msg) error {
 	return nil
 }
 
-// readTraceDB stores the keys of database reads. We use this to check that received node
-// sets contain only the trie nodes necessary to make proofs pass.
-type readTraceDB struct {
-	db    ongdb.KeyValueReader
-	reads map[string]struct{}
-}
-
-// Get returns a stored node
-func (db *readTraceDB) Get(k []byte) ([]byte, error) {
-	if db.reads == nil {
-		db.reads = make(map[string]struct{})
+// checkProofBloat ensures that the nodes of a proof are not padded over maxBloat
+// times the size of the nodes read verifying it, protecting the client from
+// servers bloating their responses. The bloat of every proof is recorded.
+func checkProofBloat(nodes *light.NodeSet, notary *trie.KeyValueNotary, maxBloat float64) error {
+	var (
+		size   = nodes.DataSize()
+		needed int
+	)
+	it := notary.Accessed().NewIterator(nil, nil)
+	for it.Next() {
+		needed += len(it.Value())
 	}
-	db.reads[string(k)] = struct{}{}
-	return db.db.Get(k)
-}
+	it.Release()
 
-// Has returns true if the node set contains the given key
-func (db *readTraceDB) Has(key []byte) (bool, error) {
-	_, err := db.Get(key)
-	return err == nil, nil
+	if needed == 0 {
+		if size == 0 {
+			return nil
+		}
+		proofBloatMeter.Mark(1)
+		return errUselessNodes
+	}
+	bloat := float64(size) / float64(needed)
+	proofBloatHistogram.Update(int64(bloat * 100))
+	if bloat > maxBloat {
+		proofBloatMeter.Mark(1)
+		return fmt.Errorf("%w: %d bytes, %d needed", errUselessNodes, size, needed)
+	}
+	return nil
 }
@@ -0,0 +1,69 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/light"
+	"github.com/ong2020/go-orange/trie"
+)
+
+// Tests that trie proofs padded with unneeded nodes are rejected over the
+// configured bloat factor.
+func TestProofBloat(t *testing.T) {
+	tr, _ := trie.New(common.Hash{}, trie.NewDatabase(rawdb.NewMemoryDatabase()))
+	for i := byte(0); i < 100; i++ {
+		key := crypto.Keccak256([]byte{i})
+		tr.Update(key, key)
+	}
+	var (
+		root  = tr.Hash()
+		key   = crypto.Keccak256([]byte{0})
+		proof = light.NewNodeSet()
+	)
+	if err := tr.Prove(key, 0, proof); err != nil {
+		t.Fatalf("failed to prove key: %v", err)
+	}
+	request := func() *TrieRequest {
+		return &TrieRequest{Id: &light.TrieID{Root: root}, Key: key}
+	}
+	msg := &Msg{MsgType: MsgProofsV2, Obj: proof.NodeList()}
+	if err := request().Validate(nil, msg, 1); err != nil {
+		t.Fatalf("minimal proof rejected: %v", err)
+	}
+	// Pad the proof with the nodes proving another key
+	padded := light.NewNodeSet()
+	proof.Store(padded)
+	if err := tr.Prove(crypto.Keccak256([]byte{1}), 0, padded); err != nil {
+		t.Fatalf("failed to prove key: %v", err)
+	}
+	if padded.KeyCount() == proof.KeyCount() {
+		t.Fatalf("proof not padded")
+	}
+	msg = &Msg{MsgType: MsgProofsV2, Obj: padded.NodeList()}
+	if err := request().Validate(nil, msg, 1); !errors.Is(err, errUselessNodes) {
+		t.Errorf("padded proof error mismatch: have %v, want %v", err, errUselessNodes)
+	}
+	if err := request().Validate(nil, msg, 3); err != nil {
+		t.Errorf("padded proof rejected within the bloat factor: %v", err)
+	}
+}
@@ -127,7 +127,7 @@ func NewLesServer(node *node.Node, e ongBackend, config *ongconfig.Config) (*Les
 		issync = func() bool { return true }
 	}
 	srv.handler = newServerHandler(srv, e.BlockChain(), e.ChainDb(), e.TxPool(), issync)
-	srv.costTracker, srv.minCapacity = newCostTracker(e.ChainDb(), config)
+	srv.costTracker, srv.minCapacity = newCostTracker(e.ChainDb(), lesDb, config)
 	srv.oracle = srv.setupOracle(node, e.BlockChain().Genesis().Hash(), config)
 
 	// Initialize the bloom trie indexer.
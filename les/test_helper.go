@@ -305,7 +305,7 @@ func newTestServerHandler(blocks int, indexers []*core.ChainIndexer, db ongdb.Da
 		},
 		fcManager: flowcontrol.NewClientManager(nil, clock),
 	}
-	server.costTracker, server.minCapacity = newCostTracker(db, server.config)
+	server.costTracker, server.minCapacity = newCostTracker(db, db, server.config)
 	server.costTracker.testCostList = testCostList(0) // Disable flow control mechanism.
 	server.clientPool = newClientPool(ns, db, testBufRecharge, defaultConnectedBias, clock, func(id enode.ID) {})
 	server.clientPool.setLimits(10000, 10000) // Assign enough capacity for clientpool
@@ -18,7 +18,6 @@ package light
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
 	"github.com/ong2020/go-orange/common"
@@ -147,8 +146,19 @@ func (t *odrTrie) GetKey(sha []byte) []byte {
 	return nil
 }
 
+// Prove constructs a merkle proof for key, retrieving any trie nodes missing
+// from the local database on the way. The key is expected to be hashed already,
+// in line with the secure trie used by full nodes.
 func (t *odrTrie) Prove(key []byte, fromLevel uint, proofDb ongdb.KeyValueWriter) error {
-	return errors.New("not implemented, needs client/server interface split")
+	return t.do(key, func() error {
+		// Resolve the whole path first so that missing nodes are reported by
+		// the lookup and fetched on demand, and the proof is only written once
+		// every node on the path is available.
+		if _, err := t.trie.TryGet(key); err != nil {
+			return err
+		}
+		return t.trie.Prove(key, fromLevel, proofDb)
+	})
 }
 
 // do tries and retries to execute a function until it returns with no error or
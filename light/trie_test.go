@@ -23,11 +23,14 @@ import (
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/consensus/ongash"
 	"github.com/ong2020/go-orange/core"
 	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/core/state"
 	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/ongdb/memorydb"
 	"github.com/ong2020/go-orange/params"
 	"github.com/ong2020/go-orange/trie"
 )
@@ -56,6 +59,53 @@ func TestNodeIterator(t *testing.T) {
 	}
 }
 
+func TestProof(t *testing.T) {
+	var (
+		fulldb  = rawdb.NewMemoryDatabase()
+		lightdb = rawdb.NewMemoryDatabase()
+		gspec   = core.Genesis{Alloc: core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}}}
+		genesis = gspec.MustCommit(fulldb)
+	)
+	gspec.MustCommit(lightdb)
+	blockchain, _ := core.NewBlockChain(fulldb, nil, params.TestChainConfig, ongash.NewFullFaker(), vm.Config{}, nil, nil)
+	gchain, _ := core.GenerateChain(params.TestChainConfig, genesis, ongash.NewFaker(), fulldb, 4, testChainGen)
+	if _, err := blockchain.InsertChain(gchain); err != nil {
+		panic(err)
+	}
+
+	ctx := context.Background()
+	odr := &testOdr{sdb: fulldb, ldb: lightdb, indexerConfig: TestClientIndexerConfig}
+	head := blockchain.CurrentHeader()
+	lightTrie, _ := NewStateDatabase(ctx, head, odr).OpenTrie(head.Root)
+	fullTrie, _ := state.NewDatabase(fulldb).OpenTrie(head.Root)
+
+	for _, addr := range []common.Address{testBankAddress, acc1Addr, acc2Addr, testContractAddr, common.HexToAddress("1234567812345678123456781234567812345678")} {
+		key := crypto.Keccak256(addr.Bytes())
+
+		fullProof, lightProof := memorydb.New(), memorydb.New()
+		if err := fullTrie.Prove(key, 0, fullProof); err != nil {
+			t.Fatalf("full proof for %x failed: %v", addr, err)
+		}
+		if err := lightTrie.Prove(key, 0, lightProof); err != nil {
+			t.Fatalf("light proof for %x failed: %v", addr, err)
+		}
+		if fullProof.Len() != lightProof.Len() {
+			t.Fatalf("proof size mismatch for %x: have %d, want %d", addr, lightProof.Len(), fullProof.Len())
+		}
+		want, err := trie.VerifyProof(head.Root, key, fullProof)
+		if err != nil {
+			t.Fatalf("full proof for %x invalid: %v", addr, err)
+		}
+		have, err := trie.VerifyProof(head.Root, key, lightProof)
+		if err != nil {
+			t.Fatalf("light proof for %x invalid: %v", addr, err)
+		}
+		if !bytes.Equal(have, want) {
+			t.Fatalf("proven value mismatch for %x: have %x, want %x", addr, have, want)
+		}
+	}
+}
+
 func diffTries(t1, t2 state.Trie) error {
 	i1 := trie.NewIterator(t1.NodeIterator(nil))
 	i2 := trie.NewIterator(t2.NodeIterator(nil))
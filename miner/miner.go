@@ -50,6 +50,12 @@ type Config struct {
 	GasPrice  *big.Int       // Minimum gas price for mining a transaction
 	Recommit  time.Duration  // The time interval for miner to re-create mining work.
 	Noverify  bool           // Disable remote mining solution verification(only useful in ongash).
+
+	MaxTxs       int           `toml:",omitempty"` // Maximum number of transactions included in a block (0 = unlimited)
+	FillDeadline time.Duration `toml:",omitempty"` // Maximum time spent filling a block with transactions (0 = unlimited)
+
+	Deterministic bool   `toml:",omitempty"` // Build the blocks deterministically: seeded ordering, parent based timestamps, no recommits
+	Seed          uint64 `toml:",omitempty"` // Seed ordering the same priced transactions in deterministic mode
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -179,6 +185,18 @@ func (miner *Miner) SetRecommitInterval(interval time.Duration) {
 	miner.worker.setRecommitInterval(interval)
 }
 
+// SetMaxTxs sets the maximum number of transactions included in a block, 0
+// meaning no limit.
+func (miner *Miner) SetMaxTxs(max int) {
+	miner.worker.setMaxTxs(max)
+}
+
+// SetFillDeadline sets the maximum time spent filling a block with transactions,
+// 0 meaning no limit.
+func (miner *Miner) SetFillDeadline(deadline time.Duration) {
+	miner.worker.setFillDeadline(deadline)
+}
+
 // Pending returns the currently pending block and associated state.
 func (miner *Miner) Pending() (*types.Block, *state.StateDB) {
 	return miner.worker.pending()
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"errors"
 	"math/big"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -87,6 +88,8 @@ type environment struct {
 	uncles    mapset.Set     // uncle set
 	tcount    int            // tx count in cycle
 	gasPool   *core.GasPool  // available gas used to pack transactions
+	maxTxs    int            // maximum number of transactions to pack (0 = unlimited)
+	deadline  time.Time      // time after which no more transactions are packed (zero = none)
 
 	header   *types.Header
 	txs      []*types.Transaction
@@ -155,9 +158,11 @@ type worker struct {
 	remoteUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
 	unconfirmed  *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
 
-	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
-	coinbase common.Address
-	extra    []byte
+	mu           sync.RWMutex // The lock used to protect the coinbase, extra and block filling limits
+	coinbase     common.Address
+	extra        []byte
+	maxTxs       int           // Maximum number of transactions included in a block
+	fillDeadline time.Duration // Maximum time spent filling a block with transactions
 
 	pendingMu    sync.RWMutex
 	pendingTasks map[common.Hash]*task
@@ -196,6 +201,8 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		mux:                mux,
 		chain:              ong.BlockChain(),
 		isLocalBlock:       isLocalBlock,
+		maxTxs:             config.MaxTxs,
+		fillDeadline:       config.FillDeadline,
 		localUncles:        make(map[common.Hash]*types.Block),
 		remoteUncles:       make(map[common.Hash]*types.Block),
 		unconfirmed:        newUnconfirmedBlocks(ong.BlockChain(), miningLogAtDepth),
@@ -250,6 +257,20 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+// setMaxTxs sets the maximum number of transactions included in a block.
+func (w *worker) setMaxTxs(max int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.maxTxs = max
+}
+
+// setFillDeadline sets the maximum time spent filling a block with transactions.
+func (w *worker) setFillDeadline(deadline time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fillDeadline = deadline
+}
+
 // setRecommitInterval updates the interval for miner sealing work recommitting.
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	w.resubmitIntervalCh <- interval
@@ -383,8 +404,9 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 
 		case <-timer.C:
 			// If mining is running resubmit a new work cycle periodically to pull in
-			// higher priced transactions. Disable this overhead for pending blocks.
-			if w.isRunning() && (w.chainConfig.Clique == nil || w.chainConfig.Clique.Period > 0) {
+			// higher priced transactions. Disable this overhead for pending blocks,
+			// and in deterministic mode as it makes the block depend on timing.
+			if w.isRunning() && !w.config.Deterministic && (w.chainConfig.Clique == nil || w.chainConfig.Clique.Period > 0) {
 				// Short circuit if no new transaction arrives.
 				if atomic.LoadInt32(&w.newTxs) == 0 {
 					timer.Reset(recommit)
@@ -480,6 +502,12 @@ func (w *worker) mainLoop() {
 			}
 
 		case ev := <-w.txsCh:
+			// In deterministic mode the work is only rebuilt on new heads, as
+			// updating it on new transactions makes the block depend on their
+			// arrival.
+			if w.config.Deterministic {
+				continue
+			}
 			// Apply transactions to the pending state if we're not mining.
 			//
 			// Note all transactions received may not be continuous with transactions
@@ -499,7 +527,7 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
+				txset := w.newTxSet(txs)
 				tcount := w.current.tcount
 				w.commitTransactions(txset, coinbase, nil)
 				// Only update the snapshot if any new transactons were added
@@ -785,6 +813,16 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			log.Trace("Not enough gas for further transactions", "have", w.current.gasPool, "want", params.TxGas)
 			break
 		}
+		// If the block reached its transaction count limit or the time allowed for
+		// filling it is up, seal it with what was included so far
+		if w.current.maxTxs > 0 && w.current.tcount >= w.current.maxTxs {
+			log.Trace("Transaction count limit reached", "count", w.current.tcount)
+			break
+		}
+		if !w.current.deadline.IsZero() && time.Now().After(w.current.deadline) {
+			log.Debug("Block fill deadline reached", "number", w.current.header.Number, "txs", w.current.tcount)
+			break
+		}
 		// Retrieve the next transaction and abort if all done
 		tx := txs.Peek()
 		if tx == nil {
@@ -803,6 +841,14 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			txs.Pop()
 			continue
 		}
+		// Skip the transactions breaking the block rules of the chain, the pool
+		// may still hold them if the rules activate with this block
+		if err := w.chain.CheckBlockRules(tx, w.current.header.Number, w.current.header.Time); err != nil {
+			log.Trace("Skipping transaction breaking block rules", "hash", tx.Hash(), "err", err)
+
+			txs.Pop()
+			continue
+		}
 		// Start executing the transaction
 		w.current.state.Prepare(tx.Hash(), common.Hash{}, w.current.tcount)
 
@@ -829,6 +875,11 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			w.current.tcount++
 			txs.Shift()
 
+		case errors.Is(err, core.ErrGasPriceBelowMinimum):
+			// Pop the account, its next transactions depend on the rejected one
+			log.Trace("Skipping transaction below minimum gas price", "sender", from, "price", tx.GasPrice())
+			txs.Pop()
+
 		case errors.Is(err, core.ErrTxTypeNotSupported):
 			// Pop the unsupported transaction without shifting in the next from the account
 			log.Trace("Skipping unsupported transaction type", "sender", from, "type", tx.Type())
@@ -873,7 +924,11 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	tstart := time.Now()
 	parent := w.chain.CurrentBlock()
 
-	if parent.Time() >= uint64(timestamp) {
+	if w.config.Deterministic {
+		// Derive the timestamp from the parent, as the wall clock would make the
+		// block depend on when it was built.
+		timestamp = int64(parent.Time() + w.blockPeriod())
+	} else if parent.Time() >= uint64(timestamp) {
 		timestamp = int64(parent.Time() + 1)
 	}
 	num := parent.Number()
@@ -929,10 +984,11 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 				delete(blocks, hash)
 			}
 		}
-		for hash, uncle := range blocks {
+		for _, hash := range w.uncleOrder(blocks) {
 			if len(uncles) == 2 {
 				break
 			}
+			uncle := blocks[hash]
 			if err := w.commitUncle(env, uncle.Header()); err != nil {
 				log.Trace("Possible uncle rejected", "hash", hash, "reason", err)
 			} else {
@@ -951,7 +1007,12 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.commit(uncles, nil, false, tstart)
 	}
 
-	// Fill the block with all available pending transactions.
+	// Fill the block with all available pending transactions, within the
+	// configured limits.
+	env.maxTxs = w.maxTxs
+	if w.fillDeadline > 0 && !w.config.Deterministic {
+		env.deadline = time.Now().Add(w.fillDeadline)
+	}
 	pending, err := w.ong.TxPool().Pending()
 	if err != nil {
 		log.Error("Failed to fetch pending transactions", "err", err)
@@ -973,13 +1034,13 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		}
 	}
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
+		txs := w.newTxSet(localTxs)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
+		txs := w.newTxSet(remoteTxs)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
@@ -987,6 +1048,37 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	w.commit(uncles, w.fullTaskHook, true, tstart)
 }
 
+// newTxSet orders pending transactions for inclusion in the current block, by
+// price and then by arrival time, or by seed in deterministic mode.
+func (w *worker) newTxSet(txs map[common.Address]types.Transactions) *types.TransactionsByPriceAndNonce {
+	if w.config.Deterministic {
+		return types.NewTransactionsByPriceAndSeed(w.current.signer, txs, w.config.Seed)
+	}
+	return types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
+}
+
+// blockPeriod returns the number of seconds between a block and its parent in
+// deterministic mode: the clique period if set, one second otherwise.
+func (w *worker) blockPeriod() uint64 {
+	if w.chainConfig.Clique != nil && w.chainConfig.Clique.Period > 0 {
+		return w.chainConfig.Clique.Period
+	}
+	return 1
+}
+
+// uncleOrder returns the hashes of the possible uncles in the order they are
+// tried, sorted in deterministic mode.
+func (w *worker) uncleOrder(blocks map[common.Hash]*types.Block) []common.Hash {
+	hashes := make([]common.Hash, 0, len(blocks))
+	for hash := range blocks {
+		hashes = append(hashes, hash)
+	}
+	if w.config.Deterministic {
+		sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+	}
+	return hashes
+}
+
 // commit runs any post-transaction state modifications, assembles the final block
 // and commits new work if consensus engine is running.
 func (w *worker) commit(uncles []*types.Header, interval func(), update bool, start time.Time) error {
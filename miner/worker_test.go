@@ -312,6 +312,110 @@ func testEmptyWork(t *testing.T, chainConfig *params.ChainConfig, engine consens
 	}
 }
 
+func TestFillLimits(t *testing.T) {
+	t.Run("maxtxs", func(t *testing.T) {
+		testFillLimits(t, func(w *worker) { w.setMaxTxs(1) }, 1)
+	})
+	t.Run("deadline", func(t *testing.T) {
+		testFillLimits(t, func(w *worker) { w.setFillDeadline(time.Nanosecond) }, 0)
+	})
+	t.Run("unlimited", func(t *testing.T) {
+		testFillLimits(t, func(w *worker) {}, 2)
+	})
+	t.Run("deterministic", func(t *testing.T) {
+		testFillLimits(t, func(w *worker) {
+			config := *w.config
+			config.Deterministic = true
+			w.config = &config
+			w.setFillDeadline(time.Nanosecond)
+		}, 2)
+	})
+}
+
+func testFillLimits(t *testing.T, limit func(w *worker), want int) {
+	engine := ongash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, ongashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	b.txPool.AddLocals(newTxs)
+	limit(w)
+
+	// Disable the empty pre-sealed block, only collect the filled ones
+	w.disablePreseal()
+
+	taskCh := make(chan *task, 1)
+	w.newTaskHook = func(task *task) {
+		select {
+		case taskCh <- task:
+		default:
+		}
+	}
+	w.skipSealHook = func(task *task) bool { return true }
+	w.start()
+
+	var full *task
+	select {
+	case full = <-taskCh:
+	case <-time.After(3 * time.Second):
+		t.Fatal("new task timeout")
+	}
+	if len(full.receipts) != want {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(full.receipts), want)
+	}
+}
+
+func TestDeterministicWork(t *testing.T) {
+	t.Run("noperiod", func(t *testing.T) { testDeterministicWork(t, 0, 1) })
+	t.Run("period", func(t *testing.T) { testDeterministicWork(t, 5, 5) })
+}
+
+func testDeterministicWork(t *testing.T, period uint64, gap uint64) {
+	engine := ongash.NewFaker()
+	defer engine.Close()
+
+	chainConfig := *params.TestChainConfig
+	chainConfig.Clique = nil
+	if period > 0 {
+		chainConfig.Clique = &params.CliqueConfig{Period: period, Epoch: 30000}
+	}
+	w, b := newTestWorker(t, &chainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	config := *w.config
+	config.Deterministic = true
+	w.config = &config
+	w.disablePreseal()
+
+	taskCh := make(chan *task, 2)
+	w.newTaskHook = func(task *task) {
+		select {
+		case taskCh <- task:
+		default:
+		}
+	}
+	w.skipSealHook = func(task *task) bool { return true }
+	w.start()
+
+	select {
+	case task := <-taskCh:
+		parent := b.chain.CurrentBlock()
+		if have, want := task.block.Time(), parent.Time()+gap; have != want {
+			t.Fatalf("timestamp mismatch: have %d, want %d", have, want)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("new task timeout")
+	}
+	// New transactions must not resubmit the work
+	b.txPool.AddLocals(newTxs)
+	select {
+	case task := <-taskCh:
+		t.Fatalf("work resubmitted on new transactions: block %d with %d txs", task.block.NumberU64(), len(task.receipts))
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
 func TestStreamUncleBlock(t *testing.T) {
 	ongash := ongash.NewFaker()
 	defer ongash.Close()
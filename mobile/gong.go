@@ -67,7 +67,9 @@ type NodeConfig struct {
 	// OrangeNetStats is a netstats connection string to use to report various
 	// chain, transaction and node stats to a monitoring server.
 	//
-	// It has the form "nodename:secret@host:port"
+	// It has the form "nodename:secret@host:port". Several monitoring servers
+	// can be reported to simultaneously by separating them with commas, while
+	// failover servers for the same target are separated with pipes.
 	OrangeNetStats string
 
 	// Listening address of pprof server.
@@ -280,6 +280,43 @@ func (api *privateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// ReloadConfig re-reads the config file of the node and applies the changes of
+// the settings adjustable at runtime, reporting the applied and rejected ones.
+func (api *privateAdminAPI) ReloadConfig() (*ConfigReloadResult, error) {
+	return api.node.ReloadConfig()
+}
+
+// CheckConnectivity runs a self-test of the inbound reachability of the p2p
+// listener and discovery ports, probing a few connected peers for the endpoint
+// they observe, to help diagnose nodes which only have outbound peers.
+func (api *privateAdminAPI) CheckConnectivity(ctx context.Context) (*p2p.ConnectivityReport, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.CheckConnectivity(ctx), nil
+}
+
+// Forensics lists the evidence captured of peers dropped for protocol violations,
+// without the captured messages.
+func (api *privateAdminAPI) Forensics() ([]*p2p.ForensicRecord, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.ForensicRecords()
+}
+
+// ForensicRecord retrieves the evidence captured of a peer dropped for a protocol
+// violation, including the raw handshake and offending messages.
+func (api *privateAdminAPI) ForensicRecord(id string) (*p2p.ForensicRecord, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.ForensicRecord(id)
+}
+
 // publicAdminAPI is the collection of administrative API Methods exposed over
 // both secure and unsecure RPC channels.
 type publicAdminAPI struct {
@@ -306,6 +343,38 @@ func (api *publicAdminAPI) NodeInfo() (*p2p.NodeInfo, error) {
 	return server.NodeInfo(), nil
 }
 
+// DiscoveryTable retrieves the nodes currently known by the discovery protocols
+// running on the node.
+func (api *publicAdminAPI) DiscoveryTable() (*p2p.DiscoveryTableInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.DiscoveryTable(), nil
+}
+
+// HandshakeFailures retrieves the most recent failed handshakes with remote
+// nodes along with their reasons (e.g. fork ID, network ID or genesis mismatch,
+// too many peers), to help diagnose nodes unable to find peers.
+func (api *publicAdminAPI) HandshakeFailures() ([]*p2p.HandshakeFailuresInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.HandshakeFailures(), nil
+}
+
+// NatStatus retrieves the externally reachable endpoint of the node as resolved
+// via NAT, the status of the port mappings and the last accepted inbound
+// connection, to help diagnose nodes which aren't reachable from the outside.
+func (api *publicAdminAPI) NatStatus() (*p2p.NATStatus, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.NATStatus(), nil
+}
+
 // Datadir retrieves the current data directory the node is using.
 func (api *publicAdminAPI) Datadir() string {
 	return api.node.DataDir()
@@ -18,6 +18,9 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -25,6 +28,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ong2020/go-orange/accounts"
 	"github.com/ong2020/go-orange/accounts/external"
@@ -32,6 +36,7 @@ import (
 	"github.com/ong2020/go-orange/accounts/scwallet"
 	"github.com/ong2020/go-orange/accounts/usbwallet"
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
 	"github.com/ong2020/go-orange/crypto"
 	"github.com/ong2020/go-orange/log"
 	"github.com/ong2020/go-orange/p2p"
@@ -70,6 +75,13 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// Namespace, if set, separates the data of a network from the data of other
+	// networks sharing the data directory: the instance files (databases, node
+	// key, node database) are stored in the Namespace subdirectory of the instance
+	// directory and the default keystore in the Namespace subdirectory of the
+	// keystore. Gong sets it to the genesis hash prefix of the selected network.
+	Namespace string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -107,6 +119,18 @@ type Config struct {
 	// relative), then that specific path is enforced. An empty path disables IPC.
 	IPCPath string
 
+	// IPCMode is the file mode of the IPC socket. Zero means 0600, accessible to
+	// the user running the node only.
+	IPCMode os.FileMode `toml:",omitempty"`
+
+	// IPCGroup is the group, by name or id, the IPC socket is handed to. Together
+	// with IPCMode, it allows other users of the group to attach to the node.
+	IPCGroup string `toml:",omitempty"`
+
+	// IPCSELinuxLabel is the SELinux security context the IPC socket is labeled
+	// with, on Linux only.
+	IPCSELinuxLabel string `toml:",omitempty"`
+
 	// HTTPHost is the host interface on which to start the HTTP RPC server. If this
 	// field is empty, no HTTP API endpoint will be started.
 	HTTPHost string
@@ -135,6 +159,10 @@ type Config struct {
 	// exposed.
 	HTTPModules []string
 
+	// HTTPMethodRules restricts the methods served via the HTTP RPC interface, see
+	// rpc.Server.SetMethodRules for the syntax of the rules.
+	HTTPMethodRules []string `toml:",omitempty"`
+
 	// HTTPTimeouts allows for customization of the timeout values used by the HTTP RPC
 	// interface.
 	HTTPTimeouts rpc.HTTPTimeouts
@@ -142,6 +170,10 @@ type Config struct {
 	// HTTPPathPrefix specifies a path prefix on which http-rpc is to be served.
 	HTTPPathPrefix string `toml:",omitempty"`
 
+	// HTTPGRPC serves the HTTP-RPC APIs over gRPC too, on the same endpoint. The
+	// gRPC calls are HTTP/2 requests in cleartext (h2c).
+	HTTPGRPC bool `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string
@@ -164,6 +196,10 @@ type Config struct {
 	// exposed.
 	WSModules []string
 
+	// WSMethodRules restricts the methods served via the websocket RPC interface,
+	// see rpc.Server.SetMethodRules for the syntax of the rules.
+	WSMethodRules []string `toml:",omitempty"`
+
 	// WSExposeAll exposes all API modules via the WebSocket RPC interface rather
 	// than just the public ones.
 	//
@@ -171,6 +207,23 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// WSCompression enables the permessage-deflate compression of the websocket
+	// messages with the clients supporting it.
+	WSCompression bool `toml:",omitempty"`
+
+	// WSReadLimit is the maximum size in bytes of the messages received by the
+	// websocket RPC server (0 = 15MB).
+	WSReadLimit int64 `toml:",omitempty"`
+
+	// WSPingInterval is the time without receiving anything from a websocket
+	// client before the server pings it (0 = 60s).
+	WSPingInterval time.Duration `toml:",omitempty"`
+
+	// WSPongTimeout is the time a websocket client has to answer a ping before
+	// its connection is closed (0 = 30s, negative = never closed). A connection
+	// silent for WSPingInterval+WSPongTimeout is closed as well.
+	WSPongTimeout time.Duration `toml:",omitempty"`
+
 	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
 	// useless for custom HTTP clients.
@@ -185,6 +238,11 @@ type Config struct {
 	// Requests using ip address directly are not affected
 	GraphQLVirtualHosts []string `toml:",omitempty"`
 
+	// RPCAdvertise is a list of public RPC endpoint URLs to advertise in the node
+	// record, together with the API namespaces exposed on them, allowing peers to
+	// discover RPC capable nodes.
+	RPCAdvertise []string `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -194,6 +252,73 @@ type Config struct {
 
 	// AllowUnprotectedTxs allows non EIP-155 protected transactions to be send over RPC.
 	AllowUnprotectedTxs bool `toml:",omitempty"`
+
+	// BatchRequestLimit is the maximum number of requests in a batch served over
+	// HTTP or WebSocket (0 = unlimited).
+	BatchRequestLimit int `toml:",omitempty"`
+
+	// BatchResponseMaxSize is the maximum number of bytes returned by the calls of
+	// a batch served over HTTP or WebSocket (0 = unlimited).
+	BatchResponseMaxSize int `toml:",omitempty"`
+
+	// RPCTimeouts limits the execution time of the methods served over HTTP and
+	// WebSocket, keyed by method (e.g. debug_traceBlockByNumber) or by namespace
+	// (e.g. debug). The timeout of a method takes precedence over the one of its
+	// namespace.
+	RPCTimeouts map[string]time.Duration `toml:",omitempty"`
+
+	// RPCRateLimits limits the rate of the calls of every remote address to the
+	// methods served over HTTP and WebSocket, keyed by method, by namespace, or
+	// by rpc.RateLimitDefault for all the other methods. The limits can be
+	// adjusted by reloading the config.
+	RPCRateLimits map[string]rpc.RateLimit `toml:",omitempty"`
+
+	// Verbosity is the logging verbosity ceiling ("crit", "error", "warn", "info",
+	// "debug" or "trace"), overriding the one set on the command line. It can be
+	// adjusted by reloading the config.
+	Verbosity string `toml:",omitempty"`
+
+	// RPCNotifyBatchWindow coalesces the subscription notifications sent over
+	// WebSocket and IPC within the given window into batches (0 = disabled).
+	RPCNotifyBatchWindow time.Duration `toml:",omitempty"`
+
+	// RPCAuthNamespaces is the list of API namespaces only available to
+	// authenticated clients over HTTP and WebSocket. The other namespaces stay
+	// open to all clients.
+	RPCAuthNamespaces []string `toml:",omitempty"`
+
+	// RPCAuthJWTSecret is the path of the file holding the hex encoded secret of
+	// the HS256 JSON web tokens accepted as bearer tokens.
+	RPCAuthJWTSecret string `toml:",omitempty"`
+
+	// RPCAuthAPIKeys is the path of the file listing the API keys accepted in the
+	// X-API-Key header, one per line, optionally followed by the identity of their
+	// holder.
+	RPCAuthAPIKeys string `toml:",omitempty"`
+
+	// RPCTLSCert and RPCTLSKey are the paths of the PEM encoded certificate and
+	// private key the HTTP and WebSocket servers are served over TLS with. Both
+	// must be set to enable TLS.
+	RPCTLSCert string `toml:",omitempty"`
+	RPCTLSKey  string `toml:",omitempty"`
+
+	// RPCTLSClientCA is the path of the PEM encoded certificates of the
+	// authorities the client certificates are verified against. Clients with a
+	// valid certificate are authenticated by its common name, granting them
+	// access to the namespaces listed in RPCAuthNamespaces.
+	RPCTLSClientCA string `toml:",omitempty"`
+
+	// RPCTLSRequireClientCert rejects the TLS connections of the clients without
+	// a valid certificate, instead of only leaving them unauthenticated.
+	RPCTLSRequireClientCert bool `toml:",omitempty"`
+
+	// RPCAuthenticator is a custom authenticator of the HTTP and WebSocket
+	// requests, checked along the configured JWT secret and API keys.
+	RPCAuthenticator rpc.Authenticator `toml:"-"`
+
+	// RPCHook observes the calls served over all the RPC transports, e.g. to keep
+	// an audit trail of the use of the admin and personal namespaces.
+	RPCHook rpc.Hook `toml:"-"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -344,7 +469,7 @@ func (c *Config) instanceDir() string {
 	if c.DataDir == "" {
 		return ""
 	}
-	return filepath.Join(c.DataDir, c.name())
+	return filepath.Join(c.DataDir, c.name(), c.Namespace)
 }
 
 // NodeKey retrieves the currently configured private key of the node, checking
@@ -373,7 +498,7 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	if err != nil {
 		log.Crit(fmt.Sprintf("Failed to generate node key: %v", err))
 	}
-	instanceDir := filepath.Join(c.DataDir, c.name())
+	instanceDir := c.instanceDir()
 	if err := os.MkdirAll(instanceDir, 0700); err != nil {
 		log.Error(fmt.Sprintf("Failed to persist node key: %v", err))
 		return key
@@ -448,6 +573,9 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 	case c.DataDir != "":
 		if c.KeyStoreDir == "" {
 			keydir = filepath.Join(c.DataDir, datadirDefaultKeyStore)
+			if !legacyKeyStore(keydir) {
+				keydir = filepath.Join(keydir, c.Namespace)
+			}
 		} else {
 			keydir, err = filepath.Abs(c.KeyStoreDir)
 		}
@@ -457,6 +585,22 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 	return scryptN, scryptP, keydir, err
 }
 
+// legacyKeyStore reports whether a keystore directory holds key files directly,
+// as created before the namespacing of the data directory. Such a keystore keeps
+// its layout, so existing keys are not hidden by the namespace.
+func legacyKeyStore(keydir string) bool {
+	entries, err := ioutil.ReadDir(keydir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			return true
+		}
+	}
+	return false
+}
+
 func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 	scryptN, scryptP, keydir, err := conf.AccountConfig()
 	var ephemeral string
@@ -537,3 +681,98 @@ func (c *Config) warnOnce(w *bool, format string, args ...interface{}) {
 	l.Warn(fmt.Sprintf(format, args...))
 	*w = true
 }
+
+// rpcAuthenticator creates the authenticator of the HTTP and WebSocket requests
+// from the configured credentials, or nil if no authentication is required.
+func (c *Config) rpcAuthenticator() (rpc.Authenticator, error) {
+	var auths []rpc.Authenticator
+	if c.RPCAuthenticator != nil {
+		auths = append(auths, c.RPCAuthenticator)
+	}
+	if c.RPCAuthJWTSecret != "" {
+		blob, err := ioutil.ReadFile(c.RPCAuthJWTSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT secret: %v", err)
+		}
+		secret, err := hexutil.Decode("0x" + strings.TrimPrefix(strings.TrimSpace(string(blob)), "0x"))
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("invalid JWT secret in %s", c.RPCAuthJWTSecret)
+		}
+		auths = append(auths, rpc.NewJWTAuthenticator(secret))
+	}
+	if c.RPCAuthAPIKeys != "" {
+		blob, err := ioutil.ReadFile(c.RPCAuthAPIKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API keys: %v", err)
+		}
+		keys := make(map[string]string)
+		for _, line := range strings.Split(string(blob), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			identity := fields[0]
+			if len(fields) > 1 {
+				identity = fields[1]
+			}
+			keys[fields[0]] = identity
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no API keys in %s", c.RPCAuthAPIKeys)
+		}
+		auths = append(auths, rpc.NewAPIKeyAuthenticator(keys))
+	}
+	if c.RPCTLSClientCA != "" {
+		auths = append(auths, rpc.NewClientCertAuthenticator())
+	}
+	switch {
+	case len(auths) == 0 && len(c.RPCAuthNamespaces) > 0:
+		return nil, errors.New("RPC authentication required without any credentials configured")
+	case len(auths) == 0:
+		return nil, nil
+	case len(auths) == 1:
+		return auths[0], nil
+	default:
+		return rpc.ChainAuthenticators(auths...), nil
+	}
+}
+
+// rpcTLSConfig loads the TLS configuration of the HTTP and WebSocket servers. It
+// returns nil if TLS is not enabled.
+func (c *Config) rpcTLSConfig() (*tls.Config, error) {
+	switch {
+	case c.RPCTLSCert == "" && c.RPCTLSKey == "":
+		if c.RPCTLSClientCA != "" || c.RPCTLSRequireClientCert {
+			return nil, errors.New("RPC client certificates configured without TLS")
+		}
+		return nil, nil
+	case c.RPCTLSCert == "" || c.RPCTLSKey == "":
+		return nil, errors.New("RPC TLS requires both a certificate and a key")
+	}
+	cert, err := tls.LoadX509KeyPair(c.RPCTLSCert, c.RPCTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load RPC TLS certificate: %v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.RPCTLSClientCA != "" {
+		blob, err := ioutil.ReadFile(c.RPCTLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RPC client CAs: %v", err)
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(blob) {
+			return nil, fmt.Errorf("no certificates in %s", c.RPCTLSClientCA)
+		}
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if c.RPCTLSRequireClientCert {
+		if config.ClientCAs == nil {
+			return nil, errors.New("RPC client certificates required without client CAs")
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
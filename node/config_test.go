@@ -18,11 +18,21 @@ package node
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"io/ioutil"
+	"math/big"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/ong2020/go-orange/crypto"
 	"github.com/ong2020/go-orange/p2p"
@@ -160,3 +170,179 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that namespaced configurations separate the instance files and the
+// default keystore of the networks sharing a data directory.
+func TestNamespaceResolution(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{Name: "unit-test", DataDir: dir, Namespace: "0123456789abcdef"}
+	if have, want := config.ResolvePath("chaindata"), filepath.Join(dir, "unit-test", "0123456789abcdef", "chaindata"); have != want {
+		t.Errorf("chaindata path mismatch: have %s, want %s", have, want)
+	}
+	if _, _, keydir, _ := config.AccountConfig(); keydir != filepath.Join(dir, datadirDefaultKeyStore, "0123456789abcdef") {
+		t.Errorf("keystore path mismatch: have %s, want %s", keydir, filepath.Join(dir, datadirDefaultKeyStore, "0123456789abcdef"))
+	}
+	config.NodeKey()
+	if _, err := os.Stat(filepath.Join(dir, "unit-test", "0123456789abcdef", datadirPrivateKey)); err != nil {
+		t.Fatalf("node key not persisted to namespace: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unit-test", datadirPrivateKey)); err == nil {
+		t.Fatalf("node key persisted outside of namespace")
+	}
+	// Keystores holding keys from before the namespacing must keep their layout
+	legacy := filepath.Join(dir, datadirDefaultKeyStore)
+	os.MkdirAll(legacy, 0700)
+	if err := ioutil.WriteFile(filepath.Join(legacy, "UTC--key"), []byte("{}"), 0600); err != nil {
+		t.Fatalf("failed to write legacy key: %v", err)
+	}
+	if _, _, keydir, _ := config.AccountConfig(); keydir != legacy {
+		t.Errorf("legacy keystore path mismatch: have %s, want %s", keydir, legacy)
+	}
+	// Explicit keystores must not be namespaced
+	config.KeyStoreDir = filepath.Join(dir, "keys")
+	if _, _, keydir, _ := config.AccountConfig(); keydir != config.KeyStoreDir {
+		t.Errorf("keystore path mismatch: have %s, want %s", keydir, config.KeyStoreDir)
+	}
+}
+
+// Tests that the RPC authenticator is created from the configured credentials.
+func TestRPCAuthenticator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary data dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		secret = filepath.Join(dir, "jwtsecret")
+		keys   = filepath.Join(dir, "apikeys")
+		empty  = filepath.Join(dir, "empty")
+	)
+	ioutil.WriteFile(secret, []byte("0x0102030405060708\n"), 0600)
+	ioutil.WriteFile(keys, []byte("# comment\nkey1 alice\nkey2\n"), 0600)
+	ioutil.WriteFile(empty, nil, 0600)
+
+	tests := []struct {
+		config Config
+		auth   bool
+		fail   bool
+	}{
+		{Config{}, false, false},
+		{Config{RPCAuthNamespaces: []string{"admin"}}, false, true},
+		{Config{RPCAuthNamespaces: []string{"admin"}, RPCAuthJWTSecret: secret}, true, false},
+		{Config{RPCAuthAPIKeys: keys}, true, false},
+		{Config{RPCAuthJWTSecret: secret, RPCAuthAPIKeys: keys}, true, false},
+		{Config{RPCAuthJWTSecret: keys}, false, true},
+		{Config{RPCAuthAPIKeys: empty}, false, true},
+		{Config{RPCAuthAPIKeys: filepath.Join(dir, "missing")}, false, true},
+	}
+	for i, tt := range tests {
+		auth, err := tt.config.rpcAuthenticator()
+		if (err != nil) != tt.fail {
+			t.Errorf("test %d: error mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+		if (auth != nil) != tt.auth {
+			t.Errorf("test %d: authenticator mismatch: have %v, want %v", i, auth != nil, tt.auth)
+		}
+	}
+}
+
+// testCert is a certificate created by the tests, with its PEM encoded files.
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert creates a certificate for localhost signed by the given authority,
+// or a self-signed authority if parent is nil.
+func newTestCert(t *testing.T, dir, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	ioutil.WriteFile(tc.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(tc.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return tc
+}
+
+// Tests that the TLS configuration of the RPC servers is loaded from the
+// configured files.
+func TestRPCTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		ca     = newTestCert(t, dir, "ca", nil)
+		server = newTestCert(t, dir, "server", ca)
+		empty  = filepath.Join(dir, "empty")
+	)
+	ioutil.WriteFile(empty, nil, 0600)
+
+	tests := []struct {
+		config     Config
+		clientAuth tls.ClientAuthType
+		fail       bool
+	}{
+		{config: Config{}},
+		{config: Config{RPCTLSCert: server.certFile, RPCTLSKey: server.keyFile}},
+		{config: Config{RPCTLSCert: server.certFile, RPCTLSKey: server.keyFile, RPCTLSClientCA: ca.certFile}, clientAuth: tls.VerifyClientCertIfGiven},
+		{config: Config{RPCTLSCert: server.certFile, RPCTLSKey: server.keyFile, RPCTLSClientCA: ca.certFile, RPCTLSRequireClientCert: true}, clientAuth: tls.RequireAndVerifyClientCert},
+		{config: Config{RPCTLSCert: server.certFile}, fail: true},
+		{config: Config{RPCTLSCert: server.certFile, RPCTLSKey: ca.keyFile}, fail: true},
+		{config: Config{RPCTLSClientCA: ca.certFile}, fail: true},
+		{config: Config{RPCTLSCert: server.certFile, RPCTLSKey: server.keyFile, RPCTLSClientCA: empty}, fail: true},
+		{config: Config{RPCTLSCert: server.certFile, RPCTLSKey: server.keyFile, RPCTLSRequireClientCert: true}, fail: true},
+	}
+	for i, tt := range tests {
+		config, err := tt.config.rpcTLSConfig()
+		if (err != nil) != tt.fail {
+			t.Errorf("test %d: error mismatch: have %v, want failure %v", i, err, tt.fail)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if enabled := tt.config.RPCTLSCert != ""; (config != nil) != enabled {
+			t.Errorf("test %d: TLS mismatch: have %v, want %v", i, config != nil, enabled)
+			continue
+		}
+		if config != nil && config.ClientAuth != tt.clientAuth {
+			t.Errorf("test %d: client auth mismatch: have %v, want %v", i, config.ClientAuth, tt.clientAuth)
+		}
+	}
+}
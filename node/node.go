@@ -23,12 +23,14 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/ong2020/go-orange/accounts"
 	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/internal/debug"
 	"github.com/ong2020/go-orange/log"
 	"github.com/ong2020/go-orange/ongdb"
 	"github.com/ong2020/go-orange/p2p"
@@ -58,6 +60,9 @@ type Node struct {
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
 	databases map[*closeTrackingDB]struct{} // All open databases
+
+	reloader   ConfigReloader // Re-reads the config file and applies the adjustable settings
+	reloadLock sync.Mutex     // Serializes config reloads
 }
 
 const (
@@ -94,6 +99,13 @@ func New(conf *Config) (*Node, error) {
 	if strings.HasSuffix(conf.Name, ".ipc") {
 		return nil, errors.New(`Config.Name cannot end in ".ipc"`)
 	}
+	if conf.Verbosity != "" {
+		lvl, err := log.LvlFromString(conf.Verbosity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log verbosity %q", conf.Verbosity)
+		}
+		debug.Handler.Verbosity(int(lvl))
+	}
 
 	node := &Node{
 		config:        conf,
@@ -134,6 +146,9 @@ func New(conf *Config) (*Node, error) {
 	if node.server.Config.NodeDatabase == "" {
 		node.server.Config.NodeDatabase = node.config.NodeDB()
 	}
+	if dir := node.server.Config.ForensicsDir; dir != "" {
+		node.server.Config.ForensicsDir = node.config.ResolvePath(dir)
+	}
 
 	// Check HTTP/WS prefixes are valid.
 	if err := validatePrefix("HTTP", conf.HTTPPathPrefix); err != nil {
@@ -147,6 +162,12 @@ func New(conf *Config) (*Node, error) {
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
 	node.ipc = newIPCServer(node.log, conf.IPCEndpoint())
+	node.ipc.notifyBatch = conf.RPCNotifyBatchWindow
+	node.ipc.config = rpc.IPCConfig{Mode: conf.IPCMode, Group: conf.IPCGroup, SELinuxLabel: conf.IPCSELinuxLabel}
+	node.ipc.hook = conf.RPCHook
+	if conf.RPCHook != nil {
+		node.inprocHandler.SetHook(conf.RPCHook)
+	}
 
 	return node, nil
 }
@@ -263,6 +284,14 @@ func (n *Node) openEndpoints() error {
 	if err := n.server.Start(); err != nil {
 		return convertFileLockError(err)
 	}
+	// advertise the public RPC endpoints in the node record, if requested
+	if entry := n.rpcAdvertisement(); entry != nil {
+		if err := entry.Validate(); err != nil {
+			n.server.Stop()
+			return err
+		}
+		n.server.LocalNode().Set(entry)
+	}
 	// start RPC endpoints
 	err := n.startRPC()
 	if err != nil {
@@ -272,6 +301,32 @@ func (n *Node) openEndpoints() error {
 	return err
 }
 
+// rpcAdvertisement assembles the "rpc" node record entry from the configured
+// public endpoints and the API modules exposed on their transports.
+func (n *Node) rpcAdvertisement() *rpc.ENREntry {
+	if len(n.config.RPCAdvertise) == 0 {
+		return nil
+	}
+	var (
+		entry = &rpc.ENREntry{URLs: n.config.RPCAdvertise}
+		seen  = make(map[string]bool)
+	)
+	for _, endpoint := range n.config.RPCAdvertise {
+		modules := n.config.HTTPModules
+		if strings.HasPrefix(endpoint, "ws") {
+			modules = n.config.WSModules
+		}
+		for _, module := range modules {
+			if !seen[module] {
+				seen[module] = true
+				entry.Namespaces = append(entry.Namespaces, module)
+			}
+		}
+	}
+	sort.Strings(entry.Namespaces)
+	return entry
+}
+
 // containsLifecycle checks if 'lfs' contains 'l'.
 func containsLifecycle(lfs []Lifecycle, l Lifecycle) bool {
 	for _, obj := range lfs {
@@ -309,7 +364,7 @@ func (n *Node) openDataDir() error {
 		return nil // ephemeral
 	}
 
-	instdir := filepath.Join(n.config.DataDir, n.config.name())
+	instdir := n.config.instanceDir()
 	if err := os.MkdirAll(instdir, 0700); err != nil {
 		return err
 	}
@@ -348,6 +403,21 @@ func (n *Node) startRPC() error {
 		}
 	}
 
+	auth, err := n.config.rpcAuthenticator()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := n.config.rpcTLSConfig()
+	if err != nil {
+		return err
+	}
+	if err := n.http.setTLS(tlsConfig); err != nil {
+		return err
+	}
+	if err := n.ws.setTLS(tlsConfig); err != nil {
+		return err
+	}
+
 	// Configure HTTP.
 	if n.config.HTTPHost != "" {
 		config := httpConfig{
@@ -355,6 +425,15 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			grpc:               n.config.HTTPGRPC,
+			batchItemLimit:     n.config.BatchRequestLimit,
+			batchResponseLimit: n.config.BatchResponseMaxSize,
+			auth:               auth,
+			authNamespaces:     n.config.RPCAuthNamespaces,
+			hook:               n.config.RPCHook,
+			methodRules:        n.config.HTTPMethodRules,
+			timeouts:           n.config.RPCTimeouts,
+			rateLimits:         n.config.RPCRateLimits,
 		}
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
@@ -371,6 +450,22 @@ func (n *Node) startRPC() error {
 			Modules: n.config.WSModules,
 			Origins: n.config.WSOrigins,
 			prefix:  n.config.WSPathPrefix,
+			conn: rpc.WebsocketConfig{
+				Compression:  n.config.WSCompression,
+				ReadLimit:    n.config.WSReadLimit,
+				PingInterval: n.config.WSPingInterval,
+				PongTimeout:  n.config.WSPongTimeout,
+			},
+			notifyBatch: n.config.RPCNotifyBatchWindow,
+
+			batchItemLimit:     n.config.BatchRequestLimit,
+			batchResponseLimit: n.config.BatchResponseMaxSize,
+			auth:               auth,
+			authNamespaces:     n.config.RPCAuthNamespaces,
+			hook:               n.config.RPCHook,
+			methodRules:        n.config.WSMethodRules,
+			timeouts:           n.config.RPCTimeouts,
+			rateLimits:         n.config.RPCRateLimits,
 		}
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
@@ -527,15 +622,15 @@ func (n *Node) IPCEndpoint() string {
 // HTTPEndpoint returns the URL of the HTTP server. Note that this URL does not
 // contain the JSON-RPC path prefix set by HTTPPathPrefix.
 func (n *Node) HTTPEndpoint() string {
-	return "http://" + n.http.listenAddr()
+	return n.http.httpScheme() + "://" + n.http.listenAddr()
 }
 
 // WSEndpoint returns the current JSON-RPC over WebSocket endpoint.
 func (n *Node) WSEndpoint() string {
 	if n.http.wsAllowed() {
-		return "ws://" + n.http.listenAddr() + n.http.wsConfig.prefix
+		return n.http.wsScheme() + "://" + n.http.listenAddr() + n.http.wsConfig.prefix
 	}
-	return "ws://" + n.ws.listenAddr() + n.ws.wsConfig.prefix
+	return n.ws.wsScheme() + "://" + n.ws.listenAddr() + n.ws.wsConfig.prefix
 }
 
 // EventMux retrieves the event multiplexer used by all the network services in
@@ -571,9 +666,10 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string) (
 // OpenDatabaseWithFreezer opens an existing database with the given name (or
 // creates one if no previous can be found) from within the node's data directory,
 // also attaching a chain freezer to it that moves ancient chain data from the
-// database to immutable append-only files. If the node is an ephemeral one, a
-// memory database is returned.
-func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer, namespace string) (ongdb.Database, error) {
+// database to immutable append-only files. The compression algorithms of the new
+// freezer tables can be overridden by table name. If the node is an ephemeral
+// one, a memory database is returned.
+func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer, namespace string, compression map[string]string) (ongdb.Database, error) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 	if n.state == closedState {
@@ -592,7 +688,7 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 		case !filepath.IsAbs(freezer):
 			freezer = n.ResolvePath(freezer)
 		}
-		db, err = rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace)
+		db, err = rawdb.NewLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace, compression)
 	}
 
 	if err == nil {
@@ -17,6 +17,9 @@
 package node
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -26,7 +29,9 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ong2020/go-orange/crypto"
 	"github.com/ong2020/go-orange/ongdb"
@@ -85,6 +90,48 @@ func TestNodeStartMultipleTimes(t *testing.T) {
 	}
 }
 
+// Tests that the configured public RPC endpoints are advertised in the node
+// record, and that invalid ones prevent the node from starting.
+func TestNodeRPCAdvertisement(t *testing.T) {
+	config := testNodeConfig()
+	config.RPCAdvertise = []string{"https://rpc.example.org", "wss://ws.example.org"}
+	config.HTTPModules = []string{"ong", "net"}
+	config.WSModules = []string{"ong", "web3"}
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	var entry rpc.ENREntry
+	if err := stack.Server().Self().Load(&entry); err != nil {
+		t.Fatalf("failed to load rpc entry: %v", err)
+	}
+	if !reflect.DeepEqual(entry.URLs, config.RPCAdvertise) {
+		t.Errorf("advertised urls mismatch: have %v, want %v", entry.URLs, config.RPCAdvertise)
+	}
+	if want := []string{"net", "ong", "web3"}; !reflect.DeepEqual(entry.Namespaces, want) {
+		t.Errorf("advertised namespaces mismatch: have %v, want %v", entry.Namespaces, want)
+	}
+	// Ensure invalid endpoints are rejected
+	config = testNodeConfig()
+	config.RPCAdvertise = []string{"ftp://rpc.example.org"}
+
+	stack, err = New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	defer stack.Close()
+
+	if err := stack.Start(); err == nil {
+		t.Fatalf("node started with invalid rpc advertisement")
+	}
+}
+
 // Tests that if the data dir is already in use, an appropriate error is returned.
 func TestNodeUsedDataDir(t *testing.T) {
 	// Create a temporary folder to use as the data directory
@@ -591,6 +638,154 @@ func (test rpcPrefixTest) check(t *testing.T, node *Node) {
 	}
 }
 
+// Tests that the RPC servers are served over TLS, authenticating the clients by
+// their certificates.
+func TestNodeRPCTLS(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		ca     = newTestCert(t, dir, "ca", nil)
+		server = newTestCert(t, dir, "server", ca)
+		client = newTestCert(t, dir, "operator", ca)
+	)
+	conf := &Config{
+		HTTPHost:          "127.0.0.1",
+		HTTPModules:       []string{"admin", "rpc"},
+		RPCAuthNamespaces: []string{"admin"},
+		RPCTLSCert:        server.certFile,
+		RPCTLSKey:         server.keyFile,
+		RPCTLSClientCA:    ca.certFile,
+	}
+	node, err := New(conf)
+	if err != nil {
+		t.Fatalf("could not create a new node: %v", err)
+	}
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	defer node.Close()
+
+	url := node.HTTPEndpoint()
+	if !strings.HasPrefix(url, "https://") {
+		t.Fatalf("HTTP endpoint not served over TLS: %s", url)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	dial := func(certs ...tls.Certificate) *rpc.Client {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}
+		c, err := rpc.DialHTTPWithClient(url, &http.Client{Transport: transport})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	// Clients without certificates can only call the open namespaces
+	anonymous := dial()
+	defer anonymous.Close()
+	if _, err := anonymous.SupportedModules(); err != nil {
+		t.Errorf("anonymous call failed: %v", err)
+	}
+	var datadir string
+	if err := anonymous.CallContext(context.Background(), &datadir, "admin_datadir"); err == nil {
+		t.Errorf("anonymous client called the admin namespace")
+	}
+	// Clients with a certificate are authenticated
+	operator := dial(tls.Certificate{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key})
+	defer operator.Close()
+	if err := operator.CallContext(context.Background(), &datadir, "admin_datadir"); err != nil {
+		t.Errorf("authenticated call failed: %v", err)
+	}
+	// Plain HTTP is refused
+	resp, err := http.Post("http"+strings.TrimPrefix(url, "https"), "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Errorf("plain HTTP request served")
+		}
+	}
+}
+
+// methodHook records the methods of the calls reported to it.
+type methodHook struct {
+	mu      sync.Mutex
+	methods []string
+}
+
+func (h *methodHook) OnRequest(ctx context.Context, call *rpc.CallInfo) {}
+
+func (h *methodHook) OnResponse(ctx context.Context, call *rpc.CallInfo, duration time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.methods = append(h.methods, call.Method)
+}
+
+// Tests that the RPC hook observes the calls served over all transports.
+func TestNodeRPCHook(t *testing.T) {
+	hook := new(methodHook)
+	conf := testNodeConfig()
+	conf.HTTPHost = "127.0.0.1"
+	conf.RPCHook = hook
+
+	node, err := New(conf)
+	if err != nil {
+		t.Fatalf("could not create a new node: %v", err)
+	}
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	defer node.Close()
+
+	inproc, _ := node.Attach()
+	defer inproc.Close()
+	var version string
+	if err := inproc.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("in-process call failed: %v", err)
+	}
+	remote, err := rpc.Dial(node.HTTPEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	if err := remote.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("HTTP call failed: %v", err)
+	}
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if want := []string{"web3_clientVersion", "web3_clientVersion"}; !reflect.DeepEqual(hook.methods, want) {
+		t.Errorf("hooked calls mismatch: have %v, want %v", hook.methods, want)
+	}
+}
+
+// Tests that the method rules of the HTTP server only apply to it.
+func TestNodeHTTPMethodRules(t *testing.T) {
+	conf := testNodeConfig()
+	conf.HTTPHost = "127.0.0.1"
+	conf.HTTPMethodRules = []string{"!web3_clientVersion"}
+
+	node, err := New(conf)
+	if err != nil {
+		t.Fatalf("could not create a new node: %v", err)
+	}
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	defer node.Close()
+
+	remote, err := rpc.Dial(node.HTTPEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+	var version string
+	if err := remote.Call(&version, "web3_clientVersion"); err == nil {
+		t.Errorf("denied HTTP call succeeded")
+	}
+	inproc, _ := node.Attach()
+	defer inproc.Close()
+	if err := inproc.Call(&version, "web3_clientVersion"); err != nil {
+		t.Errorf("in-process call failed: %v", err)
+	}
+}
+
 func createNode(t *testing.T, httpPort, wsPort int) *Node {
 	conf := &Config{
 		HTTPHost: "127.0.0.1",
@@ -0,0 +1,170 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+
+	"github.com/ong2020/go-orange/internal/debug"
+	"github.com/ong2020/go-orange/log"
+)
+
+// errNoConfigReloader is returned if a config reload is requested but the node
+// was not started from a config file.
+var errNoConfigReloader = errors.New("config reloading not supported")
+
+// ConfigReloadResult reports the outcome of a config reload: the changed settings
+// which were applied at runtime and the ones which were rejected, along with the
+// reason (e.g. the setting can only be changed by restarting the node).
+type ConfigReloadResult struct {
+	Applied  []string          `json:"applied"`
+	Rejected map[string]string `json:"rejected"`
+}
+
+// NewConfigReloadResult creates an empty config reload report.
+func NewConfigReloadResult() *ConfigReloadResult {
+	return &ConfigReloadResult{
+		Applied:  []string{},
+		Rejected: make(map[string]string),
+	}
+}
+
+// Apply records a changed setting as applied.
+func (r *ConfigReloadResult) Apply(setting string) {
+	r.Applied = append(r.Applied, setting)
+}
+
+// Reject records a changed setting as rejected for the given reason.
+func (r *ConfigReloadResult) Reject(setting string, reason string) {
+	r.Rejected[setting] = reason
+}
+
+// RejectChanged compares two versions of a config struct, rejecting all the
+// settings which changed except the adjustable ones, which the caller is
+// expected to apply (or reject) itself. Settings not stored in the config file
+// (tagged as toml:"-") and runtime hooks (interfaces, functions) are ignored.
+func (r *ConfigReloadResult) RejectChanged(prefix string, old, new interface{}, adjustable ...string) {
+	skip := make(map[string]bool)
+	for _, name := range adjustable {
+		skip[name] = true
+	}
+	oldv, newv := reflect.ValueOf(old), reflect.ValueOf(new)
+	typ := oldv.Type()
+
+	var changed []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" || skip[field.Name] || field.Tag.Get("toml") == "-" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Interface, reflect.Func, reflect.Chan:
+			continue
+		}
+		if !reflect.DeepEqual(oldv.Field(i).Interface(), newv.Field(i).Interface()) {
+			changed = append(changed, prefix+"."+field.Name)
+		}
+	}
+	sort.Strings(changed)
+	for _, name := range changed {
+		r.Reject(name, "requires restart")
+	}
+}
+
+// ConfigReloader re-reads the configuration of the node and applies the changes
+// of the settings adjustable at runtime.
+type ConfigReloader func() (*ConfigReloadResult, error)
+
+// SetConfigReloader sets the function reloading the configuration of the node,
+// invoked on SIGHUP or via the admin API.
+func (n *Node) SetConfigReloader(reload ConfigReloader) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.reloader = reload
+}
+
+// CanReloadConfig reports whether a function reloading the configuration of the
+// node is set.
+func (n *Node) CanReloadConfig() bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.reloader != nil
+}
+
+// ReloadConfig re-reads the configuration of the node and applies the changes
+// of the settings adjustable at runtime, reporting the applied and the rejected
+// ones.
+func (n *Node) ReloadConfig() (*ConfigReloadResult, error) {
+	n.lock.Lock()
+	reload := n.reloader
+	n.lock.Unlock()
+
+	if reload == nil {
+		return nil, errNoConfigReloader
+	}
+	n.reloadLock.Lock()
+	defer n.reloadLock.Unlock()
+
+	res, err := reload()
+	if err != nil {
+		n.log.Warn("Failed to reload config", "err", err)
+		return nil, err
+	}
+	n.log.Info("Reloaded config", "applied", res.Applied, "rejected", len(res.Rejected))
+	for setting, reason := range res.Rejected {
+		n.log.Warn("Rejected config change", "setting", setting, "reason", reason)
+	}
+	return res, nil
+}
+
+// ApplyConfig applies the runtime-adjustable settings of a reloaded node config:
+// the peer limit, the logging verbosity and the RPC rate limits. The changes of
+// all other settings are rejected.
+func (n *Node) ApplyConfig(cfg *Config, res *ConfigReloadResult) {
+	if cfg.P2P.MaxPeers != n.config.P2P.MaxPeers {
+		if cfg.P2P.MaxPeers < 0 {
+			res.Reject("Node.P2P.MaxPeers", "invalid peer limit")
+		} else {
+			n.server.SetMaxPeers(cfg.P2P.MaxPeers)
+			n.config.P2P.MaxPeers = cfg.P2P.MaxPeers
+			res.Apply("Node.P2P.MaxPeers")
+		}
+	}
+	if cfg.Verbosity != n.config.Verbosity {
+		if cfg.Verbosity == "" {
+			res.Reject("Node.Verbosity", "requires restart")
+		} else if lvl, err := log.LvlFromString(cfg.Verbosity); err != nil {
+			res.Reject("Node.Verbosity", "invalid verbosity")
+		} else {
+			debug.Handler.Verbosity(int(lvl))
+			n.config.Verbosity = cfg.Verbosity
+			res.Apply("Node.Verbosity")
+		}
+	}
+	if !reflect.DeepEqual(cfg.RPCRateLimits, n.config.RPCRateLimits) {
+		n.http.setRateLimits(cfg.RPCRateLimits)
+		n.ws.setRateLimits(cfg.RPCRateLimits)
+		n.config.RPCRateLimits = cfg.RPCRateLimits
+		res.Apply("Node.RPCRateLimits")
+	}
+	res.RejectChanged("Node.P2P", n.config.P2P, cfg.P2P, "MaxPeers")
+	res.RejectChanged("Node", *n.config, *cfg, "P2P", "Verbosity", "RPCRateLimits")
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// Tests that reloading the config applies the peer limit, verbosity and rate
+// limits of a running node and rejects the changes of the settings which require a restart.
+func TestReloadConfig(t *testing.T) {
+	stack := createNode(t, 0, 0)
+	defer stack.Close()
+
+	if _, err := stack.ReloadConfig(); err != errNoConfigReloader {
+		t.Fatalf("reload without reloader: have %v, want %v", err, errNoConfigReloader)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	stack.SetConfigReloader(func() (*ConfigReloadResult, error) {
+		cfg := *stack.Config()
+		cfg.P2P.MaxPeers = 7
+		cfg.Verbosity = "info"
+		cfg.RPCRateLimits = map[string]rpc.RateLimit{rpc.RateLimitDefault: {Rate: 10, Burst: 10}}
+		cfg.HTTPPort = 1234
+
+		res := NewConfigReloadResult()
+		stack.ApplyConfig(&cfg, res)
+		return res, nil
+	})
+	res, err := stack.ReloadConfig()
+	if err != nil {
+		t.Fatalf("failed to reload config: %v", err)
+	}
+	if !reflect.DeepEqual(res.Applied, []string{"Node.P2P.MaxPeers", "Node.Verbosity", "Node.RPCRateLimits"}) {
+		t.Errorf("applied settings mismatch: %v", res.Applied)
+	}
+	if _, ok := res.Rejected["Node.HTTPPort"]; !ok {
+		t.Errorf("changed HTTP port not rejected: %v", res.Rejected)
+	}
+	if stack.Server().MaxPeers != 7 {
+		t.Errorf("peer limit not applied: have %d, want 7", stack.Server().MaxPeers)
+	}
+	if limit := stack.Config().RPCRateLimits[rpc.RateLimitDefault]; limit.Rate != 10 {
+		t.Errorf("rate limits not applied: %v", stack.Config().RPCRateLimits)
+	}
+}
@@ -19,6 +19,7 @@ package node
 import (
 	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -28,10 +29,13 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ong2020/go-orange/log"
 	"github.com/ong2020/go-orange/rpc"
 	"github.com/rs/cors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // httpConfig is the JSON-RPC/HTTP configuration.
@@ -40,6 +44,17 @@ type httpConfig struct {
 	CorsAllowedOrigins []string
 	Vhosts             []string
 	prefix             string // path prefix on which to mount http handler
+	grpc               bool   // whether to serve the APIs over gRPC too
+	batchItemLimit     int    // maximum number of requests in a batch
+	batchResponseLimit int    // maximum size of the results of a batch
+
+	auth           rpc.Authenticator // authenticator of the requests, if any
+	authNamespaces []string          // namespaces restricted to authenticated clients
+	hook           rpc.Hook          // observer of the calls, if any
+	methodRules    []string          // methods allowed and denied, if any
+
+	timeouts   map[string]time.Duration // execution timeouts of methods and namespaces
+	rateLimits map[string]rpc.RateLimit // rate limits of the remote callers
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
@@ -47,11 +62,26 @@ type wsConfig struct {
 	Origins []string
 	Modules []string
 	prefix  string // path prefix on which to mount ws handler
+
+	conn        rpc.WebsocketConfig // compression and limits of the connections
+	notifyBatch time.Duration       // window coalescing the notifications, if any
+
+	batchItemLimit     int // maximum number of requests in a batch
+	batchResponseLimit int // maximum size of the results of a batch
+
+	auth           rpc.Authenticator // authenticator of the requests, if any
+	authNamespaces []string          // namespaces restricted to authenticated clients
+	hook           rpc.Hook          // observer of the calls, if any
+	methodRules    []string          // methods allowed and denied, if any
+
+	timeouts   map[string]time.Duration // execution timeouts of methods and namespaces
+	rateLimits map[string]rpc.RateLimit // rate limits of the remote callers
 }
 
 type rpcHandler struct {
 	http.Handler
 	server *rpc.Server
+	grpc   http.Handler // gRPC endpoint of the server, if enabled
 }
 
 type httpServer struct {
@@ -62,6 +92,7 @@ type httpServer struct {
 	mu       sync.Mutex
 	server   *http.Server
 	listener net.Listener // non-nil when server is running
+	tls      *tls.Config  // serves over TLS if set
 
 	// HTTP RPC handler things.
 
@@ -123,8 +154,12 @@ func (h *httpServer) start() error {
 		return nil // already running or not configured
 	}
 
-	// Initialize the server.
+	// Initialize the server. gRPC requires HTTP/2, which is served in cleartext
+	// next to HTTP/1.
 	h.server = &http.Server{Handler: h}
+	if h.rpcAllowed() && h.httpConfig.grpc {
+		h.server.Handler = h2c.NewHandler(h, new(http2.Server))
+	}
 	if h.timeouts != (rpc.HTTPTimeouts{}) {
 		CheckTimeouts(&h.timeouts)
 		h.server.ReadTimeout = h.timeouts.ReadTimeout
@@ -142,10 +177,17 @@ func (h *httpServer) start() error {
 		return err
 	}
 	h.listener = listener
-	go h.server.Serve(listener)
+	if h.tls != nil {
+		// The certificates are in the configuration, HTTP/2 is negotiated
+		// by the server.
+		h.server.TLSConfig = h.tls
+		go h.server.ServeTLS(listener, "", "")
+	} else {
+		go h.server.Serve(listener)
+	}
 
 	if h.wsAllowed() {
-		url := fmt.Sprintf("ws://%v", listener.Addr())
+		url := fmt.Sprintf("%s://%v", h.wsScheme(), listener.Addr())
 		if h.wsConfig.prefix != "" {
 			url += h.wsConfig.prefix
 		}
@@ -161,6 +203,7 @@ func (h *httpServer) start() error {
 		"prefix", h.httpConfig.prefix,
 		"cors", strings.Join(h.httpConfig.CorsAllowedOrigins, ","),
 		"vhosts", strings.Join(h.httpConfig.Vhosts, ","),
+		"tls", h.tls != nil,
 	)
 
 	// Log all handlers mounted on server.
@@ -173,13 +216,42 @@ func (h *httpServer) start() error {
 	for _, path := range paths {
 		name := h.handlerNames[path]
 		if !logged[name] {
-			log.Info(name+" enabled", "url", "http://"+listener.Addr().String()+path)
+			log.Info(name+" enabled", "url", h.httpScheme()+"://"+listener.Addr().String()+path)
 			logged[name] = true
 		}
 	}
 	return nil
 }
 
+// setTLS configures the server to serve over TLS, or in cleartext if config is
+// nil. The configuration can only be changed while the server isn't running.
+func (h *httpServer) setTLS(config *tls.Config) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.listener != nil && config != h.tls {
+		return fmt.Errorf("HTTP server already running on %s", h.endpoint)
+	}
+	h.tls = config
+	return nil
+}
+
+// httpScheme returns the URL scheme of the HTTP endpoint.
+func (h *httpServer) httpScheme() string {
+	if h.tls != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// wsScheme returns the URL scheme of the WebSocket endpoint.
+func (h *httpServer) wsScheme() string {
+	if h.tls != nil {
+		return "wss"
+	}
+	return "ws"
+}
+
 func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// check if ws request and serve if ws enabled
 	ws := h.wsHandler.Load().(*rpcHandler)
@@ -192,6 +264,10 @@ func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// if http-rpc is enabled, try to serve request
 	rpc := h.httpHandler.Load().(*rpcHandler)
 	if rpc != nil {
+		if rpc.grpc != nil && isGRPC(r) {
+			rpc.grpc.ServeHTTP(w, r)
+			return
+		}
 		// First try to route in the mux.
 		// Requests to a path below root are handled by the mux,
 		// which has all the handlers registered via Node.RegisterHandler.
@@ -280,17 +356,53 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseLimit)
+	if config.auth != nil {
+		srv.SetAuthenticator(config.auth, config.authNamespaces)
+	}
+	if config.hook != nil {
+		srv.SetHook(config.hook)
+	}
+	if err := srv.SetMethodRules(config.methodRules); err != nil {
+		return err
+	}
+	for name, timeout := range config.timeouts {
+		srv.SetMethodTimeout(name, timeout)
+	}
+	for name, limit := range config.rateLimits {
+		srv.SetRateLimit(name, limit)
+	}
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
 	h.httpConfig = config
-	h.httpHandler.Store(&rpcHandler{
+	handler := &rpcHandler{
 		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
 		server:  srv,
-	})
+	}
+	if config.grpc {
+		handler.grpc = newGRPCHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts)
+	}
+	h.httpHandler.Store(handler)
 	return nil
 }
 
+// setRateLimits replaces the rate limits of the HTTP and WebSocket handlers,
+// including the ones enabled later on.
+func (h *httpServer) setRateLimits(limits map[string]rpc.RateLimit) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.httpConfig.rateLimits = limits
+	h.wsConfig.rateLimits = limits
+	if handler := h.httpHandler.Load().(*rpcHandler); handler != nil {
+		handler.server.SetRateLimits(limits)
+	}
+	if handler := h.wsHandler.Load().(*rpcHandler); handler != nil {
+		handler.server.SetRateLimits(limits)
+	}
+}
+
 // disableRPC stops the HTTP RPC handler. This is internal, the caller must hold h.mu.
 func (h *httpServer) disableRPC() bool {
 	handler := h.httpHandler.Load().(*rpcHandler)
@@ -312,12 +424,29 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseLimit)
+	if config.auth != nil {
+		srv.SetAuthenticator(config.auth, config.authNamespaces)
+	}
+	if config.hook != nil {
+		srv.SetHook(config.hook)
+	}
+	if err := srv.SetMethodRules(config.methodRules); err != nil {
+		return err
+	}
+	for name, timeout := range config.timeouts {
+		srv.SetMethodTimeout(name, timeout)
+	}
+	for name, limit := range config.rateLimits {
+		srv.SetRateLimit(name, limit)
+	}
+	srv.SetNotificationBatching(config.notifyBatch, 0)
 	if err := RegisterApisFromWhitelist(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: srv.WebsocketHandler(config.Origins),
+		Handler: srv.WebsocketHandlerWithConfig(config.Origins, config.conn),
 		server:  srv,
 	})
 	return nil
@@ -361,6 +490,11 @@ func isWebsocket(r *http.Request) bool {
 		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
 }
 
+// isGRPC checks if the request is a gRPC call.
+func isGRPC(r *http.Request) bool {
+	return rpc.IsGRPC(r)
+}
+
 // NewHTTPHandlerStack returns wrapped http-related handlers
 func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string) http.Handler {
 	// Wrap the CORS-handler within a host-handler
@@ -369,6 +503,14 @@ func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string) http.
 	return newGzipHandler(handler)
 }
 
+// newGRPCHandlerStack returns the gRPC endpoint of the server behind the same
+// host and CORS checks as the HTTP handlers. The responses are not gzipped,
+// gRPC frames the messages itself.
+func newGRPCHandlerStack(srv *rpc.Server, cors []string, vhosts []string) http.Handler {
+	handler := newCorsHandler(http.HandlerFunc(srv.ServeGRPC), cors)
+	return newVHostHandler(vhosts, handler)
+}
+
 func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
@@ -471,8 +613,11 @@ func newGzipHandler(next http.Handler) http.Handler {
 }
 
 type ipcServer struct {
-	log      log.Logger
-	endpoint string
+	log         log.Logger
+	endpoint    string
+	notifyBatch time.Duration // window coalescing the notifications, if any
+	config      rpc.IPCConfig // access to the endpoint
+	hook        rpc.Hook      // observer of the calls, if any
 
 	mu       sync.Mutex
 	listener net.Listener
@@ -491,11 +636,15 @@ func (is *ipcServer) start(apis []rpc.API) error {
 	if is.listener != nil {
 		return nil // already running
 	}
-	listener, srv, err := rpc.StartIPCEndpoint(is.endpoint, apis)
+	listener, srv, err := rpc.StartIPCEndpointWithConfig(is.endpoint, apis, is.config)
 	if err != nil {
 		is.log.Warn("IPC opening failed", "url", is.endpoint, "error", err)
 		return err
 	}
+	srv.SetNotificationBatching(is.notifyBatch, 0)
+	if is.hook != nil {
+		srv.SetHook(is.hook)
+	}
 	is.log.Info("IPC endpoint opened", "url", is.endpoint)
 	is.listener, is.srv = listener, srv
 	return nil
@@ -18,7 +18,9 @@ package node
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -159,6 +161,46 @@ func TestWebsocketOrigins(t *testing.T) {
 	}
 }
 
+// TestGRPC makes sure the HTTP-RPC APIs are served over gRPC next to HTTP/1 when
+// enabled.
+func TestGRPC(t *testing.T) {
+	srv := createAndStartServer(t, &httpConfig{grpc: true}, false, &wsConfig{})
+	defer srv.stop()
+
+	url := "http://" + srv.listenAddr()
+	client, err := rpc.DialGRPC(context.Background(), url)
+	if err != nil {
+		t.Fatalf("can't dial gRPC endpoint: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.SupportedModules(); err != nil {
+		t.Errorf("gRPC call failed: %v", err)
+	}
+	resp := rpcRequest(t, url)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// TestGRPCVhosts makes sure gRPC calls are subject to the virtual host checks of
+// the HTTP-RPC APIs.
+func TestGRPCVhosts(t *testing.T) {
+	srv := createAndStartServer(t, &httpConfig{grpc: true, Vhosts: []string{"test"}}, false, &wsConfig{})
+	defer srv.stop()
+
+	_, port, _ := net.SplitHostPort(srv.listenAddr())
+	if _, err := rpc.DialGRPC(context.Background(), "http://localhost:"+port); err == nil {
+		t.Fatal("gRPC stream accepted for a disallowed virtual host")
+	}
+	client, err := rpc.DialGRPC(context.Background(), "http://"+srv.listenAddr())
+	if err != nil {
+		t.Fatalf("can't dial gRPC endpoint: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.SupportedModules(); err != nil {
+		t.Errorf("gRPC call failed: %v", err)
+	}
+}
+
 // TestIsWebsocket tests if an incoming websocket upgrade request is handled properly.
 func TestIsWebsocket(t *testing.T) {
 	r, _ := http.NewRequest("GET", "/", nil)
@@ -17,6 +17,7 @@
 package ong
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
@@ -33,6 +34,7 @@ import (
 	"github.com/ong2020/go-orange/core"
 	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/core/state"
+	"github.com/ong2020/go-orange/core/state/snapshot"
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/internal/ongapi"
 	"github.com/ong2020/go-orange/rlp"
@@ -66,15 +68,6 @@ func (api *PublicOrangeAPI) Hashrate() hexutil.Uint64 {
 	return hexutil.Uint64(api.e.Miner().HashRate())
 }
 
-// ChainId is the EIP-155 replay-protection chain id for the current orange chain config.
-func (api *PublicOrangeAPI) ChainId() (hexutil.Uint64, error) {
-	// if current block is at or past the EIP-155 replay-protection fork block, return chainID from config
-	if config := api.e.blockchain.Config(); config.IsEIP155(api.e.blockchain.CurrentBlock().Number()) {
-		return (hexutil.Uint64)(config.ChainID.Uint64()), nil
-	}
-	return hexutil.Uint64(0), fmt.Errorf("chain not synced beyond EIP-155 replay-protection fork block")
-}
-
 // PublicMinerAPI provides an API to control the miner.
 // It offers only Methods that operate on data that pose no security risk when it is publicly accessible.
 type PublicMinerAPI struct {
@@ -149,6 +142,57 @@ func (api *PrivateMinerAPI) SetRecommitInterval(interval int) {
 	api.e.Miner().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
 }
 
+// SetMaxTxs updates the maximum number of transactions included in a mined
+// block, 0 meaning no limit.
+func (api *PrivateMinerAPI) SetMaxTxs(max int) bool {
+	if max < 0 {
+		return false
+	}
+	api.e.Miner().SetMaxTxs(max)
+	return true
+}
+
+// SetFillDeadline updates the maximum time (in milliseconds) spent filling a
+// mined block with transactions, 0 meaning no limit.
+func (api *PrivateMinerAPI) SetFillDeadline(deadline int) bool {
+	if deadline < 0 {
+		return false
+	}
+	api.e.Miner().SetFillDeadline(time.Duration(deadline) * time.Millisecond)
+	return true
+}
+
+// PendingTransactions is the ordered list of transactions of the pending block,
+// along with the transaction selection mode of the miner.
+type PendingTransactions struct {
+	Number        hexutil.Uint64 `json:"number"`
+	ParentHash    common.Hash    `json:"parentHash"`
+	Deterministic bool           `json:"deterministic"`
+	Seed          hexutil.Uint64 `json:"seed"`
+	Transactions  []common.Hash  `json:"transactions"`
+}
+
+// PendingTransactions returns the hashes of the transactions of the pending
+// block, in the order they were included.
+func (api *PrivateMinerAPI) PendingTransactions() (*PendingTransactions, error) {
+	block := api.e.Miner().PendingBlock()
+	if block == nil {
+		return nil, errors.New("no pending block")
+	}
+	config := api.e.config.Miner
+	pending := &PendingTransactions{
+		Number:        hexutil.Uint64(block.NumberU64()),
+		ParentHash:    block.ParentHash(),
+		Deterministic: config.Deterministic,
+		Seed:          hexutil.Uint64(config.Seed),
+		Transactions:  make([]common.Hash, len(block.Transactions())),
+	}
+	for i, tx := range block.Transactions() {
+		pending.Transactions[i] = tx.Hash()
+	}
+	return pending, nil
+}
+
 // GetHashrate returns the current hashrate of the miner.
 func (api *PrivateMinerAPI) GetHashrate() uint64 {
 	return api.e.miner.HashRate()
@@ -166,6 +210,12 @@ func NewPrivateAdminAPI(ong *Orange) *PrivateAdminAPI {
 	return &PrivateAdminAPI{ong: ong}
 }
 
+// ForkReadiness reports the next scheduled fork of the chain, the estimated time
+// left until it and the fraction of the connected peers prepared for it.
+func (api *PrivateAdminAPI) ForkReadiness() *ForkReadiness {
+	return api.ong.forkMonitor.readiness()
+}
+
 // ExportChain exports the current blockchain into a local file,
 // or a range of blocks if first and last are non-nil
 func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64) (bool, error) {
@@ -321,6 +371,122 @@ func (api *PrivateDebugAPI) Preimage(ctx context.Context, hash common.Hash) (hex
 	return nil, errors.New("unknown preimage")
 }
 
+// DbSizes returns the approximate storage usage of each data category in the
+// chain database, in bytes.
+func (api *PrivateDebugAPI) DbSizes() map[string]uint64 {
+	return rawdb.DatabaseSizes(api.ong.ChainDb())
+}
+
+// PauseSnapshotGeneration suspends the background generation of the state
+// snapshot, prioritizing block processing and RPC latency over its completion.
+func (api *PrivateDebugAPI) PauseSnapshotGeneration() error {
+	snaps := api.ong.BlockChain().Snapshots()
+	if snaps == nil {
+		return errors.New("state snapshot disabled")
+	}
+	snaps.PauseGeneration()
+	return nil
+}
+
+// ResumeSnapshotGeneration continues a paused background generation of the
+// state snapshot.
+func (api *PrivateDebugAPI) ResumeSnapshotGeneration() error {
+	snaps := api.ong.BlockChain().Snapshots()
+	if snaps == nil {
+		return errors.New("state snapshot disabled")
+	}
+	snaps.ResumeGeneration()
+	return nil
+}
+
+// SnapshotStatus reports the progress of the background generation of the state
+// snapshot: the indexed accounts and slots, the position reached and the time
+// estimated until completion.
+func (api *PrivateDebugAPI) SnapshotStatus() (*snapshot.GeneratorStatus, error) {
+	snaps := api.ong.BlockChain().Snapshots()
+	if snaps == nil {
+		return nil, errors.New("state snapshot disabled")
+	}
+	return snaps.GenerationStatus()
+}
+
+// GetSideChains lists the recent non-canonical chain segments known to the node,
+// with their total difficulty and the canonical block they fork from.
+func (api *PrivateDebugAPI) GetSideChains() []*SideChain {
+	return api.ong.sideChains.sideChains()
+}
+
+// GetUncleByHash returns the full block of an uncle or side block by hash, if
+// the node has its body. If fullTx is true the transactions are returned in
+// full detail, otherwise only their hashes.
+func (api *PrivateDebugAPI) GetUncleByHash(hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	block := api.ong.blockchain.GetBlockByHash(hash)
+	if block == nil {
+		return nil, nil
+	}
+	return ongapi.RPCMarshalBlock(block, true, fullTx)
+}
+
+// BlockPropagation reports when and from which peers the given number of recent
+// blocks were received (16 by default), how long they took to be imported, and
+// how fast the peers relayed the tracked blocks.
+func (api *PrivateDebugAPI) BlockPropagation(limit *int) *PropagationStats {
+	n := 16
+	if limit != nil {
+		n = *limit
+	}
+	return api.ong.handler.propagation.stats(n)
+}
+
+// ImportEvent is the notification of an attempt to import a block.
+type ImportEvent struct {
+	Number  hexutil.Uint64 `json:"number"`
+	Hash    common.Hash    `json:"hash"`
+	Status  string         `json:"status"`           // "canonical", "side" or "rejected"
+	Code    string         `json:"code,omitempty"`   // Classification of the rejection
+	Reason  string         `json:"reason,omitempty"` // Error the block was rejected with
+	Elapsed hexutil.Uint64 `json:"elapsed"`          // Import time in milliseconds
+}
+
+// ImportEvents creates a subscription notified of the outcome of every block
+// import, accepted into the canonical or a side chain, or rejected along with
+// the reason. It is called as debug_subscribe("importEvents").
+func (api *PrivateDebugAPI) ImportEvents(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan core.BlockImportEvent, 16)
+		sub := api.ong.BlockChain().SubscribeBlockImportEvent(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				notification := &ImportEvent{
+					Number:  hexutil.Uint64(ev.Block.NumberU64()),
+					Hash:    ev.Block.Hash(),
+					Status:  ev.Status.String(),
+					Code:    ev.Code.String(),
+					Elapsed: hexutil.Uint64(ev.Elapsed.Milliseconds()),
+				}
+				if ev.Err != nil {
+					notification.Reason = ev.Err.Error()
+				}
+				notifier.Notify(rpcSub.ID, notification)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // BadBlockArgs represents the entries in the list returned when bad blocks are queried.
 type BadBlockArgs struct {
 	Hash  common.Hash            `json:"hash"`
@@ -463,57 +629,96 @@ func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeRes
 
 // GetModifiedAccountsByNumber returns all accounts that have changed between the
 // two blocks specified. A change is defined as a difference in nonce, balance,
-// code hash, or storage hash.
+// code hash, or storage hash, and includes accounts created or deleted.
 //
 // With one parameter, returns the list of accounts modified in the specified block.
 func (api *PrivateDebugAPI) GetModifiedAccountsByNumber(startNum uint64, endNum *uint64) ([]common.Address, error) {
-	var startBlock, endBlock *types.Block
-
-	startBlock = api.ong.blockchain.GetBlockByNumber(startNum)
-	if startBlock == nil {
-		return nil, fmt.Errorf("start block %x not found", startNum)
-	}
-
-	if endNum == nil {
-		endBlock = startBlock
-		startBlock = api.ong.blockchain.GetBlockByHash(startBlock.ParentHash())
-		if startBlock == nil {
-			return nil, fmt.Errorf("block %x has no parent", endBlock.Number())
-		}
-	} else {
-		endBlock = api.ong.blockchain.GetBlockByNumber(*endNum)
-		if endBlock == nil {
-			return nil, fmt.Errorf("end block %d not found", *endNum)
-		}
+	startBlock, endBlock, err := api.blocksByNumber(startNum, endNum)
+	if err != nil {
+		return nil, err
 	}
 	return api.getModifiedAccounts(startBlock, endBlock)
 }
 
 // GetModifiedAccountsByHash returns all accounts that have changed between the
 // two blocks specified. A change is defined as a difference in nonce, balance,
-// code hash, or storage hash.
+// code hash, or storage hash, and includes accounts created or deleted.
 //
 // With one parameter, returns the list of accounts modified in the specified block.
 func (api *PrivateDebugAPI) GetModifiedAccountsByHash(startHash common.Hash, endHash *common.Hash) ([]common.Address, error) {
-	var startBlock, endBlock *types.Block
-	startBlock = api.ong.blockchain.GetBlockByHash(startHash)
+	startBlock, endBlock, err := api.blocksByHash(startHash, endHash)
+	if err != nil {
+		return nil, err
+	}
+	return api.getModifiedAccounts(startBlock, endBlock)
+}
+
+// GetModifiedStorageByNumber returns the storage slots of an account that have
+// changed between the two blocks specified, including the ones set or cleared.
+//
+// With two parameters, returns the slots modified in the specified block.
+func (api *PrivateDebugAPI) GetModifiedStorageByNumber(address common.Address, startNum uint64, endNum *uint64) ([]common.Hash, error) {
+	startBlock, endBlock, err := api.blocksByNumber(startNum, endNum)
+	if err != nil {
+		return nil, err
+	}
+	return api.getModifiedStorage(address, startBlock, endBlock)
+}
+
+// GetModifiedStorageByHash returns the storage slots of an account that have
+// changed between the two blocks specified, including the ones set or cleared.
+//
+// With two parameters, returns the slots modified in the specified block.
+func (api *PrivateDebugAPI) GetModifiedStorageByHash(address common.Address, startHash common.Hash, endHash *common.Hash) ([]common.Hash, error) {
+	startBlock, endBlock, err := api.blocksByHash(startHash, endHash)
+	if err != nil {
+		return nil, err
+	}
+	return api.getModifiedStorage(address, startBlock, endBlock)
+}
+
+// blocksByNumber resolves the blocks of a modification query by number. Without
+// end block, the range is the single block at the start number.
+func (api *PrivateDebugAPI) blocksByNumber(startNum uint64, endNum *uint64) (*types.Block, *types.Block, error) {
+	startBlock := api.ong.blockchain.GetBlockByNumber(startNum)
 	if startBlock == nil {
-		return nil, fmt.Errorf("start block %x not found", startHash)
+		return nil, nil, fmt.Errorf("start block %x not found", startNum)
 	}
+	if endNum == nil {
+		endBlock := startBlock
+		startBlock = api.ong.blockchain.GetBlockByHash(startBlock.ParentHash())
+		if startBlock == nil {
+			return nil, nil, fmt.Errorf("block %x has no parent", endBlock.Number())
+		}
+		return startBlock, endBlock, nil
+	}
+	endBlock := api.ong.blockchain.GetBlockByNumber(*endNum)
+	if endBlock == nil {
+		return nil, nil, fmt.Errorf("end block %d not found", *endNum)
+	}
+	return startBlock, endBlock, nil
+}
 
+// blocksByHash resolves the blocks of a modification query by hash. Without end
+// block, the range is the single block of the start hash.
+func (api *PrivateDebugAPI) blocksByHash(startHash common.Hash, endHash *common.Hash) (*types.Block, *types.Block, error) {
+	startBlock := api.ong.blockchain.GetBlockByHash(startHash)
+	if startBlock == nil {
+		return nil, nil, fmt.Errorf("start block %x not found", startHash)
+	}
 	if endHash == nil {
-		endBlock = startBlock
+		endBlock := startBlock
 		startBlock = api.ong.blockchain.GetBlockByHash(startBlock.ParentHash())
 		if startBlock == nil {
-			return nil, fmt.Errorf("block %x has no parent", endBlock.Number())
-		}
-	} else {
-		endBlock = api.ong.blockchain.GetBlockByHash(*endHash)
-		if endBlock == nil {
-			return nil, fmt.Errorf("end block %x not found", *endHash)
+			return nil, nil, fmt.Errorf("block %x has no parent", endBlock.Number())
 		}
+		return startBlock, endBlock, nil
 	}
-	return api.getModifiedAccounts(startBlock, endBlock)
+	endBlock := api.ong.blockchain.GetBlockByHash(*endHash)
+	if endBlock == nil {
+		return nil, nil, fmt.Errorf("end block %x not found", *endHash)
+	}
+	return startBlock, endBlock, nil
 }
 
 func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Block) ([]common.Address, error) {
@@ -530,16 +735,89 @@ func (api *PrivateDebugAPI) getModifiedAccounts(startBlock, endBlock *types.Bloc
 	if err != nil {
 		return nil, err
 	}
-	diff, _ := trie.NewDifferenceIterator(oldTrie.NodeIterator([]byte{}), newTrie.NodeIterator([]byte{}))
-	iter := trie.NewIterator(diff)
+	keys, err := modifiedKeys(oldTrie, newTrie)
+	if err != nil {
+		return nil, err
+	}
+	dirty := make([]common.Address, len(keys))
+	for i, key := range keys {
+		dirty[i] = common.BytesToAddress(key)
+	}
+	return dirty, nil
+}
 
-	var dirty []common.Address
+func (api *PrivateDebugAPI) getModifiedStorage(address common.Address, startBlock, endBlock *types.Block) ([]common.Hash, error) {
+	if startBlock.Number().Uint64() >= endBlock.Number().Uint64() {
+		return nil, fmt.Errorf("start block height (%d) must be less than end block height (%d)", startBlock.Number().Uint64(), endBlock.Number().Uint64())
+	}
+	triedb := api.ong.BlockChain().StateCache().TrieDB()
+
+	oldTrie, err := storageTrieAt(triedb, startBlock.Root(), address)
+	if err != nil {
+		return nil, err
+	}
+	newTrie, err := storageTrieAt(triedb, endBlock.Root(), address)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := modifiedKeys(oldTrie, newTrie)
+	if err != nil {
+		return nil, err
+	}
+	dirty := make([]common.Hash, len(keys))
+	for i, key := range keys {
+		dirty[i] = common.BytesToHash(key)
+	}
+	return dirty, nil
+}
+
+// storageTrieAt opens the storage trie of an account in the state of the given
+// root. Missing accounts have an empty storage.
+func storageTrieAt(triedb *trie.Database, root common.Hash, address common.Address) (*trie.SecureTrie, error) {
+	accTrie, err := trie.NewSecure(root, triedb)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := accTrie.TryGet(address.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	storageRoot := types.EmptyRootHash
+	if len(enc) > 0 {
+		var account state.Account
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			return nil, err
+		}
+		storageRoot = account.Root
+	}
+	return trie.NewSecure(storageRoot, triedb)
+}
+
+// modifiedKeys returns the preimages of the keys whose values differ between two
+// tries: the ones added or changed in the new trie, and the ones deleted from the
+// old one. Subtries present in both are skipped by their hash, so the cost is
+// proportional to the size of the difference rather than of the tries.
+func modifiedKeys(oldTrie, newTrie *trie.SecureTrie) ([][]byte, error) {
+	changed, _ := trie.NewDifferenceIterator(oldTrie.NodeIterator(nil), newTrie.NodeIterator(nil))
+	deleted, _ := trie.NewDifferenceIterator(newTrie.NodeIterator(nil), oldTrie.NodeIterator(nil))
+	union, _ := trie.NewUnionIterator([]trie.NodeIterator{changed, deleted})
+	iter := trie.NewIterator(union)
+
+	var (
+		keys [][]byte
+		last []byte
+	)
 	for iter.Next() {
+		// Changed values are found in both tries, next to each other
+		if last != nil && bytes.Equal(iter.Key, last) {
+			continue
+		}
+		last = iter.Key
 		key := newTrie.GetKey(iter.Key)
 		if key == nil {
 			return nil, fmt.Errorf("no preimage found for hash %x", iter.Key)
 		}
-		dirty = append(dirty, common.BytesToAddress(key))
+		keys = append(keys, key)
 	}
-	return dirty, nil
+	return keys, iter.Err
 }
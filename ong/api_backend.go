@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"time"
 
 	"github.com/ong2020/go-orange/accounts"
 	"github.com/ong2020/go-orange/common"
@@ -31,6 +32,7 @@ import (
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/core/vm"
 	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/internal/ongapi"
 	"github.com/ong2020/go-orange/miner"
 	"github.com/ong2020/go-orange/ong/downloader"
 	"github.com/ong2020/go-orange/ong/gasprice"
@@ -45,6 +47,9 @@ type OngAPIBackend struct {
 	allowUnprotectedTxs bool
 	ong                 *Orange
 	gpo                 *gasprice.Oracle
+	budget              *ongapi.ExecutionBudget
+	abis                *ongapi.ABIRegistry
+	nonces              *ongapi.NonceReservations
 }
 
 // ChainConfig returns the active chain configuration.
@@ -71,6 +76,7 @@ func (b *OngAPIBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumb
 	if number == rpc.LatestBlockNumber {
 		return b.ong.blockchain.CurrentBlock().Header(), nil
 	}
+	number = b.resolveFinalityTag(number)
 	return b.ong.blockchain.GetHeaderByNumber(uint64(number)), nil
 }
 
@@ -105,9 +111,17 @@ func (b *OngAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 	if number == rpc.LatestBlockNumber {
 		return b.ong.blockchain.CurrentBlock(), nil
 	}
+	number = b.resolveFinalityTag(number)
 	return b.ong.blockchain.GetBlockByNumber(uint64(number)), nil
 }
 
+// resolveFinalityTag maps the "safe" and "finalized" block tags onto the
+// canonical block at the configured confirmation depth.
+func (b *OngAPIBackend) resolveFinalityTag(number rpc.BlockNumber) rpc.BlockNumber {
+	head := b.ong.blockchain.CurrentBlock().NumberU64()
+	return ongapi.ResolveFinalityTag(number, head, b.ong.config.RPCSafeDepth, b.ong.config.RPCFinalizedDepth)
+}
+
 func (b *OngAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
 	return b.ong.blockchain.GetBlockByHash(hash), nil
 }
@@ -147,7 +161,7 @@ func (b *OngAPIBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.B
 	if header == nil {
 		return nil, nil, errors.New("header not found")
 	}
-	stateDb, err := b.ong.BlockChain().StateAt(header.Root)
+	stateDb, err := b.stateAt(header.Root)
 	return stateDb, header, err
 }
 
@@ -166,12 +180,22 @@ func (b *OngAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 		if blockNrOrHash.RequireCanonical && b.ong.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
 			return nil, nil, errors.New("hash is not currently canonical")
 		}
-		stateDb, err := b.ong.BlockChain().StateAt(header.Root)
+		stateDb, err := b.stateAt(header.Root)
 		return stateDb, header, err
 	}
 	return nil, nil, errors.New("invalid arguments; neither block nor hash specified")
 }
 
+// stateAt opens the state of a block for the RPC methods. It is read from the
+// snapshot if the snapshot covers the block, or from the trie otherwise.
+func (b *OngAPIBackend) stateAt(root common.Hash) (*state.StateDB, error) {
+	stateDb, err := b.ong.BlockChain().StateAt(root)
+	if err == nil && b.ong.config.RPCVerifySnapshot {
+		stateDb.VerifySnapshotReads()
+	}
+	return stateDb, err
+}
+
 func (b *OngAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	return b.ong.blockchain.GetReceiptsByHash(hash), nil
 }
@@ -192,12 +216,14 @@ func (b *OngAPIBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
 	return b.ong.blockchain.GetTdByHash(hash)
 }
 
-func (b *OngAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+func (b *OngAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
 	vmError := func() error { return nil }
-
+	if vmConfig == nil {
+		vmConfig = b.ong.blockchain.GetVMConfig()
+	}
 	txContext := core.NewEVMTxContext(msg)
 	context := core.NewEVMBlockContext(header, b.ong.BlockChain(), nil)
-	return vm.NewEVM(context, txContext, state, b.ong.blockchain.Config(), *b.ong.blockchain.GetVMConfig()), vmError, nil
+	return vm.NewEVM(context, txContext, state, b.ong.blockchain.Config(), *vmConfig), vmError, nil
 }
 
 func (b *OngAPIBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
@@ -274,7 +300,19 @@ func (b *OngAPIBackend) Downloader() *downloader.Downloader {
 }
 
 func (b *OngAPIBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
-	return b.gpo.SuggestPrice(ctx)
+	b.ong.lock.RLock()
+	gpo := b.gpo
+	b.ong.lock.RUnlock()
+
+	return gpo.SuggestPrice(ctx)
+}
+
+func (b *OngAPIBackend) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	b.ong.lock.RLock()
+	gpo := b.gpo
+	b.ong.lock.RUnlock()
+
+	return gpo.FeeHistory(ctx, blocks, lastBlock, rewardPercentiles)
 }
 
 func (b *OngAPIBackend) ChainDb() ongdb.Database {
@@ -301,10 +339,46 @@ func (b *OngAPIBackend) RPCGasCap() uint64 {
 	return b.ong.config.RPCGasCap
 }
 
+func (b *OngAPIBackend) RPCEVMTimeout() time.Duration {
+	return b.ong.config.RPCEVMTimeout
+}
+
+func (b *OngAPIBackend) RPCBlockRangeCap() uint64 {
+	return b.ong.config.RPCBlockRangeCap
+}
+
+func (b *OngAPIBackend) RPCPersistentFilters() bool {
+	return b.ong.config.RPCPersistentFilters
+}
+
+func (b *OngAPIBackend) RPCTraceTimeout() time.Duration {
+	return b.ong.config.RPCTraceTimeout
+}
+
+func (b *OngAPIBackend) RPCExecutionBudget() *ongapi.ExecutionBudget {
+	return b.budget
+}
+
+func (b *OngAPIBackend) ABIRegistry() *ongapi.ABIRegistry {
+	return b.abis
+}
+
+func (b *OngAPIBackend) NonceReservations() *ongapi.NonceReservations {
+	return b.nonces
+}
+
 func (b *OngAPIBackend) RPCTxFeeCap() float64 {
 	return b.ong.config.RPCTxFeeCap
 }
 
+func (b *OngAPIBackend) RPCTxSpendCap() float64 {
+	return b.ong.config.RPCTxSpendCap
+}
+
+func (b *OngAPIBackend) RPCDailySpendCap() float64 {
+	return b.ong.config.RPCDailySpendCap
+}
+
 func (b *OngAPIBackend) BloomStatus() (uint64, uint64) {
 	sections, _, _ := b.ong.bloomIndexer.Sections()
 	return params.BloomBitsBlocks, sections
@@ -29,6 +29,7 @@ import (
 	"github.com/ong2020/go-orange/core/rawdb"
 	"github.com/ong2020/go-orange/core/state"
 	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/trie"
 )
 
 var dumper = spew.ConfigState{Indent: "    "}
@@ -207,3 +208,84 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the modified keys between two tries include the added, changed and
+// deleted ones, and only those.
+func TestModifiedKeys(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db       = state.NewDatabase(rawdb.NewMemoryDatabase())
+		contract = common.Address{0xcc}
+	)
+	statedb, _ := state.New(common.Hash{}, db, nil)
+	statedb.SetNonce(contract, 1)
+	for i := byte(1); i <= 100; i++ {
+		statedb.SetBalance(common.Address{i}, big.NewInt(int64(i)))
+		statedb.SetState(contract, common.Hash{i}, common.Hash{i})
+	}
+	oldRoot, _ := statedb.Commit(true)
+
+	statedb, _ = state.New(oldRoot, db, nil)
+	statedb.AddBalance(common.Address{1}, big.NewInt(1))   // changed
+	statedb.SetBalance(common.Address{200}, big.NewInt(1)) // added
+	statedb.Suicide(common.Address{2})                     // deleted
+	statedb.SetState(contract, common.Hash{1}, common.Hash{0xff})
+	statedb.SetState(contract, common.Hash{2}, common.Hash{})
+	statedb.SetState(contract, common.Hash{200}, common.Hash{0x01})
+	newRoot, _ := statedb.Commit(true)
+
+	oldTrie, _ := trie.NewSecure(oldRoot, db.TrieDB())
+	newTrie, _ := trie.NewSecure(newRoot, db.TrieDB())
+	keys, err := modifiedKeys(oldTrie, newTrie)
+	if err != nil {
+		t.Fatalf("failed to diff account tries: %v", err)
+	}
+	want := []common.Address{{1}, {2}, {200}, contract}
+	if have := toAddresses(keys); !sameAddresses(have, want) {
+		t.Errorf("modified accounts mismatch: have %v, want %v", have, want)
+	}
+	oldStorage, _ := storageTrieAt(db.TrieDB(), oldRoot, contract)
+	newStorage, _ := storageTrieAt(db.TrieDB(), newRoot, contract)
+	keys, err = modifiedKeys(oldStorage, newStorage)
+	if err != nil {
+		t.Fatalf("failed to diff storage tries: %v", err)
+	}
+	if len(keys) != 3 {
+		t.Errorf("modified slot count mismatch: have %d, want 3", len(keys))
+	}
+	for _, key := range keys {
+		if slot := common.BytesToHash(key); slot != (common.Hash{1}) && slot != (common.Hash{2}) && slot != (common.Hash{200}) {
+			t.Errorf("unexpected modified slot %x", slot)
+		}
+	}
+	// Missing accounts have an empty storage
+	emptyStorage, _ := storageTrieAt(db.TrieDB(), newRoot, common.Address{0xee})
+	if keys, _ := modifiedKeys(emptyStorage, newStorage); len(keys) != 100 {
+		t.Errorf("modified slot count against missing account mismatch: have %d, want 100", len(keys))
+	}
+}
+
+func toAddresses(keys [][]byte) []common.Address {
+	addrs := make([]common.Address, len(keys))
+	for i, key := range keys {
+		addrs[i] = common.BytesToAddress(key)
+	}
+	return addrs
+}
+
+func sameAddresses(a, b []common.Address) bool {
+	set := make(map[common.Address]bool)
+	for _, addr := range a {
+		set[addr] = true
+	}
+	if len(set) != len(a) || len(a) != len(b) {
+		return false
+	}
+	for _, addr := range b {
+		if !set[addr] {
+			return false
+		}
+	}
+	return true
+}
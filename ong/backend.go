@@ -70,6 +70,7 @@ type Orange struct {
 	handler            *handler
 	ongDialCandidates  enode.Iterator
 	snapDialCandidates enode.Iterator
+	snapScheduler      *snap.Scheduler
 
 	// DB interfaces
 	chainDb ongdb.Database // Block chain database
@@ -82,6 +83,9 @@ type Orange struct {
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
 
+	forkMonitor *forkMonitor      // Monitor of the next scheduled fork
+	sideChains  *sideChainTracker // Tracker of the recent side chains
+
 	APIBackend *OngAPIBackend
 
 	miner     *miner.Miner
@@ -122,11 +126,11 @@ func New(stack *node.Node, config *ongconfig.Config) (*Orange, error) {
 	log.Info("Allocated trie memory caches", "clean", common.StorageSize(config.TrieCleanCache)*1024*1024, "dirty", common.StorageSize(config.TrieDirtyCache)*1024*1024)
 
 	// Assemble the Orange object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "ong/db/chaindata/")
+	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "ong/db/chaindata/", config.DatabaseFreezerCompression)
 	if err != nil {
 		return nil, err
 	}
-	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideBerlin)
+	chainConfig, genesisHash, genesisErr := ongconfig.SetupGenesis(chainDb, config)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
 	}
@@ -180,6 +184,8 @@ func New(stack *node.Node, config *ongconfig.Config) (*Orange, error) {
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
+			SnapshotRejournal:   config.SnapshotRejournal,
+			SnapshotThrottle:    config.SnapshotThrottle,
 			Preimages:           config.Preimages,
 		}
 	)
@@ -219,10 +225,12 @@ func New(stack *node.Node, config *ongconfig.Config) (*Orange, error) {
 	}); err != nil {
 		return nil, err
 	}
+	ong.forkMonitor = newForkMonitor(ong.blockchain, ong.handler.peers.forkIDs)
+	ong.sideChains = newSideChainTracker(ong.blockchain)
 	ong.miner = miner.New(ong, &config.Miner, chainConfig, ong.EventMux(), ong.engine, ong.isLocalBlock)
 	ong.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
 
-	ong.APIBackend = &OngAPIBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, ong, nil}
+	ong.APIBackend = &OngAPIBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, ong, nil, ongapi.NewExecutionBudget(config.RPCEVMBudget), ongapi.NewABIRegistry(), ongapi.NewNonceReservations()}
 	if ong.APIBackend.allowUnprotectedTxs {
 		log.Info("Unprotected transactions allowed")
 	}
@@ -240,6 +248,11 @@ func New(stack *node.Node, config *ongconfig.Config) (*Orange, error) {
 	if err != nil {
 		return nil, err
 	}
+	ong.snapScheduler = snap.NewScheduler(snap.ServeConfig{
+		MaxRequests:     config.SnapServeRequests,
+		MaxPeerRequests: config.SnapServePeerRequests,
+		Bandwidth:       config.SnapServeBandwidth * 1024,
+	})
 	// Start the RPC service
 	ong.netRPCService = ongapi.NewPublicNetAPI(ong.p2pServer, config.NetworkId)
 
@@ -507,7 +520,7 @@ func (s *Orange) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
 func (s *Orange) Protocols() []p2p.Protocol {
 	protos := ong.MakeProtocols((*ongHandler)(s.handler), s.networkID, s.ongDialCandidates)
 	if s.config.SnapshotCache > 0 {
-		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates)...)
+		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.handler), s.snapDialCandidates, s.snapScheduler)...)
 	}
 	return protos
 }
@@ -530,6 +543,10 @@ func (s *Orange) Start() error {
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	// Start watching for upcoming forks and side chains
+	s.forkMonitor.start()
+	s.sideChains.start()
 	return nil
 }
 
@@ -537,6 +554,8 @@ func (s *Orange) Start() error {
 // Orange protocol.
 func (s *Orange) Stop() error {
 	// Stop all the peer-related stuff first.
+	s.forkMonitor.stop()
+	s.sideChains.stop()
 	s.handler.Stop()
 
 	// Then stop everything else.
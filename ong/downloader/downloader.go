@@ -494,6 +494,15 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	d.syncStatsChainHeight = height
 	d.syncStatsLock.Unlock()
 
+	// Resume the state sync of a previous, interrupted run if its pivot is still
+	// recent enough to be served by the network, instead of restarting the state
+	// download on a fresh root
+	if mode == FastSync && height > uint64(fsMinFullBlocks) {
+		if last := d.resumablePivot(latest, origin); last != nil && last.Number.Cmp(pivot.Number) < 0 {
+			log.Info("Resuming state sync of previous pivot", "number", last.Number, "hash", last.Hash(), "root", last.Root)
+			pivot = last
+		}
+	}
 	// Ensure our origin point is below any fast sync pivot point
 	if mode == FastSync {
 		if height <= uint64(fsMinFullBlocks) {
@@ -645,6 +654,33 @@ func (d *Downloader) Terminate() {
 	d.Cancel()
 }
 
+// resumablePivot returns the pivot header of a previous, interrupted fast sync
+// if it's on the chain shared with the remote peer (at or below the common
+// ancestor origin), its state wasn't committed yet and it's not stale compared
+// to the remote head, nil otherwise.
+func (d *Downloader) resumablePivot(latest *types.Header, origin uint64) *types.Header {
+	last := rawdb.ReadLastPivotNumber(d.stateDB)
+	if last == nil || *last == 0 || *last > origin {
+		return nil
+	}
+	if latest.Number.Uint64() >= *last+2*uint64(fsMinFullBlocks)-uint64(reorgProtHeaderDelay) {
+		return nil // Pivot became stale while we were offline
+	}
+	if d.blockchain != nil && d.blockchain.CurrentBlock().NumberU64() >= *last {
+		return nil // Pivot already committed
+	}
+	// Walk back the local header chain to the pivot, it's at most a few dozen
+	// headers below the head
+	header := d.lightchain.CurrentHeader()
+	for header != nil && header.Number.Uint64() > *last {
+		header = d.lightchain.GetHeaderByHash(header.ParentHash)
+	}
+	if header == nil || header.Number.Uint64() != *last {
+		return nil
+	}
+	return header
+}
+
 // fetchHead retrieves the head header and prior pivot block (if available) from
 // a remote peer.
 func (d *Downloader) fetchHead(p *peerConnection) (head *types.Header, pivot *types.Header, err error) {
@@ -1736,3 +1736,33 @@ func testCheckpointEnforcement(t *testing.T, protocol uint, mode SyncMode) {
 		assertOwnChain(t, tester, chain.len())
 	}
 }
+
+// Tests that the pivot of an interrupted fast sync is only resumed while it's on
+// the chain shared with the remote peer and not yet stale.
+func TestResumablePivot(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(300)
+	if _, err := tester.InsertHeaderChain(chain.headersByNumber(1, 299, 0, false), 1); err != nil {
+		t.Fatalf("failed to insert headers: %v", err)
+	}
+	head := func(number int64) *types.Header { return &types.Header{Number: big.NewInt(number)} }
+
+	if pivot := tester.downloader.resumablePivot(head(300), 299); pivot != nil {
+		t.Errorf("pivot resumed without previous sync: %d", pivot.Number)
+	}
+	rawdb.WriteLastPivotNumber(tester.stateDb, 200)
+
+	if pivot := tester.downloader.resumablePivot(head(300), 299); pivot == nil || pivot.Hash() != chain.chain[200] {
+		t.Errorf("previous pivot not resumed: have %v, want %x", pivot, chain.chain[200])
+	}
+	if pivot := tester.downloader.resumablePivot(head(int64(200+2*fsMinFullBlocks)), 299); pivot != nil {
+		t.Errorf("stale pivot resumed: %d", pivot.Number)
+	}
+	if pivot := tester.downloader.resumablePivot(head(300), 150); pivot != nil {
+		t.Errorf("pivot above common ancestor resumed: %d", pivot.Number)
+	}
+}
@@ -19,6 +19,8 @@ package fetcher
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"math/big"
 	mrand "math/rand"
 	"sort"
 	"time"
@@ -59,6 +61,10 @@ const (
 	// txGatherSlack is the interval used to collate almost-expired announces
 	// with network fetches.
 	txGatherSlack = 100 * time.Millisecond
+
+	// txFeeHistoryWeight is the weight of the previous average in the moving
+	// average of the gas price of the transactions delivered by a peer.
+	txFeeHistoryWeight = 3
 )
 
 var (
@@ -82,6 +88,8 @@ var (
 	txRequestFailMeter    = metrics.NewRegisteredMeter("ong/fetcher/transaction/request/fail", nil)
 	txRequestDoneMeter    = metrics.NewRegisteredMeter("ong/fetcher/transaction/request/done", nil)
 	txRequestTimeoutMeter = metrics.NewRegisteredMeter("ong/fetcher/transaction/request/timeout", nil)
+	txRequestDedupMeter   = metrics.NewRegisteredMeter("ong/fetcher/transaction/request/dedup", nil)
+	txRequestSizeHist     = metrics.NewRegisteredHistogram("ong/fetcher/transaction/request/size", nil, metrics.NewExpDecaySample(1028, 0.015))
 
 	txReplyInMeter          = metrics.NewRegisteredMeter("ong/fetcher/transaction/replies/in", nil)
 	txReplyKnownMeter       = metrics.NewRegisteredMeter("ong/fetcher/transaction/replies/known", nil)
@@ -117,6 +125,7 @@ type txDelivery struct {
 	origin string        // Identifier of the peer originating the notification
 	hashes []common.Hash // Batch of transaction hashes having been delivered
 	direct bool          // Whonger this is a direct reply or a broadcast
+	fee    uint64        // Average gas price of the delivered transactions
 }
 
 // txDrop is the notiication that a peer has disconnected.
@@ -133,6 +142,7 @@ type txDrop struct {
 //   - When a connected peer doesn't have in-flight retrieval requests, any
 //     transaction queued up (and announced by the peer) are allocated to the
 //     peer and moved into a fetching status until it's fulfilled or fails.
+//     Peers delivering the better paying transactions are allocated first.
 //
 // The invariants of the fetcher are:
 //   - Each tracked transaction (hash) must only be present in one of the
@@ -167,10 +177,14 @@ type TxFetcher struct {
 	requests   map[string]*txRequest               // In-flight transaction retrievals
 	alternates map[common.Hash]map[string]struct{} // In-flight transaction alternate origins if retrieval fails
 
+	// Moving average of the gas price of the transactions delivered by each peer,
+	// used as the expected fee of its announcements to prioritise retrievals.
+	fees map[string]uint64
+
 	// Callbacks
-	hasTx    func(common.Hash) bool             // Retrieves a tx from the local txpool
-	addTxs   func([]*types.Transaction) []error // Insert a batch of transactions into local txpool
-	fetchTxs func(string, []common.Hash) error  // Retrieves a set of txs from a remote peer
+	hasTx    func(common.Hash) bool                     // Retrieves a tx from the local txpool
+	addTxs   func(string, []*types.Transaction) []error // Insert a batch of transactions from a peer into local txpool
+	fetchTxs func(string, []common.Hash) error          // Retrieves a set of txs from a remote peer
 
 	step  chan struct{} // Notification channel when the fetcher loop iterates
 	clock mclock.Clock  // Time wrapper to simulate in tests
@@ -179,14 +193,14 @@ type TxFetcher struct {
 
 // NewTxFetcher creates a transaction fetcher to retrieve transaction
 // based on hash announcements.
-func NewTxFetcher(hasTx func(common.Hash) bool, addTxs func([]*types.Transaction) []error, fetchTxs func(string, []common.Hash) error) *TxFetcher {
+func NewTxFetcher(hasTx func(common.Hash) bool, addTxs func(string, []*types.Transaction) []error, fetchTxs func(string, []common.Hash) error) *TxFetcher {
 	return NewTxFetcherForTests(hasTx, addTxs, fetchTxs, mclock.System{}, nil)
 }
 
 // NewTxFetcherForTests is a testing Method to mock out the realtime clock with
 // a simulated version and the internal randomness with a deterministic one.
 func NewTxFetcherForTests(
-	hasTx func(common.Hash) bool, addTxs func([]*types.Transaction) []error, fetchTxs func(string, []common.Hash) error,
+	hasTx func(common.Hash) bool, addTxs func(string, []*types.Transaction) []error, fetchTxs func(string, []common.Hash) error,
 	clock mclock.Clock, rand *mrand.Rand) *TxFetcher {
 	return &TxFetcher{
 		notify:      make(chan *txAnnounce),
@@ -201,6 +215,7 @@ func NewTxFetcherForTests(
 		fetching:    make(map[common.Hash]string),
 		requests:    make(map[string]*txRequest),
 		alternates:  make(map[common.Hash]map[string]struct{}),
+		fees:        make(map[string]uint64),
 		underpriced: mapset.NewSet(),
 		hasTx:       hasTx,
 		addTxs:      addTxs,
@@ -271,11 +286,12 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 	// re-requesting them and dropping the peer in case of malicious transfers.
 	var (
 		added       = make([]common.Hash, 0, len(txs))
+		fees        = new(big.Int)
 		duplicate   int64
 		underpriced int64
 		otherreject int64
 	)
-	errs := f.addTxs(txs)
+	errs := f.addTxs(peer, txs)
 	for i, err := range errs {
 		if err != nil {
 			// Track the transaction hash if the price is too low for us.
@@ -302,6 +318,7 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 			}
 		}
 		added = append(added, txs[i].Hash())
+		fees.Add(fees, txs[i].GasPrice())
 	}
 	if direct {
 		txReplyKnownMeter.Mark(duplicate)
@@ -312,8 +329,16 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 		txBroadcastUnderpricedMeter.Mark(underpriced)
 		txBroadcastOtherRejectMeter.Mark(otherreject)
 	}
+	delivery := &txDelivery{origin: peer, hashes: added, direct: direct}
+	if len(added) > 0 {
+		if fee := fees.Div(fees, big.NewInt(int64(len(added)))); fee.IsUint64() {
+			delivery.fee = fee.Uint64()
+		} else {
+			delivery.fee = math.MaxUint64
+		}
+	}
 	select {
-	case f.cleanup <- &txDelivery{origin: peer, hashes: added, direct: direct}:
+	case f.cleanup <- delivery:
 		return nil
 	case <-f.quit:
 		return errTerminated
@@ -520,6 +545,14 @@ func (f *TxFetcher) loop() {
 			f.rescheduleTimeout(timeoutTimer, timeoutTrigger)
 
 		case delivery := <-f.cleanup:
+			// Track the expected fee of the transactions of the peer
+			if len(delivery.hashes) > 0 {
+				if fee, ok := f.fees[delivery.origin]; ok {
+					f.fees[delivery.origin] = fee/(txFeeHistoryWeight+1)*txFeeHistoryWeight + delivery.fee/(txFeeHistoryWeight+1)
+				} else {
+					f.fees[delivery.origin] = delivery.fee
+				}
+			}
 			// Independent if the delivery was direct or broadcast, remove all
 			// traces of the hash from internal trackers
 			for _, hash := range delivery.hashes {
@@ -627,6 +660,7 @@ func (f *TxFetcher) loop() {
 					f.rescheduleWait(waitTimer, waitTrigger)
 				}
 			}
+			delete(f.fees, drop.peer)
 			// Clean up any active requests
 			var request *txRequest
 			if request = f.requests[drop.peer]; request != nil {
@@ -770,9 +804,14 @@ func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, timeout chan struct{},
 		if len(f.announces[peer]) == 0 {
 			return // continue in the for-each
 		}
-		hashes := make([]common.Hash, 0, maxTxRetrievals)
+		var (
+			hashes = make([]common.Hash, 0, maxTxRetrievals)
+			dedups int64
+		)
 		f.forEachHash(f.announces[peer], func(hash common.Hash) bool {
-			if _, ok := f.fetching[hash]; !ok {
+			if _, ok := f.fetching[hash]; ok {
+				dedups++ // Already being retrieved from another peer
+			} else {
 				// Mark the hash as fetching and stash away possible alternates
 				f.fetching[hash] = peer
 
@@ -790,10 +829,13 @@ func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, timeout chan struct{},
 			}
 			return true // continue in the for-each
 		})
+		txRequestDedupMeter.Mark(dedups)
+
 		// If any hashes were allocated, request them from the peer
 		if len(hashes) > 0 {
 			f.requests[peer] = &txRequest{hashes: hashes, time: f.clock.Now()}
 			txRequestOutMeter.Mark(int64(len(hashes)))
+			txRequestSizeHist.Update(int64(len(hashes)))
 
 			go func(peer string, hashes []common.Hash) {
 				// Try to fetch the transactions, but in case of a request
@@ -811,23 +853,23 @@ func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, timeout chan struct{},
 	}
 }
 
-// forEachPeer does a range loop over a map of peers in production, but during
-// testing it does a deterministic sorted random to allow reproducing issues.
+// forEachPeer does a range loop over a map of peers, visiting the peers with the
+// highest expected fee first. Peers with the same expected fee are visited in map
+// order in production, but during testing in a deterministic sorted random order
+// to allow reproducing issues.
 func (f *TxFetcher) forEachPeer(peers map[string]struct{}, do func(peer string)) {
-	// If we're running production, use whatever Go's map gives us
-	if f.rand == nil {
-		for peer := range peers {
-			do(peer)
-		}
-		return
-	}
-	// We're running the test suite, make iteration deterministic
 	list := make([]string, 0, len(peers))
 	for peer := range peers {
 		list = append(list, peer)
 	}
-	sort.Strings(list)
-	rotateStrings(list, f.rand.Intn(len(list)))
+	// We're running the test suite, make iteration deterministic
+	if f.rand != nil {
+		sort.Strings(list)
+		rotateStrings(list, f.rand.Intn(len(list)))
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return f.fees[list[i]] > f.fees[list[j]]
+	})
 	for _, peer := range list {
 		do(peer)
 	}
@@ -30,6 +30,7 @@ import (
 	"github.com/ong2020/go-orange/common/hexutil"
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/internal/ongapi"
 	"github.com/ong2020/go-orange/ongdb"
 	"github.com/ong2020/go-orange/rpc"
 )
@@ -43,6 +44,9 @@ type filter struct {
 	crit     FilterCriteria
 	logs     []*types.Log
 	s        *Subscription // associated subscription in event system
+	head     uint64        // chain head when the changes were last served, if persisted
+	polled   time.Time     // time the filter was last polled, or installed
+	dirty    bool          // whether the journal entry is outdated, if persisted
 }
 
 // PublicFilterAPI offers support to create and manage filters. This will allow external clients to retrieve various
@@ -56,6 +60,7 @@ type PublicFilterAPI struct {
 	filtersMu sync.Mutex
 	filters   map[rpc.ID]*filter
 	timeout   time.Duration
+	persist   bool // whether the filters are journaled to the database
 }
 
 // NewPublicFilterAPI returns a new PublicFilterAPI instance.
@@ -66,8 +71,13 @@ func NewPublicFilterAPI(backend Backend, lightMode bool, timeout time.Duration)
 		events:  NewEventSystem(backend, lightMode),
 		filters: make(map[rpc.ID]*filter),
 		timeout: timeout,
+		persist: backend.RPCPersistentFilters(),
 	}
+	api.restoreFilters()
 	go api.timeoutLoop(timeout)
+	if api.persist {
+		go api.journalLoop()
+	}
 
 	return api
 }
@@ -79,13 +89,18 @@ func (api *PublicFilterAPI) timeoutLoop(timeout time.Duration) {
 	ticker := time.NewTicker(timeout)
 	defer ticker.Stop()
 	for {
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-api.events.closed:
+			return
+		}
 		api.filtersMu.Lock()
 		for id, f := range api.filters {
 			select {
 			case <-f.deadline.C:
 				toUninstall = append(toUninstall, f.s)
 				delete(api.filters, id)
+				api.forgetFilter(id)
 			default:
 				continue
 			}
@@ -110,13 +125,25 @@ func (api *PublicFilterAPI) timeoutLoop(timeout time.Duration) {
 //
 // https://ong.wiki/json-rpc/API#ong_newpendingtransactionfilter
 func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
+	return api.newPendingTransactionFilter("", time.Now())
+}
+
+// newPendingTransactionFilter installs a pending transaction filter under the
+// given id, or under the id of its subscription if empty. The filter expires the
+// timeout after the given poll time.
+func (api *PublicFilterAPI) newPendingTransactionFilter(id rpc.ID, polled time.Time) rpc.ID {
 	var (
-		pendingTxs   = make(chan []common.Hash)
+		pendingTxs   = make(chan []*types.Transaction)
 		pendingTxSub = api.events.SubscribePendingTxs(pendingTxs)
 	)
+	if id == "" {
+		id = pendingTxSub.ID
+	}
+	f := &filter{typ: PendingTransactionsSubscription, deadline: time.NewTimer(api.timeout - time.Since(polled)), hashes: make([]common.Hash, 0), s: pendingTxSub, polled: polled}
 
 	api.filtersMu.Lock()
-	api.filters[pendingTxSub.ID] = &filter{typ: PendingTransactionsSubscription, deadline: time.NewTimer(api.timeout), hashes: make([]common.Hash, 0), s: pendingTxSub}
+	api.filters[id] = f
+	api.journalFilter(id, f)
 	api.filtersMu.Unlock()
 
 	go func() {
@@ -124,43 +151,59 @@ func (api *PublicFilterAPI) NewPendingTransactionFilter() rpc.ID {
 			select {
 			case ph := <-pendingTxs:
 				api.filtersMu.Lock()
-				if f, found := api.filters[pendingTxSub.ID]; found {
-					f.hashes = append(f.hashes, ph...)
+				if f, found := api.filters[id]; found {
+					for _, tx := range ph {
+						f.hashes = append(f.hashes, tx.Hash())
+					}
 				}
 				api.filtersMu.Unlock()
 			case <-pendingTxSub.Err():
 				api.filtersMu.Lock()
-				delete(api.filters, pendingTxSub.ID)
+				delete(api.filters, id)
+				api.forgetFilter(id)
 				api.filtersMu.Unlock()
 				return
 			}
 		}
 	}()
 
-	return pendingTxSub.ID
+	return id
 }
 
 // NewPendingTransactions creates a subscription that is triggered each time a transaction
 // enters the transaction pool and was signed from one of the transactions this nodes manages.
-func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+//
+// By default the subscription delivers transaction hashes. The optional criteria
+// request full transaction objects instead and restrict the notifications to the
+// transactions matching the given senders, recipients and minimum gas price.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context, crit *PendingTxCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
-
+	if crit == nil {
+		crit = new(PendingTxCriteria)
+	}
 	rpcSub := notifier.CreateSubscription()
 
 	go func() {
-		txHashes := make(chan []common.Hash, 128)
-		pendingTxSub := api.events.SubscribePendingTxs(txHashes)
+		pendingTxs := make(chan []*types.Transaction, 128)
+		pendingTxSub := api.events.SubscribePendingTxs(pendingTxs)
 
 		for {
 			select {
-			case hashes := <-txHashes:
+			case txs := <-pendingTxs:
 				// To keep the original behaviour, send a single tx hash in one notification.
 				// TODO(rjl493456442) Send a batch of tx hashes in one notification
-				for _, h := range hashes {
-					notifier.Notify(rpcSub.ID, h)
+				for _, tx := range txs {
+					if !crit.matches(tx) {
+						continue
+					}
+					if crit.FullTx {
+						notifier.Notify(rpcSub.ID, ongapi.NewRPCPendingTransaction(tx))
+					} else {
+						notifier.Notify(rpcSub.ID, tx.Hash())
+					}
 				}
 			case <-rpcSub.Err():
 				pendingTxSub.Unsubscribe()
@@ -175,18 +218,79 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// PendingTxCriteria selects the pending transactions delivered by the
+// newPendingTransactions subscription and their representation.
+type PendingTxCriteria struct {
+	FullTx      bool             `json:"fullTx"`      // deliver full transaction objects instead of hashes
+	From        []common.Address `json:"from"`        // only transactions sent by one of these accounts
+	To          []common.Address `json:"to"`          // only transactions sent to one of these accounts
+	MinGasPrice *hexutil.Big     `json:"minGasPrice"` // only transactions paying at least this gas price
+}
+
+// UnmarshalJSON sets *crit fields from the given JSON object. A plain boolean is
+// accepted too, selecting between hashes and full transactions.
+func (crit *PendingTxCriteria) UnmarshalJSON(data []byte) error {
+	var fullTx bool
+	if err := json.Unmarshal(data, &fullTx); err == nil {
+		*crit = PendingTxCriteria{FullTx: fullTx}
+		return nil
+	}
+	type input PendingTxCriteria
+	var raw input
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*crit = PendingTxCriteria(raw)
+	return nil
+}
+
+// matches reports whether the transaction satisfies the criteria.
+func (crit *PendingTxCriteria) matches(tx *types.Transaction) bool {
+	if crit.MinGasPrice != nil && tx.GasPrice().Cmp(crit.MinGasPrice.ToInt()) < 0 {
+		return false
+	}
+	if len(crit.To) > 0 && (tx.To() == nil || !includes(crit.To, *tx.To())) {
+		return false
+	}
+	if len(crit.From) > 0 {
+		// Use the most permissive signer for replay-protected transactions, the
+		// homestead one otherwise, same as the RPC transaction representation.
+		var signer types.Signer = types.HomesteadSigner{}
+		if tx.Protected() {
+			signer = types.LatestSignerForChainID(tx.ChainId())
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil || !includes(crit.From, from) {
+			return false
+		}
+	}
+	return true
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with ong_getFilterChanges.
 //
 // https://ong.wiki/json-rpc/API#ong_newblockfilter
 func (api *PublicFilterAPI) NewBlockFilter() rpc.ID {
+	return api.newBlockFilter("", make([]common.Hash, 0), api.headNumber(), time.Now())
+}
+
+// newBlockFilter installs a block filter under the given id, or under the id of
+// its subscription if empty, with the given block hashes pending delivery. The
+// filter expires the timeout after the given poll time.
+func (api *PublicFilterAPI) newBlockFilter(id rpc.ID, hashes []common.Hash, head uint64, polled time.Time) rpc.ID {
 	var (
 		headers   = make(chan *types.Header)
 		headerSub = api.events.SubscribeNewHeads(headers)
 	)
+	if id == "" {
+		id = headerSub.ID
+	}
+	f := &filter{typ: BlocksSubscription, deadline: time.NewTimer(api.timeout - time.Since(polled)), hashes: hashes, s: headerSub, head: head, polled: polled}
 
 	api.filtersMu.Lock()
-	api.filters[headerSub.ID] = &filter{typ: BlocksSubscription, deadline: time.NewTimer(api.timeout), hashes: make([]common.Hash, 0), s: headerSub}
+	api.filters[id] = f
+	api.journalFilter(id, f)
 	api.filtersMu.Unlock()
 
 	go func() {
@@ -194,20 +298,21 @@ func (api *PublicFilterAPI) NewBlockFilter() rpc.ID {
 			select {
 			case h := <-headers:
 				api.filtersMu.Lock()
-				if f, found := api.filters[headerSub.ID]; found {
+				if f, found := api.filters[id]; found {
 					f.hashes = append(f.hashes, h.Hash())
 				}
 				api.filtersMu.Unlock()
 			case <-headerSub.Err():
 				api.filtersMu.Lock()
-				delete(api.filters, headerSub.ID)
+				delete(api.filters, id)
+				api.forgetFilter(id)
 				api.filtersMu.Unlock()
 				return
 			}
 		}
 	}()
 
-	return headerSub.ID
+	return id
 }
 
 // NewHeads send a notification each time a new (header) block is appended to the chain.
@@ -296,64 +401,96 @@ type FilterCriteria orange.FilterQuery
 //
 // https://ong.wiki/json-rpc/API#ong_newfilter
 func (api *PublicFilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
-	logs := make(chan []*types.Log)
-	logsSub, err := api.events.SubscribeLogs(orange.FilterQuery(crit), logs)
+	return api.newLogFilter("", crit, make([]*types.Log, 0), api.headNumber(), time.Now())
+}
+
+// newLogFilter installs a log filter under the given id, or under the id of its
+// subscription if empty, with the given logs pending delivery. The filter expires
+// the timeout after the given poll time.
+func (api *PublicFilterAPI) newLogFilter(id rpc.ID, crit FilterCriteria, logs []*types.Log, head uint64, polled time.Time) (rpc.ID, error) {
+	matchedLogs := make(chan []*types.Log)
+	logsSub, err := api.events.SubscribeLogs(orange.FilterQuery(crit), matchedLogs)
 	if err != nil {
 		return "", err
 	}
+	if id == "" {
+		id = logsSub.ID
+	}
+	f := &filter{typ: LogsSubscription, crit: crit, deadline: time.NewTimer(api.timeout - time.Since(polled)), logs: logs, s: logsSub, head: head, polled: polled}
 
 	api.filtersMu.Lock()
-	api.filters[logsSub.ID] = &filter{typ: LogsSubscription, crit: crit, deadline: time.NewTimer(api.timeout), logs: make([]*types.Log, 0), s: logsSub}
+	api.filters[id] = f
+	api.journalFilter(id, f)
 	api.filtersMu.Unlock()
 
 	go func() {
 		for {
 			select {
-			case l := <-logs:
+			case l := <-matchedLogs:
 				api.filtersMu.Lock()
-				if f, found := api.filters[logsSub.ID]; found {
+				if f, found := api.filters[id]; found {
 					f.logs = append(f.logs, l...)
 				}
 				api.filtersMu.Unlock()
 			case <-logsSub.Err():
 				api.filtersMu.Lock()
-				delete(api.filters, logsSub.ID)
+				delete(api.filters, id)
+				api.forgetFilter(id)
 				api.filtersMu.Unlock()
 				return
 			}
 		}
 	}()
 
-	return logsSub.ID, nil
+	return id, nil
 }
 
 // GetLogs returns logs matching the given argument that are stored within the state.
+// The logs are streamed to the client, so large results aren't buffered in full.
 //
 // https://ong.wiki/json-rpc/API#ong_getlogs
-func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+func (api *PublicFilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) (rpc.Stream, error) {
 	var filter *Filter
 	if crit.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
 		filter = NewBlockFilter(api.backend, *crit.BlockHash, crit.Addresses, crit.Topics)
 	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if crit.FromBlock != nil {
-			begin = crit.FromBlock.Int64()
+		var err error
+		if filter, err = api.rangeFilter(ctx, crit); err != nil {
+			return nil, err
 		}
-		end := rpc.LatestBlockNumber.Int64()
-		if crit.ToBlock != nil {
-			end = crit.ToBlock.Int64()
+		if filter == nil {
+			return streamLogs(nil), nil
 		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics)
 	}
-	// Run the filter and return all the logs
+	// Run the filter and stream all the logs
 	logs, err := filter.Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), err
+	return streamLogs(logs), nil
+}
+
+// rangeFilter resolves the block range of the criteria, defaulting both ends to
+// the latest block, and constructs a range filter over it. The range may span
+// at most the number of blocks capped by the backend. A range starting after its
+// end matches nothing, for which no filter is returned.
+func (api *PublicFilterAPI) rangeFilter(ctx context.Context, crit FilterCriteria) (*Filter, error) {
+	blocks := rpc.BlockRange{
+		From: rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber),
+		To:   rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber),
+	}
+	if crit.FromBlock != nil {
+		blocks.From = rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(crit.FromBlock.Int64()))
+	}
+	if crit.ToBlock != nil {
+		blocks.To = rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(crit.ToBlock.Int64()))
+	}
+	begin, end, err := blocks.Resolve(ongapi.NewBlockResolver(ctx, api.backend), api.backend.RPCBlockRangeCap())
+	if err != nil || begin > end {
+		return nil, err
+	}
+	return NewRangeFilter(api.backend, int64(begin), int64(end), crit.Addresses, crit.Topics), nil
 }
 
 // UninstallFilter removes the filter with the given filter id.
@@ -364,6 +501,7 @@ func (api *PublicFilterAPI) UninstallFilter(id rpc.ID) bool {
 	f, found := api.filters[id]
 	if found {
 		delete(api.filters, id)
+		api.forgetFilter(id)
 	}
 	api.filtersMu.Unlock()
 	if found {
@@ -377,7 +515,7 @@ func (api *PublicFilterAPI) UninstallFilter(id rpc.ID) bool {
 // If the filter could not be found an empty array of logs is returned.
 //
 // https://ong.wiki/json-rpc/API#ong_getfilterlogs
-func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error) {
+func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) (rpc.Stream, error) {
 	api.filtersMu.Lock()
 	f, found := api.filters[id]
 	api.filtersMu.Unlock()
@@ -391,24 +529,20 @@ func (api *PublicFilterAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*ty
 		// Block filter requested, construct a single-shot filter
 		filter = NewBlockFilter(api.backend, *f.crit.BlockHash, f.crit.Addresses, f.crit.Topics)
 	} else {
-		// Convert the RPC block numbers into internal representations
-		begin := rpc.LatestBlockNumber.Int64()
-		if f.crit.FromBlock != nil {
-			begin = f.crit.FromBlock.Int64()
+		var err error
+		if filter, err = api.rangeFilter(ctx, f.crit); err != nil {
+			return nil, err
 		}
-		end := rpc.LatestBlockNumber.Int64()
-		if f.crit.ToBlock != nil {
-			end = f.crit.ToBlock.Int64()
+		if filter == nil {
+			return streamLogs(nil), nil
 		}
-		// Construct the range filter
-		filter = NewRangeFilter(api.backend, begin, end, f.crit.Addresses, f.crit.Topics)
 	}
-	// Run the filter and return all the logs
+	// Run the filter and stream all the logs
 	logs, err := filter.Logs(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return returnLogs(logs), nil
+	return streamLogs(logs), nil
 }
 
 // GetFilterChanges returns the logs for the filter with the given id since
@@ -430,6 +564,15 @@ func (api *PublicFilterAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
 		}
 		f.deadline.Reset(api.timeout)
 
+		// All the changes up to the current head are served below. The journal
+		// entry is updated by the next flush.
+		f.polled = time.Now()
+		if api.persist {
+			if f.typ != PendingTransactionsSubscription {
+				f.head = api.headNumber()
+			}
+			f.dirty = true
+		}
 		switch f.typ {
 		case PendingTransactionsSubscription, BlocksSubscription:
 			hashes := f.hashes
@@ -463,6 +606,19 @@ func returnLogs(logs []*types.Log) []*types.Log {
 	return logs
 }
 
+// streamLogs is a helper that streams the given logs as the result of a method,
+// yielding an empty array if there are none.
+func streamLogs(logs []*types.Log) rpc.Stream {
+	return func(ctx context.Context, yield func(interface{}) error) error {
+		for _, log := range logs {
+			if err := yield(log); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
 // UnmarshalJSON sets *args fields with given data.
 func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
 	type input struct {
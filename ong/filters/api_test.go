@@ -19,9 +19,12 @@ package filters
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/crypto"
 	"github.com/ong2020/go-orange/rpc"
 )
 
@@ -183,3 +186,45 @@ func TestUnmarshalJSONNewFilterArgs(t *testing.T) {
 		t.Fatalf("expected 0 topics, got %d topics", len(test7.Topics[2]))
 	}
 }
+
+func TestPendingTxCriteria(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		other   = common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268")
+		signer  = types.LatestSignerForChainID(big.NewInt(1))
+		cheap   = types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 0, To: &other, Gas: 21000, GasPrice: big.NewInt(1)})
+		pricey  = types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 1, To: &other, Gas: 21000, GasPrice: big.NewInt(100)})
+		created = types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: 2, Gas: 53000, GasPrice: big.NewInt(100)})
+	)
+	tests := []struct {
+		input   string
+		fullTx  bool
+		matches []bool // cheap, pricey, created
+	}{
+		{`true`, true, []bool{true, true, true}},
+		{`false`, false, []bool{true, true, true}},
+		{`{"fullTx":true,"minGasPrice":"0x10"}`, true, []bool{false, true, true}},
+		{fmt.Sprintf(`{"to":["%v"]}`, other.Hex()), false, []bool{true, true, false}},
+		{fmt.Sprintf(`{"from":["%v"]}`, sender.Hex()), false, []bool{true, true, true}},
+		{fmt.Sprintf(`{"from":["%v"]}`, other.Hex()), false, []bool{false, false, false}},
+	}
+	for i, test := range tests {
+		var crit PendingTxCriteria
+		if err := json.Unmarshal([]byte(test.input), &crit); err != nil {
+			t.Fatalf("test %d: failed to parse criteria: %v", i, err)
+		}
+		if crit.FullTx != test.fullTx {
+			t.Errorf("test %d: fullTx mismatch: have %v, want %v", i, crit.FullTx, test.fullTx)
+		}
+		for j, tx := range []*types.Transaction{cheap, pricey, created} {
+			if have := crit.matches(tx); have != test.matches[j] {
+				t.Errorf("test %d, tx %d: match mismatch: have %v, want %v", i, j, have, test.matches[j])
+			}
+		}
+	}
+	var crit PendingTxCriteria
+	if err := json.Unmarshal([]byte(`"0x1"`), &crit); err == nil {
+		t.Errorf("expected error for invalid criteria")
+	}
+}
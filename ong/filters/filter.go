@@ -45,6 +45,9 @@ type Backend interface {
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+
+	RPCBlockRangeCap() uint64
+	RPCPersistentFilters() bool
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -135,6 +138,20 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	}
 	head := header.Number.Uint64()
 
+	// Resolve the "safe" and "finalized" tags through the backend
+	for _, number := range []*int64{&f.begin, &f.end} {
+		if *number != rpc.SafeBlockNumber.Int64() && *number != rpc.FinalizedBlockNumber.Int64() {
+			continue
+		}
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(*number))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			return nil, errors.New("unknown block")
+		}
+		*number = header.Number.Int64()
+	}
 	if f.begin == -1 {
 		f.begin = int64(head)
 	}
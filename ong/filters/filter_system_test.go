@@ -52,12 +52,22 @@ type testBackend struct {
 	rmLogsFeed      event.Feed
 	pendingLogsFeed event.Feed
 	chainFeed       event.Feed
+	rangeCap        uint64
+	persistFilters  bool
 }
 
 func (b *testBackend) ChainDb() ongdb.Database {
 	return b.db
 }
 
+func (b *testBackend) RPCBlockRangeCap() uint64 {
+	return b.rangeCap
+}
+
+func (b *testBackend) RPCPersistentFilters() bool {
+	return b.persistFilters
+}
+
 func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
 	var (
 		hash common.Hash
@@ -18,8 +18,10 @@ package filters
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"math/big"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -30,6 +32,7 @@ import (
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/crypto"
 	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rpc"
 )
 
 func makeReceipt(addr common.Address) *types.Receipt {
@@ -223,3 +226,119 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+func TestGetLogsBlockRange(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, rangeCap: 5}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		genesis = core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ongash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	tests := []struct {
+		from, to int64
+		fail     bool
+	}{
+		{0, 4, false},
+		{0, 5, true},
+		{6, rpc.LatestBlockNumber.Int64(), false},
+		{5, rpc.LatestBlockNumber.Int64(), true},
+		{4, 2, false},
+		{11, rpc.LatestBlockNumber.Int64(), false},
+		{rpc.LatestBlockNumber.Int64(), rpc.LatestBlockNumber.Int64(), false},
+	}
+	for i, test := range tests {
+		crit := FilterCriteria{FromBlock: big.NewInt(test.from), ToBlock: big.NewInt(test.to)}
+		_, err := api.GetLogs(context.Background(), crit)
+		if test.fail && err == nil {
+			t.Errorf("test %d: expected range %d-%d to be rejected", i, test.from, test.to)
+		}
+		if !test.fail && err != nil {
+			t.Errorf("test %d: unexpected error for range %d-%d: %v", i, test.from, test.to, err)
+		}
+	}
+	// Ranges starting after their end, e.g. polling past the head, match nothing
+	crit := FilterCriteria{FromBlock: big.NewInt(11), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())}
+	stream, err := api.GetLogs(context.Background(), crit)
+	if err != nil {
+		t.Fatalf("empty range refused: %v", err)
+	}
+	var n int
+	stream(context.Background(), func(interface{}) error { n++; return nil })
+	if n != 0 {
+		t.Fatalf("empty range returned %d logs", n)
+	}
+}
+
+// Tests that ong_getLogs streams its result to the clients.
+func TestGetLogsStream(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewPublicFilterAPI(backend, false, deadline)
+		genesis = core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ongash.NewFaker(), db, 5, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{{Topics: []common.Hash{common.BigToHash(big.NewInt(int64(i)))}}}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 1, big.NewInt(1), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("ong", api); err != nil {
+		t.Fatal(err)
+	}
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+	client, err := rpc.DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	crit := map[string]interface{}{"fromBlock": "0x0", "toBlock": "latest"}
+	stream, err := client.StreamContext(context.Background(), "ong_getLogs", crit)
+	if err != nil {
+		t.Fatalf("failed to stream logs: %v", err)
+	}
+	defer stream.Close()
+
+	var n int64
+	for ; stream.Next(); n++ {
+		var log struct{ Topics []common.Hash }
+		if err := stream.Decode(&log); err != nil {
+			t.Fatalf("failed to decode log %d: %v", n, err)
+		}
+		if len(log.Topics) != 1 || log.Topics[0] != common.BigToHash(big.NewInt(n)) {
+			t.Errorf("log %d: topics mismatch: %v", n, log.Topics)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("log count mismatch: have %d, want %d", n, 5)
+	}
+	// Streamed logs are also a regular array result, empty if nothing matches
+	var logs []json.RawMessage
+	if err := client.Call(&logs, "ong_getLogs", crit); err != nil || len(logs) != 5 {
+		t.Fatalf("call result mismatch: have %d logs, err %v", len(logs), err)
+	}
+	crit["topics"] = []interface{}{common.Hash{0xff}}
+	if err := client.Call(&logs, "ong_getLogs", crit); err != nil || logs == nil || len(logs) != 0 {
+		t.Fatalf("empty result mismatch: have %v, err %v", logs, err)
+	}
+}
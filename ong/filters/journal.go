@@ -0,0 +1,259 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+const (
+	// journalFlushInterval is the interval at which the journal entries of the
+	// polled filters are updated.
+	journalFlushInterval = 30 * time.Second
+
+	// maxReplayBlocks is the maximum number of blocks missed while the node was
+	// down that are replayed into a restored filter. Filters lagging further
+	// behind are dropped, even if the block range cap allows more.
+	maxReplayBlocks = 1024
+)
+
+// journalEntry is a filter persisted to the database, so that it survives node
+// restarts. The changes of the filter are served up to the chain head recorded
+// in the entry, they are replayed from that block on when restoring the filter.
+// The changes of the head block itself may thus be returned twice, the ones of
+// pending transactions are lost.
+//
+// The entries of the polled filters are updated in batches every flush interval,
+// so the changes served since the last flush are returned again after a crash.
+type journalEntry struct {
+	ID        rpc.ID           `json:"id"`
+	Type      Type             `json:"type"`
+	BlockHash *common.Hash     `json:"blockHash,omitempty"`
+	FromBlock *big.Int         `json:"fromBlock,omitempty"`
+	ToBlock   *big.Int         `json:"toBlock,omitempty"`
+	Addresses []common.Address `json:"addresses,omitempty"`
+	Topics    [][]common.Hash  `json:"topics,omitempty"`
+	Head      uint64           `json:"head"`
+	Polled    int64            `json:"polled"` // unix time of the last poll
+}
+
+// headNumber returns the number of the current chain head if the filters are
+// persisted, the position the changes of the new filters start from.
+func (api *PublicFilterAPI) headNumber() uint64 {
+	if !api.persist {
+		return 0
+	}
+	header, err := api.backend.HeaderByNumber(context.Background(), rpc.LatestBlockNumber)
+	if header == nil || err != nil {
+		return 0
+	}
+	return header.Number.Uint64()
+}
+
+// journalFilter persists the filter with the given id if enabled. The filters
+// lock must be held.
+func (api *PublicFilterAPI) journalFilter(id rpc.ID, f *filter) {
+	if api.persist {
+		api.writeJournalEntry(api.chainDb, id, f)
+	}
+}
+
+// writeJournalEntry writes the journal entry of a filter. The filters lock must
+// be held.
+func (api *PublicFilterAPI) writeJournalEntry(db ongdb.KeyValueWriter, id rpc.ID, f *filter) {
+	entry := &journalEntry{
+		ID:        id,
+		Type:      f.typ,
+		BlockHash: f.crit.BlockHash,
+		FromBlock: f.crit.FromBlock,
+		ToBlock:   f.crit.ToBlock,
+		Addresses: f.crit.Addresses,
+		Topics:    f.crit.Topics,
+		Head:      f.head,
+		Polled:    f.polled.Unix(),
+	}
+	blob, err := json.Marshal(entry)
+	if err != nil {
+		log.Error("Failed to encode persisted filter", "id", id, "err", err)
+		return
+	}
+	rawdb.WriteFilterJournalEntry(db, string(id), blob)
+	f.dirty = false
+}
+
+// journalLoop periodically updates the journal entries of the polled filters
+// until the event system is torn down together with the backend.
+func (api *PublicFilterAPI) journalLoop() {
+	ticker := time.NewTicker(journalFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			api.flushJournal()
+		case <-api.events.closed:
+			return
+		}
+	}
+}
+
+// flushJournal writes the outdated journal entries in a single batch.
+func (api *PublicFilterAPI) flushJournal() {
+	api.filtersMu.Lock()
+	defer api.filtersMu.Unlock()
+
+	batch := api.chainDb.NewBatch()
+	for id, f := range api.filters {
+		if f.dirty {
+			api.writeJournalEntry(batch, id, f)
+		}
+	}
+	if batch.ValueSize() > 0 {
+		if err := batch.Write(); err != nil {
+			log.Error("Failed to update persisted filters", "err", err)
+		}
+	}
+}
+
+// replayCap returns the maximum number of missed blocks replayed into a restored
+// filter, the lowest of the block range cap and maxReplayBlocks.
+func (api *PublicFilterAPI) replayCap() uint64 {
+	if limit := api.backend.RPCBlockRangeCap(); limit > 0 && limit < maxReplayBlocks {
+		return limit
+	}
+	return maxReplayBlocks
+}
+
+// forgetFilter removes the uninstalled filter with the given id from the journal.
+func (api *PublicFilterAPI) forgetFilter(id rpc.ID) {
+	if api.persist {
+		rawdb.DeleteFilterJournalEntry(api.chainDb, string(id))
+	}
+}
+
+// restoreFilters reinstalls the persisted filters under their original ids along
+// with the changes they missed, dropping the ones which can't be restored or
+// expired while the node was down. The journal is discarded if persistence is
+// disabled.
+//
+// It runs when the API is created, before the node starts syncing, so no block
+// is imported while the missed changes are collected.
+func (api *PublicFilterAPI) restoreFilters() {
+	var (
+		journal  = rawdb.ReadFilterJournal(api.chainDb)
+		head     = api.headNumber()
+		restored int
+	)
+	for _, blob := range journal {
+		var entry journalEntry
+		if err := json.Unmarshal(blob, &entry); err != nil {
+			log.Error("Invalid persisted filter", "err", err)
+			continue
+		}
+		if !api.persist {
+			rawdb.DeleteFilterJournalEntry(api.chainDb, string(entry.ID))
+			continue
+		}
+		if err := api.restoreFilter(&entry, head); err != nil {
+			log.Warn("Dropped persisted filter", "id", entry.ID, "err", err)
+			rawdb.DeleteFilterJournalEntry(api.chainDb, string(entry.ID))
+			continue
+		}
+		restored++
+	}
+	if api.persist && len(journal) > 0 {
+		log.Info("Restored persisted filters", "restored", restored, "dropped", len(journal)-restored)
+	}
+}
+
+// restoreFilter reinstalls a single persisted filter, keeping the deadline set by
+// its last poll.
+func (api *PublicFilterAPI) restoreFilter(entry *journalEntry, head uint64) error {
+	id, polled := entry.ID, time.Unix(entry.Polled, 0)
+	if time.Since(polled) >= api.timeout {
+		return errors.New("filter expired")
+	}
+	switch entry.Type {
+	case PendingTransactionsSubscription:
+		api.newPendingTransactionFilter(id, polled)
+		return nil
+
+	case BlocksSubscription:
+		if err := rpc.CheckBlockSpan(entry.Head, head, api.replayCap()); err != nil {
+			return err
+		}
+		var hashes []common.Hash
+		for number := entry.Head; number <= head; number++ {
+			header, err := api.backend.HeaderByNumber(context.Background(), rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return errors.New("missed block unavailable")
+			}
+			hashes = append(hashes, header.Hash())
+		}
+		api.newBlockFilter(id, hashes, head, polled)
+		return nil
+
+	case LogsSubscription:
+		crit := FilterCriteria{
+			BlockHash: entry.BlockHash,
+			FromBlock: entry.FromBlock,
+			ToBlock:   entry.ToBlock,
+			Addresses: entry.Addresses,
+			Topics:    entry.Topics,
+		}
+		logs, err := api.missedLogs(&crit, entry.Head, head)
+		if err != nil {
+			return err
+		}
+		_, err = api.newLogFilter(id, crit, logs, head, polled)
+		return err
+	}
+	return errors.New("unknown filter type")
+}
+
+// missedLogs retrieves the logs matching the criteria in the blocks from the
+// recorded position to the current head, within the block range of the criteria.
+func (api *PublicFilterAPI) missedLogs(crit *FilterCriteria, from, to uint64) ([]*types.Log, error) {
+	if err := rpc.CheckBlockSpan(from, to, api.replayCap()); err != nil {
+		return nil, err
+	}
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 && crit.FromBlock.Uint64() > from {
+		from = crit.FromBlock.Uint64()
+	}
+	if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 && crit.ToBlock.Uint64() < to {
+		to = crit.ToBlock.Uint64()
+	}
+	logs := make([]*types.Log, 0)
+	if from > to {
+		return logs, nil
+	}
+	found, err := NewRangeFilter(api.backend, int64(from), int64(to), crit.Addresses, crit.Topics).Logs(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return append(logs, found...), nil
+}
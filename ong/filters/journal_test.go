@@ -0,0 +1,251 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus/ongash"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// Tests that persisted filters are restored under their original ids, returning
+// the changes missed while the node was down.
+func TestPersistentFilters(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, persistFilters: true}
+		genesis = core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+		addr    = common.HexToAddress("0x1111")
+	)
+	// Every block carries a log of the filtered address
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ongash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{{Address: addr}}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, big.NewInt(1), nil))
+	})
+	importBlocks := func(blocks []*types.Block) {
+		for _, block := range blocks {
+			i := block.NumberU64() - 1
+			rawdb.WriteBlock(db, block)
+			rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+			rawdb.WriteHeadBlockHash(db, block.Hash())
+			rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+		}
+	}
+	importBlocks(chain[:4])
+
+	api := NewPublicFilterAPI(backend, false, deadline)
+	logFilter, err := api.NewFilter(FilterCriteria{Addresses: []common.Address{addr}})
+	if err != nil {
+		t.Fatalf("failed to install log filter: %v", err)
+	}
+	blockFilter := api.NewBlockFilter()
+	txFilter := api.NewPendingTransactionFilter()
+
+	// Poll the filters, then import blocks while the node is down. The polls are
+	// journaled by the next flush only.
+	importBlocks(chain[4:5])
+	for _, id := range []rpc.ID{logFilter, blockFilter} {
+		if _, err := api.GetFilterChanges(id); err != nil {
+			t.Fatalf("failed to poll filter: %v", err)
+		}
+	}
+	if head := journaledHead(t, db, logFilter); head != 4 {
+		t.Fatalf("journal updated before flush: head %d, want 4", head)
+	}
+	api.flushJournal()
+	if head := journaledHead(t, db, logFilter); head != 5 {
+		t.Fatalf("journal not flushed: head %d, want 5", head)
+	}
+	importBlocks(chain[5:7])
+
+	api = NewPublicFilterAPI(backend, false, deadline)
+	changes, err := api.GetFilterChanges(logFilter)
+	if err != nil {
+		t.Fatalf("log filter not restored: %v", err)
+	}
+	if logs := changes.([]*types.Log); len(logs) != 3 || logs[0].BlockNumber != 5 || logs[2].BlockNumber != 7 {
+		t.Errorf("missed logs mismatch: have %d logs, want blocks 5-7", len(logs))
+	}
+	changes, err = api.GetFilterChanges(blockFilter)
+	if err != nil {
+		t.Fatalf("block filter not restored: %v", err)
+	}
+	if hashes := changes.([]common.Hash); len(hashes) != 3 || hashes[0] != chain[4].Hash() || hashes[2] != chain[6].Hash() {
+		t.Errorf("missed blocks mismatch: have %d hashes, want blocks 5-7", len(hashes))
+	}
+	if _, err := api.GetFilterChanges(txFilter); err != nil {
+		t.Errorf("pending transaction filter not restored: %v", err)
+	}
+	if _, err := api.GetFilterLogs(context.Background(), logFilter); err != nil {
+		t.Errorf("failed to retrieve restored filter logs: %v", err)
+	}
+
+	// Uninstalled filters are forgotten, filters lagging beyond the range cap dropped
+	api.UninstallFilter(txFilter)
+	api.flushJournal()
+	importBlocks(chain[7:])
+	backend.rangeCap = 2
+
+	api = NewPublicFilterAPI(backend, false, deadline)
+	for _, id := range []rpc.ID{logFilter, blockFilter, txFilter} {
+		if _, err := api.GetFilterChanges(id); err == nil {
+			t.Errorf("filter %s restored", id)
+		}
+	}
+	if journal := rawdb.ReadFilterJournal(db); len(journal) != 0 {
+		t.Errorf("journal not cleaned up: %d entries left", len(journal))
+	}
+}
+
+// journaledHead returns the chain head recorded in the journal entry of a filter.
+func journaledHead(t *testing.T, db ongdb.Database, id rpc.ID) uint64 {
+	for _, blob := range rawdb.ReadFilterJournal(db) {
+		var entry journalEntry
+		if err := json.Unmarshal(blob, &entry); err != nil {
+			t.Fatalf("invalid journal entry: %v", err)
+		}
+		if entry.ID == id {
+			return entry.Head
+		}
+	}
+	t.Fatalf("filter %s not journaled", id)
+	return 0
+}
+
+// Tests that filters which expired while the node was down are dropped, and that
+// the ones polled before keep their remaining time.
+func TestPersistentFiltersExpiry(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, persistFilters: true}
+	)
+	core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+
+	api := NewPublicFilterAPI(backend, false, deadline)
+	expired, live := api.NewBlockFilter(), api.NewBlockFilter()
+
+	// Backdate the last poll of the filters
+	for _, blob := range rawdb.ReadFilterJournal(db) {
+		var entry journalEntry
+		if err := json.Unmarshal(blob, &entry); err != nil {
+			t.Fatalf("invalid journal entry: %v", err)
+		}
+		switch entry.ID {
+		case expired:
+			entry.Polled = time.Now().Add(-deadline).Unix()
+		case live:
+			entry.Polled = time.Now().Add(-deadline / 2).Unix()
+		}
+		blob, _ = json.Marshal(entry)
+		rawdb.WriteFilterJournalEntry(db, string(entry.ID), blob)
+	}
+	api = NewPublicFilterAPI(backend, false, deadline)
+	if _, err := api.GetFilterChanges(expired); err == nil {
+		t.Errorf("expired filter restored")
+	}
+	api.filtersMu.Lock()
+	f := api.filters[live]
+	api.filtersMu.Unlock()
+	if f == nil {
+		t.Fatalf("live filter not restored")
+	}
+	if remaining := f.polled.Add(deadline).Sub(time.Now()); remaining > deadline/2 {
+		t.Errorf("restored filter deadline not kept: %v left", remaining)
+	}
+}
+
+// Tests that the replay of the missed blocks is capped.
+func TestPersistentFiltersReplayCap(t *testing.T) {
+	backend := &testBackend{db: rawdb.NewMemoryDatabase()}
+	api := &PublicFilterAPI{backend: backend}
+
+	for _, tt := range []struct{ rangeCap, want uint64 }{
+		{0, maxReplayBlocks},
+		{2, 2},
+		{maxReplayBlocks * 2, maxReplayBlocks},
+	} {
+		backend.rangeCap = tt.rangeCap
+		if have := api.replayCap(); have != tt.want {
+			t.Errorf("range cap %d: replay cap mismatch: have %d, want %d", tt.rangeCap, have, tt.want)
+		}
+	}
+}
+
+// Tests that the journal is discarded when persistence is disabled.
+func TestPersistentFiltersDisabled(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, persistFilters: true}
+	)
+	core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+
+	id := NewPublicFilterAPI(backend, false, deadline).NewBlockFilter()
+	if journal := rawdb.ReadFilterJournal(db); len(journal) != 1 {
+		t.Fatalf("filter not journaled")
+	}
+	backend.persistFilters = false
+	if _, err := NewPublicFilterAPI(backend, false, deadline).GetFilterChanges(id); err == nil {
+		t.Errorf("filter restored with persistence disabled")
+	}
+	if journal := rawdb.ReadFilterJournal(db); len(journal) != 0 {
+		t.Errorf("journal not discarded: %d entries left", len(journal))
+	}
+}
+
+// Tests that filters dropped by their subscription are removed from the journal
+// and that the journal loop is stopped along with the event system.
+func TestPersistentFiltersTeardown(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db, persistFilters: true}
+	)
+	core.GenesisBlockForTesting(db, common.Address{}, big.NewInt(1000000))
+
+	api := NewPublicFilterAPI(backend, false, deadline)
+	id := api.NewBlockFilter()
+	api.filtersMu.Lock()
+	sub := api.filters[id].s
+	api.filtersMu.Unlock()
+
+	sub.Unsubscribe()
+	for i := 0; len(rawdb.ReadFilterJournal(db)) != 0; i++ {
+		if i == 100 {
+			t.Fatalf("dropped filter not removed from the journal")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Tear down the backend subscriptions, the event system should stop
+	api.events.chainSub.Unsubscribe()
+	select {
+	case <-api.events.closed:
+	case <-time.After(time.Second):
+		t.Fatalf("event system not stopped with the backend")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/forkid"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/metrics"
+)
+
+const (
+	// forkMonitorInterval is the time between two readiness assessments.
+	forkMonitorInterval = time.Minute
+
+	// forkBlockTimeWindow is the number of recent blocks averaged to estimate
+	// the time left until the next fork.
+	forkBlockTimeWindow = 256
+)
+
+var (
+	forkNextGauge   = metrics.NewRegisteredGauge("ong/fork/next", nil)
+	forkBlocksGauge = metrics.NewRegisteredGauge("ong/fork/blocks", nil)
+	forkETAGauge    = metrics.NewRegisteredGauge("ong/fork/eta", nil)
+	forkReadyGauge  = metrics.NewRegisteredGauge("ong/fork/ready", nil)
+)
+
+// forkAlert is an escalation step of the alerts raised as a fork approaches.
+type forkAlert struct {
+	eta      time.Duration                        // Estimated time to the fork below which the step applies
+	interval time.Duration                        // Time between two repeated alerts
+	logfn    func(msg string, ctx ...interface{}) // Logger to raise the alert with
+}
+
+// forkAlerts are the escalation steps of the fork alerts, nearest first.
+var forkAlerts = []forkAlert{
+	{eta: time.Hour, interval: 5 * time.Minute, logfn: log.Error},
+	{eta: 24 * time.Hour, interval: time.Hour, logfn: log.Warn},
+	{eta: 7 * 24 * time.Hour, interval: 6 * time.Hour, logfn: log.Warn},
+	{eta: 30 * 24 * time.Hour, interval: 24 * time.Hour, logfn: log.Info},
+}
+
+// ForkReadiness reports the next scheduled fork of the chain and how many of the
+// connected peers are prepared for it.
+type ForkReadiness struct {
+	Head       uint64  `json:"head"`       // Number of the local head block
+	Next       uint64  `json:"next"`       // Block number of the next scheduled fork (0 = none)
+	Blocks     uint64  `json:"blocks"`     // Number of blocks left until the fork
+	ETA        uint64  `json:"eta"`        // Estimated seconds left until the fork
+	Peers      int     `json:"peers"`      // Number of connected peers
+	ReadyPeers int     `json:"readyPeers"` // Number of peers advertising the fork
+	Readiness  float64 `json:"readiness"`  // Fraction of the peers advertising the fork
+}
+
+// forkMonitor tracks the next scheduled fork of the chain, raising escalating
+// alerts as it approaches so operators don't miss the upgrade, and assesses the
+// readiness of the network from the fork identifiers advertised by the peers.
+type forkMonitor struct {
+	chain *core.BlockChain
+	peers func() []forkid.ID // Fork identifiers advertised by the connected peers
+
+	alerted   uint64    // Fork block the last alert was raised for
+	alertTime time.Time // Time the last alert was raised
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newForkMonitor creates a fork monitor for the given chain.
+func newForkMonitor(chain *core.BlockChain, peers func() []forkid.ID) *forkMonitor {
+	return &forkMonitor{
+		chain: chain,
+		peers: peers,
+		quit:  make(chan struct{}),
+	}
+}
+
+// start launches the periodic fork readiness assessment.
+func (m *forkMonitor) start() {
+	m.wg.Add(1)
+	go m.loop()
+}
+
+// stop terminates the fork monitor.
+func (m *forkMonitor) stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+func (m *forkMonitor) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(forkMonitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.report(m.readiness())
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// readiness assesses the readiness of the network for the next scheduled fork.
+func (m *forkMonitor) readiness() *ForkReadiness {
+	var (
+		config  = m.chain.Config()
+		genesis = m.chain.Genesis().Hash()
+		head    = m.chain.CurrentHeader()
+		number  = head.Number.Uint64()
+		id      = forkid.NewID(config, genesis, number)
+	)
+	status := &ForkReadiness{Head: number, Next: id.Next}
+	if id.Next == 0 {
+		return status
+	}
+	status.Blocks = id.Next - number
+	status.ETA = uint64(m.blockTime(head) * time.Duration(status.Blocks) / time.Second)
+
+	// Peers are ready if they announce the fork as their next one, or if they
+	// already passed it
+	passed := forkid.NewID(config, genesis, id.Next).Hash
+	for _, peer := range m.peers() {
+		status.Peers++
+		if (peer.Hash == id.Hash && peer.Next == id.Next) || peer.Hash == passed {
+			status.ReadyPeers++
+		}
+	}
+	if status.Peers > 0 {
+		status.Readiness = float64(status.ReadyPeers) / float64(status.Peers)
+	}
+	return status
+}
+
+// blockTime estimates the average block time from the recent blocks.
+func (m *forkMonitor) blockTime(head *types.Header) time.Duration {
+	window := uint64(forkBlockTimeWindow)
+	if number := head.Number.Uint64(); number < window {
+		window = number
+	}
+	if window == 0 {
+		return 0
+	}
+	ancestor := m.chain.GetHeaderByNumber(head.Number.Uint64() - window)
+	if ancestor == nil || ancestor.Time > head.Time {
+		return 0
+	}
+	return time.Duration(head.Time-ancestor.Time) * time.Second / time.Duration(window)
+}
+
+// report updates the fork metrics and raises an alert if the fork is near.
+func (m *forkMonitor) report(status *ForkReadiness) {
+	forkNextGauge.Update(int64(status.Next))
+	forkBlocksGauge.Update(int64(status.Blocks))
+	forkETAGauge.Update(int64(status.ETA))
+	forkReadyGauge.Update(int64(status.Readiness * 100))
+
+	if status.Next == 0 {
+		return
+	}
+	eta := time.Duration(status.ETA) * time.Second
+	for _, alert := range forkAlerts {
+		if eta > alert.eta {
+			continue
+		}
+		if m.alerted == status.Next && time.Since(m.alertTime) < alert.interval {
+			return
+		}
+		m.alerted, m.alertTime = status.Next, time.Now()
+
+		alert.logfn("Scheduled fork approaching, upgrade if not yet done", "block", status.Next, "remaining", status.Blocks,
+			"eta", common.PrettyDuration(eta), "peers", status.Peers, "ready", status.ReadyPeers)
+		return
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ong2020/go-orange/consensus/ongash"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/forkid"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/params"
+)
+
+// Tests that the fork monitor estimates the time left until the next fork and
+// the readiness of the peers for it.
+func TestForkReadiness(t *testing.T) {
+	// Schedule Berlin at block 10 and advance the chain to block 5, 10s apart
+	config := *params.TestChainConfig
+	config.BerlinBlock = big.NewInt(10)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{Config: &config}).MustCommit(db)
+
+	chain, _ := core.NewBlockChain(db, nil, &config, ongash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	blocks, _ := core.GenerateChain(&config, genesis, ongash.NewFaker(), db, 5, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	var (
+		current = forkid.NewID(&config, genesis.Hash(), 5)
+		passed  = forkid.NewID(&config, genesis.Hash(), 10)
+		peers   = []forkid.ID{
+			current,                         // Ready, announcing the fork
+			passed,                          // Ready, past the fork
+			{Hash: current.Hash, Next: 0},   // Not upgraded
+			{Hash: current.Hash, Next: 100}, // Misconfigured
+		}
+	)
+	monitor := newForkMonitor(chain, func() []forkid.ID { return peers })
+
+	status := monitor.readiness()
+	want := ForkReadiness{Head: 5, Next: 10, Blocks: 5, ETA: 50, Peers: 4, ReadyPeers: 2, Readiness: 0.5}
+	if *status != want {
+		t.Errorf("readiness mismatch: have %+v, want %+v", *status, want)
+	}
+	// Pass the fork and ensure nothing is reported anymore
+	more, _ := core.GenerateChain(&config, blocks[len(blocks)-1], ongash.NewFaker(), db, 5, nil)
+	if _, err := chain.InsertChain(more); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	status = monitor.readiness()
+	want = ForkReadiness{Head: 10}
+	if *status != want {
+		t.Errorf("readiness mismatch: have %+v, want %+v", *status, want)
+	}
+}
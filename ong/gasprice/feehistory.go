@@ -0,0 +1,173 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// maxFeeHistory is the maximum number of blocks of a fee history.
+const maxFeeHistory = 1024
+
+var (
+	errInvalidPercentile = errors.New("invalid reward percentile")
+	errRequestBeyondHead = errors.New("request beyond head block")
+)
+
+// txGasAndPrice is the gas used by a transaction and its gas price.
+type txGasAndPrice struct {
+	gasUsed uint64
+	price   *big.Int
+}
+
+type sortGasAndPrice []txGasAndPrice
+
+func (s sortGasAndPrice) Len() int           { return len(s) }
+func (s sortGasAndPrice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortGasAndPrice) Less(i, j int) bool { return s[i].price.Cmp(s[j].price) < 0 }
+
+// FeeHistory returns the fee statistics of up to 1024 blocks ending with lastBlock:
+// the number of the oldest block, the ratio of the gas limit used by every block
+// and, for every block, the gas prices paid at the given percentiles of its gas
+// used, sorted by price. The percentiles must be increasing values between 0 and
+// 100. The pending block is not tracked, lastBlock is then the latest one.
+//
+// Blocks have no base fee, the rewards are the full gas prices of the
+// transactions.
+func (gpo *Oracle) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []float64, error) {
+	if blocks < 1 {
+		return new(big.Int), nil, nil, nil
+	}
+	if blocks > maxFeeHistory {
+		blocks = maxFeeHistory
+	}
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 || (i > 0 && p < rewardPercentiles[i-1]) {
+			return nil, nil, nil, fmt.Errorf("%w: %f", errInvalidPercentile, p)
+		}
+	}
+	if lastBlock == rpc.PendingBlockNumber {
+		lastBlock = rpc.LatestBlockNumber
+	}
+	head, err := gpo.backend.HeaderByNumber(ctx, lastBlock)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if head == nil {
+		return nil, nil, nil, fmt.Errorf("%w: block %d", errRequestBeyondHead, lastBlock)
+	}
+	last := head.Number.Uint64()
+	if uint64(blocks) > last+1 {
+		blocks = int(last + 1)
+	}
+	var (
+		oldest  = last + 1 - uint64(blocks)
+		reward  = make([][]*big.Int, blocks)
+		ratios  = make([]float64, blocks)
+		errs    = make([]error, blocks)
+		next    = make(chan int)
+		workers = runtime.NumCPU()
+		wg      sync.WaitGroup
+	)
+	if workers > blocks {
+		workers = blocks
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				reward[i], ratios[i], errs[i] = gpo.blockFees(ctx, oldest+uint64(i), rewardPercentiles)
+			}
+		}()
+	}
+	for i := 0; i < blocks; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if len(rewardPercentiles) == 0 {
+		reward = nil
+	}
+	return new(big.Int).SetUint64(oldest), reward, ratios, nil
+}
+
+// blockFees computes the gas used ratio of a block and the gas prices paid at
+// the given percentiles of its gas used.
+func (gpo *Oracle) blockFees(ctx context.Context, number uint64, percentiles []float64) ([]*big.Int, float64, error) {
+	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(number))
+	if block == nil {
+		if err == nil {
+			err = fmt.Errorf("block %d not found", number)
+		}
+		return nil, 0, err
+	}
+	var ratio float64
+	if block.GasLimit() > 0 {
+		ratio = float64(block.GasUsed()) / float64(block.GasLimit())
+	}
+	if len(percentiles) == 0 {
+		return nil, ratio, nil
+	}
+	reward := make([]*big.Int, len(percentiles))
+	if len(block.Transactions()) == 0 {
+		for i := range reward {
+			reward[i] = new(big.Int)
+		}
+		return reward, ratio, nil
+	}
+	receipts, err := gpo.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(receipts) != len(block.Transactions()) {
+		return nil, 0, fmt.Errorf("block %d has %d receipts for %d transactions", number, len(receipts), len(block.Transactions()))
+	}
+	sorted := make([]txGasAndPrice, len(receipts))
+	for i, tx := range block.Transactions() {
+		sorted[i] = txGasAndPrice{gasUsed: receipts[i].GasUsed, price: tx.GasPrice()}
+	}
+	sort.Stable(sortGasAndPrice(sorted))
+
+	var (
+		txIndex    int
+		sumGasUsed = sorted[0].gasUsed
+	)
+	for i, p := range percentiles {
+		threshold := uint64(float64(block.GasUsed()) * p / 100)
+		for sumGasUsed < threshold && txIndex < len(sorted)-1 {
+			txIndex++
+			sumGasUsed += sorted[txIndex].gasUsed
+		}
+		reward[i] = sorted[txIndex].price
+	}
+	return reward, ratio, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+func TestFeeHistory(t *testing.T) {
+	backend := newTestBackend(t)
+	oracle := NewOracle(backend, Config{Blocks: 3, Percentile: 60, Default: big.NewInt(params.GWei)})
+
+	// Every block holds a transaction priced at the block number in gwei.
+	oldest, reward, ratios, err := oracle.FeeHistory(context.Background(), 4, rpc.LatestBlockNumber, []float64{0, 50, 100})
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	if oldest.Uint64() != 29 {
+		t.Errorf("oldest block mismatch: have %d, want %d", oldest, 29)
+	}
+	if len(reward) != 4 || len(ratios) != 4 {
+		t.Fatalf("history length mismatch: have %d rewards and %d ratios, want 4", len(reward), len(ratios))
+	}
+	for i := range reward {
+		number := uint64(29 + i)
+		header := backend.chain.GetHeaderByNumber(number)
+		if want := float64(header.GasUsed) / float64(header.GasLimit); ratios[i] != want {
+			t.Errorf("block %d: gas used ratio mismatch: have %f, want %f", number, ratios[i], want)
+		}
+		want := big.NewInt(int64(number) * params.GWei)
+		for j, price := range reward[i] {
+			if price.Cmp(want) != 0 {
+				t.Errorf("block %d: reward %d mismatch: have %v, want %v", number, j, price, want)
+			}
+		}
+	}
+	// Ranges past the genesis are clipped, and rewards left out if not requested.
+	oldest, reward, ratios, err = oracle.FeeHistory(context.Background(), 10, 5, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve fee history: %v", err)
+	}
+	if oldest.Uint64() != 0 || len(ratios) != 6 || reward != nil {
+		t.Errorf("clipped history mismatch: oldest %d, %d ratios, rewards %v", oldest, len(ratios), reward)
+	}
+	// Invalid percentiles are rejected.
+	if _, _, _, err := oracle.FeeHistory(context.Background(), 1, rpc.LatestBlockNumber, []float64{50, 10}); !errors.Is(err, errInvalidPercentile) {
+		t.Errorf("decreasing percentiles error mismatch: have %v, want %v", err, errInvalidPercentile)
+	}
+	if _, _, _, err := oracle.FeeHistory(context.Background(), 1, 100, nil); !errors.Is(err, errRequestBeyondHead) {
+		t.Errorf("future block error mismatch: have %v, want %v", err, errRequestBeyondHead)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"math/big"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/core/types"
@@ -44,6 +45,7 @@ type Config struct {
 type OracleBackend interface {
 	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
 	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error)
+	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
 	ChainConfig() *params.ChainConfig
 }
 
@@ -161,6 +163,11 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	if price.Cmp(gpo.maxPrice) > 0 {
 		price = new(big.Int).Set(gpo.maxPrice)
 	}
+	// Never suggest less than the network accepts
+	next := new(big.Int).Add(head.Number, big.NewInt(1))
+	if floor := gpo.backend.ChainConfig().MinGasPriceAt(next, uint64(time.Now().Unix())); floor != nil && price.Cmp(floor) < 0 {
+		price = new(big.Int).Set(floor)
+	}
 	gpo.cacheLock.Lock()
 	gpo.lastHead = headHash
 	gpo.lastPrice = price
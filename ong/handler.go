@@ -64,6 +64,10 @@ type txPool interface {
 	// AddRemotes should add the given transactions to the pool.
 	AddRemotes([]*types.Transaction) []error
 
+	// AddRemotesFrom should add the given transactions received from a peer to
+	// the pool.
+	AddRemotesFrom(string, []*types.Transaction) []error
+
 	// Pending should return pending transactions.
 	// The slice should be modifiable by the caller.
 	Pending() (map[common.Address]types.Transactions, error)
@@ -101,13 +105,14 @@ type handler struct {
 	database ongdb.Database
 	txpool   txPool
 	chain    *core.BlockChain
-	maxPeers int
+	maxPeers int32 // Maximum number of non-trusted peers (accessed atomically)
 
 	downloader   *downloader.Downloader
 	stateBloom   *trie.SyncBloom
 	blockFetcher *fetcher.BlockFetcher
 	txFetcher    *fetcher.TxFetcher
 	peers        *peerSet
+	propagation  *propagationTracker
 
 	eventMux      *event.TypeMux
 	txsCh         chan core.NewTxsEvent
@@ -132,16 +137,17 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		config.EventMux = new(event.TypeMux) // Nicety initialization for tests
 	}
 	h := &handler{
-		networkID:  config.Network,
-		forkFilter: forkid.NewFilter(config.Chain),
-		eventMux:   config.EventMux,
-		database:   config.Database,
-		txpool:     config.TxPool,
-		chain:      config.Chain,
-		peers:      newPeerSet(),
-		whitelist:  config.Whitelist,
-		txsyncCh:   make(chan *txsync),
-		quitSync:   make(chan struct{}),
+		networkID:   config.Network,
+		forkFilter:  forkid.NewFilter(config.Chain),
+		eventMux:    config.EventMux,
+		database:    config.Database,
+		txpool:      config.TxPool,
+		chain:       config.Chain,
+		peers:       newPeerSet(),
+		propagation: newPropagationTracker(),
+		whitelist:   config.Whitelist,
+		txsyncCh:    make(chan *txsync),
+		quitSync:    make(chan struct{}),
 	}
 	if config.Sync == downloader.FullSync {
 		// The database seems empty as the current block is the genesis. Yet the fast
@@ -212,6 +218,9 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		n, err := h.chain.InsertChain(blocks)
 		if err == nil {
 			atomic.StoreUint32(&h.acceptTxs, 1) // Mark initial sync done on any fetcher import
+			for _, block := range blocks {
+				h.propagation.imported(block)
+			}
 		}
 		return n, err
 	}
@@ -224,7 +233,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return p.RequestTxs(hashes)
 	}
-	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx)
+	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotesFrom, fetchTx)
 	h.chainSync = newChainSyncer(h)
 	return h, nil
 }
@@ -257,7 +266,7 @@ func (h *handler) runOngPeer(peer *ong.Peer, handler ong.Handler) error {
 	forkID := forkid.NewID(h.chain.Config(), h.chain.Genesis().Hash(), h.chain.CurrentHeader().Number.Uint64())
 	if err := peer.Handshake(h.networkID, td, hash, genesis.Hash(), forkID, h.forkFilter); err != nil {
 		peer.Log().Debug("Orange handshake failed", "err", err)
-		return err
+		return &p2p.HandshakeError{Protocol: ong.ProtocolName, Err: err}
 	}
 	reject := false // reserved peer slots
 	if atomic.LoadUint32(&h.snapSync) == 1 {
@@ -272,8 +281,8 @@ func (h *handler) runOngPeer(peer *ong.Peer, handler ong.Handler) error {
 	}
 	// Ignore maxPeers if this is a trusted peer
 	if !peer.Peer.Info().Network.Trusted {
-		if reject || h.peers.len() >= h.maxPeers {
-			return p2p.DiscTooManyPeers
+		if reject || h.peers.len() >= h.peerLimit() {
+			return &p2p.HandshakeError{Protocol: ong.ProtocolName, Err: p2p.DiscTooManyPeers}
 		}
 	}
 	peer.Log().Debug("Orange peer connected", "name", peer.Name())
@@ -385,7 +394,7 @@ func (h *handler) removePeer(id string) {
 }
 
 func (h *handler) Start(maxPeers int) {
-	h.maxPeers = maxPeers
+	h.setPeerLimit(maxPeers)
 
 	// broadcast transactions
 	h.wg.Add(1)
@@ -404,6 +413,16 @@ func (h *handler) Start(maxPeers int) {
 	go h.txsyncLoop64() // TODO(karalabe): Legacy initial tx echange, drop with ong/64.
 }
 
+// peerLimit returns the maximum number of non-trusted peers.
+func (h *handler) peerLimit() int {
+	return int(atomic.LoadInt32(&h.maxPeers))
+}
+
+// setPeerLimit changes the maximum number of non-trusted peers.
+func (h *handler) setPeerLimit(maxPeers int) {
+	atomic.StoreInt32(&h.maxPeers, int32(maxPeers))
+}
+
 func (h *handler) Stop() {
 	h.txsSub.Unsubscribe()        // quits txBroadcastLoop
 	h.minedBlockSub.Unsubscribe() // quits blockBroadcastLoop
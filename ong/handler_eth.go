@@ -65,11 +65,24 @@ func (h *ongHandler) Handle(peer *ong.Peer, packet ong.Packet) error {
 	// Consume any broadcasts and announces, forwarding the rest to the downloader
 	switch packet := packet.(type) {
 	case *ong.BlockHeadersPacket:
-		return h.handleHeaders(peer, *packet)
+		return h.handleHeaders(peer, *packet, false)
 
 	case *ong.BlockBodiesPacket:
 		txset, uncleset := packet.Unpack()
-		return h.handleBodies(peer, txset, uncleset)
+		return h.handleBodies(peer, txset, uncleset, false)
+
+	case *ong.FetcherResponsePacket:
+		switch packet := packet.Packet.(type) {
+		case *ong.BlockHeadersPacket:
+			return h.handleHeaders(peer, *packet, true)
+
+		case *ong.BlockBodiesPacket:
+			txset, uncleset := packet.Unpack()
+			return h.handleBodies(peer, txset, uncleset, true)
+
+		default:
+			return fmt.Errorf("unexpected ong fetcher packet type: %T", packet)
+		}
 
 	case *ong.NodeDataPacket:
 		if err := h.downloader.DeliverNodeData(peer.ID(), *packet); err != nil {
@@ -105,14 +118,15 @@ func (h *ongHandler) Handle(peer *ong.Peer, packet ong.Packet) error {
 }
 
 // handleHeaders is invoked from a peer's message handler when it transmits a batch
-// of headers for the local node to process.
-func (h *ongHandler) handleHeaders(peer *ong.Peer, headers []*types.Header) error {
+// of headers for the local node to process. The fetcher flag is set if the
+// headers were matched by request id to a block fetcher request.
+func (h *ongHandler) handleHeaders(peer *ong.Peer, headers []*types.Header, fetcher bool) error {
 	p := h.peers.peer(peer.ID())
 	if p == nil {
 		return errors.New("unregistered during callback")
 	}
 	// If no headers were received, but we're expencting a checkpoint header, consider it that
-	if len(headers) == 0 && p.syncDrop != nil {
+	if len(headers) == 0 && p.syncDrop != nil && !fetcher {
 		// Stop the timer either way, decide later to drop or not
 		p.syncDrop.Stop()
 		p.syncDrop = nil
@@ -148,8 +162,17 @@ func (h *ongHandler) handleHeaders(peer *ong.Peer, headers []*types.Header) erro
 			}
 			peer.Log().Debug("Whitelist block verified", "number", headers[0].Number.Uint64(), "hash", want)
 		}
-		// Irrelevant of the fork checks, send the header to the fetcher just in case
-		headers = h.blockFetcher.FilterHeaders(peer.ID(), headers, time.Now())
+		// Irrelevant of the fork checks, send the header to the fetcher just in case,
+		// unless the response was already matched to its requester by id (ong/66)
+		if fetcher || peer.Version() < ong.ONG34 {
+			headers = h.blockFetcher.FilterHeaders(peer.ID(), headers, time.Now())
+		}
+	}
+	if fetcher {
+		if len(headers) > 0 {
+			peer.Log().Debug("Dropping unrequested fetcher headers", "count", len(headers))
+		}
+		return nil
 	}
 	if len(headers) > 0 || !filter {
 		err := h.downloader.DeliverHeaders(peer.ID(), headers)
@@ -161,13 +184,18 @@ func (h *ongHandler) handleHeaders(peer *ong.Peer, headers []*types.Header) erro
 }
 
 // handleBodies is invoked from a peer's message handler when it transmits a batch
-// of block bodies for the local node to process.
-func (h *ongHandler) handleBodies(peer *ong.Peer, txs [][]*types.Transaction, uncles [][]*types.Header) error {
-	// Filter out any explicitly requested bodies, deliver the rest to the downloader
-	filter := len(txs) > 0 || len(uncles) > 0
+// of block bodies for the local node to process. The fetcher flag is set if the
+// bodies were matched by request id to a block fetcher request.
+func (h *ongHandler) handleBodies(peer *ong.Peer, txs [][]*types.Transaction, uncles [][]*types.Header, fetcher bool) error {
+	// Filter out any explicitly requested bodies, deliver the rest to the downloader.
+	// Over ong/66 the responses are matched to their requester by id instead.
+	filter := (len(txs) > 0 || len(uncles) > 0) && (fetcher || peer.Version() < ong.ONG34)
 	if filter {
 		txs, uncles = h.blockFetcher.FilterBodies(peer.ID(), txs, uncles, time.Now())
 	}
+	if fetcher {
+		return nil
+	}
 	if len(txs) > 0 || len(uncles) > 0 || !filter {
 		err := h.downloader.DeliverBodies(peer.ID(), txs, uncles)
 		if err != nil {
@@ -186,13 +214,14 @@ func (h *ongHandler) handleBlockAnnounces(peer *ong.Peer, hashes []common.Hash,
 		unknownNumbers = make([]uint64, 0, len(numbers))
 	)
 	for i := 0; i < len(hashes); i++ {
+		h.propagation.seen(hashes[i], numbers[i], peer.ID(), propagationAnnounce)
 		if !h.chain.HasBlock(hashes[i], numbers[i]) {
 			unknownHashes = append(unknownHashes, hashes[i])
 			unknownNumbers = append(unknownNumbers, numbers[i])
 		}
 	}
 	for i := 0; i < len(unknownHashes); i++ {
-		h.blockFetcher.Notify(peer.ID(), unknownHashes[i], unknownNumbers[i], time.Now(), peer.RequestOneHeader, peer.RequestBodies)
+		h.blockFetcher.Notify(peer.ID(), unknownHashes[i], unknownNumbers[i], time.Now(), peer.RequestOneHeader, peer.RequestAnnouncedBodies)
 	}
 	return nil
 }
@@ -201,6 +230,7 @@ func (h *ongHandler) handleBlockAnnounces(peer *ong.Peer, hashes []common.Hash,
 // block broadcast for the local node to process.
 func (h *ongHandler) handleBlockBroadcast(peer *ong.Peer, block *types.Block, td *big.Int) error {
 	// Schedule the block for import
+	h.propagation.seen(block.Hash(), block.NumberU64(), peer.ID(), propagationBroadcast)
 	h.blockFetcher.Enqueue(peer.ID(), block)
 
 	// Assuming the block is importable by the peer, but possibly not yet done so,
@@ -90,6 +90,12 @@ func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
 	return make([]error, len(txs))
 }
 
+// AddRemotesFrom appends a batch of transactions received from a peer to the
+// pool, same as AddRemotes.
+func (p *testTxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	return p.AddRemotes(txs)
+}
+
 // Pending returns all the transactions known to the pool
 func (p *testTxPool) Pending() (map[common.Address]types.Transactions, error) {
 	p.lock.RLock()
@@ -18,6 +18,7 @@
 package ongconfig
 
 import (
+	"fmt"
 	"math/big"
 	"os"
 	"os/user"
@@ -28,8 +29,12 @@ import (
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/consensus"
 	"github.com/ong2020/go-orange/consensus/clique"
+	"github.com/ong2020/go-orange/consensus/misc"
 	"github.com/ong2020/go-orange/consensus/ongash"
 	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/exporter"
+	"github.com/ong2020/go-orange/health"
 	"github.com/ong2020/go-orange/log"
 	"github.com/ong2020/go-orange/miner"
 	"github.com/ong2020/go-orange/node"
@@ -37,6 +42,8 @@ import (
 	"github.com/ong2020/go-orange/ong/gasprice"
 	"github.com/ong2020/go-orange/ongdb"
 	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/relay"
+	"github.com/ong2020/go-orange/syscontract"
 )
 
 // FullNodeGPO contains default gasprice oracle settings for full node.
@@ -68,6 +75,7 @@ var Defaults = Config{
 	NetworkId:               1,
 	TxLookupLimit:           2350000,
 	LightPeers:              100,
+	LightMaxProofBloat:      1,
 	UltraLightFraction:      75,
 	DatabaseCache:           512,
 	TrieCleanCache:          154,
@@ -76,16 +84,23 @@ var Defaults = Config{
 	TrieDirtyCache:          256,
 	TrieTimeout:             60 * time.Minute,
 	SnapshotCache:           102,
+	SnapshotRejournal:       10 * time.Minute,
 	Miner: miner.Config{
 		GasFloor: 8000000,
 		GasCeil:  8000000,
 		GasPrice: big.NewInt(params.GWei),
 		Recommit: 3 * time.Second,
 	},
-	TxPool:      core.DefaultTxPoolConfig,
-	RPCGasCap:   25000000,
-	GPO:         FullNodeGPO,
-	RPCTxFeeCap: 1, // 1 onger
+	TxPool:            core.DefaultTxPoolConfig,
+	RPCGasCap:         25000000,
+	RPCEVMTimeout:     5 * time.Second,
+	RPCSafeDepth:      6,
+	RPCFinalizedDepth: 64,
+	GPO:               FullNodeGPO,
+	Health:            health.DefaultConfig,
+	Relay:             relay.DefaultConfig,
+	Exporter:          exporter.DefaultConfig,
+	RPCTxFeeCap:       1, // 1 onger
 }
 
 func init() {
@@ -121,6 +136,10 @@ type Config struct {
 	NetworkId uint64 // Network ID to use for selecting peers to connect to
 	SyncMode  downloader.SyncMode
 
+	// ForceNetwork allows opening a database created for a different genesis or
+	// network ID, instead of refusing to start.
+	ForceNetwork bool `toml:"-"`
+
 	// This can be set to list of enrtree:// URLs which will be queried for
 	// for nodes to connect to.
 	OngDiscoveryURLs  []string
@@ -135,13 +154,14 @@ type Config struct {
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
 	// Light client options
-	LightServ          int  `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
-	LightIngress       int  `toml:",omitempty"` // Incoming bandwidth limit for light servers
-	LightEgress        int  `toml:",omitempty"` // Outgoing bandwidth limit for light servers
-	LightPeers         int  `toml:",omitempty"` // Maximum number of LES client peers
-	LightNoPrune       bool `toml:",omitempty"` // Whonger to disable light chain pruning
-	LightNoSyncServe   bool `toml:",omitempty"` // Whonger to serve light clients before syncing
-	SyncFromCheckpoint bool `toml:",omitempty"` // Whonger to sync the header chain from the configured checkpoint
+	LightServ          int     `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
+	LightIngress       int     `toml:",omitempty"` // Incoming bandwidth limit for light servers
+	LightEgress        int     `toml:",omitempty"` // Outgoing bandwidth limit for light servers
+	LightPeers         int     `toml:",omitempty"` // Maximum number of LES client peers
+	LightNoPrune       bool    `toml:",omitempty"` // Whonger to disable light chain pruning
+	LightNoSyncServe   bool    `toml:",omitempty"` // Whonger to serve light clients before syncing
+	LightMaxProofBloat float64 `toml:",omitempty"` // Maximum size of the proofs accepted from light servers over the minimal ones
+	SyncFromCheckpoint bool    `toml:",omitempty"` // Whonger to sync the header chain from the configured checkpoint
 
 	// Ultra Light client options
 	UltraLightServers      []string `toml:",omitempty"` // List of trusted ultra light servers
@@ -154,12 +174,22 @@ type Config struct {
 	DatabaseCache      int
 	DatabaseFreezer    string
 
+	// DatabaseFreezerCompression overrides the compression algorithm ("none" or
+	// "snappy") of the new ancient tables by table name. Existing tables keep
+	// theirs until recompressed offline.
+	DatabaseFreezerCompression map[string]string `toml:",omitempty"`
+
 	TrieCleanCache          int
 	TrieCleanCacheJournal   string        `toml:",omitempty"` // Disk journal directory for trie cache to survive node restarts
 	TrieCleanCacheRejournal time.Duration `toml:",omitempty"` // Time interval to regenerate the journal for clean cache
 	TrieDirtyCache          int
 	TrieTimeout             time.Duration
 	SnapshotCache           int
+	SnapshotRejournal       time.Duration `toml:",omitempty"` // Time interval to checkpoint the snapshot diff layers to disk
+	SnapshotThrottle        int           `toml:",omitempty"` // Maximum write rate (MB/s) of the snapshot generation, 0 = unlimited
+	SnapServeRequests       int           `toml:",omitempty"` // Maximum number of snap requests served at once, 0 = unlimited
+	SnapServePeerRequests   int           `toml:",omitempty"` // Maximum number of snap requests of a peer served at once, 0 = 1
+	SnapServeBandwidth      int           `toml:",omitempty"` // Maximum rate (KB/s) of the snap responses, 0 = unlimited
 	Preimages               bool
 
 	// Mining options
@@ -174,6 +204,18 @@ type Config struct {
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
+	// Health and readiness endpoint options
+	Health health.Config
+
+	// Sponsored transaction relay options
+	Relay relay.Config
+
+	// System contract API options
+	SystemContracts syscontract.Config
+
+	// Chain exporter options
+	Exporter exporter.Config
+
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
@@ -189,10 +231,49 @@ type Config struct {
 	// RPCGasCap is the global gas cap for ong-call variants.
 	RPCGasCap uint64 `toml:",omitempty"`
 
+	// RPCEVMTimeout is the global timeout for ong-call and gas estimation
+	// executions, 0 means no timeout.
+	RPCEVMTimeout time.Duration
+
+	// RPCBlockRangeCap is the maximum number of blocks a ranged RPC request, such
+	// as log filtering or chain tracing, may span. 0 means no cap.
+	RPCBlockRangeCap uint64 `toml:",omitempty"`
+
+	// RPCPersistentFilters journals the filters installed over RPC to the chain
+	// database, restoring them along with their missed changes on restart.
+	RPCPersistentFilters bool `toml:",omitempty"`
+
+	// RPCSafeDepth and RPCFinalizedDepth are the number of confirmations below
+	// the chain head at which the "safe" and "finalized" block tags resolve.
+	RPCSafeDepth      uint64 `toml:",omitempty"`
+	RPCFinalizedDepth uint64 `toml:",omitempty"`
+
+	// RPCVerifySnapshot cross-checks the state read from the snapshot by the RPC
+	// methods against the state trie, logging mismatches. Debugging only, it
+	// makes the reads as slow as without snapshot.
+	RPCVerifySnapshot bool `toml:",omitempty"`
+
+	// RPCTraceTimeout is the maximum time the tracing of a single transaction may
+	// run for, capping the timeout requested by the caller. 0 means no cap.
+	RPCTraceTimeout time.Duration `toml:",omitempty"`
+
+	// RPCEVMBudget is the EVM execution time allowed per minute for the calls,
+	// gas estimations and traces of a single remote RPC origin, 0 means no limit.
+	RPCEVMBudget time.Duration `toml:",omitempty"`
+
 	// RPCTxFeeCap is the global transaction fee(price * gaslimit) cap for
 	// send-transction variants. The unit is onger.
 	RPCTxFeeCap float64 `toml:",omitempty"`
 
+	// RPCTxSpendCap is the cap on the total cost (value + fee) of a transaction
+	// signed by the node via the RPC APIs. The unit is onger, 0 means no cap.
+	RPCTxSpendCap float64 `toml:",omitempty"`
+
+	// RPCDailySpendCap is the cap on the total cost of the transactions signed and
+	// sent by the node for an account over a rolling 24 hour window. The unit is
+	// onger, 0 means no cap.
+	RPCDailySpendCap float64 `toml:",omitempty"`
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
@@ -203,6 +284,40 @@ type Config struct {
 	OverrideBerlin *big.Int `toml:",omitempty"`
 }
 
+// SetupGenesis writes or validates the genesis block of the configured network in
+// the database, the same way as core.SetupGenesisBlock, and checks that the
+// database was created for the configured network ID and that the reward policy
+// and block rules of the chain, if any, are available. Unless ForceNetwork is
+// set, a database created for a different genesis or network ID is refused to
+// prevent accidentally mixing the chains of different networks; with it, the
+// genesis of the database is used and the network ID is updated.
+func SetupGenesis(db ongdb.Database, config *Config) (*params.ChainConfig, common.Hash, error) {
+	chainConfig, genesisHash, err := core.SetupGenesisBlockWithOverride(db, config.Genesis, config.OverrideBerlin)
+	if mismatch, ok := err.(*core.GenesisMismatchError); ok && config.ForceNetwork {
+		log.Warn("Forcing database of different genesis", "stored", mismatch.Stored, "configured", mismatch.New)
+		chainConfig, genesisHash, err = core.SetupGenesisBlockWithOverride(db, nil, config.OverrideBerlin)
+	}
+	if _, ok := err.(*params.ConfigCompatError); err != nil && !ok {
+		return chainConfig, genesisHash, err
+	}
+	if err := misc.VerifyRewardPolicy(chainConfig); err != nil {
+		return chainConfig, genesisHash, err
+	}
+	if err := core.VerifyBlockRules(chainConfig); err != nil {
+		return chainConfig, genesisHash, err
+	}
+	switch stored := rawdb.ReadNetworkID(db); {
+	case stored == nil:
+		rawdb.WriteNetworkID(db, config.NetworkId)
+	case *stored != config.NetworkId && !config.ForceNetwork:
+		return chainConfig, genesisHash, fmt.Errorf("database created for network %d, not %d (use --force to override)", *stored, config.NetworkId)
+	case *stored != config.NetworkId:
+		log.Warn("Forcing database of different network", "stored", *stored, "configured", config.NetworkId)
+		rawdb.WriteNetworkID(db, config.NetworkId)
+	}
+	return chainConfig, genesisHash, err
+}
+
 // CreateConsensusEngine creates a consensus engine for the given chain configuration.
 func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, config *ongash.Config, notify []string, noverify bool, db ongdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
@@ -0,0 +1,94 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongconfig
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/params"
+)
+
+// Tests that the generated TOML marshalling covers every config field, so that
+// newly added settings round-trip through the config file.
+func TestConfigTOMLFields(t *testing.T) {
+	enc, err := Defaults.MarshalTOML()
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	have := make(map[string]bool)
+	encType := reflect.TypeOf(enc).Elem()
+	for i := 0; i < encType.NumField(); i++ {
+		have[encType.Field(i).Name] = true
+	}
+	cfgType := reflect.TypeOf(Config{})
+	for i := 0; i < cfgType.NumField(); i++ {
+		field := cfgType.Field(i)
+		if !have[field.Name] {
+			t.Errorf("config field %s missing from the TOML encoding", field.Name)
+		}
+		delete(have, field.Name)
+	}
+	for name := range have {
+		t.Errorf("TOML encoding has unknown field %s", name)
+	}
+}
+
+// Tests that databases created for a different genesis or network are refused
+// unless forced.
+func TestSetupGenesisNetworkSwitch(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+
+	config := Defaults
+	config.Genesis = core.DefaultRopstenGenesisBlock()
+	config.NetworkId = 3
+	if _, _, err := SetupGenesis(db, &config); err != nil {
+		t.Fatalf("failed to setup genesis: %v", err)
+	}
+	if id := rawdb.ReadNetworkID(db); id == nil || *id != 3 {
+		t.Fatalf("network ID not recorded: have %v, want 3", id)
+	}
+	// Switching the network ID must be refused unless forced
+	config.NetworkId = 5
+	if _, _, err := SetupGenesis(db, &config); err == nil {
+		t.Fatalf("network switch accepted")
+	}
+	config.ForceNetwork = true
+	if _, _, err := SetupGenesis(db, &config); err != nil {
+		t.Fatalf("forced network switch refused: %v", err)
+	}
+	if id := rawdb.ReadNetworkID(db); id == nil || *id != 5 {
+		t.Fatalf("network ID not updated: have %v, want 5", id)
+	}
+	// Switching the genesis must be refused unless forced
+	config.ForceNetwork = false
+	config.Genesis = &core.Genesis{Config: params.AllOngashProtocolChanges, Difficulty: big.NewInt(1)}
+	if _, _, err := SetupGenesis(db, &config); err == nil {
+		t.Fatalf("genesis switch accepted")
+	}
+	config.ForceNetwork = true
+	_, hash, err := SetupGenesis(db, &config)
+	if err != nil {
+		t.Fatalf("forced genesis switch refused: %v", err)
+	}
+	if stored := rawdb.ReadCanonicalHash(db, 0); hash != stored {
+		t.Fatalf("genesis hash mismatch: have %x, want %x", hash, stored)
+	}
+}
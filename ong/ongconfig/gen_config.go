@@ -9,63 +9,90 @@ import (
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/consensus/ongash"
 	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/exporter"
+	"github.com/ong2020/go-orange/health"
 	"github.com/ong2020/go-orange/miner"
 	"github.com/ong2020/go-orange/ong/downloader"
 	"github.com/ong2020/go-orange/ong/gasprice"
 	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/relay"
+	"github.com/ong2020/go-orange/syscontract"
 )
 
 // MarshalTOML marshals as TOML.
 func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               uint64
-		SyncMode                downloader.SyncMode
-		OngDiscoveryURLs        []string
-		SnapDiscoveryURLs       []string
-		NoPruning               bool
-		NoPrefetch              bool
-		TxLookupLimit           uint64                 `toml:",omitempty"`
-		Whitelist               map[uint64]common.Hash `toml:"-"`
-		LightServ               int                    `toml:",omitempty"`
-		LightIngress            int                    `toml:",omitempty"`
-		LightEgress             int                    `toml:",omitempty"`
-		LightPeers              int                    `toml:",omitempty"`
-		LightNoPrune            bool                   `toml:",omitempty"`
-		LightNoSyncServe        bool                   `toml:",omitempty"`
-		SyncFromCheckpoint      bool                   `toml:",omitempty"`
-		UltraLightServers       []string               `toml:",omitempty"`
-		UltraLightFraction      int                    `toml:",omitempty"`
-		UltraLightOnlyAnnounce  bool                   `toml:",omitempty"`
-		SkipBcVersionCheck      bool                   `toml:"-"`
-		DatabaseHandles         int                    `toml:"-"`
-		DatabaseCache           int
-		DatabaseFreezer         string
-		TrieCleanCache          int
-		TrieCleanCacheJournal   string        `toml:",omitempty"`
-		TrieCleanCacheRejournal time.Duration `toml:",omitempty"`
-		TrieDirtyCache          int
-		TrieTimeout             time.Duration
-		SnapshotCache           int
-		Preimages               bool
-		Miner                   miner.Config
-		Ongash                  ongash.Config
-		TxPool                  core.TxPoolConfig
-		GPO                     gasprice.Config
-		EnablePreimageRecording bool
-		DocRoot                 string `toml:"-"`
-		EWASMInterpreter        string
-		EVMInterpreter          string
-		RPCGasCap               uint64                         `toml:",omitempty"`
-		RPCTxFeeCap             float64                        `toml:",omitempty"`
-		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
-		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
-		OverrideBerlin          *big.Int                       `toml:",omitempty"`
+		Genesis                    *core.Genesis `toml:",omitempty"`
+		NetworkId                  uint64
+		SyncMode                   downloader.SyncMode
+		ForceNetwork               bool `toml:"-"`
+		OngDiscoveryURLs           []string
+		SnapDiscoveryURLs          []string
+		NoPruning                  bool
+		NoPrefetch                 bool
+		TxLookupLimit              uint64                 `toml:",omitempty"`
+		Whitelist                  map[uint64]common.Hash `toml:"-"`
+		LightServ                  int                    `toml:",omitempty"`
+		LightIngress               int                    `toml:",omitempty"`
+		LightEgress                int                    `toml:",omitempty"`
+		LightPeers                 int                    `toml:",omitempty"`
+		LightNoPrune               bool                   `toml:",omitempty"`
+		LightNoSyncServe           bool                   `toml:",omitempty"`
+		LightMaxProofBloat         float64                `toml:",omitempty"`
+		SyncFromCheckpoint         bool                   `toml:",omitempty"`
+		UltraLightServers          []string               `toml:",omitempty"`
+		UltraLightFraction         int                    `toml:",omitempty"`
+		UltraLightOnlyAnnounce     bool                   `toml:",omitempty"`
+		SkipBcVersionCheck         bool                   `toml:"-"`
+		DatabaseHandles            int                    `toml:"-"`
+		DatabaseCache              int
+		DatabaseFreezer            string
+		DatabaseFreezerCompression map[string]string `toml:",omitempty"`
+		TrieCleanCache             int
+		TrieCleanCacheJournal      string        `toml:",omitempty"`
+		TrieCleanCacheRejournal    time.Duration `toml:",omitempty"`
+		TrieDirtyCache             int
+		TrieTimeout                time.Duration
+		SnapshotCache              int
+		SnapshotRejournal          time.Duration `toml:",omitempty"`
+		SnapshotThrottle           int           `toml:",omitempty"`
+		SnapServeRequests          int           `toml:",omitempty"`
+		SnapServePeerRequests      int           `toml:",omitempty"`
+		SnapServeBandwidth         int           `toml:",omitempty"`
+		Preimages                  bool
+		Miner                      miner.Config
+		Ongash                     ongash.Config
+		TxPool                     core.TxPoolConfig
+		GPO                        gasprice.Config
+		Health                     health.Config
+		Relay                      relay.Config
+		SystemContracts            syscontract.Config
+		Exporter                   exporter.Config
+		EnablePreimageRecording    bool
+		DocRoot                    string `toml:"-"`
+		EWASMInterpreter           string
+		EVMInterpreter             string
+		RPCGasCap                  uint64 `toml:",omitempty"`
+		RPCEVMTimeout              time.Duration
+		RPCBlockRangeCap           uint64                         `toml:",omitempty"`
+		RPCPersistentFilters       bool                           `toml:",omitempty"`
+		RPCSafeDepth               uint64                         `toml:",omitempty"`
+		RPCFinalizedDepth          uint64                         `toml:",omitempty"`
+		RPCVerifySnapshot          bool                           `toml:",omitempty"`
+		RPCTraceTimeout            time.Duration                  `toml:",omitempty"`
+		RPCEVMBudget               time.Duration                  `toml:",omitempty"`
+		RPCTxFeeCap                float64                        `toml:",omitempty"`
+		RPCTxSpendCap              float64                        `toml:",omitempty"`
+		RPCDailySpendCap           float64                        `toml:",omitempty"`
+		Checkpoint                 *params.TrustedCheckpoint      `toml:",omitempty"`
+		CheckpointOracle           *params.CheckpointOracleConfig `toml:",omitempty"`
+		OverrideBerlin             *big.Int                       `toml:",omitempty"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
+	enc.ForceNetwork = c.ForceNetwork
 	enc.OngDiscoveryURLs = c.OngDiscoveryURLs
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
 	enc.NoPruning = c.NoPruning
@@ -78,6 +105,7 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.LightPeers = c.LightPeers
 	enc.LightNoPrune = c.LightNoPrune
 	enc.LightNoSyncServe = c.LightNoSyncServe
+	enc.LightMaxProofBloat = c.LightMaxProofBloat
 	enc.SyncFromCheckpoint = c.SyncFromCheckpoint
 	enc.UltraLightServers = c.UltraLightServers
 	enc.UltraLightFraction = c.UltraLightFraction
@@ -86,23 +114,43 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
 	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.DatabaseFreezerCompression = c.DatabaseFreezerCompression
 	enc.TrieCleanCache = c.TrieCleanCache
 	enc.TrieCleanCacheJournal = c.TrieCleanCacheJournal
 	enc.TrieCleanCacheRejournal = c.TrieCleanCacheRejournal
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
 	enc.SnapshotCache = c.SnapshotCache
+	enc.SnapshotRejournal = c.SnapshotRejournal
+	enc.SnapshotThrottle = c.SnapshotThrottle
+	enc.SnapServeRequests = c.SnapServeRequests
+	enc.SnapServePeerRequests = c.SnapServePeerRequests
+	enc.SnapServeBandwidth = c.SnapServeBandwidth
 	enc.Preimages = c.Preimages
 	enc.Miner = c.Miner
 	enc.Ongash = c.Ongash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
+	enc.Health = c.Health
+	enc.Relay = c.Relay
+	enc.SystemContracts = c.SystemContracts
+	enc.Exporter = c.Exporter
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
 	enc.DocRoot = c.DocRoot
 	enc.EWASMInterpreter = c.EWASMInterpreter
 	enc.EVMInterpreter = c.EVMInterpreter
 	enc.RPCGasCap = c.RPCGasCap
+	enc.RPCEVMTimeout = c.RPCEVMTimeout
+	enc.RPCBlockRangeCap = c.RPCBlockRangeCap
+	enc.RPCPersistentFilters = c.RPCPersistentFilters
+	enc.RPCSafeDepth = c.RPCSafeDepth
+	enc.RPCFinalizedDepth = c.RPCFinalizedDepth
+	enc.RPCVerifySnapshot = c.RPCVerifySnapshot
+	enc.RPCTraceTimeout = c.RPCTraceTimeout
+	enc.RPCEVMBudget = c.RPCEVMBudget
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.RPCTxSpendCap = c.RPCTxSpendCap
+	enc.RPCDailySpendCap = c.RPCDailySpendCap
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
 	enc.OverrideBerlin = c.OverrideBerlin
@@ -112,49 +160,71 @@ func (c Config) MarshalTOML() (interface{}, error) {
 // UnmarshalTOML unmarshals from TOML.
 func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
-		Genesis                 *core.Genesis `toml:",omitempty"`
-		NetworkId               *uint64
-		SyncMode                *downloader.SyncMode
-		OngDiscoveryURLs        []string
-		SnapDiscoveryURLs       []string
-		NoPruning               *bool
-		NoPrefetch              *bool
-		TxLookupLimit           *uint64                `toml:",omitempty"`
-		Whitelist               map[uint64]common.Hash `toml:"-"`
-		LightServ               *int                   `toml:",omitempty"`
-		LightIngress            *int                   `toml:",omitempty"`
-		LightEgress             *int                   `toml:",omitempty"`
-		LightPeers              *int                   `toml:",omitempty"`
-		LightNoPrune            *bool                  `toml:",omitempty"`
-		LightNoSyncServe        *bool                  `toml:",omitempty"`
-		SyncFromCheckpoint      *bool                  `toml:",omitempty"`
-		UltraLightServers       []string               `toml:",omitempty"`
-		UltraLightFraction      *int                   `toml:",omitempty"`
-		UltraLightOnlyAnnounce  *bool                  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool                  `toml:"-"`
-		DatabaseHandles         *int                   `toml:"-"`
-		DatabaseCache           *int
-		DatabaseFreezer         *string
-		TrieCleanCache          *int
-		TrieCleanCacheJournal   *string        `toml:",omitempty"`
-		TrieCleanCacheRejournal *time.Duration `toml:",omitempty"`
-		TrieDirtyCache          *int
-		TrieTimeout             *time.Duration
-		SnapshotCache           *int
-		Preimages               *bool
-		Miner                   *miner.Config
-		Ongash                  *ongash.Config
-		TxPool                  *core.TxPoolConfig
-		GPO                     *gasprice.Config
-		EnablePreimageRecording *bool
-		DocRoot                 *string `toml:"-"`
-		EWASMInterpreter        *string
-		EVMInterpreter          *string
-		RPCGasCap               *uint64                        `toml:",omitempty"`
-		RPCTxFeeCap             *float64                       `toml:",omitempty"`
-		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
-		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
-		OverrideBerlin          *big.Int                       `toml:",omitempty"`
+		Genesis                    *core.Genesis `toml:",omitempty"`
+		NetworkId                  *uint64
+		SyncMode                   *downloader.SyncMode
+		ForceNetwork               *bool `toml:"-"`
+		OngDiscoveryURLs           []string
+		SnapDiscoveryURLs          []string
+		NoPruning                  *bool
+		NoPrefetch                 *bool
+		TxLookupLimit              *uint64                `toml:",omitempty"`
+		Whitelist                  map[uint64]common.Hash `toml:"-"`
+		LightServ                  *int                   `toml:",omitempty"`
+		LightIngress               *int                   `toml:",omitempty"`
+		LightEgress                *int                   `toml:",omitempty"`
+		LightPeers                 *int                   `toml:",omitempty"`
+		LightNoPrune               *bool                  `toml:",omitempty"`
+		LightNoSyncServe           *bool                  `toml:",omitempty"`
+		LightMaxProofBloat         *float64               `toml:",omitempty"`
+		SyncFromCheckpoint         *bool                  `toml:",omitempty"`
+		UltraLightServers          []string               `toml:",omitempty"`
+		UltraLightFraction         *int                   `toml:",omitempty"`
+		UltraLightOnlyAnnounce     *bool                  `toml:",omitempty"`
+		SkipBcVersionCheck         *bool                  `toml:"-"`
+		DatabaseHandles            *int                   `toml:"-"`
+		DatabaseCache              *int
+		DatabaseFreezer            *string
+		DatabaseFreezerCompression map[string]string `toml:",omitempty"`
+		TrieCleanCache             *int
+		TrieCleanCacheJournal      *string        `toml:",omitempty"`
+		TrieCleanCacheRejournal    *time.Duration `toml:",omitempty"`
+		TrieDirtyCache             *int
+		TrieTimeout                *time.Duration
+		SnapshotCache              *int
+		SnapshotRejournal          *time.Duration `toml:",omitempty"`
+		SnapshotThrottle           *int           `toml:",omitempty"`
+		SnapServeRequests          *int           `toml:",omitempty"`
+		SnapServePeerRequests      *int           `toml:",omitempty"`
+		SnapServeBandwidth         *int           `toml:",omitempty"`
+		Preimages                  *bool
+		Miner                      *miner.Config
+		Ongash                     *ongash.Config
+		TxPool                     *core.TxPoolConfig
+		GPO                        *gasprice.Config
+		Health                     *health.Config
+		Relay                      *relay.Config
+		SystemContracts            *syscontract.Config
+		Exporter                   *exporter.Config
+		EnablePreimageRecording    *bool
+		DocRoot                    *string `toml:"-"`
+		EWASMInterpreter           *string
+		EVMInterpreter             *string
+		RPCGasCap                  *uint64 `toml:",omitempty"`
+		RPCEVMTimeout              *time.Duration
+		RPCBlockRangeCap           *uint64                        `toml:",omitempty"`
+		RPCPersistentFilters       *bool                          `toml:",omitempty"`
+		RPCSafeDepth               *uint64                        `toml:",omitempty"`
+		RPCFinalizedDepth          *uint64                        `toml:",omitempty"`
+		RPCVerifySnapshot          *bool                          `toml:",omitempty"`
+		RPCTraceTimeout            *time.Duration                 `toml:",omitempty"`
+		RPCEVMBudget               *time.Duration                 `toml:",omitempty"`
+		RPCTxFeeCap                *float64                       `toml:",omitempty"`
+		RPCTxSpendCap              *float64                       `toml:",omitempty"`
+		RPCDailySpendCap           *float64                       `toml:",omitempty"`
+		Checkpoint                 *params.TrustedCheckpoint      `toml:",omitempty"`
+		CheckpointOracle           *params.CheckpointOracleConfig `toml:",omitempty"`
+		OverrideBerlin             *big.Int                       `toml:",omitempty"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -169,6 +239,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SyncMode != nil {
 		c.SyncMode = *dec.SyncMode
 	}
+	if dec.ForceNetwork != nil {
+		c.ForceNetwork = *dec.ForceNetwork
+	}
 	if dec.OngDiscoveryURLs != nil {
 		c.OngDiscoveryURLs = dec.OngDiscoveryURLs
 	}
@@ -205,6 +278,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.LightNoSyncServe != nil {
 		c.LightNoSyncServe = *dec.LightNoSyncServe
 	}
+	if dec.LightMaxProofBloat != nil {
+		c.LightMaxProofBloat = *dec.LightMaxProofBloat
+	}
 	if dec.SyncFromCheckpoint != nil {
 		c.SyncFromCheckpoint = *dec.SyncFromCheckpoint
 	}
@@ -229,6 +305,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseFreezer != nil {
 		c.DatabaseFreezer = *dec.DatabaseFreezer
 	}
+	if dec.DatabaseFreezerCompression != nil {
+		c.DatabaseFreezerCompression = dec.DatabaseFreezerCompression
+	}
 	if dec.TrieCleanCache != nil {
 		c.TrieCleanCache = *dec.TrieCleanCache
 	}
@@ -247,6 +326,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SnapshotCache != nil {
 		c.SnapshotCache = *dec.SnapshotCache
 	}
+	if dec.SnapshotRejournal != nil {
+		c.SnapshotRejournal = *dec.SnapshotRejournal
+	}
+	if dec.SnapshotThrottle != nil {
+		c.SnapshotThrottle = *dec.SnapshotThrottle
+	}
+	if dec.SnapServeRequests != nil {
+		c.SnapServeRequests = *dec.SnapServeRequests
+	}
+	if dec.SnapServePeerRequests != nil {
+		c.SnapServePeerRequests = *dec.SnapServePeerRequests
+	}
+	if dec.SnapServeBandwidth != nil {
+		c.SnapServeBandwidth = *dec.SnapServeBandwidth
+	}
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
@@ -262,6 +356,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GPO != nil {
 		c.GPO = *dec.GPO
 	}
+	if dec.Health != nil {
+		c.Health = *dec.Health
+	}
+	if dec.Relay != nil {
+		c.Relay = *dec.Relay
+	}
+	if dec.SystemContracts != nil {
+		c.SystemContracts = *dec.SystemContracts
+	}
+	if dec.Exporter != nil {
+		c.Exporter = *dec.Exporter
+	}
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
@@ -277,9 +383,39 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCGasCap != nil {
 		c.RPCGasCap = *dec.RPCGasCap
 	}
+	if dec.RPCEVMTimeout != nil {
+		c.RPCEVMTimeout = *dec.RPCEVMTimeout
+	}
+	if dec.RPCBlockRangeCap != nil {
+		c.RPCBlockRangeCap = *dec.RPCBlockRangeCap
+	}
+	if dec.RPCPersistentFilters != nil {
+		c.RPCPersistentFilters = *dec.RPCPersistentFilters
+	}
+	if dec.RPCSafeDepth != nil {
+		c.RPCSafeDepth = *dec.RPCSafeDepth
+	}
+	if dec.RPCFinalizedDepth != nil {
+		c.RPCFinalizedDepth = *dec.RPCFinalizedDepth
+	}
+	if dec.RPCVerifySnapshot != nil {
+		c.RPCVerifySnapshot = *dec.RPCVerifySnapshot
+	}
+	if dec.RPCTraceTimeout != nil {
+		c.RPCTraceTimeout = *dec.RPCTraceTimeout
+	}
+	if dec.RPCEVMBudget != nil {
+		c.RPCEVMBudget = *dec.RPCEVMBudget
+	}
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.RPCTxSpendCap != nil {
+		c.RPCTxSpendCap = *dec.RPCTxSpendCap
+	}
+	if dec.RPCDailySpendCap != nil {
+		c.RPCDailySpendCap = *dec.RPCDailySpendCap
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
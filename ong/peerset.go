@@ -22,6 +22,7 @@ import (
 	"sync"
 
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/forkid"
 	"github.com/ong2020/go-orange/ong/protocols/ong"
 	"github.com/ong2020/go-orange/ong/protocols/snap"
 	"github.com/ong2020/go-orange/p2p"
@@ -229,6 +230,18 @@ func (ps *peerSet) snapLen() int {
 	return ps.snapPeers
 }
 
+// forkIDs retrieves the fork identifiers advertised by the connected peers.
+func (ps *peerSet) forkIDs() []forkid.ID {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	ids := make([]forkid.ID, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		ids = append(ids, p.ForkID())
+	}
+	return ids
+}
+
 // peerWithHighestTD retrieves the known peer with the currently highest total
 // difficulty.
 func (ps *peerSet) peerWithHighestTD() *ong.Peer {
@@ -0,0 +1,205 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/metrics"
+)
+
+const (
+	// propagationHistory is the number of recent blocks whose propagation is tracked.
+	propagationHistory = 256
+
+	// propagationSources is the maximum number of arrivals recorded per block.
+	propagationSources = 16
+)
+
+// The ways a block reaches the node.
+const (
+	propagationAnnounce  = "announce"
+	propagationBroadcast = "broadcast"
+)
+
+var (
+	propagationArrivalTimer   = metrics.NewRegisteredTimer("ong/propagation/arrival", nil)
+	propagationAnnounceTimer  = metrics.NewRegisteredTimer("ong/propagation/import/announce", nil)
+	propagationBroadcastTimer = metrics.NewRegisteredTimer("ong/propagation/import/broadcast", nil)
+)
+
+// PropagationSource is an arrival of a block from a peer.
+type PropagationSource struct {
+	Peer  string `json:"peer"`
+	Kind  string `json:"kind"`  // How the block was received, announce or broadcast
+	Delay uint64 `json:"delay"` // Milliseconds since the block was first seen
+}
+
+// BlockPropagation is the propagation timing of a block.
+type BlockPropagation struct {
+	Hash        common.Hash         `json:"hash"`
+	Number      uint64              `json:"number"`
+	FirstSeen   time.Time           `json:"firstSeen"`
+	Arrival     *int64              `json:"arrival,omitempty"`     // Milliseconds from the block timestamp to the first arrival
+	ImportDelay *uint64             `json:"importDelay,omitempty"` // Milliseconds from the first arrival to the import
+	Announces   int                 `json:"announces"`
+	Broadcasts  int                 `json:"broadcasts"`
+	Sources     []PropagationSource `json:"sources"` // First arrivals, earliest first
+}
+
+// PeerPropagation summarizes how fast a peer relays blocks.
+type PeerPropagation struct {
+	Peer      string `json:"peer"`
+	Blocks    int    `json:"blocks"`    // Tracked blocks the peer relayed
+	First     int    `json:"first"`     // Tracked blocks the peer relayed first
+	MeanDelay uint64 `json:"meanDelay"` // Mean milliseconds behind the first arrival
+}
+
+// PropagationStats is the propagation timing of the recent blocks.
+type PropagationStats struct {
+	Blocks []*BlockPropagation `json:"blocks"` // Most recent first
+	Peers  []*PeerPropagation  `json:"peers"`  // Most often first, first
+}
+
+// propagationTracker records when and from which peers the recent blocks were
+// received, and how long they took to be imported.
+type propagationTracker struct {
+	blocks map[common.Hash]*BlockPropagation
+	order  []common.Hash // Tracked blocks, oldest first
+	now    func() time.Time
+	lock   sync.Mutex
+}
+
+func newPropagationTracker() *propagationTracker {
+	return &propagationTracker{
+		blocks: make(map[common.Hash]*BlockPropagation),
+		now:    time.Now,
+	}
+}
+
+// seen records the arrival of a block from a peer.
+func (t *propagationTracker) seen(hash common.Hash, number uint64, peer string, kind string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := t.now()
+	block := t.blocks[hash]
+	if block == nil {
+		block = &BlockPropagation{Hash: hash, Number: number, FirstSeen: now}
+		t.blocks[hash] = block
+		t.order = append(t.order, hash)
+		if len(t.order) > propagationHistory {
+			delete(t.blocks, t.order[0])
+			t.order = t.order[1:]
+		}
+	}
+	switch kind {
+	case propagationAnnounce:
+		block.Announces++
+	case propagationBroadcast:
+		block.Broadcasts++
+	}
+	if len(block.Sources) < propagationSources {
+		block.Sources = append(block.Sources, PropagationSource{
+			Peer:  peer,
+			Kind:  kind,
+			Delay: uint64(now.Sub(block.FirstSeen) / time.Millisecond),
+		})
+	}
+}
+
+// imported records the import of a block, updating the propagation metrics if
+// the block was received from the network.
+func (t *propagationTracker) imported(block *types.Block) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	prop := t.blocks[block.Hash()]
+	if prop == nil || prop.ImportDelay != nil {
+		return
+	}
+	var (
+		delay   = t.now().Sub(prop.FirstSeen)
+		delayMs = uint64(delay / time.Millisecond)
+		arrival = prop.FirstSeen.Sub(time.Unix(int64(block.Time()), 0))
+		arrMs   = int64(arrival / time.Millisecond)
+	)
+	prop.ImportDelay, prop.Arrival = &delayMs, &arrMs
+
+	if arrival >= 0 {
+		propagationArrivalTimer.Update(arrival)
+	}
+	if prop.Sources[0].Kind == propagationAnnounce {
+		propagationAnnounceTimer.Update(delay)
+	} else {
+		propagationBroadcastTimer.Update(delay)
+	}
+}
+
+// stats returns the propagation timing of the given number of recent blocks, and
+// of all the tracked ones summarized by peer.
+func (t *propagationTracker) stats(limit int) *PropagationStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var (
+		stats  = &PropagationStats{Blocks: []*BlockPropagation{}, Peers: []*PeerPropagation{}}
+		peers  = make(map[string]*PeerPropagation)
+		delays = make(map[string]uint64)
+	)
+	for i := len(t.order) - 1; i >= 0; i-- {
+		block := t.blocks[t.order[i]]
+		if len(stats.Blocks) < limit {
+			cpy := *block
+			cpy.Sources = append([]PropagationSource(nil), block.Sources...)
+			stats.Blocks = append(stats.Blocks, &cpy)
+		}
+		relayed := make(map[string]bool)
+		for j, source := range block.Sources {
+			if relayed[source.Peer] {
+				continue
+			}
+			relayed[source.Peer] = true
+
+			peer := peers[source.Peer]
+			if peer == nil {
+				peer = &PeerPropagation{Peer: source.Peer}
+				peers[source.Peer] = peer
+				stats.Peers = append(stats.Peers, peer)
+			}
+			peer.Blocks++
+			if j == 0 {
+				peer.First++
+			}
+			delays[source.Peer] += source.Delay
+		}
+	}
+	for _, peer := range stats.Peers {
+		peer.MeanDelay = delays[peer.Peer] / uint64(peer.Blocks)
+	}
+	sort.SliceStable(stats.Peers, func(i, j int) bool {
+		if stats.Peers[i].First != stats.Peers[j].First {
+			return stats.Peers[i].First > stats.Peers[j].First
+		}
+		return stats.Peers[i].MeanDelay < stats.Peers[j].MeanDelay
+	})
+	return stats
+}
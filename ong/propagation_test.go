@@ -0,0 +1,93 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/types"
+)
+
+// Tests that the arrivals and imports of the blocks are timed, and the peers
+// ranked by how fast they relay them.
+func TestPropagationTracker(t *testing.T) {
+	var (
+		tracker = newPropagationTracker()
+		now     = time.Unix(1000, 0)
+	)
+	tracker.now = func() time.Time { return now }
+	advance := func(ms int) { now = now.Add(time.Duration(ms) * time.Millisecond) }
+
+	block1 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Time: 999})
+	block2 := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2), Time: 1000})
+
+	// Block 1 is announced by a, then broadcast by b
+	tracker.seen(block1.Hash(), 1, "a", propagationAnnounce)
+	advance(100)
+	tracker.seen(block1.Hash(), 1, "b", propagationBroadcast)
+	advance(50)
+	tracker.imported(block1)
+
+	// Block 2 is broadcast by b, then announced by a twice
+	tracker.seen(block2.Hash(), 2, "b", propagationBroadcast)
+	advance(20)
+	tracker.seen(block2.Hash(), 2, "a", propagationAnnounce)
+	tracker.seen(block2.Hash(), 2, "a", propagationAnnounce)
+	advance(10)
+	tracker.imported(block2)
+
+	stats := tracker.stats(1)
+	if len(stats.Blocks) != 1 || stats.Blocks[0].Hash != block2.Hash() {
+		t.Fatalf("recent blocks mismatch: %+v", stats.Blocks)
+	}
+	prop := stats.Blocks[0]
+	if prop.Broadcasts != 1 || prop.Announces != 2 || len(prop.Sources) != 3 {
+		t.Errorf("arrivals mismatch: %+v", prop)
+	}
+	if prop.ImportDelay == nil || *prop.ImportDelay != 30 {
+		t.Errorf("import delay mismatch: have %v, want 30", prop.ImportDelay)
+	}
+	if prop.Arrival == nil || *prop.Arrival != 150 {
+		t.Errorf("arrival mismatch: have %v, want 150", prop.Arrival)
+	}
+	// Both peers were first once, a is faster on average
+	want := []PeerPropagation{
+		{Peer: "a", Blocks: 2, First: 1, MeanDelay: 10},
+		{Peer: "b", Blocks: 2, First: 1, MeanDelay: 50},
+	}
+	if len(stats.Peers) != len(want) {
+		t.Fatalf("peer count mismatch: have %d, want %d", len(stats.Peers), len(want))
+	}
+	for i := range want {
+		if *stats.Peers[i] != want[i] {
+			t.Errorf("peer %d mismatch: have %+v, want %+v", i, *stats.Peers[i], want[i])
+		}
+	}
+	// Old blocks are evicted
+	for i := 0; i < propagationHistory; i++ {
+		tracker.seen(common.Hash{byte(i), 1}, uint64(i+3), "c", propagationAnnounce)
+	}
+	if _, ok := tracker.blocks[block1.Hash()]; ok {
+		t.Errorf("old block still tracked")
+	}
+	if len(tracker.blocks) != propagationHistory {
+		t.Errorf("tracked block count mismatch: have %d, want %d", len(tracker.blocks), propagationHistory)
+	}
+}
@@ -327,7 +327,7 @@ func handleBlockHeaders66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(res); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
-	return backend.Handle(peer, &res.BlockHeadersPacket)
+	return deliverResponse(backend, peer, res.RequestId, &res.BlockHeadersPacket)
 }
 
 func handleBlockBodies(backend Backend, msg Decoder, peer *Peer) error {
@@ -345,7 +345,7 @@ func handleBlockBodies66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(res); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
-	return backend.Handle(peer, &res.BlockBodiesPacket)
+	return deliverResponse(backend, peer, res.RequestId, &res.BlockBodiesPacket)
 }
 
 func handleNodeData(backend Backend, msg Decoder, peer *Peer) error {
@@ -363,7 +363,7 @@ func handleNodeData66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(res); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
-	return backend.Handle(peer, &res.NodeDataPacket)
+	return deliverResponse(backend, peer, res.RequestId, &res.NodeDataPacket)
 }
 
 func handleReceipts(backend Backend, msg Decoder, peer *Peer) error {
@@ -381,7 +381,7 @@ func handleReceipts66(backend Backend, msg Decoder, peer *Peer) error {
 	if err := msg.Decode(res); err != nil {
 		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
 	}
-	return backend.Handle(peer, &res.ReceiptsPacket)
+	return deliverResponse(backend, peer, res.RequestId, &res.ReceiptsPacket)
 }
 
 func handleNewPooledTransactionHashes(backend Backend, msg Decoder, peer *Peer) error {
@@ -506,5 +506,20 @@ func handlePooledTransactions66(backend Backend, msg Decoder, peer *Peer) error
 		}
 		peer.markTransaction(tx.Hash())
 	}
-	return backend.Handle(peer, &txs.PooledTransactionsPacket)
+	return deliverResponse(backend, peer, txs.RequestId, &txs.PooledTransactionsPacket)
+}
+
+// deliverResponse matches an ong/66 response to its request by id and delivers
+// it to the subsystem that issued the request. Responses not matching any live
+// request (unsolicited, duplicate or expired) are dropped.
+func deliverResponse(backend Backend, peer *Peer, id uint64, packet Packet) error {
+	origin, ok := peer.requests.resolve(id, uint64(packet.Kind()))
+	if !ok {
+		peer.Log().Debug("Dropping unsolicited response", "type", packet.Name(), "reqid", id)
+		return nil
+	}
+	if origin == originFetcher {
+		return backend.Handle(peer, &FetcherResponsePacket{packet})
+	}
+	return backend.Handle(peer, packet)
 }
@@ -18,11 +18,11 @@ package ong
 
 import (
 	"math/big"
-	"math/rand"
 	"sync"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/forkid"
 	"github.com/ong2020/go-orange/core/types"
 	"github.com/ong2020/go-orange/p2p"
 	"github.com/ong2020/go-orange/rlp"
@@ -72,8 +72,9 @@ type Peer struct {
 	rw        p2p.MsgReadWriter // Input/output streams for snap
 	version   uint              // Protocol version negotiated
 
-	head common.Hash // Latest advertised head block hash
-	td   *big.Int    // Latest advertised head block total difficulty
+	head   common.Hash // Latest advertised head block hash
+	td     *big.Int    // Latest advertised head block total difficulty
+	forkID forkid.ID   // Fork identifier advertised in the handshake
 
 	knownBlocks     mapset.Set             // Set of block hashes known to be known by this peer
 	queuedBlocks    chan *blockPropagation // Queue of blocks to broadcast to the peer
@@ -84,6 +85,8 @@ type Peer struct {
 	txBroadcast chan []common.Hash // Channel used to queue transaction propagation requests
 	txAnnounce  chan []common.Hash // Channel used to queue transaction announcement requests
 
+	requests *requestTracker // Outstanding ong/66 requests to match responses against
+
 	term chan struct{} // Termination channel to stop the broadcasters
 	lock sync.RWMutex  // Mutex protecting the internal fields
 }
@@ -103,6 +106,7 @@ func NewPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter, txpool TxPool) *Pe
 		txBroadcast:     make(chan []common.Hash),
 		txAnnounce:      make(chan []common.Hash),
 		txpool:          txpool,
+		requests:        newRequestTracker(),
 		term:            make(chan struct{}),
 	}
 	// Start up all the broadcasters
@@ -149,6 +153,11 @@ func (p *Peer) SetHead(hash common.Hash, td *big.Int) {
 	p.td.Set(td)
 }
 
+// ForkID retrieves the fork identifier advertised by the peer in the handshake.
+func (p *Peer) ForkID() forkid.ID {
+	return p.forkID
+}
+
 // KnownBlock returns whonger peer is known to already have a block.
 func (p *Peer) KnownBlock(hash common.Hash) bool {
 	return p.knownBlocks.Contains(hash)
@@ -414,7 +423,7 @@ func (p *Peer) RequestOneHeader(hash common.Hash) error {
 	}
 	if p.Version() >= ONG34 {
 		return p2p.Send(p.rw, GetBlockHeadersMsg, &GetBlockHeadersPacket66{
-			RequestId:             rand.Uint64(),
+			RequestId:             p.requests.track(BlockHeadersMsg, originFetcher),
 			GetBlockHeadersPacket: &query,
 		})
 	}
@@ -433,7 +442,7 @@ func (p *Peer) RequestHeadersByHash(origin common.Hash, amount int, skip int, re
 	}
 	if p.Version() >= ONG34 {
 		return p2p.Send(p.rw, GetBlockHeadersMsg, &GetBlockHeadersPacket66{
-			RequestId:             rand.Uint64(),
+			RequestId:             p.requests.track(BlockHeadersMsg, originDefault),
 			GetBlockHeadersPacket: &query,
 		})
 	}
@@ -452,7 +461,7 @@ func (p *Peer) RequestHeadersByNumber(origin uint64, amount int, skip int, rever
 	}
 	if p.Version() >= ONG34 {
 		return p2p.Send(p.rw, GetBlockHeadersMsg, &GetBlockHeadersPacket66{
-			RequestId:             rand.Uint64(),
+			RequestId:             p.requests.track(BlockHeadersMsg, originDefault),
 			GetBlockHeadersPacket: &query,
 		})
 	}
@@ -474,10 +483,21 @@ func (p *Peer) ExpectRequestHeadersByNumber(origin uint64, amount int, skip int,
 // RequestBodies fetches a batch of blocks' bodies corresponding to the hashes
 // specified.
 func (p *Peer) RequestBodies(hashes []common.Hash) error {
+	return p.requestBodies(hashes, originDefault)
+}
+
+// RequestAnnouncedBodies is a wrapper around the body query functions to fetch
+// the bodies of announced blocks. It is used solely by the fetcher.
+func (p *Peer) RequestAnnouncedBodies(hashes []common.Hash) error {
+	return p.requestBodies(hashes, originFetcher)
+}
+
+// requestBodies fetches a batch of blocks' bodies on behalf of the given origin.
+func (p *Peer) requestBodies(hashes []common.Hash, origin requestOrigin) error {
 	p.Log().Debug("Fetching batch of block bodies", "count", len(hashes))
 	if p.Version() >= ONG34 {
 		return p2p.Send(p.rw, GetBlockBodiesMsg, &GetBlockBodiesPacket66{
-			RequestId:            rand.Uint64(),
+			RequestId:            p.requests.track(BlockBodiesMsg, origin),
 			GetBlockBodiesPacket: hashes,
 		})
 	}
@@ -490,7 +510,7 @@ func (p *Peer) RequestNodeData(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of state data", "count", len(hashes))
 	if p.Version() >= ONG34 {
 		return p2p.Send(p.rw, GetNodeDataMsg, &GetNodeDataPacket66{
-			RequestId:         rand.Uint64(),
+			RequestId:         p.requests.track(NodeDataMsg, originDefault),
 			GetNodeDataPacket: hashes,
 		})
 	}
@@ -502,7 +522,7 @@ func (p *Peer) RequestReceipts(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of receipts", "count", len(hashes))
 	if p.Version() >= ONG34 {
 		return p2p.Send(p.rw, GetReceiptsMsg, &GetReceiptsPacket66{
-			RequestId:         rand.Uint64(),
+			RequestId:         p.requests.track(ReceiptsMsg, originDefault),
 			GetReceiptsPacket: hashes,
 		})
 	}
@@ -514,7 +534,7 @@ func (p *Peer) RequestTxs(hashes []common.Hash) error {
 	p.Log().Debug("Fetching batch of transactions", "count", len(hashes))
 	if p.Version() >= ONG34 {
 		return p2p.Send(p.rw, GetPooledTransactionsMsg, &GetPooledTransactionsPacket66{
-			RequestId:                   rand.Uint64(),
+			RequestId:                   p.requests.track(PooledTransactionsMsg, originDefault),
 			GetPooledTransactionsPacket: hashes,
 		})
 	}
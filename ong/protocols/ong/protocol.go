@@ -322,6 +322,12 @@ type PooledTransactionsRLPPacket66 struct {
 	PooledTransactionsRLPPacket
 }
 
+// FetcherResponsePacket wraps a header or body response received over ong/66,
+// which was matched by request id to a block fetcher request.
+type FetcherResponsePacket struct {
+	Packet
+}
+
 func (*StatusPacket) Name() string { return "Status" }
 func (*StatusPacket) Kind() byte   { return StatusMsg }
 
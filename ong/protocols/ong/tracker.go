@@ -0,0 +1,114 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// requestExpiration is the time after which an unanswered ong/66 request is
+	// forgotten and any late response to it is considered unsolicited.
+	requestExpiration = time.Minute
+
+	// maxPendingRequests is the maximum number of ong/66 requests tracked for a
+	// single peer before the oldest ones are forgotten.
+	maxPendingRequests = 1024
+)
+
+// requestOrigin is the local subsystem that issued a network request, which the
+// response needs to be delivered to.
+type requestOrigin int
+
+const (
+	originDefault requestOrigin = iota // Request answered through the default delivery path
+	originFetcher                      // Request issued by the block fetcher
+)
+
+// pendingRequest is an ong/66 request awaiting its response.
+type pendingRequest struct {
+	code   uint64        // Message code of the expected response
+	origin requestOrigin // Subsystem waiting for the response
+	sent   time.Time     // Time the request was sent at
+}
+
+// requestTracker keeps track of the ong/66 requests sent to a remote peer, so
+// that responses can be matched to them by request id instead of guessing the
+// requester from the response content.
+type requestTracker struct {
+	pending map[uint64]*pendingRequest
+	lock    sync.Mutex
+}
+
+// newRequestTracker creates an empty request tracker.
+func newRequestTracker() *requestTracker {
+	return &requestTracker{pending: make(map[uint64]*pendingRequest)}
+}
+
+// track generates a new unique request id and records it as awaiting a response
+// with the given message code.
+func (t *requestTracker) track(code uint64, origin requestOrigin) uint64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Drop any expired requests, and the oldest ones if there are too many
+	now := time.Now()
+	if len(t.pending) >= maxPendingRequests {
+		var (
+			oldest uint64
+			first  = true
+		)
+		for id, req := range t.pending {
+			if now.Sub(req.sent) > requestExpiration {
+				delete(t.pending, id)
+				continue
+			}
+			if first || req.sent.Before(t.pending[oldest].sent) {
+				oldest, first = id, false
+			}
+		}
+		if len(t.pending) >= maxPendingRequests {
+			delete(t.pending, oldest)
+		}
+	}
+	id := rand.Uint64()
+	for _, ok := t.pending[id]; ok; _, ok = t.pending[id] {
+		id = rand.Uint64()
+	}
+	t.pending[id] = &pendingRequest{code: code, origin: origin, sent: now}
+	return id
+}
+
+// resolve matches a response to its request, returning the origin of the request
+// and whether a live one was found. Resolved requests are forgotten.
+func (t *requestTracker) resolve(id uint64, code uint64) (requestOrigin, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	req, ok := t.pending[id]
+	if !ok || req.code != code {
+		return 0, false
+	}
+	delete(t.pending, id)
+
+	if time.Since(req.sent) > requestExpiration {
+		return 0, false
+	}
+	return req.origin, true
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that responses are matched to their requests by id and message code,
+// and that unsolicited, duplicate and expired responses are rejected.
+func TestRequestTracker(t *testing.T) {
+	tracker := newRequestTracker()
+
+	headers := tracker.track(BlockHeadersMsg, originFetcher)
+	bodies := tracker.track(BlockBodiesMsg, originDefault)
+
+	if _, ok := tracker.resolve(headers, BlockBodiesMsg); ok {
+		t.Fatalf("response with mismatching code accepted")
+	}
+	if origin, ok := tracker.resolve(bodies, BlockBodiesMsg); !ok || origin != originDefault {
+		t.Fatalf("bodies response mismatch: have %v/%v, want %v/true", origin, ok, originDefault)
+	}
+	if _, ok := tracker.resolve(bodies, BlockBodiesMsg); ok {
+		t.Fatalf("duplicate response accepted")
+	}
+	if _, ok := tracker.resolve(headers+1, BlockHeadersMsg); ok {
+		t.Fatalf("unsolicited response accepted")
+	}
+	// Expired requests should not be matched any more
+	expired := tracker.track(ReceiptsMsg, originDefault)
+	tracker.pending[expired].sent = time.Now().Add(-2 * requestExpiration)
+
+	if _, ok := tracker.resolve(expired, ReceiptsMsg); ok {
+		t.Fatalf("expired response accepted")
+	}
+	// Tracking too many requests should evict the oldest ones
+	for i := 0; i < 2*maxPendingRequests; i++ {
+		tracker.track(NodeDataMsg, originDefault)
+	}
+	if pending := len(tracker.pending); pending > maxPendingRequests {
+		t.Fatalf("pending request count mismatch: have %d, want <= %d", pending, maxPendingRequests)
+	}
+}
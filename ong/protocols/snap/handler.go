@@ -75,8 +75,10 @@ type Backend interface {
 	Handle(peer *Peer, packet Packet) error
 }
 
-// MakeProtocols constructs the P2P protocol definitions for `snap`.
-func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
+// MakeProtocols constructs the P2P protocol definitions for `snap`. The data
+// retrievals of the remote peers are served within the limits of the scheduler,
+// or one at a time per peer without any if it's nil.
+func MakeProtocols(backend Backend, dnsdisc enode.Iterator, sched *Scheduler) []p2p.Protocol {
 	protocols := make([]p2p.Protocol, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		version := version // Closure
@@ -87,7 +89,7 @@ func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
 			Length:  protocolLengths[version],
 			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
 				return backend.RunPeer(newPeer(version, p, rw), func(peer *Peer) error {
-					return handle(backend, peer)
+					return handle(backend, peer, sched)
 				})
 			},
 			NodeInfo: func() interface{} {
@@ -105,9 +107,9 @@ func MakeProtocols(backend Backend, dnsdisc enode.Iterator) []p2p.Protocol {
 
 // handle is the callback invoked to manage the life cycle of a `snap` peer.
 // When this function terminates, the peer is disconnected.
-func handle(backend Backend, peer *Peer) error {
+func handle(backend Backend, peer *Peer, sched *Scheduler) error {
 	for {
-		if err := handleMessage(backend, peer); err != nil {
+		if err := handleMessage(backend, peer, sched); err != nil {
 			peer.Log().Debug("Message handling failed in `snap`", "err", err)
 			return err
 		}
@@ -117,8 +119,8 @@ func handle(backend Backend, peer *Peer) error {
 // handleMessage is invoked whenever an inbound message is received from a
 // remote peer on the `spap` protocol. The remote connection is torn down upon
 // returning any error.
-func handleMessage(backend Backend, peer *Peer) error {
-	// Read the next message from the remote peer, and ensure it's fully consumed
+func handleMessage(backend Backend, peer *Peer, sched *Scheduler) error {
+	// Read the next message from the remote peer
 	msg, err := peer.rw.ReadMsg()
 	if err != nil {
 		return err
@@ -126,6 +128,33 @@ func handleMessage(backend Backend, peer *Peer) error {
 	if msg.Size > maxMessageSize {
 		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
 	}
+	// Serve the data retrievals in the background once the scheduler admits them,
+	// the peer being dropped if they fail.
+	if sched != nil && isRetrievalMsg(msg.Code) {
+		release := sched.acquire(peer.id)
+		go func() {
+			defer release()
+			if err := serveMessage(backend, peer, msg, sched); err != nil {
+				peer.Log().Debug("Message handling failed in `snap`", "err", err)
+				peer.Disconnect(p2p.DiscSubprotocolError)
+			}
+		}()
+		return nil
+	}
+	return serveMessage(backend, peer, msg, sched)
+}
+
+// isRetrievalMsg reports whether a message is a data retrieval to serve.
+func isRetrievalMsg(code uint64) bool {
+	switch code {
+	case GetAccountRangeMsg, GetStorageRangesMsg, GetByteCodesMsg, GetTrieNodesMsg:
+		return true
+	}
+	return false
+}
+
+// serveMessage handles an inbound message, ensuring it's fully consumed.
+func serveMessage(backend Backend, peer *Peer, msg p2p.Msg, sched *Scheduler) error {
 	defer msg.Discard()
 
 	// Handle the message depending on its contents
@@ -139,6 +168,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
+		if sched != nil {
+			req.Bytes = sched.reserve(req.Bytes)
+		}
 		// Retrieve the requested state and bail out if non existent
 		tr, err := trie.New(req.Root, backend.Chain().StateCache().TrieDB())
 		if err != nil {
@@ -219,6 +251,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
+		if sched != nil {
+			req.Bytes = sched.reserve(req.Bytes)
+		}
 		// TODO(karalabe): Do we want to enforce > 0 accounts and 1 account if origin is set?
 		// TODO(karalabe):   - Logging locally is not ideal as remote faulst annoy the local user
 		// TODO(karalabe):   - Dropping the remote peer is less flexible wrt client bugs (slow is better than non-functional)
@@ -347,6 +382,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
+		if sched != nil {
+			req.Bytes = sched.reserve(req.Bytes)
+		}
 		if len(req.Hashes) > maxCodeLookups {
 			req.Hashes = req.Hashes[:maxCodeLookups]
 		}
@@ -391,6 +429,9 @@ func handleMessage(backend Backend, peer *Peer) error {
 		if req.Bytes > softResponseLimit {
 			req.Bytes = softResponseLimit
 		}
+		if sched != nil {
+			req.Bytes = sched.reserve(req.Bytes)
+		}
 		// Make sure we have the state associated with the request
 		triedb := backend.Chain().StateCache().TrieDB()
 
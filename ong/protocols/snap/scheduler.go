@@ -0,0 +1,148 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/metrics"
+	"golang.org/x/time/rate"
+)
+
+// minShapedResponse is the smallest response size a request is shaped down to
+// when the serving bandwidth is shared between many peers.
+const minShapedResponse = 64 * 1024
+
+var (
+	serveActiveGauge   = metrics.NewRegisteredGauge("snap/serve/active", nil)   // Requests being served
+	servePeersGauge    = metrics.NewRegisteredGauge("snap/serve/peers", nil)    // Peers with requests being served
+	serveWaitTimer     = metrics.NewRegisteredTimer("snap/serve/wait", nil)     // Time requests wait for a serving slot
+	serveThrottleTimer = metrics.NewRegisteredTimer("snap/serve/throttle", nil) // Time requests wait for bandwidth
+	serveBytesMeter    = metrics.NewRegisteredMeter("snap/serve/bytes", nil)    // Response bytes granted to requests
+)
+
+// ServeConfig bounds the resources spent serving the data retrievals of the
+// remote peers, so syncing peers can't starve the local block processing.
+type ServeConfig struct {
+	MaxRequests     int // Maximum number of requests served at once across all peers (0 = unlimited)
+	MaxPeerRequests int // Maximum number of requests of a single peer served at once (0 = 1)
+	Bandwidth       int // Maximum total response bytes served per second (0 = unlimited)
+}
+
+// Scheduler schedules the data retrievals of the remote peers within the limits
+// of a serving configuration. Requests waiting for a serving slot are admitted
+// in arrival order, and when the bandwidth is limited the responses are shaped
+// down to an equal share of it for every peer being served.
+type Scheduler struct {
+	config  ServeConfig
+	slots   chan struct{} // Serving slots across all peers, nil if unlimited
+	limiter *rate.Limiter // Response bandwidth across all peers, nil if unlimited
+
+	lock  sync.Mutex
+	cond  *sync.Cond
+	peers map[string]int // Requests being served per peer
+}
+
+// NewScheduler creates a scheduler for the data retrievals of the remote peers.
+func NewScheduler(config ServeConfig) *Scheduler {
+	if config.MaxPeerRequests <= 0 {
+		config.MaxPeerRequests = 1
+	}
+	s := &Scheduler{
+		config: config,
+		peers:  make(map[string]int),
+	}
+	s.cond = sync.NewCond(&s.lock)
+	if config.MaxRequests > 0 {
+		s.slots = make(chan struct{}, config.MaxRequests)
+	}
+	if config.Bandwidth > 0 {
+		burst := config.Bandwidth
+		if burst < softResponseLimit {
+			burst = softResponseLimit
+		}
+		s.limiter = rate.NewLimiter(rate.Limit(config.Bandwidth), burst)
+	}
+	return s
+}
+
+// acquire waits until a request of the peer may be served, returning the function
+// to call once it's done.
+func (s *Scheduler) acquire(peer string) func() {
+	s.lock.Lock()
+	for s.peers[peer] >= s.config.MaxPeerRequests {
+		s.cond.Wait()
+	}
+	s.peers[peer]++
+	servePeersGauge.Update(int64(len(s.peers)))
+	s.lock.Unlock()
+
+	if s.slots != nil {
+		start := time.Now()
+		s.slots <- struct{}{}
+		serveWaitTimer.UpdateSince(start)
+	}
+	serveActiveGauge.Inc(1)
+
+	return func() {
+		serveActiveGauge.Dec(1)
+		if s.slots != nil {
+			<-s.slots
+		}
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if s.peers[peer]--; s.peers[peer] == 0 {
+			delete(s.peers, peer)
+		}
+		servePeersGauge.Update(int64(len(s.peers)))
+		s.cond.Broadcast()
+	}
+}
+
+// reserve shapes the response size requested by a peer being served to its share
+// of the bandwidth, and waits until the bandwidth is available.
+func (s *Scheduler) reserve(bytes uint64) uint64 {
+	if s.limiter == nil {
+		serveBytesMeter.Mark(int64(bytes))
+		return bytes
+	}
+	s.lock.Lock()
+	share := uint64(s.config.Bandwidth)
+	if len(s.peers) > 1 {
+		share /= uint64(len(s.peers))
+	}
+	s.lock.Unlock()
+
+	if share < minShapedResponse {
+		share = minShapedResponse
+	}
+	if bytes > share {
+		bytes = share
+	}
+	if burst := uint64(s.limiter.Burst()); bytes > burst {
+		bytes = burst
+	}
+	start := time.Now()
+	s.limiter.WaitN(context.Background(), int(bytes))
+	serveThrottleTimer.UpdateSince(start)
+	serveBytesMeter.Mark(int64(bytes))
+
+	return bytes
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests that the scheduler bounds the requests served at once, per peer and
+// across all peers.
+func TestSchedulerConcurrency(t *testing.T) {
+	sched := NewScheduler(ServeConfig{MaxRequests: 2, MaxPeerRequests: 1})
+
+	releaseA := sched.acquire("a")
+	admitted := make(chan string, 2)
+	go func() {
+		release := sched.acquire("a")
+		admitted <- "a"
+		release()
+	}()
+	select {
+	case <-admitted:
+		t.Fatal("peer exceeded its request limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	releaseB := sched.acquire("b")
+	go func() {
+		release := sched.acquire("c")
+		admitted <- "c"
+		release()
+	}()
+	select {
+	case <-admitted:
+		t.Fatal("peers exceeded the total request limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	releaseA()
+	releaseB()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-admitted:
+		case <-time.After(time.Second):
+			t.Fatal("request not admitted after release")
+		}
+	}
+}
+
+// Tests that the responses are shaped to the share of the bandwidth of every
+// peer being served.
+func TestSchedulerShaping(t *testing.T) {
+	sched := NewScheduler(ServeConfig{Bandwidth: 4 * 1024 * 1024})
+
+	release := sched.acquire("a")
+	if have := sched.reserve(softResponseLimit); have != softResponseLimit {
+		t.Errorf("single peer response size mismatch: have %d, want %d", have, softResponseLimit)
+	}
+	release()
+
+	sched = NewScheduler(ServeConfig{Bandwidth: 1024 * 1024})
+	for _, peer := range []string{"a", "b", "c", "d"} {
+		defer sched.acquire(peer)()
+	}
+	if have, want := sched.reserve(softResponseLimit), uint64(256*1024); have != want {
+		t.Errorf("shared response size mismatch: have %d, want %d", have, want)
+	}
+}
@@ -80,6 +80,11 @@ const (
 	// storageConcurrency is the number of chunks to split the a large contract
 	// storage trie into to allow concurrent retrievals.
 	storageConcurrency = 16
+
+	// statusPersistInterval is the time between two checkpoints of the sync
+	// progress into the database while syncing, so that a crash doesn't lose
+	// more than this much of the progress.
+	statusPersistInterval = time.Minute
 )
 
 var (
@@ -593,6 +598,7 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 	peerDropSub := s.peerDrop.Subscribe(peerDrop)
 	defer peerDropSub.Unsubscribe()
 
+	persisted := time.Now()
 	for {
 		// Remove all completed tasks and terminate sync if everything's done
 		s.cleanStorageTasks()
@@ -645,6 +651,15 @@ func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
 		}
 		// Report stats if somonging meaningful happened
 		s.report(false)
+
+		// Checkpoint the progress occasionally, independent of the deferred
+		// persisting on exit. In-flight account ranges are not forwarded since
+		// that would abandon their pending storage, they are just redownloaded
+		// after a crash.
+		if time.Since(persisted) > statusPersistInterval {
+			s.saveSyncStatus()
+			persisted = time.Now()
+		}
 	}
 }
 
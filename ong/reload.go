@@ -0,0 +1,94 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/ong2020/go-orange/node"
+	"github.com/ong2020/go-orange/ong/gasprice"
+	"github.com/ong2020/go-orange/ong/ongconfig"
+)
+
+// ApplyConfig applies the runtime-adjustable settings of a reloaded config: the
+// miner gas price, extra data and recommit interval, the gas price oracle and
+// the peer limit (derived from the reloaded total peer count). The changes of
+// all other settings are rejected.
+func (s *Orange) ApplyConfig(cfg *ongconfig.Config, maxPeers int, res *node.ConfigReloadResult) {
+	old := s.config
+
+	gasPriceChanged := cfg.Miner.GasPrice != nil && (old.Miner.GasPrice == nil || cfg.Miner.GasPrice.Cmp(old.Miner.GasPrice) != 0)
+	if cfg.Miner.GasPrice == nil {
+		cfg.Miner.GasPrice = old.Miner.GasPrice
+	}
+	if gasPriceChanged {
+		s.lock.Lock()
+		s.gasPrice = cfg.Miner.GasPrice
+		s.lock.Unlock()
+
+		s.txPool.SetGasPrice(cfg.Miner.GasPrice)
+		res.Apply("Ong.Miner.GasPrice")
+	}
+	if !bytes.Equal(cfg.Miner.ExtraData, old.Miner.ExtraData) {
+		if err := s.miner.SetExtra(makeExtraData(cfg.Miner.ExtraData)); err != nil {
+			res.Reject("Ong.Miner.ExtraData", err.Error())
+			cfg.Miner.ExtraData = old.Miner.ExtraData
+		} else {
+			res.Apply("Ong.Miner.ExtraData")
+		}
+	}
+	if cfg.Miner.Recommit != old.Miner.Recommit {
+		s.miner.SetRecommitInterval(cfg.Miner.Recommit)
+		res.Apply("Ong.Miner.Recommit")
+	}
+	if !reflect.DeepEqual(cfg.GPO, old.GPO) || gasPriceChanged {
+		gpoParams := cfg.GPO
+		if gpoParams.Default == nil {
+			gpoParams.Default = cfg.Miner.GasPrice
+		}
+		gpo := gasprice.NewOracle(s.APIBackend, gpoParams)
+
+		s.lock.Lock()
+		s.APIBackend.gpo = gpo
+		s.lock.Unlock()
+
+		if !reflect.DeepEqual(cfg.GPO, old.GPO) {
+			res.Apply("Ong.GPO")
+		}
+	}
+	if s.config.LightServ > 0 {
+		maxPeers -= s.config.LightPeers
+	}
+	if maxPeers > 0 && maxPeers != s.handler.peerLimit() {
+		s.handler.setPeerLimit(maxPeers)
+	}
+	res.RejectChanged("Ong.Miner", old.Miner, cfg.Miner, "GasPrice", "ExtraData", "Recommit")
+	res.RejectChanged("Ong", *old, *cfg, "Miner", "GPO")
+
+	// Keep track of the applied settings for subsequent reloads
+	s.lock.Lock()
+	old.Miner.GasPrice, old.Miner.ExtraData, old.Miner.Recommit = cfg.Miner.GasPrice, cfg.Miner.ExtraData, cfg.Miner.Recommit
+	old.GPO = cfg.GPO
+	s.lock.Unlock()
+}
+
+// ApplyConfig applies the runtime-adjustable settings of a reloaded config to
+// the full node behind the API backend.
+func (b *OngAPIBackend) ApplyConfig(cfg *ongconfig.Config, maxPeers int, res *node.ConfigReloadResult) {
+	b.ong.ApplyConfig(cfg, maxPeers, res)
+}
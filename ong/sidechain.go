@@ -0,0 +1,175 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/event"
+)
+
+const (
+	// sideChainHistory is the number of recent side blocks tracked.
+	sideChainHistory = 1024
+
+	// sideChainMaxDepth is the maximum number of blocks a side chain is walked
+	// back looking for its fork point.
+	sideChainMaxDepth = 256
+)
+
+// SideChain is a non-canonical chain segment.
+type SideChain struct {
+	Head            common.Hash    `json:"head"`
+	Number          hexutil.Uint64 `json:"number"`
+	TotalDifficulty *hexutil.Big   `json:"totalDifficulty"`
+	Length          int            `json:"length"`     // Number of non-canonical blocks
+	ForkNumber      hexutil.Uint64 `json:"forkNumber"` // Canonical block the segment forks from
+	ForkHash        common.Hash    `json:"forkHash"`   // Zero if beyond the walked depth
+	Blocks          []common.Hash  `json:"blocks"`     // Non-canonical blocks, head first
+}
+
+// sideChainTracker tracks the side blocks reported by the chain, so the side
+// chains can be reconstructed for fork analysis.
+type sideChainTracker struct {
+	chain  *core.BlockChain
+	blocks map[common.Hash]uint64 // Numbers of the tracked side blocks
+	order  []common.Hash          // Tracked side blocks, oldest first
+	lock   sync.Mutex
+
+	sub  event.Subscription
+	ch   chan core.ChainSideEvent
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newSideChainTracker(chain *core.BlockChain) *sideChainTracker {
+	return &sideChainTracker{
+		chain:  chain,
+		blocks: make(map[common.Hash]uint64),
+		ch:     make(chan core.ChainSideEvent, 16),
+		quit:   make(chan struct{}),
+	}
+}
+
+// start subscribes to the side blocks of the chain.
+func (t *sideChainTracker) start() {
+	t.sub = t.chain.SubscribeChainSideEvent(t.ch)
+	t.wg.Add(1)
+	go t.loop()
+}
+
+// stop terminates the tracker.
+func (t *sideChainTracker) stop() {
+	t.sub.Unsubscribe()
+	close(t.quit)
+	t.wg.Wait()
+}
+
+func (t *sideChainTracker) loop() {
+	defer t.wg.Done()
+
+	for {
+		select {
+		case ev := <-t.ch:
+			t.track(ev.Block)
+		case <-t.sub.Err():
+			return
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// track records a side block.
+func (t *sideChainTracker) track(block *types.Block) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.blocks[block.Hash()]; ok {
+		return
+	}
+	t.blocks[block.Hash()] = block.NumberU64()
+	t.order = append(t.order, block.Hash())
+	if len(t.order) > sideChainHistory {
+		delete(t.blocks, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// sideChains returns the side chains formed by the tracked side blocks and the
+// non-canonical blocks of the block cache, highest first.
+func (t *sideChainTracker) sideChains() []*SideChain {
+	// Gather the side blocks which are still non-canonical
+	candidates := make(map[common.Hash]*types.Block)
+	t.lock.Lock()
+	for hash, number := range t.blocks {
+		if block := t.chain.GetBlock(hash, number); block != nil {
+			candidates[hash] = block
+		}
+	}
+	t.lock.Unlock()
+
+	for _, block := range t.chain.CachedBlocks() {
+		candidates[block.Hash()] = block
+	}
+	parents := make(map[common.Hash]bool)
+	for hash, block := range candidates {
+		if t.chain.GetCanonicalHash(block.NumberU64()) == hash {
+			delete(candidates, hash)
+			continue
+		}
+		parents[block.ParentHash()] = true
+	}
+	// Walk every side chain back from its head to the canonical chain
+	chains := make([]*SideChain, 0)
+	for hash, head := range candidates {
+		if parents[hash] {
+			continue
+		}
+		side := &SideChain{
+			Head:            hash,
+			Number:          hexutil.Uint64(head.NumberU64()),
+			TotalDifficulty: (*hexutil.Big)(t.chain.GetTd(hash, head.NumberU64())),
+		}
+		for block := head; block != nil && len(side.Blocks) < sideChainMaxDepth; {
+			side.Blocks = append(side.Blocks, block.Hash())
+			if block.NumberU64() == 0 {
+				break
+			}
+			number := block.NumberU64() - 1
+			if t.chain.GetCanonicalHash(number) == block.ParentHash() {
+				side.ForkNumber, side.ForkHash = hexutil.Uint64(number), block.ParentHash()
+				break
+			}
+			block = t.chain.GetBlock(block.ParentHash(), number)
+		}
+		side.Length = len(side.Blocks)
+		chains = append(chains, side)
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		if chains[i].Number != chains[j].Number {
+			return chains[i].Number > chains[j].Number
+		}
+		return chains[i].Head.Hex() < chains[j].Head.Hex()
+	})
+	return chains
+}
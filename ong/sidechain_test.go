@@ -0,0 +1,70 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus/ongash"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/params"
+)
+
+// Tests that side chains are walked back to their fork point.
+func TestSideChains(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, ongash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	// Fork two blocks off block 2 of a 5 block chain
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ongash.NewFaker(), db, 5, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	side, _ := core.GenerateChain(params.TestChainConfig, blocks[1], ongash.NewFaker(), db, 2, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+	})
+	if _, err := chain.InsertChain(side); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	if chain.CurrentBlock().Hash() != blocks[4].Hash() {
+		t.Fatalf("side chain became canonical")
+	}
+	tracker := newSideChainTracker(chain)
+	for _, block := range side {
+		tracker.track(block)
+	}
+	chains := tracker.sideChains()
+	if len(chains) != 1 {
+		t.Fatalf("side chain count mismatch: have %d, want 1", len(chains))
+	}
+	sc := chains[0]
+	if sc.Head != side[1].Hash() || sc.Number != 4 || sc.Length != 2 {
+		t.Errorf("side chain head mismatch: have %x #%d (%d blocks), want %x #4 (2 blocks)", sc.Head, sc.Number, sc.Length, side[1].Hash())
+	}
+	if sc.ForkHash != blocks[1].Hash() || sc.ForkNumber != 2 {
+		t.Errorf("fork point mismatch: have %x #%d, want %x #2", sc.ForkHash, sc.ForkNumber, blocks[1].Hash())
+	}
+	if td := chain.GetTd(side[1].Hash(), 4); sc.TotalDifficulty.ToInt().Cmp(td) != 0 {
+		t.Errorf("total difficulty mismatch: have %v, want %v", sc.TotalDifficulty, td)
+	}
+}
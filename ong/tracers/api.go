@@ -20,6 +20,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -70,6 +71,10 @@ type Backend interface {
 	StateAtBlock(ctx context.Context, block *types.Block, reexec uint64) (*state.StateDB, func(), error)
 	StateAtTransaction(ctx context.Context, block *types.Block, txIndex int, reexec uint64) (core.Message, vm.BlockContext, *state.StateDB, func(), error)
 	StatesInRange(ctx context.Context, fromBlock *types.Block, toBlock *types.Block, reexec uint64) ([]*state.StateDB, func(), error)
+	RPCTraceTimeout() time.Duration
+	RPCBlockRangeCap() uint64
+	RPCExecutionBudget() *ongapi.ExecutionBudget
+	ABIRegistry() *ongapi.ABIRegistry
 }
 
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
@@ -207,21 +212,35 @@ type txTraceTask struct {
 
 // TraceChain returns the structured logs created during the execution of EVM
 // between two blocks (excluding start) and returns them as a JSON object.
-func (api *API) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceConfig) (*rpc.Subscription, error) { // Fetch the block interval that we want to trace
-	from, err := api.blockByNumber(ctx, start)
+func (api *API) TraceChain(ctx context.Context, start, end rpc.BlockNumberOrHash, config *TraceConfig) (*rpc.Subscription, error) { // Fetch the block interval that we want to trace
+	blocks := rpc.BlockRange{From: start, To: end}
+	first, last, err := blocks.Resolve(ongapi.NewBlockResolver(ctx, api.backend), api.backend.RPCBlockRangeCap())
 	if err != nil {
 		return nil, err
 	}
-	to, err := api.blockByNumber(ctx, end)
+	if first >= last {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", last, first)
+	}
+	from, err := api.blockByNumberOrHash(ctx, start, first)
 	if err != nil {
 		return nil, err
 	}
-	if from.Number().Cmp(to.Number()) >= 0 {
-		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", end, start)
+	to, err := api.blockByNumberOrHash(ctx, end, last)
+	if err != nil {
+		return nil, err
 	}
 	return api.traceChain(ctx, from, to, config)
 }
 
+// blockByNumberOrHash retrieves the block at one end of a resolved block range,
+// by hash if the range was pinned to one, by number otherwise.
+func (api *API) blockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, number uint64) (*types.Block, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return api.blockByHash(ctx, hash)
+	}
+	return api.blockByNumber(ctx, rpc.BlockNumber(number))
+}
+
 // traceChain configures a new tracer according to the provided configuration, and
 // executes all the transactions contained within. The return value will be one item
 // per transaction, dependent on the requested tracer.
@@ -744,33 +763,40 @@ func (api *API) TraceCall(ctx context.Context, args ongapi.CallArgs, blockNrOrHa
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
 func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTraceContext, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+	// Define a meaningful timeout of a single transaction trace, capped by the
+	// configured limit of the node and the execution budget of the requester
+	var (
+		timeout time.Duration
+		err     error
+	)
+	if config != nil && config.Tracer != nil {
+		timeout = defaultTraceTimeout
+	}
+	if config != nil && config.Timeout != nil {
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	if limit := api.backend.RPCTraceTimeout(); limit != 0 && (timeout == 0 || timeout > limit) {
+		timeout = limit
+	}
+	timeout, release, err := api.backend.RPCExecutionBudget().Acquire(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Assemble the structured logger or the JavaScript tracer
 	var (
 		tracer    vm.Tracer
-		err       error
 		txContext = core.NewEVMTxContext(message)
 	)
 	switch {
 	case config != nil && config.Tracer != nil:
-		// Define a meaningful timeout of a single transaction trace
-		timeout := defaultTraceTimeout
-		if config.Timeout != nil {
-			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
-				return nil, err
-			}
-		}
 		// Constuct the JavaScript tracer to execute with
 		if tracer, err = New(*config.Tracer, txContext); err != nil {
 			return nil, err
 		}
-		// Handle timeouts and RPC cancellations
-		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
-		go func() {
-			<-deadlineCtx.Done()
-			tracer.(*Tracer).Stop(errors.New("execution timeout"))
-		}()
-		defer cancel()
-
 	case config == nil:
 		tracer = vm.NewStructLogger(nil)
 
@@ -780,6 +806,21 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTrac
 	// Run the transaction with tracing enabled.
 	vmenv := vm.NewEVM(vmctx, txContext, statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: tracer})
 
+	// Handle timeouts and RPC cancellations
+	deadlineCtx, cancel := context.WithCancel(ctx)
+	if timeout > 0 {
+		deadlineCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	defer vmenv.CancelWith(deadlineCtx)()
+
+	if tracer, ok := tracer.(*Tracer); ok {
+		go func() {
+			<-deadlineCtx.Done()
+			tracer.Stop(errors.New("execution timeout"))
+		}()
+	}
+
 	// Call Prepare to clear out the statedb access list
 	statedb.Prepare(txctx.hash, txctx.block, txctx.index)
 
@@ -787,6 +828,9 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTrac
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %v", err)
 	}
+	if _, ok := tracer.(*vm.StructLogger); ok && vmenv.Cancelled() {
+		return nil, fmt.Errorf("tracing aborted (timeout = %v)", timeout)
+	}
 
 	// Depending on the tracer type, format and return the output.
 	switch tracer := tracer.(type) {
@@ -804,13 +848,66 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *txTrac
 		}, nil
 
 	case *Tracer:
-		return tracer.GetResult()
+		res, err := tracer.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		return api.decodeCallFrames(ctx, res), nil
 
 	default:
 		panic(fmt.Sprintf("bad tracer type %T", tracer))
 	}
 }
 
+// decodeCallFrames decodes the inputs of the call frames reported by a tracer,
+// e.g. the callTracer, with the contract ABIs registered on the RPC connection.
+// Each frame calling a contract with a known ABI gets a decodedCall field. The
+// results of the other tracers are returned unchanged.
+func (api *API) decodeCallFrames(ctx context.Context, res json.RawMessage) json.RawMessage {
+	var frame map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(res))
+	dec.UseNumber() // keep the numbers of the other fields intact
+	if err := dec.Decode(&frame); err != nil {
+		return res
+	}
+	if !decodeCallFrame(ctx, api.backend.ABIRegistry(), frame) {
+		return res
+	}
+	decoded, err := json.Marshal(frame)
+	if err != nil {
+		return res
+	}
+	return decoded
+}
+
+// decodeCallFrame decodes the input of a call frame and of its subcalls,
+// returning whonger any was decoded.
+func decodeCallFrame(ctx context.Context, abis *ongapi.ABIRegistry, frame map[string]interface{}) bool {
+	var decoded bool
+	if calls, ok := frame["calls"].([]interface{}); ok {
+		for _, call := range calls {
+			if call, ok := call.(map[string]interface{}); ok && decodeCallFrame(ctx, abis, call) {
+				decoded = true
+			}
+		}
+	}
+	to, _ := frame["to"].(string)
+	input, _ := frame["input"].(string)
+	if !common.IsHexAddress(to) || input == "" {
+		return decoded
+	}
+	data, err := hexutil.Decode(input)
+	if err != nil {
+		return decoded
+	}
+	address := common.HexToAddress(to)
+	if call := abis.DecodeCall(ctx, &address, data); call != nil {
+		frame["decodedCall"] = call
+		decoded = true
+	}
+	return decoded
+}
+
 // APIs return the collection of RPC services the tracer package offers.
 func APIs(backend Backend) []rpc.API {
 	// Append all the local APIs and return
@@ -55,6 +55,7 @@ type testBackend struct {
 	engine      consensus.Engine
 	chaindb     ongdb.Database
 	chain       *core.BlockChain
+	abis        *ongapi.ABIRegistry
 }
 
 func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) *testBackend {
@@ -62,6 +63,7 @@ func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i i
 		chainConfig: params.TestChainConfig,
 		engine:      ongash.NewFaker(),
 		chaindb:     rawdb.NewMemoryDatabase(),
+		abis:        ongapi.NewABIRegistry(),
 	}
 	// Generate blocks for testing
 	gspec.Config = backend.chainConfig
@@ -125,6 +127,22 @@ func (b *testBackend) RPCGasCap() uint64 {
 	return 25000000
 }
 
+func (b *testBackend) RPCTraceTimeout() time.Duration {
+	return 0
+}
+
+func (b *testBackend) RPCBlockRangeCap() uint64 {
+	return 0
+}
+
+func (b *testBackend) RPCExecutionBudget() *ongapi.ExecutionBudget {
+	return nil
+}
+
+func (b *testBackend) ABIRegistry() *ongapi.ABIRegistry {
+	return b.abis
+}
+
 func (b *testBackend) ChainConfig() *params.ChainConfig {
 	return b.chainConfig
 }
@@ -354,6 +372,79 @@ func TestTraceTransaction(t *testing.T) {
 	}
 }
 
+// abiBackend serves the ABI registration of the debug API from the registry of
+// the tracing backend.
+type abiBackend struct {
+	ongapi.Backend
+	abis *ongapi.ABIRegistry
+}
+
+func (b *abiBackend) ABIRegistry() *ongapi.ABIRegistry {
+	return b.abis
+}
+
+// Tests that the call frames reported by the call tracer are decoded with the
+// contract ABIs registered on the connection.
+func TestTraceTransactionDecodedCalls(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		proxy    = common.HexToAddress("0xc0de")
+		target   = common.HexToAddress("0xbeef")
+		txHash   common.Hash
+
+		// proxyCode forwards its input to the target contract
+		proxyCode = []byte{0x36, 0x60, 0x00, 0x60, 0x00, 0x37, 0x60, 0x00, 0x60, 0x00, 0x36, 0x60, 0x00, 0x60, 0x00, 0x61, 0xbe, 0xef, 0x5a, 0xf1, 0x00}
+		pingABI   = `[{"type":"function","name":"ping","inputs":[{"name":"value","type":"uint256"}],"outputs":[]}]`
+		pingInput = append(crypto.Keccak256([]byte("ping(uint256)"))[:4], common.LeftPadBytes([]byte{0x07}, 32)...)
+	)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Oranger)},
+		proxy:            {Code: proxyCode, Balance: new(big.Int)},
+		target:           {Code: []byte{0x00}, Balance: new(big.Int)},
+	}}
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), proxy, new(big.Int), 100000, big.NewInt(0), pingInput), types.HomesteadSigner{}, accounts[0].key)
+		b.AddTx(tx)
+		txHash = tx.Hash()
+	})
+	server := rpc.NewServer()
+	defer server.Stop()
+	server.RegisterName("debug", NewAPI(backend))
+	server.RegisterName("debug", ongapi.NewPrivateDebugAPI(&abiBackend{abis: backend.abis}))
+
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	if err := client.Call(nil, "debug_registerABI", target, pingABI); err != nil {
+		t.Fatalf("failed to register ABI: %v", err)
+	}
+	var (
+		tracer = "callTracer"
+		result struct {
+			To          common.Address      `json:"to"`
+			DecodedCall *ongapi.DecodedCall `json:"decodedCall"`
+			Calls       []struct {
+				To          common.Address      `json:"to"`
+				DecodedCall *ongapi.DecodedCall `json:"decodedCall"`
+			} `json:"calls"`
+		}
+	)
+	if err := client.Call(&result, "debug_traceTransaction", txHash, &TraceConfig{Tracer: &tracer}); err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if result.To != proxy || result.DecodedCall != nil {
+		t.Fatalf("top call mismatch: to %x, decoded %+v", result.To, result.DecodedCall)
+	}
+	if len(result.Calls) != 1 || result.Calls[0].To != target {
+		t.Fatalf("subcalls mismatch: %+v", result.Calls)
+	}
+	if call := result.Calls[0].DecodedCall; call == nil || call.Method != "ping" || call.Args["value"].(float64) != 7 {
+		t.Fatalf("subcall decoding mismatch: %+v", call)
+	}
+}
+
 func TestTraceBlock(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,83 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongclient
+
+import (
+	"context"
+
+	"github.com/ong2020/go-orange/p2p/enode"
+	"github.com/ong2020/go-orange/p2p/enr"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// RPCEndpoint is a set of public RPC endpoints advertised by a node in its
+// node record.
+type RPCEndpoint struct {
+	Node       *enode.Node // Node advertising the endpoints
+	URLs       []string    // Public endpoint URLs
+	Namespaces []string    // API namespaces served on the endpoints
+}
+
+// RPCEndpointOf extracts the RPC endpoints advertised in a node record, or nil if
+// the node doesn't advertise any.
+func RPCEndpointOf(node *enode.Node) *RPCEndpoint {
+	var entry rpc.ENREntry
+	if err := node.Load(enr.WithEntry(entry.ENRKey(), &entry)); err != nil {
+		return nil
+	}
+	if err := entry.Validate(); err != nil {
+		return nil
+	}
+	return &RPCEndpoint{
+		Node:       node,
+		URLs:       entry.URLs,
+		Namespaces: entry.Namespaces,
+	}
+}
+
+// DiscoverRPCEndpoints reads nodes from the iterator (e.g. a discovery or DNS
+// node source), collecting the ones advertising public RPC endpoints until max
+// of them are found, the iterator is exhausted or the context is cancelled.
+// The iterator is closed on return.
+func DiscoverRPCEndpoints(ctx context.Context, it enode.Iterator, max int) ([]*RPCEndpoint, error) {
+	defer it.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			it.Close()
+		case <-done:
+		}
+	}()
+	var (
+		endpoints []*RPCEndpoint
+		seen      = make(map[enode.ID]bool)
+	)
+	for len(endpoints) < max && it.Next() {
+		node := it.Node()
+		if seen[node.ID()] {
+			continue
+		}
+		seen[node.ID()] = true
+		if endpoint := RPCEndpointOf(node); endpoint != nil {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, ctx.Err()
+}
@@ -25,6 +25,7 @@ import (
 	"math/big"
 
 	"github.com/ong2020/go-orange"
+	"github.com/ong2020/go-orange/accounts/abi/bind"
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/common/hexutil"
 	"github.com/ong2020/go-orange/core/types"
@@ -284,6 +285,29 @@ func (ec *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*
 	return r, err
 }
 
+// BlockReceipts returns the receipts of all transactions in the given block.
+func (ec *Client) BlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]*types.Receipt, error) {
+	var r []*types.Receipt
+	err := ec.c.CallContext(ctx, &r, "ong_getBlockReceipts", blockNrOrHash)
+	if err == nil && r == nil {
+		return nil, orange.NotFound
+	}
+	return r, err
+}
+
+// WaitMined waits for tx to be mined and buried under the given number of blocks,
+// counting the containing block as the first confirmation. Reorgs removing the
+// containing block are followed. It stops waiting when the context is canceled.
+func (ec *Client) WaitMined(ctx context.Context, tx *types.Transaction, confirmations uint64) (*types.Receipt, error) {
+	return bind.WaitMinedConfirmations(ctx, ec, tx, confirmations)
+}
+
+// WaitDeployed waits for a contract deployment transaction to be mined with the
+// given number of confirmations and returns the on-chain contract address.
+func (ec *Client) WaitDeployed(ctx context.Context, tx *types.Transaction, confirmations uint64) (common.Address, error) {
+	return bind.WaitDeployedConfirmations(ctx, ec, tx, confirmations)
+}
+
 func toBlockNumArg(number *big.Int) string {
 	if number == nil {
 		return "latest"
@@ -28,6 +28,7 @@ import (
 
 	"github.com/ong2020/go-orange"
 	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
 	"github.com/ong2020/go-orange/consensus/ongash"
 	"github.com/ong2020/go-orange/core"
 	"github.com/ong2020/go-orange/core/rawdb"
@@ -253,6 +254,9 @@ func TestOngClient(t *testing.T) {
 		"TestChainID": {
 			func(t *testing.T) { testChainID(t, client) },
 		},
+		"TestChainConfig": {
+			func(t *testing.T) { testChainConfig(t, client) },
+		},
 		"TestGetBlock": {
 			func(t *testing.T) { testGetBlock(t, client) },
 		},
@@ -388,6 +392,36 @@ func testChainID(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testChainConfig(t *testing.T, client *rpc.Client) {
+	var result struct {
+		ChainID *hexutil.Big `json:"chainId"`
+		Engine  string       `json:"engine"`
+		Forks   []struct {
+			Name   string       `json:"name"`
+			Block  *hexutil.Big `json:"block"`
+			Active bool         `json:"active"`
+		} `json:"forks"`
+		Config *params.ChainConfig `json:"config"`
+	}
+	if err := client.CallContext(context.Background(), &result, "ong_chainConfig"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ChainID.ToInt().Cmp(params.AllOngashProtocolChanges.ChainID) != 0 {
+		t.Fatalf("chain id mismatch: have %v, want %v", result.ChainID, params.AllOngashProtocolChanges.ChainID)
+	}
+	if result.Engine != "ongash" {
+		t.Errorf("engine mismatch: have %s, want ongash", result.Engine)
+	}
+	if result.Config == nil || result.Config.BerlinBlock == nil {
+		t.Fatalf("missing chain configuration: %+v", result.Config)
+	}
+	for _, fork := range result.Forks {
+		if want := fork.Block != nil; fork.Active != want {
+			t.Errorf("fork %s activation mismatch: have %v, want %v", fork.Name, fork.Active, want)
+		}
+	}
+}
+
 func testGetBlock(t *testing.T, client *rpc.Client) {
 	ec := NewClient(client)
 	// Get current block number
@@ -430,6 +464,30 @@ func testGetBlock(t *testing.T, client *rpc.Client) {
 	if block.Header().Hash() != headerH.Hash() {
 		t.Fatalf("HeaderByHash returned wrong header: want %v got %v", block.Header().Hash().Hex(), headerH.Hash().Hex())
 	}
+	// Get block receipts by number and by hash
+	receipts, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(blockNumber)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(receipts) != len(block.Transactions()) {
+		t.Fatalf("BlockReceipts returned wrong number of receipts: want %d got %d", len(block.Transactions()), len(receipts))
+	}
+	receiptsH, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(block.Hash(), false))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(receiptsH) != len(receipts) {
+		t.Fatalf("BlockReceipts by hash returned %d receipts, by number %d", len(receiptsH), len(receipts))
+	}
+	if _, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithNumber(rpc.BlockNumber(blockNumber+1))); err != orange.NotFound {
+		t.Fatalf("BlockReceipts of unknown block: want %v got %v", orange.NotFound, err)
+	}
+	if _, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(block.Hash(), true)); err != nil {
+		t.Fatalf("BlockReceipts of canonical block: unexpected error: %v", err)
+	}
+	if _, err := ec.BlockReceipts(context.Background(), rpc.BlockNumberOrHashWithHash(common.Hash{1}, false)); err == nil || err == orange.NotFound {
+		t.Fatalf("BlockReceipts of unknown hash: want backend error, got %v", err)
+	}
 }
 
 func testStatusFunctions(t *testing.T, client *rpc.Client) {
@@ -82,19 +82,39 @@ type fullNodeBackend interface {
 }
 
 // Service implements an Orange netstats reporting daemon that pushes local
-// chain statistics up to a monitoring server.
+// chain statistics up to one or more monitoring servers.
 type Service struct {
 	server  *p2p.Server // Peer-to-peer server to retrieve networking infos
 	backend backend
 	engine  consensus.Engine // Consensus engine to retrieve variadic block fields
 
+	reporters []*reporter // Reporters pushing stats to the individual monitoring servers
+}
+
+// endpoint is a single monitoring server along with the credentials to use when
+// reporting to it.
+type endpoint struct {
 	node string // Name of the node to display on the monitoring page
 	pass string // Password to authorize access to the monitoring page
 	host string // Remote address of the monitoring service
+}
 
-	pongCh chan struct{} // Pong notifications are fed into this channel
-	histCh chan []uint64 // History request block numbers are fed into this channel
+// reporter pushes the chain statistics of the local node to a single monitoring
+// target. A target consists of a list of endpoints, out of which the first one
+// reachable is reported to, failing over to the next one whenever the current
+// connection breaks.
+type reporter struct {
+	*Service
 
+	endpoints []endpoint // Endpoints of the target in the order of preference
+
+	node string // Name of the node on the currently used endpoint
+	pass string // Password of the currently used endpoint
+
+	headCh chan *types.Block // Chain head notifications are fed into this channel
+	txCh   chan struct{}     // Transaction notifications are fed into this channel
+	pongCh chan struct{}     // Pong notifications are fed into this channel
+	histCh chan []uint64     // History request block numbers are fed into this channel
 }
 
 // connWrapper is a wrapper to prevent concurrent-write or concurrent-read on the
@@ -142,25 +162,51 @@ func (w *connWrapper) Close() error {
 	return w.conn.Close()
 }
 
+// parseURL splits a netstats connection url into reporting targets. Targets are
+// separated by commas and are all reported to simultaneously. Each target may
+// list several endpoints separated by pipes, which are used in order as failover
+// servers. Every endpoint has the form nodename:secret@host:port, so credentials
+// can be set individually for each of them.
+func parseURL(url string) ([][]endpoint, error) {
+	re := regexp.MustCompile("^([^:@]*)(:([^@]*))?@(.+)$")
+
+	var targets [][]endpoint
+	for _, target := range strings.Split(url, ",") {
+		var endpoints []endpoint
+		for _, spec := range strings.Split(target, "|") {
+			parts := re.FindStringSubmatch(strings.TrimSpace(spec))
+			if len(parts) != 5 {
+				return nil, fmt.Errorf("invalid netstats url: \"%s\", should be nodename:secret@host:port", spec)
+			}
+			endpoints = append(endpoints, endpoint{node: parts[1], pass: parts[3], host: parts[4]})
+		}
+		targets = append(targets, endpoints)
+	}
+	return targets, nil
+}
+
 // New returns a monitoring service ready for stats reporting.
 func New(node *node.Node, backend backend, engine consensus.Engine, url string) error {
 	// Parse the netstats connection url
-	re := regexp.MustCompile("([^:@]*)(:([^@]*))?@(.+)")
-	parts := re.FindStringSubmatch(url)
-	if len(parts) != 5 {
-		return fmt.Errorf("invalid netstats url: \"%s\", should be nodename:secret@host:port", url)
+	targets, err := parseURL(url)
+	if err != nil {
+		return err
 	}
 	ongstats := &Service{
 		backend: backend,
 		engine:  engine,
 		server:  node.Server(),
-		node:    parts[1],
-		pass:    parts[3],
-		host:    parts[4],
-		pongCh:  make(chan struct{}),
-		histCh:  make(chan []uint64, 1),
 	}
-
+	for _, endpoints := range targets {
+		ongstats.reporters = append(ongstats.reporters, &reporter{
+			Service:   ongstats,
+			endpoints: endpoints,
+			headCh:    make(chan *types.Block, 1),
+			txCh:      make(chan struct{}, 1),
+			pongCh:    make(chan struct{}),
+			histCh:    make(chan []uint64, 1),
+		})
+	}
 	node.RegisterLifecycle(ongstats)
 	return nil
 }
@@ -169,7 +215,7 @@ func New(node *node.Node, backend backend, engine consensus.Engine, url string)
 func (s *Service) Start() error {
 	go s.loop()
 
-	log.Info("Stats daemon started")
+	log.Info("Stats daemon started", "targets", len(s.reporters))
 	return nil
 }
 
@@ -179,8 +225,8 @@ func (s *Service) Stop() error {
 	return nil
 }
 
-// loop keeps trying to connect to the netstats server, reporting chain events
-// until termination.
+// loop subscribes to chain events and distributes them to all reporters, each
+// of which keeps its own monitoring server updated until termination.
 func (s *Service) loop() {
 	// Subscribe to chain events to execute updates on
 	chainHeadCh := make(chan core.ChainHeadEvent, chainHeadChanSize)
@@ -191,56 +237,102 @@ func (s *Service) loop() {
 	txSub := s.backend.SubscribeNewTxsEvent(txEventCh)
 	defer txSub.Unsubscribe()
 
-	// Start a goroutine that exhausts the subscriptions to avoid events piling up
-	var (
-		quitCh = make(chan struct{})
-		headCh = make(chan *types.Block, 1)
-		txCh   = make(chan struct{}, 1)
-	)
-	go func() {
-		var lastTx mclock.AbsTime
+	// Start the reporters, each maintaining its own server connection
+	quitCh := make(chan struct{})
+	for _, r := range s.reporters {
+		go r.loop(quitCh)
+	}
+	// Exhaust the subscriptions to avoid events piling up
+	var lastTx mclock.AbsTime
 
-	HandleLoop:
-		for {
-			select {
-			// Notify of chain head events, but drop if too frequent
-			case head := <-chainHeadCh:
+	for {
+		select {
+		// Notify of chain head events, but drop if too frequent
+		case head := <-chainHeadCh:
+			for _, r := range s.reporters {
 				select {
-				case headCh <- head.Block:
+				case r.headCh <- head.Block:
 				default:
 				}
+			}
 
-			// Notify of new transaction events, but drop if too frequent
-			case <-txEventCh:
-				if time.Duration(mclock.Now()-lastTx) < time.Second {
-					continue
-				}
-				lastTx = mclock.Now()
+		// Notify of new transaction events, but drop if too frequent
+		case <-txEventCh:
+			if time.Duration(mclock.Now()-lastTx) < time.Second {
+				continue
+			}
+			lastTx = mclock.Now()
 
+			for _, r := range s.reporters {
 				select {
-				case txCh <- struct{}{}:
+				case r.txCh <- struct{}{}:
 				default:
 				}
-
-			// node stopped
-			case <-txSub.Err():
-				break HandleLoop
-			case <-headSub.Err():
-				break HandleLoop
 			}
+
+		// node stopped
+		case <-txSub.Err():
+			close(quitCh)
+			return
+		case <-headSub.Err():
+			close(quitCh)
+			return
 		}
-		close(quitCh)
-	}()
+	}
+}
 
+// dial establishes a websocket connection to the given monitoring server on any
+// supported URL.
+func dial(host string) (*connWrapper, error) {
 	// Resolve the URL, defaulting to TLS, but falling back to none too
-	path := fmt.Sprintf("%s/api", s.host)
+	path := fmt.Sprintf("%s/api", host)
 	urls := []string{path}
 
 	// url.Parse and url.IsAbs is unsuitable (https://github.com/golang/go/issues/19779)
 	if !strings.Contains(path, "://") {
 		urls = []string{"wss://" + path, "ws://" + path}
 	}
+	dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	header := make(http.Header)
+	header.Set("origin", "http://localhost")
+
+	var err error
+	for _, url := range urls {
+		c, _, e := dialer.Dial(url, header)
+		if e == nil {
+			return newConnectionWrapper(c), nil
+		}
+		err = e
+	}
+	return nil, err
+}
+
+// connect tries the endpoints of the reporting target in order, returning an
+// authenticated connection to the first one available.
+func (r *reporter) connect() (*connWrapper, error) {
+	var err error
+	for _, ep := range r.endpoints {
+		var conn *connWrapper
+		if conn, err = dial(ep.host); err != nil {
+			log.Warn("Stats server unreachable", "host", ep.host, "err", err)
+			continue
+		}
+		// Authenticate the client with the server
+		r.node, r.pass = ep.node, ep.pass
+		if err = r.login(conn); err != nil {
+			log.Warn("Stats login failed", "host", ep.host, "err", err)
+			conn.Close()
+			continue
+		}
+		log.Debug("Connected to stats server", "host", ep.host)
+		return conn, nil
+	}
+	return nil, err
+}
 
+// loop keeps trying to connect to the netstats server of the target, reporting
+// chain events until termination.
+func (r *reporter) loop(quitCh chan struct{}) {
 	errTimer := time.NewTimer(0)
 	defer errTimer.Stop()
 	// Loop reporting until termination
@@ -249,38 +341,16 @@ func (s *Service) loop() {
 		case <-quitCh:
 			return
 		case <-errTimer.C:
-			// Establish a websocket connection to the server on any supported URL
-			var (
-				conn *connWrapper
-				err  error
-			)
-			dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
-			header := make(http.Header)
-			header.Set("origin", "http://localhost")
-			for _, url := range urls {
-				c, _, e := dialer.Dial(url, header)
-				err = e
-				if err == nil {
-					conn = newConnectionWrapper(c)
-					break
-				}
-			}
+			// Establish an authenticated connection to any of the servers
+			conn, err := r.connect()
 			if err != nil {
-				log.Warn("Stats server unreachable", "err", err)
-				errTimer.Reset(10 * time.Second)
-				continue
-			}
-			// Authenticate the client with the server
-			if err = s.login(conn); err != nil {
-				log.Warn("Stats login failed", "err", err)
-				conn.Close()
 				errTimer.Reset(10 * time.Second)
 				continue
 			}
-			go s.readLoop(conn)
+			go r.readLoop(conn)
 
 			// Send the initial stats so our node looks decent from the get go
-			if err = s.report(conn); err != nil {
+			if err = r.report(conn); err != nil {
 				log.Warn("Initial stats report failed", "err", err)
 				conn.Close()
 				errTimer.Reset(0)
@@ -298,22 +368,22 @@ func (s *Service) loop() {
 					return
 
 				case <-fullReport.C:
-					if err = s.report(conn); err != nil {
+					if err = r.report(conn); err != nil {
 						log.Warn("Full stats report failed", "err", err)
 					}
-				case list := <-s.histCh:
-					if err = s.reportHistory(conn, list); err != nil {
+				case list := <-r.histCh:
+					if err = r.reportHistory(conn, list); err != nil {
 						log.Warn("Requested history report failed", "err", err)
 					}
-				case head := <-headCh:
-					if err = s.reportBlock(conn, head); err != nil {
+				case head := <-r.headCh:
+					if err = r.reportBlock(conn, head); err != nil {
 						log.Warn("Block stats report failed", "err", err)
 					}
-					if err = s.reportPending(conn); err != nil {
+					if err = r.reportPending(conn); err != nil {
 						log.Warn("Post-block transaction stats report failed", "err", err)
 					}
-				case <-txCh:
-					if err = s.reportPending(conn); err != nil {
+				case <-r.txCh:
+					if err = r.reportPending(conn); err != nil {
 						log.Warn("Transaction stats report failed", "err", err)
 					}
 				}
@@ -331,7 +401,7 @@ func (s *Service) loop() {
 // from the network socket. If any of them match an active request, it forwards
 // it, if they themselves are requests it initiates a reply, and lastly it drops
 // unknown packets.
-func (s *Service) readLoop(conn *connWrapper) {
+func (r *reporter) readLoop(conn *connWrapper) {
 	// If the read loop exists, close the connection
 	defer conn.Close()
 
@@ -370,7 +440,7 @@ func (s *Service) readLoop(conn *connWrapper) {
 		// If the message is a ping reply, deliver (someone must be listening!)
 		if len(msg["emit"]) == 2 && command == "node-pong" {
 			select {
-			case s.pongCh <- struct{}{}:
+			case r.pongCh <- struct{}{}:
 				// Pong delivered, continue listening
 				continue
 			default:
@@ -386,7 +456,7 @@ func (s *Service) readLoop(conn *connWrapper) {
 			if !ok {
 				log.Warn("Invalid stats history request", "msg", msg["emit"][1])
 				select {
-				case s.histCh <- nil: // Treat it as an no indexes request
+				case r.histCh <- nil: // Treat it as an no indexes request
 				default:
 				}
 				continue
@@ -407,7 +477,7 @@ func (s *Service) readLoop(conn *connWrapper) {
 				numbers[i] = uint64(n)
 			}
 			select {
-			case s.histCh <- numbers:
+			case r.histCh <- numbers:
 				continue
 			default:
 			}
@@ -440,12 +510,12 @@ type authMsg struct {
 }
 
 // login tries to authorize the client at the remote server.
-func (s *Service) login(conn *connWrapper) error {
+func (r *reporter) login(conn *connWrapper) error {
 	// Construct and send the login authentication
-	infos := s.server.NodeInfo()
+	infos := r.server.NodeInfo()
 
 	var protocols []string
-	for _, proto := range s.server.Protocols {
+	for _, proto := range r.server.Protocols {
 		protocols = append(protocols, fmt.Sprintf("%s/%d", proto.Name, proto.Version))
 	}
 	var network string
@@ -455,9 +525,9 @@ func (s *Service) login(conn *connWrapper) error {
 		network = fmt.Sprintf("%d", infos.Protocols["les"].(*les.NodeInfo).Network)
 	}
 	auth := &authMsg{
-		ID: s.node,
+		ID: r.node,
 		Info: nodeInfo{
-			Name:     s.node,
+			Name:     r.node,
 			Node:     infos.Name,
 			Port:     infos.Ports.Listener,
 			Network:  network,
@@ -468,7 +538,7 @@ func (s *Service) login(conn *connWrapper) error {
 			Client:   "0.1.1",
 			History:  true,
 		},
-		Secret: s.pass,
+		Secret: r.pass,
 	}
 	login := map[string][]interface{}{
 		"emit": {"hello", auth},
@@ -487,17 +557,17 @@ func (s *Service) login(conn *connWrapper) error {
 // report collects all possible data to report and send it to the stats server.
 // This should only be used on reconnects or rarely to avoid overloading the
 // server. Use the individual Methods for reporting subscribed events.
-func (s *Service) report(conn *connWrapper) error {
-	if err := s.reportLatency(conn); err != nil {
+func (r *reporter) report(conn *connWrapper) error {
+	if err := r.reportLatency(conn); err != nil {
 		return err
 	}
-	if err := s.reportBlock(conn, nil); err != nil {
+	if err := r.reportBlock(conn, nil); err != nil {
 		return err
 	}
-	if err := s.reportPending(conn); err != nil {
+	if err := r.reportPending(conn); err != nil {
 		return err
 	}
-	if err := s.reportStats(conn); err != nil {
+	if err := r.reportStats(conn); err != nil {
 		return err
 	}
 	return nil
@@ -505,13 +575,13 @@ func (s *Service) report(conn *connWrapper) error {
 
 // reportLatency sends a ping request to the server, measures the RTT time and
 // finally sends a latency update.
-func (s *Service) reportLatency(conn *connWrapper) error {
+func (r *reporter) reportLatency(conn *connWrapper) error {
 	// Send the current time to the ongstats server
 	start := time.Now()
 
 	ping := map[string][]interface{}{
 		"emit": {"node-ping", map[string]string{
-			"id":         s.node,
+			"id":         r.node,
 			"clientTime": start.String(),
 		}},
 	}
@@ -520,7 +590,7 @@ func (s *Service) reportLatency(conn *connWrapper) error {
 	}
 	// Wait for the pong request to arrive back
 	select {
-	case <-s.pongCh:
+	case <-r.pongCh:
 		// Pong delivered, report the latency
 	case <-time.After(5 * time.Second):
 		// Ping timeout, abort
@@ -533,7 +603,7 @@ func (s *Service) reportLatency(conn *connWrapper) error {
 
 	stats := map[string][]interface{}{
 		"emit": {"latency", map[string]string{
-			"id":      s.node,
+			"id":      r.node,
 			"latency": latency,
 		}},
 	}
@@ -574,15 +644,15 @@ func (s uncleStats) MarshalJSON() ([]byte, error) {
 }
 
 // reportBlock retrieves the current chain head and reports it to the stats server.
-func (s *Service) reportBlock(conn *connWrapper, block *types.Block) error {
+func (r *reporter) reportBlock(conn *connWrapper, block *types.Block) error {
 	// Gather the block details from the header or block chain
-	details := s.assembleBlockStats(block)
+	details := r.assembleBlockStats(block)
 
 	// Assemble the block report and send it to the server
 	log.Trace("Sending new block to ongstats", "number", details.Number, "hash", details.Hash)
 
 	stats := map[string]interface{}{
-		"id":    s.node,
+		"id":    r.node,
 		"block": details,
 	}
 	report := map[string][]interface{}{
@@ -649,7 +719,7 @@ func (s *Service) assembleBlockStats(block *types.Block) *blockStats {
 
 // reportHistory retrieves the most recent batch of blocks and reports it to the
 // stats server.
-func (s *Service) reportHistory(conn *connWrapper, list []uint64) error {
+func (r *reporter) reportHistory(conn *connWrapper, list []uint64) error {
 	// Figure out the indexes that need reporting
 	indexes := make([]uint64, 0, historyUpdateRange)
 	if len(list) > 0 {
@@ -657,7 +727,7 @@ func (s *Service) reportHistory(conn *connWrapper, list []uint64) error {
 		indexes = append(indexes, list...)
 	} else {
 		// No indexes requested, send back the top ones
-		head := s.backend.CurrentHeader().Number.Int64()
+		head := r.backend.CurrentHeader().Number.Int64()
 		start := head - historyUpdateRange + 1
 		if start < 0 {
 			start = 0
@@ -669,19 +739,19 @@ func (s *Service) reportHistory(conn *connWrapper, list []uint64) error {
 	// Gather the batch of blocks to report
 	history := make([]*blockStats, len(indexes))
 	for i, number := range indexes {
-		fullBackend, ok := s.backend.(fullNodeBackend)
+		fullBackend, ok := r.backend.(fullNodeBackend)
 		// Retrieve the next block if it's known to us
 		var block *types.Block
 		if ok {
 			block, _ = fullBackend.BlockByNumber(context.Background(), rpc.BlockNumber(number)) // TODO ignore error here ?
 		} else {
-			if header, _ := s.backend.HeaderByNumber(context.Background(), rpc.BlockNumber(number)); header != nil {
+			if header, _ := r.backend.HeaderByNumber(context.Background(), rpc.BlockNumber(number)); header != nil {
 				block = types.NewBlockWithHeader(header)
 			}
 		}
 		// If we do have the block, add to the history and continue
 		if block != nil {
-			history[len(history)-1-i] = s.assembleBlockStats(block)
+			history[len(history)-1-i] = r.assembleBlockStats(block)
 			continue
 		}
 		// Ran out of blocks, cut the report short and send
@@ -695,7 +765,7 @@ func (s *Service) reportHistory(conn *connWrapper, list []uint64) error {
 		log.Trace("No history to send to stats server")
 	}
 	stats := map[string]interface{}{
-		"id":      s.node,
+		"id":      r.node,
 		"history": history,
 	}
 	report := map[string][]interface{}{
@@ -711,14 +781,14 @@ type pendStats struct {
 
 // reportPending retrieves the current number of pending transactions and reports
 // it to the stats server.
-func (s *Service) reportPending(conn *connWrapper) error {
+func (r *reporter) reportPending(conn *connWrapper) error {
 	// Retrieve the pending count from the local blockchain
-	pending, _ := s.backend.Stats()
+	pending, _ := r.backend.Stats()
 	// Assemble the transaction stats and send it to the server
 	log.Trace("Sending pending transactions to ongstats", "count", pending)
 
 	stats := map[string]interface{}{
-		"id": s.node,
+		"id": r.node,
 		"stats": &pendStats{
 			Pending: pending,
 		},
@@ -742,7 +812,7 @@ type nodeStats struct {
 
 // reportStats retrieves various stats about the node at the networking and
 // mining layer and reports it to the stats server.
-func (s *Service) reportStats(conn *connWrapper) error {
+func (r *reporter) reportStats(conn *connWrapper) error {
 	// Gather the syncing and mining infos from the local miner instance
 	var (
 		mining   bool
@@ -751,7 +821,7 @@ func (s *Service) reportStats(conn *connWrapper) error {
 		gasprice int
 	)
 	// check if backend is a full node
-	fullBackend, ok := s.backend.(fullNodeBackend)
+	fullBackend, ok := r.backend.(fullNodeBackend)
 	if ok {
 		mining = fullBackend.Miner().Mining()
 		hashrate = int(fullBackend.Miner().HashRate())
@@ -762,19 +832,19 @@ func (s *Service) reportStats(conn *connWrapper) error {
 		price, _ := fullBackend.SuggestPrice(context.Background())
 		gasprice = int(price.Uint64())
 	} else {
-		sync := s.backend.Downloader().Progress()
-		syncing = s.backend.CurrentHeader().Number.Uint64() >= sync.HighestBlock
+		sync := r.backend.Downloader().Progress()
+		syncing = r.backend.CurrentHeader().Number.Uint64() >= sync.HighestBlock
 	}
 	// Assemble the node stats and send it to the server
 	log.Trace("Sending node details to ongstats")
 
 	stats := map[string]interface{}{
-		"id": s.node,
+		"id": r.node,
 		"stats": &nodeStats{
 			Active:   true,
 			Mining:   mining,
 			Hashrate: hashrate,
-			Peers:    s.server.PeerCount(),
+			Peers:    r.server.PeerCount(),
 			GasPrice: gasprice,
 			Syncing:  syncing,
 			Uptime:   100,
@@ -0,0 +1,73 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ongstats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want [][]endpoint
+		fail bool
+	}{
+		{
+			url:  "node:secret@stats.example.org:3000",
+			want: [][]endpoint{{{node: "node", pass: "secret", host: "stats.example.org:3000"}}},
+		},
+		{
+			url:  "node@localhost:3000",
+			want: [][]endpoint{{{node: "node", host: "localhost:3000"}}},
+		},
+		{
+			url: "public:s1@stats.example.org:3000,internal:s2@10.0.0.1:3000",
+			want: [][]endpoint{
+				{{node: "public", pass: "s1", host: "stats.example.org:3000"}},
+				{{node: "internal", pass: "s2", host: "10.0.0.1:3000"}},
+			},
+		},
+		{
+			url: "node:s1@primary:3000|node:s2@backup:3000,other:s3@wss://other/stats",
+			want: [][]endpoint{
+				{{node: "node", pass: "s1", host: "primary:3000"}, {node: "node", pass: "s2", host: "backup:3000"}},
+				{{node: "other", pass: "s3", host: "wss://other/stats"}},
+			},
+		},
+		{url: "", fail: true},
+		{url: "localhost:3000", fail: true},
+		{url: "node:secret@localhost:3000,", fail: true},
+		{url: "node:secret@localhost:3000|", fail: true},
+	}
+	for _, tt := range tests {
+		have, err := parseURL(tt.url)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("url %q: expected error, got %v", tt.url, have)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("url %q: unexpected error: %v", tt.url, err)
+			continue
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("url %q: endpoint mismatch: have %+v, want %+v", tt.url, have, tt.want)
+		}
+	}
+}
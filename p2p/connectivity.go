@@ -0,0 +1,208 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"time"
+)
+
+const (
+	connectivityProbes = 3         // Number of peers asked for the observed endpoint
+	recentInbound      = time.Hour // Time since the last inbound connection still proving reachability
+)
+
+// Reachability verdicts of the connectivity check.
+const (
+	Reachable   = "reachable"   // Unsolicited inbound traffic was received
+	Unreachable = "unreachable" // Probes show the advertised endpoint can't be reached
+	Unverified  = "unverified"  // No evidence either way, e.g. no peers to probe yet
+)
+
+var errNoDiscovery = errors.New("discovery v4 not running")
+
+// ConnectivityProbe is the result of asking a connected peer for the endpoint
+// of the local node as observed from its side.
+type ConnectivityProbe struct {
+	ID       string        `json:"id"`                 // Node ID of the probed peer
+	Remote   string        `json:"remoteAddress"`      // Discovery endpoint of the probed peer
+	Observed string        `json:"observed,omitempty"` // Local endpoint as observed by the peer
+	RTT      time.Duration `json:"rtt,omitempty"`      // Round trip time of the probe
+	Error    string        `json:"error,omitempty"`    // Failure of the probe
+}
+
+// PortConnectivity is the reachability of a single listening port.
+type PortConnectivity struct {
+	Port    int    `json:"port"`    // Advertised port
+	Verdict string `json:"verdict"` // Reachable, unreachable or unverified
+	Reason  string `json:"reason"`  // Explanation of the verdict
+}
+
+// ConnectivityReport is the outcome of an inbound reachability self-test.
+type ConnectivityReport struct {
+	Endpoint     string               `json:"endpoint"`     // IP address advertised in the local node record
+	TCP          *PortConnectivity    `json:"tcp"`          // Reachability of the RLPx listener
+	UDP          *PortConnectivity    `json:"udp"`          // Reachability of the discovery port
+	InboundPeers int                  `json:"inboundPeers"` // Number of connected inbound peers
+	TotalPeers   int                  `json:"totalPeers"`   // Number of connected peers
+	LastInbound  time.Time            `json:"lastInbound"`  // Time of the last accepted inbound connection
+	Probes       []*ConnectivityProbe `json:"probes"`       // Endpoint probes sent to connected peers
+}
+
+// CheckConnectivity runs a self-test of the inbound reachability of the listener
+// and the discovery ports. Connected peers are asked via discovery for the local
+// endpoint they observe, which is compared to the advertised one, and the
+// evidence of unsolicited inbound traffic (inbound peers, discovery packets from
+// nodes never contacted) is collected.
+func (srv *Server) CheckConnectivity(ctx context.Context) *ConnectivityReport {
+	var (
+		peers  = srv.Peers()
+		self   = srv.Self()
+		report = &ConnectivityReport{
+			Endpoint:   self.IP().String(),
+			TotalPeers: len(peers),
+			Probes:     []*ConnectivityProbe{},
+		}
+	)
+	for _, p := range peers {
+		if p.Inbound() {
+			report.InboundPeers++
+		}
+	}
+	srv.natStatus.lock.Lock()
+	report.LastInbound = srv.natStatus.lastInbound
+	srv.natStatus.lock.Unlock()
+
+	// Probe a random selection of the peers with known discovery endpoints.
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	for _, p := range peers {
+		if len(report.Probes) == connectivityProbes {
+			break
+		}
+		if n := p.Node(); n.IP() != nil && n.UDP() != 0 {
+			report.Probes = append(report.Probes, &ConnectivityProbe{
+				ID:     n.ID().String(),
+				Remote: (&net.UDPAddr{IP: n.IP(), Port: n.UDP()}).String(),
+			})
+		}
+	}
+	srv.runProbes(ctx, peers, report.Probes)
+
+	report.TCP = tcpConnectivity(self.TCP(), report.InboundPeers, report.LastInbound)
+	report.UDP = udpConnectivity(self.UDP(), srv.ntab != nil, srv.localnode.PredictFullConeNAT(), report.Probes)
+	return report
+}
+
+// runProbes asks the selected peers for the local endpoint they observe.
+func (srv *Server) runProbes(ctx context.Context, peers []*Peer, probes []*ConnectivityProbe) {
+	if srv.ntab == nil {
+		for _, probe := range probes {
+			probe.Error = errNoDiscovery.Error()
+		}
+		return
+	}
+	nodes := make(map[string]*Peer)
+	for _, p := range peers {
+		nodes[p.ID().String()] = p
+	}
+	type result struct {
+		index    int
+		observed *net.UDPAddr
+		rtt      time.Duration
+		err      error
+	}
+	results := make(chan result, len(probes))
+	for i, probe := range probes {
+		go func(i int, p *Peer) {
+			start := time.Now()
+			observed, err := srv.ntab.PingEndpoint(p.Node())
+			results <- result{i, observed, time.Since(start), err}
+		}(i, nodes[probe.ID])
+	}
+	for range probes {
+		select {
+		case res := <-results:
+			probe := probes[res.index]
+			if res.err != nil {
+				probe.Error = res.err.Error()
+			} else {
+				probe.Observed, probe.RTT = res.observed.String(), res.rtt
+			}
+		case <-ctx.Done():
+			// Abandon the remaining probes, they finish on their own timeout.
+			for _, probe := range probes {
+				if probe.Observed == "" && probe.Error == "" {
+					probe.Error = ctx.Err().Error()
+				}
+			}
+			return
+		}
+	}
+}
+
+// tcpConnectivity derives the reachability of the listener from the inbound
+// connections accepted recently.
+func tcpConnectivity(port int, inbound int, lastInbound time.Time) *PortConnectivity {
+	res := &PortConnectivity{Port: port}
+	switch {
+	case port == 0:
+		res.Verdict, res.Reason = Unverified, "listener not running"
+	case inbound > 0:
+		res.Verdict, res.Reason = Reachable, "inbound peers connected"
+	case !lastInbound.IsZero() && time.Since(lastInbound) < recentInbound:
+		res.Verdict, res.Reason = Reachable, "inbound connection accepted recently"
+	default:
+		res.Verdict, res.Reason = Unverified, "no inbound connections accepted recently, check port forwarding and firewall"
+	}
+	return res
+}
+
+// udpConnectivity derives the reachability of the discovery port from the
+// unsolicited discovery traffic and the endpoints observed by the probed peers.
+func udpConnectivity(port int, discovery bool, fullCone bool, probes []*ConnectivityProbe) *PortConnectivity {
+	res := &PortConnectivity{Port: port}
+	if port == 0 || !discovery {
+		res.Verdict, res.Reason = Unverified, "discovery not running"
+		return res
+	}
+	if fullCone {
+		res.Verdict, res.Reason = Reachable, "discovery packets received from uncontacted nodes"
+		return res
+	}
+	var answered, matched int
+	for _, probe := range probes {
+		if probe.Observed == "" {
+			continue
+		}
+		answered++
+		if addr, err := net.ResolveUDPAddr("udp", probe.Observed); err == nil && addr.Port == port {
+			matched++
+		}
+	}
+	switch {
+	case answered == 0:
+		res.Verdict, res.Reason = Unverified, "no probes answered"
+	case matched == 0:
+		res.Verdict, res.Reason = Unreachable, "peers observe a different port, NAT is remapping the discovery port"
+	default:
+		res.Verdict, res.Reason = Unverified, "peers observe the advertised port, but no unsolicited packets were received yet"
+	}
+	return res
+}
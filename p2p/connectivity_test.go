@@ -0,0 +1,52 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests the reachability verdicts derived from the connectivity evidence.
+func TestConnectivityVerdicts(t *testing.T) {
+	if res := tcpConnectivity(30303, 1, time.Time{}); res.Verdict != Reachable {
+		t.Errorf("tcp with inbound peers: have %s, want %s", res.Verdict, Reachable)
+	}
+	if res := tcpConnectivity(30303, 0, time.Now().Add(-2*recentInbound)); res.Verdict != Unverified {
+		t.Errorf("tcp without recent inbound: have %s, want %s", res.Verdict, Unverified)
+	}
+	var (
+		remapped = []*ConnectivityProbe{{Observed: "1.2.3.4:40000"}, {Error: "timeout"}}
+		matching = []*ConnectivityProbe{{Observed: "1.2.3.4:30303"}}
+	)
+	tests := []struct {
+		discovery, fullCone bool
+		probes              []*ConnectivityProbe
+		want                string
+	}{
+		{false, false, matching, Unverified},
+		{true, true, nil, Reachable},
+		{true, false, nil, Unverified},
+		{true, false, remapped, Unreachable},
+		{true, false, matching, Unverified},
+	}
+	for i, tt := range tests {
+		if res := udpConnectivity(30303, tt.discovery, tt.fullCone, tt.probes); res.Verdict != tt.want {
+			t.Errorf("test %d: have %s (%s), want %s", i, res.Verdict, res.Reason, tt.want)
+		}
+	}
+}
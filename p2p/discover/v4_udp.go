@@ -161,6 +161,20 @@ func (t *UDPv4) Self() *enode.Node {
 	return t.localNode.Node()
 }
 
+// AllNodes returns all the nodes stored in the local table.
+func (t *UDPv4) AllNodes() []*enode.Node {
+	t.tab.mutex.Lock()
+	defer t.tab.mutex.Unlock()
+	nodes := make([]*enode.Node, 0)
+
+	for _, b := range &t.tab.buckets {
+		for _, n := range b.entries {
+			nodes = append(nodes, unwrapNode(n))
+		}
+	}
+	return nodes
+}
+
 // Close shuts down the socket and aborts any running queries.
 func (t *UDPv4) Close() {
 	t.closeOnce.Do(func() {
@@ -213,6 +227,17 @@ func (t *UDPv4) Ping(n *enode.Node) error {
 	return err
 }
 
+// PingEndpoint sends a ping message to the given node, returning the UDP endpoint
+// of the local node as observed by the remote end.
+func (t *UDPv4) PingEndpoint(n *enode.Node) (*net.UDPAddr, error) {
+	rm := t.sendPing(n.ID(), &net.UDPAddr{IP: n.IP(), Port: n.UDP()}, nil)
+	if err := <-rm.errc; err != nil {
+		return nil, err
+	}
+	to := rm.reply.(*v4wire.Pong).To
+	return &net.UDPAddr{IP: to.IP, Port: int(to.UDP)}, nil
+}
+
 // ping sends a ping message to the given node and waits for a reply.
 func (t *UDPv4) ping(n *enode.Node) (seq uint64, err error) {
 	rm := t.sendPing(n.ID(), &net.UDPAddr{IP: n.IP(), Port: n.UDP()}, nil)
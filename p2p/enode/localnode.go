@@ -203,6 +203,16 @@ func (ln *LocalNode) UDPContact(toaddr *net.UDPAddr) {
 	ln.updateEndpoints()
 }
 
+// PredictFullConeNAT reports whether the local node seems to be reachable via
+// UDP by nodes it didn't contact before, i.e. it isn't behind NAT or the NAT
+// device forwards unsolicited packets.
+func (ln *LocalNode) PredictFullConeNAT() bool {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+
+	return ln.endpoint4.track.PredictFullConeNAT() || ln.endpoint6.track.PredictFullConeNAT()
+}
+
 // updateEndpoints updates the record with predicted endpoints.
 func (ln *LocalNode) updateEndpoints() {
 	ip4, udp4 := ln.endpoint4.get()
@@ -0,0 +1,292 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/log"
+)
+
+const (
+	maxForensicRecords = 256             // Number of records kept, older ones are deleted
+	maxForensicPayload = 2 * 1024 * 1024 // Maximum number of payload bytes stored per message
+)
+
+var (
+	errForensicsDisabled = errors.New("forensic capture disabled")
+	errForensicNotFound  = errors.New("forensic record not found")
+)
+
+// ForensicMessage is a protocol message captured as evidence.
+type ForensicMessage struct {
+	Code      uint64        `json:"code"`                // Message code, relative to the protocol
+	Size      uint32        `json:"size"`                // Size of the message payload
+	Payload   hexutil.Bytes `json:"payload"`             // Raw message payload
+	Truncated bool          `json:"truncated,omitempty"` // Whether the stored payload is truncated
+}
+
+// ForensicRecord is the evidence captured when a peer is dropped for violating
+// a protocol: the peer's identity and the handshake and offending messages.
+type ForensicRecord struct {
+	ID            string    `json:"id"`            // Identifier of the record
+	Time          time.Time `json:"time"`          // Time the peer was dropped
+	Peer          string    `json:"peer"`          // Node ID of the peer
+	Name          string    `json:"name"`          // Client name advertised in the p2p handshake
+	Enode         string    `json:"enode"`         // Node URL of the peer
+	ENR           string    `json:"enr"`           // Node record of the peer
+	Caps          []string  `json:"caps"`          // Protocols advertised in the p2p handshake
+	RemoteAddress string    `json:"remoteAddress"` // Remote endpoint of the connection
+	Inbound       bool      `json:"inbound"`       // Whether the connection was inbound
+	Protocol      string    `json:"protocol"`      // Name and version of the violated protocol
+	Error         string    `json:"error"`         // Violation the peer was dropped for
+
+	Handshake *ForensicMessage `json:"handshake,omitempty"` // First message received on the protocol
+	Message   *ForensicMessage `json:"message,omitempty"`   // Last message received before the violation
+}
+
+// forensicStore persists the forensic records of a server in a directory, one
+// JSON file per record.
+type forensicStore struct {
+	dir  string
+	lock sync.Mutex
+	log  log.Logger
+}
+
+func newForensicStore(dir string, logger log.Logger) *forensicStore {
+	return &forensicStore{dir: dir, log: logger}
+}
+
+// capture stores the evidence of a protocol violation of a peer.
+func (s *forensicStore) capture(p *Peer, proto *protoRW, rec *msgRecorder, err error) {
+	info := p.Info()
+	record := &ForensicRecord{
+		Time:          time.Now(),
+		Peer:          info.ID,
+		Name:          info.Name,
+		Enode:         info.Enode,
+		ENR:           info.ENR,
+		Caps:          info.Caps,
+		RemoteAddress: info.Network.RemoteAddress,
+		Inbound:       info.Network.Inbound,
+		Protocol:      fmt.Sprintf("%s/%d", proto.Name, proto.Version),
+		Error:         err.Error(),
+	}
+	record.ID = fmt.Sprintf("%d-%.16s", record.Time.UnixNano(), info.ID)
+	record.Handshake, record.Message = rec.messages()
+
+	if err := s.store(record); err != nil {
+		s.log.Warn("Failed to store forensic record", "peer", info.ID, "err", err)
+		return
+	}
+	s.log.Debug("Stored forensic record", "id", record.ID, "peer", info.ID, "protocol", record.Protocol, "err", err)
+}
+
+// store writes a record into the directory, deleting the oldest records above
+// the retention limit.
+func (s *forensicStore) store(record *ForensicRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	blob, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.dir, record.ID+".json"), blob, 0600); err != nil {
+		return err
+	}
+	ids, err := s.ids()
+	if err != nil {
+		return err
+	}
+	for len(ids) > maxForensicRecords {
+		os.Remove(filepath.Join(s.dir, ids[0]+".json"))
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// ids returns the identifiers of the stored records, oldest first.
+func (s *forensicStore) ids() ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, file := range files {
+		if name := file.Name(); !file.IsDir() && strings.HasSuffix(name, ".json") {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	// Identifiers start with the capture time, compare them numerically
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids, nil
+}
+
+// list returns the stored records without their messages, oldest first.
+func (s *forensicStore) list() ([]*ForensicRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]*ForensicRecord, 0, len(ids))
+	for _, id := range ids {
+		record, err := s.read(id)
+		if err != nil {
+			continue // Deleted or corrupted meanwhile
+		}
+		record.Handshake, record.Message = nil, nil
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// get returns a stored record.
+func (s *forensicStore) get(id string) (*ForensicRecord, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, errForensicNotFound
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	record, err := s.read(id)
+	if os.IsNotExist(err) {
+		return nil, errForensicNotFound
+	}
+	return record, err
+}
+
+// read loads a record from the directory.
+func (s *forensicStore) read(id string) (*ForensicRecord, error) {
+	blob, err := ioutil.ReadFile(filepath.Join(s.dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	record := new(ForensicRecord)
+	if err := json.Unmarshal(blob, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// msgRecorder wraps a MsgReadWriter and retains the first (handshake) and the
+// last message read, to be captured if the protocol fails.
+type msgRecorder struct {
+	MsgReadWriter
+
+	lock        sync.Mutex
+	first, last *ForensicMessage
+}
+
+func newMsgRecorder(rw MsgReadWriter) *msgRecorder {
+	return &msgRecorder{MsgReadWriter: rw}
+}
+
+// ReadMsg reads a message from the underlying MsgReadWriter, buffering its
+// payload to retain a copy.
+func (rec *msgRecorder) ReadMsg() (Msg, error) {
+	msg, err := rec.MsgReadWriter.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	payload, err := ioutil.ReadAll(io.LimitReader(msg.Payload, int64(msg.Size)))
+	if err != nil {
+		return msg, err
+	}
+	msg.Payload = bytes.NewReader(payload)
+
+	captured := &ForensicMessage{Code: msg.Code, Size: msg.Size, Payload: payload}
+	if len(payload) > maxForensicPayload {
+		captured.Payload, captured.Truncated = payload[:maxForensicPayload], true
+	}
+	rec.lock.Lock()
+	if rec.first == nil {
+		rec.first = captured
+	}
+	rec.last = captured
+	rec.lock.Unlock()
+
+	return msg, nil
+}
+
+// messages returns the first and last messages read. The last one is omitted if
+// it's the same as the first.
+func (rec *msgRecorder) messages() (*ForensicMessage, *ForensicMessage) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if rec.last == rec.first {
+		return rec.first, nil
+	}
+	return rec.first, rec.last
+}
+
+// isProtocolViolation reports whether a protocol failed due to a misbehaving
+// peer, rather than a disconnect or a shutdown.
+func isProtocolViolation(err error) bool {
+	if err == nil || err == io.EOF || err == errProtocolReturned {
+		return false
+	}
+	var reason DiscReason
+	if errors.As(err, &reason) {
+		return reason == DiscProtocolError || reason == DiscSubprotocolError
+	}
+	return true
+}
+
+// ForensicRecords returns the stored forensic records of peers dropped for
+// protocol violations, without their captured messages.
+func (srv *Server) ForensicRecords() ([]*ForensicRecord, error) {
+	if srv.forensics == nil {
+		return nil, errForensicsDisabled
+	}
+	return srv.forensics.list()
+}
+
+// ForensicRecord returns a stored forensic record including its messages.
+func (srv *Server) ForensicRecord(id string) (*ForensicRecord, error) {
+	if srv.forensics == nil {
+		return nil, errForensicsDisabled
+	}
+	return srv.forensics.get(id)
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/rlp"
+)
+
+// Tests that the handshake and the offending message of a peer violating a
+// protocol are captured, and can be listed and retrieved.
+func TestForensicCapture(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forensics-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	errViolation := errors.New("invalid message")
+	proto := Protocol{
+		Name:    "a",
+		Version: 1,
+		Length:  5,
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			if err := ExpectMsg(rw, 0, []uint{1}); err != nil {
+				return err
+			}
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			// The payload must still be readable by the protocol
+			var content []uint
+			if err := msg.Decode(&content); err != nil || len(content) != 2 {
+				return fmt.Errorf("payload lost: %v %v", content, err)
+			}
+			return errViolation
+		},
+	}
+	var (
+		fd1, fd2   = net.Pipe()
+		key1, key2 = newkey(), newkey()
+		c1         = &conn{fd: fd1, node: newNode(uintID(1), ""), transport: newTestTransport(&key2.PublicKey, fd1, nil), caps: []Cap{proto.cap()}}
+		c2         = &conn{fd: fd2, node: newNode(uintID(2), ""), transport: newTestTransport(&key1.PublicKey, fd2, &key1.PublicKey), caps: []Cap{proto.cap()}}
+		store      = newForensicStore(dir, log.Root())
+		peer       = newPeer(log.Root(), c1, []Protocol{proto})
+		errc       = make(chan error, 1)
+	)
+	defer c2.close(errors.New("test done"))
+
+	peer.forensics = store
+	go func() {
+		_, err := peer.run()
+		errc <- err
+	}()
+	Send(c2, baseProtocolLength, []uint{1})
+	Send(c2, baseProtocolLength+3, []uint{2, 3})
+
+	select {
+	case err := <-errc:
+		if err != errViolation {
+			t.Fatalf("peer error mismatch: have %v, want %v", err, errViolation)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("protocol violation timeout")
+	}
+	records, err := store.list()
+	if err != nil {
+		t.Fatalf("failed to list records: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("record count mismatch: have %d, want 1", len(records))
+	}
+	if records[0].Message != nil || records[0].Handshake != nil {
+		t.Errorf("listed record contains messages")
+	}
+	record, err := store.get(records[0].ID)
+	if err != nil {
+		t.Fatalf("failed to retrieve record: %v", err)
+	}
+	if record.Protocol != "a/1" || record.Error != errViolation.Error() || record.Enode == "" {
+		t.Errorf("record mismatch: %+v", record)
+	}
+	handshake, _ := rlp.EncodeToBytes([]uint{1})
+	if record.Handshake == nil || record.Handshake.Code != 0 || !bytes.Equal(record.Handshake.Payload, handshake) {
+		t.Errorf("handshake mismatch: have %+v, want code 0 payload %x", record.Handshake, handshake)
+	}
+	message, _ := rlp.EncodeToBytes([]uint{2, 3})
+	if record.Message == nil || record.Message.Code != 3 || !bytes.Equal(record.Message.Payload, message) {
+		t.Errorf("message mismatch: have %+v, want code 3 payload %x", record.Message, message)
+	}
+	if _, err := store.get("../" + record.ID); err != errForensicNotFound {
+		t.Errorf("path traversal not rejected: %v", err)
+	}
+}
+
+func TestIsProtocolViolation(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{errProtocolReturned, false},
+		{DiscQuitting, false},
+		{DiscTooManyPeers, false},
+		{DiscReason(DiscSubprotocolError), true},
+		{newPeerError(errInvalidMsg, "test"), true},
+		{errors.New("invalid message"), true},
+		{fmt.Errorf("wrapped: %w", DiscUselessPeer), false},
+	}
+	for _, tt := range tests {
+		if have := isProtocolViolation(tt.err); have != tt.want {
+			t.Errorf("%v: have %v, want %v", tt.err, have, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/p2p/enode"
+)
+
+const (
+	// maxHandshakeFailureNodes is the maximum number of remote nodes for which
+	// handshake failures are tracked. The least recently failing node is evicted
+	// when the limit is reached.
+	maxHandshakeFailureNodes = 256
+
+	// maxHandshakeFailuresPerNode is the number of most recent handshake failures
+	// retained for each remote node.
+	maxHandshakeFailuresPerNode = 8
+)
+
+// HandshakeError is returned by sub-protocols if the protocol handshake with a
+// remote peer failed (or the peer was rejected right after it), allowing the
+// server to keep track of the failure for diagnostic purposes.
+type HandshakeError struct {
+	Protocol string // Name of the sub-protocol whose handshake failed
+	Err      error  // Underlying failure
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("%s handshake failed: %v", e.Protocol, e.Err)
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// HandshakeFailure is a single failed handshake attempt with a remote node.
+type HandshakeFailure struct {
+	Time     time.Time `json:"time"`     // Time of the failure
+	Inbound  bool      `json:"inbound"`  // Whether the connection was initiated remotely
+	Protocol string    `json:"protocol"` // Failing handshake: rlpx, p2p or a sub-protocol name
+	Reason   string    `json:"reason"`   // Root cause (e.g. "fork ID rejected", "too many peers")
+	Error    string    `json:"error"`    // Full error message
+}
+
+// HandshakeFailuresInfo represents the recent handshake failures with a remote node.
+type HandshakeFailuresInfo struct {
+	ID            string              `json:"id"`            // Unique node identifier
+	Name          string              `json:"name"`          // Client name, if the p2p handshake succeeded
+	RemoteAddress string              `json:"remoteAddress"` // Remote endpoint of the last failed connection
+	Count         uint64              `json:"count"`         // Total number of failures tracked
+	Failures      []*HandshakeFailure `json:"failures"`      // Most recent failures, newest first
+}
+
+// handshakeFailures tracks the most recent failed handshakes per remote node.
+// The zero value is ready to use.
+type handshakeFailures struct {
+	nodes map[enode.ID]*HandshakeFailuresInfo
+	lock  sync.Mutex
+}
+
+// add records a failed handshake with the given remote node.
+func (hf *handshakeFailures) add(id enode.ID, name, addr, protocol string, inbound bool, err error) {
+	hf.lock.Lock()
+	defer hf.lock.Unlock()
+
+	if hf.nodes == nil {
+		hf.nodes = make(map[enode.ID]*HandshakeFailuresInfo)
+	}
+	info, ok := hf.nodes[id]
+	if !ok {
+		if len(hf.nodes) >= maxHandshakeFailureNodes {
+			hf.evictOldest()
+		}
+		info = &HandshakeFailuresInfo{ID: id.String()}
+		hf.nodes[id] = info
+	}
+	if name != "" {
+		info.Name = name
+	}
+	info.RemoteAddress = addr
+	info.Count++
+
+	failure := &HandshakeFailure{
+		Time:     time.Now(),
+		Inbound:  inbound,
+		Protocol: protocol,
+		Reason:   handshakeFailureReason(err),
+		Error:    err.Error(),
+	}
+	info.Failures = append([]*HandshakeFailure{failure}, info.Failures...)
+	if len(info.Failures) > maxHandshakeFailuresPerNode {
+		info.Failures = info.Failures[:maxHandshakeFailuresPerNode]
+	}
+}
+
+// evictOldest drops the node whose last handshake failure is the oldest.
+func (hf *handshakeFailures) evictOldest() {
+	var (
+		oldest enode.ID
+		last   time.Time
+	)
+	for id, info := range hf.nodes {
+		if t := info.Failures[0].Time; last.IsZero() || t.Before(last) {
+			oldest, last = id, t
+		}
+	}
+	delete(hf.nodes, oldest)
+}
+
+// list returns a copy of the tracked failures, the most recently failing nodes
+// first.
+func (hf *handshakeFailures) list() []*HandshakeFailuresInfo {
+	hf.lock.Lock()
+	defer hf.lock.Unlock()
+
+	infos := make([]*HandshakeFailuresInfo, 0, len(hf.nodes))
+	for _, info := range hf.nodes {
+		cpy := *info
+		cpy.Failures = make([]*HandshakeFailure, len(info.Failures))
+		for i, failure := range info.Failures {
+			f := *failure
+			cpy.Failures[i] = &f
+		}
+		infos = append(infos, &cpy)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Failures[0].Time.After(infos[j].Failures[0].Time)
+	})
+	return infos
+}
+
+// handshakeFailureReason returns the message of the innermost wrapped error,
+// which is the classified root cause for the errors returned by the protocol
+// handshakes (e.g. "network ID mismatch" out of "network ID mismatch: 1 (!= 5)").
+func handshakeFailureReason(err error) string {
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return err.Error()
+		}
+		err = inner
+	}
+}
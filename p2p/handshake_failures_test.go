@@ -0,0 +1,67 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ong2020/go-orange/p2p/enode"
+)
+
+// Tests that handshake failures are classified by their root cause and that
+// the number of tracked failures is bounded.
+func TestHandshakeFailures(t *testing.T) {
+	var (
+		hf       handshakeFailures
+		mismatch = errors.New("network ID mismatch")
+	)
+	for i := 0; i < maxHandshakeFailuresPerNode+2; i++ {
+		err := &HandshakeError{Protocol: "ong", Err: fmt.Errorf("%w: 1 (!= 5)", mismatch)}
+		hf.add(enode.ID{1}, "client", "127.0.0.1:30303", err.Protocol, false, err.Err)
+	}
+	hf.add(enode.ID{2}, "", "127.0.0.2:30303", "p2p", true, DiscTooManyPeers)
+
+	infos := hf.list()
+	if len(infos) != 2 {
+		t.Fatalf("tracked node count mismatch: have %d, want 2", len(infos))
+	}
+	if infos[0].ID != (enode.ID{2}).String() || infos[0].Failures[0].Reason != "too many peers" {
+		t.Errorf("most recent failure mismatch: %+v", infos[0].Failures[0])
+	}
+	if have := infos[1]; have.Count != maxHandshakeFailuresPerNode+2 || len(have.Failures) != maxHandshakeFailuresPerNode {
+		t.Errorf("failure count mismatch: have %d/%d", have.Count, len(have.Failures))
+	}
+	if reason := infos[1].Failures[0].Reason; reason != "network ID mismatch" {
+		t.Errorf("reason mismatch: have %q, want %q", reason, "network ID mismatch")
+	}
+	for i := 0; i < maxHandshakeFailureNodes; i++ {
+		hf.add(enode.ID{3, byte(i)}, "", "", "rlpx", false, errors.New("EOF"))
+	}
+	if n := len(hf.list()); n != maxHandshakeFailureNodes {
+		t.Errorf("tracked node count not bounded: have %d, want %d", n, maxHandshakeFailureNodes)
+	}
+}
+
+// Tests that wrapped disconnect reasons are preserved when dropping a peer.
+func TestDiscReasonForHandshakeError(t *testing.T) {
+	err := &HandshakeError{Protocol: "ong", Err: DiscTooManyPeers}
+	if reason := discReasonForError(err); reason != DiscTooManyPeers {
+		t.Errorf("disconnect reason mismatch: have %v, want %v", reason, DiscTooManyPeers)
+	}
+}
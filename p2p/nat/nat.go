@@ -97,6 +97,15 @@ const (
 // Map adds a port mapping on m and keeps it alive until c is closed.
 // This function is typically invoked in its own goroutine.
 func Map(m Interface, c <-chan struct{}, protocol string, extport, intport int, name string) {
+	MapWithReport(m, c, protocol, extport, intport, name, nil)
+}
+
+// MapWithReport is like Map, but also reports the outcome of every attempt to
+// add or refresh the mapping to the given callback, if non-nil.
+func MapWithReport(m Interface, c <-chan struct{}, protocol string, extport, intport int, name string, report func(error)) {
+	if report == nil {
+		report = func(error) {}
+	}
 	log := log.New("proto", protocol, "extport", extport, "intport", intport, "interface", m)
 	refresh := time.NewTimer(mapTimeout)
 	defer func() {
@@ -104,11 +113,13 @@ func Map(m Interface, c <-chan struct{}, protocol string, extport, intport int,
 		log.Debug("Deleting port mapping")
 		m.DeleteMapping(protocol, extport, intport)
 	}()
-	if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
+	err := m.AddMapping(protocol, extport, intport, name, mapTimeout)
+	if err != nil {
 		log.Debug("Couldn't add port mapping", "err", err)
 	} else {
 		log.Info("Mapped network port")
 	}
+	report(err)
 	for {
 		select {
 		case _, ok := <-c:
@@ -117,9 +128,11 @@ func Map(m Interface, c <-chan struct{}, protocol string, extport, intport int,
 			}
 		case <-refresh.C:
 			log.Trace("Refreshing port mapping")
-			if err := m.AddMapping(protocol, extport, intport, name, mapTimeout); err != nil {
+			err := m.AddMapping(protocol, extport, intport, name, mapTimeout)
+			if err != nil {
 				log.Debug("Couldn't add port mapping", "err", err)
 			}
+			report(err)
 			refresh.Reset(mapTimeout)
 		}
 	}
@@ -0,0 +1,192 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/p2p/nat"
+)
+
+// natRefreshInterval is the time between two queries of the external IP address
+// from the NAT device, to detect address changes (e.g. on dynamic IPs).
+const natRefreshInterval = 5 * time.Minute
+
+// NATPortMapping is the status of a port mapped on the NAT device.
+type NATPortMapping struct {
+	Protocol    string    `json:"protocol"`        // Transport protocol, tcp or udp
+	Port        int       `json:"port"`            // Mapped local (and external) port
+	Mapped      bool      `json:"mapped"`          // Whether the last mapping attempt succeeded
+	LastAttempt time.Time `json:"lastAttempt"`     // Time of the last mapping attempt
+	Error       string    `json:"error,omitempty"` // Error of the last mapping attempt
+}
+
+// NATStatus reports the externally reachable endpoint of the node, as resolved
+// via the NAT device, along with the evidence of it actually being reachable.
+type NATStatus struct {
+	Interface    string            `json:"interface,omitempty"`    // Configured NAT mechanism, empty if none
+	ExternalIP   net.IP            `json:"externalIP,omitempty"`   // Last external IP reported by the NAT device
+	LastRefresh  time.Time         `json:"lastRefresh,omitempty"`  // Time of the last external IP query
+	RefreshError string            `json:"refreshError,omitempty"` // Error of the last external IP query
+	IPChanges    int               `json:"ipChanges"`              // Number of external IP changes detected
+	Mappings     []*NATPortMapping `json:"mappings"`               // Port mappings on the NAT device
+	Endpoint     string            `json:"endpoint"`               // Endpoint advertised in the local node record
+	TCP          int               `json:"tcp"`                    // TCP port advertised in the local node record
+	UDP          int               `json:"udp"`                    // UDP port advertised in the local node record
+	LastInbound  time.Time         `json:"lastInbound,omitempty"`  // Time of the last accepted inbound connection
+}
+
+// natTracker keeps track of the NAT related state of the server. The zero value
+// is ready to use.
+type natTracker struct {
+	externalIP   net.IP
+	lastRefresh  time.Time
+	refreshError string
+	ipChanges    int
+	mappings     []*NATPortMapping
+	lastInbound  time.Time
+	lock         sync.Mutex
+}
+
+// mapping returns the status entry of a port mapping, creating it if needed.
+func (nt *natTracker) mapping(protocol string, port int) *NATPortMapping {
+	nt.lock.Lock()
+	defer nt.lock.Unlock()
+
+	for _, m := range nt.mappings {
+		if m.Protocol == protocol && m.Port == port {
+			return m
+		}
+	}
+	m := &NATPortMapping{Protocol: protocol, Port: port}
+	nt.mappings = append(nt.mappings, m)
+	return m
+}
+
+// reportMapping records the outcome of an attempt to add a port mapping.
+func (nt *natTracker) reportMapping(protocol string, port int, err error) {
+	m := nt.mapping(protocol, port)
+
+	nt.lock.Lock()
+	defer nt.lock.Unlock()
+
+	m.Mapped, m.LastAttempt, m.Error = err == nil, time.Now(), ""
+	if err != nil {
+		m.Error = err.Error()
+	}
+}
+
+// reportExternalIP records the outcome of an external IP query, returning whether
+// the address changed since the last successful query.
+func (nt *natTracker) reportExternalIP(ip net.IP, err error) bool {
+	nt.lock.Lock()
+	defer nt.lock.Unlock()
+
+	nt.lastRefresh = time.Now()
+	if err != nil {
+		nt.refreshError = err.Error()
+		return false
+	}
+	nt.refreshError = ""
+	if nt.externalIP.Equal(ip) {
+		return false
+	}
+	if nt.externalIP != nil {
+		nt.ipChanges++
+	}
+	nt.externalIP = ip
+	return true
+}
+
+// reportInbound records an accepted inbound connection.
+func (nt *natTracker) reportInbound() {
+	nt.lock.Lock()
+	defer nt.lock.Unlock()
+
+	nt.lastInbound = time.Now()
+}
+
+// mapPort maps a listening port on the NAT device until the server is stopped,
+// tracking the outcome of the mapping attempts.
+func (srv *Server) mapPort(protocol string, port int, name string) {
+	srv.natStatus.mapping(protocol, port)
+
+	srv.loopWG.Add(1)
+	go func() {
+		defer srv.loopWG.Done()
+		nat.MapWithReport(srv.NAT, srv.quit, protocol, port, port, name, func(err error) {
+			srv.natStatus.reportMapping(protocol, port, err)
+		})
+	}()
+}
+
+// natLoop periodically queries the external IP address from the NAT device,
+// updating the local node record (and thus the endpoint announced to the
+// network) whenever it changes.
+func (srv *Server) natLoop() {
+	defer srv.loopWG.Done()
+
+	refresh := time.NewTimer(0)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-refresh.C:
+			ip, err := srv.NAT.ExternalIP()
+			if changed := srv.natStatus.reportExternalIP(ip, err); changed {
+				srv.log.Info("External IP address changed", "ip", ip)
+				srv.localnode.SetStaticIP(ip)
+			} else if err != nil {
+				srv.log.Debug("Couldn't get external IP address", "interface", srv.NAT, "err", err)
+			}
+			refresh.Reset(natRefreshInterval)
+
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+// NATStatus returns the externally reachable endpoint of the node as resolved
+// via the NAT device, the status of the port mappings and the time an inbound
+// connection was last accepted.
+func (srv *Server) NATStatus() *NATStatus {
+	status := &NATStatus{Mappings: []*NATPortMapping{}}
+	if srv.NAT != nil {
+		status.Interface = srv.NAT.String()
+	}
+	srv.natStatus.lock.Lock()
+	status.ExternalIP = srv.natStatus.externalIP
+	status.LastRefresh = srv.natStatus.lastRefresh
+	status.RefreshError = srv.natStatus.refreshError
+	status.IPChanges = srv.natStatus.ipChanges
+	status.LastInbound = srv.natStatus.lastInbound
+	for _, m := range srv.natStatus.mappings {
+		cpy := *m
+		status.Mappings = append(status.Mappings, &cpy)
+	}
+	srv.natStatus.lock.Unlock()
+
+	if srv.localnode != nil {
+		node := srv.localnode.Node()
+		status.Endpoint = node.IP().String()
+		status.TCP, status.UDP = node.TCP(), node.UDP()
+	}
+	return status
+}
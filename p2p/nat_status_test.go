@@ -0,0 +1,53 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// Tests that external IP changes and port mapping outcomes are tracked.
+func TestNATTracker(t *testing.T) {
+	var nt natTracker
+
+	if !nt.reportExternalIP(net.IP{1, 2, 3, 4}, nil) {
+		t.Error("initial external IP not reported as changed")
+	}
+	if nt.reportExternalIP(net.IP{1, 2, 3, 4}, nil) {
+		t.Error("unchanged external IP reported as changed")
+	}
+	if nt.reportExternalIP(nil, errors.New("timeout")) || nt.refreshError != "timeout" {
+		t.Errorf("failed refresh mismatch: error %q", nt.refreshError)
+	}
+	if !nt.reportExternalIP(net.IP{5, 6, 7, 8}, nil) || nt.ipChanges != 1 || nt.refreshError != "" {
+		t.Errorf("external IP change mismatch: changes %d, error %q", nt.ipChanges, nt.refreshError)
+	}
+	nt.reportMapping("tcp", 30303, errors.New("no mapping"))
+	nt.reportMapping("udp", 30303, nil)
+	nt.reportMapping("tcp", 30303, nil)
+
+	if len(nt.mappings) != 2 {
+		t.Fatalf("mapping count mismatch: have %d, want 2", len(nt.mappings))
+	}
+	for _, m := range nt.mappings {
+		if !m.Mapped || m.Error != "" {
+			t.Errorf("%s mapping status mismatch: %+v", m.Protocol, m)
+		}
+	}
+}
@@ -116,6 +116,9 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// forensics stores the evidence of protocol violations if set
+	forensics *forensicStore
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -387,6 +390,11 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name, p.Info().Network.RemoteAddress, p.Info().Network.LocalAddress)
 		}
+		var recorder *msgRecorder
+		if p.forensics != nil {
+			recorder = newMsgRecorder(rw)
+			rw = recorder
+		}
 		p.log.Trace(fmt.Sprintf("Starting protocol %s/%d", proto.Name, proto.Version))
 		go func() {
 			defer p.wg.Done()
@@ -397,6 +405,9 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 			} else if err != io.EOF {
 				p.log.Trace(fmt.Sprintf("Protocol %s/%d failed", proto.Name, proto.Version), "err", err)
 			}
+			if recorder != nil && isProtocolViolation(err) {
+				p.forensics.capture(p, proto, recorder, err)
+			}
 			p.protoErr <- err
 		}()
 	}
@@ -100,7 +100,8 @@ func (d DiscReason) Error() string {
 }
 
 func discReasonForError(err error) DiscReason {
-	if reason, ok := err.(DiscReason); ok {
+	var reason DiscReason
+	if errors.As(err, &reason) {
 		return reason
 	}
 	if err == errProtocolReturned {
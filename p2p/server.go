@@ -152,6 +152,11 @@ type Config struct {
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
 
+	// ForensicsDir is the directory to store the evidence (offending message,
+	// node record, handshake) of peers dropped for protocol violations in. The
+	// capture is disabled if empty.
+	ForensicsDir string `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -176,6 +181,8 @@ type Server struct {
 	ourHandshake *protoHandshake
 	loopWG       sync.WaitGroup // loop, listenLoop
 	peerFeed     event.Feed
+	natStatus    natTracker // NAT mapping and reachability status
+	forensics    *forensicStore
 	log          log.Logger
 
 	nodedb    *enode.DB
@@ -197,6 +204,9 @@ type Server struct {
 
 	// State of run loop and listenLoop.
 	inboundHistory expHeap
+
+	// Recent failed handshakes, for diagnosing connectivity issues.
+	hsFailures handshakeFailures
 }
 
 type peerOpFunc func(map[enode.ID]*Peer)
@@ -316,6 +326,16 @@ func (srv *Server) PeerCount() int {
 	return count
 }
 
+// SetMaxPeers changes the maximum number of connected peers while the server
+// is running. Excess peers are not disconnected, but no new ones are accepted
+// until the peer count drops below the limit. The number of dialed peers stays
+// bounded by the limit the server was started with.
+func (srv *Server) SetMaxPeers(n int) {
+	srv.doPeerOp(func(map[enode.ID]*Peer) {
+		srv.MaxPeers = n
+	})
+}
+
 // AddPeer adds the given node to the static node set. When there is room in the peer set,
 // the server will connect to the node. If the connection fails for any reason, the server
 // will attempt to reconnect the peer.
@@ -447,6 +467,9 @@ func (srv *Server) Start() (err error) {
 	if srv.clock == nil {
 		srv.clock = mclock.System{}
 	}
+	if srv.ForensicsDir != "" {
+		srv.forensics = newForensicStore(srv.ForensicsDir, srv.log)
+	}
 	if srv.NoDial && srv.ListenAddr == "" {
 		srv.log.Warn("P2P server will be useless, neither dialing nor listening")
 	}
@@ -516,18 +539,15 @@ func (srv *Server) setupLocalNode() error {
 		// No NAT interface, do nothing.
 	case nat.ExtIP:
 		// ExtIP doesn't block, set the IP right away.
-		ip, _ := srv.NAT.ExternalIP()
+		ip, err := srv.NAT.ExternalIP()
+		srv.natStatus.reportExternalIP(ip, err)
 		srv.localnode.SetStaticIP(ip)
 	default:
 		// Ask the router about the IP. This takes a while and blocks startup,
-		// do it in the background.
+		// do it in the background. The address is re-queried periodically to
+		// detect changes and update the announced endpoint.
 		srv.loopWG.Add(1)
-		go func() {
-			defer srv.loopWG.Done()
-			if ip, err := srv.NAT.ExternalIP(); err == nil {
-				srv.localnode.SetStaticIP(ip)
-			}
-		}()
+		go srv.natLoop()
 	}
 	return nil
 }
@@ -561,11 +581,7 @@ func (srv *Server) setupDiscovery() error {
 	srv.log.Debug("UDP listener up", "addr", realaddr)
 	if srv.NAT != nil {
 		if !realaddr.IP.IsLoopback() {
-			srv.loopWG.Add(1)
-			go func() {
-				nat.Map(srv.NAT, srv.quit, "udp", realaddr.Port, realaddr.Port, "orange discovery")
-				srv.loopWG.Done()
-			}()
+			srv.mapPort("udp", realaddr.Port, "orange discovery")
 		}
 	}
 	srv.localnode.SetFallbackUDP(realaddr.Port)
@@ -668,11 +684,7 @@ func (srv *Server) setupListening() error {
 	if tcp, ok := listener.Addr().(*net.TCPAddr); ok {
 		srv.localnode.Set(enr.TCP(tcp.Port))
 		if !tcp.IP.IsLoopback() && srv.NAT != nil {
-			srv.loopWG.Add(1)
-			go func() {
-				nat.Map(srv.NAT, srv.quit, "tcp", tcp.Port, tcp.Port, "orange p2p")
-				srv.loopWG.Done()
-			}()
+			srv.mapPort("tcp", tcp.Port, "orange p2p")
 		}
 	}
 
@@ -882,6 +894,7 @@ func (srv *Server) listenLoop() {
 			slots <- struct{}{}
 			continue
 		}
+		srv.natStatus.reportInbound()
 		if remoteIP != nil {
 			var addr *net.TCPAddr
 			if tcp, ok := fd.RemoteAddr().(*net.TCPAddr); ok {
@@ -957,6 +970,9 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 	remotePubkey, err := c.doEncHandshake(srv.PrivateKey)
 	if err != nil {
 		srv.log.Trace("Failed RLPx handshake", "addr", c.fd.RemoteAddr(), "conn", c.flags, "err", err)
+		if dialDest != nil {
+			srv.hsFailures.add(dialDest.ID(), "", c.fd.RemoteAddr().String(), "rlpx", false, err)
+		}
 		return err
 	}
 	if dialDest != nil {
@@ -968,6 +984,7 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 	err = srv.checkpoint(c, srv.checkpointPostHandshake)
 	if err != nil {
 		clog.Trace("Rejected peer", "err", err)
+		srv.recordHandshakeFailure(c, err)
 		return err
 	}
 
@@ -975,22 +992,34 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 	phs, err := c.doProtoHandshake(srv.ourHandshake)
 	if err != nil {
 		clog.Trace("Failed p2p handshake", "err", err)
+		srv.recordHandshakeFailure(c, err)
 		return err
 	}
 	if id := c.node.ID(); !bytes.Equal(crypto.Keccak256(phs.ID), id[:]) {
 		clog.Trace("Wrong devp2p handshake identity", "phsid", hex.EncodeToString(phs.ID))
+		srv.recordHandshakeFailure(c, DiscUnexpectedIdentity)
 		return DiscUnexpectedIdentity
 	}
 	c.caps, c.name = phs.Caps, phs.Name
 	err = srv.checkpoint(c, srv.checkpointAddPeer)
 	if err != nil {
 		clog.Trace("Rejected peer", "err", err)
+		srv.recordHandshakeFailure(c, err)
 		return err
 	}
 
 	return nil
 }
 
+// recordHandshakeFailure tracks a connection failing the devp2p handshake or the
+// checks following it.
+func (srv *Server) recordHandshakeFailure(c *conn, err error) {
+	if err == errServerStopped {
+		return
+	}
+	srv.hsFailures.add(c.node.ID(), c.name, c.fd.RemoteAddr().String(), "p2p", c.is(inboundConn), err)
+}
+
 func nodeFromConn(pubkey *ecdsa.PublicKey, conn net.Conn) *enode.Node {
 	var ip net.IP
 	var port int
@@ -1019,6 +1048,7 @@ func (srv *Server) launchPeer(c *conn) *Peer {
 		// to the peer.
 		p.events = &srv.peerFeed
 	}
+	p.forensics = srv.forensics
 	go srv.runPeer(p)
 	return p
 }
@@ -1038,6 +1068,12 @@ func (srv *Server) runPeer(p *Peer) {
 	// Run the per-peer main loop.
 	remoteRequested, err := p.run()
 
+	// Track sub-protocol handshake failures.
+	var hsErr *HandshakeError
+	if errors.As(err, &hsErr) {
+		srv.hsFailures.add(p.ID(), p.Name(), p.RemoteAddr().String(), hsErr.Protocol, p.Inbound(), hsErr.Err)
+	}
+
 	// Announce disconnect on the main loop to update the peer set.
 	// The main loop waits for existing peers to be sent on srv.delpeer
 	// before returning, so this send should not select on srv.quit.
@@ -1100,6 +1136,65 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+// DiscoveredNodeInfo represents a short summary of a node found by discovery.
+type DiscoveredNodeInfo struct {
+	ID    string `json:"id"`    // Unique node identifier
+	Enode string `json:"enode"` // Enode URL of the node
+	ENR   string `json:"enr"`   // Orange Node Record
+	IP    string `json:"ip"`    // IP address of the node
+	UDP   int    `json:"udp"`   // UDP port for discovery protocol
+	TCP   int    `json:"tcp"`   // TCP port for RLPx
+}
+
+// DiscoveryTableInfo represents the content of the discovery tables.
+type DiscoveryTableInfo struct {
+	V4 []*DiscoveredNodeInfo `json:"v4"` // Nodes in the discovery v4 table
+	V5 []*DiscoveredNodeInfo `json:"v5"` // Nodes in the discovery v5 table
+}
+
+// DiscoveryTable returns the nodes currently stored in the discovery tables.
+func (srv *Server) DiscoveryTable() *DiscoveryTableInfo {
+	srv.lock.Lock()
+	ntab, discv5 := srv.ntab, srv.DiscV5
+	srv.lock.Unlock()
+
+	info := &DiscoveryTableInfo{
+		V4: []*DiscoveredNodeInfo{},
+		V5: []*DiscoveredNodeInfo{},
+	}
+	if ntab != nil {
+		for _, n := range ntab.AllNodes() {
+			info.V4 = append(info.V4, newDiscoveredNodeInfo(n))
+		}
+	}
+	if discv5 != nil {
+		for _, n := range discv5.AllNodes() {
+			info.V5 = append(info.V5, newDiscoveredNodeInfo(n))
+		}
+	}
+	return info
+}
+
+// HandshakeFailures returns the most recent failed handshakes with remote nodes,
+// covering the RLPx and devp2p handshakes, the connection checks following them
+// (e.g. too many peers) and the sub-protocol handshakes (e.g. network ID, genesis
+// or fork ID mismatch). The most recently failing nodes are listed first.
+func (srv *Server) HandshakeFailures() []*HandshakeFailuresInfo {
+	return srv.hsFailures.list()
+}
+
+// newDiscoveredNodeInfo summarizes a discovered node.
+func newDiscoveredNodeInfo(n *enode.Node) *DiscoveredNodeInfo {
+	return &DiscoveredNodeInfo{
+		ID:    n.ID().String(),
+		Enode: n.URLv4(),
+		ENR:   n.String(),
+		IP:    n.IP().String(),
+		UDP:   n.UDP(),
+		TCP:   n.TCP(),
+	}
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
@@ -0,0 +1,163 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package protosim
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ong2020/go-orange/p2p"
+)
+
+// maxQueuedMsgs is the number of messages a link direction buffers while they
+// are in flight, before writers start to block.
+const maxQueuedMsgs = 1024
+
+// LinkConfig are the impairments applied to the messages sent over a link.
+type LinkConfig struct {
+	Latency    time.Duration // Fixed delay added to every message
+	Jitter     time.Duration // Maximum random delay added on top of the latency
+	PacketLoss float64       // Probability of a message being dropped, [0, 1]
+
+	// Lossy, if set, restricts the packet loss to the message codes for which
+	// it returns true (e.g. to keep protocol handshakes reliable).
+	Lossy func(code uint64) bool
+}
+
+// LinkStats are the message counters of one direction of a link.
+type LinkStats struct {
+	Sent      uint64 // Messages written by the sender
+	Dropped   uint64 // Messages dropped due to packet loss
+	Delivered uint64 // Messages handed over to the receiver
+}
+
+// queuedMsg is a message in flight, waiting for its delivery time.
+type queuedMsg struct {
+	code    uint64
+	payload []byte
+	due     time.Time
+}
+
+// linkEnd is one end of a simulated link, implementing p2p.MsgReadWriter. Reads
+// are served directly from the underlying pipe, whereas writes are subject to
+// the link impairments and delivered in order by a background forwarder.
+type linkEnd struct {
+	pipe   *p2p.MsgPipeRW
+	config LinkConfig
+
+	rand     *rand.Rand // Source of the loss and jitter decisions, seeded for reproducibility
+	randLock sync.Mutex
+
+	queue   chan *queuedMsg
+	closing chan struct{}
+	stats   LinkStats // Accessed atomically
+}
+
+// newLinkEnd wraps one end of a message pipe with the given impairments.
+func newLinkEnd(pipe *p2p.MsgPipeRW, config LinkConfig, seed int64, closing chan struct{}) *linkEnd {
+	end := &linkEnd{
+		pipe:    pipe,
+		config:  config,
+		rand:    rand.New(rand.NewSource(seed)),
+		queue:   make(chan *queuedMsg, maxQueuedMsgs),
+		closing: closing,
+	}
+	go end.forward()
+	return end
+}
+
+// ReadMsg returns the next message sent from the other end of the link.
+func (e *linkEnd) ReadMsg() (p2p.Msg, error) {
+	return e.pipe.ReadMsg()
+}
+
+// WriteMsg queues a message for delivery to the other end of the link, unless
+// it's lost according to the link's packet loss.
+func (e *linkEnd) WriteMsg(msg p2p.Msg) error {
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	atomic.AddUint64(&e.stats.Sent, 1)
+
+	e.randLock.Lock()
+	lost := e.config.PacketLoss > 0 && (e.config.Lossy == nil || e.config.Lossy(msg.Code)) && e.rand.Float64() < e.config.PacketLoss
+	delay := e.config.Latency
+	if e.config.Jitter > 0 {
+		delay += time.Duration(e.rand.Int63n(int64(e.config.Jitter)))
+	}
+	e.randLock.Unlock()
+
+	if lost {
+		atomic.AddUint64(&e.stats.Dropped, 1)
+		return nil
+	}
+	select {
+	case e.queue <- &queuedMsg{code: msg.Code, payload: payload, due: time.Now().Add(delay)}:
+		return nil
+	case <-e.closing:
+		return p2p.ErrPipeClosed
+	}
+}
+
+// forward delivers the queued messages to the other end of the link once their
+// delivery time arrives, preserving their order.
+func (e *linkEnd) forward() {
+	timer := time.NewTimer(0)
+	<-timer.C
+	defer timer.Stop()
+
+	for {
+		select {
+		case msg := <-e.queue:
+			if wait := time.Until(msg.due); wait > 0 {
+				timer.Reset(wait)
+				select {
+				case <-timer.C:
+				case <-e.closing:
+					return
+				}
+			}
+			err := e.pipe.WriteMsg(p2p.Msg{
+				Code:       msg.code,
+				Size:       uint32(len(msg.payload)),
+				Payload:    bytes.NewReader(msg.payload),
+				ReceivedAt: time.Now(),
+			})
+			if err != nil {
+				return
+			}
+			atomic.AddUint64(&e.stats.Delivered, 1)
+
+		case <-e.closing:
+			return
+		}
+	}
+}
+
+// Stats returns the message counters of the link direction written by this end.
+func (e *linkEnd) Stats() LinkStats {
+	return LinkStats{
+		Sent:      atomic.LoadUint64(&e.stats.Sent),
+		Dropped:   atomic.LoadUint64(&e.stats.Dropped),
+		Delivered: atomic.LoadUint64(&e.stats.Delivered),
+	}
+}
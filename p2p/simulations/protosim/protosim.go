@@ -0,0 +1,342 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package protosim builds in-memory networks of protocol handlers for testing.
+//
+// Nodes are anything exposing devp2p protocols (e.g. a full `ong.Orange` or a
+// light `les.LightOrange` service), and are connected to each other through
+// p2p.MsgPipe links with adjustable latency, jitter and packet loss. No real
+// networking, discovery or encryption is involved, which allows protocol level
+// changes to be integration tested across many nodes quickly and, given the
+// same seed, with reproducible packet loss.
+package protosim
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/p2p"
+	"github.com/ong2020/go-orange/p2p/enode"
+)
+
+var (
+	errNodeExists     = errors.New("node already exists")
+	errUnknownNode    = errors.New("unknown node")
+	errAlreadyLinked  = errors.New("nodes already connected")
+	errNotLinked      = errors.New("nodes not connected")
+	errSelfLink       = errors.New("cannot connect node to itself")
+	errNoCommonProtos = errors.New("no common protocols")
+	errShutdown       = errors.New("network shut down")
+)
+
+// Node is a participant of the simulated network, running a set of protocols.
+type Node interface {
+	Protocols() []p2p.Protocol
+}
+
+// Config contains the settings of a simulated network.
+type Config struct {
+	Seed        int64      // Seed of the link impairment decisions
+	DefaultLink LinkConfig // Impairments of links created via Connect
+}
+
+// simNode is a node registered in the simulated network.
+type simNode struct {
+	name string
+	id   enode.ID
+	node Node
+}
+
+// caps returns the capabilities advertised by the node.
+func (n *simNode) caps() []p2p.Cap {
+	var caps []p2p.Cap
+	for _, proto := range n.node.Protocols() {
+		caps = append(caps, p2p.Cap{Name: proto.Name, Version: proto.Version})
+	}
+	return caps
+}
+
+// linkKey identifies an undirected link between two nodes.
+type linkKey struct {
+	a, b string
+}
+
+// newLinkKey creates a link key independent of the order of the endpoints.
+func newLinkKey(a, b string) linkKey {
+	if a > b {
+		a, b = b, a
+	}
+	return linkKey{a, b}
+}
+
+// link is a live connection between two nodes, running all their common
+// protocols over separate message pipes.
+type link struct {
+	ends    map[string][]*linkEnd // Link ends written by each endpoint, per protocol
+	pipes   []*p2p.MsgPipeRW
+	closing chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// close tears down all the protocol pipes of the link.
+func (l *link) close() {
+	l.once.Do(func() {
+		close(l.closing)
+		for _, pipe := range l.pipes {
+			pipe.Close()
+		}
+	})
+}
+
+// Network is an in-memory network of nodes connected by simulated links.
+type Network struct {
+	config Config
+	nodes  map[string]*simNode
+	links  map[linkKey]*link
+	closed bool
+	lock   sync.Mutex
+}
+
+// New creates an empty simulated network.
+func New(config Config) *Network {
+	return &Network{
+		config: config,
+		nodes:  make(map[string]*simNode),
+		links:  make(map[linkKey]*link),
+	}
+}
+
+// AddNode registers a node in the network under the given unique name. The node
+// identifier is derived from the name.
+func (net *Network) AddNode(name string, node Node) error {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+
+	if net.closed {
+		return errShutdown
+	}
+	if _, ok := net.nodes[name]; ok {
+		return fmt.Errorf("%w: %s", errNodeExists, name)
+	}
+	net.nodes[name] = &simNode{
+		name: name,
+		id:   enode.ID(crypto.Keccak256Hash([]byte(name))),
+		node: node,
+	}
+	return nil
+}
+
+// NodeID returns the identifier of a node, as seen by its remote peers.
+func (net *Network) NodeID(name string) (enode.ID, error) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+
+	node, ok := net.nodes[name]
+	if !ok {
+		return enode.ID{}, fmt.Errorf("%w: %s", errUnknownNode, name)
+	}
+	return node.id, nil
+}
+
+// Nodes returns the names of all the nodes in the network, sorted.
+func (net *Network) Nodes() []string {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+
+	names := make([]string, 0, len(net.nodes))
+	for name := range net.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Connect links two nodes using the network's default link impairments.
+func (net *Network) Connect(a, b string) error {
+	return net.ConnectWith(a, b, net.config.DefaultLink)
+}
+
+// ConnectWith links two nodes using the given link impairments, starting all the
+// protocols they have in common (the highest shared version of each).
+func (net *Network) ConnectWith(a, b string, config LinkConfig) error {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+
+	if net.closed {
+		return errShutdown
+	}
+	if a == b {
+		return errSelfLink
+	}
+	nodeA, ok := net.nodes[a]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownNode, a)
+	}
+	nodeB, ok := net.nodes[b]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownNode, b)
+	}
+	key := newLinkKey(a, b)
+	if _, ok := net.links[key]; ok {
+		return errAlreadyLinked
+	}
+	protosA, protosB := matchProtocols(nodeA.node.Protocols(), nodeB.node.Protocols())
+	if len(protosA) == 0 {
+		return errNoCommonProtos
+	}
+	l := &link{
+		ends:    make(map[string][]*linkEnd),
+		closing: make(chan struct{}),
+	}
+	for i := range protosA {
+		pipeA, pipeB := p2p.MsgPipe()
+		l.pipes = append(l.pipes, pipeA)
+
+		endA := newLinkEnd(pipeA, config, net.linkSeed(a, b, i), l.closing)
+		endB := newLinkEnd(pipeB, config, net.linkSeed(b, a, i), l.closing)
+		l.ends[a] = append(l.ends[a], endA)
+		l.ends[b] = append(l.ends[b], endB)
+
+		l.wg.Add(2)
+		go net.run(l, protosA[i], nodeA, nodeB, endA)
+		go net.run(l, protosB[i], nodeB, nodeA, endB)
+	}
+	net.links[key] = l
+	return nil
+}
+
+// run executes a protocol of the local node against the remote one, tearing
+// down the entire link when the protocol terminates.
+func (net *Network) run(l *link, proto p2p.Protocol, local, remote *simNode, rw p2p.MsgReadWriter) {
+	defer l.wg.Done()
+
+	peer := p2p.NewPeer(remote.id, remote.name, remote.caps())
+	err := proto.Run(peer, rw)
+	log.Debug("Simulated protocol terminated", "local", local.name, "remote", remote.name, "proto", proto.Name, "err", err)
+
+	l.close()
+	net.lock.Lock()
+	if net.links[newLinkKey(local.name, remote.name)] == l {
+		delete(net.links, newLinkKey(local.name, remote.name))
+	}
+	net.lock.Unlock()
+}
+
+// Connected returns whether two nodes are linked.
+func (net *Network) Connected(a, b string) bool {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+
+	_, ok := net.links[newLinkKey(a, b)]
+	return ok
+}
+
+// Disconnect tears down the link between two nodes and waits for all of their
+// protocols to terminate.
+func (net *Network) Disconnect(a, b string) error {
+	net.lock.Lock()
+	l, ok := net.links[newLinkKey(a, b)]
+	if ok {
+		delete(net.links, newLinkKey(a, b))
+	}
+	net.lock.Unlock()
+
+	if !ok {
+		return errNotLinked
+	}
+	l.close()
+	l.wg.Wait()
+	return nil
+}
+
+// Stats returns the message counters of the link direction from one node to the
+// other, summed up across all protocols.
+func (net *Network) Stats(from, to string) (LinkStats, error) {
+	net.lock.Lock()
+	defer net.lock.Unlock()
+
+	l, ok := net.links[newLinkKey(from, to)]
+	if !ok {
+		return LinkStats{}, errNotLinked
+	}
+	var stats LinkStats
+	for _, end := range l.ends[from] {
+		s := end.Stats()
+		stats.Sent += s.Sent
+		stats.Dropped += s.Dropped
+		stats.Delivered += s.Delivered
+	}
+	return stats, nil
+}
+
+// Shutdown tears down all the links in the network and waits for the protocols
+// to terminate. The nodes themselves are not stopped.
+func (net *Network) Shutdown() {
+	net.lock.Lock()
+	net.closed = true
+	links := net.links
+	net.links = make(map[linkKey]*link)
+	net.lock.Unlock()
+
+	for _, l := range links {
+		l.close()
+	}
+	for _, l := range links {
+		l.wg.Wait()
+	}
+}
+
+// linkSeed derives the seed of one direction of a protocol link from the
+// network seed, so that impairments are reproducible across runs.
+func (net *Network) linkSeed(from, to string, proto int) int64 {
+	hasher := fnv.New64a()
+	fmt.Fprintf(hasher, "%s>%s/%d", from, to, proto)
+	return net.config.Seed ^ int64(hasher.Sum64())
+}
+
+// matchProtocols pairs up the protocols shared by two nodes, picking the highest
+// common version of each, in the same way devp2p negotiates capabilities.
+func matchProtocols(local, remote []p2p.Protocol) ([]p2p.Protocol, []p2p.Protocol) {
+	best := make(map[string][2]int)
+	for i, lp := range local {
+		for j, rp := range remote {
+			if lp.Name != rp.Name || lp.Version != rp.Version {
+				continue
+			}
+			if prev, ok := best[lp.Name]; !ok || local[prev[0]].Version < lp.Version {
+				best[lp.Name] = [2]int{i, j}
+			}
+		}
+	}
+	names := make([]string, 0, len(best))
+	for name := range best {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var matchedLocal, matchedRemote []p2p.Protocol
+	for _, name := range names {
+		matchedLocal = append(matchedLocal, local[best[name][0]])
+		matchedRemote = append(matchedRemote, remote[best[name][1]])
+	}
+	return matchedLocal, matchedRemote
+}
@@ -0,0 +1,192 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package protosim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/p2p"
+	"github.com/ong2020/go-orange/p2p/enode"
+)
+
+const (
+	pingMsg = 0x00
+	pongMsg = 0x01
+)
+
+// pingNode is a test node running a trivial protocol, answering every ping with
+// a pong and reporting all received pongs.
+type pingNode struct {
+	version uint
+	pongs   chan enode.ID
+}
+
+func newPingNode(version uint) *pingNode {
+	return &pingNode{version: version, pongs: make(chan enode.ID, 1024)}
+}
+
+func (n *pingNode) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    "ping",
+		Version: n.version,
+		Length:  2,
+		Run: func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+			for {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				msg.Discard()
+
+				switch msg.Code {
+				case pingMsg:
+					if err := p2p.Send(rw, pongMsg, []uint{}); err != nil {
+						return err
+					}
+				case pongMsg:
+					n.pongs <- peer.ID()
+				}
+			}
+		},
+	}}
+}
+
+// pinger is a test node sending a fixed number of pings on connection.
+type pinger struct {
+	*pingNode
+	pings int
+}
+
+func (n *pinger) Protocols() []p2p.Protocol {
+	protos := n.pingNode.Protocols()
+	run := protos[0].Run
+	protos[0].Run = func(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
+		go func() {
+			for i := 0; i < n.pings; i++ {
+				if p2p.Send(rw, pingMsg, []uint{}) != nil {
+					return
+				}
+			}
+		}()
+		return run(peer, rw)
+	}
+	return protos
+}
+
+// Tests that messages are exchanged between multiple connected nodes, with the
+// configured latency.
+func TestNetworkLatency(t *testing.T) {
+	net := New(Config{DefaultLink: LinkConfig{Latency: 50 * time.Millisecond}})
+	defer net.Shutdown()
+
+	source := &pinger{pingNode: newPingNode(1), pings: 1}
+	if err := net.AddNode("source", source); err != nil {
+		t.Fatalf("failed to add source: %v", err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := net.AddNode(name, newPingNode(1)); err != nil {
+			t.Fatalf("failed to add node %s: %v", name, err)
+		}
+	}
+	start := time.Now()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := net.Connect("source", name); err != nil {
+			t.Fatalf("failed to connect node %s: %v", name, err)
+		}
+	}
+	seen := make(map[enode.ID]bool)
+	for i := 0; i < 3; i++ {
+		select {
+		case id := <-source.pongs:
+			seen[id] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("pong %d timeout", i)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("round trip faster than link latency: %v", elapsed)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		id, _ := net.NodeID(name)
+		if !seen[id] {
+			t.Errorf("no pong from node %s", name)
+		}
+	}
+	if err := net.Disconnect("source", "a"); err != nil {
+		t.Fatalf("failed to disconnect: %v", err)
+	}
+	if net.Connected("source", "a") || !net.Connected("source", "b") {
+		t.Errorf("connectivity mismatch after disconnect")
+	}
+}
+
+// Tests that packet loss drops messages reproducibly for the same seed, and only
+// for the selected message types.
+func TestNetworkPacketLoss(t *testing.T) {
+	run := func(seed int64, lossy func(uint64) bool) LinkStats {
+		net := New(Config{Seed: seed})
+		defer net.Shutdown()
+
+		source := &pinger{pingNode: newPingNode(1), pings: 200}
+		net.AddNode("source", source)
+		net.AddNode("sink", newPingNode(1))
+
+		if err := net.ConnectWith("source", "sink", LinkConfig{PacketLoss: 0.5, Lossy: lossy}); err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		for {
+			stats, err := net.Stats("source", "sink")
+			if err != nil {
+				t.Fatalf("failed to retrieve stats: %v", err)
+			}
+			if stats.Dropped+stats.Delivered == 200 {
+				return stats
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	first, second := run(1, nil), run(1, nil)
+	if first != second {
+		t.Errorf("packet loss not reproducible: %+v != %+v", first, second)
+	}
+	if first.Dropped < 50 || first.Dropped > 150 {
+		t.Errorf("dropped message count out of range: %d", first.Dropped)
+	}
+	if stats := run(1, func(code uint64) bool { return code == pongMsg }); stats.Dropped != 0 {
+		t.Errorf("non-lossy messages dropped: %d", stats.Dropped)
+	}
+}
+
+// Tests that only the highest common protocol versions are run.
+func TestNetworkProtocolMatching(t *testing.T) {
+	net := New(Config{})
+	defer net.Shutdown()
+
+	net.AddNode("old", newPingNode(1))
+	net.AddNode("new", newPingNode(2))
+	if err := net.Connect("old", "new"); err != errNoCommonProtos {
+		t.Fatalf("connection error mismatch: have %v, want %v", err, errNoCommonProtos)
+	}
+	local := append(newPingNode(1).Protocols(), newPingNode(2).Protocols()...)
+	remote := append(newPingNode(2).Protocols(), newPingNode(1).Protocols()...)
+
+	matchedLocal, matchedRemote := matchProtocols(local, remote)
+	if len(matchedLocal) != 1 || matchedLocal[0].Version != 2 || matchedRemote[0].Version != 2 {
+		t.Fatalf("protocol matching mismatch: %v, %v", matchedLocal, matchedRemote)
+	}
+}
@@ -17,9 +17,13 @@
 package params
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"reflect"
 
 	"github.com/ong2020/go-orange/common"
 	"github.com/ong2020/go-orange/crypto"
@@ -229,16 +233,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllOngashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(OngashConfig), nil}
+	AllOngashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, new(OngashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Orange core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(OngashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, new(OngashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -289,6 +293,11 @@ type CheckpointOracleConfig struct {
 // ChainConfig is stored in the database on a per block basis. This means
 // that any network, identified by its genesis block, can have its own
 // set of configuration options.
+//
+// The protocol forks are activated at block numbers. The transitions specific
+// to private networks (the reward policy, the minimum gas price and the block
+// rules) are activated either at a block number or at a block timestamp, for
+// networks with irregular block times to schedule them by wall-clock time.
 type ChainConfig struct {
 	ChainID *big.Int `json:"chainId"` // chainId identifies the current chain and is used for replay protection
 
@@ -314,6 +323,15 @@ type ChainConfig struct {
 	YoloV3Block *big.Int `json:"yoloV3Block,omitempty"` // YOLO v3: Gas repricings TODO @holiman add EIP references
 	EWASMBlock  *big.Int `json:"ewasmBlock,omitempty"`  // EWASM switch block (nil = no fork, 0 = already activated)
 
+	// Non-standard payout scheme of the block rewards (private chains)
+	RewardPolicy *RewardPolicyConfig `json:"rewardPolicy,omitempty"`
+
+	// Protocol enforced floor of the transaction gas prices (private chains)
+	MinGasPrice *MinGasPriceConfig `json:"minGasPrice,omitempty"`
+
+	// Additional validation rules of the blocks (private chains)
+	BlockRules []BlockRuleConfig `json:"blockRules,omitempty"`
+
 	// Various consensus engines
 	Ongash *OngashConfig `json:"ongash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
@@ -338,6 +356,60 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// RewardPolicyConfig selects a non-standard payout scheme for the block rewards
+// of the block beneficiaries, e.g. to split them among several addresses or to
+// route them to a system contract on private chains. Uncle rewards are not
+// affected. The policy is activated either at a block number or at a block
+// timestamp, not both.
+//
+// Clique chains have no block rewards and use the beneficiary of the blocks for
+// voting, so they can't schedule a reward policy.
+type RewardPolicyConfig struct {
+	Block    *big.Int        `json:"block"`              // Activation block of the policy (nil = not scheduled by block)
+	Time     *uint64         `json:"time,omitempty"`     // Activation timestamp of the policy (nil = not scheduled by time)
+	Policy   string          `json:"policy"`             // Name of the payout policy, "split", "contract" or a registered one
+	Payees   []RewardPayee   `json:"payees,omitempty"`   // Recipients of the rewards, for the split policy
+	Contract *common.Address `json:"contract,omitempty"` // System contract receiving the rewards, for the contract policy
+}
+
+// ValidateRewardPolicy checks the reward policy scheduled by a chain
+// configuration, as part of CheckConfigForkOrder. The payout policies are
+// implemented by the consensus/misc package, which installs it.
+var ValidateRewardPolicy func(config *RewardPolicyConfig) error
+
+// MinGasPriceConfig enforces a minimum gas price on all the transactions from
+// its activation on, for private networks wanting a protocol level price floor.
+// Blocks including cheaper transactions are invalid. The floor is activated
+// either at a block number or at a block timestamp, not both.
+type MinGasPriceConfig struct {
+	Block *big.Int `json:"block"`          // Activation block of the floor (nil = not scheduled by block)
+	Time  *uint64  `json:"time,omitempty"` // Activation timestamp of the floor (nil = not scheduled by time)
+	Price *big.Int `json:"price"`          // Minimum gas price in wei
+}
+
+// BlockRuleConfig schedules an additional validation rule of the blocks, e.g. an
+// extra-data policy or a transaction type ban, for private networks enforcing
+// their own policies. Blocks breaking an active rule are invalid. The rule is
+// activated either at a block number or at a block timestamp, not both.
+type BlockRuleConfig struct {
+	Rule   string          `json:"rule"`             // Name of the rule, a built-in or a registered one
+	Block  *big.Int        `json:"block"`            // Activation block of the rule (nil = not scheduled by block)
+	Time   *uint64         `json:"time,omitempty"`   // Activation timestamp of the rule (nil = not scheduled by time)
+	Params json.RawMessage `json:"params,omitempty"` // Parameters of the rule
+}
+
+// IsActive returns whonger the rule applies to the block with the given number
+// and timestamp.
+func (r *BlockRuleConfig) IsActive(num *big.Int, time uint64) bool {
+	return isForked(r.Block, num) || isTimestampForked(r.Time, time)
+}
+
+// RewardPayee is a recipient of a share of the block rewards.
+type RewardPayee struct {
+	Address common.Address `json:"address"` // Account credited with the share
+	Weight  uint64         `json:"weight"`  // Share of the reward, relative to the other payees
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -430,20 +502,84 @@ func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
 }
 
-// CheckCompatible checks whonger scheduled fork transitions have been imported
-// with a mismatching chain configuration.
-func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
-	bhead := new(big.Int).SetUint64(height)
+// IsRewardPolicy returns whonger num is either equal to the reward policy
+// activation block or greater, or time is either equal to the reward policy
+// activation timestamp or greater.
+func (c *ChainConfig) IsRewardPolicy(num *big.Int, time uint64) bool {
+	return c.RewardPolicy != nil && (isForked(c.RewardPolicy.Block, num) || isTimestampForked(c.RewardPolicy.Time, time))
+}
+
+// IsMinGasPrice returns whonger num is either equal to the minimum gas price
+// activation block or greater, or time is either equal to the minimum gas price
+// activation timestamp or greater.
+func (c *ChainConfig) IsMinGasPrice(num *big.Int, time uint64) bool {
+	return c.MinGasPrice != nil && (isForked(c.MinGasPrice.Block, num) || isTimestampForked(c.MinGasPrice.Time, time))
+}
+
+// MinGasPriceAt returns the minimum gas price of the transactions included in
+// the block with the given number and timestamp, or nil if no floor is enforced.
+func (c *ChainConfig) MinGasPriceAt(num *big.Int, time uint64) *big.Int {
+	if !c.IsMinGasPrice(num, time) {
+		return nil
+	}
+	return c.MinGasPrice.Price
+}
+
+// minGasPriceBlock returns the activation block of the minimum gas price, if any.
+func (c *ChainConfig) minGasPriceBlock() *big.Int {
+	if c.MinGasPrice == nil {
+		return nil
+	}
+	return c.MinGasPrice.Block
+}
+
+// minGasPriceTime returns the activation timestamp of the minimum gas price, if
+// any.
+func (c *ChainConfig) minGasPriceTime() *uint64 {
+	if c.MinGasPrice == nil {
+		return nil
+	}
+	return c.MinGasPrice.Time
+}
+
+// rewardPolicyBlock returns the activation block of the reward policy, if any.
+func (c *ChainConfig) rewardPolicyBlock() *big.Int {
+	if c.RewardPolicy == nil {
+		return nil
+	}
+	return c.RewardPolicy.Block
+}
 
+// rewardPolicyTime returns the activation timestamp of the reward policy, if any.
+func (c *ChainConfig) rewardPolicyTime() *uint64 {
+	if c.RewardPolicy == nil {
+		return nil
+	}
+	return c.RewardPolicy.Time
+}
+
+// CheckCompatible checks whonger scheduled fork transitions have been imported
+// with a mismatching chain configuration. The head is given both by number and
+// timestamp, since the private network transitions may be scheduled by timestamp.
+func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64, time uint64) *ConfigCompatError {
+	var (
+		bhead = new(big.Int).SetUint64(height)
+		btime = time
+	)
 	// Iterate checkCompatible to find the lowest conflict.
 	var lasterr *ConfigCompatError
 	for {
-		err := c.checkCompatible(newcfg, bhead)
-		if err == nil || (lasterr != nil && err.RewindTo == lasterr.RewindTo) {
+		err := c.checkCompatible(newcfg, bhead, btime)
+		if err == nil || (lasterr != nil && err.RewindTo == lasterr.RewindTo && err.RewindToTime == lasterr.RewindToTime) {
 			break
 		}
 		lasterr = err
-		bhead.SetUint64(err.RewindTo)
+
+		if err.RewindToTime > 0 {
+			btime = err.RewindToTime
+		} else {
+			bhead.SetUint64(err.RewindTo)
+		}
 	}
 	return lasterr
 }
@@ -488,10 +624,42 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 			lastFork = cur
 		}
 	}
+	// The private network transitions are independent of the forks and of each
+	// other, but each one is scheduled either by block or by timestamp
+	type transition struct {
+		name  string
+		block *big.Int
+		time  *uint64
+	}
+	transitions := []transition{
+		{name: "reward policy", block: c.rewardPolicyBlock(), time: c.rewardPolicyTime()},
+		{name: "minimum gas price", block: c.minGasPriceBlock(), time: c.minGasPriceTime()},
+	}
+	for i, rule := range c.BlockRules {
+		transitions = append(transitions, transition{name: fmt.Sprintf("block rule %d (%s)", i, rule.Rule), block: rule.Block, time: rule.Time})
+	}
+	for _, cur := range transitions {
+		if cur.block != nil && cur.time != nil {
+			return fmt.Errorf("%s scheduled both at block %v and at timestamp %d", cur.name, cur.block, *cur.time)
+		}
+	}
+	if c.MinGasPrice != nil && (c.MinGasPrice.Block != nil || c.MinGasPrice.Time != nil) && (c.MinGasPrice.Price == nil || c.MinGasPrice.Price.Sign() <= 0) {
+		return errors.New("minimum gas price scheduled without a positive price")
+	}
+	if c.rewardPolicyBlock() != nil || c.rewardPolicyTime() != nil {
+		if c.Clique != nil {
+			return errors.New("reward policy scheduled on a clique chain, which has no block rewards")
+		}
+		if ValidateRewardPolicy != nil {
+			if err := ValidateRewardPolicy(c.RewardPolicy); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int, time uint64) *ConfigCompatError {
 	if isForkIncompatible(c.HomesteadBlock, newcfg.HomesteadBlock, head) {
 		return newCompatError("Homestead fork block", c.HomesteadBlock, newcfg.HomesteadBlock)
 	}
@@ -541,9 +709,81 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.rewardPolicyBlock(), newcfg.rewardPolicyBlock(), head) {
+		return newCompatError("reward policy block", c.rewardPolicyBlock(), newcfg.rewardPolicyBlock())
+	}
+	if isTimestampForkIncompatible(c.rewardPolicyTime(), newcfg.rewardPolicyTime(), time) {
+		return newTimestampCompatError("reward policy timestamp", c.rewardPolicyTime(), newcfg.rewardPolicyTime())
+	}
+	if c.IsRewardPolicy(head, time) && (c.RewardPolicy.Policy != newcfg.RewardPolicy.Policy || !reflect.DeepEqual(c.RewardPolicy.Payees, newcfg.RewardPolicy.Payees) || !reflect.DeepEqual(c.RewardPolicy.Contract, newcfg.RewardPolicy.Contract)) {
+		if isForked(c.RewardPolicy.Block, head) {
+			return newCompatError("reward policy", c.rewardPolicyBlock(), newcfg.rewardPolicyBlock())
+		}
+		return newTimestampCompatError("reward policy", c.rewardPolicyTime(), newcfg.rewardPolicyTime())
+	}
+	for i := 0; i < len(c.BlockRules) || i < len(newcfg.BlockRules); i++ {
+		var stored, next *BlockRuleConfig
+		if i < len(c.BlockRules) {
+			stored = &c.BlockRules[i]
+		}
+		if i < len(newcfg.BlockRules) {
+			next = &newcfg.BlockRules[i]
+		}
+		if blockRuleEqual(stored, next) {
+			continue
+		}
+		if stored != nil && stored.IsActive(head, time) || next != nil && next.IsActive(head, time) {
+			if blockRuleTime(stored) != nil || blockRuleTime(next) != nil {
+				return newTimestampCompatError("block rule", blockRuleTime(stored), blockRuleTime(next))
+			}
+			return newCompatError("block rule", blockRuleBlock(stored), blockRuleBlock(next))
+		}
+	}
+	if isForkIncompatible(c.minGasPriceBlock(), newcfg.minGasPriceBlock(), head) {
+		return newCompatError("minimum gas price block", c.minGasPriceBlock(), newcfg.minGasPriceBlock())
+	}
+	if isTimestampForkIncompatible(c.minGasPriceTime(), newcfg.minGasPriceTime(), time) {
+		return newTimestampCompatError("minimum gas price timestamp", c.minGasPriceTime(), newcfg.minGasPriceTime())
+	}
+	if c.IsMinGasPrice(head, time) && !configNumEqual(c.MinGasPrice.Price, newcfg.MinGasPrice.Price) {
+		if isForked(c.MinGasPrice.Block, head) {
+			return newCompatError("minimum gas price", c.minGasPriceBlock(), newcfg.minGasPriceBlock())
+		}
+		return newTimestampCompatError("minimum gas price", c.minGasPriceTime(), newcfg.minGasPriceTime())
+	}
 	return nil
 }
 
+// blockRuleEqual returns whonger two block rules, either possibly absent, are
+// the same. The parameters are compared regardless of their formatting.
+func blockRuleEqual(a, b *BlockRuleConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Rule != b.Rule || !configNumEqual(a.Block, b.Block) || !configTimestampEqual(a.Time, b.Time) {
+		return false
+	}
+	var pa, pb bytes.Buffer
+	json.Compact(&pa, a.Params)
+	json.Compact(&pb, b.Params)
+	return bytes.Equal(pa.Bytes(), pb.Bytes())
+}
+
+// blockRuleBlock returns the activation block of a possibly absent block rule.
+func blockRuleBlock(r *BlockRuleConfig) *big.Int {
+	if r == nil {
+		return nil
+	}
+	return r.Block
+}
+
+func blockRuleTime(r *BlockRuleConfig) *uint64 {
+	if r == nil {
+		return nil
+	}
+	return r.Time
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -568,14 +808,43 @@ func configNumEqual(x, y *big.Int) bool {
 	return x.Cmp(y) == 0
 }
 
+// isTimestampForkIncompatible returns true if a transition scheduled at timestamp
+// s1 cannot be rescheduled to timestamp s2 because head is already past it.
+func isTimestampForkIncompatible(s1, s2 *uint64, head uint64) bool {
+	return (isTimestampForked(s1, head) || isTimestampForked(s2, head)) && !configTimestampEqual(s1, s2)
+}
+
+// isTimestampForked returns whonger a transition scheduled at timestamp s is
+// active at the given head timestamp.
+func isTimestampForked(s *uint64, head uint64) bool {
+	if s == nil {
+		return false
+	}
+	return *s <= head
+}
+
+func configTimestampEqual(x, y *uint64) bool {
+	if x == nil {
+		return y == nil
+	}
+	if y == nil {
+		return x == nil
+	}
+	return *x == *y
+}
+
 // ConfigCompatError is raised if the locally-stored blockchain is initialised with a
 // ChainConfig that would alter the past.
 type ConfigCompatError struct {
 	What string
-	// block numbers of the stored and new configurations
+	// block numbers of the stored and new configurations, for forks scheduled by block
 	StoredConfig, NewConfig *big.Int
+	// timestamps of the stored and new configurations, for forks scheduled by time
+	StoredTime, NewTime *uint64
 	// the block number to which the local chain must be rewound to correct the error
 	RewindTo uint64
+	// the timestamp to which the local chain must be rewound to correct the error
+	RewindToTime uint64
 }
 
 func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatError {
@@ -588,17 +857,45 @@ func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatEr
 	default:
 		rew = newblock
 	}
-	err := &ConfigCompatError{what, storedblock, newblock, 0}
+	err := &ConfigCompatError{What: what, StoredConfig: storedblock, NewConfig: newblock}
 	if rew != nil && rew.Sign() > 0 {
 		err.RewindTo = rew.Uint64() - 1
 	}
 	return err
 }
 
+func newTimestampCompatError(what string, storedtime, newtime *uint64) *ConfigCompatError {
+	var rew *uint64
+	switch {
+	case storedtime == nil:
+		rew = newtime
+	case newtime == nil || *storedtime < *newtime:
+		rew = storedtime
+	default:
+		rew = newtime
+	}
+	err := &ConfigCompatError{What: what, StoredTime: storedtime, NewTime: newtime}
+	if rew != nil && *rew > 0 {
+		err.RewindToTime = *rew - 1
+	}
+	return err
+}
+
 func (err *ConfigCompatError) Error() string {
+	if err.StoredTime != nil || err.NewTime != nil {
+		return fmt.Sprintf("mismatching %s in database (have timestamp %s, want timestamp %s, rewindto timestamp %d)", err.What, timestampString(err.StoredTime), timestampString(err.NewTime), err.RewindToTime)
+	}
 	return fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
 }
 
+// timestampString formats an optional fork timestamp.
+func timestampString(time *uint64) string {
+	if time == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%d", *time)
+}
+
 // Rules wraps ChainConfig and is merely syntactic sugar or can be used for functions
 // that do not have or require information about the block.
 //
@@ -17,6 +17,7 @@
 package params
 
 import (
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
@@ -26,8 +27,12 @@ func TestCheckCompatible(t *testing.T) {
 	type test struct {
 		stored, new *ChainConfig
 		head        uint64
+		time        uint64
 		wantErr     *ConfigCompatError
 	}
+	var (
+		time10, time20 = uint64(10), uint64(20)
+	)
 	tests := []test{
 		{stored: AllOngashProtocolChanges, new: AllOngashProtocolChanges, head: 0, wantErr: nil},
 		{stored: AllOngashProtocolChanges, new: AllOngashProtocolChanges, head: 100, wantErr: nil},
@@ -87,12 +92,179 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{RewardPolicy: &RewardPolicyConfig{Time: &time10}},
+			new:     &ChainConfig{RewardPolicy: &RewardPolicyConfig{Time: &time20}},
+			head:    40,
+			time:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{RewardPolicy: &RewardPolicyConfig{Time: &time10}},
+			new:    &ChainConfig{RewardPolicy: &RewardPolicyConfig{Time: &time20}},
+			head:   40,
+			time:   15,
+			wantErr: &ConfigCompatError{
+				What:         "reward policy timestamp",
+				StoredTime:   &time10,
+				NewTime:      &time20,
+				RewindToTime: 9,
+			},
+		},
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{RewardPolicy: &RewardPolicyConfig{Time: &time10}},
+			head:   40,
+			time:   15,
+			wantErr: &ConfigCompatError{
+				What:         "reward policy timestamp",
+				NewTime:      &time10,
+				RewindToTime: 9,
+			},
+		},
+		{
+			stored: &ChainConfig{RewardPolicy: &RewardPolicyConfig{Time: &time10, Policy: "split"}},
+			new:    &ChainConfig{RewardPolicy: &RewardPolicyConfig{Time: &time10, Policy: "other"}},
+			head:   40,
+			time:   15,
+			wantErr: &ConfigCompatError{
+				What:         "reward policy",
+				StoredTime:   &time10,
+				NewTime:      &time10,
+				RewindToTime: 9,
+			},
+		},
+		{
+			stored:  &ChainConfig{MinGasPrice: &MinGasPriceConfig{Block: big.NewInt(10), Price: big.NewInt(1)}},
+			new:     &ChainConfig{MinGasPrice: &MinGasPriceConfig{Block: big.NewInt(10), Price: big.NewInt(2)}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{MinGasPrice: &MinGasPriceConfig{Block: big.NewInt(10), Price: big.NewInt(1)}},
+			new:    &ChainConfig{MinGasPrice: &MinGasPriceConfig{Block: big.NewInt(10), Price: big.NewInt(2)}},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "minimum gas price",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Block: big.NewInt(10), Params: json.RawMessage(`{"size": 1}`)}}},
+			new:     &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Block: big.NewInt(10), Params: json.RawMessage(`{"size":1}`)}}},
+			head:    20,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Block: big.NewInt(10), Params: json.RawMessage(`{"size": 1}`)}}},
+			new:    &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Block: big.NewInt(10), Params: json.RawMessage(`{"size": 2}`)}}},
+			head:   20,
+			wantErr: &ConfigCompatError{
+				What:         "block rule",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored:  &ChainConfig{},
+			new:     &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Block: big.NewInt(30)}}},
+			head:    20,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{MinGasPrice: &MinGasPriceConfig{Time: &time10, Price: big.NewInt(1)}},
+			new:    &ChainConfig{MinGasPrice: &MinGasPriceConfig{Time: &time20, Price: big.NewInt(1)}},
+			head:   40,
+			time:   15,
+			wantErr: &ConfigCompatError{
+				What:         "minimum gas price timestamp",
+				StoredTime:   &time10,
+				NewTime:      &time20,
+				RewindToTime: 9,
+			},
+		},
+		{
+			stored: &ChainConfig{MinGasPrice: &MinGasPriceConfig{Time: &time10, Price: big.NewInt(1)}},
+			new:    &ChainConfig{MinGasPrice: &MinGasPriceConfig{Time: &time10, Price: big.NewInt(2)}},
+			head:   40,
+			time:   15,
+			wantErr: &ConfigCompatError{
+				What:         "minimum gas price",
+				StoredTime:   &time10,
+				NewTime:      &time10,
+				RewindToTime: 9,
+			},
+		},
+		{
+			stored:  &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Time: &time20}}},
+			new:     &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Time: &time10}}},
+			head:    40,
+			time:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Time: &time20}}},
+			new:    &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Time: &time10}}},
+			head:   40,
+			time:   15,
+			wantErr: &ConfigCompatError{
+				What:         "block rule",
+				StoredTime:   &time20,
+				NewTime:      &time10,
+				RewindToTime: 9,
+			},
+		},
 	}
 
 	for _, test := range tests {
-		err := test.stored.CheckCompatible(test.new, test.head)
+		err := test.stored.CheckCompatible(test.new, test.head, test.time)
 		if !reflect.DeepEqual(err, test.wantErr) {
-			t.Errorf("error mismatch:\nstored: %v\nnew: %v\nhead: %v\nerr: %v\nwant: %v", test.stored, test.new, test.head, err, test.wantErr)
+			t.Errorf("error mismatch:\nstored: %v\nnew: %v\nhead: %v\ntime: %v\nerr: %v\nwant: %v", test.stored, test.new, test.head, test.time, err, test.wantErr)
+		}
+	}
+}
+
+func TestCheckConfigForkOrderTransitions(t *testing.T) {
+	time10 := uint64(10)
+	tests := []struct {
+		config *ChainConfig
+		fail   bool
+	}{
+		{config: &ChainConfig{RewardPolicy: &RewardPolicyConfig{Time: &time10}}},
+		{config: &ChainConfig{RewardPolicy: &RewardPolicyConfig{Block: big.NewInt(10), Time: &time10}}, fail: true},
+		{config: &ChainConfig{MinGasPrice: &MinGasPriceConfig{Time: &time10, Price: big.NewInt(1)}}},
+		{config: &ChainConfig{MinGasPrice: &MinGasPriceConfig{Block: big.NewInt(10), Time: &time10, Price: big.NewInt(1)}}, fail: true},
+		{config: &ChainConfig{MinGasPrice: &MinGasPriceConfig{Time: &time10}}, fail: true},
+		{config: &ChainConfig{MinGasPrice: &MinGasPriceConfig{Block: big.NewInt(10), Price: big.NewInt(0)}}, fail: true},
+		{config: &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Time: &time10}}}},
+		{config: &ChainConfig{BlockRules: []BlockRuleConfig{{Rule: "maxCalldata", Block: big.NewInt(10), Time: &time10}}}, fail: true},
+	}
+	for i, tt := range tests {
+		if err := tt.config.CheckConfigForkOrder(); (err != nil) != tt.fail {
+			t.Errorf("test %d: error mismatch: have %v, want failure %v", i, err, tt.fail)
 		}
 	}
 }
+
+func TestTimestampTransitions(t *testing.T) {
+	time10 := uint64(10)
+	config := &ChainConfig{
+		MinGasPrice: &MinGasPriceConfig{Time: &time10, Price: big.NewInt(7)},
+		BlockRules:  []BlockRuleConfig{{Rule: "maxCalldata", Time: &time10}},
+	}
+	if price := config.MinGasPriceAt(big.NewInt(100), 9); price != nil {
+		t.Errorf("minimum gas price active before its timestamp: %v", price)
+	}
+	if price := config.MinGasPriceAt(big.NewInt(0), 10); price == nil || price.Int64() != 7 {
+		t.Errorf("minimum gas price mismatch at its timestamp: have %v, want 7", price)
+	}
+	if config.BlockRules[0].IsActive(big.NewInt(100), 9) {
+		t.Error("block rule active before its timestamp")
+	}
+	if !config.BlockRules[0].IsActive(big.NewInt(0), 10) {
+		t.Error("block rule inactive at its timestamp")
+	}
+}
@@ -0,0 +1,524 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package relay implements a sponsored transaction relay: users sign
+// meta-transactions, which the node wraps into transactions signed and paid
+// for by a relayer account, so they can use the chain without holding funds.
+//
+// The relayed transactions call the target contract with the calldata of the
+// meta-transaction followed by the 20 bytes address of its sender, the
+// convention of the trusted forwarders of EIP-2771, so the contracts trusting
+// the relayer can act on behalf of the actual sender.
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ong2020/go-orange/accounts"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/node"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rlp"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// maxTracked is the number of relayed transactions whose outcome is remembered.
+const maxTracked = 4096
+
+var (
+	errExpired        = errors.New("meta-transaction expired")
+	errTargetDenied   = errors.New("target not allowed by the relay")
+	errGasTooHigh     = errors.New("gas exceeds the relay limit")
+	errSenderLimit    = errors.New("daily relay limit of the sender exceeded")
+	errRelayLimit     = errors.New("daily relay limit exceeded")
+	errInvalidSigLen  = fmt.Errorf("signature must be %d bytes long", crypto.SignatureLength)
+	errInvalidSigV    = errors.New("invalid Orange signature (V is not 27 or 28)")
+	errInvalidSigner  = errors.New("meta-transaction not signed by its sender")
+	errMissingTarget  = errors.New("meta-transaction without target")
+	errRelayerMissing = errors.New("relayer account not available")
+)
+
+// Config contains the settings of the transaction relay.
+type Config struct {
+	Enabled            bool             // Whether to serve the relay namespace
+	Relayer            common.Address   // Account signing and paying for the relayed transactions, must be unlocked
+	MaxGas             uint64           // Maximum gas of a relayed transaction
+	SenderDailyLimit   *big.Int         `toml:",omitempty"` // Maximum cost (wei) relayed per sender and day (nil = unlimited)
+	DailyLimit         *big.Int         `toml:",omitempty"` // Maximum cost (wei) relayed per day (nil = unlimited)
+	Targets            []common.Address `toml:",omitempty"` // Contracts transactions may be relayed to (empty = any)
+	ReimbursementTopic common.Hash      `toml:",omitempty"` // Topic of the events of the targets reimbursing the relayer
+}
+
+// DefaultConfig contains the default relay settings.
+var DefaultConfig = Config{
+	MaxGas: 500000,
+}
+
+// Backend is the chain access needed to relay transactions.
+type Backend interface {
+	ChainConfig() *params.ChainConfig
+	ChainDb() ongdb.Database
+	AccountManager() *accounts.Manager
+	SuggestPrice(ctx context.Context) (*big.Int, error)
+	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
+	SendTx(ctx context.Context, signedTx *types.Transaction) error
+	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
+	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
+}
+
+// MetaTransaction is a call signed by its sender, to be relayed in a transaction
+// of the relayer.
+type MetaTransaction struct {
+	From      common.Address `json:"from"`
+	To        common.Address `json:"to"`
+	Data      hexutil.Bytes  `json:"data"`
+	Gas       hexutil.Uint64 `json:"gas"`                 // Gas limit of the relayed transaction
+	Nonce     hexutil.Uint64 `json:"nonce"`               // Next relay nonce of the sender
+	Deadline  hexutil.Uint64 `json:"deadline"`            // Unix time after which the relay refuses it
+	Signature hexutil.Bytes  `json:"signature,omitempty"` // Signature of SigHash, in the [R || S || V] format with V 27 or 28
+}
+
+// SigHash returns the hash signed by the sender of the meta-transaction for the
+// given chain and relayer, to be signed as a text message (see accounts.TextHash)
+// e.g. with personal_sign.
+func (m *MetaTransaction) SigHash(chainID *big.Int, relayer common.Address) common.Hash {
+	enc, _ := rlp.EncodeToBytes([]interface{}{
+		chainID,
+		relayer,
+		m.From,
+		m.To,
+		[]byte(m.Data),
+		uint64(m.Gas),
+		uint64(m.Nonce),
+		uint64(m.Deadline),
+	})
+	return crypto.Keccak256Hash(enc)
+}
+
+// sender recovers the signer of the meta-transaction.
+func (m *MetaTransaction) sender(chainID *big.Int, relayer common.Address) (common.Address, error) {
+	if len(m.Signature) != crypto.SignatureLength {
+		return common.Address{}, errInvalidSigLen
+	}
+	sig := common.CopyBytes(m.Signature)
+	if sig[crypto.RecoveryIDOffset] != 27 && sig[crypto.RecoveryIDOffset] != 28 {
+		return common.Address{}, errInvalidSigV
+	}
+	sig[crypto.RecoveryIDOffset] -= 27 // Transform yellow paper V from 27/28 to 0/1
+
+	hash := m.SigHash(chainID, relayer)
+	pub, err := crypto.SigToPub(accounts.TextHash(hash[:]), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// RelayedTransaction is the record of a relayed meta-transaction.
+type RelayedTransaction struct {
+	Hash       common.Hash    `json:"hash"`                 // Hash of the relayed transaction
+	Sender     common.Address `json:"sender"`               // Sender of the meta-transaction
+	Target     common.Address `json:"target"`               // Contract called
+	Nonce      hexutil.Uint64 `json:"nonce"`                // Relay nonce of the meta-transaction
+	MaxCost    *hexutil.Big   `json:"maxCost"`              // Cost charged to the daily limits
+	Included   bool           `json:"included"`             // Whether the transaction made it into a block
+	Block      *hexutil.Big   `json:"block,omitempty"`      // Block including the transaction
+	Status     hexutil.Uint64 `json:"status"`               // Receipt status once included
+	Cost       *hexutil.Big   `json:"cost,omitempty"`       // Actual cost once included
+	Reimbursed *hexutil.Big   `json:"reimbursed,omitempty"` // Amount reimbursed by the target once included
+
+	gasPrice *big.Int // Gas price paid by the relayer
+}
+
+// Status is the state of the relay returned by relay_status.
+type Status struct {
+	Relayer          common.Address `json:"relayer"`
+	Day              string         `json:"day"`                        // UTC day the spending is accounted for
+	Spent            *hexutil.Big   `json:"spent"`                      // Cost relayed during the day
+	DailyLimit       *hexutil.Big   `json:"dailyLimit,omitempty"`       // Maximum cost relayed per day
+	SenderDailyLimit *hexutil.Big   `json:"senderDailyLimit,omitempty"` // Maximum cost relayed per sender and day
+	Pending          int            `json:"pending"`                    // Relayed transactions not yet included
+	Relayed          uint64         `json:"relayed"`                    // Transactions relayed since startup
+	Cost             *hexutil.Big   `json:"cost"`                       // Actual cost of the included transactions since startup
+	Reimbursed       *hexutil.Big   `json:"reimbursed"`                 // Amount reimbursed by the targets since startup
+}
+
+// spendingJournal is the daily spending of the relay persisted across restarts.
+type spendingJournal struct {
+	Day     int64                       `json:"day"`
+	Spent   *big.Int                    `json:"spent"`
+	Senders map[common.Address]*big.Int `json:"senders"`
+}
+
+// Service relays meta-transactions, tracking their inclusion and reimbursement.
+//
+// The daily spending checked against the limits is persisted in the database,
+// so restarting the node doesn't reset it. The outcome of the relayed
+// transactions and the totals reported by relay_status are only tracked since
+// startup.
+type Service struct {
+	config  Config
+	backend Backend
+	targets map[common.Address]bool
+
+	lock        sync.Mutex
+	day         int64                       // UTC day of the spending accounting (days since epoch)
+	spent       *big.Int                    // Cost relayed during the day
+	senderSpent map[common.Address]*big.Int // Cost relayed per sender during the day
+	txs         map[common.Hash]*RelayedTransaction
+	order       []common.Hash // Tracked transactions, oldest first
+	pending     int
+	relayed     uint64
+	cost        *big.Int
+	reimbursed  *big.Int
+
+	now  func() time.Time
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New registers the transaction relay and its relay namespace on the node. The
+// relayer account must be unlocked for the transactions to be signed.
+func New(stack *node.Node, backend Backend, config Config) error {
+	s := newService(backend, config)
+	stack.RegisterAPIs([]rpc.API{{
+		Namespace: "relay",
+		Version:   "1.0",
+		Service:   &API{s},
+		Public:    true,
+	}})
+	stack.RegisterLifecycle(s)
+	return nil
+}
+
+func newService(backend Backend, config Config) *Service {
+	s := &Service{
+		config:      config,
+		backend:     backend,
+		spent:       new(big.Int),
+		senderSpent: make(map[common.Address]*big.Int),
+		txs:         make(map[common.Hash]*RelayedTransaction),
+		cost:        new(big.Int),
+		reimbursed:  new(big.Int),
+		now:         time.Now,
+		quit:        make(chan struct{}),
+	}
+	if len(config.Targets) > 0 {
+		s.targets = make(map[common.Address]bool)
+		for _, target := range config.Targets {
+			s.targets[target] = true
+		}
+	}
+	if blob := rawdb.ReadRelaySpendingJournal(backend.ChainDb()); len(blob) > 0 {
+		var journal spendingJournal
+		if err := json.Unmarshal(blob, &journal); err != nil {
+			log.Warn("Failed to restore relay spending", "err", err)
+			return s
+		}
+		s.day = journal.Day
+		if journal.Spent != nil {
+			s.spent = journal.Spent
+		}
+		for sender, spent := range journal.Senders {
+			s.senderSpent[sender] = spent
+		}
+	}
+	return s
+}
+
+// Start implements node.Lifecycle, starting to track the relayed transactions.
+func (s *Service) Start() error {
+	s.wg.Add(1)
+	go s.loop()
+
+	log.Info("Transaction relay started", "relayer", s.config.Relayer)
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the tracking of the relayed
+// transactions.
+func (s *Service) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+
+	log.Info("Transaction relay stopped")
+	return nil
+}
+
+// relay validates a meta-transaction against the limits of the relay, and sends
+// it wrapped in a transaction of the relayer.
+func (s *Service) relay(ctx context.Context, meta *MetaTransaction) (common.Hash, error) {
+	chainID := s.backend.ChainConfig().ChainID
+
+	// Check the meta-transaction itself
+	if meta.To == (common.Address{}) {
+		return common.Hash{}, errMissingTarget
+	}
+	if s.targets != nil && !s.targets[meta.To] {
+		return common.Hash{}, errTargetDenied
+	}
+	if s.config.MaxGas > 0 && uint64(meta.Gas) > s.config.MaxGas {
+		return common.Hash{}, fmt.Errorf("%w: have %d, want at most %d", errGasTooHigh, meta.Gas, s.config.MaxGas)
+	}
+	if now := s.now(); uint64(meta.Deadline) < uint64(now.Unix()) {
+		return common.Hash{}, errExpired
+	}
+	sender, err := meta.sender(chainID, s.config.Relayer)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if sender != meta.From {
+		return common.Hash{}, errInvalidSigner
+	}
+	account := accounts.Account{Address: s.config.Relayer}
+	wallet, err := s.backend.AccountManager().Find(account)
+	if err != nil {
+		return common.Hash{}, errRelayerMissing
+	}
+	gasPrice, err := s.backend.SuggestPrice(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	cost := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(uint64(meta.Gas)))
+
+	// Relay the meta-transactions one at a time to keep nonces and limits sane
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	db := s.backend.ChainDb()
+	if next := rawdb.ReadRelayNonce(db, sender); uint64(meta.Nonce) != next {
+		return common.Hash{}, fmt.Errorf("invalid relay nonce: have %d, want %d", meta.Nonce, next)
+	}
+	s.rollDay()
+	senderSpent := s.senderSpent[sender]
+	if senderSpent == nil {
+		senderSpent = new(big.Int)
+	}
+	if limit := s.config.SenderDailyLimit; limit != nil && new(big.Int).Add(senderSpent, cost).Cmp(limit) > 0 {
+		return common.Hash{}, errSenderLimit
+	}
+	if limit := s.config.DailyLimit; limit != nil && new(big.Int).Add(s.spent, cost).Cmp(limit) > 0 {
+		return common.Hash{}, errRelayLimit
+	}
+	nonce, err := s.backend.GetPoolNonce(ctx, s.config.Relayer)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	data := make([]byte, 0, len(meta.Data)+common.AddressLength)
+	data = append(append(data, meta.Data...), sender.Bytes()...)
+
+	tx := types.NewTransaction(nonce, meta.To, new(big.Int), uint64(meta.Gas), gasPrice, data)
+	signed, err := wallet.SignTx(account, tx, chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := s.backend.SendTx(ctx, signed); err != nil {
+		return common.Hash{}, err
+	}
+	rawdb.WriteRelayNonce(db, sender, uint64(meta.Nonce)+1)
+
+	s.spent.Add(s.spent, cost)
+	s.senderSpent[sender] = senderSpent.Add(senderSpent, cost)
+	s.persist(db)
+	s.relayed++
+	s.track(&RelayedTransaction{
+		Hash:     signed.Hash(),
+		Sender:   sender,
+		Target:   meta.To,
+		Nonce:    meta.Nonce,
+		MaxCost:  (*hexutil.Big)(cost),
+		gasPrice: gasPrice,
+	})
+	log.Debug("Relayed meta-transaction", "sender", sender, "target", meta.To, "nonce", meta.Nonce, "hash", signed.Hash())
+	return signed.Hash(), nil
+}
+
+// rollDay resets the daily spending at the start of a new UTC day, returning
+// it as days since the epoch. The caller must hold the lock.
+func (s *Service) rollDay() int64 {
+	day := s.now().Unix() / 86400
+	if day != s.day {
+		s.day = day
+		s.spent = new(big.Int)
+		s.senderSpent = make(map[common.Address]*big.Int)
+	}
+	return day
+}
+
+// persist stores the daily spending in the database. The caller must hold the
+// lock.
+func (s *Service) persist(db ongdb.KeyValueWriter) {
+	blob, err := json.Marshal(&spendingJournal{Day: s.day, Spent: s.spent, Senders: s.senderSpent})
+	if err != nil {
+		log.Error("Failed to encode relay spending", "err", err)
+		return
+	}
+	rawdb.WriteRelaySpendingJournal(db, blob)
+}
+
+// track records a relayed transaction, forgetting the oldest one if too many
+// are tracked. The caller must hold the lock.
+func (s *Service) track(tx *RelayedTransaction) {
+	if len(s.order) >= maxTracked {
+		if old := s.txs[s.order[0]]; old != nil && !old.Included {
+			s.pending--
+		}
+		delete(s.txs, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.txs[tx.Hash] = tx
+	s.order = append(s.order, tx.Hash)
+	s.pending++
+}
+
+// loop tracks the inclusion of the relayed transactions in the chain.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	events := make(chan core.ChainEvent, 16)
+	sub := s.backend.SubscribeChainEvent(events)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-events:
+			s.process(ev.Block)
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// process records the outcome of the relayed transactions included in a block:
+// their actual cost and the reimbursements of their targets.
+func (s *Service) process(block *types.Block) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pending == 0 {
+		return
+	}
+	var receipts types.Receipts
+	for i, tx := range block.Transactions() {
+		relayed := s.txs[tx.Hash()]
+		if relayed == nil || relayed.Included {
+			continue
+		}
+		if receipts == nil {
+			var err error
+			if receipts, err = s.backend.GetReceipts(context.Background(), block.Hash()); err != nil || len(receipts) != len(block.Transactions()) {
+				log.Warn("Failed to retrieve relayed transaction receipts", "block", block.Number(), "err", err)
+				return
+			}
+		}
+		receipt := receipts[i]
+
+		relayed.Included = true
+		relayed.Block = (*hexutil.Big)(block.Number())
+		relayed.Status = hexutil.Uint64(receipt.Status)
+		cost := new(big.Int).Mul(relayed.gasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+		relayed.Cost = (*hexutil.Big)(cost)
+		s.cost.Add(s.cost, cost)
+		s.pending--
+
+		reimbursed := new(big.Int)
+		if s.config.ReimbursementTopic != (common.Hash{}) {
+			for _, l := range receipt.Logs {
+				if l.Address == relayed.Target && len(l.Topics) > 0 && l.Topics[0] == s.config.ReimbursementTopic && len(l.Data) >= 32 {
+					reimbursed.Add(reimbursed, new(big.Int).SetBytes(l.Data[:32]))
+				}
+			}
+		}
+		relayed.Reimbursed = (*hexutil.Big)(reimbursed)
+		s.reimbursed.Add(s.reimbursed, reimbursed)
+	}
+}
+
+// status reports the state of the relay.
+func (s *Service) status() *Status {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	day := s.rollDay()
+	return &Status{
+		Relayer:          s.config.Relayer,
+		Day:              time.Unix(day*86400, 0).UTC().Format("2006-01-02"),
+		Spent:            (*hexutil.Big)(new(big.Int).Set(s.spent)),
+		DailyLimit:       (*hexutil.Big)(s.config.DailyLimit),
+		SenderDailyLimit: (*hexutil.Big)(s.config.SenderDailyLimit),
+		Pending:          s.pending,
+		Relayed:          s.relayed,
+		Cost:             (*hexutil.Big)(new(big.Int).Set(s.cost)),
+		Reimbursed:       (*hexutil.Big)(new(big.Int).Set(s.reimbursed)),
+	}
+}
+
+// API is the relay namespace of the transaction relay.
+type API struct {
+	s *Service
+}
+
+// SendMetaTransaction relays a signed meta-transaction, returning the hash of
+// the transaction of the relayer wrapping it.
+func (api *API) SendMetaTransaction(ctx context.Context, meta MetaTransaction) (common.Hash, error) {
+	return api.s.relay(ctx, &meta)
+}
+
+// MetaTransactionHash returns the hash the sender of a meta-transaction must sign
+// as a text message (e.g. with personal_sign) for it to be relayed.
+func (api *API) MetaTransactionHash(meta MetaTransaction) common.Hash {
+	return meta.SigHash(api.s.backend.ChainConfig().ChainID, api.s.config.Relayer)
+}
+
+// Nonce returns the next relay nonce of a sender.
+func (api *API) Nonce(sender common.Address) hexutil.Uint64 {
+	return hexutil.Uint64(rawdb.ReadRelayNonce(api.s.backend.ChainDb(), sender))
+}
+
+// Transaction returns the record of a relayed transaction, or nil if unknown.
+func (api *API) Transaction(hash common.Hash) *RelayedTransaction {
+	api.s.lock.Lock()
+	defer api.s.lock.Unlock()
+
+	tx := api.s.txs[hash]
+	if tx == nil {
+		return nil
+	}
+	cpy := *tx
+	return &cpy
+}
+
+// Status reports the relayer, its spending and the outcome of the relayed
+// transactions.
+func (api *API) Status() *Status {
+	return api.s.status()
+}
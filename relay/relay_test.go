@@ -0,0 +1,226 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ong2020/go-orange/accounts"
+	"github.com/ong2020/go-orange/accounts/keystore"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/trie"
+)
+
+var (
+	testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr   = crypto.PubkeyToAddress(testKey.PublicKey)
+)
+
+type testBackend struct {
+	db       ongdb.Database
+	am       *accounts.Manager
+	sent     []*types.Transaction
+	receipts map[common.Hash]types.Receipts
+	feed     event.Feed
+}
+
+func (b *testBackend) ChainConfig() *params.ChainConfig  { return params.TestChainConfig }
+func (b *testBackend) ChainDb() ongdb.Database           { return b.db }
+func (b *testBackend) AccountManager() *accounts.Manager { return b.am }
+
+func (b *testBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(10), nil
+}
+
+func (b *testBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return uint64(len(b.sent)), nil
+}
+
+func (b *testBackend) SendTx(ctx context.Context, tx *types.Transaction) error {
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.receipts[hash], nil
+}
+
+func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
+	return b.feed.Subscribe(ch)
+}
+
+func newTestBackend(t *testing.T) (*testBackend, common.Address) {
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	relayer, err := ks.NewAccount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Unlock(relayer, ""); err != nil {
+		t.Fatal(err)
+	}
+	backend := &testBackend{
+		db:       rawdb.NewMemoryDatabase(),
+		am:       accounts.NewManager(&accounts.Config{}, ks),
+		receipts: make(map[common.Hash]types.Receipts),
+	}
+	return backend, relayer.Address
+}
+
+// signMeta signs a meta-transaction as done by personal_sign.
+func signMeta(t *testing.T, meta *MetaTransaction, relayer common.Address) {
+	meta.From = testAddr
+
+	hash := meta.SigHash(params.TestChainConfig.ChainID, relayer)
+	sig, err := crypto.Sign(accounts.TextHash(hash[:]), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	meta.Signature = sig
+}
+
+// Tests that meta-transactions are relayed in transactions of the relayer within
+// the relay limits.
+func TestRelay(t *testing.T) {
+	backend, relayer := newTestBackend(t)
+	target := common.HexToAddress("0x1000")
+
+	s := newService(backend, Config{
+		Relayer:          relayer,
+		MaxGas:           100000,
+		SenderDailyLimit: big.NewInt(1500000), // 1.5 transactions of 100000 gas at 10 wei
+		Targets:          []common.Address{target},
+	})
+	deadline := uint64(time.Now().Add(time.Hour).Unix())
+
+	meta := &MetaTransaction{To: target, Data: []byte{0x01, 0x02}, Gas: 100000, Deadline: hexutil.Uint64(deadline)}
+	signMeta(t, meta, relayer)
+	hash, err := s.relay(context.Background(), meta)
+	if err != nil {
+		t.Fatalf("failed to relay meta-transaction: %v", err)
+	}
+	if len(backend.sent) != 1 || backend.sent[0].Hash() != hash {
+		t.Fatalf("relayed transaction not sent")
+	}
+	tx := backend.sent[0]
+	if from, _ := types.Sender(types.LatestSignerForChainID(params.TestChainConfig.ChainID), tx); from != relayer {
+		t.Errorf("relayed transaction signer mismatch: have %x, want %x", from, relayer)
+	}
+	if want := append([]byte{0x01, 0x02}, meta.From.Bytes()...); !bytes.Equal(tx.Data(), want) {
+		t.Errorf("relayed calldata mismatch: have %x, want %x", tx.Data(), want)
+	}
+	if nonce := rawdb.ReadRelayNonce(backend.db, meta.From); nonce != 1 {
+		t.Errorf("relay nonce mismatch: have %d, want 1", nonce)
+	}
+	// Replays, bad signatures and requests over the limits are refused
+	if _, err := s.relay(context.Background(), meta); err == nil {
+		t.Errorf("replayed meta-transaction relayed")
+	}
+	tests := []struct {
+		meta MetaTransaction
+		err  error
+	}{
+		{MetaTransaction{To: common.HexToAddress("0x2000"), Gas: 21000, Nonce: 1, Deadline: hexutil.Uint64(deadline)}, errTargetDenied},
+		{MetaTransaction{To: target, Gas: 200000, Nonce: 1, Deadline: hexutil.Uint64(deadline)}, errGasTooHigh},
+		{MetaTransaction{To: target, Gas: 21000, Nonce: 1, Deadline: 1}, errExpired},
+		{MetaTransaction{To: target, Gas: 100000, Nonce: 1, Deadline: hexutil.Uint64(deadline)}, errSenderLimit},
+	}
+	for i, tt := range tests {
+		signMeta(t, &tt.meta, relayer)
+		if _, err := s.relay(context.Background(), &tt.meta); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	forged := &MetaTransaction{To: target, Gas: 21000, Nonce: 1, Deadline: hexutil.Uint64(deadline)}
+	signMeta(t, forged, relayer)
+	forged.From = common.HexToAddress("0x3000")
+	if _, err := s.relay(context.Background(), forged); !errors.Is(err, errInvalidSigner) {
+		t.Errorf("forged error mismatch: have %v, want %v", err, errInvalidSigner)
+	}
+	// The daily spending survives a restart
+	restarted := newService(backend, s.config)
+	meta = &MetaTransaction{To: target, Gas: 100000, Nonce: 1, Deadline: hexutil.Uint64(deadline)}
+	signMeta(t, meta, relayer)
+	if _, err := restarted.relay(context.Background(), meta); !errors.Is(err, errSenderLimit) {
+		t.Errorf("restarted error mismatch: have %v, want %v", err, errSenderLimit)
+	}
+	if spent := restarted.status().Spent.ToInt(); spent.Cmp(big.NewInt(1000000)) != 0 {
+		t.Errorf("restarted spending mismatch: have %v, want %v", spent, 1000000)
+	}
+	// The daily limits are reset the next day
+	s.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	meta = &MetaTransaction{To: target, Gas: 100000, Nonce: 1, Deadline: hexutil.Uint64(deadline + 86400)}
+	signMeta(t, meta, relayer)
+	if _, err := s.relay(context.Background(), meta); err != nil {
+		t.Errorf("failed to relay meta-transaction the next day: %v", err)
+	}
+}
+
+// Tests that the inclusion and reimbursement of the relayed transactions are
+// tracked.
+func TestRelayTracking(t *testing.T) {
+	backend, relayer := newTestBackend(t)
+	var (
+		target = common.HexToAddress("0x1000")
+		topic  = common.HexToHash("0x01")
+	)
+	s := newService(backend, Config{Relayer: relayer, ReimbursementTopic: topic})
+
+	meta := &MetaTransaction{To: target, Gas: 50000, Deadline: hexutil.Uint64(uint64(time.Now().Add(time.Hour).Unix()))}
+	signMeta(t, meta, relayer)
+	hash, err := s.relay(context.Background(), meta)
+	if err != nil {
+		t.Fatalf("failed to relay meta-transaction: %v", err)
+	}
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, backend.sent, nil, nil, trie.NewStackTrie(nil))
+	backend.receipts[block.Hash()] = types.Receipts{{
+		Status:  types.ReceiptStatusSuccessful,
+		GasUsed: 30000,
+		Logs: []*types.Log{
+			{Address: target, Topics: []common.Hash{topic}, Data: common.LeftPadBytes([]byte{0x64}, 32)},
+			{Address: common.HexToAddress("0x2000"), Topics: []common.Hash{topic}, Data: common.LeftPadBytes([]byte{0x64}, 32)},
+		},
+	}}
+	s.process(block)
+
+	tx := (&API{s}).Transaction(hash)
+	if tx == nil || !tx.Included {
+		t.Fatalf("relayed transaction not included: %+v", tx)
+	}
+	if cost := tx.Cost.ToInt(); cost.Cmp(big.NewInt(300000)) != 0 {
+		t.Errorf("cost mismatch: have %v, want %v", cost, 300000)
+	}
+	if reimbursed := tx.Reimbursed.ToInt(); reimbursed.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("reimbursement mismatch: have %v, want %v", reimbursed, 100)
+	}
+	if status := s.status(); status.Pending != 0 || status.Relayed != 1 || status.Reimbursed.ToInt().Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("status mismatch: %+v", status)
+	}
+}
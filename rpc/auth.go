@@ -0,0 +1,240 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// jwtClockSkew is the tolerated clock difference between the token issuer
+	// and the server when checking the timestamps of a token.
+	jwtClockSkew = 5 * time.Second
+
+	// apiKeyHeader is the HTTP header carrying API keys.
+	apiKeyHeader = "X-API-Key"
+)
+
+// Authenticator verifies the credentials of the HTTP and WebSocket requests
+// received by a server, before any method is dispatched.
+type Authenticator interface {
+	// Authenticate returns the identity of the client sending the request. It
+	// returns an empty identity if the request carries no credentials handled by
+	// the authenticator, and an error if it carries invalid ones, in which case the
+	// request is rejected.
+	Authenticate(r *http.Request) (string, error)
+}
+
+// AuthenticatorFunc is an adapter allowing the use of ordinary functions as
+// request authenticators.
+type AuthenticatorFunc func(r *http.Request) (string, error)
+
+// Authenticate calls f(r).
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// SetAuthenticator installs an authenticator checking the credentials of the
+// HTTP and WebSocket requests served by the server. Requests with invalid
+// credentials are rejected, while requests without credentials may only call
+// the methods of the namespaces not listed as protected. Requests served over
+// other transports (IPC, in-process) are not authenticated.
+//
+// This method should be called before processing any requests via ServeHTTP or
+// WebsocketHandler.
+func (s *Server) SetAuthenticator(auth Authenticator, protected []string) {
+	s.auth = auth
+	s.services.protect(protected)
+}
+
+// authenticate checks the credentials of a request, if the server requires so.
+func (s *Server) authenticate(r *http.Request) (string, error) {
+	if s.auth == nil {
+		return "", nil
+	}
+	return s.auth.Authenticate(r)
+}
+
+type authContextKey struct{}
+
+// AuthIdentity returns the identity of the authenticated client of a request,
+// or an empty string if the client did not authenticate.
+func AuthIdentity(ctx context.Context) string {
+	identity, _ := ctx.Value(authContextKey{}).(string)
+	return identity
+}
+
+// unauthorizedError is returned for calls to protected namespaces made without
+// credentials.
+type unauthorizedError struct{ namespace string }
+
+func (e *unauthorizedError) ErrorCode() int { return errcodeUnauthorized }
+
+func (e *unauthorizedError) Error() string {
+	return fmt.Sprintf("authentication required for the %s namespace", e.namespace)
+}
+
+// ChainAuthenticators combines several authenticators, each handling its own
+// kind of credentials. The identity returned by the first authenticator
+// recognising the credentials of the request is used.
+func ChainAuthenticators(auths ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (string, error) {
+		for _, auth := range auths {
+			identity, err := auth.Authenticate(r)
+			if err != nil || identity != "" {
+				return identity, err
+			}
+		}
+		return "", nil
+	})
+}
+
+// NewAPIKeyAuthenticator creates an authenticator checking the API key sent in
+// the X-API-Key header against the given keys, mapped to the identities of
+// their holders.
+func NewAPIKeyAuthenticator(keys map[string]string) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (string, error) {
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" {
+			return "", nil
+		}
+		// Compare against every key in constant time to not leak the valid ones
+		var identity string
+		for k, id := range keys {
+			if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+				identity = id
+			}
+		}
+		if identity == "" {
+			return "", errors.New("invalid API key")
+		}
+		return identity, nil
+	})
+}
+
+// NewClientCertAuthenticator creates an authenticator identifying the clients by
+// the TLS certificate they present, verified against the client CAs of the
+// server during the handshake. The identity is the common name of the subject
+// of the certificate.
+func NewClientCertAuthenticator() Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (string, error) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return "", nil
+		}
+		subject := r.TLS.VerifiedChains[0][0].Subject
+		if subject.CommonName != "" {
+			return subject.CommonName, nil
+		}
+		return subject.String(), nil
+	})
+}
+
+// jwtClaims are the registered claims of a JSON web token checked by the
+// authenticator.
+type jwtClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+	IssuedAt  *int64 `json:"iat"`
+}
+
+// NewJWTAuthenticator creates an authenticator checking the JSON web tokens sent
+// as bearer tokens in the Authorization header. Tokens must be signed with
+// HMAC-SHA256 (HS256) using the given secret, and their timestamp claims, if
+// any, must be valid. The subject claim of a token is used as the identity of
+// its holder.
+func NewJWTAuthenticator(secret []byte) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request) (string, error) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			return "", nil
+		}
+		if !strings.HasPrefix(header, "Bearer ") {
+			return "", errors.New("unsupported authorization scheme")
+		}
+		claims, err := verifyJWT(strings.TrimPrefix(header, "Bearer "), secret, time.Now())
+		if err != nil {
+			return "", err
+		}
+		if claims.Subject == "" {
+			return "jwt", nil
+		}
+		return claims.Subject, nil
+	})
+}
+
+// verifyJWT checks the signature and the timestamps of an HS256 JSON web token,
+// returning its claims.
+func verifyJWT(token string, secret []byte, now time.Time) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %v", err)
+	}
+	if header.Alg != "HS256" {
+		return nil, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %v", err)
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("invalid token signature")
+	}
+	claims := new(jwtClaims)
+	if err := decodeJWTSegment(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %v", err)
+	}
+	unix := now.Unix()
+	skew := int64(jwtClockSkew / time.Second)
+	if claims.ExpiresAt != nil && unix > *claims.ExpiresAt+skew {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && unix < *claims.NotBefore-skew {
+		return nil, errors.New("token not valid yet")
+	}
+	if claims.IssuedAt != nil && unix < *claims.IssuedAt-skew {
+		return nil, errors.New("token issued in the future")
+	}
+	return claims, nil
+}
+
+// decodeJWTSegment decodes a base64url encoded JSON segment of a token.
+func decodeJWTSegment(segment string, v interface{}) error {
+	blob, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(blob, v)
+}
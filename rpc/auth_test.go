@@ -0,0 +1,115 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signJWT creates an HS256 token with the given claims.
+func signJWT(claims string, secret []byte) string {
+	var (
+		header  = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+		payload = base64.RawURLEncoding.EncodeToString([]byte(claims))
+		mac     = hmac.New(sha256.New, secret)
+	)
+	mac.Write([]byte(header + "." + payload))
+	return header + "." + payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	var (
+		secret = []byte("secret")
+		now    = time.Unix(1000, 0)
+	)
+	tests := []struct {
+		token string
+		fail  bool
+	}{
+		{signJWT(`{"sub":"alice"}`, secret), false},
+		{signJWT(`{"sub":"alice","exp":1100,"iat":990}`, secret), false},
+		{signJWT(`{"sub":"alice","exp":900}`, secret), true},
+		{signJWT(`{"sub":"alice","nbf":1100}`, secret), true},
+		{signJWT(`{"sub":"alice","iat":1100}`, secret), true},
+		{signJWT(`{"sub":"alice"}`, []byte("other")), true},
+		{"not.a.token", true},
+		{"garbage", true},
+	}
+	for i, tt := range tests {
+		claims, err := verifyJWT(tt.token, secret, now)
+		if (err != nil) != tt.fail {
+			t.Errorf("test %d: error mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+		if err == nil && claims.Subject != "alice" {
+			t.Errorf("test %d: subject mismatch: have %q, want %q", i, claims.Subject, "alice")
+		}
+	}
+}
+
+// Tests that protected namespaces can only be called by authenticated clients.
+func TestServerAuthentication(t *testing.T) {
+	server := newTestServer()
+	server.SetAuthenticator(ChainAuthenticators(
+		NewAPIKeyAuthenticator(map[string]string{"key": "alice"}),
+		NewJWTAuthenticator([]byte("secret")),
+	), []string{"test"})
+	defer server.Stop()
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	tests := []struct {
+		header, value string
+		err           string
+	}{
+		{"", "", "authentication required"},
+		{"X-API-Key", "key", ""},
+		{"X-API-Key", "wrong", "invalid API key"},
+		{"Authorization", "Bearer " + signJWT(`{"sub":"bob"}`, []byte("secret")), ""},
+		{"Authorization", "Bearer " + signJWT(`{"sub":"bob"}`, []byte("wrong")), "invalid token signature"},
+	}
+	for i, tt := range tests {
+		client, err := DialHTTP(httpsrv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.header != "" {
+			client.SetHeader(tt.header, tt.value)
+		}
+		var resp echoResult
+		err = client.Call(&resp, "test_echo", "hello", 10, &echoArgs{"world"})
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("test %d: call failed: %v", i, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+		// Unprotected namespaces are open to anonymous clients
+		if tt.header == "" {
+			if _, err := client.SupportedModules(); err != nil {
+				t.Errorf("test %d: unprotected call failed: %v", i, err)
+			}
+		}
+		client.Close()
+	}
+}
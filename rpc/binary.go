@@ -0,0 +1,545 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Content types of the binary encodings.
+const (
+	cborContentType    = "application/cbor"
+	msgpackContentType = "application/msgpack"
+)
+
+// binaryMaxDepth is the maximum nesting depth of the decoded values.
+const binaryMaxDepth = 512
+
+var (
+	errBinaryDepth    = errors.New("binary value nested too deeply")
+	errBinaryMapKey   = errors.New("unsupported binary map key")
+	errBinaryNotFinit = errors.New("non-finite number")
+	errInvalidJSON    = errors.New("invalid JSON value")
+)
+
+// binaryFormat is a binary encoding of the JSON data model. The JSON-RPC messages
+// are carried in binary form on the wire, which is more compact and faster to
+// parse than text, while the server processes them as JSON like any other.
+//
+// Encoded JSON numbers are integers if they fit 64 bits, floats otherwise. Byte
+// strings are decoded as 0x-prefixed hex strings, the way the APIs encode binary
+// data, and integer map keys as their decimal string.
+type binaryFormat struct {
+	contentType string
+	writer      binaryWriter
+	read        func(r *bufio.Reader, out *bytes.Buffer, depth int) error
+}
+
+// binaryWriter writes the items of the JSON data model in a binary encoding.
+// Arrays and maps are written as a head giving their length, followed by their
+// items, or their keys and values.
+type binaryWriter interface {
+	writeNull(buf *bytes.Buffer)
+	writeBool(buf *bytes.Buffer, v bool)
+	writeInt(buf *bytes.Buffer, n int64)
+	writeUint(buf *bytes.Buffer, n uint64)
+	writeFloat(buf *bytes.Buffer, f float64)
+	writeString(buf *bytes.Buffer, s []byte)
+	writeArrayHead(buf *bytes.Buffer, n int)
+	writeMapHead(buf *bytes.Buffer, n int)
+}
+
+var (
+	cborFormat    = &binaryFormat{cborContentType, cborWriter{}, readCBOR}
+	msgpackFormat = &binaryFormat{msgpackContentType, msgpackWriter{}, readMsgPack}
+)
+
+// binaryFormats are the binary encodings by content type.
+var binaryFormats = map[string]*binaryFormat{
+	cborContentType:           cborFormat,
+	msgpackContentType:        msgpackFormat,
+	"application/x-msgpack":   msgpackFormat,
+	"application/vnd.msgpack": msgpackFormat,
+}
+
+// formatOf returns the binary encoding of a content type, nil for JSON.
+func formatOf(ctype string) *binaryFormat {
+	mt, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return nil
+	}
+	return binaryFormats[mt]
+}
+
+// fromJSON encodes a JSON value.
+func (f *binaryFormat) fromJSON(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := f.transcode(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encode encodes a message, a batch of messages or a raw JSON value. Messages are
+// written field by field rather than marshalled as JSON first, and the raw JSON
+// members are transcoded without being decoded.
+func (f *binaryFormat) encode(v interface{}) ([]byte, error) {
+	var (
+		buf = new(bytes.Buffer)
+		err error
+	)
+	switch v := v.(type) {
+	case *jsonrpcMessage:
+		err = f.writeMessage(buf, v)
+	case []*jsonrpcMessage:
+		f.writer.writeArrayHead(buf, len(v))
+		for _, msg := range v {
+			if err = f.writeMessage(buf, msg); err != nil {
+				break
+			}
+		}
+	case json.RawMessage:
+		err = f.transcode(buf, v)
+	default:
+		var enc []byte
+		if enc, err = json.Marshal(v); err == nil {
+			err = f.transcode(buf, enc)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeMessage writes a message as a map holding the members of its JSON encoding,
+// in the same order.
+func (f *binaryFormat) writeMessage(buf *bytes.Buffer, msg *jsonrpcMessage) error {
+	w := f.writer
+	if msg == nil {
+		w.writeNull(buf)
+		return nil
+	}
+	n := 0
+	for _, present := range []bool{msg.Version != "", len(msg.ID) > 0, msg.Method != "", len(msg.Params) > 0, msg.Error != nil, len(msg.Result) > 0} {
+		if present {
+			n++
+		}
+	}
+	w.writeMapHead(buf, n)
+	if msg.Version != "" {
+		w.writeString(buf, []byte("jsonrpc"))
+		w.writeString(buf, []byte(msg.Version))
+	}
+	if len(msg.ID) > 0 {
+		w.writeString(buf, []byte("id"))
+		if err := f.transcode(buf, msg.ID); err != nil {
+			return err
+		}
+	}
+	if msg.Method != "" {
+		w.writeString(buf, []byte("Method"))
+		w.writeString(buf, []byte(msg.Method))
+	}
+	if len(msg.Params) > 0 {
+		w.writeString(buf, []byte("params"))
+		if err := f.transcode(buf, msg.Params); err != nil {
+			return err
+		}
+	}
+	if msg.Error != nil {
+		w.writeString(buf, []byte("error"))
+		if msg.Error.Data != nil {
+			w.writeMapHead(buf, 3)
+		} else {
+			w.writeMapHead(buf, 2)
+		}
+		w.writeString(buf, []byte("code"))
+		w.writeInt(buf, int64(msg.Error.Code))
+		w.writeString(buf, []byte("message"))
+		w.writeString(buf, []byte(msg.Error.Message))
+		if msg.Error.Data != nil {
+			data, err := json.Marshal(msg.Error.Data)
+			if err != nil {
+				return err
+			}
+			w.writeString(buf, []byte("data"))
+			if err := f.transcode(buf, data); err != nil {
+				return err
+			}
+		}
+	}
+	if len(msg.Result) > 0 {
+		w.writeString(buf, []byte("result"))
+		if err := f.transcode(buf, msg.Result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transcode writes a JSON value in the binary format. The JSON text is scanned
+// and converted item by item, without decoding it into Go values.
+func (f *binaryFormat) transcode(buf *bytes.Buffer, data []byte) error {
+	t := &jsonTranscoder{data: data, w: f.writer}
+	if err := t.value(buf, 0); err != nil {
+		return err
+	}
+	if t.skipSpace(); t.pos != len(t.data) {
+		return errInvalidJSON
+	}
+	return nil
+}
+
+// jsonTranscoder converts JSON text into a binary format. Array items and map
+// entries are written to a scratch buffer per nesting level until their count is
+// known, then appended after the head of their array or map.
+type jsonTranscoder struct {
+	data    []byte
+	pos     int
+	w       binaryWriter
+	scratch []*bytes.Buffer
+}
+
+func (t *jsonTranscoder) skipSpace() {
+	for t.pos < len(t.data) {
+		switch t.data[t.pos] {
+		case ' ', '\t', '\n', '\r':
+			t.pos++
+		default:
+			return
+		}
+	}
+}
+
+// value writes the next JSON value.
+func (t *jsonTranscoder) value(out *bytes.Buffer, depth int) error {
+	if depth > binaryMaxDepth {
+		return errBinaryDepth
+	}
+	if t.skipSpace(); t.pos >= len(t.data) {
+		return errInvalidJSON
+	}
+	switch c := t.data[t.pos]; {
+	case c == 'n':
+		return t.literal(out, "null", func() { t.w.writeNull(out) })
+	case c == 't':
+		return t.literal(out, "true", func() { t.w.writeBool(out, true) })
+	case c == 'f':
+		return t.literal(out, "false", func() { t.w.writeBool(out, false) })
+	case c == '"':
+		s, err := t.str()
+		if err != nil {
+			return err
+		}
+		t.w.writeString(out, s)
+		return nil
+	case c == '[':
+		return t.array(out, depth)
+	case c == '{':
+		return t.object(out, depth)
+	case c == '-' || (c >= '0' && c <= '9'):
+		return t.number(out)
+	default:
+		return errInvalidJSON
+	}
+}
+
+// literal checks and writes a null or boolean.
+func (t *jsonTranscoder) literal(out *bytes.Buffer, lit string, write func()) error {
+	if !bytes.HasPrefix(t.data[t.pos:], []byte(lit)) {
+		return errInvalidJSON
+	}
+	t.pos += len(lit)
+	write()
+	return nil
+}
+
+// buffer returns the empty scratch buffer of a nesting level.
+func (t *jsonTranscoder) buffer(depth int) *bytes.Buffer {
+	for len(t.scratch) <= depth {
+		t.scratch = append(t.scratch, new(bytes.Buffer))
+	}
+	buf := t.scratch[depth]
+	buf.Reset()
+	return buf
+}
+
+func (t *jsonTranscoder) array(out *bytes.Buffer, depth int) error {
+	t.pos++ // '['
+	var (
+		items = t.buffer(depth)
+		n     int
+	)
+	if t.skipSpace(); t.pos < len(t.data) && t.data[t.pos] == ']' {
+		t.pos++
+		t.w.writeArrayHead(out, 0)
+		return nil
+	}
+	for {
+		if err := t.value(items, depth+1); err != nil {
+			return err
+		}
+		n++
+		if t.skipSpace(); t.pos >= len(t.data) {
+			return errInvalidJSON
+		}
+		t.pos++
+		switch t.data[t.pos-1] {
+		case ',':
+		case ']':
+			t.w.writeArrayHead(out, n)
+			out.Write(items.Bytes())
+			return nil
+		default:
+			return errInvalidJSON
+		}
+	}
+}
+
+func (t *jsonTranscoder) object(out *bytes.Buffer, depth int) error {
+	t.pos++ // '{'
+	var (
+		entries = t.buffer(depth)
+		n       int
+	)
+	if t.skipSpace(); t.pos < len(t.data) && t.data[t.pos] == '}' {
+		t.pos++
+		t.w.writeMapHead(out, 0)
+		return nil
+	}
+	for {
+		if t.skipSpace(); t.pos >= len(t.data) || t.data[t.pos] != '"' {
+			return errInvalidJSON
+		}
+		key, err := t.str()
+		if err != nil {
+			return err
+		}
+		t.w.writeString(entries, key)
+		if t.skipSpace(); t.pos >= len(t.data) || t.data[t.pos] != ':' {
+			return errInvalidJSON
+		}
+		t.pos++
+		if err := t.value(entries, depth+1); err != nil {
+			return err
+		}
+		n++
+		if t.skipSpace(); t.pos >= len(t.data) {
+			return errInvalidJSON
+		}
+		t.pos++
+		switch t.data[t.pos-1] {
+		case ',':
+		case '}':
+			t.w.writeMapHead(out, n)
+			out.Write(entries.Bytes())
+			return nil
+		default:
+			return errInvalidJSON
+		}
+	}
+}
+
+// str reads a string. Strings without escapes or invalid UTF-8 are returned as
+// they are in the input, the others are unquoted by the JSON decoder.
+func (t *jsonTranscoder) str() ([]byte, error) {
+	start := t.pos
+	t.pos++ // '"'
+	plain := true
+	for t.pos < len(t.data) {
+		switch c := t.data[t.pos]; {
+		case c == '"':
+			t.pos++
+			s := t.data[start+1 : t.pos-1]
+			if plain && utf8.Valid(s) {
+				return s, nil
+			}
+			var unquoted string
+			if err := json.Unmarshal(t.data[start:t.pos], &unquoted); err != nil {
+				return nil, errInvalidJSON
+			}
+			return []byte(unquoted), nil
+		case c == '\\':
+			plain = false
+			t.pos += 2
+		case c < 0x20:
+			return nil, errInvalidJSON
+		default:
+			t.pos++
+		}
+	}
+	return nil, errInvalidJSON
+}
+
+// number writes a number as an integer if it fits 64 bits, as a float otherwise.
+func (t *jsonTranscoder) number(out *bytes.Buffer) error {
+	start := t.pos
+	for t.pos < len(t.data) {
+		c := t.data[t.pos]
+		if !(c >= '0' && c <= '9') && c != '-' && c != '+' && c != '.' && c != 'e' && c != 'E' {
+			break
+		}
+		t.pos++
+	}
+	n := string(t.data[start:t.pos])
+	if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+		t.w.writeInt(out, i)
+		return nil
+	}
+	if u, err := strconv.ParseUint(n, 10, 64); err == nil {
+		t.w.writeUint(out, u)
+		return nil
+	}
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return errInvalidJSON
+	}
+	if math.IsInf(f, 0) {
+		return errBinaryNotFinit
+	}
+	t.w.writeFloat(out, f)
+	return nil
+}
+
+// toJSON decodes the next value of the stream into JSON. It returns io.EOF if the
+// stream ends before the value.
+func (f *binaryFormat) toJSON(r *bufio.Reader) (json.RawMessage, error) {
+	if _, err := r.Peek(1); err != nil {
+		return nil, err
+	}
+	out := new(bytes.Buffer)
+	if err := f.read(r, out, 0); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// NewCBORCodec creates a codec exchanging the messages encoded as CBOR (RFC 8949)
+// on the given connection.
+func NewCBORCodec(conn Conn) ServerCodec {
+	return newBinaryCodec(conn, cborFormat)
+}
+
+// NewMsgPackCodec creates a codec exchanging the messages encoded as MessagePack
+// on the given connection.
+func NewMsgPackCodec(conn Conn) ServerCodec {
+	return newBinaryCodec(conn, msgpackFormat)
+}
+
+func newBinaryCodec(conn Conn, f *binaryFormat) ServerCodec {
+	r := bufio.NewReader(conn)
+	encode := func(v interface{}) error {
+		enc, err := f.encode(v)
+		if err != nil {
+			return err
+		}
+		_, err = conn.Write(enc)
+		return err
+	}
+	decode := func(v interface{}) error {
+		msg, err := f.toJSON(r)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(msg, v)
+	}
+	return NewFuncCodec(conn, encode, decode)
+}
+
+// writeJSONString writes a decoded text string as JSON.
+func writeJSONString(out *bytes.Buffer, s []byte) {
+	enc, _ := json.Marshal(string(s))
+	out.Write(enc)
+}
+
+// writeJSONBytes writes a decoded byte string as JSON.
+func writeJSONBytes(out *bytes.Buffer, b []byte) {
+	out.WriteString(`"0x`)
+	out.WriteString(hex.EncodeToString(b))
+	out.WriteByte('"')
+}
+
+// writeJSONFloat writes a decoded float as JSON.
+func writeJSONFloat(out *bytes.Buffer, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return errBinaryNotFinit
+	}
+	out.Write(strconv.AppendFloat(nil, f, 'g', -1, 64))
+	return nil
+}
+
+// readJSONKey decodes a map key with the given reader, writing it as JSON string.
+func readJSONKey(r *bufio.Reader, out *bytes.Buffer, depth int, read func(*bufio.Reader, *bytes.Buffer, int) error) error {
+	var key bytes.Buffer
+	if err := read(r, &key, depth); err != nil {
+		return err
+	}
+	switch k := key.Bytes(); {
+	case len(k) > 0 && k[0] == '"':
+		out.Write(k)
+	case len(k) > 0 && (k[0] == '-' || (k[0] >= '0' && k[0] <= '9')):
+		out.WriteByte('"')
+		out.Write(k)
+		out.WriteByte('"')
+	default:
+		return errBinaryMapKey
+	}
+	out.WriteByte(':')
+	return nil
+}
+
+// readBinaryString reads a string of n bytes. The buffer grows with the data read,
+// so bogus lengths fail at the end of the stream rather than allocating.
+func readBinaryString(r *bufio.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readBigEndian reads an n-byte big endian integer.
+func readBigEndian(r *bufio.Reader, n int) (uint64, error) {
+	var v uint64
+	for i := 0; i < n; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
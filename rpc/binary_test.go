@@ -0,0 +1,237 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var binaryTests = []struct {
+	json, cbor, msgpack string
+}{
+	{`null`, "f6", "c0"},
+	{`true`, "f5", "c3"},
+	{`0`, "00", "00"},
+	{`24`, "1818", "18"},
+	{`-1`, "20", "ff"},
+	{`-500`, "3901f3", "d1fe0c"},
+	{`18446744073709551615`, "1bffffffffffffffff", "cfffffffffffffffff"},
+	{`1.5`, "fb3ff8000000000000", "cb3ff8000000000000"},
+	{`"0x01"`, "6430783031", "a430783031"},
+	{`[1,[2]]`, "82018102", "92019102"},
+	{`{"a":1,"b":[2,3]}`, "a26161016162820203", "82a16101a162920203"},
+}
+
+func TestBinaryEncoding(t *testing.T) {
+	for _, tt := range binaryTests {
+		for _, f := range []struct {
+			format *binaryFormat
+			enc    string
+		}{{cborFormat, tt.cbor}, {msgpackFormat, tt.msgpack}} {
+			enc, err := f.format.fromJSON([]byte(tt.json))
+			if err != nil {
+				t.Errorf("%s: can't encode %s: %v", f.format.contentType, tt.json, err)
+				continue
+			}
+			if hex.EncodeToString(enc) != f.enc {
+				t.Errorf("%s: encoding mismatch for %s: have %x, want %s", f.format.contentType, tt.json, enc, f.enc)
+			}
+			dec, err := f.format.toJSON(bufio.NewReader(bytes.NewReader(enc)))
+			if err != nil {
+				t.Errorf("%s: can't decode %x: %v", f.format.contentType, enc, err)
+				continue
+			}
+			if string(dec) != tt.json {
+				t.Errorf("%s: decoding mismatch: have %s, want %s", f.format.contentType, dec, tt.json)
+			}
+		}
+	}
+}
+
+// Tests that messages encoded field by field match the encoding of their JSON.
+func TestBinaryMessageEncoding(t *testing.T) {
+	msgs := []interface{}{
+		&jsonrpcMessage{Version: vsn, ID: json.RawMessage(`1`), Method: "test_echo", Params: json.RawMessage(`["x\"\u00e9", 2, {"a": null, "b": -1.5e3}]`)},
+		&jsonrpcMessage{Version: vsn, ID: json.RawMessage(`"id"`), Result: json.RawMessage(`{"b":[1.5,true],"a":"0x01"}`)},
+		&jsonrpcMessage{Version: vsn, ID: json.RawMessage(`2`), Error: &jsonError{Code: -32000, Message: "fail", Data: map[string]int{"x": 1}}},
+		&jsonrpcMessage{Version: vsn, Method: "test_subscription", Params: json.RawMessage(`{"subscription":"0x1","result":18446744073709551615}`)},
+		[]*jsonrpcMessage{{Version: vsn, ID: json.RawMessage(`3`), Result: json.RawMessage(`null`)}, nil},
+		json.RawMessage(`{"jsonrpc":"2.0","id":4,"result":[]}`),
+	}
+	for _, format := range []*binaryFormat{cborFormat, msgpackFormat} {
+		for i, msg := range msgs {
+			enc, err := format.encode(msg)
+			if err != nil {
+				t.Fatalf("%s: can't encode message %d: %v", format.contentType, i, err)
+			}
+			js, _ := json.Marshal(msg)
+			want, err := format.fromJSON(js)
+			if err != nil {
+				t.Fatalf("%s: can't encode JSON of message %d: %v", format.contentType, i, err)
+			}
+			if !bytes.Equal(enc, want) {
+				t.Errorf("%s: encoding mismatch for message %d: have %x, want %x", format.contentType, i, enc, want)
+			}
+		}
+	}
+}
+
+// Tests that invalid JSON values are rejected by the binary encodings.
+func TestBinaryInvalidJSON(t *testing.T) {
+	for _, input := range []string{``, `nul`, `"abc`, `[1,]`, `{"a":}`, `{"a" 1}`, `{1:2}`, `1 2`, `01x`, `1e999`, "\"a\x01\""} {
+		for _, format := range []*binaryFormat{cborFormat, msgpackFormat} {
+			if enc, err := format.fromJSON([]byte(input)); err == nil {
+				t.Errorf("%s: encoded invalid JSON %q as %x", format.contentType, input, enc)
+			}
+		}
+	}
+}
+
+// discardConn is a connection discarding the data written.
+type discardConn struct{}
+
+func (discardConn) Read(b []byte) (int, error)       { return 0, io.EOF }
+func (discardConn) Write(b []byte) (int, error)      { return len(b), nil }
+func (discardConn) Close() error                     { return nil }
+func (discardConn) SetWriteDeadline(time.Time) error { return nil }
+
+// Benchmarks the encoding of a response by the binary codecs against the JSON one.
+func BenchmarkCodecEncode(b *testing.B) {
+	items := make([]string, 100)
+	for i := range items {
+		items[i] = `{"hash":"0x5a6f5d4bdbae3bd3d66f6eb0d2b83ed7f0ffba5dad1f53a7e3ae2d7c00f3b38d","number":12345678,"used":true}`
+	}
+	msg := &jsonrpcMessage{Version: vsn, ID: json.RawMessage(`1`), Result: json.RawMessage("[" + strings.Join(items, ",") + "]")}
+
+	for _, codec := range []struct {
+		name string
+		new  func(Conn) ServerCodec
+	}{{"json", NewCodec}, {"cbor", NewCBORCodec}, {"msgpack", NewMsgPackCodec}} {
+		b.Run(codec.name, func(b *testing.B) {
+			c := codec.new(discardConn{})
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := c.writeJSON(context.Background(), msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Tests the decoding of the binary values which have no JSON counterpart, and
+// of invalid ones.
+func TestBinaryDecoding(t *testing.T) {
+	tests := []struct {
+		format *binaryFormat
+		input  string
+		json   string // empty if invalid
+	}{
+		{cborFormat, "43010203", `"0x010203"`},                         // byte string
+		{cborFormat, "9f0102ff", `[1,2]`},                              // indefinite array
+		{cborFormat, "bf616101ff", `{"a":1}`},                          // indefinite map
+		{cborFormat, "7f61616162ff", `"ab"`},                           // chunked text
+		{cborFormat, "f93e00", `1.5`},                                  // half float
+		{cborFormat, "c249010000000000000000", `18446744073709551616`}, // bignum
+		{cborFormat, "c11a514b67b0", `1363896240`},                     // epoch tag
+		{cborFormat, "a10102", `{"1":2}`},                              // integer key
+		{cborFormat, "a1f601", ``},                                     // null key
+		{cborFormat, "a10161", ``},                                     // truncated
+		{cborFormat, "5bffffffffffffffff", ``},                         // bogus length
+		{msgpackFormat, "c403010203", `"0x010203"`},                    // bin
+		{msgpackFormat, "d0ff", `-1`},                                  // int8
+		{msgpackFormat, "d1fe0c", `-500`},                              // int16
+		{msgpackFormat, "81a16101", `{"a":1}`},                         // fixmap
+		{msgpackFormat, "d40000", ``},                                  // extension
+		{msgpackFormat, "dbffffffff", ``},                              // truncated
+	}
+	for i, tt := range tests {
+		input, _ := hex.DecodeString(tt.input)
+		dec, err := tt.format.toJSON(bufio.NewReader(bytes.NewReader(input)))
+		switch {
+		case tt.json == "":
+			if err == nil {
+				t.Errorf("test %d: decoded invalid input %s as %s", i, tt.input, dec)
+			}
+		case err != nil:
+			t.Errorf("test %d: can't decode %s: %v", i, tt.input, err)
+		case string(dec) != tt.json:
+			t.Errorf("test %d: decoding mismatch: have %s, want %s", i, dec, tt.json)
+		}
+	}
+}
+
+// Tests that HTTP requests in a binary encoding are answered in the same one.
+func TestHTTPBinaryEncoding(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	for _, ctype := range []string{cborContentType, msgpackContentType} {
+		client, err := DialHTTP(httpsrv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.SetHeader("content-type", ctype)
+
+		var resp echoResult
+		if err := client.Call(&resp, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+			t.Fatalf("%s: call failed: %v", ctype, err)
+		}
+		if want := (echoResult{"hello", 10, &echoArgs{"world"}}); !reflect.DeepEqual(resp, want) {
+			t.Errorf("%s: result mismatch: have %+v, want %+v", ctype, resp, want)
+		}
+		batch := []BatchElem{
+			{Method: "test_echo", Args: []interface{}{"x", 1, nil}, Result: new(echoResult)},
+			{Method: "no_such_method", Result: new(int)},
+		}
+		if err := client.BatchCall(batch); err != nil {
+			t.Fatalf("%s: batch failed: %v", ctype, err)
+		}
+		if batch[0].Error != nil || batch[1].Error == nil {
+			t.Errorf("%s: batch results mismatch: %v, %v", ctype, batch[0].Error, batch[1].Error)
+		}
+		client.Close()
+
+		// The response is encoded like the request
+		format := binaryFormats[ctype]
+		body, _ := format.fromJSON([]byte(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules"}`))
+		raw, err := http.Post(httpsrv.URL, ctype, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := raw.Header.Get("content-type"); have != ctype {
+			t.Errorf("response content type mismatch: have %s, want %s", have, ctype)
+		}
+		if _, err := format.toJSON(bufio.NewReader(raw.Body)); err != nil {
+			t.Errorf("%s: invalid response: %v", ctype, err)
+		}
+		raw.Body.Close()
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// CBOR major types.
+const (
+	cborUint   = 0
+	cborNegint = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+const (
+	cborIndefinite = 31   // additional information of indefinite lengths
+	cborBreak      = 0xff // end of the indefinite-length items
+)
+
+var errCBORIndefinite = errors.New("invalid indefinite-length CBOR item")
+
+// cborWriter writes the items of the JSON data model as CBOR.
+type cborWriter struct{}
+
+func (cborWriter) writeNull(buf *bytes.Buffer) { buf.WriteByte(0xf6) }
+
+func (cborWriter) writeBool(buf *bytes.Buffer, v bool) {
+	if v {
+		buf.WriteByte(0xf5)
+	} else {
+		buf.WriteByte(0xf4)
+	}
+}
+
+func (cborWriter) writeInt(buf *bytes.Buffer, n int64) {
+	if n >= 0 {
+		writeCBORHead(buf, cborUint, uint64(n))
+	} else {
+		writeCBORHead(buf, cborNegint, uint64(-1-n))
+	}
+}
+
+func (cborWriter) writeUint(buf *bytes.Buffer, n uint64) {
+	writeCBORHead(buf, cborUint, n)
+}
+
+func (cborWriter) writeFloat(buf *bytes.Buffer, f float64) {
+	buf.WriteByte(0xfb)
+	binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+}
+
+func (cborWriter) writeString(buf *bytes.Buffer, s []byte) {
+	writeCBORHead(buf, cborText, uint64(len(s)))
+	buf.Write(s)
+}
+
+func (cborWriter) writeArrayHead(buf *bytes.Buffer, n int) {
+	writeCBORHead(buf, cborArray, uint64(n))
+}
+
+func (cborWriter) writeMapHead(buf *bytes.Buffer, n int) {
+	writeCBORHead(buf, cborMap, uint64(n))
+}
+
+// writeCBORHead writes the head of an item, in its shortest form.
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// readCBOR decodes a CBOR item into JSON.
+func readCBOR(r *bufio.Reader, out *bytes.Buffer, depth int) error {
+	if depth > binaryMaxDepth {
+		return errBinaryDepth
+	}
+	head, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	major, info := head>>5, head&0x1f
+
+	if major == cborSimple {
+		return readCBORSimple(r, out, info)
+	}
+	if info == cborIndefinite {
+		return readCBORIndefinite(r, out, major, depth)
+	}
+	arg, err := readCBORArg(r, info)
+	if err != nil {
+		return err
+	}
+	switch major {
+	case cborUint:
+		out.WriteString(strconv.FormatUint(arg, 10))
+	case cborNegint:
+		n := new(big.Int).SetUint64(arg)
+		out.WriteString(n.Not(n).String()) // -1-arg
+	case cborBytes, cborText:
+		s, err := readBinaryString(r, arg)
+		if err != nil {
+			return err
+		}
+		if major == cborBytes {
+			writeJSONBytes(out, s)
+		} else {
+			writeJSONString(out, s)
+		}
+	case cborArray:
+		out.WriteByte('[')
+		for i := uint64(0); i < arg; i++ {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := readCBOR(r, out, depth+1); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+	case cborMap:
+		out.WriteByte('{')
+		for i := uint64(0); i < arg; i++ {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := readJSONKey(r, out, depth+1, readCBOR); err != nil {
+				return err
+			}
+			if err := readCBOR(r, out, depth+1); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+	case cborTag:
+		// Bignums are decoded as numbers, other tags are ignored.
+		if arg != 2 && arg != 3 {
+			return readCBOR(r, out, depth+1)
+		}
+		head, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if head>>5 != cborBytes {
+			return errors.New("invalid CBOR bignum")
+		}
+		s, err := readCBORString(r, cborBytes, head&0x1f)
+		if err != nil {
+			return err
+		}
+		n := new(big.Int).SetBytes(s)
+		if arg == 3 {
+			n.Not(n)
+		}
+		out.WriteString(n.String())
+	}
+	return nil
+}
+
+// readCBORArg reads the argument of an item head.
+func readCBORArg(r *bufio.Reader, info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		return readBigEndian(r, 1<<(info-24))
+	}
+	return 0, fmt.Errorf("invalid CBOR additional information %d", info)
+}
+
+// readCBORSimple decodes a simple value or float.
+func readCBORSimple(r *bufio.Reader, out *bytes.Buffer, info byte) error {
+	switch info {
+	case 20:
+		out.WriteString("false")
+	case 21:
+		out.WriteString("true")
+	case 22, 23: // null, undefined
+		out.WriteString("null")
+	case 25:
+		bits, err := readBigEndian(r, 2)
+		if err != nil {
+			return err
+		}
+		return writeJSONFloat(out, float16(uint16(bits)))
+	case 26:
+		bits, err := readBigEndian(r, 4)
+		if err != nil {
+			return err
+		}
+		return writeJSONFloat(out, float64(math.Float32frombits(uint32(bits))))
+	case 27:
+		bits, err := readBigEndian(r, 8)
+		if err != nil {
+			return err
+		}
+		return writeJSONFloat(out, math.Float64frombits(bits))
+	default:
+		return fmt.Errorf("unsupported CBOR simple value %d", info)
+	}
+	return nil
+}
+
+// readCBORIndefinite decodes an indefinite-length item.
+func readCBORIndefinite(r *bufio.Reader, out *bytes.Buffer, major byte, depth int) error {
+	switch major {
+	case cborBytes, cborText:
+		s, err := readCBORString(r, major, cborIndefinite)
+		if err != nil {
+			return err
+		}
+		if major == cborBytes {
+			writeJSONBytes(out, s)
+		} else {
+			writeJSONString(out, s)
+		}
+		return nil
+	case cborArray, cborMap:
+		start, end := byte('['), byte(']')
+		if major == cborMap {
+			start, end = '{', '}'
+		}
+		out.WriteByte(start)
+		for i := 0; ; i++ {
+			if next, err := r.Peek(1); err != nil {
+				return err
+			} else if next[0] == cborBreak {
+				r.ReadByte()
+				break
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if major == cborMap {
+				if err := readJSONKey(r, out, depth+1, readCBOR); err != nil {
+					return err
+				}
+			}
+			if err := readCBOR(r, out, depth+1); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(end)
+		return nil
+	}
+	return errCBORIndefinite
+}
+
+// readCBORString reads the content of a byte or text string, concatenating the
+// chunks of indefinite-length strings.
+func readCBORString(r *bufio.Reader, major byte, info byte) ([]byte, error) {
+	if info != cborIndefinite {
+		n, err := readCBORArg(r, info)
+		if err != nil {
+			return nil, err
+		}
+		return readBinaryString(r, n)
+	}
+	var s []byte
+	for {
+		head, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if head == cborBreak {
+			return s, nil
+		}
+		if head>>5 != major || head&0x1f == cborIndefinite {
+			return nil, errCBORIndefinite
+		}
+		chunk, err := readCBORString(r, major, head&0x1f)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+}
+
+// float16 converts an IEEE 754 half-precision float.
+func float16(bits uint16) float64 {
+	var (
+		sign = 1.0
+		exp  = int(bits>>10) & 0x1f
+		frac = float64(bits & 0x3ff)
+	)
+	if bits&0x8000 != 0 {
+		sign = -1
+	}
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(frac+1024, exp-25)
+}
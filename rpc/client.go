@@ -35,6 +35,7 @@ var (
 	ErrClientQuit                = errors.New("client is closed")
 	ErrNoResult                  = errors.New("no result in JSON-RPC response")
 	ErrSubscriptionQueueOverflow = errors.New("subscription queue overflow")
+	ErrPeerUnresponsive          = errors.New("connection closed: peer unresponsive")
 	errClientReconnected         = errors.New("client reconnected")
 	errDead                      = errors.New("connection lost")
 )
@@ -77,6 +78,10 @@ type Client struct {
 	idgen    func() ID // for subscriptions
 	isHTTP   bool
 	services *serviceRegistry
+	limits   batchLimits // for the batches served by the client
+
+	interceptor atomic.Value // Interceptor observing the calls
+	conn        atomic.Value // currentConn of the client, for its health
 
 	idCounter uint32
 
@@ -111,7 +116,7 @@ type clientConn struct {
 
 func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx := context.WithValue(context.Background(), clientContextKey{}, c)
-	handler := newHandler(ctx, conn, c.idgen, c.services)
+	handler := newHandler(ctx, conn, c.idgen, c.services, c.limits)
 	return &clientConn{conn, handler}
 }
 
@@ -197,17 +202,18 @@ func newClient(initctx context.Context, connect reconnectFunc) (*Client, error)
 	if err != nil {
 		return nil, err
 	}
-	c := initClient(conn, randomIDGenerator(), new(serviceRegistry))
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), batchLimits{})
 	c.reconnectFunc = connect
 	return c, nil
 }
 
-func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry) *Client {
+func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, limits batchLimits) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
 		idgen:       idgen,
 		isHTTP:      isHTTP,
 		services:    services,
+		limits:      limits,
 		writeConn:   conn,
 		close:       make(chan struct{}),
 		closing:     make(chan struct{}),
@@ -220,6 +226,7 @@ func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry) *C
 		reqTimeout:  make(chan *requestOp),
 	}
 	if !isHTTP {
+		c.conn.Store(currentConn{conn})
 		go c.dispatch(conn)
 	}
 	return c
@@ -288,7 +295,7 @@ func (c *Client) Call(result interface{}, Method string, args ...interface{}) er
 //
 // The result must be a pointer so that package json can unmarshal into it. You
 // can also pass nil, in which case the result is ignored.
-func (c *Client) CallContext(ctx context.Context, result interface{}, Method string, args ...interface{}) error {
+func (c *Client) CallContext(ctx context.Context, result interface{}, Method string, args ...interface{}) (err error) {
 	if result != nil && reflect.TypeOf(result).Kind() != reflect.Ptr {
 		return fmt.Errorf("call result parameter must be pointer or nil interface: %v", result)
 	}
@@ -298,6 +305,17 @@ func (c *Client) CallContext(ctx context.Context, result interface{}, Method str
 	}
 	op := &requestOp{ids: []json.RawMessage{msg.ID}, resp: make(chan *jsonrpcMessage, 1)}
 
+	var (
+		resp  *jsonrpcMessage
+		start = time.Now()
+	)
+	defer func() {
+		event := &CallEvent{Method: Method, Duration: time.Since(start), Err: err, RequestSize: len(msg.Params)}
+		if resp != nil {
+			event.ResponseSize = len(resp.Result)
+		}
+		c.intercept(event)
+	}()
 	if c.isHTTP {
 		err = c.sendHTTP(ctx, op, msg)
 	} else {
@@ -308,7 +326,8 @@ func (c *Client) CallContext(ctx context.Context, result interface{}, Method str
 	}
 
 	// dispatch has accepted the request and will close the channel when it quits.
-	switch resp, err := op.wait(ctx, c); {
+	resp, err = op.wait(ctx, c)
+	switch {
 	case err != nil:
 		return err
 	case resp.Error != nil:
@@ -356,7 +375,20 @@ func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
 		op.ids[i] = msg.ID
 	}
 
-	var err error
+	var (
+		err   error
+		sizes = make([]int, len(b))
+		start = time.Now()
+	)
+	defer func() {
+		for i, msg := range msgs {
+			event := &CallEvent{Method: msg.Method, Duration: time.Since(start), Err: b[i].Error, RequestSize: len(msg.Params), ResponseSize: sizes[i]}
+			if err != nil {
+				event.Err = err
+			}
+			c.intercept(event)
+		}
+	}()
 	if c.isHTTP {
 		err = c.sendBatchHTTP(ctx, op, msgs)
 	} else {
@@ -377,6 +409,7 @@ func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
 		for i := range msgs {
 			if bytes.Equal(msgs[i].ID, resp.ID) {
 				elem = &b[i]
+				sizes[i] = len(resp.Result)
 				break
 			}
 		}
@@ -394,7 +427,7 @@ func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
 }
 
 // Notify sends a notification, i.e. a Method call that doesn't expect a response.
-func (c *Client) Notify(ctx context.Context, Method string, args ...interface{}) error {
+func (c *Client) Notify(ctx context.Context, Method string, args ...interface{}) (err error) {
 	op := new(requestOp)
 	msg, err := c.newMessage(Method, args...)
 	if err != nil {
@@ -402,6 +435,11 @@ func (c *Client) Notify(ctx context.Context, Method string, args ...interface{})
 	}
 	msg.ID = nil
 
+	start := time.Now()
+	defer func() {
+		c.intercept(&CallEvent{Method: Method, Duration: time.Since(start), Err: err, RequestSize: len(msg.Params)})
+	}()
+
 	if c.isHTTP {
 		return c.sendHTTP(ctx, op, msg)
 	}
@@ -430,8 +468,15 @@ func (c *Client) ShhSubscribe(ctx context.Context, channel interface{}, args ...
 // Slow subscribers will be dropped eventually. Client buffers up to 20000 notifications
 // before considering the subscriber dead. The subscription Err channel will receive
 // ErrSubscriptionQueueOverflow. Use a sufficiently large buffer on the channel or ensure
-// that the channel usually has at least one reader to prevent this issue.
+// that the channel usually has at least one reader to prevent this issue, or use
+// SubscribeWithOptions to choose another behavior.
 func (c *Client) Subscribe(ctx context.Context, namespace string, channel interface{}, args ...interface{}) (*ClientSubscription, error) {
+	return c.SubscribeWithOptions(ctx, namespace, channel, SubscriptionOptions{}, args...)
+}
+
+// SubscribeWithOptions registers a subscription like Subscribe, buffering the
+// notifications of slow subscribers as configured by opts.
+func (c *Client) SubscribeWithOptions(ctx context.Context, namespace string, channel interface{}, opts SubscriptionOptions, args ...interface{}) (*ClientSubscription, error) {
 	// Check type of channel first.
 	chanVal := reflect.ValueOf(channel)
 	if chanVal.Kind() != reflect.Chan || chanVal.Type().ChanDir()&reflect.SendDir == 0 {
@@ -451,15 +496,19 @@ func (c *Client) Subscribe(ctx context.Context, namespace string, channel interf
 	op := &requestOp{
 		ids:  []json.RawMessage{msg.ID},
 		resp: make(chan *jsonrpcMessage),
-		sub:  newClientSubscription(c, namespace, chanVal),
+		sub:  newClientSubscription(c, namespace, chanVal, opts),
 	}
 
 	// Send the subscription request.
 	// The arrival and validity of the response is signaled on sub.quit.
+	start := time.Now()
 	if err := c.send(ctx, op, msg); err != nil {
+		c.intercept(&CallEvent{Method: msg.Method, Duration: time.Since(start), Err: err, RequestSize: len(msg.Params)})
 		return nil, err
 	}
-	if _, err := op.wait(ctx, c); err != nil {
+	_, err = op.wait(ctx, c)
+	c.intercept(&CallEvent{Method: msg.Method, Duration: time.Since(start), Err: err, RequestSize: len(msg.Params)})
+	if err != nil {
 		return nil, err
 	}
 	return op.sub, nil
@@ -590,6 +639,7 @@ func (c *Client) dispatch(codec ServerCodec) {
 			go c.read(newcodec)
 			reading = true
 			conn = c.newClientConn(newcodec)
+			c.conn.Store(currentConn{newcodec})
 			// Re-register the in-flight request on the new handler
 			// because that's where it will be sent.
 			conn.handler.addRequestOp(lastOp)
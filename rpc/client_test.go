@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
@@ -33,6 +34,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ong2020/go-orange/log"
+	"github.com/ong2020/go-orange/metrics"
 )
 
 func TestClientRequest(t *testing.T) {
@@ -94,6 +96,41 @@ func TestClientErrorData(t *testing.T) {
 	}
 }
 
+// This test checks that the code and data of wrapped and structured errors are
+// sent to the client.
+func TestClientStructuredErrors(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	tests := []struct {
+		method  string
+		message string
+		code    int
+		data    interface{}
+	}{
+		{"test_returnWrappedError", "wrapped: testError", 444, "testError data"},
+		{"test_returnDataError", "data error", 555, map[string]interface{}{"value": float64(1)}},
+	}
+	for _, tt := range tests {
+		err := client.Call(nil, tt.method)
+		var de DataError
+		if !errors.As(err, &de) {
+			t.Fatalf("%s: client did not return rpc.DataError, got %#v", tt.method, err)
+		}
+		if err.Error() != tt.message {
+			t.Errorf("%s: wrong error message %q, want %q", tt.method, err.Error(), tt.message)
+		}
+		if code := err.(Error).ErrorCode(); code != tt.code {
+			t.Errorf("%s: wrong error code %d, want %d", tt.method, code, tt.code)
+		}
+		if !reflect.DeepEqual(de.ErrorData(), tt.data) {
+			t.Errorf("%s: wrong error data %#v, want %#v", tt.method, de.ErrorData(), tt.data)
+		}
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer()
 	defer server.Stop()
@@ -376,6 +413,54 @@ func TestClientCloseUnsubscribeRace(t *testing.T) {
 	}
 }
 
+// This test checks the backpressure policies of the subscriptions of slow
+// subscribers.
+func TestClientSubscriptionBackpressure(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	drop := func(policy BackpressurePolicy, first int) {
+		nc := make(chan int)
+		opts := SubscriptionOptions{BufferSize: 10, Policy: policy}
+		sub, err := client.SubscribeWithOptions(context.Background(), "nftest", nc, opts, "someSubscription", 100, 0)
+		if err != nil {
+			t.Fatal("can't subscribe:", err)
+		}
+		defer sub.Unsubscribe()
+
+		for deadline := time.Now().Add(5 * time.Second); sub.Dropped() < 90; {
+			if time.Now().After(deadline) {
+				t.Fatalf("policy %d: dropped %d notifications, want 90", policy, sub.Dropped())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		for i := 0; i < 10; i++ {
+			if val := <-nc; val != first+i {
+				t.Fatalf("policy %d: notification %d mismatch: have %d, want %d", policy, i, val, first+i)
+			}
+		}
+	}
+	drop(BackpressureDropOldest, 90)
+	drop(BackpressureDropNewest, 0)
+
+	nc := make(chan int)
+	opts := SubscriptionOptions{BufferSize: 5, Policy: BackpressureBlock, BlockTimeout: 50 * time.Millisecond}
+	sub, err := client.SubscribeWithOptions(context.Background(), "nftest", nc, opts, "someSubscription", 20, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	select {
+	case err := <-sub.Err():
+		if err != ErrSubscriptionQueueOverflow {
+			t.Fatalf("got error %q, want %q", err, ErrSubscriptionQueueOverflow)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked subscription not ended within timeout")
+	}
+}
+
 // This test checks that Client doesn't lock up when a single subscriber
 // doesn't read subscription events.
 func TestClientNotificationStorm(t *testing.T) {
@@ -466,6 +551,117 @@ func TestClientSetHeader(t *testing.T) {
 	}
 }
 
+// Tests that the client interceptor observes calls and subscription notifications.
+func TestClientInterceptor(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var (
+		events []CallEvent
+		lock   sync.Mutex
+	)
+	client.SetInterceptor(func(event *CallEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, *event)
+	})
+	var resp echoResult
+	if err := client.Call(&resp, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(nil, "test_returnError"); err == nil {
+		t.Fatal("expected error")
+	}
+	nc := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "nftest", nc, "someSubscription", 2, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	<-nc
+	<-nc
+	sub.Unsubscribe()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(events) < 5 {
+		t.Fatalf("event count mismatch: have %d, want at least 5", len(events))
+	}
+	want := []struct {
+		method       string
+		notification bool
+		failed       bool
+	}{
+		{"test_echo", false, false},
+		{"test_returnError", false, true},
+		{"nftest_subscribe", false, false},
+		{"nftest_subscription", true, false},
+		{"nftest_subscription", true, false},
+	}
+	for i, w := range want {
+		event := events[i]
+		if event.Method != w.method || event.Notification != w.notification || (event.Err != nil) != w.failed {
+			t.Errorf("event %d mismatch: have %+v, want method %s, notification %v, failed %v", i, event, w.method, w.notification, w.failed)
+		}
+	}
+	if events[0].RequestSize == 0 || events[0].ResponseSize == 0 {
+		t.Errorf("call sizes missing: %+v", events[0])
+	}
+	if events[3].ResponseSize == 0 {
+		t.Errorf("notification size missing: %+v", events[3])
+	}
+}
+
+// Tests that the metrics interceptor records the calls by method.
+func TestClientMetricsInterceptor(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	// The server records the calls in the default registry, clean up after it
+	defer func() {
+		for _, method := range []string{"echo", "returnError"} {
+			for _, name := range []string{"calls", "errors", "duration"} {
+				metrics.DefaultRegistry.Unregister("rpc/test/" + method + "/" + name)
+			}
+		}
+	}()
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	registry := metrics.NewRegistry()
+	client.SetInterceptor(NewMetricsInterceptor(registry, "client"))
+	for i := 0; i < 2; i++ {
+		if err := client.Call(nil, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.Call(nil, "test_returnError"); err == nil {
+		t.Fatal("expected error")
+	}
+	counts := map[string]int64{
+		"client/test/echo/calls":         2,
+		"client/test/echo/errors":        0,
+		"client/test/returnError/calls":  1,
+		"client/test/returnError/errors": 1,
+	}
+	for name, want := range counts {
+		var have int64
+		if meter, ok := registry.Get(name).(metrics.Meter); ok {
+			have = meter.Count()
+		}
+		if have != want {
+			t.Errorf("%s count mismatch: have %d, want %d", name, have, want)
+		}
+	}
+	if timer, ok := registry.Get("client/test/echo/duration").(metrics.Timer); !ok || timer.Count() != 2 {
+		t.Errorf("latency not recorded")
+	}
+}
+
 func TestClientHTTP(t *testing.T) {
 	server := newTestServer()
 	defer server.Stop()
@@ -610,7 +806,7 @@ func ipcTestClient(srv *Server, fl *flakeyListener) (*Client, net.Listener) {
 	} else {
 		endpoint = os.TempDir() + "/" + endpoint
 	}
-	l, err := ipcListen(endpoint)
+	l, err := ipcListen(endpoint, IPCConfig{})
 	if err != nil {
 		panic(err)
 	}
@@ -49,6 +49,35 @@ will be pointing to the given third argument. Since the optional argument is the
 argument the RPC package will also accept 2 integers as arguments. It will pass the mod
 argument as nil to the RPC Method.
 
+Named parameters are supported by Methods taking a single struct argument whose fields
+are tagged with their parameter names.
+
+ type AddArgs struct {
+	A   int  `rpc:"a"`
+	B   int  `rpc:"b"`
+	Mod *int `rpc:"mod"`
+ }
+
+ func (s *CalcService) Add(args AddArgs) (int, error)
+
+This RPC Method can be called with an object of named parameters, e.g. {"a":1,"b":2}, or
+with the parameters in the order of the tagged fields, e.g. [1,2]. Parameters of pointer
+type are optional in both cases.
+
+Methods with a variadic last parameter accept any number of trailing arguments, which are
+passed to it in order.
+
+ func (s *CalcService) Sum(values ...int) int
+
+This RPC Method can be called with no arguments, e.g. [], or with any number of integers,
+e.g. [1,2,3].
+
+Methods returning a Stream produce an array result incrementally. The items are written
+to the connection as they are produced instead of buffering the entire response, and
+clients can iterate over them with Client.StreamContext.
+
+ func (s *CalcService) Range(n int) rpc.Stream
+
 The server offers the ServeCodec Method which accepts a ServerCodec instance. It will read
 requests from the codec, process the request and sends the response back to the client
 using the codec. The server can execute requests concurrently. Responses can be sent back
@@ -93,7 +122,8 @@ An example Method:
 
 When the service containing the subscription Method is registered to the server, for
 example under the "blockchain" namespace, a subscription is created by calling the
-"blockchain_subscribe" Method.
+"blockchain_subscribe" Method. Subscription methods named Subscribe<Name> can also be
+called directly, e.g. SubscribeBlocks as "blockchain_subscribeBlocks".
 
 Subscriptions are deleted when the user sends an unsubscribe request or when the
 connection which was used to create the subscription is closed. This can be initiated by
@@ -25,6 +25,12 @@ import (
 
 // StartIPCEndpoint starts an IPC endpoint.
 func StartIPCEndpoint(ipcEndpoint string, apis []API) (net.Listener, *Server, error) {
+	return StartIPCEndpointWithConfig(ipcEndpoint, apis, IPCConfig{})
+}
+
+// StartIPCEndpointWithConfig starts an IPC endpoint, setting the access to it as
+// configured.
+func StartIPCEndpointWithConfig(ipcEndpoint string, apis []API, config IPCConfig) (net.Listener, *Server, error) {
 	// Register all the APIs exposed by the services.
 	var (
 		handler    = NewServer()
@@ -43,7 +49,7 @@ func StartIPCEndpoint(ipcEndpoint string, apis []API) (net.Listener, *Server, er
 	}
 	log.Debug("IPCs registered", "namespaces", strings.Join(registered, ","))
 	// All APIs registered, start the IPC listener.
-	listener, err := ipcListen(ipcEndpoint)
+	listener, err := ipcListen(ipcEndpoint, config)
 	if err != nil {
 		return nil, nil, err
 	}
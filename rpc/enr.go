@@ -0,0 +1,76 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/ong2020/go-orange/rlp"
+)
+
+// maxENREntrySize is the maximum encoded size of the RPC advertisement, leaving
+// enough room in the 300 byte node record for the mandatory and protocol entries.
+const maxENREntrySize = 160
+
+var errENREntryTooLarge = fmt.Errorf("rpc advertisement exceeds %d bytes", maxENREntrySize)
+
+// ENREntry is the "rpc" node record entry, advertising the public RPC endpoints
+// of a node and the API namespaces served on them.
+type ENREntry struct {
+	URLs       []string // Public endpoint URLs (http, https, ws or wss)
+	Namespaces []string // API namespaces served on the endpoints
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e ENREntry) ENRKey() string {
+	return "rpc"
+}
+
+// Validate checks that the advertised endpoints are well formed absolute URLs
+// and that the entry fits into a node record.
+func (e *ENREntry) Validate() error {
+	if len(e.URLs) == 0 {
+		return errors.New("no rpc endpoints to advertise")
+	}
+	for _, rawurl := range e.URLs {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return fmt.Errorf("invalid rpc endpoint %q: %v", rawurl, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "ws", "wss":
+		default:
+			return fmt.Errorf("invalid rpc endpoint %q: unsupported scheme", rawurl)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("invalid rpc endpoint %q: missing host", rawurl)
+		}
+	}
+	blob, err := rlp.EncodeToBytes(e)
+	if err != nil {
+		return err
+	}
+	if len(blob) > maxENREntrySize {
+		return errENREntryTooLarge
+	}
+	return nil
+}
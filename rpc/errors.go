@@ -18,6 +18,13 @@ package rpc
 
 import "fmt"
 
+// NewDataError creates an error answered with the given JSON-RPC error code and
+// data, for services returning structured errors without defining their own
+// types. The data is encoded as JSON along with the message.
+func NewDataError(code int, message string, data interface{}) error {
+	return &jsonError{Code: code, Message: message, Data: data}
+}
+
 var (
 	_ Error = new(MethodNotFoundError)
 	_ Error = new(subscriptionNotFoundError)
@@ -25,10 +32,29 @@ var (
 	_ Error = new(invalidRequestError)
 	_ Error = new(invalidMessageError)
 	_ Error = new(invalidParamsError)
+	_ Error = new(internalServerError)
+	_ Error = new(unauthorizedError)
+	_ Error = new(rateLimitError)
+	_ Error = new(methodDeniedError)
+
+	_ DataError = new(jsonError)
+	_ DataError = new(rateLimitError)
 )
 
 const defaultErrorCode = -32000
 
+const (
+	errcodeUnauthorized     = -32001
+	errcodeTimeout          = -32002
+	errcodeResponseTooLarge = -32003
+	errcodeMethodDenied     = -32004
+	errcodeRateLimited      = -32005
+
+	errMsgTimeout          = "request timed out"
+	errMsgBatchTooLarge    = "batch too large"
+	errMsgResponseTooLarge = "response too large"
+)
+
 type MethodNotFoundError struct{ Method string }
 
 func (e *MethodNotFoundError) ErrorCode() int { return -32601 }
@@ -72,3 +98,13 @@ type invalidParamsError struct{ message string }
 func (e *invalidParamsError) ErrorCode() int { return -32602 }
 
 func (e *invalidParamsError) Error() string { return e.message }
+
+// internalServerError is used for server side errors during request processing.
+type internalServerError struct {
+	code    int
+	message string
+}
+
+func (e *internalServerError) ErrorCode() int { return e.code }
+
+func (e *internalServerError) Error() string { return e.message }
@@ -0,0 +1,308 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The gRPC transport carries the JSON-RPC messages in the json field of the
+// protobuf message of the service below, so any gRPC infrastructure can route
+// them without knowing about the APIs of the node:
+//
+//    syntax = "proto3";
+//    package orange.rpc;
+//
+//    service JSONRPC {
+//        // Call serves a single request or batch, like HTTP.
+//        rpc Call(Message) returns (Message);
+//        // Stream serves any number of requests and subscriptions, like websockets.
+//        rpc Stream(stream Message) returns (stream Message);
+//    }
+//
+//    message Message {
+//        bytes json = 1;
+//    }
+const (
+	grpcContentType = "application/grpc"
+	grpcCallPath    = "/orange.rpc.JSONRPC/Call"
+	grpcStreamPath  = "/orange.rpc.JSONRPC/Stream"
+
+	grpcHeaderSize = 5 // compression flag and message length
+)
+
+// gRPC status codes used by the server.
+const (
+	grpcStatusOK              = 0
+	grpcStatusUnimplemented   = 12
+	grpcStatusUnauthenticated = 16
+)
+
+var (
+	errGRPCCompressed  = errors.New("compressed gRPC messages are not supported")
+	errGRPCMessageSize = errors.New("gRPC message too large")
+)
+
+// IsGRPC reports whether the HTTP request is a gRPC call.
+func IsGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("content-type"), grpcContentType)
+}
+
+// ServeGRPC serves JSON-RPC requests over gRPC. The server must speak HTTP/2, over
+// TLS or in cleartext (h2c). The Call method serves a single request or batch in
+// the way of HTTP, while Stream serves the requests of a bidirectional stream in
+// the way of websockets, including subscriptions.
+func (s *Server) ServeGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !IsGRPC(r) {
+		http.Error(w, "gRPC requires HTTP/2 POST requests", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("content-type", r.Header.Get("content-type"))
+
+	identity, err := s.authenticate(r)
+	if err != nil {
+		writeGRPCStatus(w, grpcStatusUnauthenticated, err.Error())
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn := &grpcConn{r: r.Body, w: w, flush: flusher.Flush, identity: identity, remote: r.RemoteAddr}
+
+	switch r.URL.Path {
+	case grpcCallPath:
+		ctx := context.WithValue(r.Context(), "remote", r.RemoteAddr)
+		ctx = context.WithValue(ctx, "scheme", "grpc")
+		ctx = context.WithValue(ctx, "local", r.Host)
+		if identity != "" {
+			ctx = context.WithValue(ctx, authContextKey{}, identity)
+		}
+		s.serveSingleRequest(ctx, newGRPCCodec(conn))
+	case grpcStreamPath:
+		// Send the headers right away, the client may wait for them before
+		// sending its requests.
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		s.ServeCodec(newGRPCCodec(conn), 0)
+	default:
+		writeGRPCStatus(w, grpcStatusUnimplemented, fmt.Sprintf("unknown method %s", r.URL.Path))
+		return
+	}
+	w.Header().Set(http.TrailerPrefix+"grpc-status", fmt.Sprint(grpcStatusOK))
+}
+
+// writeGRPCStatus answers a call with a trailers-only response.
+func writeGRPCStatus(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("grpc-status", fmt.Sprint(code))
+	w.Header().Set("grpc-message", url.PathEscape(msg))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcConn is a gRPC stream carrying JSON-RPC messages.
+type grpcConn struct {
+	r        io.Reader
+	w        io.Writer
+	flush    func()
+	closer   func()
+	identity string // authenticated client, if any
+	remote   string
+
+	closeOnce sync.Once
+}
+
+func newGRPCCodec(conn *grpcConn) ServerCodec {
+	return NewFuncCodec(conn, conn.writeMessage, conn.readMessage)
+}
+
+// writeMessage writes a JSON value as a gRPC message.
+func (c *grpcConn) writeMessage(v interface{}) error {
+	enc, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	msg := protowire.AppendTag(make([]byte, grpcHeaderSize, grpcHeaderSize+len(enc)+16), 1, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, enc)
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(msg)-grpcHeaderSize))
+
+	if _, err := c.w.Write(msg); err != nil {
+		return err
+	}
+	if c.flush != nil {
+		c.flush()
+	}
+	return nil
+}
+
+// readMessage reads a gRPC message, decoding its JSON content into v.
+func (c *grpcConn) readMessage(v interface{}) error {
+	var header [grpcHeaderSize]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return io.EOF
+		}
+		return err
+	}
+	if header[0] != 0 {
+		return errGRPCCompressed
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxRequestContentLength {
+		return errGRPCMessageSize
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(c.r, msg); err != nil {
+		return err
+	}
+	// Find the json field, skipping any other.
+	var content []byte
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if num == 1 && typ == protowire.BytesType {
+			content, n = protowire.ConsumeBytes(msg)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+	}
+	return json.Unmarshal(content, v)
+}
+
+// Close ends the stream of a client, it does nothing for servers.
+func (c *grpcConn) Close() error {
+	if c.closer != nil {
+		c.closeOnce.Do(c.closer)
+	}
+	return nil
+}
+
+// SetWriteDeadline does nothing and always returns nil.
+func (c *grpcConn) SetWriteDeadline(time.Time) error { return nil }
+
+// RemoteAddr returns the peer address of the stream.
+func (c *grpcConn) RemoteAddr() string { return c.remote }
+
+// authIdentity returns the authenticated client of the stream, if any.
+func (c *grpcConn) authIdentity() string { return c.identity }
+
+// DialGRPC creates a new RPC client that communicates with a JSON-RPC server over
+// gRPC. The endpoint is the URL of the server: http endpoints are reached over
+// cleartext HTTP/2, https ones over TLS. The calls of the client are served over
+// a single bidirectional stream, which supports subscriptions.
+//
+// The context is used for the initial connection establishment. It does not
+// affect subsequent interactions with the client.
+func DialGRPC(ctx context.Context, endpoint string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	transport := new(http2.Transport)
+	switch u.Scheme {
+	case "http":
+		// Dial plain TCP connections, the endpoint speaks HTTP/2 in cleartext.
+		transport.AllowHTTP = true
+		transport.DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		}
+	case "https":
+	default:
+		return nil, fmt.Errorf("unsupported gRPC endpoint scheme %q", u.Scheme)
+	}
+	target := strings.TrimSuffix(endpoint, "/") + grpcStreamPath
+
+	return newClient(ctx, func(ctx context.Context) (ServerCodec, error) {
+		return dialGRPCStream(ctx, transport, target)
+	})
+}
+
+// dialGRPCStream opens a stream to the server.
+func dialGRPCStream(ctx context.Context, transport *http2.Transport, target string) (ServerCodec, error) {
+	body, w := io.Pipe()
+	streamCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodPost, target, body)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("content-type", grpcContentType+"+proto")
+	req.Header.Set("te", "trailers")
+
+	// The stream outlives the dial context, abort it separately.
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := transport.RoundTrip(req)
+		done <- result{resp, err}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+	if res.err != nil {
+		cancel()
+		return nil, res.err
+	}
+	if status := res.resp.Header.Get("grpc-status"); res.resp.StatusCode != http.StatusOK || (status != "" && status != "0") {
+		res.resp.Body.Close()
+		cancel()
+		if msg, err := url.PathUnescape(res.resp.Header.Get("grpc-message")); err == nil && msg != "" {
+			return nil, fmt.Errorf("gRPC stream refused: %s", msg)
+		}
+		return nil, fmt.Errorf("gRPC stream refused: %s", res.resp.Status)
+	}
+	conn := &grpcConn{
+		r:      res.resp.Body,
+		w:      w,
+		remote: target,
+		closer: func() {
+			w.Close()
+			res.resp.Body.Close()
+			cancel()
+		},
+	}
+	return newGRPCCodec(conn), nil
+}
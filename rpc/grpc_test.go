@@ -0,0 +1,126 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func newGRPCTestServer(server *Server) *httptest.Server {
+	return httptest.NewServer(h2c.NewHandler(http.HandlerFunc(server.ServeGRPC), new(http2.Server)))
+}
+
+// Tests that calls and subscriptions are served over gRPC streams.
+func TestGRPCStream(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	httpsrv := newGRPCTestServer(server)
+	defer httpsrv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := DialGRPC(ctx, httpsrv.URL)
+	if err != nil {
+		t.Fatal("can't dial:", err)
+	}
+	defer client.Close()
+
+	var result echoResult
+	if err := client.CallContext(ctx, &result, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+		t.Fatal("call failed:", err)
+	}
+	if want := (echoResult{"hello", 10, &echoArgs{"world"}}); !reflect.DeepEqual(result, want) {
+		t.Errorf("wrong result: have %v, want %v", result, want)
+	}
+	nc := make(chan int)
+	sub, err := client.Subscribe(ctx, "nftest", nc, "someSubscription", 5, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	defer sub.Unsubscribe()
+	for i := 0; i < 5; i++ {
+		select {
+		case val := <-nc:
+			if val != i {
+				t.Fatalf("value mismatch: have %d, want %d", val, i)
+			}
+		case err := <-sub.Err():
+			t.Fatal("subscription failed:", err)
+		case <-ctx.Done():
+			t.Fatal("notification timeout")
+		}
+	}
+}
+
+// Tests that the Call method serves a single request in a unary gRPC call.
+func TestGRPCCall(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	httpsrv := newGRPCTestServer(server)
+	defer httpsrv.Close()
+
+	transport := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+	call := func(path string, req []byte) (*http.Response, []byte) {
+		body := new(bytes.Buffer)
+		(&grpcConn{w: body}).writeMessage(json.RawMessage(req))
+		hreq, _ := http.NewRequest(http.MethodPost, httpsrv.URL+path, body)
+		hreq.Header.Set("content-type", "application/grpc")
+		resp, err := transport.RoundTrip(hreq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var answer json.RawMessage
+		if err := (&grpcConn{r: resp.Body}).readMessage(&answer); err != nil && path == grpcCallPath {
+			t.Fatal("can't read answer:", err)
+		}
+		// Consume the rest of the body to receive the trailers.
+		io.Copy(ioutil.Discard, resp.Body)
+		return resp, answer
+	}
+	resp, answer := call(grpcCallPath, []byte(`{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`))
+	if want := `{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}}`; string(answer) != want {
+		t.Errorf("wrong answer: have %s, want %s", answer, want)
+	}
+	if status := resp.Trailer.Get("grpc-status"); status != "0" {
+		t.Errorf("wrong status: %q", status)
+	}
+	resp, _ = call("/orange.rpc.JSONRPC/Unknown", []byte(`{}`))
+	if status := resp.Header.Get("grpc-status"); status != "12" {
+		t.Errorf("wrong status of unknown method: %q", status)
+	}
+}
@@ -19,10 +19,12 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ong2020/go-orange/log"
@@ -61,17 +63,35 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	session        *Session             // persistent connection state, exposed if subscriptions are allowed
+	limits         batchLimits          // size limits of the batches served
+	batcher        *notificationBatcher // coalesces the notifications, if enabled
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
 }
 
+// batchLimits are the size limits of the batch requests served by a handler.
+type batchLimits struct {
+	items        int // Maximum number of requests in a batch (0 = unlimited)
+	responseSize int // Maximum total size of the batch results in bytes (0 = unlimited)
+}
+
 type callProc struct {
 	ctx       context.Context
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, limits batchLimits) *handler {
+	// Expose the remote address of long-lived connections (e.g. websocket) to
+	// the method handlers, as done for every HTTP request.
+	if _, ok := connCtx.Value("remote").(string); !ok && conn.remoteAddr() != "" {
+		connCtx = context.WithValue(connCtx, "remote", conn.remoteAddr())
+	}
+	// Likewise expose the client authenticated when the connection was opened.
+	if conn, ok := conn.(interface{ authIdentity() string }); ok && conn.authIdentity() != "" {
+		connCtx = context.WithValue(connCtx, authContextKey{}, conn.authIdentity())
+	}
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
 		reg:            reg,
@@ -83,7 +103,12 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 		cancelRoot:     cancelRoot,
 		allowSubscribe: true,
 		serverSubs:     make(map[ID]*Subscription),
+		session:        &Session{ID: NewID(), done: rootCtx.Done()},
 		log:            log.Root(),
+		limits:         limits,
+	}
+	if config := reg.notifyBatching(); config.window > 0 {
+		h.batcher = newNotificationBatcher(conn, config, &reg.stats.queued)
 	}
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
@@ -101,6 +126,22 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		})
 		return
 	}
+	// Reject the whole batch if it holds too many requests, the protocol has no
+	// way to report an error for the entire batch so it's sent as the answer to
+	// its first call.
+	if h.limits.items > 0 && len(msgs) > h.limits.items {
+		h.startCallProc(func(cp *callProc) {
+			resp := errorMessage(&invalidRequestError{errMsgBatchTooLarge})
+			for _, msg := range msgs {
+				if msg.isCall() {
+					resp.ID = msg.ID
+					break
+				}
+			}
+			h.conn.writeJSON(cp.ctx, []*jsonrpcMessage{resp})
+		})
+		return
+	}
 
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
@@ -114,10 +155,27 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
-		for _, msg := range calls {
+		var (
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			size    int
+		)
+		for i, msg := range calls {
+			// Once the results outgrow the limit, answer the remaining calls
+			// with an error instead of executing them.
+			if h.limits.responseSize > 0 && size > h.limits.responseSize {
+				for _, msg := range calls[i:] {
+					if msg.isCall() {
+						answers = append(answers, msg.errorResponse(&internalServerError{errcodeResponseTooLarge, errMsgResponseTooLarge}))
+					}
+				}
+				break
+			}
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
+				if answer.stream != nil {
+					answer = answer.collect(cp.ctx)
+				}
 				answers = append(answers, answer)
+				size += len(answer.Result)
 			}
 		}
 		h.addSubscriptions(cp.notifiers)
@@ -139,7 +197,7 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 		answer := h.handleCallMsg(cp, msg)
 		h.addSubscriptions(cp.notifiers)
 		if answer != nil {
-			h.conn.writeJSON(cp.ctx, answer)
+			h.writeAnswer(cp.ctx, answer)
 		}
 		for _, n := range cp.notifiers {
 			n.activate()
@@ -147,6 +205,29 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	})
 }
 
+// writeAnswer sends the answer to a single call, writing streamed results
+// incrementally if the connection supports it.
+func (h *handler) writeAnswer(ctx context.Context, answer *jsonrpcMessage) {
+	if answer.stream == nil {
+		h.conn.writeJSON(ctx, answer)
+		return
+	}
+	sw, ok := h.conn.(streamWriter)
+	if !ok {
+		h.conn.writeJSON(ctx, answer.collect(ctx))
+		return
+	}
+	sent, err := sw.writeStream(ctx, func(w io.Writer) error { return answer.writeStream(ctx, w) })
+	switch {
+	case err == nil:
+	case !sent:
+		// Nothing was sent yet, answer with the error alone
+		h.conn.writeJSON(ctx, answer.errorResponse(err))
+	default:
+		h.log.Debug("Aborted streamed result", "id", string(answer.ID), "err", err)
+	}
+}
+
 // close cancels all requests except for inflightReq and waits for
 // call goroutines to shut down.
 func (h *handler) close(err error, inflightReq *requestOp) {
@@ -154,6 +235,9 @@ func (h *handler) close(err error, inflightReq *requestOp) {
 	h.callWG.Wait()
 	h.cancelRoot()
 	h.cancelServerSubscriptions(err)
+	if h.batcher != nil {
+		h.batcher.stop()
+	}
 }
 
 // addRequestOp registers a request operation.
@@ -200,6 +284,7 @@ func (h *handler) addSubscriptions(nn []*Notifier) {
 	for _, n := range nn {
 		if sub := n.takeSubscription(); sub != nil {
 			h.serverSubs[sub.ID] = sub
+			atomic.AddInt64(&h.reg.stats.subscriptions, 1)
 		}
 	}
 }
@@ -213,6 +298,7 @@ func (h *handler) cancelServerSubscriptions(err error) {
 		s.err <- err
 		close(s.err)
 		delete(h.serverSubs, id)
+		atomic.AddInt64(&h.reg.stats.subscriptions, -1)
 	}
 }
 
@@ -221,6 +307,9 @@ func (h *handler) startCallProc(fn func(*callProc)) {
 	h.callWG.Add(1)
 	go func() {
 		ctx, cancel := context.WithCancel(h.rootCtx)
+		if h.allowSubscribe {
+			ctx = context.WithValue(ctx, sessionKey{}, h.session)
+		}
 		defer h.callWG.Done()
 		defer cancel()
 		fn(&callProc{ctx: ctx})
@@ -254,8 +343,9 @@ func (h *handler) handleSubscriptionResult(msg *jsonrpcMessage) {
 		h.log.Debug("Dropping invalid subscription message")
 		return
 	}
-	if h.clientSubs[result.ID] != nil {
-		h.clientSubs[result.ID].deliver(result.Result)
+	if sub := h.clientSubs[result.ID]; sub != nil {
+		sub.client.intercept(&CallEvent{Method: msg.Method, Notification: true, ResponseSize: len(result.Result)})
+		sub.deliver(result.Result)
 	}
 }
 
@@ -291,11 +381,12 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	start := time.Now()
 	switch {
 	case msg.isNotification():
-		h.handleCall(ctx, msg)
+		h.handleHookedCall(ctx, msg)
 		h.log.Debug("Served "+msg.Method, "t", time.Since(start))
 		return nil
 	case msg.isCall():
-		resp := h.handleCall(ctx, msg)
+		resp := h.handleHookedCall(ctx, msg)
+		h.reg.stats.recordCall(resp.Error != nil)
 		var ctx []interface{}
 		ctx = append(ctx, "reqid", idForLog{msg.ID}, "t", time.Since(start))
 		if resp.Error != nil {
@@ -315,11 +406,51 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	}
 }
 
+// handleHookedCall processes Method calls, reporting them to the hook of the
+// server if any.
+func (h *handler) handleHookedCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	hook := h.reg.callHook()
+	if hook == nil {
+		return h.handleCall(cp, msg)
+	}
+	call := newCallInfo(cp.ctx, msg)
+	hook.OnRequest(cp.ctx, call)
+	start := time.Now()
+	answer := h.handleCall(cp, msg)
+
+	// Report streamed results once they are produced
+	if stream := answer.stream; stream != nil {
+		answer.stream = func(ctx context.Context, yield func(interface{}) error) error {
+			err := stream(ctx, yield)
+			hook.OnResponse(cp.ctx, call, time.Since(start), err)
+			return err
+		}
+		return answer
+	}
+	var err error
+	if answer.Error != nil {
+		err = answer.Error
+	}
+	hook.OnResponse(cp.ctx, call, time.Since(start), err)
+	return answer
+}
+
 // handleCall processes Method calls.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if !msg.isUnsubscribe() {
+		if !h.reg.rules.allowed(msg.Method) {
+			return msg.errorResponse(&methodDeniedError{msg.Method})
+		}
+		if err := h.reg.limiter.allow(cp.ctx, msg.Method); err != nil {
+			return msg.errorResponse(err)
+		}
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
+	if name, ok := msg.directSubscription(); ok && h.reg.subscription(msg.namespace(), name) != nil {
+		return h.handleSubscribe(cp, msg.subscribeCall(name))
+	}
 	var callb *callback
 	if msg.isUnsubscribe() {
 		callb = h.unsubscribeCb
@@ -329,12 +460,32 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if callb == nil {
 		return msg.errorResponse(&MethodNotFoundError{Method: msg.Method})
 	}
-	args, err := parsePositionalArguments(msg.Params, callb.argTypes)
+	if callb != h.unsubscribeCb && !h.reg.authorized(cp.ctx, msg.namespace()) {
+		return msg.errorResponse(&unauthorizedError{msg.namespace()})
+	}
+	args, err := callb.parseArguments(msg.Params)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
+	ctx, cancel := cp.ctx, context.CancelFunc(func() {})
+	if timeout := h.reg.timeout(msg.Method); timeout > 0 && callb != h.unsubscribeCb {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
 	start := time.Now()
-	answer := h.runMethod(cp.ctx, msg, callb, args)
+	answer := h.runMethod(ctx, msg, callb, args)
+
+	// Streams are produced after the method returns, keep them within its deadline
+	if answer.stream != nil {
+		answer.stream = answer.stream.bound(ctx, cancel)
+	} else {
+		cancel()
+	}
+
+	// Report calls aborted by their own deadline as timed out, not by the one of
+	// the connection.
+	if answer.Error != nil && ctx.Err() == context.DeadlineExceeded && cp.ctx.Err() == nil {
+		answer = msg.errorResponse(&internalServerError{errcodeTimeout, errMsgTimeout})
+	}
 
 	// Collect the statistics for RPC calls if metrics is enabled.
 	// We only care about pure rpc call. Filter out subscription.
@@ -345,8 +496,10 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 		} else {
 			successfulRequestGauge.Inc(1)
 		}
-		rpcServingTimer.UpdateSince(start)
-		newRPCServingTimer(msg.Method, answer.Error == nil).UpdateSince(start)
+		elapsed := time.Since(start)
+		rpcServingTimer.Update(elapsed)
+		newRPCServingTimer(msg.Method, answer.Error == nil).Update(elapsed)
+		updateMethodMetrics(msg.Method, answer.Error != nil, elapsed)
 	}
 	return answer
 }
@@ -367,10 +520,13 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 	if callb == nil {
 		return msg.errorResponse(&subscriptionNotFoundError{namespace, name})
 	}
+	if !h.reg.authorized(cp.ctx, namespace) {
+		return msg.errorResponse(&unauthorizedError{namespace})
+	}
 
 	// Parse subscription name arg too, but remove it before calling the callback.
 	argTypes := append([]reflect.Type{stringType}, callb.argTypes...)
-	args, err := parsePositionalArguments(msg.Params, argTypes)
+	args, err := parsePositionalArguments(msg.Params, argTypes, callb.isVariadic)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
@@ -390,6 +546,12 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 	if err != nil {
 		return msg.errorResponse(err)
 	}
+	if stream, ok := result.(Stream); ok {
+		if stream == nil {
+			return msg.response([]interface{}{})
+		}
+		return &jsonrpcMessage{Version: vsn, ID: msg.ID, stream: stream}
+	}
 	return msg.response(result)
 }
 
@@ -404,6 +566,7 @@ func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	}
 	close(s.err)
 	delete(h.serverSubs, id)
+	atomic.AddInt64(&h.reg.stats.subscriptions, -1)
 	return true, nil
 }
 
@@ -0,0 +1,100 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// digestKey keys the digests of the call parameters. It is generated anew by
+// every process, so that the digests can't be matched against the digests of
+// guessed parameters, e.g. common passwords, outside of the process.
+var digestKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("can't generate rpc digest key: " + err.Error())
+	}
+	return key
+}()
+
+// CallInfo describes a call served by a server.
+type CallInfo struct {
+	Method       string // Name of the method called, e.g. admin_addPeer
+	ParamsDigest string // Hex encoded keyed digest of the raw JSON parameters
+	Identity     string // Authenticated client, empty if the client did not authenticate
+	Remote       string // Remote address of the client, empty for in-process calls
+}
+
+// Hook observes the calls served by a server, e.g. to keep an audit trail of the
+// use of the sensitive namespaces. The parameters of the calls are only passed
+// as a digest, so credentials such as account passwords don't end up in the
+// trail. The digest is an HMAC-SHA256 under a key private to the process: equal
+// parameters have equal digests for the lifetime of the process, but the digests
+// can't be brute forced offline.
+//
+// The hook is called synchronously by the goroutine serving the call, from
+// multiple goroutines at once. It should return quickly.
+type Hook interface {
+	// OnRequest is called before the call is executed.
+	OnRequest(ctx context.Context, call *CallInfo)
+
+	// OnResponse is called once the call is answered, with the time taken to
+	// execute it and the error it failed with, if any.
+	OnResponse(ctx context.Context, call *CallInfo, duration time.Duration, err error)
+}
+
+// SetHook installs a hook observing the calls served by the server, including
+// the ones rejected before execution. Without hook, the calls are served at no
+// extra cost.
+//
+// The hook may be replaced or removed (nil) while the server is running.
+func (s *Server) SetHook(hook Hook) {
+	s.services.hook.Store(hookSlot{hook})
+}
+
+// hookSlot wraps the hook of a registry, as atomic.Value can't hold a nil
+// interface.
+type hookSlot struct{ Hook }
+
+// callHook returns the hook observing the calls, nil if none.
+func (r *serviceRegistry) callHook() Hook {
+	slot, _ := r.hook.Load().(hookSlot)
+	return slot.Hook
+}
+
+// paramsDigest computes the keyed digest of the raw parameters of a call.
+func paramsDigest(params []byte) string {
+	mac := hmac.New(sha256.New, digestKey)
+	mac.Write(params)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newCallInfo describes a call message for the hook.
+func newCallInfo(ctx context.Context, msg *jsonrpcMessage) *CallInfo {
+	remote, _ := ctx.Value("remote").(string)
+	return &CallInfo{
+		Method:       msg.Method,
+		ParamsDigest: paramsDigest(msg.Params),
+		Identity:     AuthIdentity(ctx),
+		Remote:       remote,
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingHook records the calls reported to it.
+type recordingHook struct {
+	mu        sync.Mutex
+	requests  []CallInfo
+	responses []CallInfo
+	errors    []error
+}
+
+func (h *recordingHook) OnRequest(ctx context.Context, call *CallInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.requests = append(h.requests, *call)
+}
+
+func (h *recordingHook) OnResponse(ctx context.Context, call *CallInfo, duration time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.responses = append(h.responses, *call)
+	h.errors = append(h.errors, err)
+}
+
+// Tests that the hook of the server is called for every call, with the identity
+// of the caller and the outcome of the call.
+func TestServerHook(t *testing.T) {
+	server := newTestServer()
+	server.SetAuthenticator(NewAPIKeyAuthenticator(map[string]string{"key": "alice"}), nil)
+	hook := new(recordingHook)
+	server.SetHook(hook)
+	defer server.Stop()
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+	client, err := DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetHeader("X-API-Key", "key")
+
+	var resp echoResult
+	if err := client.Call(&resp, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if err := client.Call(nil, "test_returnError"); err == nil {
+		t.Fatalf("failing call succeeded")
+	}
+	if err := client.Call(nil, "test_missing"); err == nil {
+		t.Fatalf("call to missing method succeeded")
+	}
+	if len(hook.requests) != 3 || len(hook.responses) != 3 {
+		t.Fatalf("hook call count mismatch: have %d requests and %d responses, want 3", len(hook.requests), len(hook.responses))
+	}
+	mac := hmac.New(sha256.New, digestKey)
+	mac.Write([]byte(`["hello",10,{"S":"world"}]`))
+	want := CallInfo{
+		Method:       "test_echo",
+		ParamsDigest: hex.EncodeToString(mac.Sum(nil)),
+		Identity:     "alice",
+		Remote:       hook.requests[0].Remote,
+	}
+	if hook.requests[0] != want || hook.responses[0] != want {
+		t.Errorf("call info mismatch: have %+v, want %+v", hook.requests[0], want)
+	}
+	if want.Remote == "" {
+		t.Errorf("remote address not reported")
+	}
+	for i, method := range []string{"test_echo", "test_returnError", "test_missing"} {
+		if hook.responses[i].Method != method {
+			t.Errorf("call %d: method mismatch: have %s, want %s", i, hook.responses[i].Method, method)
+		}
+		if failed := hook.errors[i] != nil; failed != (i > 0) {
+			t.Errorf("call %d: error mismatch: have %v", i, hook.errors[i])
+		}
+	}
+}
+
+// Tests that the hook can be installed and removed while calls are served.
+func TestServerHookConcurrentInstall(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				var resp echoResult
+				if err := client.Call(&resp, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+					t.Errorf("call failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	hook := new(recordingHook)
+	for j := 0; j < 50; j++ {
+		server.SetHook(hook)
+		server.SetHook(nil)
+	}
+	wg.Wait()
+
+	server.SetHook(hook)
+	if err := client.Call(nil, "test_echo", "hello", 10, &echoArgs{"world"}); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if len(hook.responses) == 0 {
+		t.Fatal("installed hook not called")
+	}
+}
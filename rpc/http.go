@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -40,12 +41,13 @@ const (
 var acceptedContentTypes = []string{contentType, "application/json-rpc", "application/jsonrequest"}
 
 type httpConn struct {
-	client    *http.Client
-	url       string
-	closeOnce sync.Once
-	closeCh   chan interface{}
-	mu        sync.Mutex // protects headers
-	headers   http.Header
+	client     *http.Client
+	url        string
+	closeOnce  sync.Once
+	closeCh    chan interface{}
+	mu         sync.Mutex // protects headers and propagator
+	headers    http.Header
+	propagator TracePropagator // adds the trace context of the calls, if any
 }
 
 // httpConn is treated specially by Client.
@@ -173,7 +175,25 @@ func (c *Client) sendBatchHTTP(ctx context.Context, op *requestOp, msgs []*jsonr
 }
 
 func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadCloser, error) {
-	body, err := json.Marshal(msg)
+	hc.mu.Lock()
+	headers, propagator := hc.headers.Clone(), hc.propagator
+	hc.mu.Unlock()
+
+	if propagator != nil {
+		propagator(ctx, headers)
+	}
+
+	// Requests are sent in the binary encoding of their content type, if any.
+	var (
+		body []byte
+		err  error
+	)
+	if format := formatOf(headers.Get("content-type")); format != nil {
+		body, err = format.encode(msg)
+		headers.Set("accept", format.contentType)
+	} else {
+		body, err = json.Marshal(msg)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -182,11 +202,7 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 		return nil, err
 	}
 	req.ContentLength = int64(len(body))
-
-	// set headers
-	hc.mu.Lock()
-	req.Header = hc.headers.Clone()
-	hc.mu.Unlock()
+	req.Header = headers
 
 	// do request
 	resp, err := hc.client.Do(req)
@@ -196,6 +212,15 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return resp.Body, errors.New(resp.Status)
 	}
+	// Binary responses are converted back to JSON for the caller.
+	if format := formatOf(resp.Header.Get("content-type")); format != nil {
+		defer resp.Body.Close()
+		msg, err := format.toJSON(bufio.NewReader(resp.Body))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(msg)), nil
+	}
 	return resp.Body, nil
 }
 
@@ -209,6 +234,9 @@ type httpServerConn struct {
 func newHTTPServerConn(r *http.Request, w http.ResponseWriter) ServerCodec {
 	body := io.LimitReader(r.Body, maxRequestContentLength)
 	conn := &httpServerConn{Reader: body, Writer: w, r: r}
+	if format := formatOf(r.Header.Get("content-type")); format != nil {
+		return newBinaryCodec(conn, format)
+	}
 	return NewCodec(conn)
 }
 
@@ -234,6 +262,11 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), code)
 		return
 	}
+	identity, err := s.authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
 	// All checks passed, create a codec that reads directly from the request body
 	// until EOF, writes the response to w, and orders the server to process a
 	// single request.
@@ -247,8 +280,16 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if origin := r.Header.Get("Origin"); origin != "" {
 		ctx = context.WithValue(ctx, "Origin", origin)
 	}
+	if identity != "" {
+		ctx = context.WithValue(ctx, authContextKey{}, identity)
+	}
 
-	w.Header().Set("content-type", contentType)
+	// Answer binary requests in the same encoding.
+	if format := formatOf(r.Header.Get("content-type")); format != nil {
+		w.Header().Set("content-type", format.contentType)
+	} else {
+		w.Header().Set("content-type", contentType)
+	}
 	codec := newHTTPServerConn(r, w)
 	defer codec.close()
 	s.serveSingleRequest(ctx, codec)
@@ -275,6 +316,9 @@ func validateRequest(r *http.Request) (int, error) {
 				return 0, nil
 			}
 		}
+		if binaryFormats[mt] != nil {
+			return 0, nil
+		}
 	}
 	// Invalid content-type
 	err := fmt.Errorf("invalid content type, only %s is supported", contentType)
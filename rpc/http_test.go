@@ -17,6 +17,7 @@
 package rpc
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -123,3 +124,69 @@ func TestHTTPRespBodyUnlimited(t *testing.T) {
 		t.Fatalf("response has wrong length %d, want %d", len(r), respLength)
 	}
 }
+
+// Tests that the trace context of the calls is sent in the W3C headers, every
+// request as a new span of the trace.
+func TestHTTPTracePropagation(t *testing.T) {
+	var headers []http.Header
+	server := newTestServer()
+	defer server.Stop()
+	httpsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		server.ServeHTTP(w, r)
+	}))
+	defer httpsrv.Close()
+
+	client, err := DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetTracePropagator(PropagateTraceContext)
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parent  = "00-" + traceID + "-00f067aa0ba902b7-01"
+	)
+	ctx, err := ContextWithTraceParent(context.Background(), parent, "vendor=value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := client.CallContext(ctx, nil, "test_echo", "x", 1, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.CallContext(context.Background(), nil, "test_echo", "x", 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	spans := make(map[string]bool)
+	for i, header := range headers[:2] {
+		tp := header.Get("traceparent")
+		if !strings.HasPrefix(tp, "00-"+traceID+"-") || !strings.HasSuffix(tp, "-01") || tp == parent {
+			t.Errorf("request %d: traceparent mismatch: %q", i, tp)
+		}
+		spans[tp] = true
+		if ts := header.Get("tracestate"); ts != "vendor=value" {
+			t.Errorf("request %d: tracestate mismatch: %q", i, ts)
+		}
+	}
+	if len(spans) != 2 {
+		t.Errorf("requests share their span")
+	}
+	if tp := headers[2].Get("traceparent"); tp != "" {
+		t.Errorf("untraced request has traceparent %q", tp)
+	}
+	for _, invalid := range []string{
+		"",
+		"00-" + traceID + "-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-" + traceID + "-0000000000000000-01",
+		"00-" + strings.ToUpper(traceID) + "-00f067aa0ba902b7-01",
+		"ff-" + traceID + "-00f067aa0ba902b7-01",
+	} {
+		if _, err := ContextWithTraceParent(context.Background(), invalid, ""); err == nil {
+			t.Errorf("invalid traceparent %q accepted", invalid)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ong2020/go-orange/metrics"
+)
+
+// CallEvent describes a JSON-RPC exchange performed by a client: either a call
+// made by the client, or a subscription notification received from the server.
+type CallEvent struct {
+	Method       string        // Method called, or notification method of the subscription
+	Notification bool          // Whether the event is a subscription notification
+	Duration     time.Duration // Time from sending the call until its response arrived (0 for notifications)
+	Err          error         // Error returned by the call, if any
+	RequestSize  int           // Size of the encoded call parameters in bytes
+	ResponseSize int           // Size of the encoded result or notification in bytes
+}
+
+// Interceptor is invoked by a client for every call it makes and for every
+// subscription notification it receives. It runs synchronously on the calling
+// goroutine (or on the read loop for notifications), so it must not block.
+type Interceptor func(event *CallEvent)
+
+// SetInterceptor installs a callback observing all the calls and subscription
+// notifications of the client, e.g. for logging or metrics. Passing nil removes
+// the installed one.
+func (c *Client) SetInterceptor(fn Interceptor) {
+	c.interceptor.Store(fn)
+}
+
+// intercept reports an event to the interceptor of the client, if any.
+func (c *Client) intercept(event *CallEvent) {
+	if fn, _ := c.interceptor.Load().(Interceptor); fn != nil {
+		fn(event)
+	}
+}
+
+// ChainInterceptors combines several interceptors, invoked in order for every
+// event.
+func ChainInterceptors(fns ...Interceptor) Interceptor {
+	return func(event *CallEvent) {
+		for _, fn := range fns {
+			fn(event)
+		}
+	}
+}
+
+// NewMetricsInterceptor creates an interceptor recording the calls of a client
+// in the given registry, the default one if nil: the meters and latency timer of
+// every method, <prefix>/<namespace>/<method>/{calls,errors,duration}, and the
+// meter of the notifications of every subscription, <prefix>/<namespace>/
+// <method>/notifications. Metrics must be enabled for anything to be recorded.
+func NewMetricsInterceptor(registry metrics.Registry, prefix string) Interceptor {
+	return func(event *CallEvent) {
+		if !metrics.Enabled {
+			return
+		}
+		name := prefix + "/" + strings.Replace(event.Method, serviceMethodSeparator, "/", 1)
+		if event.Notification {
+			metrics.GetOrRegisterMeter(name+"/notifications", registry).Mark(1)
+			return
+		}
+		metrics.GetOrRegisterMeter(name+"/calls", registry).Mark(1)
+		if event.Err != nil {
+			metrics.GetOrRegisterMeter(name+"/errors", registry).Mark(1)
+		}
+		metrics.GetOrRegisterTimer(name+"/duration", registry).Update(event.Duration)
+	}
+}
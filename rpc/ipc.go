@@ -19,11 +19,28 @@ package rpc
 import (
 	"context"
 	"net"
+	"os"
 
 	"github.com/ong2020/go-orange/log"
 	"github.com/ong2020/go-orange/p2p/netutil"
 )
 
+// defaultIPCMode is the file mode of the Unix sockets if none is configured.
+const defaultIPCMode = 0600
+
+// IPCConfig configures the access to an IPC endpoint. The settings apply to Unix
+// sockets, named pipes can't be configured.
+type IPCConfig struct {
+	Mode         os.FileMode // File mode of the socket (0 = 0600)
+	Group        string      // Group owning the socket, by name or id (empty = unchanged)
+	SELinuxLabel string      // SELinux security context of the socket, Linux only (empty = unchanged)
+}
+
+// isDefault reports whether the configuration leaves the default access.
+func (c IPCConfig) isDefault() bool {
+	return c == IPCConfig{}
+}
+
 // ServeListener accepts connections on l, serving JSON-RPC on them.
 func (s *Server) ServeListener(l net.Listener) error {
 	for {
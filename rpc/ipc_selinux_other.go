@@ -0,0 +1,26 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build darwin dragonfly freebsd nacl netbsd openbsd solaris
+
+package rpc
+
+import "errors"
+
+// setSELinuxLabel fails, SELinux is only supported on Linux.
+func setSELinuxLabel(path, label string) error {
+	return errors.New("SELinux labels are only supported on Linux")
+}
@@ -23,13 +23,15 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 
 	"github.com/ong2020/go-orange/log"
 )
 
 // ipcListen will create a Unix socket on the given endpoint.
-func ipcListen(endpoint string) (net.Listener, error) {
+func ipcListen(endpoint string, config IPCConfig) (net.Listener, error) {
 	if len(endpoint) > int(max_path_size) {
 		log.Warn(fmt.Sprintf("The ipc endpoint is longer than %d characters. ", max_path_size),
 			"endpoint", endpoint)
@@ -44,10 +46,45 @@ func ipcListen(endpoint string) (net.Listener, error) {
 	if err != nil {
 		return nil, err
 	}
-	os.Chmod(endpoint, 0600)
+	if err := setIPCAccess(endpoint, config); err != nil {
+		l.Close()
+		return nil, err
+	}
 	return l, nil
 }
 
+// setIPCAccess sets the file mode, group and SELinux label of a Unix socket.
+func setIPCAccess(endpoint string, config IPCConfig) error {
+	mode := config.Mode
+	if mode == 0 {
+		mode = defaultIPCMode
+	}
+	if err := os.Chmod(endpoint, mode); err != nil {
+		return err
+	}
+	if config.Group != "" {
+		group, err := user.LookupGroup(config.Group)
+		if err != nil {
+			if group, err = user.LookupGroupId(config.Group); err != nil {
+				return fmt.Errorf("unknown IPC group %q", config.Group)
+			}
+		}
+		gid, err := strconv.Atoi(group.Gid)
+		if err != nil {
+			return err
+		}
+		if err := os.Chown(endpoint, -1, gid); err != nil {
+			return err
+		}
+	}
+	if config.SELinuxLabel != "" {
+		if err := setSELinuxLabel(endpoint, config.SELinuxLabel); err != nil {
+			return fmt.Errorf("failed to set IPC SELinux label: %v", err)
+		}
+	}
+	return nil
+}
+
 // newIPCConnection will connect to a Unix socket on the given endpoint.
 func newIPCConnection(ctx context.Context, endpoint string) (net.Conn, error) {
 	return new(net.Dialer).DialContext(ctx, "unix", endpoint)
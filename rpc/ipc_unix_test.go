@@ -0,0 +1,63 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build darwin dragonfly freebsd linux nacl netbsd openbsd solaris
+
+package rpc
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// Tests that the file mode and group of the Unix sockets are set as configured.
+func TestIPCAccess(t *testing.T) {
+	gid := os.Getgid()
+	tests := []struct {
+		config IPCConfig
+		mode   os.FileMode
+	}{
+		{IPCConfig{}, 0600},
+		{IPCConfig{Mode: 0660, Group: strconv.Itoa(gid)}, 0660},
+	}
+	for i, tt := range tests {
+		endpoint := filepath.Join(t.TempDir(), "test.ipc")
+		l, err := ipcListen(endpoint, tt.config)
+		if err != nil {
+			t.Fatalf("test %d: failed to listen: %v", i, err)
+		}
+		info, err := os.Stat(endpoint)
+		if err != nil {
+			t.Fatalf("test %d: failed to stat socket: %v", i, err)
+		}
+		if mode := info.Mode().Perm(); mode != tt.mode {
+			t.Errorf("test %d: mode mismatch: have %v, want %v", i, mode, tt.mode)
+		}
+		if have := int(info.Sys().(*syscall.Stat_t).Gid); have != gid {
+			t.Errorf("test %d: group mismatch: have %d, want %d", i, have, gid)
+		}
+		l.Close()
+	}
+	// Unknown groups are rejected
+	endpoint := filepath.Join(t.TempDir(), "test.ipc")
+	if l, err := ipcListen(endpoint, IPCConfig{Group: "go-orange-no-such-group"}); err == nil {
+		l.Close()
+		t.Fatal("listened with an unknown group")
+	}
+}
@@ -20,6 +20,7 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"net"
 	"time"
 
@@ -30,8 +31,12 @@ import (
 // defaultDialTimeout because named pipes are local and there is no need to wait so long.
 const defaultPipeDialTimeout = 2 * time.Second
 
-// ipcListen will create a named pipe on the given endpoint.
-func ipcListen(endpoint string) (net.Listener, error) {
+// ipcListen will create a named pipe on the given endpoint. The access to named
+// pipes can't be configured.
+func ipcListen(endpoint string, config IPCConfig) (net.Listener, error) {
+	if !config.isDefault() {
+		return nil, errors.New("rpc: IPC access can't be configured for named pipes")
+	}
 	return npipe.Listen(endpoint)
 }
 
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -33,6 +34,7 @@ const (
 	vsn                      = "2.0"
 	serviceMethodSeparator   = "_"
 	subscribeMethodSuffix    = "_subscribe"
+	subscribeMethodPrefix    = "subscribe"
 	unsubscribeMethodSuffix  = "_unsubscribe"
 	notificationMethodSuffix = "_subscription"
 
@@ -55,6 +57,8 @@ type jsonrpcMessage struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 	Error   *jsonError      `json:"error,omitempty"`
 	Result  json.RawMessage `json:"result,omitempty"`
+
+	stream Stream // result written incrementally, if any
 }
 
 func (msg *jsonrpcMessage) isNotification() bool {
@@ -81,6 +85,39 @@ func (msg *jsonrpcMessage) isUnsubscribe() bool {
 	return strings.HasSuffix(msg.Method, unsubscribeMethodSuffix)
 }
 
+// directSubscription returns the name of the subscription called directly as
+// <namespace>_subscribe<Name>, e.g. personal_subscribeWalletEvents, instead of
+// through <namespace>_subscribe.
+func (msg *jsonrpcMessage) directSubscription() (string, bool) {
+	elem := strings.SplitN(msg.Method, serviceMethodSeparator, 2)
+	if len(elem) != 2 || len(elem[1]) <= len(subscribeMethodPrefix) || !strings.HasPrefix(elem[1], subscribeMethodPrefix) {
+		return "", false
+	}
+	return elem[1], true
+}
+
+// subscribeCall returns the <namespace>_subscribe call equivalent to a direct
+// call of the named subscription.
+func (msg *jsonrpcMessage) subscribeCall(name string) *jsonrpcMessage {
+	call := *msg
+	call.Method = msg.namespace() + subscribeMethodSuffix
+
+	params := bytes.TrimSpace(msg.Params)
+	enc, _ := json.Marshal(name)
+	switch {
+	case len(params) == 0 || bytes.Equal(params, null):
+		call.Params = append(append([]byte{'['}, enc...), ']')
+	case params[0] == '[':
+		rest := bytes.TrimSpace(params[1:])
+		call.Params = append([]byte{'['}, enc...)
+		if len(rest) > 0 && rest[0] != ']' {
+			call.Params = append(call.Params, ',')
+		}
+		call.Params = append(call.Params, rest...)
+	}
+	return &call
+}
+
 func (msg *jsonrpcMessage) namespace() string {
 	elem := strings.SplitN(msg.Method, serviceMethodSeparator, 2)
 	return elem[0]
@@ -111,12 +148,14 @@ func errorMessage(err error) *jsonrpcMessage {
 		Code:    defaultErrorCode,
 		Message: err.Error(),
 	}}
-	ec, ok := err.(Error)
-	if ok {
+	// The code and data of wrapped errors are kept, the message being the one
+	// of the wrapping error.
+	var ec Error
+	if errors.As(err, &ec) {
 		msg.Error.Code = ec.ErrorCode()
 	}
-	de, ok := err.(DataError)
-	if ok {
+	var de DataError
+	if errors.As(err, &de) {
 		msg.Error.Data = de.ErrorData()
 	}
 	return msg
@@ -168,9 +207,13 @@ type jsonCodec struct {
 	closer  sync.Once                 // close closed channel once
 	closeCh chan interface{}          // closed on Close
 	decode  func(v interface{}) error // decoder to allow multiple transports
+	msgMu   sync.Mutex                // serializes the messages, held across the chunks of streams
 	encMu   sync.Mutex                // guards the encoder
 	encode  func(v interface{}) error // encoder to allow multiple transports
+	writer  io.Writer                 // connection written by streams, nil if unknown
 	conn    deadlineCloser
+
+	liveness connHealth // messages received, for keepalive
 }
 
 // NewFuncCodec creates a codec which uses the given functions to read and write. If conn
@@ -195,7 +238,9 @@ func NewCodec(conn Conn) ServerCodec {
 	enc := json.NewEncoder(conn)
 	dec := json.NewDecoder(conn)
 	dec.UseNumber()
-	return NewFuncCodec(conn, enc.Encode, dec.Decode)
+	codec := NewFuncCodec(conn, enc.Encode, dec.Decode).(*jsonCodec)
+	codec.writer = conn
+	return codec
 }
 
 func (c *jsonCodec) remoteAddr() string {
@@ -207,8 +252,13 @@ func (c *jsonCodec) readBatch() (messages []*jsonrpcMessage, batch bool, err err
 	// This verifies basic syntax, etc.
 	var rawmsg json.RawMessage
 	if err := c.decode(&rawmsg); err != nil {
+		if c.liveness.isDead() {
+			// Closed by the keepalive, report why rather than the read failure
+			return nil, false, ErrPeerUnresponsive
+		}
 		return nil, false, err
 	}
+	c.liveness.seen()
 	messages, batch = parseMessage(rawmsg)
 	for i, msg := range messages {
 		if msg == nil {
@@ -221,6 +271,8 @@ func (c *jsonCodec) readBatch() (messages []*jsonrpcMessage, batch bool, err err
 }
 
 func (c *jsonCodec) writeJSON(ctx context.Context, v interface{}) error {
+	c.msgMu.Lock()
+	defer c.msgMu.Unlock()
 	c.encMu.Lock()
 	defer c.encMu.Unlock()
 
@@ -239,6 +291,11 @@ func (c *jsonCodec) close() {
 	})
 }
 
+// health returns the liveness of the connection.
+func (c *jsonCodec) health() *connHealth {
+	return &c.liveness
+}
+
 // Closed returns a channel which will be closed when Close is called
 func (c *jsonCodec) closed() <-chan interface{} {
 	return c.closeCh
@@ -278,8 +335,10 @@ func isBatch(raw json.RawMessage) bool {
 
 // parsePositionalArguments tries to parse the given args to an array of values with the
 // given types. It returns the parsed values or an error when the args could not be
-// parsed. Missing optional arguments are returned as reflect.Zero values.
-func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]reflect.Value, error) {
+// parsed. Missing optional arguments are returned as reflect.Zero values. If variadic
+// is set, the last type is a slice and any number of trailing args are parsed as its
+// elements, each returned as a value of its own.
+func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type, variadic bool) ([]reflect.Value, error) {
 	dec := json.NewDecoder(bytes.NewReader(rawArgs))
 	var args []reflect.Value
 	tok, err := dec.Token()
@@ -291,13 +350,16 @@ func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]
 		return nil, err
 	case tok == json.Delim('['):
 		// Read argument array.
-		if args, err = parseArgumentArray(dec, types); err != nil {
+		if args, err = parseArgumentArray(dec, types, variadic); err != nil {
 			return nil, err
 		}
 	default:
 		return nil, errors.New("non-array args")
 	}
-	// Set any missing args to nil.
+	// Set any missing args to nil. Missing variadic args are simply left out.
+	if variadic {
+		types = types[:len(types)-1]
+	}
 	for i := len(args); i < len(types); i++ {
 		if types[i].Kind() != reflect.Ptr {
 			return nil, fmt.Errorf("missing value for required argument %d", i)
@@ -307,17 +369,87 @@ func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]
 	return args, nil
 }
 
-func parseArgumentArray(dec *json.Decoder, types []reflect.Type) ([]reflect.Value, error) {
+// parseArguments parses the params of a call to the callback, either positional
+// or, if the callback accepts them, named.
+func (c *callback) parseArguments(rawArgs json.RawMessage) ([]reflect.Value, error) {
+	if c.named == nil {
+		return parsePositionalArguments(rawArgs, c.argTypes, c.isVariadic)
+	}
+	var (
+		values []reflect.Value
+		err    error
+	)
+	if trimmed := bytes.TrimLeft(rawArgs, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		values, err = parseNamedArguments(rawArgs, c.named)
+	} else {
+		values, err = parsePositionalArguments(rawArgs, c.named.types, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	arg := reflect.New(c.named.typ)
+	for i, field := range c.named.fields {
+		arg.Elem().Field(field).Set(values[i])
+	}
+	if c.named.ptr {
+		return []reflect.Value{arg}, nil
+	}
+	return []reflect.Value{arg.Elem()}, nil
+}
+
+// parseNamedArguments parses a params object into the values of the named
+// parameters of a callback.
+func parseNamedArguments(rawArgs json.RawMessage, named *namedArgs) ([]reflect.Value, error) {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(rawArgs, &params); err != nil {
+		return nil, err
+	}
+	values := make([]reflect.Value, len(named.names))
+	for i, name := range named.names {
+		raw, ok := params[name]
+		delete(params, name)
+
+		if !ok || string(raw) == "null" {
+			if named.types[i].Kind() != reflect.Ptr {
+				return nil, fmt.Errorf("missing value for required argument %s", name)
+			}
+			values[i] = reflect.Zero(named.types[i])
+			continue
+		}
+		argval := reflect.New(named.types[i])
+		if err := json.Unmarshal(raw, argval.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid argument %s: %v", name, err)
+		}
+		values[i] = argval.Elem()
+	}
+	if len(params) > 0 {
+		unknown := make([]string, 0, len(params))
+		for name := range params {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown arguments %s", strings.Join(unknown, ", "))
+	}
+	return values, nil
+}
+
+func parseArgumentArray(dec *json.Decoder, types []reflect.Type, variadic bool) ([]reflect.Value, error) {
 	args := make([]reflect.Value, 0, len(types))
 	for i := 0; dec.More(); i++ {
-		if i >= len(types) {
+		var typ reflect.Type
+		switch {
+		case variadic && i >= len(types)-1:
+			typ = types[len(types)-1].Elem()
+		case i >= len(types):
 			return args, fmt.Errorf("too many arguments, want at most %d", len(types))
+		default:
+			typ = types[i]
 		}
-		argval := reflect.New(types[i])
+		argval := reflect.New(typ)
 		if err := dec.Decode(argval.Interface()); err != nil {
 			return args, fmt.Errorf("invalid argument %d: %v", i, err)
 		}
-		if argval.IsNil() && types[i].Kind() != reflect.Ptr {
+		if argval.IsNil() && typ.Kind() != reflect.Ptr {
 			return args, fmt.Errorf("missing value for required argument %d", i)
 		}
 		args = append(args, argval.Elem())
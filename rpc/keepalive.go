@@ -0,0 +1,181 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/ong2020/go-orange/log"
+)
+
+// heartbeatMethod is called by the clients to probe the liveness of the server
+// on the transports without ping frames. Any answer, even an error from servers
+// not knowing the method, proves the server alive.
+const heartbeatMethod = MetadataApi + "_ping"
+
+// ConnectionHealth reports the liveness of a connection, as seen by the keepalive
+// probes.
+type ConnectionHealth struct {
+	Alive    bool          `json:"alive"`              // Whether the peer answered the last probe in time
+	LastSeen time.Time     `json:"lastSeen,omitempty"` // Time the last message or probe answer was received
+	RTT      time.Duration `json:"rtt,omitempty"`      // Round trip time of the last answered probe
+}
+
+// connHealth tracks the liveness of a connection.
+type connHealth struct {
+	lastSeen int64 // Unix time in nanoseconds of the last received message (atomic)
+	rtt      int64 // Round trip time of the last answered probe (atomic)
+	dead     int32 // Set once the peer failed to answer a probe (atomic)
+}
+
+// currentConn holds the connection of a client, stored in an atomic.Value
+// whatever the type of the codec.
+type currentConn struct {
+	codec ServerCodec
+}
+
+// healthReporter is implemented by the codecs tracking the liveness of their
+// connection.
+type healthReporter interface {
+	health() *connHealth
+}
+
+// seen records a message received from the peer.
+func (h *connHealth) seen() {
+	atomic.StoreInt64(&h.lastSeen, time.Now().UnixNano())
+}
+
+// answered records the answer to a probe sent at the given time.
+func (h *connHealth) answered(sent time.Time) {
+	h.seen()
+	atomic.StoreInt64(&h.rtt, int64(time.Since(sent)))
+}
+
+// idle returns the time since the last message was received.
+func (h *connHealth) idle() time.Duration {
+	return time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&h.lastSeen))
+}
+
+// markDead records that the peer failed to answer a probe.
+func (h *connHealth) markDead() {
+	atomic.StoreInt32(&h.dead, 1)
+}
+
+// isDead reports whether the peer failed to answer a probe.
+func (h *connHealth) isDead() bool {
+	return atomic.LoadInt32(&h.dead) == 1
+}
+
+// report assembles the health report of the connection.
+func (h *connHealth) report() ConnectionHealth {
+	report := ConnectionHealth{
+		Alive: !h.isDead(),
+		RTT:   time.Duration(atomic.LoadInt64(&h.rtt)),
+	}
+	if seen := atomic.LoadInt64(&h.lastSeen); seen != 0 {
+		report.LastSeen = time.Unix(0, seen)
+	}
+	return report
+}
+
+// Health reports the liveness of the current connection of the client. HTTP
+// clients have no long-lived connection and are always reported alive.
+func (c *Client) Health() ConnectionHealth {
+	if current, ok := c.conn.Load().(currentConn); ok {
+		if codec, ok := current.codec.(healthReporter); ok {
+			return codec.health().report()
+		}
+	}
+	return ConnectionHealth{Alive: true}
+}
+
+// SetKeepalive enables heartbeats on the connections of the client without ping
+// frames, such as IPC ones. When no message is received for the given interval,
+// the client calls rpc_ping and closes the connection if the server doesn't
+// answer within timeout, failing the pending calls and subscriptions. The next
+// call reconnects. WebSocket connections are probed with ping frames instead,
+// configured by WebsocketConfig. A zero interval disables the heartbeats.
+//
+// This method should be called once, right after creating the client.
+func (c *Client) SetKeepalive(interval, timeout time.Duration) {
+	if c.isHTTP || interval <= 0 {
+		return
+	}
+	if timeout <= 0 {
+		timeout = interval
+	}
+	go c.heartbeatLoop(interval, timeout)
+}
+
+// heartbeatLoop probes the server whenever the connection has been idle for the
+// interval, closing the connection if the server doesn't answer in time.
+func (c *Client) heartbeatLoop(interval, timeout time.Duration) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.closing:
+			return
+		case <-timer.C:
+		}
+		current, ok := c.conn.Load().(currentConn)
+		if !ok {
+			// No connection established yet.
+			timer.Reset(interval)
+			continue
+		}
+		codec := current.codec
+		reporter, ok := codec.(healthReporter)
+		if _, ws := codec.(*websocketCodec); ws || !ok {
+			// Probed by ping frames, or not tracked.
+			timer.Reset(interval)
+			continue
+		}
+		health := reporter.health()
+		if idle := health.idle(); idle < interval {
+			timer.Reset(interval - idle)
+			continue
+		}
+		select {
+		case <-codec.closed():
+			// Wait for a call to reconnect.
+			timer.Reset(interval)
+			continue
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		sent := time.Now()
+		err := c.CallContext(ctx, nil, heartbeatMethod)
+		cancel()
+
+		switch {
+		case err == nil || errors.As(err, new(Error)):
+			health.answered(sent)
+		case errors.Is(err, ErrClientQuit):
+			return
+		case errors.Is(err, context.DeadlineExceeded):
+			log.Debug("RPC server unresponsive, closing connection", "conn", codec.remoteAddr(), "timeout", timeout)
+			health.markDead()
+			codec.close()
+		}
+		timer.Reset(interval)
+	}
+}
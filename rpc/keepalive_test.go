@@ -0,0 +1,212 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// waitHealth polls the health of a client until cond holds.
+func waitHealth(t *testing.T, client *Client, cond func(ConnectionHealth) bool) ConnectionHealth {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		health := client.Health()
+		if cond(health) {
+			return health
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("health condition not reached: %+v", health)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that websocket peers answering ping frames are reported alive, while
+// those not answering are closed, on both the client and the server side.
+func TestWebsocketKeepalive(t *testing.T) {
+	config := WebsocketConfig{PingInterval: 20 * time.Millisecond, PongTimeout: 100 * time.Millisecond}
+
+	srv := newTestServer()
+	httpsrv := httptest.NewServer(srv.WebsocketHandlerWithConfig([]string{"*"}, config))
+	wsURL := "ws:" + strings.TrimPrefix(httpsrv.URL, "http:")
+	defer srv.Stop()
+	defer httpsrv.Close()
+
+	client, err := DialWebsocketWithConfig(context.Background(), wsURL, "", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	health := waitHealth(t, client, func(h ConnectionHealth) bool { return h.RTT > 0 })
+	if !health.Alive || health.LastSeen.IsZero() {
+		t.Fatalf("responsive server not reported alive: %+v", health)
+	}
+
+	// A peer not answering pings is closed by the server.
+	dialer := websocket.Dialer{}
+	conn, _, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetPingHandler(func(string) error { return nil })
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.stats().Unresponsive != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("unresponsive peer not closed: %+v", srv.stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !client.Health().Alive {
+		t.Fatalf("responsive connection closed")
+	}
+}
+
+// Tests that a websocket server going silent, as behind a half-open connection,
+// fails the pending calls and the subscriptions of the client with
+// ErrPeerUnresponsive.
+func TestWebsocketDeadServer(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	httpsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Confirm the subscription, then ignore the requests and pings.
+		conn.SetPingHandler(func(string) error { return nil })
+		var req jsonrpcMessage
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		conn.WriteJSON(&jsonrpcMessage{Version: vsn, ID: req.ID, Result: json.RawMessage(`"0x1"`)})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer httpsrv.Close()
+
+	config := WebsocketConfig{PingInterval: 20 * time.Millisecond, PongTimeout: 100 * time.Millisecond}
+	client, err := DialWebsocketWithConfig(context.Background(), "ws:"+strings.TrimPrefix(httpsrv.URL, "http:"), "", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	sub, err := client.Subscribe(context.Background(), "nftest", make(chan int), "someSubscription", 1, 1)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	callErr := make(chan error, 1)
+	go func() { callErr <- client.Call(nil, "test_echo", "x", 1) }()
+
+	select {
+	case err := <-sub.Err():
+		if err != ErrPeerUnresponsive {
+			t.Errorf("subscription error mismatch: have %v, want %v", err, ErrPeerUnresponsive)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("subscription not failed")
+	}
+	select {
+	case err := <-callErr:
+		if err != ErrPeerUnresponsive {
+			t.Errorf("call error mismatch: have %v, want %v", err, ErrPeerUnresponsive)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("pending call not failed")
+	}
+	if client.Health().Alive {
+		t.Errorf("silent server reported alive")
+	}
+}
+
+// Tests that the heartbeats of the client detect unresponsive servers on the
+// transports without ping frames.
+func TestClientHeartbeat(t *testing.T) {
+	// A responsive server answers the heartbeats.
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	client.SetKeepalive(20*time.Millisecond, time.Second)
+	health := waitHealth(t, client, func(h ConnectionHealth) bool { return h.RTT > 0 })
+	if !health.Alive {
+		t.Fatalf("responsive server not reported alive: %+v", health)
+	}
+
+	// An unresponsive one is closed, and the calls fail.
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := p2.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	client = initClient(NewCodec(p1), randomIDGenerator(), new(serviceRegistry), batchLimits{})
+	defer client.Close()
+
+	client.SetKeepalive(20*time.Millisecond, 50*time.Millisecond)
+	waitHealth(t, client, func(h ConnectionHealth) bool { return !h.Alive })
+	if err := client.Call(nil, "test_echo", "x", 1); err == nil {
+		t.Fatalf("call succeeded over a dead connection")
+	}
+}
+
+// Tests that the heartbeats skip the ticks of a client without a connection
+// instead of crashing.
+func TestClientHeartbeatNoConn(t *testing.T) {
+	client := &Client{closing: make(chan struct{})}
+
+	done := make(chan struct{})
+	go func() {
+		client.heartbeatLoop(time.Millisecond, time.Second)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(client.closing)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("heartbeat loop not stopped")
+	}
+}
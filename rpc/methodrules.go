@@ -0,0 +1,98 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// methodDeniedError is returned for calls to the methods denied by the rules of
+// the server.
+type methodDeniedError struct{ method string }
+
+func (e *methodDeniedError) ErrorCode() int { return errcodeMethodDenied }
+
+func (e *methodDeniedError) Error() string {
+	return fmt.Sprintf("the method %s is not available on this endpoint", e.method)
+}
+
+// methodRule allows or denies the methods matching its pattern.
+type methodRule struct {
+	pattern string
+	deny    bool
+}
+
+// methodRules decide which methods a server dispatches.
+type methodRules struct {
+	rules []methodRule
+	allow bool // whether any rule allows methods, denying the unmatched ones
+}
+
+// SetMethodRules restricts the methods dispatched by the server with rules
+// evaluated before every call. A rule is a pattern allowing the matching methods,
+// or denying them if prefixed with "!". Patterns are method names, namespace
+// names matching all their methods, or globs such as "ong_get*". The last rule
+// matching a method decides. Methods matching no rule are denied if any rule
+// allows methods, and allowed otherwise. For example, "ong,!ong_sendRawTransaction"
+// only serves the ong namespace except ong_sendRawTransaction, and "!admin,!debug"
+// serves everything but the admin and debug namespaces.
+//
+// Subscriptions are ruled by the subscribe method of their namespace. Calls to
+// denied methods are answered with an error, as if they did not exist. A nil
+// rule set removes the restrictions.
+//
+// This method should be called before processing any requests.
+func (s *Server) SetMethodRules(rules []string) error {
+	parsed := new(methodRules)
+	for _, rule := range rules {
+		r := methodRule{pattern: strings.TrimSpace(rule)}
+		if strings.HasPrefix(r.pattern, "!") {
+			r.pattern, r.deny = strings.TrimSpace(r.pattern[1:]), true
+		}
+		if r.pattern == "" {
+			return fmt.Errorf("empty method rule %q", rule)
+		}
+		if !strings.ContainsAny(r.pattern, serviceMethodSeparator+"*?[") {
+			r.pattern += serviceMethodSeparator + "*" // namespace
+		}
+		if _, err := path.Match(r.pattern, ""); err != nil {
+			return fmt.Errorf("invalid method rule %q: %v", rule, err)
+		}
+		parsed.rules = append(parsed.rules, r)
+		parsed.allow = parsed.allow || !r.deny
+	}
+	if len(parsed.rules) == 0 {
+		parsed = nil
+	}
+	s.services.rules = parsed
+	return nil
+}
+
+// allowed reports whether the rules allow calling a method.
+func (r *methodRules) allowed(method string) bool {
+	if r == nil {
+		return true
+	}
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(r.rules[i].pattern, method); ok {
+			return !r.rules[i].deny
+		}
+	}
+	return !r.allow
+}
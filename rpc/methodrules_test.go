@@ -0,0 +1,88 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMethodRules(t *testing.T) {
+	tests := []struct {
+		rules   []string
+		method  string
+		allowed bool
+	}{
+		{nil, "admin_peers", true},
+		{[]string{"ong"}, "ong_call", true},
+		{[]string{"ong"}, "net_version", false},
+		{[]string{"ong", "!ong_sendRawTransaction"}, "ong_sendRawTransaction", false},
+		{[]string{"ong", "!ong_sendRawTransaction"}, "ong_getBalance", true},
+		{[]string{"!ong_send*", "ong_sendRawTransaction"}, "ong_sendRawTransaction", true},
+		{[]string{"!ong_send*", "ong_sendRawTransaction"}, "ong_sendTransaction", false},
+		{[]string{"!admin", "!debug"}, "debug_traceTransaction", false},
+		{[]string{"!admin", "!debug"}, "ong_call", true},
+		{[]string{"*", "!personal"}, "personal_sign", false},
+		{[]string{"ong_get*"}, "ong_getBlockByNumber", true},
+		{[]string{"ong_get*"}, "ong_call", false},
+	}
+	for i, tt := range tests {
+		server := NewServer()
+		if err := server.SetMethodRules(tt.rules); err != nil {
+			t.Fatalf("test %d: invalid rules: %v", i, err)
+		}
+		if allowed := server.services.rules.allowed(tt.method); allowed != tt.allowed {
+			t.Errorf("test %d: %v: %s allowed %t, want %t", i, tt.rules, tt.method, allowed, tt.allowed)
+		}
+	}
+	for _, rules := range [][]string{{"!"}, {""}, {"ong_[get"}} {
+		if err := NewServer().SetMethodRules(rules); err == nil {
+			t.Errorf("invalid rules %q accepted", rules)
+		}
+	}
+}
+
+// Tests that calls and subscriptions denied by the method rules are refused.
+func TestServerMethodRules(t *testing.T) {
+	server := newTestServer()
+	if err := server.SetMethodRules([]string{"test", "nftest_subscribe", "!test_rets", "!test_subscribe"}); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	if err := client.Call(nil, "test_echo", "x", 1, nil); err != nil {
+		t.Fatalf("allowed call failed: %v", err)
+	}
+	for _, method := range []string{"test_rets", "nftest_echo"} {
+		err := client.Call(nil, method)
+		if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != errcodeMethodDenied {
+			t.Errorf("%s error mismatch: have %v, want code %d", method, err, errcodeMethodDenied)
+		}
+	}
+	// Subscriptions are ruled by the subscribe method of their namespace
+	sub, err := client.Subscribe(context.Background(), "nftest", make(chan int), "someSubscription", 1, 1)
+	if err != nil {
+		t.Fatalf("allowed subscription failed: %v", err)
+	}
+	sub.Unsubscribe()
+	if _, err := client.Subscribe(context.Background(), "test", make(chan int), "subscription"); err == nil {
+		t.Errorf("denied subscription succeeded")
+	}
+}
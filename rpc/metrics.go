@@ -18,6 +18,8 @@ package rpc
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/ong2020/go-orange/metrics"
 )
@@ -37,3 +39,17 @@ func newRPCServingTimer(Method string, valid bool) metrics.Timer {
 	m := fmt.Sprintf("rpc/duration/%s/%s", Method, flag)
 	return metrics.GetOrRegisterTimer(m, nil)
 }
+
+// updateMethodMetrics records a call served by a method in the meters and timer
+// of the method, rpc/<namespace>/<method>/{calls,errors,duration}.
+func updateMethodMetrics(Method string, failed bool, elapsed time.Duration) {
+	if !metrics.Enabled {
+		return
+	}
+	prefix := "rpc/" + strings.Replace(Method, serviceMethodSeparator, "/", 1)
+	metrics.GetOrRegisterMeter(prefix+"/calls", nil).Mark(1)
+	if failed {
+		metrics.GetOrRegisterMeter(prefix+"/errors", nil).Mark(1)
+	}
+	metrics.GetOrRegisterTimer(prefix+"/duration", nil).Update(elapsed)
+}
@@ -0,0 +1,256 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// msgpackWriter writes the items of the JSON data model as MessagePack.
+type msgpackWriter struct{}
+
+func (msgpackWriter) writeNull(buf *bytes.Buffer) { buf.WriteByte(0xc0) }
+
+func (msgpackWriter) writeBool(buf *bytes.Buffer, v bool) {
+	if v {
+		buf.WriteByte(0xc3)
+	} else {
+		buf.WriteByte(0xc2)
+	}
+}
+
+func (msgpackWriter) writeInt(buf *bytes.Buffer, n int64) { writeMsgPackInt(buf, n) }
+
+func (msgpackWriter) writeUint(buf *bytes.Buffer, n uint64) { writeMsgPackUint(buf, n) }
+
+func (msgpackWriter) writeFloat(buf *bytes.Buffer, f float64) {
+	buf.WriteByte(0xcb)
+	binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+}
+
+func (msgpackWriter) writeString(buf *bytes.Buffer, s []byte) {
+	writeMsgPackHead(buf, len(s), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	buf.Write(s)
+}
+
+func (msgpackWriter) writeArrayHead(buf *bytes.Buffer, n int) {
+	writeMsgPackHead(buf, n, 0x90, 16, 0, 0xdc, 0xdd)
+}
+
+func (msgpackWriter) writeMapHead(buf *bytes.Buffer, n int) {
+	writeMsgPackHead(buf, n, 0x80, 16, 0, 0xde, 0xdf)
+}
+
+// writeMsgPackHead writes the head of a string, array or map of n items, in its
+// shortest form: fixed if n is below fixmax, else with an 8 bit length if there
+// is such a form (non-zero head8), else with a 16 or 32 bit length.
+func writeMsgPackHead(buf *bytes.Buffer, n int, fix byte, fixmax int, head8, head16, head32 byte) {
+	switch {
+	case n < fixmax:
+		buf.WriteByte(fix | byte(n))
+	case head8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(head8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(head16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(head32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// writeMsgPackUint writes an unsigned integer in its shortest form.
+func writeMsgPackUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n <= math.MaxInt8:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// writeMsgPackInt writes a signed integer in its shortest form.
+func writeMsgPackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0:
+		writeMsgPackUint(buf, uint64(n))
+	case n >= -32:
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// readMsgPack decodes a MessagePack value into JSON.
+func readMsgPack(r *bufio.Reader, out *bytes.Buffer, depth int) error {
+	if depth > binaryMaxDepth {
+		return errBinaryDepth
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	switch {
+	case b <= 0x7f:
+		out.WriteString(strconv.Itoa(int(b)))
+		return nil
+	case b >= 0xe0:
+		out.WriteString(strconv.Itoa(int(int8(b))))
+		return nil
+	case b >= 0xa0 && b <= 0xbf:
+		return readMsgPackString(r, out, uint64(b&0x1f), false)
+	case b >= 0x90 && b <= 0x9f:
+		return readMsgPackArray(r, out, uint64(b&0x0f), depth)
+	case b >= 0x80 && b <= 0x8f:
+		return readMsgPackMap(r, out, uint64(b&0x0f), depth)
+	}
+	switch b {
+	case 0xc0:
+		out.WriteString("null")
+	case 0xc2:
+		out.WriteString("false")
+	case 0xc3:
+		out.WriteString("true")
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readBigEndian(r, 1<<(b-0xcc))
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.FormatUint(n, 10))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := readBigEndian(r, size)
+		if err != nil {
+			return err
+		}
+		// Sign-extend the integer to 64 bits.
+		shift := uint(64 - 8*size)
+		out.WriteString(strconv.FormatInt(int64(n<<shift)>>shift, 10))
+	case 0xca:
+		bits, err := readBigEndian(r, 4)
+		if err != nil {
+			return err
+		}
+		return writeJSONFloat(out, float64(math.Float32frombits(uint32(bits))))
+	case 0xcb:
+		bits, err := readBigEndian(r, 8)
+		if err != nil {
+			return err
+		}
+		return writeJSONFloat(out, math.Float64frombits(bits))
+	case 0xd9, 0xda, 0xdb:
+		n, err := readBigEndian(r, 1<<(b-0xd9))
+		if err != nil {
+			return err
+		}
+		return readMsgPackString(r, out, n, false)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readBigEndian(r, 1<<(b-0xc4))
+		if err != nil {
+			return err
+		}
+		return readMsgPackString(r, out, n, true)
+	case 0xdc, 0xdd:
+		n, err := readBigEndian(r, 2<<(b-0xdc))
+		if err != nil {
+			return err
+		}
+		return readMsgPackArray(r, out, n, depth)
+	case 0xde, 0xdf:
+		n, err := readBigEndian(r, 2<<(b-0xde))
+		if err != nil {
+			return err
+		}
+		return readMsgPackMap(r, out, n, depth)
+	default:
+		return fmt.Errorf("unsupported MessagePack type 0x%x", b)
+	}
+	return nil
+}
+
+// readMsgPackString decodes a string or binary of n bytes.
+func readMsgPackString(r *bufio.Reader, out *bytes.Buffer, n uint64, bin bool) error {
+	s, err := readBinaryString(r, n)
+	if err != nil {
+		return err
+	}
+	if bin {
+		writeJSONBytes(out, s)
+	} else {
+		writeJSONString(out, s)
+	}
+	return nil
+}
+
+// readMsgPackArray decodes an array of n values.
+func readMsgPackArray(r *bufio.Reader, out *bytes.Buffer, n uint64, depth int) error {
+	out.WriteByte('[')
+	for i := uint64(0); i < n; i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := readMsgPack(r, out, depth+1); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+// readMsgPackMap decodes a map of n entries.
+func readMsgPackMap(r *bufio.Reader, out *bytes.Buffer, n uint64, depth int) error {
+	out.WriteByte('{')
+	for i := uint64(0); i < n; i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := readJSONKey(r, out, depth+1, readMsgPack); err != nil {
+			return err
+		}
+		if err := readMsgPack(r, out, depth+1); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultNotifyBatchItems is the maximum number of notifications in a batch if
+// the server doesn't set one.
+const defaultNotifyBatchItems = 100
+
+// notifyBatchConfig configures the coalescing of the notifications of a server.
+type notifyBatchConfig struct {
+	window time.Duration // Maximum delay of a notification (0 = no batching)
+	items  int           // Maximum number of notifications in a batch
+}
+
+// SetNotificationBatching makes the server coalesce the subscription notifications
+// sent over a connection within the given time window into a single batch,
+// cutting the number of writes of busy subscriptions. A batch is sent as soon as
+// it holds maxItems notifications (0 = 100), or when the window since its first
+// notification expires. A window of 0 disables batching.
+//
+// Batches of notifications are JSON arrays, which the clients of the package
+// handle transparently. This method should be called before processing any
+// requests via ServeCodec, ServeListener etc.
+func (s *Server) SetNotificationBatching(window time.Duration, maxItems int) {
+	if maxItems <= 0 {
+		maxItems = defaultNotifyBatchItems
+	}
+	s.services.mu.Lock()
+	defer s.services.mu.Unlock()
+
+	s.services.notifyBatch = notifyBatchConfig{window: window, items: maxItems}
+}
+
+// notifyBatching returns the notification batching configuration of the server.
+func (r *serviceRegistry) notifyBatching() notifyBatchConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.notifyBatch
+}
+
+// notificationBatcher coalesces the notifications sent over a connection.
+type notificationBatcher struct {
+	conn   jsonWriter
+	config notifyBatchConfig
+	queued *int64 // Server counter of the notifications waiting to be sent
+
+	mu      sync.Mutex
+	pending []*jsonrpcMessage
+	timer   *time.Timer
+	err     error // Error of the last write, reported to the next notification
+}
+
+func newNotificationBatcher(conn jsonWriter, config notifyBatchConfig, queued *int64) *notificationBatcher {
+	return &notificationBatcher{conn: conn, config: config, queued: queued}
+}
+
+// add queues a notification, sending the batch if it is full. The error of a
+// previous write, if any, is returned instead.
+func (b *notificationBatcher) add(msg *jsonrpcMessage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+	b.pending = append(b.pending, msg)
+	atomic.AddInt64(b.queued, 1)
+	if len(b.pending) >= b.config.items {
+		return b.flushLocked()
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.config.window, b.flush)
+	}
+	return nil
+}
+
+// flush sends the queued notifications once the window expires.
+func (b *notificationBatcher) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushLocked()
+}
+
+// flushLocked sends the queued notifications, as a batch if there are several.
+// The caller must hold the lock.
+func (b *notificationBatcher) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return nil
+	}
+	msgs := b.pending
+	b.pending = nil
+	defer atomic.AddInt64(b.queued, -int64(len(msgs)))
+
+	var err error
+	if len(msgs) == 1 {
+		err = b.conn.writeJSON(context.Background(), msgs[0])
+	} else {
+		err = b.conn.writeJSON(context.Background(), msgs)
+	}
+	if err != nil {
+		b.err = err
+	}
+	return err
+}
+
+// stop drops the queued notifications when the connection is closed.
+func (b *notificationBatcher) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	atomic.AddInt64(b.queued, -int64(len(b.pending)))
+	b.pending = nil
+}
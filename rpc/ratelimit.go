@@ -0,0 +1,182 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// RateLimitDefault is the name of the rate limit applying to the methods
+	// without a limit of their own or of their namespace.
+	RateLimitDefault = "*"
+
+	// rateLimitSweepThreshold is the number of tracked buckets above which the
+	// idle ones are dropped.
+	rateLimitSweepThreshold = 4096
+)
+
+// RateLimit is the rate of calls allowed to every remote address for a method.
+type RateLimit struct {
+	Rate  float64 // Calls allowed per second
+	Burst int     // Calls allowed at once, at least 1
+}
+
+// rateLimitError is returned for calls exceeding their rate limit.
+type rateLimitError struct{ retry time.Duration }
+
+func (e *rateLimitError) ErrorCode() int { return errcodeRateLimited }
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry in %v", e.retry)
+}
+
+// ErrorData returns the number of seconds to wait before retrying the call.
+func (e *rateLimitError) ErrorData() interface{} {
+	return map[string]interface{}{"retryAfter": int(math.Ceil(e.retry.Seconds()))}
+}
+
+// rateLimiter tracks the calls of the remote addresses with a token bucket per
+// address and method.
+type rateLimiter struct {
+	limits  map[string]RateLimit     // limits by method, namespace or default
+	buckets map[string]*rate.Limiter // buckets by remote address and method
+	lock    sync.Mutex
+}
+
+// SetRateLimit limits the rate of the calls every remote address may make to a
+// method ("namespace_method"), to each method of a namespace ("namespace"), or
+// to each method without a limit of its own or of its namespace (RateLimitDefault).
+// A rate of 0 removes the limit. Calls exceeding the limit are answered with an
+// error telling when to retry. Requests served over local transports (IPC,
+// in-process) are not limited.
+func (s *Server) SetRateLimit(name string, limit RateLimit) {
+	s.services.limiter.setLimit(name, limit)
+}
+
+// SetRateLimits replaces all the rate limits of the server, e.g. on a config
+// reload. The names and limits are interpreted as by SetRateLimit.
+func (s *Server) SetRateLimits(limits map[string]RateLimit) {
+	s.services.limiter.setLimits(limits)
+}
+
+// setLimit sets or removes the rate limit of a method, namespace or the default.
+func (l *rateLimiter) setLimit(name string, limit RateLimit) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.limits == nil {
+		l.limits = make(map[string]RateLimit)
+	}
+	l.putLimit(name, limit)
+
+	// Drop the buckets so the new limits take effect
+	l.buckets = make(map[string]*rate.Limiter)
+}
+
+// setLimits replaces all the rate limits.
+func (l *rateLimiter) setLimits(limits map[string]RateLimit) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.limits = make(map[string]RateLimit)
+	for name, limit := range limits {
+		l.putLimit(name, limit)
+	}
+	l.buckets = make(map[string]*rate.Limiter)
+}
+
+// putLimit sets or removes a rate limit. The caller must hold the lock.
+func (l *rateLimiter) putLimit(name string, limit RateLimit) {
+	if limit.Rate <= 0 {
+		delete(l.limits, name)
+		return
+	}
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	l.limits[name] = limit
+}
+
+// limit returns the rate limit applying to a method, if any. The caller must
+// hold the lock.
+func (l *rateLimiter) limit(Method string) (RateLimit, bool) {
+	if limit, ok := l.limits[Method]; ok {
+		return limit, true
+	}
+	if limit, ok := l.limits[strings.SplitN(Method, serviceMethodSeparator, 2)[0]]; ok {
+		return limit, true
+	}
+	limit, ok := l.limits[RateLimitDefault]
+	return limit, ok
+}
+
+// allow consumes a call of a method from the bucket of the remote address of
+// the request, returning an error if the rate limit is exceeded.
+func (l *rateLimiter) allow(ctx context.Context, Method string) error {
+	remote, _ := ctx.Value("remote").(string)
+	if remote == "" {
+		return nil
+	}
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	limit, ok := l.limit(Method)
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	key := remote + "/" + Method
+	bucket := l.buckets[key]
+	if bucket == nil {
+		if len(l.buckets) >= rateLimitSweepThreshold {
+			l.sweep(now)
+		}
+		bucket = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+		l.buckets[key] = bucket
+	}
+	reservation := bucket.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return &rateLimitError{retry: delay.Round(time.Millisecond)}
+	}
+	return nil
+}
+
+// sweep drops the buckets refilled to their burst, which behave the same as new
+// ones. The caller must hold the lock.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		reservation := bucket.ReserveN(now, bucket.Burst())
+		if reservation.OK() && reservation.DelayFrom(now) == 0 {
+			delete(l.buckets, key)
+		} else {
+			reservation.CancelAt(now)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"io"
 	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ong2020/go-orange/log"
@@ -46,6 +47,8 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	limits   batchLimits
+	auth     Authenticator
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -58,6 +61,28 @@ func NewServer() *Server {
 	return server
 }
 
+// SetBatchLimits sets the limits applied to the batch requests served by the
+// server: the maximum number of requests in a batch and the maximum total size
+// of their results in bytes, 0 meaning unlimited. Batches with too many requests
+// are rejected as a whole, while the calls of a batch exceeding the response
+// size are answered with an error once the limit is reached. The connection is
+// kept open in both cases.
+//
+// This method should be called before processing any requests via ServeCodec,
+// ServeHTTP, ServeListener etc.
+func (s *Server) SetBatchLimits(itemLimit, maxResponseSize int) {
+	s.limits = batchLimits{items: itemLimit, responseSize: maxResponseSize}
+}
+
+// SetMethodTimeout limits the execution time of a method ("namespace_method"),
+// or of all the methods of a namespace ("namespace"), the timeout of a method
+// taking precedence over the one of its namespace. A timeout of 0 removes the
+// limit. The context of the calls exceeding the timeout is cancelled, and the
+// calls failing due to it are answered with a "request timed out" error.
+func (s *Server) SetMethodTimeout(name string, timeout time.Duration) {
+	s.services.setTimeout(name, timeout)
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // Methods on the given receiver match the criteria to be either a RPC Method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -83,9 +108,13 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.limits)
 	<-codec.closed()
 	c.Close()
+
+	if h, ok := codec.(healthReporter); ok && h.health().isDead() {
+		atomic.AddInt64(&s.services.stats.unresponsive, 1)
+	}
 }
 
 // serveSingleRequest reads and processes a single RPC request from the given codec. This
@@ -97,7 +126,10 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services)
+	atomic.AddInt64(&s.services.stats.httpRequests, 1)
+	defer atomic.AddInt64(&s.services.stats.httpRequests, -1)
+
+	h := newHandler(ctx, codec, s.idgen, &s.services, s.limits)
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
@@ -145,3 +177,12 @@ func (s *RPCService) Modules() map[string]string {
 	}
 	return modules
 }
+
+// Ping answers the heartbeats of the clients.
+func (s *RPCService) Ping() {}
+
+// Stats reports the open connections and subscriptions of the server, along with
+// the calls served over the last minute.
+func (s *RPCService) Stats() *ServerStats {
+	return s.server.stats()
+}
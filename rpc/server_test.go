@@ -19,13 +19,17 @@ package rpc
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"net"
+	"net/http/httptest"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/ong2020/go-orange/metrics"
 )
 
 func TestServerRegisterName(t *testing.T) {
@@ -45,7 +49,7 @@ func TestServerRegisterName(t *testing.T) {
 		t.Fatalf("Expected service calc to be registered")
 	}
 
-	wantCallbacks := 9
+	wantCallbacks := 11
 	if len(svc.callbacks) != wantCallbacks {
 		t.Errorf("Expected %d callbacks for service 'service', got %d", wantCallbacks, len(svc.callbacks))
 	}
@@ -150,3 +154,325 @@ func TestServerShortLivedConn(t *testing.T) {
 		}
 	}
 }
+
+// Tests that oversized batches are answered with errors without closing the
+// connection.
+func TestServerBatchLimits(t *testing.T) {
+	server := newTestServer()
+	server.SetBatchLimits(3, 40)
+	defer server.Stop()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewCodec(serverConn), 0)
+
+	tests := []struct {
+		request  string
+		response string
+	}{
+		// Batch with too many requests
+		{
+			`[{"jsonrpc":"2.0","Method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"Method":"test_echo","params":["x",2]},{"jsonrpc":"2.0","id":3,"Method":"test_echo","params":["x",3]},{"jsonrpc":"2.0","id":4,"Method":"test_echo","params":["x",4]}]`,
+			`[{"jsonrpc":"2.0","id":2,"error":{"code":-32600,"message":"batch too large"}}]`,
+		},
+		// Batch with too large results
+		{
+			`[{"jsonrpc":"2.0","id":5,"Method":"test_echo","params":["x",5]},{"jsonrpc":"2.0","id":6,"Method":"test_echo","params":["x",6]},{"jsonrpc":"2.0","id":7,"Method":"test_echo","params":["x",7]}]`,
+			`[{"jsonrpc":"2.0","id":5,"result":{"String":"x","Int":5,"Args":null}},{"jsonrpc":"2.0","id":6,"result":{"String":"x","Int":6,"Args":null}},{"jsonrpc":"2.0","id":7,"error":{"code":-32003,"message":"response too large"}}]`,
+		},
+		// Batch within the limits, served on the same connection
+		{
+			`[{"jsonrpc":"2.0","id":8,"Method":"test_echo","params":["x",8]}]`,
+			`[{"jsonrpc":"2.0","id":8,"result":{"String":"x","Int":8,"Args":null}}]`,
+		},
+	}
+	readbuf := bufio.NewReader(clientConn)
+	for i, tt := range tests {
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.WriteString(clientConn, tt.request+"\n"); err != nil {
+			t.Fatalf("test %d: write error: %v", i, err)
+		}
+		resp, err := readbuf.ReadString('\n')
+		if err != nil {
+			t.Fatalf("test %d: read error: %v", i, err)
+		}
+		if resp = strings.TrimRight(resp, "\r\n"); resp != tt.response {
+			t.Errorf("test %d: wrong response\ngot:  %s\nwant: %s", i, resp, tt.response)
+		}
+	}
+}
+
+// Tests that calls exceeding their method or namespace timeout are cancelled.
+func TestServerMethodTimeout(t *testing.T) {
+	server := newTestServer()
+	server.SetMethodTimeout("test", time.Minute)
+	server.SetMethodTimeout("test_block", 50*time.Millisecond)
+	defer server.Stop()
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	start := time.Now()
+	err := client.Call(nil, "test_block")
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != errcodeTimeout {
+		t.Fatalf("error mismatch: have %v, want code %d", err, errcodeTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("call not cancelled in time: %v", elapsed)
+	}
+	// Calls finishing in time are unaffected
+	if err := client.Call(nil, "test_echo", "x", 1, nil); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+}
+
+type namedTestArgs struct {
+	Str      string    `rpc:"str"`
+	Int      int       `rpc:"int"`
+	Args     *echoArgs `rpc:"args"`
+	Internal string    // not a parameter
+}
+
+type namedTestService struct{}
+
+func (s *namedTestService) Echo(args namedTestArgs) echoResult {
+	return echoResult{args.Str, args.Int, args.Args}
+}
+
+// Tests that methods taking a tagged struct accept named and positional params.
+func TestServerNamedParams(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("named", new(namedTestService)); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewCodec(serverConn), 0)
+
+	tests := []struct {
+		request  string
+		response string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":1,"Method":"named_echo","params":{"int":23,"str":"x","args":{"S":"y"}}}`,
+			`{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":23,"Args":{"S":"y"}}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":2,"Method":"named_echo","params":["x",23]}`,
+			`{"jsonrpc":"2.0","id":2,"result":{"String":"x","Int":23,"Args":null}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":3,"Method":"named_echo","params":{"str":"x"}}`,
+			`{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"missing value for required argument int"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":4,"Method":"named_echo","params":{"str":"x","int":1,"foo":1,"Internal":"z"}}`,
+			`{"jsonrpc":"2.0","id":4,"error":{"code":-32602,"message":"unknown arguments Internal, foo"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":5,"Method":"named_echo","params":{"str":1,"int":1}}`,
+			`{"jsonrpc":"2.0","id":5,"error":{"code":-32602,"message":"invalid argument str: json: cannot unmarshal number into Go value of type string"}}`,
+		},
+	}
+	readbuf := bufio.NewReader(clientConn)
+	for i, tt := range tests {
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.WriteString(clientConn, tt.request+"\n"); err != nil {
+			t.Fatalf("test %d: write error: %v", i, err)
+		}
+		resp, err := readbuf.ReadString('\n')
+		if err != nil {
+			t.Fatalf("test %d: read error: %v", i, err)
+		}
+		if resp = strings.TrimRight(resp, "\r\n"); resp != tt.response {
+			t.Errorf("test %d: wrong response\ngot:  %s\nwant: %s", i, resp, tt.response)
+		}
+	}
+}
+
+type variadicTestService struct{}
+
+func (s *variadicTestService) Join(sep string, parts ...string) string {
+	return strings.Join(parts, sep)
+}
+
+// Tests that methods with a variadic parameter accept any number of trailing params.
+func TestServerVariadicParams(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("variadic", new(variadicTestService)); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewCodec(serverConn), 0)
+
+	tests := []struct {
+		request  string
+		response string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":1,"Method":"variadic_join","params":["-","a","b","c"]}`,
+			`{"jsonrpc":"2.0","id":1,"result":"a-b-c"}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":2,"Method":"variadic_join","params":["-"]}`,
+			`{"jsonrpc":"2.0","id":2,"result":""}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":3,"Method":"variadic_join","params":[]}`,
+			`{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"missing value for required argument 0"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":4,"Method":"variadic_join","params":["-","a",1]}`,
+			`{"jsonrpc":"2.0","id":4,"error":{"code":-32602,"message":"invalid argument 2: json: cannot unmarshal number into Go value of type string"}}`,
+		},
+	}
+	readbuf := bufio.NewReader(clientConn)
+	for i, tt := range tests {
+		clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.WriteString(clientConn, tt.request+"\n"); err != nil {
+			t.Fatalf("test %d: write error: %v", i, err)
+		}
+		resp, err := readbuf.ReadString('\n')
+		if err != nil {
+			t.Fatalf("test %d: read error: %v", i, err)
+		}
+		if resp = strings.TrimRight(resp, "\r\n"); resp != tt.response {
+			t.Errorf("test %d: wrong response\ngot:  %s\nwant: %s", i, resp, tt.response)
+		}
+	}
+}
+
+// Tests that the calls of remote addresses are rate limited per method.
+func TestServerRateLimit(t *testing.T) {
+	server := newTestServer()
+	server.SetRateLimit(RateLimitDefault, RateLimit{Rate: 0.001, Burst: 2})
+	server.SetRateLimit("test_rets", RateLimit{Rate: 1000, Burst: 1000})
+	defer server.Stop()
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+	client, err := DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := client.Call(nil, "test_echo", "x", 1, nil); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+	}
+	err = client.Call(nil, "test_echo", "x", 1, nil)
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != errcodeRateLimited {
+		t.Fatalf("error mismatch: have %v, want code %d", err, errcodeRateLimited)
+	}
+	if data, ok := err.(DataError); !ok || data.ErrorData() == nil {
+		t.Errorf("missing retry hint: %v", err)
+	}
+	// Other methods have buckets of their own
+	for i := 0; i < 5; i++ {
+		if err := client.Call(nil, "test_rets"); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+	}
+	// Local requests are not limited
+	local := DialInProc(server)
+	defer local.Close()
+	if err := local.Call(nil, "test_echo", "x", 1, nil); err != nil {
+		t.Fatalf("local call failed: %v", err)
+	}
+	// Replacing the limits lifts the removed ones
+	server.SetRateLimits(map[string]RateLimit{"test_rets": {Rate: 0.001, Burst: 1}})
+	if err := client.Call(nil, "test_echo", "x", 1, nil); err != nil {
+		t.Fatalf("call after lifting the limit failed: %v", err)
+	}
+	client.Call(nil, "test_rets")
+	if err := client.Call(nil, "test_rets"); err == nil {
+		t.Fatalf("call exceeding the replaced limit succeeded")
+	}
+}
+
+// Tests that the server statistics report the connections, subscriptions and
+// recent calls.
+func TestServerStats(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	nc := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "nftest", nc, "someSubscription", 1, 0)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	<-nc
+	client.Call(nil, "test_returnError")
+
+	var stats ServerStats
+	if err := client.Call(&stats, "rpc_stats"); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Connections["pipe"] != 1 {
+		t.Errorf("connections mismatch: have %v, want 1 pipe", stats.Connections)
+	}
+	if stats.Subscriptions != 1 {
+		t.Errorf("subscriptions mismatch: have %d, want 1", stats.Subscriptions)
+	}
+	if stats.Calls != 2 || stats.Errors != 1 || stats.ErrorRate != 0.5 {
+		t.Errorf("calls mismatch: have %d calls %d errors (rate %v), want 2 calls 1 error", stats.Calls, stats.Errors, stats.ErrorRate)
+	}
+	sub.Unsubscribe()
+	if err := client.Call(&stats, "rpc_stats"); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Subscriptions != 0 {
+		t.Errorf("subscriptions mismatch after unsubscribe: have %d, want 0", stats.Subscriptions)
+	}
+}
+
+// Tests that the calls served by each method are recorded in its metrics.
+func TestServerMethodMetrics(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		client.Call(nil, "test_echo", "x", 1, nil)
+	}
+	client.Call(nil, "test_returnError")
+
+	want := map[string]int64{
+		"rpc/test/echo/calls":         2,
+		"rpc/test/echo/errors":        0,
+		"rpc/test/echo/duration":      2,
+		"rpc/test/returnError/calls":  1,
+		"rpc/test/returnError/errors": 1,
+	}
+	for name, want := range want {
+		defer metrics.DefaultRegistry.Unregister(name)
+
+		var have int64
+		switch m := metrics.DefaultRegistry.Get(name).(type) {
+		case metrics.Meter:
+			have = m.Count()
+		case metrics.Timer:
+			have = m.Count()
+		}
+		if have != want {
+			t.Errorf("%s count mismatch: have %d, want %d", name, have, want)
+		}
+	}
+}
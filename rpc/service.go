@@ -24,6 +24,8 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 
 	"github.com/ong2020/go-orange/log"
@@ -37,8 +39,16 @@ var (
 )
 
 type serviceRegistry struct {
-	mu       sync.Mutex
-	services map[string]service
+	stats     serverStats // usage statistics of the server, first for 64-bit atomic alignment
+	mu        sync.Mutex
+	services  map[string]service
+	protected map[string]bool          // namespaces restricted to authenticated clients
+	timeouts  map[string]time.Duration // execution timeouts of methods and namespaces
+	limiter   rateLimiter              // rate limits of the remote callers
+	hook      atomic.Value             // observer of the calls (hookSlot), if any
+	rules     *methodRules             // methods dispatched, nil if all
+
+	notifyBatch notifyBatchConfig // coalescing of the subscription notifications
 }
 
 // service represents a registered object.
@@ -56,6 +66,50 @@ type callback struct {
 	hasCtx      bool           // Method's first argument is a context (not included in argTypes)
 	errPos      int            // err return idx, of -1 when Method cannot return error
 	isSubscribe bool           // true if this is a subscription callback
+	isVariadic  bool           // true if the last argument is variadic (a slice in argTypes)
+	named       *namedArgs     // named parameters, set if the Method accepts them
+}
+
+// namedArgs describes the struct argument of a Method accepting named parameters:
+// the fields of the struct tagged with `rpc:"name"`, in declaration order. Such
+// Methods may be called either with a params object keyed by the field names, or
+// with a params array holding the fields in order.
+type namedArgs struct {
+	typ    reflect.Type   // struct type of the argument
+	ptr    bool           // whether the argument is a pointer to the struct
+	names  []string       // parameter names of the tagged fields
+	fields []int          // indices of the tagged fields
+	types  []reflect.Type // types of the tagged fields
+}
+
+// newNamedArgs returns the named parameters of a struct (or struct pointer)
+// argument type, or nil if it has no fields tagged as such.
+func newNamedArgs(typ reflect.Type) *namedArgs {
+	ptr := typ.Kind() == reflect.Ptr
+	if ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	na := &namedArgs{typ: typ, ptr: ptr}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, ok := field.Tag.Lookup("rpc")
+		if !ok || field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		na.names = append(na.names, name)
+		na.fields = append(na.fields, i)
+		na.types = append(na.types, field.Type)
+	}
+	if len(na.names) == 0 {
+		return nil
+	}
+	return na
 }
 
 func (r *serviceRegistry) registerName(name string, rcvr interface{}) error {
@@ -103,6 +157,55 @@ func (r *serviceRegistry) callback(Method string) *callback {
 	return r.services[elem[0]].callbacks[elem[1]]
 }
 
+// protect restricts the given namespaces to authenticated clients.
+func (r *serviceRegistry) protect(namespaces []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.protected = make(map[string]bool)
+	for _, namespace := range namespaces {
+		r.protected[namespace] = true
+	}
+}
+
+// authorized checks whether the namespace of a method may be called with the
+// credentials of the request context.
+func (r *serviceRegistry) authorized(ctx context.Context, namespace string) bool {
+	r.mu.Lock()
+	protected := r.protected[namespace]
+	r.mu.Unlock()
+
+	return !protected || AuthIdentity(ctx) != ""
+}
+
+// setTimeout sets the execution timeout of a method or of all the methods of a
+// namespace, 0 removing it.
+func (r *serviceRegistry) setTimeout(name string, timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timeouts == nil {
+		r.timeouts = make(map[string]time.Duration)
+	}
+	if timeout == 0 {
+		delete(r.timeouts, name)
+		return
+	}
+	r.timeouts[name] = timeout
+}
+
+// timeout returns the execution timeout of a method, either set for the method
+// or for its namespace, 0 meaning unlimited.
+func (r *serviceRegistry) timeout(Method string) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if timeout, ok := r.timeouts[Method]; ok {
+		return timeout
+	}
+	return r.timeouts[strings.SplitN(Method, serviceMethodSeparator, 2)[0]]
+}
+
 // subscription returns a subscription callback in the given service.
 func (r *serviceRegistry) subscription(service, name string) *callback {
 	r.mu.Lock()
@@ -178,6 +281,11 @@ func (c *callback) makeArgTypes() {
 	for i := firstArg; i < fntype.NumIn(); i++ {
 		c.argTypes[i-firstArg] = fntype.In(i)
 	}
+	c.isVariadic = fntype.IsVariadic() && len(c.argTypes) > 0
+	// Methods taking a single struct with tagged fields accept named parameters.
+	if len(c.argTypes) == 1 && !c.isSubscribe && !c.isVariadic {
+		c.named = newNamedArgs(c.argTypes[0])
+	}
 }
 
 // call invokes the callback.
@@ -0,0 +1,42 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import "context"
+
+// Session is a persistent client connection (websocket, IPC or in-process),
+// letting the method handlers keep state on behalf of the client until it
+// disconnects. HTTP requests are served without a session.
+type Session struct {
+	ID   ID // Unique identifier of the connection
+	done <-chan struct{}
+}
+
+// Closed returns a channel which is closed once the connection is closed and
+// all its calls have returned.
+func (s *Session) Closed() <-chan struct{} {
+	return s.done
+}
+
+type sessionKey struct{}
+
+// SessionFromContext returns the session of the connection a call was received
+// on, if it is a persistent one.
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(sessionKey{}).(*Session)
+	return s, ok
+}
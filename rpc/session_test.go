@@ -0,0 +1,93 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type sessionService struct {
+	sessions chan *Session
+}
+
+func (s *sessionService) Session(ctx context.Context) ID {
+	session, ok := SessionFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	s.sessions <- session
+	return session.ID
+}
+
+// Tests that the calls received on a persistent connection share its session,
+// which is closed with the connection, while HTTP requests have none.
+func TestServerSession(t *testing.T) {
+	var (
+		server  = NewServer()
+		service = &sessionService{sessions: make(chan *Session, 3)}
+	)
+	defer server.Stop()
+	if err := server.RegisterName("test", service); err != nil {
+		t.Fatal(err)
+	}
+	call := func(c *Client) ID {
+		var id ID
+		if err := c.Call(&id, "test_session"); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	first, second := DialInProc(server), DialInProc(server)
+	defer second.Close()
+
+	id := call(first)
+	if id == "" {
+		t.Fatal("no session on in-process connection")
+	}
+	if again := call(first); again != id {
+		t.Fatalf("session changed between calls: %s != %s", again, id)
+	}
+	if other := call(second); other == "" || other == id {
+		t.Fatalf("sessions not unique per connection: %q, %q", other, id)
+	}
+	session := <-service.sessions
+	select {
+	case <-session.Closed():
+		t.Fatal("session closed before the connection")
+	default:
+	}
+	first.Close()
+	select {
+	case <-session.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not closed with the connection")
+	}
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+	client, err := DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if id := call(client); id != "" {
+		t.Fatalf("session on HTTP request: %s", id)
+	}
+}
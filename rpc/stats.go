@@ -0,0 +1,129 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// statsWindow is the number of seconds over which the recent calls are counted.
+const statsWindow = 60
+
+// ServerStats is the usage report of a server returned by rpc_stats.
+type ServerStats struct {
+	Connections         map[string]int `json:"connections"`         // Open connections (in-flight requests for HTTP) per transport
+	Subscriptions       int64          `json:"subscriptions"`       // Open subscriptions
+	QueuedNotifications int64          `json:"queuedNotifications"` // Notifications waiting to be sent
+	Calls               int            `json:"calls"`               // Calls served over the last minute
+	Errors              int            `json:"errors"`              // Calls answered with an error over the last minute
+	ErrorRate           float64        `json:"errorRate"`           // Share of the calls answered with an error over the last minute
+	Unresponsive        int64          `json:"unresponsive"`        // Connections closed as their peer failed to answer keepalive probes
+}
+
+// statsBucket counts the calls served during a second.
+type statsBucket struct {
+	second int64
+	calls  int
+	errors int
+}
+
+// serverStats tracks the usage of a server.
+type serverStats struct {
+	httpRequests  int64 // HTTP requests being served (atomic)
+	subscriptions int64 // Open subscriptions (atomic)
+	queued        int64 // Notifications waiting to be sent (atomic)
+	unresponsive  int64 // Connections closed by keepalive probes (atomic)
+
+	buckets [statsWindow]statsBucket // Calls served over the last seconds
+	lock    sync.Mutex               // Protects the buckets
+}
+
+// recordCall counts a served call, failed or not.
+func (s *serverStats) recordCall(failed bool) {
+	now := time.Now().Unix()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	bucket := &s.buckets[now%statsWindow]
+	if bucket.second != now {
+		*bucket = statsBucket{second: now}
+	}
+	bucket.calls++
+	if failed {
+		bucket.errors++
+	}
+}
+
+// recentCalls returns the number of calls served, and of them failed, over the
+// last minute.
+func (s *serverStats) recentCalls() (calls int, errors int) {
+	now := time.Now().Unix()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, bucket := range s.buckets {
+		if now-bucket.second < statsWindow {
+			calls += bucket.calls
+			errors += bucket.errors
+		}
+	}
+	return calls, errors
+}
+
+// stats assembles the usage report of the server.
+func (s *Server) stats() *ServerStats {
+	stats := &ServerStats{
+		Connections:         make(map[string]int),
+		Subscriptions:       atomic.LoadInt64(&s.services.stats.subscriptions),
+		QueuedNotifications: atomic.LoadInt64(&s.services.stats.queued),
+		Unresponsive:        atomic.LoadInt64(&s.services.stats.unresponsive),
+	}
+	s.codecs.Each(func(c interface{}) bool {
+		stats.Connections[codecTransport(c.(ServerCodec))]++
+		return false
+	})
+	if n := atomic.LoadInt64(&s.services.stats.httpRequests); n > 0 {
+		stats.Connections["http"] = int(n)
+	}
+	stats.Calls, stats.Errors = s.services.stats.recentCalls()
+	if stats.Calls > 0 {
+		stats.ErrorRate = float64(stats.Errors) / float64(stats.Calls)
+	}
+	return stats
+}
+
+// codecTransport names the transport of a long-lived connection.
+func codecTransport(codec ServerCodec) string {
+	switch c := codec.(type) {
+	case *websocketCodec:
+		return "ws"
+	case *jsonCodec:
+		if conn, ok := c.conn.(net.Conn); ok {
+			network := conn.LocalAddr().Network()
+			if network == "unix" {
+				return "ipc"
+			}
+			return network
+		}
+	}
+	return "other"
+}
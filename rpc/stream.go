@@ -0,0 +1,383 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamBufferSize is the size of the chunks the items of a stream are written to
+// the connection in, bounding the number of writes on it.
+const streamBufferSize = 4096
+
+// Stream is a method result produced incrementally. The result is a JSON array of
+// the items passed to yield, which are written to the connection in chunks as the
+// function produces them instead of buffering the entire response in memory. The
+// function should stop and return the error of yield, if any.
+//
+// Streams run after the method returns, with a context carrying the method
+// timeout and the origin of the request, so the limits applying to the method
+// apply to them too. A stream failing before its first chunk is sent is answered
+// with a regular error response. As a response can't carry both a result and an
+// error, a failure once chunks were sent drops the connection instead. Results in
+// batch responses, and on connections unable to stream, are buffered in full
+// before they are sent.
+type Stream func(ctx context.Context, yield func(item interface{}) error) error
+
+// bound makes a stream run within the context of its method, reporting the
+// expiry of the method deadline as a timeout, and releases the context once done.
+func (s Stream) bound(ctx context.Context, cancel context.CancelFunc) Stream {
+	return func(parent context.Context, yield func(interface{}) error) error {
+		defer cancel()
+
+		err := s(ctx, yield)
+		if err != nil && ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+			return &internalServerError{errcodeTimeout, errMsgTimeout}
+		}
+		return err
+	}
+}
+
+// streamWriter is implemented by the connections able to write a message
+// incrementally.
+type streamWriter interface {
+	// writeStream writes a message through the writer passed to fn, reporting
+	// whether any of it was sent. If fn fails before, the connection is left
+	// untouched. Otherwise the message can't be completed and the connection
+	// is closed.
+	writeStream(ctx context.Context, fn func(w io.Writer) error) (bool, error)
+}
+
+// chunkWriter passes the chunks of a streamed message to a connection. The
+// connection is only locked while a chunk is written, so that pings aren't held
+// back by slow streams, but the first chunk reserves it for the message until
+// finish is called, so that other messages aren't interleaved with the chunks.
+type chunkWriter struct {
+	msgMu   *sync.Mutex // serializes the messages of the connection
+	encMu   *sync.Mutex // guards the writes to the connection
+	conn    deadlineCloser
+	open    func() (io.WriteCloser, error) // starts the message on the connection
+	w       io.WriteCloser
+	started bool
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	if !cw.started {
+		cw.msgMu.Lock()
+		cw.started = true
+	}
+	cw.encMu.Lock()
+	defer cw.encMu.Unlock()
+
+	// Refresh the write deadline on every chunk, so long streams don't time
+	// out as long as they make progress.
+	cw.conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
+	if cw.w == nil {
+		w, err := cw.open()
+		if err != nil {
+			return 0, err
+		}
+		cw.w = w
+	}
+	return cw.w.Write(p)
+}
+
+// finish ends the message, releasing the connection to the other messages. An
+// incomplete message is left unterminated, the connection must be closed first.
+func (cw *chunkWriter) finish(complete bool) error {
+	if !cw.started {
+		return nil
+	}
+	defer cw.msgMu.Unlock()
+
+	if !complete || cw.w == nil {
+		return nil
+	}
+	cw.encMu.Lock()
+	defer cw.encMu.Unlock()
+	return cw.w.Close()
+}
+
+// nopWriteCloser adds a no-op Close method to a writer.
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writeStream writes a message incrementally. Codecs created by NewFuncCodec have
+// no access to the connection, so the message is buffered and sent at once.
+func (c *jsonCodec) writeStream(ctx context.Context, fn func(w io.Writer) error) (bool, error) {
+	if c.writer == nil {
+		buf := new(bytes.Buffer)
+		if err := fn(buf); err != nil {
+			return false, err
+		}
+		return true, c.writeJSON(ctx, json.RawMessage(buf.Bytes()))
+	}
+	return c.writeChunks(fn, func() (io.WriteCloser, error) {
+		return nopWriteCloser{c.writer}, nil
+	})
+}
+
+// writeStream writes a message incrementally as a single text frame.
+func (wc *websocketCodec) writeStream(ctx context.Context, fn func(w io.Writer) error) (bool, error) {
+	return wc.writeChunks(fn, func() (io.WriteCloser, error) {
+		return wc.conn.NextWriter(websocket.TextMessage)
+	})
+}
+
+// writeChunks writes a message produced by fn in chunks through a chunkWriter.
+func (c *jsonCodec) writeChunks(fn func(w io.Writer) error, open func() (io.WriteCloser, error)) (bool, error) {
+	cw := &chunkWriter{msgMu: &c.msgMu, encMu: &c.encMu, conn: c.conn, open: open}
+	if err := fn(cw); err != nil {
+		if cw.started {
+			c.close()
+		}
+		cw.finish(false)
+		return cw.started, err
+	}
+	return true, cw.finish(true)
+}
+
+// collect buffers the result of a streamed answer, for the responses which can't
+// be written incrementally.
+func (msg *jsonrpcMessage) collect(ctx context.Context) *jsonrpcMessage {
+	items := make([]json.RawMessage, 0)
+	err := msg.stream(ctx, func(item interface{}) error {
+		enc, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		items = append(items, enc)
+		return nil
+	})
+	if err != nil {
+		return msg.errorResponse(err)
+	}
+	return msg.response(items)
+}
+
+// writeStream writes a streamed answer to w, in chunks of streamBufferSize bytes.
+// If the stream fails, its error is returned and the chunk being filled is
+// dropped, leaving the response unterminated.
+func (msg *jsonrpcMessage) writeStream(ctx context.Context, w io.Writer) error {
+	buf := new(bytes.Buffer)
+	buf.WriteString(`{"jsonrpc":"` + vsn + `","id":`)
+	buf.Write(msg.ID)
+	buf.WriteString(`,"result":[`)
+
+	first := true
+	err := msg.stream(ctx, func(item interface{}) error {
+		enc, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.Write(enc)
+
+		if buf.Len() < streamBufferSize {
+			return nil
+		}
+		_, err = w.Write(buf.Bytes())
+		buf.Reset()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	buf.WriteString("]}\n")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// ClientStream iterates over the items of a streamed result.
+type ClientStream struct {
+	dec      *json.Decoder
+	body     io.Closer       // response body being decoded, if any
+	response bool            // whether dec reads the whole response or just the result
+	item     json.RawMessage // current item
+	err      error
+	done     bool
+}
+
+// StreamContext performs a JSON-RPC call with the given arguments, returning an
+// iterator over the items of its array result. Over HTTP the items are decoded as
+// the response is received, so results larger than the memory of the client can
+// be processed. Other transports deliver the response at once, which is then
+// decoded item by item.
+//
+// The stream must be closed once done with it. A server failing midway through the
+// result is reported by Err once the items sent before the failure are consumed.
+func (c *Client) StreamContext(ctx context.Context, method string, args ...interface{}) (*ClientStream, error) {
+	if !c.isHTTP {
+		var result json.RawMessage
+		if err := c.CallContext(ctx, &result, method, args...); err != nil {
+			return nil, err
+		}
+		s := &ClientStream{dec: json.NewDecoder(bytes.NewReader(result))}
+		if err := s.readStart(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	msg, err := c.newMessage(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.writeConn.(*httpConn).doRequest(ctx, msg)
+	if err != nil {
+		if body != nil {
+			body.Close()
+		}
+		return nil, err
+	}
+	s := &ClientStream{dec: json.NewDecoder(body), body: body, response: true}
+	if err := s.readHeader(); err != nil {
+		body.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// readHeader consumes the response up to the start of the result array.
+func (s *ClientStream) readHeader() error {
+	if tok, err := s.dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return errors.New("invalid JSON-RPC response")
+	}
+	for s.dec.More() {
+		key, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "result":
+			return s.readStart()
+		case "error":
+			return s.readError()
+		default:
+			var skip json.RawMessage
+			if err := s.dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	}
+	return ErrNoResult
+}
+
+// readStart consumes the start of the result array. A null result is an empty
+// stream.
+func (s *ClientStream) readStart() error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('['):
+		return nil
+	case nil:
+		s.done = true
+		return nil
+	}
+	return errors.New("streamed result is not an array")
+}
+
+// readTrailer consumes the end of the result, returning the error of the server
+// if it failed midway through the result.
+func (s *ClientStream) readTrailer() error {
+	if _, err := s.dec.Token(); err != nil {
+		return err
+	}
+	if !s.response {
+		return nil
+	}
+	for s.dec.More() {
+		key, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+		if key == "error" {
+			return s.readError()
+		}
+		var skip json.RawMessage
+		if err := s.dec.Decode(&skip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readError decodes the error member of the response.
+func (s *ClientStream) readError() error {
+	var err jsonError
+	if derr := s.dec.Decode(&err); derr != nil {
+		return derr
+	}
+	return &err
+}
+
+// Next advances the stream to its next item, returning false once the stream is
+// exhausted or failed.
+func (s *ClientStream) Next() bool {
+	if s.done || s.err != nil {
+		return false
+	}
+	if !s.dec.More() {
+		s.done = true
+		s.err = s.readTrailer()
+		return false
+	}
+	s.item = nil
+	if err := s.dec.Decode(&s.item); err != nil {
+		s.err = err
+		return false
+	}
+	return true
+}
+
+// Decode unmarshals the current item of the stream into v.
+func (s *ClientStream) Decode(v interface{}) error {
+	if s.item == nil {
+		return errors.New("no current stream item")
+	}
+	return json.Unmarshal(s.item, v)
+}
+
+// Err returns the error which stopped the stream, if any.
+func (s *ClientStream) Err() error {
+	return s.err
+}
+
+// Close releases the response being decoded. Items left unread are discarded.
+func (s *ClientStream) Close() error {
+	s.done = true
+	if s.body != nil {
+		return s.body.Close()
+	}
+	return nil
+}
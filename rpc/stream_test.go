@@ -0,0 +1,217 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type streamTestService struct{}
+
+// Items streams the integers up to n, failing before the item failAt if set.
+func (s *streamTestService) Items(n, failAt int) Stream {
+	return func(ctx context.Context, yield func(interface{}) error) error {
+		for i := 0; i < n; i++ {
+			if failAt > 0 && i == failAt {
+				return errors.New("stream failure")
+			}
+			if err := yield(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Wait streams nothing until its context is done.
+func (s *streamTestService) Wait() Stream {
+	return func(ctx context.Context, yield func(interface{}) error) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+}
+
+func newStreamTestServer() *Server {
+	server := NewServer()
+	server.RegisterName("stream", new(streamTestService))
+	return server
+}
+
+// readStream consumes a stream of integers.
+func readStream(t *testing.T, s *ClientStream) []int {
+	defer s.Close()
+
+	var items []int
+	for s.Next() {
+		var item int
+		if err := s.Decode(&item); err != nil {
+			t.Fatalf("failed to decode item: %v", err)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Tests that streamed results are written incrementally as a regular response.
+func TestStreamWire(t *testing.T) {
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+
+	server := newStreamTestServer()
+	defer server.Stop()
+	go server.ServeCodec(NewCodec(p1), 0)
+
+	p2.SetDeadline(time.Now().Add(10 * time.Second))
+	in := bufio.NewReader(p2)
+	tests := []struct {
+		req, resp string
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"stream_items","params":[3,0]}`, `{"jsonrpc":"2.0","id":1,"result":[0,1,2]}`},
+		{`{"jsonrpc":"2.0","id":2,"method":"stream_items","params":[0,0]}`, `{"jsonrpc":"2.0","id":2,"result":[]}`},
+		{`{"jsonrpc":"2.0","id":3,"method":"stream_items","params":[3,2]}`, `{"jsonrpc":"2.0","id":3,"error":{"code":-32000,"message":"stream failure"}}`},
+	}
+	for i, tt := range tests {
+		p2.Write([]byte(tt.req))
+		line, err := in.ReadString('\n')
+		if err != nil {
+			t.Fatalf("test %d: read error: %v", i, err)
+		}
+		if line != tt.resp+"\n" {
+			t.Errorf("test %d: response mismatch:\nhave %s\nwant %s", i, line, tt.resp)
+		}
+	}
+	// A failure once chunks were sent can't be answered, the connection is dropped
+	p2.Write([]byte(`{"jsonrpc":"2.0","id":4,"method":"stream_items","params":[5000,4000]}`))
+	if line, err := in.ReadString('\n'); err == nil {
+		t.Fatalf("aborted stream terminated: %s", line)
+	} else if !strings.HasPrefix(line, `{"jsonrpc":"2.0","id":4,"result":[0,1,2`) {
+		t.Fatalf("aborted stream not started: %.40s", line)
+	}
+}
+
+// Tests that streams run within the timeout of their method.
+func TestStreamTimeout(t *testing.T) {
+	server := newStreamTestServer()
+	defer server.Stop()
+	server.SetMethodTimeout("stream_wait", 50*time.Millisecond)
+	client := DialInProc(server)
+	defer client.Close()
+
+	var result []int
+	err := client.Call(&result, "stream_wait")
+	if err == nil || err.Error() != errMsgTimeout {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+// Tests that clients iterate over streamed results, over transports decoding them
+// incrementally and over the ones delivering them at once.
+func TestClientStream(t *testing.T) {
+	server := newStreamTestServer()
+	defer server.Stop()
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	httpclient, err := DialHTTP(httpsrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer httpclient.Close()
+	wssrv := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	defer wssrv.Close()
+
+	wsclient, err := DialWebsocket(context.Background(), "ws:"+strings.TrimPrefix(wssrv.URL, "http:"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wsclient.Close()
+	inproc := DialInProc(server)
+	defer inproc.Close()
+
+	for name, client := range map[string]*Client{"http": httpclient, "ws": wsclient, "inproc": inproc} {
+		s, err := client.StreamContext(context.Background(), "stream_items", 100, 0)
+		if err != nil {
+			t.Fatalf("%s: stream failed: %v", name, err)
+		}
+		items := readStream(t, s)
+		if s.Err() != nil {
+			t.Errorf("%s: stream error: %v", name, s.Err())
+		}
+		if len(items) != 100 || items[0] != 0 || items[99] != 99 {
+			t.Errorf("%s: wrong items: %v", name, items)
+		}
+		// Streamed results are also regular array results
+		var result []int
+		if err := client.Call(&result, "stream_items", 3, 0); err != nil {
+			t.Fatalf("%s: call failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(result, []int{0, 1, 2}) {
+			t.Errorf("%s: wrong call result: %v", name, result)
+		}
+	}
+	// A failure before the first chunk is answered with the error alone
+	for name, client := range map[string]*Client{"http": httpclient, "inproc": inproc} {
+		if _, err := client.StreamContext(context.Background(), "stream_items", 10, 5); err == nil || err.Error() != "stream failure" {
+			t.Errorf("%s: wrong stream error: %v", name, err)
+		}
+	}
+	// A failure midway is reported once the items sent before it are consumed
+	s, err := httpclient.StreamContext(context.Background(), "stream_items", 5000, 4000)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if items := readStream(t, s); len(items) == 0 || len(items) >= 4000 {
+		t.Errorf("wrong number of items before failure: have %d", len(items))
+	}
+	if s.Err() == nil {
+		t.Errorf("aborted stream not reported")
+	}
+}
+
+// Tests that streamed results in batches are buffered.
+func TestStreamBatch(t *testing.T) {
+	server := newStreamTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var (
+		ok     []int
+		failed []int
+	)
+	batch := []BatchElem{
+		{Method: "stream_items", Args: []interface{}{2, 0}, Result: &ok},
+		{Method: "stream_items", Args: []interface{}{2, 1}, Result: &failed},
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch[0].Error != nil || !reflect.DeepEqual(ok, []int{0, 1}) {
+		t.Errorf("wrong batch result: %v %v", ok, batch[0].Error)
+	}
+	if batch[1].Error == nil || batch[1].Error.Error() != "stream failure" {
+		t.Errorf("wrong batch error: %v", batch[1].Error)
+	}
+}
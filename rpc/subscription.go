@@ -28,6 +28,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -134,7 +135,9 @@ func (n *Notifier) Notify(id ID, data interface{}) error {
 	} else if n.sub.ID != id {
 		panic("Notify with wrong ID")
 	}
+	atomic.AddInt64(&n.h.reg.stats.queued, 1)
 	if n.activated {
+		defer atomic.AddInt64(&n.h.reg.stats.queued, -1)
 		return n.send(n.sub, enc)
 	}
 	n.buffer = append(n.buffer, enc)
@@ -162,6 +165,7 @@ func (n *Notifier) takeSubscription() *Subscription {
 func (n *Notifier) activate() error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
+	defer atomic.AddInt64(&n.h.reg.stats.queued, -int64(len(n.buffer)))
 
 	for _, data := range n.buffer {
 		if err := n.send(n.sub, data); err != nil {
@@ -174,12 +178,15 @@ func (n *Notifier) activate() error {
 
 func (n *Notifier) send(sub *Subscription, data json.RawMessage) error {
 	params, _ := json.Marshal(&subscriptionResult{ID: string(sub.ID), Result: data})
-	ctx := context.Background()
-	return n.h.conn.writeJSON(ctx, &jsonrpcMessage{
+	msg := &jsonrpcMessage{
 		Version: vsn,
 		Method:  n.namespace + notificationMethodSuffix,
 		Params:  params,
-	})
+	}
+	if n.h.batcher != nil {
+		return n.h.batcher.add(msg)
+	}
+	return n.h.conn.writeJSON(context.Background(), msg)
 }
 
 // A Subscription is created by a notifier and tied to that notifier. The client can use
@@ -203,12 +210,15 @@ func (s *Subscription) MarshalJSON() ([]byte, error) {
 // ClientSubscription is a subscription established through the Client's Subscribe or
 // OngSubscribe Methods.
 type ClientSubscription struct {
+	dropped uint64 // Notifications dropped by the backpressure policy (atomic)
+
 	client    *Client
 	etype     reflect.Type
 	channel   reflect.Value
 	namespace string
 	subid     string
 	in        chan json.RawMessage
+	opts      SubscriptionOptions
 
 	quitOnce sync.Once     // ensures quit is closed once
 	quit     chan struct{} // quit is closed when the subscription exits
@@ -216,12 +226,48 @@ type ClientSubscription struct {
 	err      chan error
 }
 
-func newClientSubscription(c *Client, namespace string, channel reflect.Value) *ClientSubscription {
+// BackpressurePolicy is what a client subscription does with the notifications
+// received while its buffer is full, because the subscriber is too slow reading
+// them from its channel.
+type BackpressurePolicy int
+
+const (
+	// BackpressureDisconnect ends the subscription, sending
+	// ErrSubscriptionQueueOverflow on its error channel.
+	BackpressureDisconnect BackpressurePolicy = iota
+
+	// BackpressureDropOldest drops the oldest buffered notification to make
+	// room for the received one.
+	BackpressureDropOldest
+
+	// BackpressureDropNewest drops the received notification.
+	BackpressureDropNewest
+
+	// BackpressureBlock waits for the subscriber to read a notification, for at
+	// most the block timeout of the subscription, before ending it like
+	// BackpressureDisconnect. While waiting, the client doesn't process any other
+	// message received from the server.
+	BackpressureBlock
+)
+
+// SubscriptionOptions configures the buffering of the notifications of a client
+// subscription. The zero value is the default configuration.
+type SubscriptionOptions struct {
+	BufferSize   int                // Maximum number of buffered notifications (0 = 20000)
+	Policy       BackpressurePolicy // Handling of the notifications received while the buffer is full
+	BlockTimeout time.Duration      // Maximum wait of BackpressureBlock (0 = unlimited)
+}
+
+func newClientSubscription(c *Client, namespace string, channel reflect.Value, opts SubscriptionOptions) *ClientSubscription {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = maxClientSubscriptionBuffer
+	}
 	sub := &ClientSubscription{
 		client:    c,
 		namespace: namespace,
 		etype:     channel.Type().Elem(),
 		channel:   channel,
+		opts:      opts,
 		quit:      make(chan struct{}),
 		err:       make(chan error, 1),
 		in:        make(chan json.RawMessage),
@@ -229,6 +275,12 @@ func newClientSubscription(c *Client, namespace string, channel reflect.Value) *
 	return sub
 }
 
+// Dropped returns the number of notifications dropped by the backpressure policy
+// of the subscription so far, allowing the subscriber to detect gaps.
+func (sub *ClientSubscription) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
 // Err returns the subscription error channel. The intended use of Err is to schedule
 // resubscription when the client connection is closed unexpectedly.
 //
@@ -307,8 +359,23 @@ func (sub *ClientSubscription) forward() (unsubscribeServer bool, err error) {
 			if err != nil {
 				return true, err
 			}
-			if buffer.Len() == maxClientSubscriptionBuffer {
-				return true, ErrSubscriptionQueueOverflow
+			if buffer.Len() >= sub.opts.BufferSize {
+				switch sub.opts.Policy {
+				case BackpressureDropOldest:
+					buffer.Remove(buffer.Front())
+					atomic.AddUint64(&sub.dropped, 1)
+				case BackpressureDropNewest:
+					atomic.AddUint64(&sub.dropped, 1)
+					continue
+				case BackpressureBlock:
+					if ok, quit := sub.waitForSpace(buffer); quit {
+						return false, nil
+					} else if !ok {
+						return true, ErrSubscriptionQueueOverflow
+					}
+				default:
+					return true, ErrSubscriptionQueueOverflow
+				}
 			}
 			buffer.PushBack(val)
 		case 2: // sub.channel<-
@@ -318,6 +385,32 @@ func (sub *ClientSubscription) forward() (unsubscribeServer bool, err error) {
 	}
 }
 
+// waitForSpace sends the first buffered notification to the subscriber, waiting
+// for at most the block timeout. It reports whether the notification was sent,
+// or whether the subscription ended meanwhile.
+func (sub *ClientSubscription) waitForSpace(buffer *list.List) (ok bool, quit bool) {
+	var timeout <-chan time.Time
+	if sub.opts.BlockTimeout > 0 {
+		timer := time.NewTimer(sub.opts.BlockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	chosen, _, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sub.quit)},
+		{Dir: reflect.SelectSend, Chan: sub.channel, Send: reflect.ValueOf(buffer.Front().Value)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timeout)},
+	})
+	switch chosen {
+	case 0:
+		return false, true
+	case 1:
+		buffer.Remove(buffer.Front())
+		return true, false
+	default:
+		return false, false
+	}
+}
+
 func (sub *ClientSubscription) unmarshal(result json.RawMessage) (interface{}, error) {
 	val := reflect.New(sub.etype)
 	err := json.Unmarshal(result, val.Interface())
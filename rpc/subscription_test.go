@@ -218,3 +218,66 @@ func readAndValidateMessage(in *json.Decoder) (*subConfirmation, *subscriptionRe
 		return nil, nil, fmt.Errorf("unrecognized message: %v", msg)
 	}
 }
+
+// This test checks that notifications are coalesced into batches when enabled.
+func TestServerNotificationBatching(t *testing.T) {
+	p1, p2 := net.Pipe()
+	defer p2.Close()
+
+	server := newTestServer()
+	server.SetNotificationBatching(100*time.Millisecond, 4)
+	service := &notificationTestService{}
+	server.RegisterName("nftest", service)
+	go server.ServeCodec(NewCodec(p1), 0)
+
+	p2.SetDeadline(time.Now().Add(10 * time.Second))
+	p2.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"nftest_subscribe","params":["someSubscription",10,0]}`))
+
+	var (
+		in      = json.NewDecoder(p2)
+		values  []int
+		batches int
+	)
+	for len(values) < 10 {
+		var raw json.RawMessage
+		if err := in.Decode(&raw); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		var msgs []*jsonrpcMessage
+		if isBatch(raw) {
+			if err := json.Unmarshal(raw, &msgs); err != nil {
+				t.Fatalf("invalid batch: %v", err)
+			}
+			if len(msgs) > 4 {
+				t.Fatalf("batch too large: have %d notifications, want at most 4", len(msgs))
+			}
+			batches++
+		} else {
+			msg := new(jsonrpcMessage)
+			if err := json.Unmarshal(raw, msg); err != nil {
+				t.Fatalf("invalid message: %v", err)
+			}
+			msgs = append(msgs, msg)
+		}
+		for _, msg := range msgs {
+			if msg.isResponse() {
+				continue
+			}
+			var res struct {
+				Result int `json:"result"`
+			}
+			if err := json.Unmarshal(msg.Params, &res); err != nil {
+				t.Fatalf("invalid subscription result: %v", err)
+			}
+			values = append(values, res.Result)
+		}
+	}
+	if batches == 0 {
+		t.Fatal("notifications not batched")
+	}
+	for i, v := range values {
+		if v != i {
+			t.Fatalf("wrong notification %d: have %d, want %d", i, v, i)
+		}
+	}
+}
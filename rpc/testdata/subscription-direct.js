@@ -0,0 +1,14 @@
+// This test checks that subscriptions named subscribe<Name> can be called
+// directly, without going through the _subscribe method.
+
+--> {"jsonrpc":"2.0","id":1,"Method":"nftest_subscribeNumbers","params":[2,1]}
+<-- {"jsonrpc":"2.0","id":1,"result":"0x1"}
+<-- {"jsonrpc":"2.0","Method":"nftest_subscription","params":{"subscription":"0x1","result":1}}
+<-- {"jsonrpc":"2.0","Method":"nftest_subscription","params":{"subscription":"0x1","result":2}}
+
+--> {"jsonrpc":"2.0","id":2,"Method":"nftest_subscribe","params":["subscribeNumbers",1,5]}
+<-- {"jsonrpc":"2.0","id":2,"result":"0x2"}
+<-- {"jsonrpc":"2.0","Method":"nftest_subscription","params":{"subscription":"0x2","result":5}}
+
+--> {"jsonrpc":"2.0","id":3,"Method":"nftest_subscribeUnknown","params":[]}
+<-- {"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"the Method nftest_subscribeUnknown does not exist/is not available"}}
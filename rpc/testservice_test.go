@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -110,6 +111,14 @@ func (s *testService) ReturnError() error {
 	return testError{}
 }
 
+func (s *testService) ReturnWrappedError() error {
+	return fmt.Errorf("wrapped: %w", testError{})
+}
+
+func (s *testService) ReturnDataError() error {
+	return NewDataError(555, "data error", map[string]int{"value": 1})
+}
+
 func (s *testService) CallMeBack(ctx context.Context, Method string, args []interface{}) (interface{}, error) {
 	c, ok := ClientFromContext(ctx)
 	if !ok {
@@ -180,6 +189,11 @@ func (s *notificationTestService) SomeSubscription(ctx context.Context, n, val i
 	return subscription, nil
 }
 
+// SubscribeNumbers is SomeSubscription, callable directly as nftest_subscribeNumbers.
+func (s *notificationTestService) SubscribeNumbers(ctx context.Context, n, val int) (*Subscription, error) {
+	return s.SomeSubscription(ctx, n, val)
+}
+
 // HangSubscription blocks on s.unblockHangSubscription before sending anything.
 func (s *notificationTestService) HangSubscription(ctx context.Context, val int) (*Subscription, error) {
 	notifier, supported := NotifierFromContext(ctx)
@@ -0,0 +1,123 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Headers of the W3C trace context.
+const (
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+)
+
+var errInvalidTraceParent = errors.New("invalid traceparent")
+
+// TracePropagator adds the trace context of a call, if any, to the headers of its
+// HTTP request, so the node's handling of the call can be linked to the trace of
+// the caller. It is the hook for distributed tracing systems, e.g. OpenTelemetry:
+//
+//    client.SetTracePropagator(func(ctx context.Context, h http.Header) {
+//        otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+//    })
+type TracePropagator func(ctx context.Context, header http.Header)
+
+// SetTracePropagator installs the trace propagator of the client requests. Passing
+// nil removes the installed one. This method only works for clients using HTTP,
+// it doesn't have any effect for clients using another transport.
+func (c *Client) SetTracePropagator(fn TracePropagator) {
+	if !c.isHTTP {
+		return
+	}
+	conn := c.writeConn.(*httpConn)
+	conn.mu.Lock()
+	conn.propagator = fn
+	conn.mu.Unlock()
+}
+
+// traceContext is a W3C trace context.
+type traceContext struct {
+	traceID [16]byte
+	flags   byte
+	state   string
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceParent returns a context carrying the W3C trace context given by
+// the values of the traceparent and tracestate headers, e.g. those of the request
+// being served, for PropagateTraceContext to send along the calls made with it.
+func ContextWithTraceParent(ctx context.Context, traceparent, tracestate string) (context.Context, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return nil, errInvalidTraceParent
+	}
+	var (
+		tc     = traceContext{state: tracestate}
+		parent [8]byte
+		flags  [1]byte
+	)
+	if err := decodeTraceField(tc.traceID[:], parts[1]); err != nil {
+		return nil, err
+	}
+	if err := decodeTraceField(parent[:], parts[2]); err != nil {
+		return nil, err
+	}
+	if err := decodeTraceField(flags[:], parts[3]); err != nil {
+		return nil, err
+	}
+	if tc.traceID == ([16]byte{}) || parent == ([8]byte{}) {
+		return nil, errInvalidTraceParent
+	}
+	tc.flags = flags[0]
+	return context.WithValue(ctx, traceContextKey{}, &tc), nil
+}
+
+// decodeTraceField decodes a lowercase hex field of a traceparent.
+func decodeTraceField(dst []byte, field string) error {
+	if len(field) != 2*len(dst) || strings.ToLower(field) != field {
+		return errInvalidTraceParent
+	}
+	if _, err := hex.Decode(dst, []byte(field)); err != nil {
+		return errInvalidTraceParent
+	}
+	return nil
+}
+
+// PropagateTraceContext is a trace propagator sending the W3C trace context set by
+// ContextWithTraceParent, if any. Every request is a new span of the trace.
+func PropagateTraceContext(ctx context.Context, header http.Header) {
+	tc, _ := ctx.Value(traceContextKey{}).(*traceContext)
+	if tc == nil {
+		return
+	}
+	var span [8]byte
+	if _, err := rand.Read(span[:]); err != nil {
+		return
+	}
+	header.Set(traceParentHeader, fmt.Sprintf("00-%x-%x-%02x", tc.traceID, span, tc.flags))
+	if tc.state != "" {
+		header.Set(traceStateHeader, tc.state)
+	}
+}
@@ -69,13 +69,15 @@ type jsonWriter interface {
 type BlockNumber int64
 
 const (
-	PendingBlockNumber  = BlockNumber(-2)
-	LatestBlockNumber   = BlockNumber(-1)
-	EarliestBlockNumber = BlockNumber(0)
+	FinalizedBlockNumber = BlockNumber(-4)
+	SafeBlockNumber      = BlockNumber(-3)
+	PendingBlockNumber   = BlockNumber(-2)
+	LatestBlockNumber    = BlockNumber(-1)
+	EarliestBlockNumber  = BlockNumber(0)
 )
 
 // UnmarshalJSON parses the given JSON fragment into a BlockNumber. It supports:
-// - "latest", "earliest" or "pending" as string arguments
+// - "latest", "earliest", "pending", "safe" or "finalized" as string arguments
 // - the block number
 // Returned errors:
 // - an invalid block number error when the given argument isn't a known strings
@@ -96,6 +98,12 @@ func (bn *BlockNumber) UnmarshalJSON(data []byte) error {
 	case "pending":
 		*bn = PendingBlockNumber
 		return nil
+	case "safe":
+		*bn = SafeBlockNumber
+		return nil
+	case "finalized":
+		*bn = FinalizedBlockNumber
+		return nil
 	}
 
 	blckNum, err := hexutil.DecodeUint64(input)
@@ -113,6 +121,32 @@ func (bn BlockNumber) Int64() int64 {
 	return (int64)(bn)
 }
 
+// MarshalText implements encoding.TextMarshaler. It marshals the block number
+// in the same form UnmarshalJSON accepts.
+func (bn BlockNumber) MarshalText() ([]byte, error) {
+	return []byte(bn.encode()), nil
+}
+
+// encode returns the block number as the JSON-RPC argument it was parsed from.
+func (bn BlockNumber) encode() string {
+	switch bn {
+	case EarliestBlockNumber:
+		return "earliest"
+	case LatestBlockNumber:
+		return "latest"
+	case PendingBlockNumber:
+		return "pending"
+	case SafeBlockNumber:
+		return "safe"
+	case FinalizedBlockNumber:
+		return "finalized"
+	}
+	if bn < 0 {
+		return fmt.Sprintf("<invalid %d>", bn)
+	}
+	return hexutil.Uint64(bn).String()
+}
+
 type BlockNumberOrHash struct {
 	BlockNumber      *BlockNumber `json:"blockNumber,omitempty"`
 	BlockHash        *common.Hash `json:"blockHash,omitempty"`
@@ -150,6 +184,14 @@ func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
 		bn := PendingBlockNumber
 		bnh.BlockNumber = &bn
 		return nil
+	case "safe":
+		bn := SafeBlockNumber
+		bnh.BlockNumber = &bn
+		return nil
+	case "finalized":
+		bn := FinalizedBlockNumber
+		bnh.BlockNumber = &bn
+		return nil
 	default:
 		if len(input) == 66 {
 			hash := common.Hash{}
@@ -174,6 +216,17 @@ func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
 	}
 }
 
+// String returns the block number or hash as a JSON-RPC argument.
+func (bnh *BlockNumberOrHash) String() string {
+	if bnh.BlockNumber != nil {
+		return bnh.BlockNumber.encode()
+	}
+	if bnh.BlockHash != nil {
+		return bnh.BlockHash.String()
+	}
+	return "nil"
+}
+
 func (bnh *BlockNumberOrHash) Number() (BlockNumber, bool) {
 	if bnh.BlockNumber != nil {
 		return *bnh.BlockNumber, true
@@ -203,3 +256,42 @@ func BlockNumberOrHashWithHash(hash common.Hash, canonical bool) BlockNumberOrHa
 		RequireCanonical: canonical,
 	}
 }
+
+// BlockRange is an inclusive range of blocks. Each end may be given as a block
+// number, a block tag or a block hash, the latter pinning the range to a given
+// branch of the chain.
+type BlockRange struct {
+	From BlockNumberOrHash `json:"fromBlock"`
+	To   BlockNumberOrHash `json:"toBlock"`
+}
+
+// BlockResolver maps a block number, tag or hash onto the number of the block
+// it designates.
+type BlockResolver func(BlockNumberOrHash) (uint64, error)
+
+// Resolve maps both ends of the range onto block numbers and checks that they
+// span at most maxSpan blocks (0 means no limit). A range starting after its end
+// is empty rather than invalid, e.g. polling from the block after the head up to
+// the latest one, and is left to the caller to handle.
+func (r BlockRange) Resolve(resolve BlockResolver, maxSpan uint64) (from, to uint64, err error) {
+	if from, err = resolve(r.From); err != nil {
+		return 0, 0, err
+	}
+	if to, err = resolve(r.To); err != nil {
+		return 0, 0, err
+	}
+	if err := CheckBlockSpan(from, to, maxSpan); err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+// CheckBlockSpan checks that the inclusive range from from to to covers at most
+// maxSpan blocks (0 means no limit). Empty ranges, with from after to, always
+// pass. The errors are reported to the caller as invalid parameters.
+func CheckBlockSpan(from, to, maxSpan uint64) error {
+	if maxSpan > 0 && from <= to && to-from >= maxSpan {
+		return &invalidParamsError{fmt.Sprintf("block range too large: %d blocks, the limit is %d", to-from+1, maxSpan)}
+	}
+	return nil
+}
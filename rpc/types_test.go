@@ -18,6 +18,7 @@ package rpc
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/ong2020/go-orange/common"
@@ -47,6 +48,8 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 		14: {`someString`, true, BlockNumber(0)},
 		15: {`""`, true, BlockNumber(0)},
 		16: {``, true, BlockNumber(0)},
+		17: {`"safe"`, false, SafeBlockNumber},
+		18: {`"finalized"`, false, FinalizedBlockNumber},
 	}
 
 	for i, test := range tests {
@@ -98,6 +101,10 @@ func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 		23: {`{"blockNumber":"latest"}`, false, BlockNumberOrHashWithNumber(LatestBlockNumber)},
 		24: {`{"blockNumber":"earliest"}`, false, BlockNumberOrHashWithNumber(EarliestBlockNumber)},
 		25: {`{"blockNumber":"0x1", "blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000"}`, true, BlockNumberOrHash{}},
+		26: {`"safe"`, false, BlockNumberOrHashWithNumber(SafeBlockNumber)},
+		27: {`"finalized"`, false, BlockNumberOrHashWithNumber(FinalizedBlockNumber)},
+		28: {`{"blockNumber":"safe"}`, false, BlockNumberOrHashWithNumber(SafeBlockNumber)},
+		29: {`{"blockNumber":"finalized"}`, false, BlockNumberOrHashWithNumber(FinalizedBlockNumber)},
 	}
 
 	for i, test := range tests {
@@ -122,3 +129,104 @@ func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockNumberOrHash_StringRoundTrip(t *testing.T) {
+	tests := []BlockNumberOrHash{
+		BlockNumberOrHashWithNumber(EarliestBlockNumber),
+		BlockNumberOrHashWithNumber(LatestBlockNumber),
+		BlockNumberOrHashWithNumber(PendingBlockNumber),
+		BlockNumberOrHashWithNumber(SafeBlockNumber),
+		BlockNumberOrHashWithNumber(FinalizedBlockNumber),
+		BlockNumberOrHashWithNumber(18),
+		BlockNumberOrHashWithHash(common.HexToHash("0xdeadbeef"), false),
+	}
+	for i, want := range tests {
+		input, _ := json.Marshal(want.String())
+
+		var have BlockNumberOrHash
+		if err := json.Unmarshal(input, &have); err != nil {
+			t.Errorf("test %d: failed to parse %s: %v", i, input, err)
+			continue
+		}
+		if have.String() != want.String() {
+			t.Errorf("test %d: round trip mismatch: have %v, want %v", i, have.String(), want.String())
+		}
+	}
+}
+
+func TestBlockNumberOrHash_JSONRoundTrip(t *testing.T) {
+	tests := []BlockNumberOrHash{
+		BlockNumberOrHashWithNumber(EarliestBlockNumber),
+		BlockNumberOrHashWithNumber(LatestBlockNumber),
+		BlockNumberOrHashWithNumber(PendingBlockNumber),
+		BlockNumberOrHashWithNumber(18),
+		BlockNumberOrHashWithHash(common.HexToHash("0xdeadbeef"), false),
+		BlockNumberOrHashWithHash(common.HexToHash("0xdeadbeef"), true),
+	}
+	for i, want := range tests {
+		input, err := json.Marshal(want)
+		if err != nil {
+			t.Errorf("test %d: failed to marshal %v: %v", i, want, err)
+			continue
+		}
+		var have BlockNumberOrHash
+		if err := json.Unmarshal(input, &have); err != nil {
+			t.Errorf("test %d: failed to parse %s: %v", i, input, err)
+			continue
+		}
+		if have.String() != want.String() || have.RequireCanonical != want.RequireCanonical {
+			t.Errorf("test %d: round trip mismatch: have %s, want %s", i, input, want.String())
+		}
+	}
+}
+
+func TestBlockRangeResolve(t *testing.T) {
+	var (
+		head = uint64(100)
+		hash = common.HexToHash("0xdeadbeef")
+	)
+	resolve := func(bnh BlockNumberOrHash) (uint64, error) {
+		if h, ok := bnh.Hash(); ok {
+			if h != hash {
+				return 0, fmt.Errorf("unknown block %x", h)
+			}
+			return 42, nil
+		}
+		number, _ := bnh.Number()
+		if number == LatestBlockNumber {
+			return head, nil
+		}
+		return uint64(number), nil
+	}
+	tests := []struct {
+		from, to BlockNumberOrHash
+		maxSpan  uint64
+		wantFrom uint64
+		wantTo   uint64
+		wantErr  bool
+	}{
+		{BlockNumberOrHashWithNumber(10), BlockNumberOrHashWithNumber(LatestBlockNumber), 0, 10, 100, false},
+		{BlockNumberOrHashWithHash(hash, false), BlockNumberOrHashWithNumber(50), 9, 42, 50, false},
+		{BlockNumberOrHashWithHash(hash, false), BlockNumberOrHashWithNumber(50), 8, 0, 0, true},
+		{BlockNumberOrHashWithNumber(50), BlockNumberOrHashWithHash(hash, false), 1, 50, 42, false},
+		{BlockNumberOrHashWithNumber(101), BlockNumberOrHashWithNumber(LatestBlockNumber), 1, 101, 100, false},
+		{BlockNumberOrHashWithHash(common.Hash{}, false), BlockNumberOrHashWithNumber(50), 0, 0, 0, true},
+		{BlockNumberOrHashWithNumber(7), BlockNumberOrHashWithNumber(7), 1, 7, 7, false},
+	}
+	for i, test := range tests {
+		from, to, err := BlockRange{From: test.from, To: test.to}.Resolve(resolve, test.maxSpan)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("test %d: expected error, got range %d-%d", i, from, to)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if from != test.wantFrom || to != test.wantTo {
+			t.Errorf("test %d: range mismatch: have %d-%d, want %d-%d", i, from, to, test.wantFrom, test.wantTo)
+		}
+	}
+}
@@ -19,7 +19,10 @@ package rpc
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -37,29 +40,101 @@ const (
 	wsWriteBuffer      = 1024
 	wsPingInterval     = 60 * time.Second
 	wsPingWriteTimeout = 5 * time.Second
+	wsPongTimeout      = 30 * time.Second
 	wsMessageSizeLimit = 15 * 1024 * 1024
 )
 
 var wsBufferPool = new(sync.Pool)
 
+// WebsocketConfig configures the WebSocket connections of a server or a client.
+// The zero value is the default configuration.
+type WebsocketConfig struct {
+	// Compression enables the negotiation of the permessage-deflate extension,
+	// compressing the messages if the other side supports it.
+	Compression bool
+
+	// CompressionLevel is the flate compression level of the sent messages, from
+	// -2 (Huffman only) to 9 (best compression). Zero uses the default level.
+	CompressionLevel int
+
+	// ReadLimit is the maximum size in bytes of the received messages, applying
+	// both to the messages as sent over the wire and, if compressed, once
+	// decompressed. Larger messages close the connection. Zero uses the default
+	// limit of 15MB.
+	ReadLimit int64
+
+	// ReadBufferSize and WriteBufferSize are the sizes of the I/O buffers of the
+	// connections. Zero uses the default size of 1KB.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// PingInterval is the time without receiving anything from the peer before
+	// a ping frame is sent to it. Zero uses the default interval of 60s.
+	PingInterval time.Duration
+
+	// PongTimeout is the time the peer has to answer a ping before it is deemed
+	// dead and the connection closed. Zero uses the default timeout of 30s, a
+	// negative value disables the detection of dead peers.
+	//
+	// Unless disabled, the connections also have a read deadline: nothing at all
+	// received for PingInterval+PongTimeout, not even the answer to a ping, closes
+	// the connection. The pending calls and the subscriptions over it then fail
+	// with ErrPeerUnresponsive.
+	PongTimeout time.Duration
+}
+
+// withDefaults returns the configuration with the unset values defaulted.
+func (c WebsocketConfig) withDefaults() WebsocketConfig {
+	if c.ReadLimit <= 0 {
+		c.ReadLimit = wsMessageSizeLimit
+	}
+	if c.ReadBufferSize <= 0 {
+		c.ReadBufferSize = wsReadBuffer
+	}
+	if c.WriteBufferSize <= 0 {
+		c.WriteBufferSize = wsWriteBuffer
+	}
+	if c.PingInterval <= 0 {
+		c.PingInterval = wsPingInterval
+	}
+	if c.PongTimeout == 0 {
+		c.PongTimeout = wsPongTimeout
+	}
+	return c
+}
+
 // WebsocketHandler returns a handler that serves JSON-RPC to WebSocket connections.
 //
 // allowedOrigins should be a comma-separated list of allowed origin URLs.
 // To allow connections with any origin, pass "*".
 func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
+	return s.WebsocketHandlerWithConfig(allowedOrigins, WebsocketConfig{})
+}
+
+// WebsocketHandlerWithConfig returns a handler that serves JSON-RPC to WebSocket
+// connections configured by config. See WebsocketHandler for allowedOrigins.
+func (s *Server) WebsocketHandlerWithConfig(allowedOrigins []string, config WebsocketConfig) http.Handler {
+	config = config.withDefaults()
 	var upgrader = websocket.Upgrader{
-		ReadBufferSize:  wsReadBuffer,
-		WriteBufferSize: wsWriteBuffer,
-		WriteBufferPool: wsBufferPool,
-		CheckOrigin:     wsHandshakeValidator(allowedOrigins),
+		ReadBufferSize:    config.ReadBufferSize,
+		WriteBufferSize:   config.WriteBufferSize,
+		WriteBufferPool:   wsBufferPool,
+		CheckOrigin:       wsHandshakeValidator(allowedOrigins),
+		EnableCompression: config.Compression,
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := s.authenticate(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Debug("WebSocket upgrade failed", "err", err)
 			return
 		}
-		codec := newWebsocketCodec(conn)
+		codec := newWebsocketCodec(conn, config)
+		codec.(*websocketCodec).identity = identity
 		s.ServeCodec(codec, 0)
 	})
 }
@@ -183,6 +258,10 @@ func parseOriginURL(origin string) (string, string, string, error) {
 // DialWebsocketWithDialer creates a new RPC client that communicates with a JSON-RPC server
 // that is listening on the given endpoint using the provided dialer.
 func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, dialer websocket.Dialer) (*Client, error) {
+	return dialWebsocket(ctx, endpoint, origin, dialer, WebsocketConfig{}.withDefaults())
+}
+
+func dialWebsocket(ctx context.Context, endpoint, origin string, dialer websocket.Dialer, config WebsocketConfig) (*Client, error) {
 	endpoint, header, err := wsClientHeaders(endpoint, origin)
 	if err != nil {
 		return nil, err
@@ -196,7 +275,7 @@ func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, diale
 			}
 			return nil, hErr
 		}
-		return newWebsocketCodec(conn), nil
+		return newWebsocketCodec(conn, config), nil
 	})
 }
 
@@ -206,12 +285,24 @@ func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, diale
 // The context is used for the initial connection establishment. It does not
 // affect subsequent interactions with the client.
 func DialWebsocket(ctx context.Context, endpoint, origin string) (*Client, error) {
+	return DialWebsocketWithConfig(ctx, endpoint, origin, WebsocketConfig{})
+}
+
+// DialWebsocketWithConfig creates a new RPC client that communicates with a JSON-RPC
+// server that is listening on the given endpoint, using connections configured by
+// config.
+//
+// The context is used for the initial connection establishment. It does not
+// affect subsequent interactions with the client.
+func DialWebsocketWithConfig(ctx context.Context, endpoint, origin string, config WebsocketConfig) (*Client, error) {
+	config = config.withDefaults()
 	dialer := websocket.Dialer{
-		ReadBufferSize:  wsReadBuffer,
-		WriteBufferSize: wsWriteBuffer,
-		WriteBufferPool: wsBufferPool,
+		ReadBufferSize:    config.ReadBufferSize,
+		WriteBufferSize:   config.WriteBufferSize,
+		WriteBufferPool:   wsBufferPool,
+		EnableCompression: config.Compression,
 	}
-	return DialWebsocketWithDialer(ctx, endpoint, origin, dialer)
+	return dialWebsocket(ctx, endpoint, origin, dialer, config)
 }
 
 func wsClientHeaders(endpoint, origin string) (string, http.Header, error) {
@@ -233,44 +324,138 @@ func wsClientHeaders(endpoint, origin string) (string, http.Header, error) {
 
 type websocketCodec struct {
 	*jsonCodec
-	conn *websocket.Conn
+	conn     *websocket.Conn
+	identity string // authenticated client, if any
 
-	wg        sync.WaitGroup
-	pingReset chan struct{}
+	wg           sync.WaitGroup
+	readLimit    int64 // size limit of the decompressed messages
+	pingReset    chan struct{}
+	pongReceived chan struct{}
+	pingInterval time.Duration
+	pongTimeout  time.Duration // negative if dead peers aren't detected
 }
 
-func newWebsocketCodec(conn *websocket.Conn) ServerCodec {
-	conn.SetReadLimit(wsMessageSizeLimit)
+func newWebsocketCodec(conn *websocket.Conn, config WebsocketConfig) ServerCodec {
+	conn.SetReadLimit(config.ReadLimit)
+	if config.CompressionLevel != 0 {
+		// Invalid levels are refused, leaving the default one
+		conn.SetCompressionLevel(config.CompressionLevel)
+	}
 	wc := &websocketCodec{
-		jsonCodec: NewFuncCodec(conn, conn.WriteJSON, conn.ReadJSON).(*jsonCodec),
-		conn:      conn,
-		pingReset: make(chan struct{}, 1),
+		conn:         conn,
+		readLimit:    config.ReadLimit,
+		pingReset:    make(chan struct{}, 1),
+		pongReceived: make(chan struct{}, 1),
+		pingInterval: config.PingInterval,
+		pongTimeout:  config.PongTimeout,
 	}
+	wc.jsonCodec = NewFuncCodec(conn, conn.WriteJSON, wc.readJSON).(*jsonCodec)
+	wc.jsonCodec.remote = conn.RemoteAddr().String()
+	wc.received()
+
+	conn.SetPongHandler(func(string) error {
+		wc.received()
+		select {
+		case wc.pongReceived <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	conn.SetPingHandler(func(data string) error {
+		// Answer like the default handler, recording the peer alive.
+		wc.received()
+		wc.liveness.seen()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(wsPingWriteTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		} else if e, ok := err.(net.Error); ok && e.Temporary() {
+			return nil
+		}
+		return err
+	})
 	wc.wg.Add(1)
 	go wc.pingLoop()
 	return wc
 }
 
+func (wc *websocketCodec) authIdentity() string {
+	return wc.identity
+}
+
 func (wc *websocketCodec) close() {
 	wc.jsonCodec.close()
 	wc.wg.Wait()
 }
 
-func (wc *websocketCodec) writeJSON(ctx context.Context, v interface{}) error {
-	err := wc.jsonCodec.writeJSON(ctx, v)
+// readJSON reads the next message, failing once the read deadline is exceeded.
+// The connection only limits the size of the compressed messages, so the limit
+// is also enforced on the decompressed stream to not inflate arbitrarily small
+// frames into huge messages.
+func (wc *websocketCodec) readJSON(v interface{}) error {
+	_, r, err := wc.conn.NextReader()
 	if err == nil {
-		// Notify pingLoop to delay the next idle ping.
-		select {
-		case wc.pingReset <- struct{}{}:
-		default:
+		err = json.NewDecoder(&readLimiter{r: r, limit: wc.readLimit}).Decode(v)
+		if err == io.EOF {
+			// One value is expected in the message
+			err = io.ErrUnexpectedEOF
 		}
 	}
+	if err == nil {
+		wc.received()
+		return nil
+	}
+	if e, ok := err.(net.Error); ok && e.Timeout() && wc.pongTimeout > 0 {
+		log.Debug("WebSocket peer silent, closing connection", "conn", wc.remote, "timeout", wc.pingInterval+wc.pongTimeout)
+		wc.liveness.markDead()
+	}
 	return err
 }
 
-// pingLoop sends periodic ping frames when the connection is idle.
+// readLimiter fails reading a message once it exceeds the read limit.
+type readLimiter struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+func (l *readLimiter) Read(p []byte) (int, error) {
+	// Read up to one byte past the limit to detect longer messages, but don't
+	// hand it out: the decoder could complete a value with it.
+	if left := l.limit - l.read + 1; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := l.r.Read(p)
+	if l.read += int64(n); l.read > l.limit {
+		return n - 1, websocket.ErrReadLimit
+	}
+	return n, err
+}
+
+// received records a frame received from the peer: it extends the read deadline
+// and delays the next ping. It's called by the reading goroutine only.
+//
+// The pings are scheduled by the received frames rather than the sent ones, so
+// that a peer only receiving, e.g. subscription notifications, still answers
+// pings in time to not hit the read deadline.
+func (wc *websocketCodec) received() {
+	if wc.pongTimeout > 0 {
+		wc.conn.SetReadDeadline(time.Now().Add(wc.pingInterval + wc.pongTimeout))
+	}
+	select {
+	case wc.pingReset <- struct{}{}:
+	default:
+	}
+}
+
+// pingLoop sends periodic ping frames when nothing was received from the peer
+// for the ping interval, closing the connection if the peer doesn't answer them
+// within the pong timeout.
 func (wc *websocketCodec) pingLoop() {
-	var timer = time.NewTimer(wsPingInterval)
+	var (
+		timer    = time.NewTimer(wc.pingInterval)
+		sent     time.Time
+		pongWait <-chan time.Time // set while waiting for a pong
+	)
 	defer wc.wg.Done()
 	defer timer.Stop()
 
@@ -282,13 +467,26 @@ func (wc *websocketCodec) pingLoop() {
 			if !timer.Stop() {
 				<-timer.C
 			}
-			timer.Reset(wsPingInterval)
+			timer.Reset(wc.pingInterval)
 		case <-timer.C:
 			wc.jsonCodec.encMu.Lock()
 			wc.conn.SetWriteDeadline(time.Now().Add(wsPingWriteTimeout))
 			wc.conn.WriteMessage(websocket.PingMessage, nil)
 			wc.jsonCodec.encMu.Unlock()
-			timer.Reset(wsPingInterval)
+			if pongWait == nil && wc.pongTimeout > 0 {
+				sent, pongWait = time.Now(), time.After(wc.pongTimeout)
+			}
+			timer.Reset(wc.pingInterval)
+		case <-wc.pongReceived:
+			if pongWait != nil {
+				wc.liveness.answered(sent)
+				pongWait = nil
+			}
+		case <-pongWait:
+			log.Debug("WebSocket peer unresponsive, closing connection", "conn", wc.remote, "timeout", wc.pongTimeout)
+			wc.liveness.markDead()
+			wc.jsonCodec.close()
+			return
 		}
 	}
 }
@@ -18,6 +18,8 @@ package rpc
 
 import (
 	"context"
+	"encoding/hex"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -113,6 +115,65 @@ func TestWebsocketLargeCall(t *testing.T) {
 	}
 }
 
+// This test checks that permessage-deflate is negotiated when enabled, and that the
+// read limit of the server applies.
+func TestWebsocketCompression(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv     = newTestServer()
+		config  = WebsocketConfig{Compression: true, CompressionLevel: 9, ReadLimit: 4096}
+		httpsrv = httptest.NewServer(srv.WebsocketHandlerWithConfig([]string{"*"}, config))
+		wsURL   = "ws:" + strings.TrimPrefix(httpsrv.URL, "http:")
+	)
+	defer srv.Stop()
+	defer httpsrv.Close()
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, resp, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	conn.Close()
+	if ext := resp.Header.Get("Sec-Websocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("compression not negotiated, extensions %q", ext)
+	}
+
+	client, err := DialWebsocketWithConfig(context.Background(), wsURL, "", WebsocketConfig{Compression: true})
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	defer client.Close()
+
+	var result echoResult
+	arg := strings.Repeat("x", 2048)
+	if err := client.Call(&result, "test_echo", arg, 1); err != nil {
+		t.Fatalf("valid call didn't work: %v", err)
+	}
+	if result.String != arg {
+		t.Fatal("wrong string echoed")
+	}
+	// Messages over the limit once decompressed are refused, even if they are
+	// small on the wire.
+	arg = strings.Repeat("x", 16384)
+	if err := client.Call(&result, "test_echo", arg, 1); err == nil {
+		t.Fatal("no error for compressed call over the read limit")
+	}
+	client.Close()
+
+	// Messages over the limit on the wire are refused too
+	client, err = DialWebsocketWithConfig(context.Background(), wsURL, "", WebsocketConfig{Compression: true})
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	blob := make([]byte, 16384)
+	rand.Read(blob)
+	arg = hex.EncodeToString(blob)
+	if err := client.Call(&result, "test_echo", arg, 1); err == nil {
+		t.Fatal("no error for call over the read limit")
+	}
+}
+
 // This test checks that client handles WebSocket ping frames correctly.
 func TestClientWebsocketPing(t *testing.T) {
 	t.Parallel()
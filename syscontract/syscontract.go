@@ -0,0 +1,331 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package syscontract serves read-only calls to the system contracts of a network,
+// such as the governance and validator set contracts of PoA networks managing
+// their validators on-chain, decoding the results with the ABIs of the contracts.
+package syscontract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/ong2020/go-orange/accounts/abi"
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/common/hexutil"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/internal/ongapi"
+	"github.com/ong2020/go-orange/node"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+// Contract is a system contract served by the API.
+type Contract struct {
+	Name    string         // Name the contract is called by
+	Address common.Address // Address of the contract
+	ABI     string         // Path of the JSON ABI of the contract
+}
+
+// Config are the configuration parameters of the system contract API.
+type Config struct {
+	Contracts []Contract `toml:",omitempty"` // System contracts served (none = disabled)
+}
+
+// contract is a system contract with its parsed ABI.
+type contract struct {
+	address common.Address
+	abi     abi.ABI
+}
+
+// caller executes a read-only call on the state of a block.
+type caller func(ctx context.Context, args ongapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash) ([]byte, error)
+
+// New registers the system contract API on the given node. The ABIs of the
+// contracts are loaded right away, failing on invalid ones.
+func New(stack *node.Node, backend ongapi.Backend, config Config) error {
+	contracts, err := loadContracts(config.Contracts)
+	if err != nil {
+		return err
+	}
+	stack.RegisterAPIs([]rpc.API{{
+		Namespace: "system",
+		Version:   "1.0",
+		Service:   newAPI(contracts, backendCaller(backend)),
+		Public:    true,
+	}})
+	return nil
+}
+
+// loadContracts parses the ABIs of the system contracts.
+func loadContracts(config []Contract) (map[string]*contract, error) {
+	contracts := make(map[string]*contract)
+	for _, c := range config {
+		if c.Name == "" {
+			return nil, fmt.Errorf("system contract %x has no name", c.Address)
+		}
+		if _, ok := contracts[c.Name]; ok {
+			return nil, fmt.Errorf("duplicate system contract %q", c.Name)
+		}
+		file, err := os.Open(c.ABI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ABI of system contract %q: %v", c.Name, err)
+		}
+		parsed, err := abi.JSON(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid ABI of system contract %q: %v", c.Name, err)
+		}
+		contracts[c.Name] = &contract{address: c.Address, abi: parsed}
+	}
+	return contracts, nil
+}
+
+// backendCaller executes calls like ong_call, within the gas cap, timeout and
+// execution budget of the node.
+func backendCaller(b ongapi.Backend) caller {
+	return func(ctx context.Context, args ongapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash) ([]byte, error) {
+		timeout, release, err := b.RPCExecutionBudget().Acquire(ctx, b.RPCEVMTimeout())
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		result, err := ongapi.DoCall(ctx, b, args, blockNrOrHash, nil, vm.Config{}, timeout, b.RPCGasCap())
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Revert()) > 0 {
+			return nil, ongapi.NewRevertError(result)
+		}
+		return result.Return(), result.Err
+	}
+}
+
+// API offers read-only calls to the view methods of the system contracts.
+type API struct {
+	contracts map[string]*contract
+	call      caller
+}
+
+func newAPI(contracts map[string]*contract, call caller) *API {
+	return &API{contracts: contracts, call: call}
+}
+
+// ContractInfo describes a system contract.
+type ContractInfo struct {
+	Name    string         `json:"name"`
+	Address common.Address `json:"address"`
+	Methods []string       `json:"methods"` // Signatures of the view methods
+}
+
+// Contracts lists the system contracts with their view methods.
+func (api *API) Contracts() []ContractInfo {
+	infos := make([]ContractInfo, 0, len(api.contracts))
+	for name, c := range api.contracts {
+		info := ContractInfo{Name: name, Address: c.address, Methods: []string{}}
+		for _, method := range c.abi.Methods {
+			if method.IsConstant() {
+				info.Methods = append(info.Methods, method.Sig)
+			}
+		}
+		sort.Strings(info.Methods)
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Call calls a view method of a system contract on the state of the given block,
+// the latest one if unset. The arguments are the JSON values of the inputs of
+// the method: numbers as decimal or hex strings, bytes as hex strings. The
+// results are returned by name, unnamed ones by position.
+func (api *API) Call(ctx context.Context, name string, method string, args []json.RawMessage, blockNrOrHash *rpc.BlockNumberOrHash) (map[string]interface{}, error) {
+	c, ok := api.contracts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown system contract %q", name)
+	}
+	m, ok := c.abi.Methods[method]
+	if !ok {
+		return nil, fmt.Errorf("system contract %q has no method %q", name, method)
+	}
+	if !m.IsConstant() {
+		return nil, fmt.Errorf("method %q of system contract %q is not a view", method, name)
+	}
+	if len(args) != len(m.Inputs) {
+		return nil, fmt.Errorf("method %q takes %d arguments, %d given", method, len(m.Inputs), len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i, input := range m.Inputs {
+		value, err := decodeArg(input.Type, args[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %d (%s): %v", i, input.Type, err)
+		}
+		values[i] = value.Interface()
+	}
+	input, err := c.abi.Pack(method, values...)
+	if err != nil {
+		return nil, err
+	}
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	data := hexutil.Bytes(input)
+	output, err := api.call(ctx, ongapi.CallArgs{To: &c.address, Data: &data}, *blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	results, err := m.Outputs.Unpack(output)
+	if err != nil {
+		return nil, err
+	}
+	named := make(map[string]interface{}, len(results))
+	for i, result := range results {
+		key := m.Outputs[i].Name
+		if key == "" {
+			key = fmt.Sprint(i)
+		}
+		named[key] = formatResult(reflect.ValueOf(result))
+	}
+	return named, nil
+}
+
+// decodeArg decodes the JSON value of an argument into the Go type of its ABI type.
+func decodeArg(t abi.Type, raw json.RawMessage) (reflect.Value, error) {
+	typ := t.GetType()
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		var n hexOrDecimal
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return reflect.Value{}, err
+		}
+		if typ == reflect.TypeOf(new(big.Int)) {
+			return reflect.ValueOf((*big.Int)(&n)), nil
+		}
+		v := reflect.New(typ).Elem()
+		if t.T == abi.IntTy {
+			if !(*big.Int)(&n).IsInt64() || v.OverflowInt((*big.Int)(&n).Int64()) {
+				return reflect.Value{}, errors.New("integer out of range")
+			}
+			v.SetInt((*big.Int)(&n).Int64())
+		} else {
+			if !(*big.Int)(&n).IsUint64() || v.OverflowUint((*big.Int)(&n).Uint64()) {
+				return reflect.Value{}, errors.New("integer out of range")
+			}
+			v.SetUint((*big.Int)(&n).Uint64())
+		}
+		return v, nil
+
+	case abi.BoolTy, abi.StringTy, abi.AddressTy, abi.HashTy:
+		v := reflect.New(typ)
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return v.Elem(), nil
+
+	case abi.BytesTy:
+		var b hexutil.Bytes
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf([]byte(b)), nil
+
+	case abi.FixedBytesTy:
+		var b hexutil.Bytes
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return reflect.Value{}, err
+		}
+		if len(b) != t.Size {
+			return reflect.Value{}, fmt.Errorf("have %d bytes, want %d", len(b), t.Size)
+		}
+		v := reflect.New(typ).Elem()
+		reflect.Copy(v, reflect.ValueOf([]byte(b)))
+		return v, nil
+
+	case abi.SliceTy, abi.ArrayTy:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return reflect.Value{}, err
+		}
+		var v reflect.Value
+		if t.T == abi.SliceTy {
+			v = reflect.MakeSlice(typ, len(elems), len(elems))
+		} else {
+			if len(elems) != t.Size {
+				return reflect.Value{}, fmt.Errorf("have %d elements, want %d", len(elems), t.Size)
+			}
+			v = reflect.New(typ).Elem()
+		}
+		for i, elem := range elems {
+			ev, err := decodeArg(*t.Elem, elem)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %v", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported type %s", t)
+}
+
+// hexOrDecimal is an integer given as a JSON number, or as a decimal or hex string.
+type hexOrDecimal big.Int
+
+func (n *hexOrDecimal) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		s = string(input)
+	}
+	if _, ok := (*big.Int)(n).SetString(s, 0); !ok {
+		return fmt.Errorf("invalid integer %s", input)
+	}
+	return nil
+}
+
+// formatResult converts a result into its JSON representation: big integers and
+// bytes as hex strings, and tuples as objects of their named fields.
+func formatResult(v reflect.Value) interface{} {
+	switch {
+	case v.Type() == reflect.TypeOf(new(big.Int)):
+		return (*hexutil.Big)(v.Interface().(*big.Int))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return hexutil.Bytes(v.Bytes())
+	case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 && v.Type() != reflect.TypeOf(common.Address{}):
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return hexutil.Bytes(b)
+	case v.Kind() == reflect.Slice || (v.Kind() == reflect.Array && v.Type() != reflect.TypeOf(common.Address{})):
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = formatResult(v.Index(i))
+		}
+		return items
+	case v.Kind() == reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			tag := v.Type().Field(i).Tag.Get("json")
+			fields[tag] = formatResult(v.Field(i))
+		}
+		return fields
+	}
+	return v.Interface()
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package syscontract
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/internal/ongapi"
+	"github.com/ong2020/go-orange/rpc"
+)
+
+const validatorsABI = `[
+	{"type":"function","name":"validators","stateMutability":"view","inputs":[{"name":"epoch","type":"uint64"}],"outputs":[{"name":"validators","type":"address[]"},{"name":"threshold","type":"uint256"}]},
+	{"type":"function","name":"isValidator","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"setThreshold","stateMutability":"nonpayable","inputs":[{"name":"threshold","type":"uint256"}],"outputs":[]}
+]`
+
+func newTestAPI(t *testing.T, call caller) *API {
+	path := filepath.Join(t.TempDir(), "validators.json")
+	if err := ioutil.WriteFile(path, []byte(validatorsABI), 0600); err != nil {
+		t.Fatal(err)
+	}
+	contracts, err := loadContracts([]Contract{{Name: "validators", Address: common.HexToAddress("0x1000"), ABI: path}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newAPI(contracts, call)
+}
+
+// Tests that view methods of the system contracts are called with the arguments
+// encoded from JSON, and their results decoded by name.
+func TestCall(t *testing.T) {
+	var (
+		validators = []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+		block      rpc.BlockNumberOrHash
+		api        *API
+	)
+	api = newTestAPI(t, func(ctx context.Context, args ongapi.CallArgs, blockNrOrHash rpc.BlockNumberOrHash) ([]byte, error) {
+		block = blockNrOrHash
+		if *args.To != common.HexToAddress("0x1000") {
+			t.Errorf("call to wrong contract %x", *args.To)
+		}
+		c := api.contracts["validators"]
+		switch {
+		case reflect.DeepEqual([]byte(*args.Data), packOrFail(t, c, "validators", uint64(42))):
+			return c.abi.Methods["validators"].Outputs.Pack(validators, big.NewInt(2))
+		case reflect.DeepEqual([]byte(*args.Data), packOrFail(t, c, "isValidator", validators[0])):
+			return c.abi.Methods["isValidator"].Outputs.Pack(true)
+		}
+		t.Fatalf("unexpected call data %x", *args.Data)
+		return nil, nil
+	})
+	number := rpc.BlockNumberOrHashWithNumber(7)
+	result, err := api.Call(context.Background(), "validators", "validators", []json.RawMessage{json.RawMessage(`"0x2a"`)}, &number)
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if n, _ := block.Number(); n != 7 {
+		t.Errorf("call on wrong block %v", block)
+	}
+	enc, _ := json.Marshal(result)
+	if want := `{"threshold":"0x2","validators":["0x0000000000000000000000000000000000000001","0x0000000000000000000000000000000000000002"]}`; string(enc) != want {
+		t.Errorf("result mismatch:\nhave %s\nwant %s", enc, want)
+	}
+	result, err = api.Call(context.Background(), "validators", "isValidator", []json.RawMessage{json.RawMessage(`"0x0000000000000000000000000000000000000001"`)}, nil)
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if n, _ := block.Number(); n != rpc.LatestBlockNumber {
+		t.Errorf("call on wrong block %v", block)
+	}
+	if !reflect.DeepEqual(result, map[string]interface{}{"0": true}) {
+		t.Errorf("result mismatch: %v", result)
+	}
+	// Unknown contracts and methods, state changing methods and invalid arguments are refused
+	failures := []struct {
+		contract, method string
+		args             []json.RawMessage
+	}{
+		{"governance", "validators", []json.RawMessage{json.RawMessage(`1`)}},
+		{"validators", "owner", nil},
+		{"validators", "setThreshold", []json.RawMessage{json.RawMessage(`1`)}},
+		{"validators", "validators", nil},
+		{"validators", "validators", []json.RawMessage{json.RawMessage(`"0x10000000000000000"`)}},
+		{"validators", "isValidator", []json.RawMessage{json.RawMessage(`true`)}},
+	}
+	for i, tt := range failures {
+		if _, err := api.Call(context.Background(), tt.contract, tt.method, tt.args, nil); err == nil {
+			t.Errorf("test %d: call of %s.%s succeeded", i, tt.contract, tt.method)
+		}
+	}
+}
+
+// Tests that the system contracts are listed with their view methods.
+func TestContracts(t *testing.T) {
+	api := newTestAPI(t, nil)
+	want := []ContractInfo{{
+		Name:    "validators",
+		Address: common.HexToAddress("0x1000"),
+		Methods: []string{"isValidator(address)", "validators(uint64)"},
+	}}
+	if have := api.Contracts(); !reflect.DeepEqual(have, want) {
+		t.Errorf("contracts mismatch: have %+v, want %+v", have, want)
+	}
+}
+
+func packOrFail(t *testing.T, c *contract, method string, args ...interface{}) []byte {
+	input, err := c.abi.Pack(method, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input
+}
@@ -0,0 +1,32 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build go1.18
+// +build go1.18
+
+package abi
+
+import (
+	"testing"
+
+	"github.com/ong2020/go-orange/tests/fuzzers/corpus"
+)
+
+// FuzzABI is the native fuzz target of the go-fuzz harness, seeded with its
+// corpus. Run it with `go test -fuzz=FuzzABI`.
+func FuzzABI(f *testing.F) {
+	corpus.Fuzz(f, "corpus", Fuzz)
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package corpus contains helpers to build fuzzer corpora from chain data and to
+// seed the fuzz targets with them.
+package corpus
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/ongdb"
+	"github.com/ong2020/go-orange/rlp"
+)
+
+// Write stores the inputs in a corpus directory, naming each entry after the
+// SHA1 hash of its content like go-fuzz does, so existing entries are not
+// duplicated. The number of new entries is returned.
+func Write(dir string, inputs [][]byte) (int, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	var added int
+	for _, input := range inputs {
+		path := filepath.Join(dir, fmt.Sprintf("%x", sha1.Sum(input)))
+		if common.FileExist(path) {
+			continue
+		}
+		if err := ioutil.WriteFile(path, input, 0644); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
+
+// Read loads all the entries of a corpus directory. A missing directory is
+// treated as an empty corpus.
+func Read(dir string) ([][]byte, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var inputs [][]byte
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		input, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// ChainRLP collects the RLP encodings of the headers, bodies, transaction lists,
+// transactions and receipt lists of the canonical chain segment [first, last],
+// as seeds for the RLP decoding fuzzer. Missing blocks are skipped.
+func ChainRLP(db ongdb.Reader, first, last uint64) [][]byte {
+	var inputs [][]byte
+	for number := first; number <= last; number++ {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			continue
+		}
+		if header := rawdb.ReadHeaderRLP(db, hash, number); len(header) > 0 {
+			inputs = append(inputs, header)
+		}
+		if body := rawdb.ReadBodyRLP(db, hash, number); len(body) > 0 {
+			inputs = append(inputs, body)
+		}
+		if receipts := rawdb.ReadReceiptsRLP(db, hash, number); len(receipts) > 0 {
+			inputs = append(inputs, receipts)
+		}
+		if body := rawdb.ReadBody(db, hash, number); body != nil && len(body.Transactions) > 0 {
+			if blob, err := rlp.EncodeToBytes(body.Transactions); err == nil {
+				inputs = append(inputs, blob)
+			}
+			for _, tx := range body.Transactions {
+				if blob, err := rlp.EncodeToBytes(tx); err == nil {
+					inputs = append(inputs, blob)
+				}
+			}
+		}
+	}
+	return inputs
+}
+
+// Transactions collects the canonical binary encodings of the transactions in
+// the canonical chain segment [first, last], as seeds for the transaction pool
+// ingestion fuzzer.
+func Transactions(db ongdb.Reader, first, last uint64) [][]byte {
+	var inputs [][]byte
+	for number := first; number <= last; number++ {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			continue
+		}
+		body := rawdb.ReadBody(db, hash, number)
+		if body == nil {
+			continue
+		}
+		for _, tx := range body.Transactions {
+			if blob, err := tx.MarshalBinary(); err == nil {
+				inputs = append(inputs, blob)
+			}
+		}
+	}
+	return inputs
+}
+
+// CallData collects the input data of the contract calls in the canonical chain
+// segment [first, last], as seeds for the ABI unpacking fuzzer.
+func CallData(db ongdb.Reader, first, last uint64) [][]byte {
+	var inputs [][]byte
+	for number := first; number <= last; number++ {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			continue
+		}
+		body := rawdb.ReadBody(db, hash, number)
+		if body == nil {
+			continue
+		}
+		for _, tx := range body.Transactions {
+			if tx.To() != nil && len(tx.Data()) > 0 {
+				inputs = append(inputs, tx.Data())
+			}
+		}
+	}
+	return inputs
+}
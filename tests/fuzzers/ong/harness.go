@@ -0,0 +1,323 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ong contains a deterministic harness feeding message sequences into a
+// live `ong` protocol handler backed by a fake chain, used both as a fuzz target
+// and to reproduce protocol edge cases in regression tests.
+package ong
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/consensus/ongash"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/core/vm"
+	"github.com/ong2020/go-orange/crypto"
+	ongproto "github.com/ong2020/go-orange/ong/protocols/ong"
+	"github.com/ong2020/go-orange/p2p"
+	"github.com/ong2020/go-orange/p2p/enode"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/rlp"
+	"github.com/ong2020/go-orange/trie"
+)
+
+var (
+	bankKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	bankAddr   = crypto.PubkeyToAddress(bankKey.PublicKey)
+	bankFunds  = new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Oranger))
+
+	testChainLen = 64
+
+	chain    *core.BlockChain     // Fake chain shared by all runs, only ever read from
+	pool     = make(txPool)       // Transactions known to the fake pool
+	poolTxs  []*types.Transaction // Transactions of the fake pool in creation order
+	chainTxs []*types.Transaction // Transactions included in the fake chain
+)
+
+var (
+	errUnknownRequest = errors.New("unknown request code")
+	errUnansweredId   = errors.New("answered request not issued")
+)
+
+func init() {
+	db := rawdb.NewMemoryDatabase()
+	gspec := core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{bankAddr: {Balance: bankFunds}},
+	}
+	genesis := gspec.MustCommit(db)
+	signer := types.HomesteadSigner{}
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ongash.NewFaker(), db, testChainLen, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), common.Address{byte(i)}, big.NewInt(10000), params.TxGas, big.NewInt(params.GWei), nil), signer, bankKey)
+		gen.AddTx(tx)
+		chainTxs = append(chainTxs, tx)
+	})
+	chain, _ = core.NewBlockChain(db, nil, gspec.Config, ongash.NewFaker(), vm.Config{}, nil, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		panic(err)
+	}
+	for i := 0; i < 16; i++ {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(testChainLen+i), common.Address{byte(i)}, big.NewInt(10000), params.TxGas, big.NewInt(params.GWei), nil), signer, bankKey)
+		pool[tx.Hash()] = tx
+		poolTxs = append(poolTxs, tx)
+	}
+}
+
+// Step is a single action of a message sequence. It is either a message sent by
+// the remote peer, or a request issued by the local node which later responses
+// of the sequence can answer.
+type Step struct {
+	Request bool   // Whether the local node issues a request instead of receiving a message
+	Code    uint64 // Message code of the request or of the received message
+	Answers uint64 // Index + 1 of the local request an ong/66 response answers (0 = keep request id)
+	Payload []byte // RLP encoded content of the received message
+}
+
+// Sequence is a recorded series of steps to run against a handler speaking the
+// given protocol version.
+type Sequence struct {
+	Version uint
+	Steps   []Step
+}
+
+// EncodeSequence serializes a message sequence, so it can be stored as part of
+// a fuzzer corpus or alongside a regression test.
+func EncodeSequence(seq *Sequence) ([]byte, error) {
+	return rlp.EncodeToBytes(seq)
+}
+
+// DecodeSequence parses a message sequence serialized by EncodeSequence.
+func DecodeSequence(blob []byte) (*Sequence, error) {
+	seq := new(Sequence)
+	if err := rlp.DecodeBytes(blob, seq); err != nil {
+		return nil, err
+	}
+	for _, version := range ongproto.ProtocolVersions {
+		if seq.Version == version {
+			return seq, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported protocol version %d", seq.Version)
+}
+
+// Report summarizes how the handler reacted to a message sequence.
+type Report struct {
+	Steps     int            // Number of steps completed before the handler stopped
+	Delivered map[string]int // Packets forwarded to the backend, by name
+	Replies   map[uint64]int // Messages sent to the remote peer, by message code
+}
+
+// txPool is a fixed set of transactions to serve pooled transactions from.
+type txPool map[common.Hash]*types.Transaction
+
+func (p txPool) Get(hash common.Hash) *types.Transaction { return p[hash] }
+
+// backend is the data provider of the handler, which serves from the fake chain
+// and records the packets delivered to it instead of processing them.
+type backend struct {
+	report *Report
+	lock   sync.Mutex
+}
+
+func (b *backend) Chain() *core.BlockChain          { return chain }
+func (b *backend) StateBloom() *trie.SyncBloom      { return nil }
+func (b *backend) TxPool() ongproto.TxPool          { return pool }
+func (b *backend) AcceptTxs() bool                  { return true }
+func (b *backend) PeerInfo(id enode.ID) interface{} { return nil }
+
+func (b *backend) RunPeer(peer *ongproto.Peer, handler ongproto.Handler) error {
+	return handler(peer)
+}
+
+func (b *backend) Handle(peer *ongproto.Peer, packet ongproto.Packet) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.report.Delivered[packet.Name()]++
+	return nil
+}
+
+// stepRW wraps the handler side of the message pipe, signalling whenever the
+// handler is ready to read the next message. As the handler only reads after
+// fully processing the previous message, this allows running every step to
+// completion before starting the next one, keeping runs deterministic.
+type stepRW struct {
+	p2p.MsgReadWriter
+	idle chan struct{}
+	quit chan struct{}
+}
+
+func (rw *stepRW) ReadMsg() (p2p.Msg, error) {
+	select {
+	case rw.idle <- struct{}{}:
+	case <-rw.quit:
+	}
+	return rw.MsgReadWriter.ReadMsg()
+}
+
+// Run feeds a message sequence into a fresh `ong` protocol handler, running each
+// step to completion before starting the next one. The returned error is the one
+// the handler dropped the peer with, nil if it processed the entire sequence.
+func Run(seq *Sequence) (*Report, error) {
+	var (
+		report   = &Report{Delivered: make(map[string]int), Replies: make(map[uint64]int)}
+		back     = &backend{report: report}
+		app, net = p2p.MsgPipe()
+		rw       = &stepRW{MsgReadWriter: net, idle: make(chan struct{}), quit: make(chan struct{})}
+		peer     = ongproto.NewPeer(seq.Version, p2p.NewPeer(enode.ID{}, "fuzzer", nil), rw, pool)
+	)
+	defer peer.Close()
+
+	// Consume everything the handler sends, collecting the ids of local requests
+	var (
+		ids  []uint64
+		done = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		for {
+			msg, err := app.ReadMsg()
+			if err != nil {
+				return
+			}
+			if isRequest(msg.Code) {
+				var id uint64
+				if seq.Version >= ongproto.ONG34 {
+					var req struct {
+						RequestId uint64
+						Rest      rlp.RawValue
+					}
+					msg.Decode(&req)
+					id = req.RequestId
+				}
+				back.lock.Lock()
+				ids = append(ids, id)
+				back.lock.Unlock()
+			} else {
+				back.lock.Lock()
+				report.Replies[msg.Code]++
+				back.lock.Unlock()
+			}
+			msg.Discard()
+		}
+	}()
+	// Start the handler, tearing down the pipe if it drops the peer
+	errc := make(chan error, 1)
+	go func() {
+		err := ongproto.Handle(back, peer)
+		app.Close()
+		errc <- err
+	}()
+	defer func() {
+		close(rw.quit)
+		app.Close()
+		<-done
+	}()
+	// Wait for the handler to become ready and run the sequence step by step
+	wait := func() error {
+		select {
+		case <-rw.idle:
+			return nil
+		case err := <-errc:
+			return err
+		}
+	}
+	if err := wait(); err != nil {
+		return report, err
+	}
+	for _, step := range seq.Steps {
+		if step.Request {
+			if err := request(peer, step.Code); err != nil {
+				return report, err
+			}
+			report.Steps++
+			continue
+		}
+		payload := step.Payload
+		if step.Answers > 0 && seq.Version >= ongproto.ONG34 {
+			back.lock.Lock()
+			if step.Answers > uint64(len(ids)) {
+				back.lock.Unlock()
+				return report, errUnansweredId
+			}
+			id := ids[step.Answers-1]
+			back.lock.Unlock()
+
+			var err error
+			if payload, err = answer(payload, id); err != nil {
+				return report, err
+			}
+		}
+		// A failed write means the handler dropped the peer, reported by the wait
+		app.WriteMsg(p2p.Msg{Code: step.Code, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)})
+		if err := wait(); err != nil {
+			return report, err
+		}
+		report.Steps++
+	}
+	return report, nil
+}
+
+// isRequest returns whether a message sent by the handler is a data request.
+func isRequest(code uint64) bool {
+	switch code {
+	case ongproto.GetBlockHeadersMsg, ongproto.GetBlockBodiesMsg, ongproto.GetNodeDataMsg, ongproto.GetReceiptsMsg, ongproto.GetPooledTransactionsMsg:
+		return true
+	}
+	return false
+}
+
+// request issues a data request of the given kind to the remote peer, asking for
+// data of the fake chain.
+func request(peer *ongproto.Peer, code uint64) error {
+	var hashes []common.Hash
+	for i := uint64(1); i <= 4; i++ {
+		hashes = append(hashes, chain.GetCanonicalHash(i))
+	}
+	switch code {
+	case ongproto.GetBlockHeadersMsg:
+		return peer.RequestHeadersByNumber(1, 4, 0, false)
+	case ongproto.GetBlockBodiesMsg:
+		return peer.RequestBodies(hashes)
+	case ongproto.GetNodeDataMsg:
+		return peer.RequestNodeData([]common.Hash{chain.CurrentBlock().Root()})
+	case ongproto.GetReceiptsMsg:
+		return peer.RequestReceipts(hashes)
+	case ongproto.GetPooledTransactionsMsg:
+		return peer.RequestTxs([]common.Hash{poolTxs[0].Hash()})
+	}
+	return fmt.Errorf("%w: %d", errUnknownRequest, code)
+}
+
+// answer replaces the request id of an encoded ong/66 response.
+func answer(payload []byte, id uint64) ([]byte, error) {
+	var res struct {
+		RequestId uint64
+		Rest      rlp.RawValue
+	}
+	if err := rlp.DecodeBytes(payload, &res); err != nil {
+		return nil, err
+	}
+	res.RequestId = id
+	return rlp.EncodeToBytes(&res)
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	ongproto "github.com/ong2020/go-orange/ong/protocols/ong"
+	"github.com/ong2020/go-orange/rlp"
+)
+
+// message creates a step delivering the given packet, wrapped into an ong/66
+// envelope with the given request id if it's not nil.
+func message(t *testing.T, code uint64, packet interface{}, id *uint64) Step {
+	if id != nil {
+		packet = []interface{}{*id, packet}
+	}
+	payload, err := rlp.EncodeToBytes(packet)
+	if err != nil {
+		t.Fatalf("failed to encode packet: %v", err)
+	}
+	return Step{Code: code, Payload: payload}
+}
+
+// Tests that ong/66 responses are only delivered if they answer a live request,
+// regardless of the order they arrive in.
+func TestReorderedResponses(t *testing.T) {
+	var (
+		id      = uint64(0)
+		headers = ongproto.BlockHeadersPacket{chain.GetHeaderByNumber(1)}
+		bodies  = ongproto.BlockBodiesRLPPacket{chain.GetBodyRLP(chain.GetCanonicalHash(1))}
+	)
+	unsolicited := message(t, ongproto.BlockHeadersMsg, headers, &id)
+
+	answerHeaders := message(t, ongproto.BlockHeadersMsg, headers, &id)
+	answerHeaders.Answers = 1
+	answerBodies := message(t, ongproto.BlockBodiesMsg, bodies, &id)
+	answerBodies.Answers = 2
+
+	report, err := Run(&Sequence{
+		Version: ongproto.ONG34,
+		Steps: []Step{
+			unsolicited,
+			{Request: true, Code: ongproto.GetBlockHeadersMsg},
+			{Request: true, Code: ongproto.GetBlockBodiesMsg},
+			answerBodies,
+			answerHeaders,
+			answerHeaders, // duplicate
+		},
+	})
+	if err != nil {
+		t.Fatalf("handler dropped peer: %v", err)
+	}
+	if report.Steps != 6 {
+		t.Errorf("completed steps mismatch: have %d, want %d", report.Steps, 6)
+	}
+	want := map[string]int{"BlockHeaders": 1, "BlockBodies": 1}
+	if !reflect.DeepEqual(report.Delivered, want) {
+		t.Errorf("delivered packets mismatch: have %v, want %v", report.Delivered, want)
+	}
+}
+
+// Tests that messages above the size limit make the handler drop the peer.
+func TestOversizedMessage(t *testing.T) {
+	for _, version := range ongproto.ProtocolVersions {
+		report, err := Run(&Sequence{
+			Version: version,
+			Steps: []Step{
+				{Code: ongproto.TransactionsMsg, Payload: []byte{0xc0}},
+				{Code: ongproto.BlockBodiesMsg, Payload: make([]byte, oversizedMessage)},
+				{Code: ongproto.TransactionsMsg, Payload: []byte{0xc0}},
+			},
+		})
+		if err == nil {
+			t.Errorf("ong/%d: oversized message accepted", version)
+		}
+		if report.Steps != 1 {
+			t.Errorf("ong/%d: completed steps mismatch: have %d, want %d", version, report.Steps, 1)
+		}
+	}
+}
+
+// Tests that requests are answered from the fake chain.
+func TestServeRequests(t *testing.T) {
+	id := uint64(1)
+	report, err := Run(&Sequence{
+		Version: ongproto.ONG34,
+		Steps: []Step{
+			message(t, ongproto.GetBlockHeadersMsg, &ongproto.GetBlockHeadersPacket{Origin: ongproto.HashOrNumber{Number: 1}, Amount: 4}, &id),
+			message(t, ongproto.GetReceiptsMsg, ongproto.GetReceiptsPacket{chain.GetCanonicalHash(1)}, &id),
+			message(t, ongproto.GetPooledTransactionsMsg, ongproto.GetPooledTransactionsPacket{poolTxs[0].Hash()}, &id),
+		},
+	})
+	if err != nil {
+		t.Fatalf("handler dropped peer: %v", err)
+	}
+	want := map[uint64]int{ongproto.BlockHeadersMsg: 1, ongproto.ReceiptsMsg: 1, ongproto.PooledTransactionsMsg: 1}
+	if !reflect.DeepEqual(report.Replies, want) {
+		t.Errorf("replies mismatch: have %v, want %v", report.Replies, want)
+	}
+}
+
+// Tests that generated sequences and their outcome only depend on the input and
+// survive an encoding round trip.
+func TestGenerateDeterministic(t *testing.T) {
+	input := make([]byte, 4096)
+	for i := range input {
+		input[i] = byte(i * 7)
+	}
+	seq := Generate(input)
+	if len(seq.Steps) == 0 {
+		t.Fatal("no steps generated")
+	}
+	blob, err := EncodeSequence(seq)
+	if err != nil {
+		t.Fatalf("failed to encode sequence: %v", err)
+	}
+	if other, _ := EncodeSequence(Generate(input)); !bytes.Equal(blob, other) {
+		t.Fatal("generated sequences differ")
+	}
+	decoded, err := DecodeSequence(blob)
+	if err != nil {
+		t.Fatalf("failed to decode sequence: %v", err)
+	}
+	report1, err1 := Run(seq)
+	report2, err2 := Run(decoded)
+	if !reflect.DeepEqual(report1, report2) {
+		t.Errorf("reports differ: %+v != %+v", report1, report2)
+	}
+	if (err1 == nil) != (err2 == nil) || (err1 != nil && err1.Error() != err2.Error()) {
+		t.Errorf("errors differ: %v != %v", err1, err2)
+	}
+}
+
+// Tests that recorded sequences with an unknown protocol version are rejected.
+func TestDecodeSequenceVersion(t *testing.T) {
+	blob, _ := EncodeSequence(&Sequence{Version: 1})
+	if _, err := DecodeSequence(blob); err == nil {
+		t.Fatal("sequence with unsupported version accepted")
+	}
+	if _, err := Run(&Sequence{Version: ongproto.ONG34, Steps: []Step{{Request: true, Code: ongproto.StatusMsg}}}); !errors.Is(err, errUnknownRequest) {
+		t.Fatalf("unknown request error mismatch: have %v, want %v", err, errUnknownRequest)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package ong
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/big"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core/types"
+	ongproto "github.com/ong2020/go-orange/ong/protocols/ong"
+	"github.com/ong2020/go-orange/rlp"
+)
+
+const (
+	// maxSteps is the maximum number of steps generated for a single sequence.
+	maxSteps = 64
+
+	// oversizedMessage is the size of generated messages exceeding the protocol
+	// message size limit.
+	oversizedMessage = 10*1024*1024 + 1
+)
+
+// generator turns fuzzer input into a message sequence, drawing on the contents
+// of the fake chain so most messages refer to data the handler actually knows.
+type generator struct {
+	input     io.Reader
+	exhausted bool
+	version   uint
+}
+
+func (g *generator) read(size int) []byte {
+	out := make([]byte, size)
+	if _, err := io.ReadFull(g.input, out); err != nil {
+		g.exhausted = true
+	}
+	return out
+}
+
+func (g *generator) randomByte() byte {
+	return g.read(1)[0]
+}
+
+func (g *generator) randomBool() bool {
+	return g.randomByte()&1 == 1
+}
+
+func (g *generator) randomInt(max int) int {
+	if max == 0 {
+		return 0
+	}
+	if max <= 256 {
+		return int(g.randomByte()) % max
+	}
+	return int(binary.LittleEndian.Uint16(g.read(2))) % max
+}
+
+func (g *generator) randomUint64() uint64 {
+	return binary.BigEndian.Uint64(g.read(8))
+}
+
+func (g *generator) randomBlock() *types.Block {
+	return chain.GetBlockByNumber(uint64(g.randomInt(testChainLen + 1)))
+}
+
+func (g *generator) randomBlockHash() common.Hash {
+	if g.randomInt(4) != 0 {
+		return g.randomBlock().Hash()
+	}
+	return common.BytesToHash(g.read(common.HashLength))
+}
+
+func (g *generator) randomBlockHashes(max int) []common.Hash {
+	hashes := make([]common.Hash, g.randomInt(max+1))
+	for i := range hashes {
+		hashes[i] = g.randomBlockHash()
+	}
+	return hashes
+}
+
+func (g *generator) randomTx() *types.Transaction {
+	if g.randomBool() {
+		return chainTxs[g.randomInt(len(chainTxs))]
+	}
+	return poolTxs[g.randomInt(len(poolTxs))]
+}
+
+func (g *generator) randomTxHashes(max int) []common.Hash {
+	hashes := make([]common.Hash, g.randomInt(max+1))
+	for i := range hashes {
+		if g.randomInt(4) != 0 {
+			hashes[i] = g.randomTx().Hash()
+		} else {
+			hashes[i] = common.BytesToHash(g.read(common.HashLength))
+		}
+	}
+	return hashes
+}
+
+// message creates a step delivering the given packet. Requests and responses
+// are wrapped into an ong/66 envelope if the protocol version requires.
+func (g *generator) message(code uint64, packet interface{}, withId bool) Step {
+	if withId && g.version >= ongproto.ONG34 {
+		packet = &struct {
+			RequestId uint64
+			Packet    interface{}
+		}{g.randomUint64(), packet}
+	}
+	payload, err := rlp.EncodeToBytes(packet)
+	if err != nil {
+		panic(err)
+	}
+	return Step{Code: code, Payload: payload}
+}
+
+// response creates a step answering one of the requests of the local node, or
+// an unsolicited one if the request index doesn't exist.
+func (g *generator) response(code uint64, packet interface{}) Step {
+	step := g.message(code, packet, true)
+	if g.version >= ongproto.ONG34 {
+		step.Answers = uint64(g.randomInt(4))
+	}
+	return step
+}
+
+// step generates the next step of the sequence.
+func (g *generator) step() Step {
+	switch g.randomInt(12) {
+	case 0:
+		query := &ongproto.GetBlockHeadersPacket{
+			Amount:  uint64(g.randomInt(1100)),
+			Skip:    uint64(g.randomInt(8)),
+			Reverse: g.randomBool(),
+		}
+		if g.randomBool() {
+			query.Origin.Hash = g.randomBlockHash()
+		} else {
+			query.Origin.Number = uint64(g.randomInt(2 * testChainLen))
+		}
+		return g.message(ongproto.GetBlockHeadersMsg, query, true)
+
+	case 1:
+		return g.message(ongproto.GetBlockBodiesMsg, ongproto.GetBlockBodiesPacket(g.randomBlockHashes(32)), true)
+
+	case 2:
+		hashes := make([]common.Hash, g.randomInt(8))
+		for i := range hashes {
+			hashes[i] = g.randomBlock().Root()
+		}
+		return g.message(ongproto.GetNodeDataMsg, ongproto.GetNodeDataPacket(hashes), true)
+
+	case 3:
+		return g.message(ongproto.GetReceiptsMsg, ongproto.GetReceiptsPacket(g.randomBlockHashes(32)), true)
+
+	case 4:
+		return g.message(ongproto.GetPooledTransactionsMsg, ongproto.GetPooledTransactionsPacket(g.randomTxHashes(32)), true)
+
+	case 5:
+		// Block announcements, mostly of blocks already known to the node
+		ann := make(ongproto.NewBlockHashesPacket, g.randomInt(16))
+		for i := range ann {
+			block := g.randomBlock()
+			ann[i].Hash, ann[i].Number = block.Hash(), block.NumberU64()
+			if g.randomInt(4) == 0 {
+				ann[i].Number = g.randomUint64()
+			}
+		}
+		return g.message(ongproto.NewBlockHashesMsg, ann, false)
+
+	case 6:
+		// Block propagation, mostly of stale blocks or ones with a bogus difficulty
+		block := g.randomBlock()
+		if g.randomBool() {
+			header := block.Header()
+			header.Number = new(big.Int).SetUint64(g.randomUint64())
+			block = block.WithSeal(header)
+		}
+		td := new(big.Int).SetBytes(g.read(g.randomInt(40)))
+		return g.message(ongproto.NewBlockMsg, &ongproto.NewBlockPacket{Block: block, TD: td}, false)
+
+	case 7:
+		txs := make(ongproto.TransactionsPacket, g.randomInt(16))
+		for i := range txs {
+			txs[i] = g.randomTx()
+		}
+		return g.message(ongproto.TransactionsMsg, txs, false)
+
+	case 8:
+		return g.message(ongproto.NewPooledTransactionHashesMsg, ongproto.NewPooledTransactionHashesPacket(g.randomTxHashes(64)), false)
+
+	case 9:
+		codes := []uint64{ongproto.GetBlockHeadersMsg, ongproto.GetBlockBodiesMsg, ongproto.GetNodeDataMsg, ongproto.GetReceiptsMsg, ongproto.GetPooledTransactionsMsg}
+		return Step{Request: true, Code: codes[g.randomInt(len(codes))]}
+
+	case 10:
+		// Responses to local requests, possibly reordered, duplicated or unsolicited
+		switch g.randomInt(5) {
+		case 0:
+			headers := make(ongproto.BlockHeadersPacket, g.randomInt(8))
+			for i := range headers {
+				headers[i] = g.randomBlock().Header()
+			}
+			return g.response(ongproto.BlockHeadersMsg, headers)
+		case 1:
+			bodies := make(ongproto.BlockBodiesRLPPacket, g.randomInt(8))
+			for i := range bodies {
+				bodies[i] = chain.GetBodyRLP(g.randomBlock().Hash())
+			}
+			return g.response(ongproto.BlockBodiesMsg, bodies)
+		case 2:
+			var nodes ongproto.NodeDataPacket
+			if node, err := chain.TrieNode(g.randomBlock().Root()); err == nil {
+				nodes = append(nodes, node)
+			}
+			return g.response(ongproto.NodeDataMsg, nodes)
+		case 3:
+			receipts := make(ongproto.ReceiptsPacket, g.randomInt(8))
+			for i := range receipts {
+				receipts[i] = chain.GetReceiptsByHash(g.randomBlock().Hash())
+			}
+			return g.response(ongproto.ReceiptsMsg, receipts)
+		default:
+			txs := make(ongproto.PooledTransactionsPacket, g.randomInt(8))
+			for i := range txs {
+				txs[i] = g.randomTx()
+			}
+			return g.response(ongproto.PooledTransactionsMsg, txs)
+		}
+
+	default:
+		// Junk of any message code, occasionally exceeding the size limit
+		code := uint64(g.randomInt(0x20))
+		if g.randomInt(16) == 0 {
+			return Step{Code: code, Payload: make([]byte, oversizedMessage)}
+		}
+		return Step{Code: code, Payload: g.read(g.randomInt(256))}
+	}
+}
+
+// Generate deterministically derives a message sequence from arbitrary input.
+func Generate(input []byte) *Sequence {
+	g := &generator{input: bytes.NewReader(input)}
+	g.version = ongproto.ProtocolVersions[g.randomInt(len(ongproto.ProtocolVersions))]
+
+	seq := &Sequence{Version: g.version}
+	for len(seq.Steps) < maxSteps {
+		step := g.step()
+		if g.exhausted {
+			break
+		}
+		seq.Steps = append(seq.Steps, step)
+	}
+	return seq
+}
+
+// Fuzz runs a message sequence against the `ong` protocol handler. The input is
+// first tried as an encoded sequence, so recorded sequences can be replayed, and
+// is otherwise used to generate one.
+func Fuzz(input []byte) int {
+	seq, err := DecodeSequence(input)
+	if err != nil {
+		seq = Generate(input)
+	}
+	if len(seq.Steps) == 0 {
+		return -1
+	}
+	if _, err := Run(seq); err != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 The go-orange Authors
+// This file is part of the go-orange library.
+//
+// The go-orange library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-orange library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-orange library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ong2020/go-orange/common"
+	"github.com/ong2020/go-orange/core"
+	"github.com/ong2020/go-orange/core/rawdb"
+	"github.com/ong2020/go-orange/core/state"
+	"github.com/ong2020/go-orange/core/types"
+	"github.com/ong2020/go-orange/crypto"
+	"github.com/ong2020/go-orange/event"
+	"github.com/ong2020/go-orange/params"
+	"github.com/ong2020/go-orange/trie"
+)
+
+var (
+	keys   []*ecdsa.PrivateKey
+	signer = types.LatestSigner(params.TestChainConfig)
+)
+
+func init() {
+	keys = make([]*ecdsa.PrivateKey, 4)
+	for i := range keys {
+		keys[i], _ = crypto.ToECDSA(crypto.Keccak256([]byte{byte(i)}))
+	}
+}
+
+// testBlockChain is a minimal chain for the pool to read its state from.
+type testBlockChain struct {
+	statedb       *state.StateDB
+	chainHeadFeed *event.Feed
+}
+
+func (bc *testBlockChain) CurrentBlock() *types.Block {
+	return types.NewBlock(&types.Header{GasLimit: 10000000}, nil, nil, nil, trie.NewStackTrie(nil))
+}
+
+func (bc *testBlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.CurrentBlock()
+}
+
+func (bc *testBlockChain) StateAt(common.Hash) (*state.StateDB, error) {
+	return bc.statedb, nil
+}
+
+func (bc *testBlockChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return bc.chainHeadFeed.Subscribe(ch)
+}
+
+// newPool creates a transaction pool on top of a state funding the fuzzer keys,
+// with limits small enough for the fuzzer to hit them.
+func newPool() *core.TxPool {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	for _, key := range keys {
+		statedb.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(100000000))
+	}
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	config.AccountSlots = 4
+	config.GlobalSlots = 16
+	config.AccountQueue = 4
+	config.GlobalQueue = 16
+
+	return core.NewTxPool(config, params.TestChainConfig, &testBlockChain{statedb, new(event.Feed)})
+}
+
+// Fuzz feeds transactions into a pool and checks its invariants afterwards. The
+// input is first tried as a single encoded transaction, then read as a sequence
+// of 8 byte transaction specs (sender, nonce, price, gas, value, type, origin),
+// which are signed by the fuzzer keys to get past the signature checks.
+func Fuzz(input []byte) int {
+	// Don't generate insanely large test cases, not much value in them
+	if len(input) > 8*1024 {
+		return 0
+	}
+	pool := newPool()
+	defer pool.Stop()
+
+	if tx := new(types.Transaction); tx.UnmarshalBinary(input) == nil {
+		pool.AddRemotesSync([]*types.Transaction{tx})
+	}
+	var (
+		r    = bytes.NewReader(input)
+		spec [8]byte
+	)
+	for {
+		if _, err := io.ReadFull(r, spec[:]); err != nil {
+			break
+		}
+		var (
+			key   = keys[int(spec[0])%len(keys)]
+			nonce = uint64(spec[1] % 16)
+			price = big.NewInt(int64(spec[2]) + 1)
+			gas   = params.TxGas + uint64(spec[3])*1000
+			value = big.NewInt(int64(spec[4]) << 16)
+			inner types.TxData
+		)
+		if spec[5]%2 == 0 {
+			inner = &types.LegacyTx{Nonce: nonce, GasPrice: price, Gas: gas, To: &common.Address{spec[6]}, Value: value}
+		} else {
+			inner = &types.AccessListTx{ChainID: params.TestChainConfig.ChainID, Nonce: nonce, GasPrice: price, Gas: gas, To: &common.Address{spec[6]}, Value: value,
+				AccessList: types.AccessList{{Address: common.Address{spec[6]}, StorageKeys: []common.Hash{{spec[7]}}}}}
+		}
+		tx, err := types.SignNewTx(key, signer, inner)
+		if err != nil {
+			panic(err)
+		}
+		if spec[7]%2 == 0 {
+			pool.AddLocal(tx)
+		} else {
+			pool.AddRemotesSync([]*types.Transaction{tx})
+		}
+	}
+	// All accounts start with a zero nonce, so pending transactions must be
+	// gapless from zero and queued ones must not be executable.
+	pending, queued := pool.Content()
+	for addr, txs := range pending {
+		for i, tx := range txs {
+			if tx.Nonce() != uint64(i) {
+				panic(fmt.Sprintf("account %x: pending nonce gap: have %d, want %d", addr, tx.Nonce(), i))
+			}
+		}
+		if nonce := pool.Nonce(addr); nonce != uint64(len(txs)) {
+			panic(fmt.Sprintf("account %x: pending nonce mismatch: have %d, want %d", addr, nonce, len(txs)))
+		}
+	}
+	for addr, txs := range queued {
+		for _, tx := range txs {
+			if tx.Nonce() == uint64(len(pending[addr])) {
+				panic(fmt.Sprintf("account %x: executable transaction %d queued", addr, tx.Nonce()))
+			}
+		}
+	}
+	return 1
+}